@@ -0,0 +1,28 @@
+package ldap
+
+import (
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// abandonRequest asks the server to stop processing the operation with the
+// given message ID, see https://datatracker.ietf.org/doc/html/rfc4511#section-4.11
+type abandonRequest struct {
+	messageID int64
+}
+
+func (req abandonRequest) appendTo(envelope *ber.Packet) error {
+	envelope.AppendChild(ber.NewInteger(ber.ClassApplication, ber.TypePrimitive, ApplicationAbandonRequest, req.messageID, ApplicationMap[ApplicationAbandonRequest]))
+	return nil
+}
+
+// abandon sends an abandon request for the operation with the given message
+// ID. There is no response to an abandon request, so it is finished right
+// after being sent.
+func (l *Conn) abandon(messageID int64) error {
+	msgCtx, err := l.doRequest(abandonRequest{messageID: messageID})
+	if err != nil {
+		return err
+	}
+	l.finishMessage(msgCtx)
+	return nil
+}
@@ -0,0 +1,200 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogRecord is a write operation recorded by the OpenLDAP accesslog
+// overlay in an auditWriteObject entry
+type AccessLogRecord struct {
+	// Start and End are the reqStart and reqEnd times of the operation
+	Start time.Time
+	End   time.Time
+	// Type is the reqType of the operation: "add", "modify", "delete" or
+	// "modrdn"
+	Type    string
+	DN      string
+	AuthzID string
+	Result  int
+	// Request is the operation as *AddRequest, *ModifyRequest, *DelRequest
+	// or *ModifyDNRequest
+	Request interface{}
+
+	reqStart string
+}
+
+// ParseAccessLogEntry converts an auditWriteObject entry of the accesslog
+// database into an AccessLogRecord
+func ParseAccessLogEntry(entry *Entry) (*AccessLogRecord, error) {
+	r := &AccessLogRecord{
+		Type:     entry.GetEqualFoldAttributeValue("reqType"),
+		DN:       entry.GetEqualFoldAttributeValue("reqDN"),
+		AuthzID:  entry.GetEqualFoldAttributeValue("reqAuthzID"),
+		reqStart: entry.GetEqualFoldAttributeValue("reqStart"),
+	}
+	var err error
+	if r.Start, err = ParseGeneralizedTime(r.reqStart); err != nil {
+		return nil, err
+	}
+	if end := entry.GetEqualFoldAttributeValue("reqEnd"); end != "" {
+		if r.End, err = ParseGeneralizedTime(end); err != nil {
+			return nil, err
+		}
+	}
+	if result := entry.GetEqualFoldAttributeValue("reqResult"); result != "" {
+		if r.Result, err = strconv.Atoi(result); err != nil {
+			return nil, fmt.Errorf("ldap: invalid reqResult '%s' in accesslog entry %s", result, entry.DN)
+		}
+	}
+
+	mods, err := parseAccessLogMods(entry.GetEqualFoldAttributeValues("reqMod"))
+	if err != nil {
+		return nil, fmt.Errorf("%s in accesslog entry %s", err, entry.DN)
+	}
+	switch strings.ToLower(r.Type) {
+	case "add":
+		req := NewAddRequest(r.DN, nil)
+		for _, mod := range mods {
+			req.Attribute(mod.attribute, mod.values)
+		}
+		r.Request = req
+	case "modify":
+		req := NewModifyRequest(r.DN, nil)
+		for _, mod := range mods {
+			req.appendChange(mod.operation, mod.attribute, mod.values)
+		}
+		r.Request = req
+	case "delete":
+		r.Request = NewDelRequest(r.DN, nil)
+	case "modrdn":
+		r.Request = NewModifyDNRequest(
+			r.DN,
+			entry.GetEqualFoldAttributeValue("reqNewRDN"),
+			strings.EqualFold(entry.GetEqualFoldAttributeValue("reqDeleteOldRDN"), "TRUE"),
+			entry.GetEqualFoldAttributeValue("reqNewSuperior"),
+		)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported reqType '%s' in accesslog entry %s", r.Type, entry.DN)
+	}
+	return r, nil
+}
+
+type accessLogMod struct {
+	operation uint
+	attribute string
+	values    []string
+}
+
+// accessLogOperations maps the operation characters of reqMod values to
+// modify operations
+var accessLogOperations = map[byte]uint{
+	'+': AddAttribute,
+	'-': DeleteAttribute,
+	'=': ReplaceAttribute,
+	'#': IncrementAttribute,
+}
+
+// parseAccessLogMods parses reqMod values like "description:+ value",
+// merging consecutive values of the same attribute and operation
+func parseAccessLogMods(values []string) ([]*accessLogMod, error) {
+	var mods []*accessLogMod
+	var last *accessLogMod
+	for _, value := range values {
+		if strings.HasPrefix(value, "-:") {
+			// separator between modifications written by newer versions
+			last = nil
+			continue
+		}
+		colon := strings.IndexByte(value, ':')
+		if colon < 0 || colon+1 >= len(value) {
+			return nil, fmt.Errorf("ldap: invalid reqMod '%s'", value)
+		}
+		attribute := value[:colon]
+		operation, ok := accessLogOperations[value[colon+1]]
+		if !ok {
+			return nil, fmt.Errorf("ldap: invalid reqMod operation '%s'", value)
+		}
+		rest := value[colon+2:]
+		if last == nil || last.operation != operation || !strings.EqualFold(last.attribute, attribute) {
+			last = &accessLogMod{operation: operation, attribute: attribute}
+			mods = append(mods, last)
+		}
+		if strings.HasPrefix(rest, " ") {
+			last.values = append(last.values, rest[1:])
+		}
+	}
+	return mods, nil
+}
+
+// accessLogPagingSize is the page size of the searches of AccessLogReader
+const accessLogPagingSize = 500
+
+// AccessLogReader reads the successful write operations recorded by the
+// OpenLDAP accesslog overlay in order, remembering the reqStart of the last
+// record read so that repeated calls of Next only return new records
+type AccessLogReader struct {
+	client Client
+	baseDN string
+	cursor string
+}
+
+// NewAccessLogReader returns a reader for the accesslog database with the
+// given suffix, e.g. "cn=accesslog", starting with the records after since.
+// If since is zero, all records are read.
+func NewAccessLogReader(client Client, baseDN string, since time.Time) *AccessLogReader {
+	r := &AccessLogReader{client: client, baseDN: baseDN}
+	if !since.IsZero() {
+		r.cursor = since.UTC().Format("20060102150405.000000Z")
+	}
+	return r
+}
+
+// Cursor returns the reqStart value of the last record read, which can be
+// persisted and restored with SetCursor to resume reading
+func (r *AccessLogReader) Cursor() string {
+	return r.cursor
+}
+
+// SetCursor sets the reqStart value after which records are read
+func (r *AccessLogReader) SetCursor(cursor string) {
+	r.cursor = cursor
+}
+
+// Next returns the records written since the last call, ordered by their
+// start time
+func (r *AccessLogReader) Next() ([]*AccessLogRecord, error) {
+	filter := "(&(objectClass=auditWriteObject)(reqResult=0))"
+	if r.cursor != "" {
+		filter = "(&(objectClass=auditWriteObject)(reqResult=0)(reqStart>=" + EscapeFilter(r.cursor) + "))"
+	}
+	result, err := r.client.SearchWithPaging(NewSearchRequest(
+		r.baseDN, ScopeSingleLevel, NeverDerefAliases, 0, 0, false,
+		filter, []string{"*"}, nil,
+	), accessLogPagingSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*AccessLogRecord
+	for _, entry := range result.Entries {
+		record, err := ParseAccessLogEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		// reqStart values share one format and sort lexically
+		if record.reqStart > r.cursor {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].reqStart < records[j].reqStart
+	})
+	if len(records) > 0 {
+		r.cursor = records[len(records)-1].reqStart
+	}
+	return records, nil
+}
@@ -0,0 +1,132 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAccessLogEntry(t *testing.T) {
+	record, err := ParseAccessLogEntry(NewEntry("reqStart=20240102030405.000001Z,cn=accesslog", map[string][]string{
+		"reqStart":   {"20240102030405.000001Z"},
+		"reqEnd":     {"20240102030405.000002Z"},
+		"reqType":    {"modify"},
+		"reqDN":      {"uid=jdoe,dc=example,dc=com"},
+		"reqResult":  {"0"},
+		"reqAuthzID": {"cn=admin,dc=example,dc=com"},
+		"reqMod": {
+			"mail:+ jdoe@example.com",
+			"mail:+ john@example.com",
+			"description:-",
+			"-:",
+			"mail:- old@example.com",
+			"uidNumber:# 1",
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 1000, time.UTC); !record.Start.Equal(want) {
+		t.Errorf("unexpected start %s", record.Start)
+	}
+	if record.AuthzID != "cn=admin,dc=example,dc=com" || record.Result != 0 {
+		t.Errorf("unexpected record %+v", record)
+	}
+	req, ok := record.Request.(*ModifyRequest)
+	if !ok {
+		t.Fatalf("unexpected request %T", record.Request)
+	}
+	expected := NewModifyRequest("uid=jdoe,dc=example,dc=com", nil)
+	expected.Add("mail", []string{"jdoe@example.com", "john@example.com"})
+	expected.Delete("description", nil)
+	expected.Delete("mail", []string{"old@example.com"})
+	expected.Increment("uidNumber", "1")
+	if !reflect.DeepEqual(req, expected) {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestParseAccessLogEntryAdd(t *testing.T) {
+	record, err := ParseAccessLogEntry(NewEntry("reqStart=20240102030405.000001Z,cn=accesslog", map[string][]string{
+		"reqStart": {"20240102030405.000001Z"},
+		"reqType":  {"add"},
+		"reqDN":    {"uid=jdoe,dc=example,dc=com"},
+		"reqMod":   {"objectClass:+ person", "objectClass:+ top", "cn:+ John"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewAddRequest("uid=jdoe,dc=example,dc=com", nil)
+	expected.Attribute("objectClass", []string{"person", "top"})
+	expected.Attribute("cn", []string{"John"})
+	if !reflect.DeepEqual(record.Request, expected) {
+		t.Errorf("unexpected request %+v", record.Request)
+	}
+
+	if _, err := ParseAccessLogEntry(NewEntry("x", map[string][]string{
+		"reqStart": {"20240102030405.000001Z"},
+		"reqType":  {"modify"},
+		"reqMod":   {"mail:? x"},
+	})); err == nil {
+		t.Error("expected error for invalid operation")
+	}
+}
+
+func TestAccessLogReader(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	filters := make(chan string, 2)
+	respond := func(entries ...*Entry) {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		filter, _ := DecompileFilter(req.Children[1].Children[6])
+		filters <- filter
+		sendSearchResult(ptc, req.Children[0].Value.(int64), LDAPResultSuccess, entries...)
+	}
+	go func() {
+		respond(
+			NewEntry("reqStart=20240102030406.000000Z,cn=accesslog", map[string][]string{
+				"reqStart": {"20240102030406.000000Z"}, "reqType": {"delete"}, "reqDN": {"uid=b,dc=example"},
+			}),
+			NewEntry("reqStart=20240102030405.000000Z,cn=accesslog", map[string][]string{
+				"reqStart": {"20240102030405.000000Z"}, "reqType": {"delete"}, "reqDN": {"uid=a,dc=example"},
+			}),
+		)
+		respond(NewEntry("reqStart=20240102030406.000000Z,cn=accesslog", map[string][]string{
+			"reqStart": {"20240102030406.000000Z"}, "reqType": {"delete"}, "reqDN": {"uid=b,dc=example"},
+		}))
+	}()
+
+	reader := NewAccessLogReader(conn, "cn=accesslog", time.Time{})
+	records, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].DN != "uid=a,dc=example" || records[1].DN != "uid=b,dc=example" {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if reader.Cursor() != "20240102030406.000000Z" {
+		t.Errorf("unexpected cursor %s", reader.Cursor())
+	}
+	if filter := <-filters; filter != "(&(objectClass=auditWriteObject)(reqResult=0))" {
+		t.Errorf("unexpected filter %s", filter)
+	}
+
+	records, err = reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no new records, got %+v", records)
+	}
+	if filter := <-filters; filter != "(&(objectClass=auditWriteObject)(reqResult=0)(reqStart>=20240102030406.000000Z))" {
+		t.Errorf("unexpected filter %s", filter)
+	}
+}
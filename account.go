@@ -0,0 +1,55 @@
+package ldap
+
+import "errors"
+
+// ErrUnsupportedServer is returned by operations which are not supported for
+// the detected directory server, see RootDSE.Vendor
+var ErrUnsupportedServer = errors.New("ldap: operation not supported for this directory server")
+
+// EnableAccount enables the account with the given DN, see DisableAccount
+func (l *Conn) EnableAccount(dn string) error {
+	return l.setAccountEnabled(dn, true)
+}
+
+// DisableAccount disables the account with the given DN using the mechanism
+// of the directory server detected from the root DSE: the ACCOUNTDISABLE
+// flag of userAccountControl on Active Directory, nsAccountLock on 389
+// Directory Server and a permanent ppolicy lockout (pwdAccountLockedTime) on
+// OpenLDAP, which requires the ppolicy overlay. ErrUnsupportedServer is
+// returned for other servers.
+func (l *Conn) DisableAccount(dn string) error {
+	return l.setAccountEnabled(dn, false)
+}
+
+func (l *Conn) setAccountEnabled(dn string, enabled bool) error {
+	rootDSE, err := l.RootDSE()
+	if err != nil {
+		return err
+	}
+
+	var attr, disabledValue string
+	switch rootDSE.Vendor() {
+	case VendorActiveDirectory:
+		if enabled {
+			_, err = l.ModifyUserAccountControl(dn, 0, UACAccountDisable)
+		} else {
+			_, err = l.ModifyUserAccountControl(dn, UACAccountDisable, 0)
+		}
+		return err
+	case Vendor389DS:
+		attr, disabledValue = "nsAccountLock", "true"
+	case VendorOpenLDAP:
+		attr, disabledValue = "pwdAccountLockedTime", ppolicyPermanentLockout
+	default:
+		return ErrUnsupportedServer
+	}
+
+	req := NewModifyRequest(dn, nil)
+	if enabled {
+		// replacing without values removes the attribute if present
+		req.Replace(attr, nil)
+	} else {
+		req.Replace(attr, []string{disabledValue})
+	}
+	return l.Modify(req)
+}
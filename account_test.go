@@ -0,0 +1,92 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestDisableAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		rootDSE map[string][]string
+		enable  bool
+		attr    string
+		values  []string
+	}{
+		{
+			name:    "389-ds disable",
+			rootDSE: map[string][]string{"vendorName": {"389 Project"}},
+			attr:    "nsAccountLock",
+			values:  []string{"true"},
+		},
+		{
+			name:    "389-ds enable",
+			rootDSE: map[string][]string{"vendorName": {"389 Project"}},
+			enable:  true,
+			attr:    "nsAccountLock",
+		},
+		{
+			name:    "openldap disable",
+			rootDSE: map[string][]string{"objectClass": {"top", "OpenLDAProotDSE"}},
+			attr:    "pwdAccountLockedTime",
+			values:  []string{"000001010000Z"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ptc := newPacketTranslatorConn()
+			defer ptc.Close()
+
+			conn := NewConn(ptc, false)
+			conn.Start()
+			defer conn.Close()
+
+			requests := make(chan *ber.Packet, 1)
+			go func() {
+				respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", tc.rootDSE))
+				req, err := respondWithResult(ptc, ApplicationModifyResponse, LDAPResultSuccess)
+				if err != nil {
+					return
+				}
+				requests <- req
+			}()
+
+			var err error
+			if tc.enable {
+				err = conn.EnableAccount("uid=user,dc=example")
+			} else {
+				err = conn.DisableAccount("uid=user,dc=example")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			change := (<-requests).Children[1].Children[1].Children[0]
+			if change.Children[0].Value != int64(ReplaceAttribute) || change.Children[1].Children[0].Value != tc.attr {
+				t.Errorf("unexpected change %v", change)
+			}
+			var values []string
+			for _, value := range change.Children[1].Children[1].Children {
+				values = append(values, value.Value.(string))
+			}
+			if len(values) != len(tc.values) || (len(values) > 0 && values[0] != tc.values[0]) {
+				t.Errorf("expected values %v, got %v", tc.values, values)
+			}
+		})
+	}
+}
+
+func TestDisableAccountUnsupported(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", map[string][]string{"vendorName": {"Example"}}))
+	if err := conn.DisableAccount("uid=user,dc=example"); !errors.Is(err, ErrUnsupportedServer) {
+		t.Errorf("expected ErrUnsupportedServer, got %v", err)
+	}
+}
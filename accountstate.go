@@ -0,0 +1,103 @@
+package ldap
+
+import "time"
+
+// ADAccountState is the state of an Active Directory account, computed from
+// its userAccountControl, msDS-User-Account-Control-Computed, pwdLastSet,
+// accountExpires, lockoutTime and msDS-UserPasswordExpiryTimeComputed
+// attributes
+type ADAccountState struct {
+	Disabled bool
+	// Locked is true if the account is locked out after too many failed
+	// logons
+	Locked      bool
+	LockoutTime time.Time
+
+	// PasswordMustChange is true if the password has to be changed at the
+	// next logon
+	PasswordMustChange   bool
+	PasswordExpired      bool
+	PasswordNeverExpires bool
+	PasswordLastSet      time.Time
+	// PasswordExpiresAt is zero if the password does not expire
+	PasswordExpiresAt time.Time
+
+	AccountExpired bool
+	// AccountExpiresAt is zero if the account does not expire
+	AccountExpiresAt time.Time
+}
+
+// adAccountStateAttributes are read by GetADAccountState. The computed
+// attributes are only returned for base searches.
+var adAccountStateAttributes = []string{
+	"userAccountControl", "msDS-User-Account-Control-Computed", "pwdLastSet",
+	"accountExpires", "lockoutTime", "msDS-UserPasswordExpiryTimeComputed",
+}
+
+// Usable returns true if the account can log on: it is neither disabled,
+// locked nor expired and its password is valid
+func (s *ADAccountState) Usable() bool {
+	return !s.Disabled && !s.Locked && !s.AccountExpired && !s.PasswordExpired && !s.PasswordMustChange
+}
+
+// NewADAccountState computes the state of the account at the given time
+// from its entry. The computed attributes msDS-User-Account-Control-Computed
+// and msDS-UserPasswordExpiryTimeComputed take lockout durations and
+// password policies into account; without them lockoutTime alone decides
+// about lockouts and password expiry is unknown unless it is flagged in
+// userAccountControl.
+func NewADAccountState(entry *Entry, now time.Time) (*ADAccountState, error) {
+	var attrs struct {
+		UserAccountControl UserAccountControl `ldap:"userAccountControl"`
+		Computed           UserAccountControl `ldap:"msDS-User-Account-Control-Computed"`
+		PwdLastSet         *FileTime          `ldap:"pwdLastSet"`
+		AccountExpires     FileTime           `ldap:"accountExpires"`
+		LockoutTime        FileTime           `ldap:"lockoutTime"`
+		PasswordExpiry     *FileTime          `ldap:"msDS-UserPasswordExpiryTimeComputed"`
+	}
+	if err := NewDecoder(DecodeWithCaseInsensitive(true)).Decode(entry, &attrs); err != nil {
+		return nil, err
+	}
+	uac := attrs.UserAccountControl | attrs.Computed
+	hasComputed := entry.getEqualFoldAttribute("msDS-User-Account-Control-Computed") != nil
+
+	s := &ADAccountState{
+		Disabled:             uac.Has(UACAccountDisable),
+		PasswordNeverExpires: uac.Has(UACDontExpirePassword),
+		PasswordExpired:      uac.Has(UACPasswordExpired),
+		LockoutTime:          attrs.LockoutTime.Time(),
+		AccountExpiresAt:     attrs.AccountExpires.Time(),
+	}
+
+	if hasComputed {
+		s.Locked = uac.Has(UACLockout)
+	} else {
+		s.Locked = uac.Has(UACLockout) || attrs.LockoutTime.IsSet()
+	}
+
+	if attrs.PwdLastSet != nil {
+		s.PasswordLastSet = attrs.PwdLastSet.Time()
+		s.PasswordMustChange = *attrs.PwdLastSet == 0
+	}
+	if attrs.PasswordExpiry != nil && !s.PasswordNeverExpires {
+		s.PasswordExpiresAt = attrs.PasswordExpiry.Time()
+		if !s.PasswordExpiresAt.IsZero() && !now.Before(s.PasswordExpiresAt) {
+			s.PasswordExpired = true
+		}
+	}
+
+	if !s.AccountExpiresAt.IsZero() && !now.Before(s.AccountExpiresAt) {
+		s.AccountExpired = true
+	}
+	return s, nil
+}
+
+// GetADAccountState reads the attributes of the account with the given DN
+// and computes its current state, see NewADAccountState
+func (l *Conn) GetADAccountState(dn string) (*ADAccountState, error) {
+	entry, err := l.GetEntry(dn, adAccountStateAttributes...)
+	if err != nil {
+		return nil, err
+	}
+	return NewADAccountState(entry, time.Now())
+}
@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewADAccountState(t *testing.T) {
+	now := time.Date(2022, 3, 15, 10, 0, 0, 0, time.UTC)
+	fileTime := func(t time.Time) string {
+		return strconv.FormatInt(int64(NewFileTime(t)), 10)
+	}
+
+	tests := []struct {
+		name  string
+		attrs map[string][]string
+		check func(s *ADAccountState) bool
+	}{
+		{
+			name: "usable",
+			attrs: map[string][]string{
+				"userAccountControl":                  {"512"},
+				"msDS-User-Account-Control-Computed":  {"0"},
+				"pwdLastSet":                          {fileTime(now.AddDate(0, 0, -10))},
+				"accountExpires":                      {"9223372036854775807"},
+				"lockoutTime":                         {fileTime(now.Add(-time.Hour))},
+				"msDS-UserPasswordExpiryTimeComputed": {fileTime(now.AddDate(0, 0, 20))},
+			},
+			check: (*ADAccountState).Usable,
+		},
+		{
+			name:  "disabled",
+			attrs: map[string][]string{"userAccountControl": {"514"}},
+			check: func(s *ADAccountState) bool { return s.Disabled && !s.Usable() },
+		},
+		{
+			name:  "locked computed",
+			attrs: map[string][]string{"userAccountControl": {"512"}, "msDS-User-Account-Control-Computed": {"16"}},
+			check: func(s *ADAccountState) bool { return s.Locked },
+		},
+		{
+			name:  "locked by lockout time",
+			attrs: map[string][]string{"userAccountControl": {"512"}, "lockoutTime": {fileTime(now.Add(-time.Minute))}},
+			check: func(s *ADAccountState) bool { return s.Locked && s.LockoutTime.Equal(now.Add(-time.Minute)) },
+		},
+		{
+			name: "password expired",
+			attrs: map[string][]string{
+				"userAccountControl":                  {"512"},
+				"msDS-UserPasswordExpiryTimeComputed": {fileTime(now.Add(-time.Second))},
+			},
+			check: func(s *ADAccountState) bool { return s.PasswordExpired },
+		},
+		{
+			name: "password never expires",
+			attrs: map[string][]string{
+				"userAccountControl":                  {"66048"},
+				"msDS-UserPasswordExpiryTimeComputed": {"9223372036854775807"},
+			},
+			check: func(s *ADAccountState) bool {
+				return s.PasswordNeverExpires && !s.PasswordExpired && s.PasswordExpiresAt.IsZero()
+			},
+		},
+		{
+			name:  "must change password",
+			attrs: map[string][]string{"userAccountControl": {"512"}, "pwdLastSet": {"0"}},
+			check: func(s *ADAccountState) bool { return s.PasswordMustChange && !s.Usable() },
+		},
+		{
+			name:  "account expired",
+			attrs: map[string][]string{"userAccountControl": {"512"}, "accountExpires": {fileTime(now.AddDate(0, 0, -1))}},
+			check: func(s *ADAccountState) bool { return s.AccountExpired && !s.Usable() },
+		},
+	}
+	for _, test := range tests {
+		s, err := NewADAccountState(NewEntry("cn=user", test.attrs), now)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !test.check(s) {
+			t.Errorf("%s: unexpected state %+v", test.name, s)
+		}
+	}
+
+	if _, err := NewADAccountState(NewEntry("cn=user", map[string][]string{"pwdLastSet": {"never"}}), now); err == nil {
+		t.Error("expected error for invalid pwdLastSet")
+	}
+}
@@ -1,6 +1,8 @@
 package ldap
 
 import (
+	"context"
+
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
 
@@ -64,9 +66,36 @@ func NewAddRequest(dn string, controls []Control) *AddRequest {
 
 }
 
+// NewAddRequestFromStruct returns an AddRequest for the given DN holding the
+// fields of the struct pointed to by i as attributes, see MarshalEntry. If dn
+// is empty, the value of the field tagged "dn" is used.
+func NewAddRequestFromStruct(dn string, i interface{}, controls []Control) (*AddRequest, error) {
+	entry, err := MarshalEntry(i)
+	if err != nil {
+		return nil, err
+	}
+	if dn == "" {
+		dn = entry.DN
+	}
+
+	req := NewAddRequest(dn, controls)
+	for _, attr := range entry.Attributes {
+		req.Attribute(attr.Name, attr.StringValues())
+	}
+	return req, nil
+}
+
 // Add performs the given AddRequest
 func (l *Conn) Add(addRequest *AddRequest) error {
-	msgCtx, err := l.doRequest(addRequest)
+	return l.AddContext(context.Background(), addRequest)
+}
+
+// AddContext performs the given AddRequest. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) AddContext(ctx context.Context, addRequest *AddRequest) (err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, addRequest)
 	if err != nil {
 		return err
 	}
@@ -87,3 +116,15 @@ func (l *Conn) Add(addRequest *AddRequest) error {
 	}
 	return nil
 }
+
+// AddWithResult performs the given AddRequest and returns the result
+func (l *Conn) AddWithResult(addRequest *AddRequest) (*WriteResult, error) {
+	return l.AddWithResultContext(context.Background(), addRequest)
+}
+
+// AddWithResultContext performs the given AddRequest and returns the result. The correlation ID
+// carried by ctx, see WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) AddWithResultContext(ctx context.Context, addRequest *AddRequest) (_ *WriteResult, err error) {
+	defer correlateError(ctx, &err)
+	return l.writeWithResult(ctx, addRequest, ApplicationAddResponse)
+}
@@ -0,0 +1,62 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+// ErrTLSRequired is returned by operations which would send secrets over a
+// connection which is not protected by TLS
+var ErrTLSRequired = errors.New("ldap: operation requires a TLS connection")
+
+// EncodeADPassword encodes a password the way Active Directory expects it in
+// the unicodePwd attribute: enclosed in double quotes and encoded as
+// UTF-16LE
+func EncodeADPassword(password string) string {
+	encoded := utf16.Encode([]rune(`"` + password + `"`))
+	b := make([]byte, 2*len(encoded))
+	for i, v := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], v)
+	}
+	return string(b)
+}
+
+// NewADPasswordModify returns a request resetting the password of the
+// account with the given DN, which requires the right to reset passwords
+func NewADPasswordModify(dn, newPassword string) *ModifyRequest {
+	req := NewModifyRequest(dn, nil)
+	req.Replace("unicodePwd", []string{EncodeADPassword(newPassword)})
+	return req
+}
+
+// NewADPasswordChange returns a request changing the password of the
+// account with the given DN from oldPassword to newPassword, as a user may
+// do for their own account. Password history and complexity rules apply.
+func NewADPasswordChange(dn, oldPassword, newPassword string) *ModifyRequest {
+	req := NewModifyRequest(dn, nil)
+	req.Delete("unicodePwd", []string{EncodeADPassword(oldPassword)})
+	req.Add("unicodePwd", []string{EncodeADPassword(newPassword)})
+	return req
+}
+
+// ADPasswordModify sets the password of the Active Directory account with
+// the given DN. If oldPassword is empty, the password is reset, otherwise it
+// is changed. Active Directory only accepts passwords over protected
+// connections, ErrTLSRequired is returned without sending the password if
+// the connection does not use TLS. Controls are sent with the modification,
+// e.g. NewControlMicrosoftPolicyHints(true) to enforce the password history
+// on resets.
+func (l *Conn) ADPasswordModify(dn, oldPassword, newPassword string, controls ...Control) error {
+	if !l.isTLS {
+		return ErrTLSRequired
+	}
+	var req *ModifyRequest
+	if oldPassword == "" {
+		req = NewADPasswordModify(dn, newPassword)
+	} else {
+		req = NewADPasswordChange(dn, oldPassword, newPassword)
+	}
+	req.Controls = controls
+	return l.Modify(req)
+}
@@ -0,0 +1,54 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeADPassword(t *testing.T) {
+	expected := []byte{'"', 0, 'p', 0, 0xe4, 0, 's', 0, 's', 0, '"', 0}
+	if got := EncodeADPassword("päss"); !bytes.Equal([]byte(got), expected) {
+		t.Errorf("got %x, expected %x", got, expected)
+	}
+}
+
+func TestNewADPasswordModify(t *testing.T) {
+	req := NewADPasswordModify("cn=user,dc=example", "new")
+	if len(req.Changes) != 1 || req.Changes[0].Operation != ReplaceAttribute ||
+		req.Changes[0].Modification.Vals[0] != EncodeADPassword("new") {
+		t.Errorf("unexpected reset request %+v", req.Changes)
+	}
+
+	req = NewADPasswordChange("cn=user,dc=example", "old", "new")
+	if len(req.Changes) != 2 || req.Changes[0].Operation != DeleteAttribute || req.Changes[1].Operation != AddAttribute ||
+		req.Changes[0].Modification.Vals[0] != EncodeADPassword("old") || req.Changes[1].Modification.Vals[0] != EncodeADPassword("new") {
+		t.Errorf("unexpected change request %+v", req.Changes)
+	}
+}
+
+func TestADPasswordModify(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	if err := conn.ADPasswordModify("cn=user,dc=example", "", "new"); err != ErrTLSRequired {
+		t.Errorf("expected ErrTLSRequired, got %v", err)
+	}
+
+	tlsPtc := newPacketTranslatorConn()
+	defer tlsPtc.Close()
+
+	tlsConn := NewConn(tlsPtc, true)
+	tlsConn.Start()
+	defer tlsConn.Close()
+
+	go func() {
+		_, _ = respondWithResult(tlsPtc, ApplicationModifyResponse, LDAPResultSuccess)
+	}()
+	if err := tlsConn.ADPasswordModify("cn=user,dc=example", "old", "new"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,219 @@
+package ldap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a write operation performed on a connection with an
+// Auditor, see SetAuditor. The values of attributes and passwords are not
+// recorded.
+type AuditRecord struct {
+	// Sequence numbers the records of an Auditor, starting with 1
+	Sequence uint64
+	// Time is the time the operation finished
+	Time time.Time
+	// Operation is "add", "delete", "modify", "modifyDN" or
+	// "passwordModify"
+	Operation string
+	// BoundIdentity is the name of the last successful simple, DIGEST-MD5
+	// or NTLM bind of the connection, empty for anonymous connections and
+	// other bind mechanisms
+	BoundIdentity string
+	// DN is the target of the operation, for passwordModify the user
+	// identity, which is empty for the bound user
+	DN string
+	// Changes summarize the attributes added or modified
+	Changes []AuditChange `json:",omitempty"`
+	// NewDN is the new DN of the entry for modifyDN
+	NewDN string `json:",omitempty"`
+	// ResultCode is the result code returned by the server, -1 if there was
+	// no response
+	ResultCode int64
+	// Error is the error which kept the operation from completing, e.g. a
+	// network error. Failures reported by the server are in ResultCode.
+	Error string `json:",omitempty"`
+	// CorrelationID is the caller-supplied ID of the operation, see
+	// WithCorrelationID
+	CorrelationID string `json:",omitempty"`
+	// PrevHash is the Hash of the previous record, nil for the first record
+	PrevHash []byte
+	// Hash is the SHA-256 hash of the JSON encoding of the record without
+	// Hash, which includes PrevHash. Changing, removing or reordering
+	// records breaks the chain, see VerifyAuditChain.
+	Hash []byte `json:",omitempty"`
+}
+
+// AuditChange is an attribute added or modified by an audited operation
+type AuditChange struct {
+	// Operation is "add", "delete", "replace" or "increment"
+	Operation string
+	Attribute string
+	// Values is the number of values
+	Values int
+}
+
+// auditChangeOperations maps modify operations to the names of AuditChange
+var auditChangeOperations = map[uint]string{
+	AddAttribute:       "add",
+	DeleteAttribute:    "delete",
+	ReplaceAttribute:   "replace",
+	IncrementAttribute: "increment",
+}
+
+// computeHash returns the hash of the record
+func (r *AuditRecord) computeHash() ([]byte, error) {
+	unhashed := *r
+	unhashed.Hash = nil
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// Auditor hands records of the write operations on the connections it is
+// set on to a callback, chaining them by hash so that tampering with the
+// stored records can be detected with VerifyAuditChain. An Auditor can be
+// shared by connections to record their operations in one chain.
+type Auditor struct {
+	callback func(*AuditRecord)
+
+	mu       sync.Mutex
+	sequence uint64
+	prevHash []byte
+}
+
+// NewAuditor returns an Auditor calling callback with every record. The
+// records are passed in chain order, callback must not block for long as
+// the operations of all connections of the Auditor wait for it. If last is
+// not nil, the chain continues after it, e.g. with the last record stored
+// before a restart.
+func NewAuditor(callback func(*AuditRecord), last *AuditRecord) *Auditor {
+	a := &Auditor{callback: callback}
+	if last != nil {
+		a.sequence = last.Sequence
+		a.prevHash = last.Hash
+	}
+	return a
+}
+
+// emit chains the record and passes it to the callback
+func (a *Auditor) emit(r *AuditRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sequence++
+	r.Sequence = a.sequence
+	r.PrevHash = a.prevHash
+	hash, err := r.computeHash()
+	if err != nil {
+		logger.Printf("ldap: hashing audit record: %s", err)
+		return
+	}
+	r.Hash = hash
+	a.prevHash = hash
+	a.callback(r)
+}
+
+// VerifyAuditChain checks that the hashes of records are correct and that
+// each record follows the previous one, so that the records were neither
+// modified nor removed or reordered. The records need not start with the
+// first record of the chain.
+func VerifyAuditChain(records []*AuditRecord) error {
+	for i, r := range records {
+		hash, err := r.computeHash()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash, r.Hash) {
+			return fmt.Errorf("ldap: audit record %d has been modified", r.Sequence)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := records[i-1]
+		if r.Sequence != prev.Sequence+1 || !bytes.Equal(r.PrevHash, prev.Hash) {
+			return fmt.Errorf("ldap: audit record %d does not follow record %d", r.Sequence, prev.Sequence)
+		}
+	}
+	if len(records) > 0 && records[0].Sequence == 1 && records[0].PrevHash != nil {
+		return errors.New("ldap: first audit record has a previous hash")
+	}
+	return nil
+}
+
+// SetAuditor records the write operations on this connection, i.e. adds,
+// deletes, modifications, modify DNs and password modifications, with
+// auditor. Passing nil disables auditing.
+func (l *Conn) SetAuditor(auditor *Auditor) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	l.auditor = auditor
+}
+
+// setBoundIdentity records the name of a bind performed on the connection.
+// A failed bind leaves the connection anonymous, see RFC 4513 section 4.
+func (l *Conn) setBoundIdentity(identity string, err error) {
+	if err != nil {
+		identity = ""
+	}
+	l.boundIdentity.Store(identity)
+}
+
+// isAuditedOperation returns true for the operations recorded by an Auditor
+func isAuditedOperation(operation string) bool {
+	switch operation {
+	case "add", "delete", "modify", "modifyDN", "passwordModify":
+		return true
+	}
+	return false
+}
+
+// audit passes the record of a finished write operation to the Auditor
+func (t *operationTrace) audit() {
+	r := &AuditRecord{
+		Time:          time.Now().UTC(),
+		Operation:     t.operation,
+		ResultCode:    t.resultCode,
+		CorrelationID: t.correlationID,
+	}
+	r.BoundIdentity, _ = t.conn.boundIdentity.Load().(string)
+	if t.err != nil {
+		r.Error = t.err.Error()
+	}
+	switch req := t.req.(type) {
+	case *AddRequest:
+		r.DN = req.DN
+		for _, attr := range req.Attributes {
+			r.Changes = append(r.Changes, AuditChange{Operation: "add", Attribute: attr.Type, Values: len(attr.Vals)})
+		}
+	case *DelRequest:
+		r.DN = req.DN
+	case *ModifyRequest:
+		r.DN = req.DN
+		for _, change := range req.Changes {
+			r.Changes = append(r.Changes, AuditChange{
+				Operation: auditChangeOperations[change.Operation],
+				Attribute: change.Modification.Type,
+				Values:    len(change.Modification.Vals),
+			})
+		}
+	case *ModifyDNRequest:
+		r.DN = req.DN
+		r.NewDN = req.NewRDN
+		if req.NewSuperior != "" {
+			r.NewDN += "," + req.NewSuperior
+		} else if dn, err := ParseDN(req.DN); err == nil && len(dn.RDNs) > 1 {
+			r.NewDN += "," + (&DN{RDNs: dn.RDNs[1:]}).String()
+		}
+	case *PasswordModifyRequest:
+		r.DN = req.UserIdentity
+	}
+	t.auditor.emit(r)
+}
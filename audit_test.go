@@ -0,0 +1,93 @@
+package ldap
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditor(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	var records []*AuditRecord
+	conn.SetAuditor(NewAuditor(func(r *AuditRecord) { records = append(records, r) }, nil))
+
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Bind("cn=admin,dc=example,dc=com", "secret"))
+
+	add := NewAddRequest("cn=a,dc=example,dc=com", nil)
+	add.Attribute("objectClass", []string{"top", "person"})
+	go respondWithResult(ptc, ApplicationAddResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Add(add))
+
+	modify := NewModifyRequest("cn=a,dc=example,dc=com", nil)
+	modify.Replace("description", []string{"secret value"})
+	go respondWithResult(ptc, ApplicationModifyResponse, LDAPResultInsufficientAccessRights)
+	assert.Error(t, conn.Modify(modify))
+
+	go respondWithResult(ptc, ApplicationModifyDNResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.ModifyDN(NewModifyDNRequest("cn=a,dc=example,dc=com", "cn=b", true, "")))
+
+	// reads are not audited
+	go respondWithResult(ptc, ApplicationCompareResponse, LDAPResultCompareTrue)
+	_, err := conn.Compare("cn=b,dc=example,dc=com", "cn", "b")
+	assert.NoError(t, err)
+
+	go respondWithResult(ptc, ApplicationDelResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Del(NewDelRequest("cn=b,dc=example,dc=com", nil)))
+
+	if !assert.Equal(t, 4, len(records)) {
+		return
+	}
+	for i, r := range records {
+		assert.Equal(t, uint64(i+1), r.Sequence)
+		assert.Equal(t, "cn=admin,dc=example,dc=com", r.BoundIdentity)
+	}
+	assert.Equal(t, "add", records[0].Operation)
+	assert.Equal(t, []AuditChange{{Operation: "add", Attribute: "objectClass", Values: 2}}, records[0].Changes)
+	assert.Nil(t, records[0].PrevHash)
+	assert.Equal(t, "modify", records[1].Operation)
+	assert.Equal(t, []AuditChange{{Operation: "replace", Attribute: "description", Values: 1}}, records[1].Changes)
+	assert.Equal(t, int64(LDAPResultInsufficientAccessRights), records[1].ResultCode)
+	assert.Equal(t, "modifyDN", records[2].Operation)
+	assert.Equal(t, "cn=b,dc=example,dc=com", records[2].NewDN)
+	assert.Equal(t, "delete", records[3].Operation)
+	assert.Equal(t, "cn=b,dc=example,dc=com", records[3].DN)
+	assert.Equal(t, int64(LDAPResultSuccess), records[3].ResultCode)
+
+	// the chain survives storing the records as JSON
+	data, err := json.Marshal(records)
+	assert.NoError(t, err)
+	var stored []*AuditRecord
+	assert.NoError(t, json.Unmarshal(data, &stored))
+	assert.NoError(t, VerifyAuditChain(stored))
+	assert.NoError(t, VerifyAuditChain(stored[1:]))
+
+	// a continued chain follows the last stored record
+	var continued []*AuditRecord
+	auditor := NewAuditor(func(r *AuditRecord) { continued = append(continued, r) }, stored[3])
+	auditor.emit(&AuditRecord{Operation: "delete", DN: "cn=c,dc=example,dc=com"})
+	assert.NoError(t, VerifyAuditChain(append(stored, continued...)))
+
+	// modified, removed and reordered records are detected
+	stored[1].ResultCode = LDAPResultSuccess
+	assert.Error(t, VerifyAuditChain(stored))
+	stored[1].ResultCode = LDAPResultInsufficientAccessRights
+	assert.NoError(t, VerifyAuditChain(stored))
+	assert.Error(t, VerifyAuditChain([]*AuditRecord{stored[0], stored[2], stored[3]}))
+	assert.Error(t, VerifyAuditChain([]*AuditRecord{stored[1], stored[0]}))
+
+	// a failed bind leaves the connection anonymous
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultInvalidCredentials)
+	assert.Error(t, conn.Bind("cn=admin,dc=example,dc=com", "wrong"))
+	go respondWithResult(ptc, ApplicationDelResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Del(NewDelRequest("cn=c,dc=example,dc=com", nil)))
+	if assert.Equal(t, 5, len(records)) {
+		assert.Equal(t, "", records[4].BoundIdentity)
+	}
+}
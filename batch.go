@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// batchWindow is the number of requests of a batch awaiting their results at
+// a time
+const batchWindow = 128
+
+// batchRequest returns the request and the tag of the expected response for
+// a request of a batch, or false if it cannot be part of one
+func batchRequest(req interface{}) (request, ber.Tag, bool) {
+	switch req := req.(type) {
+	case *AddRequest:
+		return req, ApplicationAddResponse, true
+	case *ModifyRequest:
+		return req, ApplicationModifyResponse, true
+	case *DelRequest:
+		return req, ApplicationDelResponse, true
+	}
+	return nil, 0, false
+}
+
+// Batch performs the given *AddRequest, *ModifyRequest and *DelRequest
+// requests, sending them without waiting for the result of each, which
+// saves a round trip per request when provisioning many entries. It returns
+// the errors of the requests in the order of the requests, nil for the
+// succeeded ones, or an error without sending anything if a request is of
+// another type.
+//
+// The server may process the requests concurrently, so requests depending on
+// each other, like adding an entry and then its child, need separate batches.
+func (l *Conn) Batch(requests ...interface{}) ([]error, error) {
+	return l.BatchContext(context.Background(), requests...)
+}
+
+// BatchContext performs the given requests like Batch. The correlation ID
+// carried by ctx, see WithCorrelationID, is attached to the requests and
+// their errors. Once ctx is done, the remaining requests are not sent and
+// get the error of ctx.
+func (l *Conn) BatchContext(ctx context.Context, requests ...interface{}) ([]error, error) {
+	reqs := make([]request, len(requests))
+	tags := make([]ber.Tag, len(requests))
+	for i, req := range requests {
+		r, tag, ok := batchRequest(req)
+		if !ok {
+			return nil, fmt.Errorf("ldap: unsupported batch request %T", req)
+		}
+		reqs[i], tags[i] = r, tag
+	}
+
+	errs := make([]error, len(requests))
+	msgCtxs := make([]*messageContext, len(requests))
+	// next is the oldest request awaiting its result
+	next := 0
+	for i, req := range reqs {
+		for ; i-next >= batchWindow; next++ {
+			if msgCtxs[next] != nil {
+				errs[next] = l.batchResult(msgCtxs[next], tags[next])
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		msgCtxs[i], errs[i] = l.doRequestContext(ctx, req)
+	}
+	for ; next < len(reqs); next++ {
+		if msgCtxs[next] != nil {
+			errs[next] = l.batchResult(msgCtxs[next], tags[next])
+		}
+	}
+	for i := range errs {
+		correlateError(ctx, &errs[i])
+	}
+	return errs, nil
+}
+
+// batchResult waits for the result of a request of a batch with the given
+// response tag
+func (l *Conn) batchResult(msgCtx *messageContext, tag ber.Tag) error {
+	defer l.finishMessage(msgCtx)
+
+	packet, err := l.readPacket(msgCtx)
+	if err != nil {
+		return err
+	}
+	if packet.Children[1].Tag != tag {
+		return NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
+	}
+	return GetLDAPError(packet)
+}
@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+)
+
+// respondToBatch receives a request per result code before answering them in
+// reverse order with the result codes
+func respondToBatch(ptc *packetTranslatorConn, resultCodes ...uint16) {
+	requests := make([]*ber.Packet, len(resultCodes))
+	for i := range requests {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		requests[i] = req
+	}
+	for i := len(requests) - 1; i >= 0; i-- {
+		req := requests[i]
+		// the response tags follow the request tags
+		tag := req.Children[1].Tag + 1
+		response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, ApplicationMap[uint8(tag)])
+		result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCodes[i]), "resultCode"))
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		response.AppendChild(result)
+		_ = ptc.SendResponse(response)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	add := NewAddRequest("cn=a,dc=example,dc=com", nil)
+	add.Attribute("objectClass", []string{"person"})
+	modify := NewModifyRequest("cn=b,dc=example,dc=com", nil)
+	modify.Replace("sn", []string{"b"})
+	del := NewDelRequest("cn=c,dc=example,dc=com", nil)
+
+	// the results are only sent once all requests are received
+	go respondToBatch(ptc, LDAPResultSuccess, LDAPResultNoSuchObject, LDAPResultSuccess)
+	errs, err := conn.Batch(add, modify, del)
+	if !assert.NoError(t, err) || !assert.Equal(t, 3, len(errs)) {
+		return
+	}
+	assert.NoError(t, errs[0])
+	assert.True(t, errors.Is(errs[1], ErrNoSuchObject))
+	assert.NoError(t, errs[2])
+
+	_, err = conn.Batch(add, NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs, err = conn.BatchContext(ctx, add, del)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{context.Canceled, context.Canceled}, errs)
+}
+
+func TestBatchWindow(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make([]interface{}, batchWindow+10)
+	for i := range requests {
+		requests[i] = NewDelRequest("cn=a,dc=example,dc=com", nil)
+	}
+	go func() {
+		for range requests {
+			respondWithResult(ptc, ApplicationDelResponse, LDAPResultSuccess)
+		}
+	}()
+	errs, err := conn.Batch(requests...)
+	assert.NoError(t, err)
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("request %d: %s", i, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ldap
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	enchex "encoding/hex"
 	"errors"
@@ -20,6 +21,10 @@ type SimpleBindRequest struct {
 	Username string
 	// Password is the credentials to bind with
 	Password string
+	// PasswordBytes is used instead of Password if it is not nil. Unlike a
+	// string, it can be zeroed by the caller once the bind returned, as the
+	// request keeps no other copy of it.
+	PasswordBytes []byte
 	// Controls are optional controls to send with the bind request
 	Controls []Control
 	// AllowEmptyPassword sets whether the client allows binding with an empty password
@@ -46,23 +51,68 @@ func (req *SimpleBindRequest) appendTo(envelope *ber.Packet) error {
 	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
 	pkt.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
 	pkt.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.Username, "User Name"))
-	pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.Password, "Password"))
+	if req.PasswordBytes == nil {
+		pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.Password, "Password"))
+		envelope.AppendChild(pkt)
+		if len(req.Controls) > 0 {
+			envelope.AppendChild(encodeControls(req.Controls))
+		}
+		return nil
+	}
 
-	envelope.AppendChild(pkt)
+	appendSecretChild(pkt, newSecretPacket(ber.ClassContext, 0, req.PasswordBytes, "Password"))
+	var controls *ber.Packet
 	if len(req.Controls) > 0 {
-		envelope.AppendChild(encodeControls(req.Controls))
+		controls = encodeControls(req.Controls)
+		// the envelope must not grow after the password is written, which
+		// would leave a copy of it behind
+		envelope.Data.Grow(pkt.Data.Len() + controls.Data.Len() + 20)
+	}
+	appendSecretChild(envelope, pkt)
+	if controls != nil {
+		envelope.AppendChild(controls)
 	}
-
 	return nil
 }
 
+func (req *SimpleBindRequest) credentials() {}
+
+// hasPassword reports whether the request has a non-empty password
+func (req *SimpleBindRequest) hasPassword() bool {
+	if req.PasswordBytes != nil {
+		return len(req.PasswordBytes) > 0
+	}
+	return req.Password != ""
+}
+
 // SimpleBind performs the simple bind operation defined in the given request
 func (l *Conn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResult, error) {
-	if simpleBindRequest.Password == "" && !simpleBindRequest.AllowEmptyPassword {
+	return l.SimpleBindContext(context.Background(), simpleBindRequest)
+}
+
+// SimpleBindContext performs the simple bind operation defined in the given request. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) SimpleBindContext(ctx context.Context, simpleBindRequest *SimpleBindRequest) (_ *SimpleBindResult, err error) {
+	defer correlateError(ctx, &err)
+
+	if err := l.checkBindGuards(simpleBindRequest); err != nil {
+		return nil, err
+	}
+	if !simpleBindRequest.hasPassword() && !simpleBindRequest.AllowEmptyPassword {
 		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
 	}
+	if simpleBindRequest.hasPassword() {
+		if err := l.checkStrictConfidentiality(); err != nil {
+			return nil, err
+		}
+		done, throttleErr := l.throttleBind(simpleBindRequest.Username)
+		if throttleErr != nil {
+			return nil, throttleErr
+		}
+		defer func() { done(err) }()
+	}
 
-	msgCtx, err := l.doRequest(simpleBindRequest)
+	msgCtx, err := l.doRequestContext(ctx, simpleBindRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -88,9 +138,32 @@ func (l *Conn) SimpleBind(simpleBindRequest *SimpleBindRequest) (*SimpleBindResu
 	}
 
 	err = GetLDAPError(packet)
+	l.setBoundIdentity(simpleBindRequest.Username, err)
+	if err != nil {
+		l.log(LogLevelWarn, "bind failed", "dn", simpleBindRequest.Username, "error", err)
+	} else {
+		l.log(LogLevelInfo, "bind succeeded", "dn", simpleBindRequest.Username)
+	}
 	return result, err
 }
 
+// endBind ends the trace of a bind taking several requests, if not nil, and
+// logs its outcome
+func (l *Conn) endBind(trace *operationTrace, mechanism, identity string, err error) {
+	if trace != nil {
+		trace.endSteps(err)
+	}
+	keysAndValues := []interface{}{"mechanism", mechanism}
+	if identity != "" {
+		keysAndValues = append(keysAndValues, "dn", identity)
+	}
+	if err != nil {
+		l.log(LogLevelWarn, "bind failed", append(keysAndValues, "error", err)...)
+	} else {
+		l.log(LogLevelInfo, "bind succeeded", keysAndValues...)
+	}
+}
+
 // Bind performs a bind with the given username and password.
 //
 // It does not allow unauthenticated bind (i.e. empty password). Use the UnauthenticatedBind method
@@ -165,27 +238,43 @@ func (l *Conn) MD5Bind(host, username, password string) error {
 }
 
 // DigestMD5Bind performs the digest-md5 bind operation defined in the given request
-func (l *Conn) DigestMD5Bind(digestMD5BindRequest *DigestMD5BindRequest) (*DigestMD5BindResult, error) {
+func (l *Conn) DigestMD5Bind(digestMD5BindRequest *DigestMD5BindRequest) (_ *DigestMD5BindResult, err error) {
 	if digestMD5BindRequest.Password == "" {
 		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
 	}
+	if err := checkStrictMechanism("DIGEST-MD5"); err != nil {
+		return nil, err
+	}
+	done, err := l.throttleBind(digestMD5BindRequest.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { done(err) }()
+	defer func() { l.setBoundIdentity(digestMD5BindRequest.Username, err) }()
 
 	msgCtx, err := l.doRequest(digestMD5BindRequest)
 	if err != nil {
 		return nil, err
 	}
 	defer l.finishMessage(msgCtx)
+	// the trace spans all steps of the bind
+	trace := msgCtx.trace
+	msgCtx.trace = nil
+	defer func() { l.endBind(trace, "DIGEST-MD5", digestMD5BindRequest.Username, err) }()
 
 	packet, err := l.readPacket(msgCtx)
 	if err != nil {
 		return nil, err
 	}
+	if trace != nil {
+		trace.observe(packet)
+	}
 	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 	if l.Debug {
 		if err = addLDAPDescriptions(packet); err != nil {
 			return nil, err
 		}
-		ber.PrintPacket(packet)
+		ber.PrintPacket(redactPacket(packet))
 	}
 
 	result := &DigestMD5BindResult{
@@ -235,20 +324,19 @@ func (l *Conn) DigestMD5Bind(digestMD5BindRequest *DigestMD5BindRequest) (*Diges
 		auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, resp, "Credentials"))
 		request.AppendChild(auth)
 		packet.AppendChild(request)
-		msgCtx, err = l.sendMessage(packet)
+		msgCtx, err = l.sendRequest(packet, scrubCredentials, "")
 		if err != nil {
 			return nil, fmt.Errorf("send message: %s", err)
 		}
 		defer l.finishMessage(msgCtx)
-		packetResponse, ok := <-msgCtx.responses
-		if !ok {
-			return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-		}
-		packet, err = packetResponse.ReadPacket()
+		packet, err = l.receivePacket(msgCtx)
 		l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 		if err != nil {
 			return nil, fmt.Errorf("read packet: %s", err)
 		}
+		if trace != nil && packet != nil {
+			trace.observe(packet)
+		}
 	}
 
 	err = GetLDAPError(packet)
@@ -375,6 +463,7 @@ var externalBindRequest = requestFunc(func(envelope *ber.Packet) error {
 //
 // See https://tools.ietf.org/html/rfc4422#appendix-A
 func (l *Conn) ExternalBind() error {
+	l.setBoundIdentity("", nil)
 	msgCtx, err := l.doRequest(externalBindRequest)
 	if err != nil {
 		return err
@@ -386,7 +475,13 @@ func (l *Conn) ExternalBind() error {
 		return err
 	}
 
-	return GetLDAPError(packet)
+	err = GetLDAPError(packet)
+	if err != nil {
+		l.log(LogLevelWarn, "bind failed", "mechanism", "EXTERNAL", "error", err)
+	} else {
+		l.log(LogLevelInfo, "bind succeeded", "mechanism", "EXTERNAL")
+	}
+	return err
 }
 
 // NTLMBind performs an NTLMSSP bind leveraging https://github.com/Azure/go-ntlmssp
@@ -473,26 +568,48 @@ func (l *Conn) NTLMBindWithHash(domain, username, hash string) error {
 }
 
 // NTLMChallengeBind performs the NTLMSSP bind operation defined in the given request
-func (l *Conn) NTLMChallengeBind(ntlmBindRequest *NTLMBindRequest) (*NTLMBindResult, error) {
+func (l *Conn) NTLMChallengeBind(ntlmBindRequest *NTLMBindRequest) (_ *NTLMBindResult, err error) {
 	if !ntlmBindRequest.AllowEmptyPassword && ntlmBindRequest.Password == "" && ntlmBindRequest.Hash == "" {
 		return nil, NewError(ErrorEmptyPassword, errors.New("ldap: empty password not allowed by the client"))
 	}
+	if err := checkStrictMechanism("NTLM"); err != nil {
+		return nil, err
+	}
+	identity := ntlmBindRequest.Username
+	if ntlmBindRequest.Domain != "" {
+		identity = ntlmBindRequest.Domain + "\\" + identity
+	}
+	if ntlmBindRequest.Password != "" || ntlmBindRequest.Hash != "" {
+		done, throttleErr := l.throttleBind(identity)
+		if throttleErr != nil {
+			return nil, throttleErr
+		}
+		defer func() { done(err) }()
+	}
+	defer func() { l.setBoundIdentity(identity, err) }()
 
 	msgCtx, err := l.doRequest(ntlmBindRequest)
 	if err != nil {
 		return nil, err
 	}
 	defer l.finishMessage(msgCtx)
+	// the trace spans all steps of the bind
+	trace := msgCtx.trace
+	msgCtx.trace = nil
+	defer func() { l.endBind(trace, "NTLM", identity, err) }()
 	packet, err := l.readPacket(msgCtx)
 	if err != nil {
 		return nil, err
 	}
+	if trace != nil {
+		trace.observe(packet)
+	}
 	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 	if l.Debug {
 		if err = addLDAPDescriptions(packet); err != nil {
 			return nil, err
 		}
-		ber.PrintPacket(packet)
+		ber.PrintPacket(redactPacket(packet))
 	}
 	result := &NTLMBindResult{
 		Controls: make([]Control, 0),
@@ -538,20 +655,19 @@ func (l *Conn) NTLMChallengeBind(ntlmBindRequest *NTLMBindRequest) (*NTLMBindRes
 
 		request.AppendChild(auth)
 		packet.AppendChild(request)
-		msgCtx, err = l.sendMessage(packet)
+		msgCtx, err = l.sendRequest(packet, scrubCredentials, "")
 		if err != nil {
 			return nil, fmt.Errorf("send message: %s", err)
 		}
 		defer l.finishMessage(msgCtx)
-		packetResponse, ok := <-msgCtx.responses
-		if !ok {
-			return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-		}
-		packet, err = packetResponse.ReadPacket()
+		packet, err = l.receivePacket(msgCtx)
 		l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 		if err != nil {
 			return nil, fmt.Errorf("read packet: %s", err)
 		}
+		if trace != nil && packet != nil {
+			trace.observe(packet)
+		}
 
 	}
 
@@ -602,6 +718,19 @@ type GSSAPIBindRequest struct {
 	AuthZID string
 	// (Optional) Controls to send with the bind request
 	Controls []Control
+	// (Optional) Mechanism is the SASL mechanism, "GSSAPI" if empty or
+	// "GSS-SPNEGO". The GSSAPIClient must produce the tokens of the
+	// mechanism.
+	Mechanism string
+	// (Optional) SecurityLayer are the acceptable SASL security layers.
+	// If it contains SASLSecurityIntegrity or SASLSecurityConfidentiality,
+	// the strongest layer offered by the server is selected and protects
+	// all further PDUs, e.g. for Active Directory domain controllers
+	// requiring LDAP signing without TLS. This requires a GSSAPIClient
+	// implementing GSSAPIWrapper. For GSS-SPNEGO, the server does not
+	// offer layers and the strongest requested one is used. If it is 0,
+	// the layer is selected by the NegotiateSaslAuth of the client.
+	SecurityLayer SASLSecurityLayer
 }
 
 // GSSAPIBind performs the GSSAPI SASL bind using the provided GSSAPI client.
@@ -613,21 +742,61 @@ func (l *Conn) GSSAPIBind(client GSSAPIClient, servicePrincipal, authzid string)
 }
 
 // GSSAPIBindRequest performs the GSSAPI SASL bind using the provided GSSAPI client.
-func (l *Conn) GSSAPIBindRequest(client GSSAPIClient, req *GSSAPIBindRequest) error {
-	// nolint:errcheck
-	defer client.DeleteSecContext()
+func (l *Conn) GSSAPIBindRequest(client GSSAPIClient, req *GSSAPIBindRequest) (err error) {
+	l.setBoundIdentity("", nil)
+	mechanism := req.Mechanism
+	if mechanism == "" {
+		mechanism = "GSSAPI"
+	}
+	spnego := strings.EqualFold(mechanism, "GSS-SPNEGO")
+	var wrapper GSSAPIWrapper
+	if req.SecurityLayer&(SASLSecurityIntegrity|SASLSecurityConfidentiality) != 0 {
+		var ok bool
+		if wrapper, ok = client.(GSSAPIWrapper); !ok {
+			return errors.New("ldap: SASL security layers require a GSSAPIClient implementing GSSAPIWrapper")
+		}
+	}
+	trace := l.startOperationTrace("bind", nil, "")
+	defer func() { l.endBind(trace, mechanism, "", err) }()
+
+	// the security context is kept for the security layer once installed
+	installed := false
+	defer func() {
+		if !installed {
+			// nolint:errcheck
+			client.DeleteSecContext()
+		}
+	}()
 
-	var err error
 	var reqToken []byte
 	var recvToken []byte
+	layer := SASLSecurityNone
+	var maxSend uint32
 	needInit := true
 	for {
+		// the response to the final request is followed by protected PDUs
+		final := false
 		if needInit {
 			// Establish secure context between client and server.
 			reqToken, needInit, err = client.InitSecContext(req.ServicePrincipalName, recvToken)
 			if err != nil {
 				return err
 			}
+			if spnego && !needInit && wrapper != nil {
+				layer = SASLSecurityIntegrity
+				if req.SecurityLayer&SASLSecurityConfidentiality != 0 {
+					layer = SASLSecurityConfidentiality
+				}
+				final = true
+			}
+		} else if spnego {
+			return errors.New("ldap: unexpected GSS-SPNEGO token after the security context was established")
+		} else if wrapper != nil {
+			reqToken, layer, maxSend, err = negotiateSASLSecurityLayer(wrapper, recvToken, req)
+			if err != nil {
+				return err
+			}
+			final = true
 		} else {
 			// Secure context is set up, perform the last step of SASL handshake.
 			reqToken, err = client.NegotiateSaslAuth(recvToken, req.AuthZID)
@@ -637,10 +806,20 @@ func (l *Conn) GSSAPIBindRequest(client GSSAPIClient, req *GSSAPIBindRequest) er
 		}
 		// Send Bind request containing the current token and extract the
 		// token sent by server.
-		recvToken, err = l.saslBindTokenExchange(req.Controls, reqToken)
+		var install func()
+		if final && layer != SASLSecurityNone {
+			install = func() {
+				installed = true
+				l.installSASLLayer(client, layer, maxSend)
+			}
+		}
+		recvToken, err = l.saslBindTokenExchange(mechanism, req.Controls, reqToken, install, trace)
 		if err != nil {
 			return err
 		}
+		if installed {
+			return nil
+		}
 
 		if !needInit && len(recvToken) == 0 {
 			break
@@ -650,7 +829,11 @@ func (l *Conn) GSSAPIBindRequest(client GSSAPIClient, req *GSSAPIBindRequest) er
 	return nil
 }
 
-func (l *Conn) saslBindTokenExchange(reqControls []Control, reqToken []byte) ([]byte, error) {
+// saslBindTokenExchange sends a SASL bind request with reqToken and returns
+// the token of the server. If install is not nil, it is called to install
+// the security layer once the bind succeeded and before further responses
+// are read. The response is observed by trace, if not nil.
+func (l *Conn) saslBindTokenExchange(mechanism string, reqControls []Control, reqToken []byte, install func(), trace *operationTrace) (_ []byte, err error) {
 
 	// Construct LDAP Bind request with GSSAPI SASL mechanism.
 	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
@@ -661,7 +844,7 @@ func (l *Conn) saslBindTokenExchange(reqControls []Control, reqToken []byte) ([]
 	request.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
 
 	auth := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, "", "authentication")
-	auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "GSSAPI", "SASL Mech"))
+	auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, mechanism, "SASL Mech"))
 	if len(reqToken) > 0 {
 		auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(reqToken), "Credentials"))
 	}
@@ -671,22 +854,51 @@ func (l *Conn) saslBindTokenExchange(reqControls []Control, reqToken []byte) ([]
 		envelope.AppendChild(encodeControls(reqControls))
 	}
 
-	msgCtx, err := l.sendMessage(envelope)
+	flags := scrubCredentials
+	if install != nil {
+		flags |= saslSecurityLayer
+	}
+	msgCtx, err := l.sendRequest(envelope, flags, "")
 	if err != nil {
 		return nil, err
 	}
-	defer l.finishMessage(msgCtx)
+	// like for StartTLS, the reader stops after reading the response. The
+	// layer is installed before the message is finished and other requests
+	// may be sent, and the reader is restarted afterwards.
+	received := false
+	defer func() {
+		if received && err == nil {
+			install()
+		}
+		l.finishMessage(msgCtx)
+		if received {
+			go l.reader()
+		}
+	}()
 
-	packet, err := l.readPacket(msgCtx)
+	var packet *ber.Packet
+	if install != nil {
+		response, ok := msgCtx.receive()
+		if !ok {
+			return nil, NewError(ErrorNetwork, errRespChanClosed)
+		}
+		received = response.Packet != nil
+		packet, err = response.ReadPacket()
+	} else {
+		packet, err = l.readPacket(msgCtx)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if trace != nil {
+		trace.observe(packet)
+	}
 	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 	if l.Debug {
 		if err = addLDAPDescriptions(packet); err != nil {
 			return nil, err
 		}
-		ber.PrintPacket(packet)
+		ber.PrintPacket(redactPacket(packet))
 	}
 
 	// https://www.rfc-editor.org/rfc/rfc4511#section-4.1.1
@@ -710,7 +922,7 @@ RESP:
 		}
 		switch resultCode.Value.(int64) {
 		case 14: // Sasl bind in progress
-			if len(protocolOp.Children) < 3 {
+			if len(protocolOp.Children) < 4 {
 				break RESP
 			}
 			referral := protocolOp.Children[3]
@@ -727,7 +939,6 @@ RESP:
 			//}
 		case 0: // Success - Bind OK.
 			// SASL layer in effect (if any) (See https://www.rfc-editor.org/rfc/rfc4513#section-5.2.1.4)
-			// NOTE: SASL security layers are not supported currently.
 			return nil, nil
 		}
 	}
@@ -0,0 +1,97 @@
+package ldap
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// bind guards set on a connection, see DisallowAnonymousBind and
+// DisallowUnauthenticatedBind
+const (
+	guardAnonymousBind uint32 = 1 << iota
+	guardUnauthenticatedBind
+)
+
+// BindDisallowedError is the underlying error of the *Error returned by
+// simple binds refused by the client because anonymous or unauthenticated
+// binds are disallowed on the connection. The *Error has the result code
+// ErrorEmptyPassword, so the bind fails even if the application only checks
+// for IsAuthError, e.g.
+//
+//	var disallowed *ldap.BindDisallowedError
+//	if errors.As(err, &disallowed) {
+//		...
+//	}
+type BindDisallowedError struct {
+	// Username is the name of the refused bind
+	Username string
+	// Anonymous is true if the bind was refused for its empty name, false
+	// if for its empty password
+	Anonymous bool
+}
+
+func (e *BindDisallowedError) Error() string {
+	if e.Anonymous {
+		return "ldap: anonymous bind disallowed by the client"
+	}
+	return fmt.Sprintf("ldap: unauthenticated bind of %q disallowed by the client", e.Username)
+}
+
+// DisallowAnonymousBind refuses simple binds with an empty name on this
+// connection without sending them, see RFC 4513 section 5.1.1. It guards
+// against authenticating users by binding with the name and password they
+// entered, where an empty name binds anonymously and succeeds.
+func (l *Conn) DisallowAnonymousBind(disallow bool) {
+	l.setBindGuard(guardAnonymousBind, disallow)
+}
+
+// DisallowUnauthenticatedBind refuses simple binds with a name but an empty
+// password on this connection without sending them, even if the request
+// sets AllowEmptyPassword or is sent by UnauthenticatedBind. Many servers
+// treat such binds as anonymous binds and report success, see RFC 4513
+// section 5.1.2.
+func (l *Conn) DisallowUnauthenticatedBind(disallow bool) {
+	l.setBindGuard(guardUnauthenticatedBind, disallow)
+}
+
+func (l *Conn) setBindGuard(guard uint32, set bool) {
+	for {
+		old := atomic.LoadUint32(&l.bindGuards)
+		updated := old &^ guard
+		if set {
+			updated |= guard
+		}
+		if atomic.CompareAndSwapUint32(&l.bindGuards, old, updated) {
+			return
+		}
+	}
+}
+
+// checkBindGuards returns an error if the guards of the connection refuse
+// the simple bind
+func (l *Conn) checkBindGuards(req *SimpleBindRequest) error {
+	guards := atomic.LoadUint32(&l.bindGuards)
+	switch {
+	case guards&guardAnonymousBind != 0 && req.Username == "":
+		return NewError(ErrorEmptyPassword, &BindDisallowedError{Anonymous: true})
+	case guards&guardUnauthenticatedBind != 0 && req.Username != "" && !req.hasPassword():
+		return NewError(ErrorEmptyPassword, &BindDisallowedError{Username: req.Username})
+	}
+	return nil
+}
+
+// DialWithDisallowAnonymousBind disallows anonymous binds on the new
+// connection, see Conn.DisallowAnonymousBind.
+func DialWithDisallowAnonymousBind() DialOpt {
+	return func(dc *DialContext) {
+		dc.bindGuards |= guardAnonymousBind
+	}
+}
+
+// DialWithDisallowUnauthenticatedBind disallows unauthenticated binds on the
+// new connection, see Conn.DisallowUnauthenticatedBind.
+func DialWithDisallowUnauthenticatedBind() DialOpt {
+	return func(dc *DialContext) {
+		dc.bindGuards |= guardUnauthenticatedBind
+	}
+}
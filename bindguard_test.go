@@ -0,0 +1,42 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindGuards(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	conn.DisallowAnonymousBind(true)
+	conn.DisallowUnauthenticatedBind(true)
+
+	var disallowed *BindDisallowedError
+	err := conn.UnauthenticatedBind("")
+	if assert.True(t, errors.As(err, &disallowed)) {
+		assert.True(t, disallowed.Anonymous)
+	}
+	assert.True(t, IsAuthError(err))
+
+	_, err = conn.SimpleBind(&SimpleBindRequest{Username: "cn=a,dc=example,dc=com", AllowEmptyPassword: true})
+	if assert.True(t, errors.As(err, &disallowed)) {
+		assert.False(t, disallowed.Anonymous)
+		assert.Equal(t, "cn=a,dc=example,dc=com", disallowed.Username)
+	}
+	assert.True(t, errors.Is(err, ErrEmptyPassword))
+
+	// binds with a name and password are sent
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Bind("cn=a,dc=example,dc=com", "secret"))
+
+	conn.DisallowUnauthenticatedBind(false)
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.UnauthenticatedBind("cn=a,dc=example,dc=com"))
+	assert.Error(t, conn.UnauthenticatedBind(""))
+}
@@ -0,0 +1,64 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// PacketDirection tells whether a captured PDU was sent or received
+type PacketDirection int
+
+// Packet directions
+const (
+	PacketSent PacketDirection = iota
+	PacketReceived
+)
+
+// PacketDirectionMap contains human readable descriptions of packet directions
+var PacketDirectionMap = map[PacketDirection]string{
+	PacketSent:     "sent",
+	PacketReceived: "received",
+}
+
+// PacketCaptureFunc receives the raw BER encoding of a PDU. For TLS connections
+// data is the decrypted plaintext. data is a copy and may be retained.
+type PacketCaptureFunc func(direction PacketDirection, data []byte)
+
+type captureHolder struct {
+	capture PacketCaptureFunc
+}
+
+// SetPacketCapture installs a hook receiving the raw BER bytes of every PDU sent
+// and received on this connection. Passing nil removes the hook. The hook is
+// called from the connection's internal goroutines and must not block.
+func (l *Conn) SetPacketCapture(capture PacketCaptureFunc) {
+	l.packetCapture.Store(captureHolder{capture: capture})
+}
+
+func (l *Conn) getPacketCapture() PacketCaptureFunc {
+	holder, _ := l.packetCapture.Load().(captureHolder)
+	return holder.capture
+}
+
+// capturePacket hands a copy of data to the packet capture hook, if any
+func (l *Conn) capturePacket(direction PacketDirection, data []byte) {
+	if capture := l.getPacketCapture(); capture != nil {
+		buf := make([]byte, len(data))
+		copy(buf, data)
+		capture(direction, buf)
+	}
+}
+
+// DumpPackets returns a PacketCaptureFunc writing a timestamped hex dump of each
+// PDU to w, similar to the output of packet capture tools.
+func DumpPackets(w io.Writer) PacketCaptureFunc {
+	var mu sync.Mutex
+	return func(direction PacketDirection, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s %s %d bytes\n%s", time.Now().Format(time.RFC3339Nano), PacketDirectionMap[direction], len(data), hex.Dump(data))
+	}
+}
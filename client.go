@@ -1,11 +1,15 @@
 package ldap
 
 import (
+	"context"
 	"crypto/tls"
 	"time"
 )
 
-// Client knows how to interact with an LDAP server
+// Client knows how to interact with an LDAP server. It covers the protocol
+// operations of Conn, so that mocks and decorators can stand in for it.
+// Helpers built on these operations, like GetEntry or RootDSE, and the
+// configuration of logging, tracing and middleware are not part of it.
 type Client interface {
 	Start()
 	StartTLS(*tls.Config) error
@@ -17,19 +21,50 @@ type Client interface {
 	Bind(username, password string) error
 	UnauthenticatedBind(username string) error
 	SimpleBind(*SimpleBindRequest) (*SimpleBindResult, error)
+	SimpleBindContext(context.Context, *SimpleBindRequest) (*SimpleBindResult, error)
 	ExternalBind() error
+	MD5Bind(host, username, password string) error
+	DigestMD5Bind(*DigestMD5BindRequest) (*DigestMD5BindResult, error)
+	NTLMBind(domain, username, password string) error
+	NTLMBindWithHash(domain, username, hash string) error
 	NTLMUnauthenticatedBind(domain, username string) error
+	NTLMChallengeBind(*NTLMBindRequest) (*NTLMBindResult, error)
+	GSSAPIBind(client GSSAPIClient, servicePrincipal, authzid string) error
+	GSSAPIBindRequest(GSSAPIClient, *GSSAPIBindRequest) error
 	Unbind() error
 
 	Add(*AddRequest) error
+	AddContext(context.Context, *AddRequest) error
+	AddWithResult(*AddRequest) (*WriteResult, error)
+	AddWithResultContext(context.Context, *AddRequest) (*WriteResult, error)
 	Del(*DelRequest) error
+	DelContext(context.Context, *DelRequest) error
+	DelWithResult(*DelRequest) (*WriteResult, error)
+	DelWithResultContext(context.Context, *DelRequest) (*WriteResult, error)
 	Modify(*ModifyRequest) error
+	ModifyContext(context.Context, *ModifyRequest) error
 	ModifyDN(*ModifyDNRequest) error
+	ModifyDNContext(context.Context, *ModifyDNRequest) error
+	ModifyDNWithResult(*ModifyDNRequest) (*WriteResult, error)
+	ModifyDNWithResultContext(context.Context, *ModifyDNRequest) (*WriteResult, error)
 	ModifyWithResult(*ModifyRequest) (*ModifyResult, error)
+	ModifyWithResultContext(context.Context, *ModifyRequest) (*ModifyResult, error)
 
 	Compare(dn, attribute, value string) (bool, error)
+	CompareContext(ctx context.Context, dn, attribute, value string) (bool, error)
+	CompareWithResult(*CompareRequest) (*CompareResult, error)
+	CompareWithResultContext(context.Context, *CompareRequest) (*CompareResult, error)
 	PasswordModify(*PasswordModifyRequest) (*PasswordModifyResult, error)
+	PasswordModifyContext(context.Context, *PasswordModifyRequest) (*PasswordModifyResult, error)
+	WhoAmI(controls []Control) (*WhoAmIResult, error)
+	Extended(*ExtendedRequest) (*ExtendedResponse, error)
+	ExtendedContext(context.Context, *ExtendedRequest) (*ExtendedResponse, error)
 
 	Search(*SearchRequest) (*SearchResult, error)
+	SearchContext(context.Context, *SearchRequest) (*SearchResult, error)
+	SearchAsync(ctx context.Context, searchRequest *SearchRequest, bufferSize int) Response
+	SearchWithCallback(searchRequest *SearchRequest, onEntry func(*Entry) error, onReferral func([]string), onControls func([]Control)) error
 	SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error)
+	DirSync(searchRequest *SearchRequest, flags, maxAttrCount int64, cookie []byte) (*SearchResult, error)
+	Syncrepl(ctx context.Context, searchRequest *SearchRequest, control *ControlSyncRequest, onEntry func(*Entry, *ControlSyncState) error, onInfo func(*SyncInfo) error) (*ControlSyncDone, error)
 }
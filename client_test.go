@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+// connHelpers are the exported methods of Conn which are deliberately not
+// part of Client
+var connHelpers = map[string]bool{
+	"ADPasswordModify":            true,
+	"Batch":                       true,
+	"BatchContext":                true,
+	"BindWithCredentials":         true,
+	"CheckPasswordQuality":        true,
+	"CompareMany":                 true,
+	"CompareManyContext":          true,
+	"DisableAccount":              true,
+	"DisallowAnonymousBind":       true,
+	"DisallowUnauthenticatedBind": true,
+	"EnableAccount":               true,
+	"Exists":                      true,
+	"GetADAccountState":           true,
+	"GetEffectivePrivileges":      true,
+	"GetEntry":                    true,
+	"GetGroupMembers":             true,
+	"GetLAPSPassword":             true,
+	"GetLockoutInfo":              true,
+	"GetPasswordQualityPolicy":    true,
+	"GetTokenGroups":              true,
+	"IsMemberOf":                  true,
+	"LookupSIDs":                  true,
+	"ModifyUserAccountControl":    true,
+	"Move":                        true,
+	"MoveSubtree":                 true,
+	"NMASSetPassword":             true,
+	"RefreshRootDSE":              true,
+	"Rename":                      true,
+	"RootDSE":                     true,
+	"SearchIter":                  true,
+	"SearchOne":                   true,
+	"SearchPager":                 true,
+	"SearchWithReferrals":         true,
+	"SetAuditor":                  true,
+	"SetBindThrottle":             true,
+	"SetControlValidation":        true,
+	"SetDecodingMode":             true,
+	"SetLogger":                   true,
+	"SetPacketCapture":            true,
+	"SetSlowQueryThreshold":       true,
+	"SetTracer":                   true,
+	"StreamAttributeValue":        true,
+	"TokenGroups":                 true,
+	"Unlock":                      true,
+	"Use":                         true,
+}
+
+// TestClientCoversConn fails if an exported method is added to Conn without
+// adding it to Client or to connHelpers
+func TestClientCoversConn(t *testing.T) {
+	client := reflect.TypeOf((*Client)(nil)).Elem()
+	conn := reflect.TypeOf(&Conn{})
+	for i := 0; i < conn.NumMethod(); i++ {
+		name := conn.Method(i).Name
+		if _, ok := client.MethodByName(name); !ok && !connHelpers[name] {
+			t.Errorf("Conn.%s is neither part of Client nor listed in connHelpers", name)
+		}
+	}
+}
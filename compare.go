@@ -1,7 +1,10 @@
 package ldap
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -11,6 +14,19 @@ type CompareRequest struct {
 	DN        string
 	Attribute string
 	Value     string
+	// Controls hold optional controls to send with the request
+	Controls []Control
+}
+
+// NewCompareRequest returns a compare request of the given attribute value of the entry
+// with the given DN, which can be passed to CompareWithResult
+func NewCompareRequest(dn, attribute, value string, controls []Control) *CompareRequest {
+	return &CompareRequest{
+		DN:        dn,
+		Attribute: attribute,
+		Value:     value,
+		Controls:  controls,
+	}
 }
 
 func (req *CompareRequest) appendTo(envelope *ber.Packet) error {
@@ -24,38 +40,147 @@ func (req *CompareRequest) appendTo(envelope *ber.Packet) error {
 	pkt.AppendChild(ava)
 
 	envelope.AppendChild(pkt)
+	if len(req.Controls) > 0 {
+		envelope.AppendChild(encodeControls(req.Controls))
+	}
 
 	return nil
 }
 
+// CompareResult holds the server's response to a compare request
+type CompareResult struct {
+	// Matched is set if the entry has the compared value
+	Matched bool
+	// Controls are the returned controls
+	Controls []Control
+}
+
 // Compare checks to see if the attribute of the dn matches value. Returns true if it does otherwise
 // false with any error that occurs if any.
 func (l *Conn) Compare(dn, attribute, value string) (bool, error) {
-	msgCtx, err := l.doRequest(&CompareRequest{
-		DN:        dn,
-		Attribute: attribute,
-		Value:     value})
+	return l.CompareContext(context.Background(), dn, attribute, value)
+}
+
+// CompareContext performs the comparison of Compare. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) CompareContext(ctx context.Context, dn, attribute, value string) (_ bool, err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, NewCompareRequest(dn, attribute, value, nil))
+	if err != nil {
+		return false, err
+	}
+	result, err := l.compareResult(msgCtx)
 	if err != nil {
 		return false, err
 	}
+	return result.Matched, nil
+}
+
+// CompareWithResult performs the CompareRequest and returns the result including the response
+// controls
+func (l *Conn) CompareWithResult(req *CompareRequest) (*CompareResult, error) {
+	return l.CompareWithResultContext(context.Background(), req)
+}
+
+// CompareWithResultContext performs the CompareRequest and returns the result. The correlation ID
+// carried by ctx, see WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) CompareWithResultContext(ctx context.Context, req *CompareRequest) (_ *CompareResult, err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return l.compareResult(msgCtx)
+}
+
+// CompareMany compares the given values of the attributes of the entry with the given DN, e.g. to
+// check which of several ACL granting values it has. The compare requests are sent without
+// waiting for the result of each, see Batch. It returns whether each attribute has the value,
+// keyed by the attribute, and the error of the first failed comparison of the attributes in
+// sorted order, if any, in which case the map holds the results of the other attributes.
+func (l *Conn) CompareMany(dn string, assertions map[string]string) (map[string]bool, error) {
+	return l.CompareManyContext(context.Background(), dn, assertions)
+}
+
+// CompareManyContext performs the comparisons of CompareMany. The correlation ID carried by ctx,
+// see WithCorrelationID, is attached to the operations and any returned error. Once ctx is done,
+// the remaining comparisons are not sent.
+func (l *Conn) CompareManyContext(ctx context.Context, dn string, assertions map[string]string) (_ map[string]bool, err error) {
+	defer correlateError(ctx, &err)
+
+	attributes := make([]string, 0, len(assertions))
+	for attribute := range assertions {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+
+	errs := make([]error, len(attributes))
+	msgCtxs := make([]*messageContext, len(attributes))
+	results := make(map[string]bool, len(attributes))
+	// next is the oldest comparison awaiting its result
+	next := 0
+	receive := func() {
+		if msgCtxs[next] != nil {
+			var result *CompareResult
+			if result, errs[next] = l.compareResult(msgCtxs[next]); errs[next] == nil {
+				results[attributes[next]] = result.Matched
+			}
+		}
+		next++
+	}
+	for i, attribute := range attributes {
+		for i-next >= batchWindow {
+			receive()
+		}
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			continue
+		}
+		msgCtxs[i], errs[i] = l.doRequestContext(ctx, NewCompareRequest(dn, attribute, assertions[attribute], nil))
+	}
+	for next < len(attributes) {
+		receive()
+	}
+	for _, err := range errs {
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// compareResult waits for the response to a compare request
+func (l *Conn) compareResult(msgCtx *messageContext) (*CompareResult, error) {
 	defer l.finishMessage(msgCtx)
 
 	packet, err := l.readPacket(msgCtx)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if packet.Children[1].Tag != ApplicationCompareResponse {
+		return nil, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag)
 	}
 
-	if packet.Children[1].Tag == ApplicationCompareResponse {
-		err := GetLDAPError(packet)
-
-		switch {
-		case IsErrorWithCode(err, LDAPResultCompareTrue):
-			return true, nil
-		case IsErrorWithCode(err, LDAPResultCompareFalse):
-			return false, nil
-		default:
-			return false, err
+	result := &CompareResult{}
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			decodedChild, err := DecodeControl(child)
+			if err != nil {
+				return nil, errors.New("failed to decode child control: " + err.Error())
+			}
+			result.Controls = append(result.Controls, decodedChild)
 		}
 	}
-	return false, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag)
+
+	err = GetLDAPError(packet)
+	switch {
+	case IsErrorWithCode(err, LDAPResultCompareTrue):
+		result.Matched = true
+		return result, nil
+	case IsErrorWithCode(err, LDAPResultCompareFalse):
+		return result, nil
+	default:
+		return result, err
+	}
 }
@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareWithResult(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	received := make(chan int, 1)
+	go func() {
+		req, err := respondWithResultControls(ptc, ApplicationCompareResponse, LDAPResultCompareTrue, &ControlVChuPasswordMustChange{MustChange: true})
+		if err != nil {
+			return
+		}
+		received <- len(req.Children)
+	}()
+
+	result, err := conn.CompareWithResult(NewCompareRequest("uid=jdoe,dc=example,dc=com", "sn", "Doe", []Control{NewControlManageDsaIT(false)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Matched {
+		t.Error("expected the comparison to match")
+	}
+	if FindControl(result.Controls, ControlTypeVChuPasswordMustChange) == nil {
+		t.Errorf("expected the response control, got %v", result.Controls)
+	}
+	if n := <-received; n != 3 {
+		t.Errorf("expected the request to carry controls, got %d children", n)
+	}
+}
+
+func TestCompareMany(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		// the requests are sent in the sorted order of the attributes
+		for _, code := range []uint16{LDAPResultCompareTrue, LDAPResultInsufficientAccessRights, LDAPResultCompareFalse} {
+			if _, err := respondWithResult(ptc, ApplicationCompareResponse, code); err != nil {
+				return
+			}
+		}
+	}()
+
+	results, err := conn.CompareMany("cn=admins,dc=example,dc=com", map[string]string{
+		"member":      "uid=jdoe,dc=example,dc=com",
+		"owner":       "uid=jdoe,dc=example,dc=com",
+		"description": "admins",
+	})
+	if !IsErrorWithCode(err, LDAPResultInsufficientAccessRights) {
+		t.Errorf("unexpected error %v", err)
+	}
+	expected := map[string]bool{"description": true, "owner": false}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+}
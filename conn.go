@@ -2,6 +2,8 @@ package ldap
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -14,6 +16,8 @@ import (
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
 
+// The operations of the former message processing loop of Conn. They are no
+// longer used, as requests are written and responses are delivered directly.
 const (
 	// MessageQuit causes the processMessages loop to exit
 	MessageQuit = 0
@@ -50,37 +54,199 @@ func (pr *PacketResponse) ReadPacket() (*ber.Packet, error) {
 	return pr.Packet, pr.Error
 }
 
+// maxQueuedResponses is the number of responses buffered for a message
+// before the reader waits for them to be received, which bounds the memory
+// held for a slow consumer
+const maxQueuedResponses = 64
+
 type messageContext struct {
 	id int64
-	// close(done) should only be called from finishMessage()
-	done chan struct{}
-	// close(responses) should only be called from processMessages(), and only sent to from sendResponse()
-	responses chan *PacketResponse
+	// mu guards the responses of the message, cond is signaled when they
+	// change
+	mu   sync.Mutex
+	cond sync.Cond
+	// queue[head:] are the responses which are not received yet, held in
+	// inline unless there are more
+	queue  []PacketResponse
+	head   int
+	inline [2]PacketResponse
+	// finished is set by finishMessage, after which responses are dropped
+	finished bool
+	// closed is set once no more responses are delivered
+	closed bool
+	// timer triggers the request timeout, if any
+	timer *time.Timer
+	// trace is the span of the operation, if tracing is enabled
+	trace *operationTrace
+	// stream yields the responses passed through the middleware chain, if any
+	stream ResponseStream
+	// correlationID is the caller-supplied ID of the operation, if any
+	correlationID string
+	// completed is set once the final response of the message is delivered.
+	// It is only accessed by the reader.
+	completed bool
+}
+
+func newMessageContext(id int64, correlationID string) *messageContext {
+	msgCtx := &messageContext{id: id, correlationID: correlationID}
+	msgCtx.cond.L = &msgCtx.mu
+	msgCtx.queue = msgCtx.inline[:0]
+	return msgCtx
+}
+
+// deliver queues a response for the message, waiting while the queue is
+// full. Responses of finished or closed messages are dropped.
+func (msgCtx *messageContext) deliver(response PacketResponse) {
+	msgCtx.mu.Lock()
+	defer msgCtx.mu.Unlock()
+	for len(msgCtx.queue)-msgCtx.head >= maxQueuedResponses && !msgCtx.finished && !msgCtx.closed {
+		msgCtx.cond.Wait()
+	}
+	if msgCtx.finished || msgCtx.closed {
+		return
+	}
+	msgCtx.queue = append(msgCtx.queue, response)
+	msgCtx.cond.Broadcast()
+}
+
+// close ends the responses of the message after queueing err, if it is not
+// nil. It must only be called by whoever removed the message from the
+// messageMap.
+func (msgCtx *messageContext) close(err error) {
+	msgCtx.mu.Lock()
+	defer msgCtx.mu.Unlock()
+	if err != nil && !msgCtx.finished && !msgCtx.closed {
+		msgCtx.queue = append(msgCtx.queue, PacketResponse{Error: err})
+	}
+	msgCtx.closed = true
+	msgCtx.cond.Broadcast()
+}
+
+// receive returns the next response for the message, waiting for it to
+// arrive. It returns false once the responses are closed.
+func (msgCtx *messageContext) receive() (PacketResponse, bool) {
+	msgCtx.mu.Lock()
+	defer msgCtx.mu.Unlock()
+	for msgCtx.head == len(msgCtx.queue) && !msgCtx.closed {
+		msgCtx.cond.Wait()
+	}
+	if msgCtx.head == len(msgCtx.queue) {
+		return PacketResponse{}, false
+	}
+	response := msgCtx.queue[msgCtx.head]
+	msgCtx.queue[msgCtx.head] = PacketResponse{}
+	msgCtx.head++
+	if msgCtx.head == len(msgCtx.queue) {
+		msgCtx.queue = msgCtx.queue[:0]
+		msgCtx.head = 0
+	}
+	msgCtx.cond.Broadcast()
+	return response, true
+}
+
+// finish drops the queued responses of the message and any arriving later
+func (msgCtx *messageContext) finish() {
+	msgCtx.mu.Lock()
+	defer msgCtx.mu.Unlock()
+	msgCtx.finished = true
+	msgCtx.queue = nil
+	msgCtx.head = 0
+	msgCtx.inline = [2]PacketResponse{}
+	msgCtx.cond.Broadcast()
+}
+
+// isFinalResponse reports whether the response completes its operation, so
+// that any further response with the same message ID is a duplicate
+func isFinalResponse(packet *ber.Packet) bool {
+	switch packet.Children[1].Tag {
+	case ApplicationSearchResultEntry, ApplicationSearchResultReference, ApplicationIntermediateResponse:
+		return false
+	}
+	return true
+}
+
+// messageShards is the number of shards of a messageMap
+const messageShards = 32
+
+// messageMap holds the messages awaiting responses by message ID. It is
+// sharded, so that the reader and the goroutines sending requests rarely
+// contend for the same lock.
+type messageMap struct {
+	shards [messageShards]messageShard
+}
+
+type messageShard struct {
+	mu       sync.Mutex
+	contexts map[int64]*messageContext
+	// closed is set by closeAll, after which no messages are added
+	closed bool
+}
+
+func (m *messageMap) shard(messageID int64) *messageShard {
+	return &m.shards[uint64(messageID)%messageShards]
+}
+
+// add adds the message, returning false if the map is closed
+func (m *messageMap) add(msgCtx *messageContext) bool {
+	shard := m.shard(msgCtx.id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.closed {
+		return false
+	}
+	if shard.contexts == nil {
+		shard.contexts = make(map[int64]*messageContext)
+	}
+	shard.contexts[msgCtx.id] = msgCtx
+	return true
+}
+
+func (m *messageMap) get(messageID int64) *messageContext {
+	shard := m.shard(messageID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.contexts[messageID]
 }
 
-// sendResponse should only be called within the processMessages() loop which
-// is also responsible for closing the responses channel.
-func (msgCtx *messageContext) sendResponse(packet *PacketResponse) {
-	select {
-	case msgCtx.responses <- packet:
-		// Successfully sent packet to message handler.
-	case <-msgCtx.done:
-		// The request handler is done and will not receive more
-		// packets.
+// remove removes the message and returns true if it was in the map, in
+// which case the caller has to close it
+func (m *messageMap) remove(msgCtx *messageContext) bool {
+	shard := m.shard(msgCtx.id)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.contexts[msgCtx.id] != msgCtx {
+		return false
 	}
+	delete(shard.contexts, msgCtx.id)
+	return true
 }
 
-type messagePacket struct {
-	Op        int
-	MessageID int64
-	Packet    *ber.Packet
-	Context   *messageContext
+// closeAll closes the map and returns the messages it held, which the caller
+// has to close
+func (m *messageMap) closeAll() []*messageContext {
+	var contexts []*messageContext
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.Lock()
+		shard.closed = true
+		for _, msgCtx := range shard.contexts {
+			contexts = append(contexts, msgCtx)
+		}
+		shard.contexts = nil
+		shard.mu.Unlock()
+	}
+	return contexts
 }
 
 type sendMessageFlags uint
 
 const (
 	startTLS sendMessageFlags = 1 << iota
+	// scrubCredentials zeroes the encoding of the request once it is sent
+	scrubCredentials
+	// saslSecurityLayer stops the reader after the response like startTLS,
+	// so that the SASL security layer can be installed
+	saslSecurityLayer
 )
 
 // Conn represents an LDAP Connection
@@ -89,19 +255,33 @@ type Conn struct {
 	// so we need to ensure 64-bit alignment on 32-bit platforms.
 	// https://github.com/go-ldap/ldap/pull/199
 	requestTimeout      int64
+	lastMessageID       int64
+	decodingMode        uint32
+	bindGuards          uint32
+	validateControls    uint32
 	conn                net.Conn
 	isTLS               bool
 	closing             uint32
 	closeErr            atomic.Value
 	isStartingTLS       bool
+	installingSASLLayer bool
 	Debug               debugging
-	chanConfirm         chan struct{}
-	messageContexts     map[int64]*messageContext
-	chanMessage         chan *messagePacket
-	chanMessageID       chan int64
+	messages            messageMap
+	valueStreams        map[int64]*valueStream
 	wgClose             sync.WaitGroup
+	writeMu             sync.Mutex
 	outstandingRequests uint
 	messageMutex        sync.Mutex
+	tracer              Tracer
+	traceConfig         *traceConfig
+	structuredLogger    atomic.Value
+	middleware          []Middleware
+	slowQuery           *slowQueryConfig
+	packetCapture       atomic.Value
+	bindThrottle        atomic.Value
+	auditor             *Auditor
+	boundIdentity       atomic.Value
+	rootDSE             *RootDSE
 }
 
 var _ Client = &Conn{}
@@ -130,6 +310,14 @@ func DialWithTLSConfig(tc *tls.Config) DialOpt {
 	}
 }
 
+// DialWithLogger sets the structured logger of the new connection, so that
+// the connect event is logged as well.
+func DialWithLogger(logger StructuredLogger) DialOpt {
+	return func(dc *DialContext) {
+		dc.logger = logger
+	}
+}
+
 // DialWithTLSDialer is a wrapper for DialWithTLSConfig with the option to
 // specify a net.Dialer to for example define a timeout or a custom resolver.
 // @deprecated Use DialWithDialer and DialWithTLSConfig instead
@@ -142,8 +330,15 @@ func DialWithTLSDialer(tlsConfig *tls.Config, dialer *net.Dialer) DialOpt {
 
 // DialContext contains necessary parameters to dial the given ldap URL.
 type DialContext struct {
-	dialer    *net.Dialer
-	tlsConfig *tls.Config
+	dialer           *net.Dialer
+	tlsConfig        *tls.Config
+	logger           StructuredLogger
+	decodingMode     DecodingMode
+	credentials      CredentialProvider
+	bindThrottle     *BindThrottle
+	tlsFiles         *TLSFiles
+	bindGuards       uint32
+	validateControls bool
 }
 
 func (dc *DialContext) dial(u *url.URL) (net.Conn, error) {
@@ -176,7 +371,7 @@ func (dc *DialContext) dial(u *url.URL) (net.Conn, error) {
 		if port == "" {
 			port = DefaultLdapsPort
 		}
-		return tls.DialWithDialer(dc.dialer, "tcp", net.JoinHostPort(host, port), dc.tlsConfig)
+		return tls.DialWithDialer(dc.dialer, "tcp", net.JoinHostPort(host, port), strictTLSConfig(dc.tlsConfig))
 	}
 
 	return nil, fmt.Errorf("Unknown scheme '%s'", u.Scheme)
@@ -199,7 +394,7 @@ func Dial(network, addr string) (*Conn, error) {
 // and then returns a new Conn for the connection.
 // @deprecated Use DialURL instead.
 func DialTLS(network, addr string, config *tls.Config) (*Conn, error) {
-	c, err := tls.DialWithDialer(&net.Dialer{Timeout: DefaultTimeout}, network, addr, config)
+	c, err := tls.DialWithDialer(&net.Dialer{Timeout: DefaultTimeout}, network, addr, strictTLSConfig(config))
 	if err != nil {
 		return nil, NewError(ErrorNetwork, err)
 	}
@@ -225,35 +420,52 @@ func DialURL(addr string, opts ...DialOpt) (*Conn, error) {
 	if dc.dialer == nil {
 		dc.dialer = &net.Dialer{Timeout: DefaultTimeout}
 	}
+	if dc.tlsFiles != nil {
+		dc.tlsConfig = dc.tlsFiles.Config()
+	}
 
 	c, err := dc.dial(u)
 	if err != nil {
+		if dc.logger != nil {
+			dc.logger.Log(LogLevelError, "connect failed", "scheme", u.Scheme, "host", u.Host, "error", err)
+		}
 		return nil, NewError(ErrorNetwork, err)
 	}
 
 	conn := NewConn(c, u.Scheme == "ldaps")
+	conn.SetDecodingMode(dc.decodingMode)
+	conn.bindGuards = dc.bindGuards
+	conn.SetControlValidation(dc.validateControls)
+	if dc.logger != nil {
+		conn.SetLogger(dc.logger)
+		conn.log(LogLevelInfo, "connected", "scheme", u.Scheme, "host", u.Host, "remote_addr", c.RemoteAddr())
+	}
+	if dc.bindThrottle != nil {
+		conn.SetBindThrottle(dc.bindThrottle)
+	}
 	conn.Start()
+	if dc.credentials != nil {
+		if err := conn.BindWithCredentials(context.Background(), dc.credentials); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
 	return conn, nil
 }
 
 // NewConn returns a new Conn using conn for network I/O.
 func NewConn(conn net.Conn, isTLS bool) *Conn {
 	return &Conn{
-		conn:            conn,
-		chanConfirm:     make(chan struct{}),
-		chanMessageID:   make(chan int64),
-		chanMessage:     make(chan *messagePacket, 10),
-		messageContexts: map[int64]*messageContext{},
-		requestTimeout:  0,
-		isTLS:           isTLS,
+		conn:           conn,
+		requestTimeout: 0,
+		isTLS:          isTLS,
 	}
 }
 
-// Start initializes goroutines to read responses and process messages
+// Start initializes the goroutine reading responses
 func (l *Conn) Start() {
 	l.wgClose.Add(1)
 	go l.reader()
-	go l.processMessages()
 }
 
 // IsClosing returns whether or not we're currently closing.
@@ -272,16 +484,27 @@ func (l *Conn) Close() {
 	defer l.messageMutex.Unlock()
 
 	if l.setClosing() {
-		l.Debug.Printf("Sending quit message and waiting for confirmation")
-		l.chanMessage <- &messagePacket{Op: MessageQuit}
-		<-l.chanConfirm
-		close(l.chanMessage)
-
 		l.Debug.Printf("Closing network connection")
 		if err := l.conn.Close(); err != nil {
 			logger.Println(err)
 		}
 
+		// If we are closing due to an error, inform anyone who is waiting
+		// about the error.
+		err, _ := l.closeErr.Load().(error)
+		for _, msgCtx := range l.messages.closeAll() {
+			l.Debug.Printf("Closing responses for MessageID %d", msgCtx.id)
+			if e, ok := err.(*Error); ok {
+				// every operation gets a copy, as correlateError sets the
+				// correlation ID of the operation
+				e := *e
+				msgCtx.close(&e)
+				continue
+			}
+			msgCtx.close(err)
+		}
+		l.log(LogLevelInfo, "connection closed")
+
 		l.wgClose.Done()
 	}
 	l.wgClose.Wait()
@@ -292,12 +515,26 @@ func (l *Conn) SetTimeout(timeout time.Duration) {
 	atomic.StoreInt64(&l.requestTimeout, int64(timeout))
 }
 
-// Returns the next available messageID
+// maxMessageID is the largest message ID, see RFC 4511 section 4.1.1.1
+const maxMessageID = 1<<31 - 1
+
+// Returns the next available messageID. The IDs wrap around after
+// maxMessageID, skipping 0, which is reserved for unsolicited notifications,
+// and the IDs of the messages still awaiting responses.
 func (l *Conn) nextMessageID() int64 {
-	if messageID, ok := <-l.chanMessageID; ok {
-		return messageID
+	if l.IsClosing() {
+		return 0
+	}
+	for {
+		messageID := atomic.AddInt64(&l.lastMessageID, 1)
+		if messageID > maxMessageID {
+			atomic.CompareAndSwapInt64(&l.lastMessageID, messageID, 0)
+			continue
+		}
+		if l.messages.get(messageID) == nil {
+			return messageID
+		}
 	}
-	return 0
 }
 
 // StartTLS sends the command to start a TLS session and then creates a new TLS Client
@@ -313,7 +550,7 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 	packet.AppendChild(request)
 	l.Debug.PrintPacket(packet)
 
-	msgCtx, err := l.sendMessageWithFlags(packet, startTLS)
+	msgCtx, err := l.sendMessageWithFlags(packet, startTLS, "")
 	if err != nil {
 		return err
 	}
@@ -321,7 +558,7 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 
 	l.Debug.Printf("%d: waiting for response", msgCtx.id)
 
-	packetResponse, ok := <-msgCtx.responses
+	packetResponse, ok := msgCtx.receive()
 	if !ok {
 		return NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
 	}
@@ -340,7 +577,7 @@ func (l *Conn) StartTLS(config *tls.Config) error {
 	}
 
 	if err := GetLDAPError(packet); err == nil {
-		conn := tls.Client(l.conn, config)
+		conn := tls.Client(l.conn, strictTLSConfig(config))
 
 		if connErr := conn.Handshake(); connErr != nil {
 			l.Close()
@@ -369,53 +606,96 @@ func (l *Conn) TLSConnectionState() (state tls.ConnectionState, ok bool) {
 }
 
 func (l *Conn) sendMessage(packet *ber.Packet) (*messageContext, error) {
-	return l.sendMessageWithFlags(packet, 0)
+	return l.sendMessageWithFlags(packet, 0, "")
 }
 
-func (l *Conn) sendMessageWithFlags(packet *ber.Packet, flags sendMessageFlags) (*messageContext, error) {
+func (l *Conn) sendMessageWithFlags(packet *ber.Packet, flags sendMessageFlags, correlationID string) (*messageContext, error) {
 	if l.IsClosing() {
 		return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
 	}
 	l.messageMutex.Lock()
 	l.Debug.Printf("flags&startTLS = %d", flags&startTLS)
 	if l.isStartingTLS {
+		installingSASLLayer := l.installingSASLLayer
 		l.messageMutex.Unlock()
+		if installingSASLLayer {
+			return nil, NewError(ErrorNetwork, errors.New("ldap: connection is installing the SASL security layer"))
+		}
 		return nil, NewError(ErrorNetwork, errors.New("ldap: connection is in startls phase"))
 	}
-	if flags&startTLS != 0 {
+	if flags&(startTLS|saslSecurityLayer) != 0 {
 		if l.outstandingRequests != 0 {
 			l.messageMutex.Unlock()
+			if flags&saslSecurityLayer != 0 {
+				return nil, NewError(ErrorNetwork, errors.New("ldap: cannot install the SASL security layer with outstanding requests"))
+			}
 			return nil, NewError(ErrorNetwork, errors.New("ldap: cannot StartTLS with outstanding requests"))
 		}
 		l.isStartingTLS = true
+		l.installingSASLLayer = flags&saslSecurityLayer != 0
 	}
 	l.outstandingRequests++
 
 	l.messageMutex.Unlock()
 
-	responses := make(chan *PacketResponse)
 	messageID := packet.Children[0].Value.(int64)
-	message := &messagePacket{
-		Op:        MessageRequest,
-		MessageID: messageID,
-		Packet:    packet,
-		Context: &messageContext{
-			id:        messageID,
-			done:      make(chan struct{}),
-			responses: responses,
-		},
-	}
-	if !l.sendProcessMessage(message) {
-		if l.IsClosing() {
-			return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
+	msgCtx := newMessageContext(messageID, correlationID)
+	// the message is added before the request is written, as the response
+	// may arrive at any time after
+	if !l.messages.add(msgCtx) {
+		return nil, NewError(ErrorNetwork, errors.New("ldap: connection closed"))
+	}
+
+	l.Debug.Printf("Sending message %d", messageID)
+	buf := encodeMessage(packet)
+	size := buf.Len()
+	l.capturePacket(PacketSent, buf.Bytes())
+	l.writeMu.Lock()
+	_, err := l.conn.Write(buf.Bytes())
+	l.writeMu.Unlock()
+	if flags&scrubCredentials != 0 {
+		data := buf.Bytes()
+		zeroBytes(data[:cap(data)])
+	}
+	releaseBuffer(buf)
+	if err != nil {
+		l.Debug.Printf("Error Sending Message: %s", err.Error())
+		l.log(LogLevelError, "send failed", msgCtx.correlationFields("message_id", messageID, "error", err)...)
+		if l.messages.remove(msgCtx) {
+			msgCtx.close(NewError(ErrorNetwork, fmt.Errorf("unable to send request: %s", err)))
 		}
-		return nil, NewError(ErrorNetwork, errors.New("ldap: could not send message for unknown reason"))
+		return msgCtx, nil
+	}
+	l.log(LogLevelDebug, "request sent", msgCtx.correlationFields("message_id", messageID, "operation", packetOperation(packet), "bytes", size)...)
+
+	// Add timeout if defined
+	if timeout := time.Duration(atomic.LoadInt64(&l.requestTimeout)); timeout > 0 {
+		msgCtx.timer = time.AfterFunc(timeout, func() {
+			// Handle the timeout by closing the responses, all reads
+			// will return immediately
+			if l.messages.remove(msgCtx) {
+				l.Debug.Printf("Receiving message timeout for %d", messageID)
+				msgCtx.close(NewError(ErrorNetwork, errors.New("ldap: connection timed out")))
+			}
+		})
 	}
-	return message.Context, nil
+	return msgCtx, nil
 }
 
 func (l *Conn) finishMessage(msgCtx *messageContext) {
-	close(msgCtx.done)
+	msgCtx.finish()
+	if msgCtx.timer != nil {
+		msgCtx.timer.Stop()
+	}
+
+	if msgCtx.trace != nil {
+		msgCtx.trace.end()
+	}
+
+	l.Debug.Printf("Finished message %d", msgCtx.id)
+	if l.messages.remove(msgCtx) {
+		msgCtx.close(nil)
+	}
 
 	if l.IsClosing() {
 		return
@@ -425,121 +705,9 @@ func (l *Conn) finishMessage(msgCtx *messageContext) {
 	l.outstandingRequests--
 	if l.isStartingTLS {
 		l.isStartingTLS = false
+		l.installingSASLLayer = false
 	}
 	l.messageMutex.Unlock()
-
-	message := &messagePacket{
-		Op:        MessageFinish,
-		MessageID: msgCtx.id,
-	}
-	l.sendProcessMessage(message)
-}
-
-func (l *Conn) sendProcessMessage(message *messagePacket) bool {
-	l.messageMutex.Lock()
-	defer l.messageMutex.Unlock()
-	if l.IsClosing() {
-		return false
-	}
-	l.chanMessage <- message
-	return true
-}
-
-func (l *Conn) processMessages() {
-	defer func() {
-		if err := recover(); err != nil {
-			logger.Printf("ldap: recovered panic in processMessages: %v", err)
-		}
-		for messageID, msgCtx := range l.messageContexts {
-			// If we are closing due to an error, inform anyone who
-			// is waiting about the error.
-			if l.IsClosing() && l.closeErr.Load() != nil {
-				msgCtx.sendResponse(&PacketResponse{Error: l.closeErr.Load().(error)})
-			}
-			l.Debug.Printf("Closing channel for MessageID %d", messageID)
-			close(msgCtx.responses)
-			delete(l.messageContexts, messageID)
-		}
-		close(l.chanMessageID)
-		close(l.chanConfirm)
-	}()
-
-	var messageID int64 = 1
-	for {
-		select {
-		case l.chanMessageID <- messageID:
-			messageID++
-		case message := <-l.chanMessage:
-			switch message.Op {
-			case MessageQuit:
-				l.Debug.Printf("Shutting down - quit message received")
-				return
-			case MessageRequest:
-				// Add to message list and write to network
-				l.Debug.Printf("Sending message %d", message.MessageID)
-
-				buf := message.Packet.Bytes()
-				_, err := l.conn.Write(buf)
-				if err != nil {
-					l.Debug.Printf("Error Sending Message: %s", err.Error())
-					message.Context.sendResponse(&PacketResponse{Error: fmt.Errorf("unable to send request: %s", err)})
-					close(message.Context.responses)
-					break
-				}
-
-				// Only add to messageContexts if we were able to
-				// successfully write the message.
-				l.messageContexts[message.MessageID] = message.Context
-
-				// Add timeout if defined
-				if l.requestTimeout > 0 {
-					go func() {
-						timer := time.NewTimer(time.Duration(l.requestTimeout))
-						defer func() {
-							if err := recover(); err != nil {
-								logger.Printf("ldap: recovered panic in RequestTimeout: %v", err)
-							}
-
-							timer.Stop()
-						}()
-
-						select {
-						case <-timer.C:
-							timeoutMessage := &messagePacket{
-								Op:        MessageTimeout,
-								MessageID: message.MessageID,
-							}
-							l.sendProcessMessage(timeoutMessage)
-						case <-message.Context.done:
-						}
-					}()
-				}
-			case MessageResponse:
-				l.Debug.Printf("Receiving message %d", message.MessageID)
-				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
-					msgCtx.sendResponse(&PacketResponse{message.Packet, nil})
-				} else {
-					logger.Printf("Received unexpected message %d, %v", message.MessageID, l.IsClosing())
-					l.Debug.PrintPacket(message.Packet)
-				}
-			case MessageTimeout:
-				// Handle the timeout by closing the channel
-				// All reads will return immediately
-				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
-					l.Debug.Printf("Receiving message timeout for %d", message.MessageID)
-					msgCtx.sendResponse(&PacketResponse{message.Packet, NewError(ErrorNetwork, errors.New("ldap: connection timed out"))})
-					delete(l.messageContexts, message.MessageID)
-					close(msgCtx.responses)
-				}
-			case MessageFinish:
-				l.Debug.Printf("Finished message %d", message.MessageID)
-				if msgCtx, ok := l.messageContexts[message.MessageID]; ok {
-					delete(l.messageContexts, message.MessageID)
-					close(msgCtx.responses)
-				}
-			}
-		}
-	}
 }
 
 func (l *Conn) reader() {
@@ -553,18 +721,33 @@ func (l *Conn) reader() {
 		}
 	}()
 
-	bufConn := bufio.NewReader(l.conn)
+	bufConn := bufio.NewReader(&stickyErrorReader{r: l.conn})
+	var captured bytes.Buffer
 	for {
 		if cleanstop {
 			l.Debug.Printf("reader clean stopping (without closing the connection)")
 			return
 		}
-		packet, err := ber.ReadPacket(bufConn)
+		// data holds the exact bytes read for the packet capture hook and
+		// strict validation, except for streamed entries which are not held
+		var packet *ber.Packet
+		var data []byte
+		var err error
+		if stream := l.peekValueStream(bufConn); stream != nil {
+			packet, err = readStreamedEntry(bufConn, stream)
+		} else {
+			packet, data, err = readResponse(bufConn, &captured)
+		}
+		if err == nil && data != nil {
+			l.capturePacket(PacketReceived, data)
+		}
+		mode := l.getDecodingMode()
 		if err != nil {
 			// A read error is expected here if we are closing the connection...
 			if !l.IsClosing() {
-				l.closeErr.Store(fmt.Errorf("unable to read LDAP response packet: %s", err))
+				l.closeErr.Store(fmt.Errorf("unable to read LDAP response packet (%s decoding): %s", DecodingModeMap[mode], err))
 				l.Debug.Printf("reader error: %s", err)
+				l.log(LogLevelError, "read failed", "error", err)
 			}
 			return
 		}
@@ -580,13 +763,69 @@ func (l *Conn) reader() {
 			cleanstop = true
 		}
 		l.messageMutex.Unlock()
-		message := &messagePacket{
-			Op:        MessageResponse,
-			MessageID: packet.Children[0].Value.(int64),
-			Packet:    packet,
-		}
-		if !l.sendProcessMessage(message) {
+		messageID, ok := packet.Children[0].Value.(int64)
+		if !ok {
+			l.closeErr.Store(fmt.Errorf("unable to read LDAP response packet (%s decoding): missing message ID", DecodingModeMap[mode]))
+			l.Debug.Printf("reader error: missing message ID")
 			return
 		}
+		response := PacketResponse{Packet: packet}
+		if mode == DecodingStrict && data != nil {
+			if err := checkStrictMessage(data); err != nil {
+				l.Debug.Printf("%d: rejected response: %s", messageID, err)
+				response.Error = NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: malformed response (%s decoding): %s", DecodingModeMap[mode], err))
+			}
+		}
+		if messageID == 0 {
+			if err := l.unsolicitedNotification(packet); err != nil {
+				l.closeErr.Store(err)
+				return
+			}
+			continue
+		}
+		l.Debug.Printf("Receiving message %d", messageID)
+		if msgCtx := l.messages.get(messageID); msgCtx != nil {
+			if msgCtx.completed {
+				logger.Printf("Received duplicate response for message %d", messageID)
+				l.log(LogLevelWarn, "duplicate response", msgCtx.correlationFields("message_id", messageID, "operation", packetOperation(packet))...)
+				l.Debug.PrintPacket(packet)
+				continue
+			}
+			l.log(LogLevelDebug, "response received", msgCtx.correlationFields("message_id", messageID, "operation", packetOperation(packet))...)
+			msgCtx.completed = len(packet.Children) > 1 && isFinalResponse(packet)
+			msgCtx.deliver(response)
+		} else {
+			logger.Printf("Received unexpected message %d, %v", messageID, l.IsClosing())
+			l.log(LogLevelWarn, "unexpected response", "message_id", messageID, "operation", packetOperation(packet))
+			l.Debug.PrintPacket(packet)
+		}
+	}
+}
+
+// unsolicitedNotification handles a response with message ID 0. It returns
+// the error the connection is closed with if the server is about to close
+// it, see NoticeOfDisconnectionError.
+func (l *Conn) unsolicitedNotification(packet *ber.Packet) error {
+	var name string
+	if len(packet.Children) > 1 {
+		for _, child := range packet.Children[1].Children {
+			if child.ClassType == ber.ClassContext && child.Tag == extendedResponseNameTag {
+				name = child.Data.String()
+			}
+		}
+	}
+	l.Debug.Printf("Received unsolicited notification %q", name)
+	if name != noticeOfDisconnectionOID {
+		logger.Printf("Received unsolicited notification %q", name)
+		l.log(LogLevelWarn, "unsolicited notification", "name", name)
+		l.Debug.PrintPacket(packet)
+		return nil
+	}
+	notice := &NoticeOfDisconnectionError{ResultCode: LDAPResultOther}
+	if err, ok := GetLDAPError(packet).(*Error); ok {
+		notice.ResultCode = err.ResultCode
+		notice.DiagnosticMessage = err.DiagnosticMessage
 	}
+	l.log(LogLevelWarn, "notice of disconnection", "result_code", notice.ResultCode, "diagnostic_message", notice.DiagnosticMessage)
+	return &Error{ResultCode: notice.ResultCode, DiagnosticMessage: notice.DiagnosticMessage, Err: notice, Packet: packet}
 }
@@ -1,8 +1,10 @@
 package ldap
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -45,7 +47,7 @@ func TestUnresponsiveConnection(t *testing.T) {
 	}
 	defer conn.finishMessage(msgCtx)
 
-	packetResponse, ok := <-msgCtx.responses
+	packetResponse, ok := msgCtx.receive()
 	if !ok {
 		t.Fatalf("no PacketResponse in response channel")
 	}
@@ -104,6 +106,39 @@ func TestFinishMessage(t *testing.T) {
 	conn.Close()
 }
 
+// TestUnreceivedResponses tests that responses which are not received yet
+// do not hold up the responses of other messages
+func TestUnreceivedResponses(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	pending := testSendRequest(t, ptc, conn)
+	responsePacket := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	responsePacket.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, pending.id, "MessageID"))
+	for i := 0; i < 3; i++ {
+		if err := ptc.SendResponse(responsePacket); err != nil {
+			t.Fatalf("unable to send response packet: %s", err)
+		}
+	}
+
+	msgCtx := testSendRequest(t, ptc, conn)
+	testReceiveResponse(t, ptc, msgCtx)
+	conn.finishMessage(msgCtx)
+
+	for i := 0; i < 3; i++ {
+		runWithTimeout(t, time.Second, func() {
+			if _, ok := pending.receive(); !ok {
+				t.Fatal("responses closed")
+			}
+		})
+	}
+	conn.finishMessage(pending)
+}
+
 // See: https://github.com/go-ldap/ldap/issues/332
 func TestNilConnection(t *testing.T) {
 	var conn *Conn
@@ -113,6 +148,102 @@ func TestNilConnection(t *testing.T) {
 	}
 }
 
+func TestNextMessageIDWraparound(t *testing.T) {
+	conn := NewConn(nil, false)
+	conn.lastMessageID = maxMessageID - 2
+	inUse := newMessageContext(1, "")
+	conn.messages.add(inUse)
+
+	// 0 is reserved for unsolicited notifications and 1 is in use
+	for _, want := range []int64{maxMessageID - 1, maxMessageID, 2} {
+		if got := conn.nextMessageID(); got != want {
+			t.Errorf("got message ID %d, expected %d", got, want)
+		}
+	}
+
+	conn.messages.remove(inUse)
+	conn.lastMessageID = maxMessageID
+	if got := conn.nextMessageID(); got != 1 {
+		t.Errorf("got message ID %d, expected 1", got)
+	}
+}
+
+func TestDuplicateResponse(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	msgCtx := testSendRequest(t, ptc, conn)
+	defer conn.finishMessage(msgCtx)
+	sendSearchResult(ptc, msgCtx.id, LDAPResultSuccess)
+	sendSearchResult(ptc, msgCtx.id, LDAPResultSuccess)
+	other := testSendRequest(t, ptc, conn)
+	testReceiveResponse(t, ptc, other)
+	conn.finishMessage(other)
+
+	runWithTimeout(t, time.Second, func() {
+		if _, ok := msgCtx.receive(); !ok {
+			t.Fatal("response channel closed")
+		}
+	})
+	// the duplicate was dropped before the response of the other message
+	msgCtx.mu.Lock()
+	queued := len(msgCtx.queue) - msgCtx.head
+	msgCtx.mu.Unlock()
+	if queued != 0 {
+		t.Errorf("got %d queued responses, expected none", queued)
+	}
+}
+
+func TestNoticeOfDisconnection(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	msgCtx := testSendRequest(t, ptc, conn)
+	defer conn.finishMessage(msgCtx)
+
+	notice := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	notice.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(0), "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultUnavailable), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "shutting down", "diagnosticMessage"))
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extendedResponseNameTag, noticeOfDisconnectionOID, "responseName"))
+	notice.AppendChild(response)
+	if err := ptc.SendResponse(notice); err != nil {
+		t.Fatalf("unable to send notice: %s", err)
+	}
+
+	var err error
+	runWithTimeout(t, time.Second, func() {
+		packetResponse, ok := msgCtx.receive()
+		if !ok {
+			t.Fatal("response channel closed")
+		}
+		_, err = packetResponse.ReadPacket()
+	})
+	var noticeErr *NoticeOfDisconnectionError
+	if !errors.As(err, &noticeErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if noticeErr.ResultCode != LDAPResultUnavailable || noticeErr.DiagnosticMessage != "shutting down" {
+		t.Errorf("unexpected notice %+v", noticeErr)
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("%v is not ErrUnavailable", err)
+	}
+	if !conn.IsClosing() {
+		t.Error("connection is not closed")
+	}
+}
+
 func testSendRequest(t *testing.T, ptc *packetTranslatorConn, conn *Conn) (msgCtx *messageContext) {
 	var msgID int64
 	runWithTimeout(t, time.Second, func() {
@@ -155,7 +286,7 @@ func testReceiveResponse(t *testing.T, ptc *packetTranslatorConn, msgCtx *messag
 
 	// We should be able to receive the packet from the connection.
 	runWithTimeout(t, time.Second, func() {
-		if _, ok := <-msgCtx.responses; !ok {
+		if _, ok := msgCtx.receive(); !ok {
 			t.Fatal("response channel closed")
 		}
 	})
@@ -268,7 +399,7 @@ func (c *packetTranslatorConn) SendResponse(packet *ber.Packet) error {
 	return nil
 }
 
-// Write is called by the processMessages() loop to send request packets.
+// Write is called by Conn to send request packets.
 func (c *packetTranslatorConn) Write(b []byte) (n int, err error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -343,3 +474,130 @@ func (c *packetTranslatorConn) SetReadDeadline(t time.Time) error {
 func (c *packetTranslatorConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
+
+func TestPacketCapture(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var mu sync.Mutex
+	captured := map[PacketDirection][]byte{}
+	conn.SetPacketCapture(func(direction PacketDirection, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		captured[direction] = data
+	})
+
+	msgCtx := testSendRequest(t, ptc, conn)
+	testReceiveResponse(t, ptc, msgCtx)
+	conn.finishMessage(msgCtx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, direction := range []PacketDirection{PacketSent, PacketReceived} {
+		packet, err := ber.DecodePacketErr(captured[direction])
+		if err != nil {
+			t.Fatalf("failed to decode %s packet: %s", PacketDirectionMap[direction], err)
+		}
+		if packet.Children[0].Value.(int64) != msgCtx.id {
+			t.Errorf("unexpected message ID in %s packet: %v", PacketDirectionMap[direction], packet.Children[0].Value)
+		}
+	}
+}
+
+// serveSearches answers every search request read from conn with a single
+// entry, until conn is closed. The responses are encoded from prepared
+// protocol operations, so that serving adds little to benchmarks.
+func serveSearches(conn net.Conn) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn=a,dc=example,dc=com", "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	attributes.AppendChild((&Attribute{Type: "cn", Vals: []string{"a"}}).encode())
+	op.AppendChild(attributes)
+	entry := op.Bytes()
+	op = ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	done := op.Bytes()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var buf bytes.Buffer
+	for {
+		data, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		req, err := decodePacket(data)
+		if err != nil || len(req.Children) < 2 || req.Children[1].Tag != ApplicationSearchRequest {
+			continue
+		}
+		// the message ID is echoed as received
+		messageID := req.Children[0]
+		id := data[len(data)-req.Data.Len() : len(data)-req.Data.Len()+2+messageID.Data.Len()]
+		for _, op := range [][]byte{entry, done} {
+			buf.Reset()
+			appendHeader(&buf, req.Identifier, len(id)+len(op))
+			buf.Write(id)
+			buf.Write(op)
+			_, _ = w.Write(buf.Bytes())
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// BenchmarkConcurrentSearches runs 1000 searches at a time on a single
+// connection
+func BenchmarkConcurrentSearches(b *testing.B) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSearches(c)
+		}
+	}()
+	c, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	conn := NewConn(c, false)
+	conn.Start()
+	defer conn.Close()
+
+	const concurrency = 1000
+	req := NewSearchRequest("dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		b.Run(fmt.Sprintf("timeout=%s", timeout), func(b *testing.B) {
+			conn.SetTimeout(timeout)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				wg.Add(concurrency)
+				for j := 0; j < concurrency; j++ {
+					go func() {
+						defer wg.Done()
+						if _, err := conn.Search(req); err != nil {
+							b.Error(err)
+						}
+					}()
+				}
+				wg.Wait()
+			}
+		})
+	}
+}
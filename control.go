@@ -3,6 +3,7 @@ package ldap
 import (
 	"fmt"
 	"strconv"
+	"sync"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -22,6 +23,18 @@ const (
 	ControlTypeWhoAmI = "1.3.6.1.4.1.4203.1.11.3"
 	// ControlTypeSubTreeDelete - https://datatracker.ietf.org/doc/html/draft-armijo-ldap-treedelete-02
 	ControlTypeSubtreeDelete = "1.2.840.113556.1.4.805"
+	// ControlTypeAssertion - https://tools.ietf.org/html/rfc4528
+	ControlTypeAssertion = "1.3.6.1.1.12"
+	// ControlTypeSyncRequest - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncRequest = "1.3.6.1.4.1.4203.1.9.1.1"
+	// ControlTypeSyncState - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncState = "1.3.6.1.4.1.4203.1.9.1.2"
+	// ControlTypeSyncDone - https://tools.ietf.org/html/rfc4533
+	ControlTypeSyncDone = "1.3.6.1.4.1.4203.1.9.1.3"
+	// ControlTypeServerSideSortingResult - https://tools.ietf.org/html/rfc2891
+	ControlTypeServerSideSortingResult = "1.2.840.113556.1.4.474"
+	// ControlTypeVLVResponse - https://tools.ietf.org/html/draft-ietf-ldapext-ldapv3-vlv-09
+	ControlTypeVLVResponse = "2.16.840.1.113730.3.4.10"
 
 	// ControlTypeMicrosoftNotification - https://msdn.microsoft.com/en-us/library/aa366983(v=vs.85).aspx
 	ControlTypeMicrosoftNotification = "1.2.840.113556.1.4.528"
@@ -29,17 +42,38 @@ const (
 	ControlTypeMicrosoftShowDeleted = "1.2.840.113556.1.4.417"
 	// ControlTypeMicrosoftServerLinkTTL - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/f4f523a8-abc0-4b3a-a471-6b2fef135481?redirectedfrom=MSDN
 	ControlTypeMicrosoftServerLinkTTL = "1.2.840.113556.1.4.2309"
+	// ControlTypeMicrosoftExtendedDN - https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/57056773-932c-4e55-9491-e13f49ba580c
+	ControlTypeMicrosoftExtendedDN = "1.2.840.113556.1.4.529"
+	// ControlTypeMicrosoftPolicyHints - https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/4ae22f0e-1e2c-4d47-b2b2-7e0e8e8fbc9c
+	ControlTypeMicrosoftPolicyHints = "1.2.840.113556.1.4.2239"
+	// ControlTypeMicrosoftPolicyHintsDeprecated is the OID of the policy hints
+	// control used by Windows Server 2008 R2 SP1
+	ControlTypeMicrosoftPolicyHintsDeprecated = "1.2.840.113556.1.4.2066"
+	// ControlTypeMicrosoftDirSync - https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
+	ControlTypeMicrosoftDirSync = "1.2.840.113556.1.4.841"
 )
 
 // ControlTypeMap maps controls to text descriptions
 var ControlTypeMap = map[string]string{
-	ControlTypePaging:                 "Paging",
-	ControlTypeBeheraPasswordPolicy:   "Password Policy - Behera Draft",
-	ControlTypeManageDsaIT:            "Manage DSA IT",
-	ControlTypeSubtreeDelete:          "Subtree Delete Control",
-	ControlTypeMicrosoftNotification:  "Change Notification - Microsoft",
-	ControlTypeMicrosoftShowDeleted:   "Show Deleted Objects - Microsoft",
-	ControlTypeMicrosoftServerLinkTTL: "Return TTL-DNs for link values with associated expiry times - Microsoft",
+	ControlTypePaging:                         "Paging",
+	ControlTypeBeheraPasswordPolicy:           "Password Policy - Behera Draft",
+	ControlTypeVChuPasswordMustChange:         "Password Expired - Netscape",
+	ControlTypeVChuPasswordWarning:            "Password Expiring - Netscape",
+	ControlTypeManageDsaIT:                    "Manage DSA IT",
+	ControlTypeSubtreeDelete:                  "Subtree Delete Control",
+	ControlTypeAssertion:                      "Assertion",
+	ControlTypeSyncRequest:                    "Sync Request",
+	ControlTypeSyncState:                      "Sync State",
+	ControlTypeSyncDone:                       "Sync Done",
+	ControlTypeServerSideSortingResult:        "Server Side Sorting Result",
+	ControlTypeVLVResponse:                    "Virtual List View Response",
+	ControlTypeMicrosoftNotification:          "Change Notification - Microsoft",
+	ControlTypeMicrosoftShowDeleted:           "Show Deleted Objects - Microsoft",
+	ControlTypeMicrosoftServerLinkTTL:         "Return TTL-DNs for link values with associated expiry times - Microsoft",
+	ControlTypeMicrosoftExtendedDN:            "Extended DN - Microsoft",
+	ControlTypeMicrosoftPolicyHints:           "Policy Hints - Microsoft",
+	ControlTypeMicrosoftPolicyHintsDeprecated: "Policy Hints (deprecated) - Microsoft",
+	ControlTypeMicrosoftDirSync:               "DirSync - Microsoft",
 }
 
 // Control defines an interface controls provide to encode and describe themselves
@@ -52,7 +86,8 @@ type Control interface {
 	String() string
 }
 
-// ControlString implements the Control interface for simple controls
+// ControlString implements the Control interface for simple controls. Controls
+// of unknown types received from a server are decoded as ControlRaw.
 type ControlString struct {
 	ControlType  string
 	Criticality  bool
@@ -82,6 +117,50 @@ func (c *ControlString) String() string {
 	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t  Control Value: %s", ControlTypeMap[c.ControlType], c.ControlType, c.Criticality, c.ControlValue)
 }
 
+// ControlRaw is a control with a type this package does not decode, e.g. a
+// vendor control in a response. DecodeControl returns unknown controls as
+// ControlRaw, so the value can be decoded by the caller, e.g.
+//
+//	if c, ok := ldap.FindControl(result.Controls, oid).(*ldap.ControlRaw); ok {
+//		value, err := ber.DecodePacketErr(c.Value)
+//		...
+//	}
+type ControlRaw struct {
+	// OID is the control type
+	OID string
+	// Criticality is the criticality of the control
+	Criticality bool
+	// Value is the content of the control value octet string, nil if the
+	// control has no value
+	Value []byte
+	// Packet is the decoded control, nil for controls created by the
+	// application
+	Packet *ber.Packet
+}
+
+// GetControlType returns the OID
+func (c *ControlRaw) GetControlType() string {
+	return c.OID
+}
+
+// Encode returns the ber packet representation
+func (c *ControlRaw) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.OID, "Control Type ("+ControlTypeMap[c.OID]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	if c.Value != nil {
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Value), "Control Value"))
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlRaw) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t  Control Value: %x", ControlTypeMap[c.OID], c.OID, c.Criticality, c.Value)
+}
+
 // ControlPaging implements the paging control described in https://www.ietf.org/rfc/rfc2696.txt
 type ControlPaging struct {
 	// PagingSize indicates the page size
@@ -146,11 +225,31 @@ func (c *ControlBeheraPasswordPolicy) GetControlType() string {
 	return ControlTypeBeheraPasswordPolicy
 }
 
-// Encode returns the ber packet representation
+// Encode returns the ber packet representation. The control of a request has no value, the
+// PasswordPolicyResponseValue is only encoded if Expire, Grace or Error is set.
 func (c *ControlBeheraPasswordPolicy) Encode() *ber.Packet {
 	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
 	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type ("+ControlTypeMap[ControlTypeBeheraPasswordPolicy]+")"))
+	if c.Expire < 0 && c.Grace < 0 && c.Error < 0 {
+		return packet
+	}
 
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Password Policy - Behera)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswordPolicyResponseValue")
+	if c.Expire >= 0 || c.Grace >= 0 {
+		warning := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Warning")
+		if c.Expire >= 0 {
+			warning.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 0, c.Expire, "timeBeforeExpiration"))
+		} else {
+			warning.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, c.Grace, "graceAuthNsRemaining"))
+		}
+		seq.AppendChild(warning)
+	}
+	if c.Error >= 0 {
+		seq.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, int64(c.Error), "Error"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
 	return packet
 }
 
@@ -168,6 +267,9 @@ func (c *ControlBeheraPasswordPolicy) String() string {
 }
 
 // ControlVChuPasswordMustChange implements the control described in https://tools.ietf.org/html/draft-vchu-ldap-pwd-policy-00
+// It is the Netscape Password Expired control, which Sun/Oracle DSEE and 389
+// Directory Server return on a bind if the password has expired or must be
+// changed after a reset.
 type ControlVChuPasswordMustChange struct {
 	// MustChange indicates if the password is required to be changed
 	MustChange bool
@@ -180,7 +282,10 @@ func (c *ControlVChuPasswordMustChange) GetControlType() string {
 
 // Encode returns the ber packet representation
 func (c *ControlVChuPasswordMustChange) Encode() *ber.Packet {
-	return nil
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeVChuPasswordMustChange, "Control Type ("+ControlTypeMap[ControlTypeVChuPasswordMustChange]+")"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "0", "Control Value (Password Expired)"))
+	return packet
 }
 
 // String returns a human-readable description
@@ -194,6 +299,8 @@ func (c *ControlVChuPasswordMustChange) String() string {
 }
 
 // ControlVChuPasswordWarning implements the control described in https://tools.ietf.org/html/draft-vchu-ldap-pwd-policy-00
+// It is the Netscape Password Expiring control, which Sun/Oracle DSEE and 389
+// Directory Server return on a bind if the password expires soon.
 type ControlVChuPasswordWarning struct {
 	// Expire indicates the time in seconds until the password expires
 	Expire int64
@@ -206,13 +313,16 @@ func (c *ControlVChuPasswordWarning) GetControlType() string {
 
 // Encode returns the ber packet representation
 func (c *ControlVChuPasswordWarning) Encode() *ber.Packet {
-	return nil
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeVChuPasswordWarning, "Control Type ("+ControlTypeMap[ControlTypeVChuPasswordWarning]+")"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, strconv.FormatInt(c.Expire, 10), "Control Value (Password Expiring)"))
+	return packet
 }
 
 // String returns a human-readable description
 func (c *ControlVChuPasswordWarning) String() string {
 	return fmt.Sprintf(
-		"Control Type: %s (%q)  Criticality: %t  Expire: %b",
+		"Control Type: %s (%q)  Criticality: %t  Expire: %d",
 		ControlTypeMap[ControlTypeVChuPasswordWarning],
 		ControlTypeVChuPasswordWarning,
 		false,
@@ -255,6 +365,57 @@ func NewControlManageDsaIT(Criticality bool) *ControlManageDsaIT {
 	return &ControlManageDsaIT{Criticality: Criticality}
 }
 
+// ControlAssertion implements the control described in https://tools.ietf.org/html/rfc4528
+// The operation it is attached to is only performed if the target entry
+// matches the filter, otherwise it fails with LDAPResultAssertionFailed.
+type ControlAssertion struct {
+	// Criticality should be true, servers not supporting the control
+	// otherwise perform the operation unconditionally
+	Criticality bool
+	// Filter is the assertion the target entry must match
+	Filter string
+}
+
+// GetControlType returns the OID
+func (c *ControlAssertion) GetControlType() string {
+	return ControlTypeAssertion
+}
+
+// Encode returns the ber packet representation
+func (c *ControlAssertion) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeAssertion, "Control Type ("+ControlTypeMap[ControlTypeAssertion]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Assertion)")
+	// an invalid filter is sent as an empty value, which the server rejects
+	if filter, err := CompileFilter(c.Filter); err == nil {
+		value.AppendChild(filter)
+	}
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlAssertion) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Filter: %s",
+		ControlTypeMap[ControlTypeAssertion],
+		ControlTypeAssertion,
+		c.Criticality,
+		c.Filter)
+}
+
+// NewControlAssertion returns a critical ControlAssertion control for the
+// given filter. An error is returned if the filter is invalid.
+func NewControlAssertion(filter string) (*ControlAssertion, error) {
+	if _, err := CompileFilter(filter); err != nil {
+		return nil, err
+	}
+	return &ControlAssertion{Criticality: true, Filter: filter}, nil
+}
+
 // ControlMicrosoftNotification implements the control described in https://msdn.microsoft.com/en-us/library/aa366983(v=vs.85).aspx
 type ControlMicrosoftNotification struct{}
 
@@ -342,6 +503,285 @@ func NewControlMicrosoftServerLinkTTL() *ControlMicrosoftServerLinkTTL {
 	return &ControlMicrosoftServerLinkTTL{}
 }
 
+// ControlMicrosoftExtendedDN implements the control described in https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/57056773-932c-4e55-9491-e13f49ba580c
+// It makes the server return DNs with the objectGUID and objectSid of the
+// objects prepended, which can be read with ParseExtendedDN.
+type ControlMicrosoftExtendedDN struct {
+	// StringFormat requests GUIDs and SIDs in their string instead of their
+	// hexadecimal binary form
+	StringFormat bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftExtendedDN) GetControlType() string {
+	return ControlTypeMicrosoftExtendedDN
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftExtendedDN) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftExtendedDN, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftExtendedDN]+")"))
+	if c.StringFormat {
+		value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Extended DN)")
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ExtendedDNRequestValue")
+		seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "Flag"))
+		value.AppendChild(seq)
+		packet.AppendChild(value)
+	}
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftExtendedDN) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  StringFormat: %t",
+		ControlTypeMap[ControlTypeMicrosoftExtendedDN],
+		ControlTypeMicrosoftExtendedDN,
+		c.StringFormat)
+}
+
+// NewControlMicrosoftExtendedDN returns a ControlMicrosoftExtendedDN control
+func NewControlMicrosoftExtendedDN(stringFormat bool) *ControlMicrosoftExtendedDN {
+	return &ControlMicrosoftExtendedDN{StringFormat: stringFormat}
+}
+
+// ControlMicrosoftPolicyHints implements the LDAP_SERVER_POLICY_HINTS
+// control described in https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/4ae22f0e-1e2c-4d47-b2b2-7e0e8e8fbc9c
+// Attached to a password reset, it makes Active Directory enforce the
+// password history and other policies which otherwise only apply to password
+// changes.
+type ControlMicrosoftPolicyHints struct {
+	Criticality bool
+	// Enforce turns on the enforcement of password policies
+	Enforce bool
+	// Deprecated sends the control with the OID understood by Windows Server
+	// 2008 R2, see ControlTypeMicrosoftPolicyHintsDeprecated
+	Deprecated bool
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftPolicyHints) GetControlType() string {
+	if c.Deprecated {
+		return ControlTypeMicrosoftPolicyHintsDeprecated
+	}
+	return ControlTypeMicrosoftPolicyHints
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftPolicyHints) Encode() *ber.Packet {
+	controlType := c.GetControlType()
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, controlType, "Control Type ("+ControlTypeMap[controlType]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	flags := int64(0)
+	if c.Enforce {
+		flags = 1
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Policy Hints)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PolicyHintsRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, flags, "Flags"))
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftPolicyHints) String() string {
+	controlType := c.GetControlType()
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Enforce: %t",
+		ControlTypeMap[controlType],
+		controlType,
+		c.Criticality,
+		c.Enforce)
+}
+
+// NewControlMicrosoftPolicyHints returns a critical
+// ControlMicrosoftPolicyHints control
+func NewControlMicrosoftPolicyHints(enforce bool) *ControlMicrosoftPolicyHints {
+	return &ControlMicrosoftPolicyHints{Criticality: true, Enforce: enforce}
+}
+
+// SyncMode is the mode of a content synchronization, see ControlSyncRequest
+type SyncMode int64
+
+// Content synchronization modes as defined in https://tools.ietf.org/html/rfc4533
+const (
+	SyncModeRefreshOnly       SyncMode = 1
+	SyncModeRefreshAndPersist SyncMode = 3
+)
+
+// ControlSyncRequest implements the Sync Request control of the content
+// synchronization operation described in https://tools.ietf.org/html/rfc4533
+type ControlSyncRequest struct {
+	Criticality bool
+	Mode        SyncMode
+	// Cookie is the synchronization state of the client, nil for an
+	// initial content load
+	Cookie     []byte
+	ReloadHint bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncRequest) GetControlType() string {
+	return ControlTypeSyncRequest
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncRequest) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncRequest, "Control Type ("+ControlTypeMap[ControlTypeSyncRequest]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Request)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "syncRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.Mode), "Mode"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	if c.ReloadHint {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReloadHint, "Reload Hint"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncRequest) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Mode: %d  Cookie: %q  ReloadHint: %t",
+		ControlTypeMap[ControlTypeSyncRequest],
+		ControlTypeSyncRequest,
+		c.Criticality,
+		c.Mode,
+		c.Cookie,
+		c.ReloadHint)
+}
+
+// NewControlSyncRequest returns a critical ControlSyncRequest control
+func NewControlSyncRequest(mode SyncMode, cookie []byte, reloadHint bool) *ControlSyncRequest {
+	return &ControlSyncRequest{
+		Criticality: true,
+		Mode:        mode,
+		Cookie:      cookie,
+		ReloadHint:  reloadHint,
+	}
+}
+
+// SyncState is the state of an entry reported by ControlSyncState
+type SyncState int64
+
+// Entry states as defined in https://tools.ietf.org/html/rfc4533
+const (
+	SyncStatePresent SyncState = 0
+	SyncStateAdd     SyncState = 1
+	SyncStateModify  SyncState = 2
+	SyncStateDelete  SyncState = 3
+)
+
+// syncStateMap contains human readable descriptions of entry states
+var syncStateMap = map[SyncState]string{
+	SyncStatePresent: "present",
+	SyncStateAdd:     "add",
+	SyncStateModify:  "modify",
+	SyncStateDelete:  "delete",
+}
+
+// String returns the name of the state
+func (s SyncState) String() string {
+	if name, ok := syncStateMap[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", int64(s))
+}
+
+// ControlSyncState implements the Sync State control the server attaches to
+// the entries returned by a content synchronization, see
+// https://tools.ietf.org/html/rfc4533
+type ControlSyncState struct {
+	State     SyncState
+	EntryUUID UUID
+	// Cookie is the new synchronization state, if the server sent one
+	Cookie []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncState) GetControlType() string {
+	return ControlTypeSyncState
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncState) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncState, "Control Type ("+ControlTypeMap[ControlTypeSyncState]+")"))
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync State)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "syncStateValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.State), "State"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.EntryUUID[:]), "Entry UUID"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncState) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  State: %s  EntryUUID: %s  Cookie: %q",
+		ControlTypeMap[ControlTypeSyncState],
+		ControlTypeSyncState,
+		c.State,
+		c.EntryUUID,
+		c.Cookie)
+}
+
+// ControlSyncDone implements the Sync Done control the server attaches to
+// the end of a content synchronization, see https://tools.ietf.org/html/rfc4533
+type ControlSyncDone struct {
+	Cookie []byte
+	// RefreshDeletes is true if the refresh used the delete phase, i.e. the
+	// deleted entries were sent explicitly
+	RefreshDeletes bool
+}
+
+// GetControlType returns the OID
+func (c *ControlSyncDone) GetControlType() string {
+	return ControlTypeSyncDone
+}
+
+// Encode returns the ber packet representation
+func (c *ControlSyncDone) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeSyncDone, "Control Type ("+ControlTypeMap[ControlTypeSyncDone]+")"))
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sync Done)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "syncDoneValue")
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	}
+	if c.RefreshDeletes {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.RefreshDeletes, "Refresh Deletes"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlSyncDone) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Cookie: %q  RefreshDeletes: %t",
+		ControlTypeMap[ControlTypeSyncDone],
+		ControlTypeSyncDone,
+		c.Cookie,
+		c.RefreshDeletes)
+}
+
 // FindControl returns the first control of the given type in the list, or nil
 func FindControl(controls []Control, controlType string) Control {
 	for _, c := range controls {
@@ -352,7 +792,30 @@ func FindControl(controls []Control, controlType string) Control {
 	return nil
 }
 
-// DecodeControl returns a control read from the given packet, or nil if no recognized control can be made
+// ControlDecoder decodes a control packet, i.e. the sequence of the control type, criticality and
+// value, see RegisterControlDecoder
+type ControlDecoder func(packet *ber.Packet) (Control, error)
+
+var (
+	controlDecodersMutex sync.RWMutex
+	controlDecoders      = make(map[string]ControlDecoder)
+)
+
+// RegisterControlDecoder registers the decoder used by DecodeControl for controls of the given type,
+// e.g. for vendor controls. A registered decoder takes precedence over the decoding of this package.
+// Registering a nil decoder removes the decoder of the type.
+func RegisterControlDecoder(oid string, decoder ControlDecoder) {
+	controlDecodersMutex.Lock()
+	defer controlDecodersMutex.Unlock()
+	if decoder == nil {
+		delete(controlDecoders, oid)
+		return
+	}
+	controlDecoders[oid] = decoder
+}
+
+// DecodeControl returns a control read from the given packet. Controls of unknown types are returned
+// as *ControlRaw unless a decoder is registered with RegisterControlDecoder.
 func DecodeControl(packet *ber.Packet) (Control, error) {
 	var (
 		ControlType = ""
@@ -368,11 +831,9 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 	case 1:
 		// just type, no criticality or value
 		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
 
 	case 2:
 		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
 
 		// Children[1] could be criticality or value (both are optional)
 		// duck-type on whether this is a boolean
@@ -386,10 +847,13 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 
 	case 3:
 		packet.Children[0].Description = "Control Type (" + ControlTypeMap[ControlType] + ")"
-		ControlType = packet.Children[0].Value.(string)
 
 		packet.Children[1].Description = "Criticality"
-		Criticality = packet.Children[1].Value.(bool)
+		criticality, ok := packet.Children[1].Value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("control criticality is not a boolean")
+		}
+		Criticality = criticality
 
 		packet.Children[2].Description = "Control Value"
 		value = packet.Children[2]
@@ -398,11 +862,26 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 		// more than 3 children is invalid
 		return nil, fmt.Errorf("more than 3 children is invalid for controls")
 	}
+	controlType, ok := packet.Children[0].Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("control type is not a string")
+	}
+	ControlType = controlType
+
+	controlDecodersMutex.RLock()
+	decoder := controlDecoders[ControlType]
+	controlDecodersMutex.RUnlock()
+	if decoder != nil {
+		return decoder(packet)
+	}
 
 	switch ControlType {
 	case ControlTypeManageDsaIT:
 		return NewControlManageDsaIT(Criticality), nil
 	case ControlTypePaging:
+		if value == nil {
+			return nil, fmt.Errorf("paging control requires a value")
+		}
 		value.Description += " (Paging)"
 		c := new(ControlPaging)
 		if value.Value != nil {
@@ -414,17 +893,28 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 			value.Value = nil
 			value.AppendChild(valueChildren)
 		}
+		if len(value.Children) != 1 || len(value.Children[0].Children) != 2 {
+			return nil, fmt.Errorf("invalid paging control value")
+		}
 		value = value.Children[0]
 		value.Description = "Search Control Value"
 		value.Children[0].Description = "Paging Size"
 		value.Children[1].Description = "Cookie"
-		c.PagingSize = uint32(value.Children[0].Value.(int64))
+		size, ok := value.Children[0].Value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("paging size is not an integer")
+		}
+		c.PagingSize = uint32(size)
 		c.Cookie = value.Children[1].Data.Bytes()
 		value.Children[1].Value = c.Cookie
 		return c, nil
 	case ControlTypeBeheraPasswordPolicy:
-		value.Description += " (Password Policy - Behera)"
 		c := NewControlBeheraPasswordPolicy()
+		if value == nil {
+			// requests carry no value
+			return c, nil
+		}
+		value.Description += " (Password Policy - Behera)"
 		if value.Value != nil {
 			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
 			if err != nil {
@@ -435,36 +925,11 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 			value.AppendChild(valueChildren)
 		}
 
-		sequence := value.Children[0]
-
-		for _, child := range sequence.Children {
-			if child.Tag == 0 {
-				//Warning
-				warningPacket := child.Children[0]
-				val, err := ber.ParseInt64(warningPacket.Data.Bytes())
-				if err != nil {
-					return nil, fmt.Errorf("failed to decode data bytes: %s", err)
-				}
-				if warningPacket.Tag == 0 {
-					//timeBeforeExpiration
-					c.Expire = val
-					warningPacket.Value = c.Expire
-				} else if warningPacket.Tag == 1 {
-					//graceAuthNsRemaining
-					c.Grace = val
-					warningPacket.Value = c.Grace
-				}
-			} else if child.Tag == 1 {
-				// Error
-				bs := child.Data.Bytes()
-				if len(bs) != 1 || bs[0] > 8 {
-					return nil, fmt.Errorf("failed to decode data bytes: %s", "invalid PasswordPolicyResponse enum value")
-				}
-				val := int8(bs[0])
-				c.Error = val
-				child.Value = c.Error
-				c.ErrorString = BeheraPasswordPolicyErrorMap[c.Error]
-			}
+		if len(value.Children) != 1 {
+			return nil, fmt.Errorf("invalid password policy control value")
+		}
+		if err := c.decode(value.Children[0]); err != nil {
+			return nil, err
 		}
 		return c, nil
 	case ControlTypeVChuPasswordMustChange:
@@ -472,6 +937,9 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 		return c, nil
 	case ControlTypeVChuPasswordWarning:
 		c := &ControlVChuPasswordWarning{Expire: -1}
+		if value == nil {
+			return nil, fmt.Errorf("password expiring control requires a value")
+		}
 		expireStr := ber.DecodeString(value.Data.Bytes())
 
 		expire, err := strconv.ParseInt(expireStr, 10, 64)
@@ -488,14 +956,143 @@ func DecodeControl(packet *ber.Packet) (Control, error) {
 		return NewControlMicrosoftShowDeleted(), nil
 	case ControlTypeMicrosoftServerLinkTTL:
 		return NewControlMicrosoftServerLinkTTL(), nil
+	case ControlTypeMicrosoftExtendedDN:
+		c := NewControlMicrosoftExtendedDN(false)
+		if value == nil {
+			return c, nil
+		}
+		value.Description += " (Extended DN)"
+		if value.Value != nil {
+			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+			}
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) == 1 && len(value.Children[0].Children) == 1 {
+			flag := value.Children[0].Children[0]
+			flag.Description = "Flag"
+			c.StringFormat = flag.Value == int64(1)
+		}
+		return c, nil
 	case ControlTypeSubtreeDelete:
 		return NewControlSubtreeDelete(), nil
+	case ControlTypeMicrosoftPolicyHints, ControlTypeMicrosoftPolicyHintsDeprecated:
+		c := &ControlMicrosoftPolicyHints{
+			Criticality: Criticality,
+			Deprecated:  ControlType == ControlTypeMicrosoftPolicyHintsDeprecated,
+		}
+		if value == nil {
+			return c, nil
+		}
+		value.Description += " (Policy Hints)"
+		if value.Value != nil {
+			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+			}
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) == 1 && len(value.Children[0].Children) == 1 {
+			flags := value.Children[0].Children[0]
+			flags.Description = "Flags"
+			c.Enforce = flags.Value == int64(1)
+		}
+		return c, nil
+	case ControlTypeAssertion:
+		c := &ControlAssertion{Criticality: Criticality}
+		if value == nil {
+			return nil, fmt.Errorf("assertion control requires a value")
+		}
+		value.Description += " (Assertion)"
+		if value.Value != nil {
+			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+			}
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) != 1 {
+			return nil, fmt.Errorf("assertion control requires a filter")
+		}
+		filter, err := DecompileFilter(value.Children[0])
+		if err != nil {
+			return nil, err
+		}
+		c.Filter = filter
+		return c, nil
+	case ControlTypeSyncRequest, ControlTypeSyncState, ControlTypeSyncDone:
+		if value == nil {
+			return nil, fmt.Errorf("%s control requires a value", ControlTypeMap[ControlType])
+		}
+		value.Description += " (" + ControlTypeMap[ControlType] + ")"
+		if value.Value != nil {
+			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+			}
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) != 1 {
+			return nil, fmt.Errorf("invalid %s control value", ControlTypeMap[ControlType])
+		}
+		return decodeSyncControl(ControlType, Criticality, value.Children[0].Children)
+	case ControlTypeMicrosoftDirSync:
+		if value == nil {
+			return nil, fmt.Errorf("DirSync control requires a value")
+		}
+		value.Description += " (DirSync)"
+		if value.Value != nil {
+			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+			}
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) != 1 {
+			return nil, fmt.Errorf("invalid DirSync control value")
+		}
+		return decodeDirSyncControl(Criticality, value.Children[0].Children)
+	case ControlTypeServerSideSortingResult, ControlTypeVLVResponse:
+		if value == nil {
+			return nil, fmt.Errorf("%s control requires a value", ControlTypeMap[ControlType])
+		}
+		value.Description += " (" + ControlTypeMap[ControlType] + ")"
+		if value.Value != nil {
+			valueChildren, err := ber.DecodePacketErr(value.Data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode data bytes: %s", err)
+			}
+			value.Data.Truncate(0)
+			value.Value = nil
+			value.AppendChild(valueChildren)
+		}
+		if len(value.Children) != 1 {
+			return nil, fmt.Errorf("invalid %s control value", ControlTypeMap[ControlType])
+		}
+		if ControlType == ControlTypeServerSideSortingResult {
+			return decodeSortResultControl(Criticality, value.Children[0].Children)
+		}
+		return decodeVLVResponseControl(Criticality, value.Children[0].Children)
 	default:
-		c := new(ControlString)
-		c.ControlType = ControlType
-		c.Criticality = Criticality
+		c := &ControlRaw{
+			OID:         ControlType,
+			Criticality: Criticality,
+			Packet:      packet,
+		}
 		if value != nil {
-			c.ControlValue = value.Value.(string)
+			// unknown values are kept in their encoded form
+			c.Value = append([]byte{}, value.Data.Bytes()...)
 		}
 		return c, nil
 	}
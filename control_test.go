@@ -32,15 +32,97 @@ func TestControlMicrosoftServerLinkTTL(t *testing.T) {
 	runControlTest(t, NewControlMicrosoftServerLinkTTL())
 }
 
+func TestControlMicrosoftExtendedDN(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftExtendedDN(false))
+	runControlTest(t, NewControlMicrosoftExtendedDN(true))
+}
+
+func TestControlMicrosoftPolicyHints(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftPolicyHints(true))
+	runControlTest(t, NewControlMicrosoftPolicyHints(false))
+	runControlTest(t, &ControlMicrosoftPolicyHints{Enforce: true, Deprecated: true})
+}
+
+func TestControlSync(t *testing.T) {
+	runControlTest(t, NewControlSyncRequest(SyncModeRefreshOnly, nil, false))
+	runControlTest(t, NewControlSyncRequest(SyncModeRefreshAndPersist, []byte("cookie"), true))
+	runControlTest(t, &ControlSyncState{State: SyncStateModify, EntryUUID: UUID{1, 2, 3}, Cookie: []byte("cookie")})
+	runControlTest(t, &ControlSyncState{State: SyncStatePresent})
+	runControlTest(t, &ControlSyncDone{})
+	runControlTest(t, &ControlSyncDone{Cookie: []byte("cookie"), RefreshDeletes: true})
+}
+
+func TestControlVChuPasswordPolicy(t *testing.T) {
+	runControlTest(t, &ControlVChuPasswordMustChange{MustChange: true})
+	runControlTest(t, &ControlVChuPasswordWarning{Expire: 3600})
+}
+
+func TestControlSortAndVLVResponse(t *testing.T) {
+	runControlTest(t, &ControlServerSideSortingResult{Result: LDAPResultSuccess})
+	runControlTest(t, &ControlServerSideSortingResult{Result: LDAPResultNoSuchAttribute, AttributeType: "sn"})
+	runControlTest(t, &ControlVLVResponse{TargetPosition: 11, ContentCount: 250, Result: LDAPResultSuccess})
+	runControlTest(t, &ControlVLVResponse{TargetPosition: 1, ContentCount: 2, Result: LDAPResultOffsetRangeError, ContextID: []byte("context")})
+
+	sortResult := &ControlServerSideSortingResult{Result: LDAPResultNoSuchAttribute, AttributeType: "sn"}
+	vlv := &ControlVLVResponse{TargetPosition: 11, ContentCount: 250, ContextID: []byte("context")}
+	result := &SearchResult{Controls: []Control{
+		&ControlPaging{PagingSize: 100, Cookie: []byte("1")}, sortResult, vlv,
+		&ControlPaging{PagingSize: 0, Cookie: []byte("2")},
+	}}
+	if !reflect.DeepEqual(sortResult, result.SortResult()) || !reflect.DeepEqual(vlv, result.VLVResponse()) {
+		t.Errorf("unexpected sort result %v or VLV response %v", result.SortResult(), result.VLVResponse())
+	}
+	if estimate, ok := result.SizeEstimate(); !ok || estimate != 100 {
+		t.Errorf("unexpected size estimate %d, %t", estimate, ok)
+	}
+	result = &SearchResult{}
+	if _, ok := result.SizeEstimate(); ok || result.SortResult() != nil || result.VLVResponse() != nil {
+		t.Error("unexpected response controls of an empty result")
+	}
+}
+
 func TestControlSubtreeDelete(t *testing.T) {
 	runControlTest(t, NewControlSubtreeDelete())
 }
 
 func TestControlString(t *testing.T) {
-	runControlTest(t, NewControlString("x", true, "y"))
-	runControlTest(t, NewControlString("x", true, ""))
-	runControlTest(t, NewControlString("x", false, "y"))
-	runControlTest(t, NewControlString("x", false, ""))
+	// controls of unknown types are decoded as ControlRaw
+	for _, c := range []*ControlString{
+		NewControlString("x", true, "y"),
+		NewControlString("x", true, ""),
+		NewControlString("x", false, "y"),
+		NewControlString("x", false, ""),
+	} {
+		decoded, err := DecodeControl(c.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, ok := decoded.(*ControlRaw)
+		if !ok || raw.OID != c.ControlType || raw.Criticality != c.Criticality || string(raw.Value) != c.ControlValue {
+			t.Errorf("unexpected decoded control %#v for %s", decoded, c)
+		}
+	}
+}
+
+func TestControlRaw(t *testing.T) {
+	runControlTest(t, &ControlRaw{OID: "1.2.3.4", Criticality: true, Value: []byte{0x30, 0x03, 0x02, 0x01, 0x05}})
+	runControlTest(t, &ControlRaw{OID: "1.2.3.4", Value: []byte{}})
+	runControlTest(t, &ControlRaw{OID: "1.2.3.4"})
+
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Value")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(5), "Count"))
+	packet, err := ber.DecodePacketErr((&ControlRaw{OID: "1.2.3.4", Value: value.Bytes()}).Encode().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeControl(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := decoded.(*ControlRaw)
+	if !ok || raw.Packet != packet || !bytes.Equal(raw.Value, value.Bytes()) {
+		t.Errorf("unexpected decoded control %#v", decoded)
+	}
 }
 
 func runControlTest(t *testing.T, originalControl Control) {
@@ -210,3 +292,97 @@ func TestDecodeControl(t *testing.T) {
 		})
 	}
 }
+
+func TestControlAssertion(t *testing.T) {
+	c, err := NewControlAssertion("(userAccountControl=512)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runControlTest(t, c)
+	if _, err := NewControlAssertion("(invalid"); err == nil {
+		t.Error("expected error for invalid filter")
+	}
+}
+
+func TestSimpleBindNetscapePasswordControls(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		if _, err := respondWithResultControls(ptc, ApplicationBindResponse, LDAPResultSuccess, &ControlVChuPasswordWarning{Expire: 86400}); err != nil {
+			return
+		}
+		_, _ = respondWithResultControls(ptc, ApplicationBindResponse, LDAPResultInvalidCredentials, &ControlVChuPasswordMustChange{MustChange: true})
+	}()
+
+	result, err := conn.SimpleBind(NewSimpleBindRequest("uid=jdoe,dc=example", "secret", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	warning, ok := FindControl(result.Controls, ControlTypeVChuPasswordWarning).(*ControlVChuPasswordWarning)
+	if !ok || warning.Expire != 86400 {
+		t.Errorf("expected password expiring control, got %v", result.Controls)
+	}
+
+	result, err = conn.SimpleBind(NewSimpleBindRequest("uid=jdoe,dc=example", "secret", nil))
+	if !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	if expired, ok := FindControl(result.Controls, ControlTypeVChuPasswordMustChange).(*ControlVChuPasswordMustChange); !ok || !expired.MustChange {
+		t.Errorf("expected password expired control, got %v", result.Controls)
+	}
+}
+
+// controlCount is a vendor control used to test RegisterControlDecoder
+type controlCount struct {
+	Count int64
+}
+
+func (c *controlCount) GetControlType() string { return "1.2.3.4" }
+
+func (c *controlCount) Encode() *ber.Packet {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Value")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Count, "Count"))
+	return (&ControlRaw{OID: "1.2.3.4", Value: value.Bytes()}).Encode()
+}
+
+func (c *controlCount) String() string { return fmt.Sprintf("Count: %d", c.Count) }
+
+func TestRegisterControlDecoder(t *testing.T) {
+	RegisterControlDecoder("1.2.3.4", func(packet *ber.Packet) (Control, error) {
+		if len(packet.Children) != 2 {
+			return nil, fmt.Errorf("count control requires a value")
+		}
+		value, err := ber.DecodePacketErr(packet.Children[1].Data.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if len(value.Children) != 1 {
+			return nil, fmt.Errorf("invalid count control value")
+		}
+		count, ok := value.Children[0].Value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("count is not an integer")
+		}
+		return &controlCount{Count: count}, nil
+	})
+	defer RegisterControlDecoder("1.2.3.4", nil)
+
+	runControlTest(t, &controlCount{Count: 5})
+	if _, err := DecodeControl((&ControlRaw{OID: "1.2.3.4"}).Encode()); err == nil {
+		t.Error("expected an error from the registered decoder")
+	}
+
+	RegisterControlDecoder("1.2.3.4", nil)
+	decoded, err := DecodeControl((&controlCount{Count: 5}).Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded.(*ControlRaw); !ok {
+		t.Errorf("expected a ControlRaw after removing the decoder, got %T", decoded)
+	}
+}
@@ -0,0 +1,109 @@
+package ldap
+
+import (
+	"strings"
+	"sync/atomic"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// UnsupportedControlsError is the underlying error of the *Error returned for
+// requests with critical controls which the server does not list in the
+// supportedControl attribute of its root DSE, see SetControlValidation. The
+// *Error has the result code LDAPResultUnavailableCriticalExtension, which
+// the server would have returned, e.g.
+//
+//	var unsupported *ldap.UnsupportedControlsError
+//	if errors.As(err, &unsupported) {
+//		...
+//	}
+type UnsupportedControlsError struct {
+	// OIDs are the types of the unsupported critical controls
+	OIDs []string
+}
+
+func (e *UnsupportedControlsError) Error() string {
+	names := make([]string, len(e.OIDs))
+	for i, oid := range e.OIDs {
+		names[i] = oid
+		if name := ControlTypeMap[oid]; name != "" {
+			names[i] += " (" + name + ")"
+		}
+	}
+	return "ldap: critical controls not supported by the server: " + strings.Join(names, ", ")
+}
+
+// SetControlValidation enables or disables the validation of critical
+// controls on this connection. If enabled, requests with critical controls
+// which the server does not list in the supportedControl attribute of its
+// root DSE fail with an *UnsupportedControlsError without being sent. The
+// root DSE is read once, see RootDSE, on the first request with critical
+// controls. If it cannot be read or lists no controls at all, e.g. because
+// access is restricted, the requests are sent unchecked.
+func (l *Conn) SetControlValidation(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&l.validateControls, v)
+}
+
+// DialWithControlValidation enables the validation of critical controls on
+// the new connection, see Conn.SetControlValidation.
+func DialWithControlValidation() DialOpt {
+	return func(dc *DialContext) {
+		dc.validateControls = true
+	}
+}
+
+// checkCriticalControls returns an error if control validation is enabled
+// and the server does not support a critical control of the encoded request
+func (l *Conn) checkCriticalControls(packet *ber.Packet) error {
+	if atomic.LoadUint32(&l.validateControls) == 0 {
+		return nil
+	}
+	critical := criticalControls(packet)
+	if len(critical) == 0 {
+		return nil
+	}
+	rootDSE, err := l.RootDSE()
+	if err != nil || len(rootDSE.SupportedControl) == 0 {
+		return nil
+	}
+	var unsupported []string
+	for _, oid := range critical {
+		if !rootDSE.SupportsControl(oid) {
+			unsupported = append(unsupported, oid)
+		}
+	}
+	if len(unsupported) == 0 {
+		return nil
+	}
+	l.log(LogLevelWarn, "request with unsupported critical controls refused", "controls", unsupported)
+	return NewError(LDAPResultUnavailableCriticalExtension, &UnsupportedControlsError{OIDs: unsupported})
+}
+
+// criticalControls returns the types of the critical controls of an encoded
+// LDAPMessage
+func criticalControls(packet *ber.Packet) []string {
+	if len(packet.Children) < 3 {
+		return nil
+	}
+	controls := packet.Children[2]
+	if controls.ClassType != ber.ClassContext || controls.Tag != 0 {
+		return nil
+	}
+	var critical []string
+	for _, control := range controls.Children {
+		if len(control.Children) < 2 {
+			continue
+		}
+		if isCritical, _ := control.Children[1].Value.(bool); !isCritical {
+			continue
+		}
+		if oid, ok := control.Children[0].Value.(string); ok {
+			critical = append(critical, oid)
+		}
+	}
+	return critical
+}
@@ -0,0 +1,49 @@
+package ldap
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestControlValidation(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	conn.SetControlValidation(true)
+
+	// the root DSE is read for the first request with critical controls
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", map[string][]string{
+		"supportedControl": {ControlTypePaging, ControlTypeManageDsaIT},
+	}))
+	del := NewDelRequest("cn=a,dc=example,dc=com", []Control{NewControlString(ControlTypeSubtreeDelete, true, ""), NewControlManageDsaIT(true)})
+	err := conn.Del(del)
+	var unsupported *UnsupportedControlsError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected an UnsupportedControlsError, got %v", err)
+	}
+	if !reflect.DeepEqual(unsupported.OIDs, []string{ControlTypeSubtreeDelete}) {
+		t.Errorf("unexpected unsupported controls %v", unsupported.OIDs)
+	}
+	if !IsErrorWithCode(err, LDAPResultUnavailableCriticalExtension) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if unsupported.Error() != "ldap: critical controls not supported by the server: 1.2.840.113556.1.4.805 (Subtree Delete Control)" {
+		t.Errorf("unexpected message %q", unsupported.Error())
+	}
+
+	// supported and non-critical controls are sent
+	go respondWithResult(ptc, ApplicationDelResponse, LDAPResultSuccess)
+	if err := conn.Del(NewDelRequest("cn=a,dc=example,dc=com", []Control{NewControlManageDsaIT(true), NewControlString(ControlTypeSubtreeDelete, false, "")})); err != nil {
+		t.Error(err)
+	}
+
+	conn.SetControlValidation(false)
+	go respondWithResult(ptc, ApplicationDelResponse, LDAPResultUnavailableCriticalExtension)
+	if err := conn.Del(del); errors.As(err, &unsupported) || !IsErrorWithCode(err, LDAPResultUnavailableCriticalExtension) {
+		t.Errorf("unexpected error without validation %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+)
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying the given correlation ID.
+// Operations performed with the returned context, e.g. through ModifyContext,
+// include the ID in structured log events, spans, slow query reports and
+// returned errors.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, or an empty string
+// if there is none
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// correlateError attaches the correlation ID carried by ctx to *err. It is
+// meant to be deferred by operations taking a context. An *Error is copied,
+// as it may be shared by other callers.
+func correlateError(ctx context.Context, err *error) {
+	if *err == nil {
+		return
+	}
+	id := CorrelationID(ctx)
+	if id == "" {
+		return
+	}
+	if e, ok := (*err).(*Error); ok {
+		if e.CorrelationID == "" {
+			correlated := *e
+			correlated.CorrelationID = id
+			*err = &correlated
+		}
+		return
+	}
+	*err = fmt.Errorf("%w (correlation ID %q)", *err, id)
+}
+
+// correlationFields appends the correlation ID of the message, if any, to the
+// given structured log fields
+func (msgCtx *messageContext) correlationFields(keysAndValues ...interface{}) []interface{} {
+	if msgCtx != nil && msgCtx.correlationID != "" {
+		keysAndValues = append(keysAndValues, "correlation_id", msgCtx.correlationID)
+	}
+	return keysAndValues
+}
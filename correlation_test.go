@@ -0,0 +1,74 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestCorrelationID(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	conn.SetLogger(NewStdStructuredLogger(log.New(&buf, "", 0), LogLevelDebug))
+
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+		modifyResponse := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationModifyResponse, nil, "Modify Response")
+		modifyResponse.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultNoSuchObject), "resultCode"))
+		modifyResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		modifyResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		response.AppendChild(modifyResponse)
+		_ = ptc.SendResponse(response)
+	}()
+
+	ctx := WithCorrelationID(context.Background(), "req-42")
+	modifyRequest := NewModifyRequest("cn=missing,dc=example,dc=com", nil)
+	modifyRequest.Replace("description", []string{"test"})
+	err := conn.ModifyContext(ctx, modifyRequest)
+	if !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+		t.Fatalf("expected no such object error, got %v", err)
+	}
+	if id := err.(*Error).CorrelationID; id != "req-42" {
+		t.Errorf("got correlation ID %q on error, expected %q", id, "req-42")
+	}
+	if !strings.Contains(err.Error(), `"req-42"`) {
+		t.Errorf("expected correlation ID in error message: %s", err)
+	}
+
+	out := buf.String()
+	for _, event := range []string{"request sent", "response received"} {
+		if !strings.Contains(out, event) {
+			t.Errorf("missing %q event in log:\n%s", event, out)
+		}
+	}
+	if strings.Count(out, "correlation_id=req-42") != 2 {
+		t.Errorf("expected correlation ID on sent and received events:\n%s", out)
+	}
+
+	// shared errors are left unchanged
+	shared := NewError(ErrorNetwork, errors.New("connection closed"))
+	err = shared
+	correlateError(ctx, &err)
+	if id := err.(*Error).CorrelationID; id != "req-42" {
+		t.Errorf("got correlation ID %q on error, expected %q", id, "req-42")
+	}
+	if id := shared.(*Error).CorrelationID; id != "" {
+		t.Errorf("shared error was changed to correlation ID %q", id)
+	}
+}
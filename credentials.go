@@ -0,0 +1,139 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bind mechanisms supported by BindWithCredentials
+const (
+	MechanismSimple    = "SIMPLE"
+	MechanismExternal  = "EXTERNAL"
+	MechanismDigestMD5 = "DIGEST-MD5"
+)
+
+// Credentials are the credentials returned by a CredentialProvider
+type Credentials struct {
+	// Username is the DN or user name to bind as
+	Username string
+	// Secret is the password of the mechanism. It is not modified, so a
+	// provider may hand out the same slice repeatedly.
+	Secret []byte
+	// Mechanism is the bind mechanism, MechanismSimple if empty
+	Mechanism string
+	// Host is the host name of the server for MechanismDigestMD5
+	Host string
+	// Expiry is the time the credentials expire, zero if they do not
+	Expiry time.Time
+}
+
+// expired reports whether the credentials have expired at t
+func (c *Credentials) expired(t time.Time) bool {
+	return !c.Expiry.IsZero() && !t.Before(c.Expiry)
+}
+
+// CredentialProvider supplies the credentials to bind with. It is consulted
+// on every bind, e.g. by BindWithCredentials, DialWithCredentials and the
+// connections opened to follow referrals, so secrets kept in a vault or a
+// file can be rotated without restarting.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (*Credentials, error)
+}
+
+// CredentialProviderFunc is a function implementing CredentialProvider
+type CredentialProviderFunc func(ctx context.Context) (*Credentials, error)
+
+// Credentials calls f
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (*Credentials, error) {
+	return f(ctx)
+}
+
+// StaticCredentials returns a CredentialProvider always returning a simple
+// bind with the given DN and password
+func StaticCredentials(dn string, password []byte) CredentialProvider {
+	credentials := &Credentials{Username: dn, Secret: password, Mechanism: MechanismSimple}
+	return CredentialProviderFunc(func(context.Context) (*Credentials, error) {
+		return credentials, nil
+	})
+}
+
+// cachedCredentials caches the credentials of a provider until they are
+// about to expire
+type cachedCredentials struct {
+	provider CredentialProvider
+	margin   time.Duration
+
+	mu          sync.Mutex
+	credentials *Credentials
+}
+
+// NewCachedCredentialProvider returns a CredentialProvider caching the
+// credentials of provider until margin before their Expiry, so that a
+// vault is not asked on every bind. Credentials without Expiry are not
+// cached.
+func NewCachedCredentialProvider(provider CredentialProvider, margin time.Duration) CredentialProvider {
+	return &cachedCredentials{provider: provider, margin: margin}
+}
+
+func (c *cachedCredentials) Credentials(ctx context.Context) (*Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.credentials != nil && !c.credentials.expired(time.Now().Add(c.margin)) {
+		return c.credentials, nil
+	}
+	c.credentials = nil
+	credentials, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !credentials.Expiry.IsZero() {
+		c.credentials = credentials
+	}
+	return credentials, nil
+}
+
+// DialWithCredentials binds the new connection with the credentials of
+// provider, see BindWithCredentials
+func DialWithCredentials(provider CredentialProvider) DialOpt {
+	return func(dc *DialContext) {
+		dc.credentials = provider
+	}
+}
+
+// BindWithCredentials binds with the credentials returned by provider.
+// Expired credentials are not used.
+func (l *Conn) BindWithCredentials(ctx context.Context, provider CredentialProvider) error {
+	credentials, err := provider.Credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("ldap: getting credentials: %w", err)
+	}
+	if credentials == nil {
+		return errors.New("ldap: credential provider returned no credentials")
+	}
+	if credentials.expired(time.Now()) {
+		return fmt.Errorf("ldap: credentials of %q expired at %s", credentials.Username, credentials.Expiry.Format(time.RFC3339))
+	}
+
+	switch strings.ToUpper(credentials.Mechanism) {
+	case "", MechanismSimple:
+		_, err = l.SimpleBindContext(ctx, &SimpleBindRequest{
+			Username:      credentials.Username,
+			PasswordBytes: credentials.Secret,
+		})
+	case MechanismExternal:
+		err = l.ExternalBind()
+	case MechanismDigestMD5:
+		_, err = l.DigestMD5Bind(&DigestMD5BindRequest{
+			Host:     credentials.Host,
+			Username: credentials.Username,
+			Password: string(credentials.Secret),
+		})
+	default:
+		return fmt.Errorf("ldap: unsupported bind mechanism %q", credentials.Mechanism)
+	}
+	return err
+}
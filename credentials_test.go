@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindWithCredentials(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	// the provider is asked on every bind, so rotated passwords are used
+	passwords := []string{"first", "second"}
+	calls := 0
+	provider := CredentialProviderFunc(func(context.Context) (*Credentials, error) {
+		password := passwords[calls]
+		calls++
+		return &Credentials{Username: "cn=service,dc=example,dc=com", Secret: []byte(password)}, nil
+	})
+	for _, password := range passwords {
+		requests := make(chan *Credentials, 1)
+		go func() {
+			req, err := respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+			if err != nil {
+				return
+			}
+			bind := req.Children[1]
+			requests <- &Credentials{Username: bind.Children[1].Value.(string), Secret: bind.Children[2].Data.Bytes()}
+		}()
+		assert.NoError(t, conn.BindWithCredentials(context.Background(), provider))
+		sent := <-requests
+		assert.Equal(t, "cn=service,dc=example,dc=com", sent.Username)
+		assert.Equal(t, password, string(sent.Secret))
+	}
+
+	expired := &Credentials{Username: "cn=service", Secret: []byte("x"), Expiry: time.Now().Add(-time.Minute)}
+	err := conn.BindWithCredentials(context.Background(), CredentialProviderFunc(func(context.Context) (*Credentials, error) {
+		return expired, nil
+	}))
+	assert.Error(t, err)
+
+	unsupported := &Credentials{Username: "cn=service", Secret: []byte("x"), Mechanism: "GSSAPI"}
+	err = conn.BindWithCredentials(context.Background(), CredentialProviderFunc(func(context.Context) (*Credentials, error) {
+		return unsupported, nil
+	}))
+	assert.Error(t, err)
+
+	vaultErr := errors.New("vault sealed")
+	err = conn.BindWithCredentials(context.Background(), CredentialProviderFunc(func(context.Context) (*Credentials, error) {
+		return nil, vaultErr
+	}))
+	assert.True(t, errors.Is(err, vaultErr))
+}
+
+func TestCachedCredentialProvider(t *testing.T) {
+	calls := 0
+	expiry := time.Now().Add(time.Hour)
+	provider := NewCachedCredentialProvider(CredentialProviderFunc(func(context.Context) (*Credentials, error) {
+		calls++
+		return &Credentials{Username: "cn=service", Secret: []byte("secret"), Expiry: expiry}, nil
+	}), time.Minute)
+
+	for i := 0; i < 3; i++ {
+		credentials, err := provider.Credentials(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "cn=service", credentials.Username)
+	}
+	assert.Equal(t, 1, calls)
+
+	// credentials expiring within the margin are refreshed
+	provider.(*cachedCredentials).credentials.Expiry = time.Now().Add(30 * time.Second)
+	_, err := provider.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// credentials without expiry are not cached
+	expiry = time.Time{}
+	provider.(*cachedCredentials).credentials = nil
+	_, _ = provider.Credentials(context.Background())
+	_, _ = provider.Credentials(context.Background())
+	assert.Equal(t, 4, calls)
+}
@@ -0,0 +1,318 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DCFlags are the flags of a domain controller returned by a CLDAP ping, see
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/f55d3f53-351d-4407-940e-f3f6bea10fd0
+type DCFlags uint32
+
+// domain controller flags
+const (
+	DCFlagPDC              DCFlags = 0x00000001
+	DCFlagGC               DCFlags = 0x00000004
+	DCFlagLDAP             DCFlags = 0x00000008
+	DCFlagDS               DCFlags = 0x00000010
+	DCFlagKDC              DCFlags = 0x00000020
+	DCFlagTimeServ         DCFlags = 0x00000040
+	DCFlagClosest          DCFlags = 0x00000080
+	DCFlagWritable         DCFlags = 0x00000100
+	DCFlagGoodTimeServ     DCFlags = 0x00000200
+	DCFlagNDNC             DCFlags = 0x00000400
+	DCFlagSelectSecretDom6 DCFlags = 0x00000800
+	DCFlagFullSecretDom6   DCFlags = 0x00001000
+	DCFlagWS               DCFlags = 0x00002000
+	DCFlagDS8              DCFlags = 0x00004000
+	DCFlagDS9              DCFlags = 0x00008000
+	DCFlagDS10             DCFlags = 0x00010000
+)
+
+// Has returns true if all the given flags are set
+func (f DCFlags) Has(flags DCFlags) bool {
+	return f&flags == flags
+}
+
+// NetlogonResponse is the NETLOGON_SAM_LOGON_RESPONSE_EX structure returned
+// by domain controllers for CLDAP pings, see
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/8401a33f-34a8-40ca-bf03-c3484b66265f
+type NetlogonResponse struct {
+	Opcode              uint16
+	Flags               DCFlags
+	DomainGUID          GUID
+	ForestName          string
+	DomainName          string
+	HostName            string
+	NetbiosDomainName   string
+	NetbiosComputerName string
+	UserName            string
+	DCSiteName          string
+	ClientSiteName      string
+}
+
+// netlogonNtVersion requests NETLOGON_SAM_LOGON_RESPONSE_EX responses
+// (NETLOGON_NT_VERSION_5 | NETLOGON_NT_VERSION_5EX)
+const netlogonNtVersion = 0x00000006
+
+// ParseNetlogonResponse parses the value of the Netlogon attribute returned
+// for a CLDAP ping
+func ParseNetlogonResponse(b []byte) (*NetlogonResponse, error) {
+	if len(b) < 24 {
+		return nil, errors.New("ldap: netlogon response too short")
+	}
+	r := &NetlogonResponse{
+		Opcode: binary.LittleEndian.Uint16(b),
+		Flags:  DCFlags(binary.LittleEndian.Uint32(b[4:])),
+	}
+	copy(r.DomainGUID[:], b[8:24])
+	offset := 24
+	for _, name := range []*string{
+		&r.ForestName, &r.DomainName, &r.HostName, &r.NetbiosDomainName,
+		&r.NetbiosComputerName, &r.UserName, &r.DCSiteName, &r.ClientSiteName,
+	} {
+		var err error
+		if *name, offset, err = readNetlogonName(b, offset); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// readNetlogonName reads a name compressed as described in RFC 1035
+// section 4.1.4 at offset and returns it with the offset following it
+func readNetlogonName(b []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if offset >= len(b) {
+			return "", 0, errors.New("ldap: truncated name in netlogon response")
+		}
+		length := int(b[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(b) || jumps > 32 {
+				return "", 0, errors.New("ldap: invalid name pointer in netlogon response")
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(b[offset:]) & 0x3fff)
+			jumps++
+		default:
+			if offset+1+length > len(b) {
+				return "", 0, errors.New("ldap: truncated name in netlogon response")
+			}
+			labels = append(labels, string(b[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// PingDC sends a CLDAP ping for the domain to the domain controller at addr
+// ("host:port", usually UDP port 389) and returns its response
+func PingDC(addr, domain string, timeout time.Duration) (*NetlogonResponse, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+
+	var ntVersion [4]byte
+	binary.LittleEndian.PutUint32(ntVersion[:], netlogonNtVersion)
+	req := NewSearchRequest(
+		"", ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(DnsDomain=%s)(NtVer=%s))", EscapeFilter(domain), escapeFilterBytes(ntVersion[:])),
+		[]string{"Netlogon"}, nil,
+	)
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	if err := req.appendTo(packet); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+
+	buf := make([]byte, 65536)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	// the entry and the search result done message may share a datagram
+	response, err := ber.ReadPacket(bytes.NewReader(buf[:n]))
+	if err != nil {
+		return nil, NewError(ErrorNetwork, err)
+	}
+	if len(response.Children) < 2 || response.Children[1].Tag != ApplicationSearchResultEntry {
+		if err := GetLDAPError(response); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ldap: no netlogon response from %s", addr)
+	}
+	entry, err := decodeEntry(response, valueDecoding{})
+	if err != nil {
+		return nil, err
+	}
+	netlogon := entry.GetEqualFoldRawAttributeValue("Netlogon")
+	if netlogon == nil {
+		return nil, fmt.Errorf("ldap: no netlogon response from %s", addr)
+	}
+	return ParseNetlogonResponse(netlogon)
+}
+
+func escapeFilterBytes(b []byte) string {
+	var s strings.Builder
+	for _, v := range b {
+		fmt.Fprintf(&s, "\\%02x", v)
+	}
+	return s.String()
+}
+
+// DCRequirements restrict the domain controllers returned by DiscoverDC
+type DCRequirements struct {
+	// Writable excludes read-only domain controllers
+	Writable bool
+	// GlobalCatalog only returns global catalog servers, with the global
+	// catalog port in their URL
+	GlobalCatalog bool
+	// PDC only returns the primary domain controller
+	PDC bool
+	// Timeout of the CLDAP pings, defaults to DefaultTimeout
+	Timeout time.Duration
+}
+
+// DCCandidate is a domain controller found by DiscoverDC
+type DCCandidate struct {
+	Host     string
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+	// Netlogon is the response of the domain controller to the CLDAP ping
+	Netlogon *NetlogonResponse
+	// Closest is true if the domain controller is in the site of the client
+	Closest bool
+}
+
+// URL returns the ldap:// URL of the domain controller
+func (c *DCCandidate) URL() string {
+	return "ldap://" + net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port)))
+}
+
+// globalCatalogPort and globalCatalogTLSPort are the ports of the global
+// catalog service without and with TLS
+const (
+	globalCatalogPort    = 3268
+	globalCatalogTLSPort = 3269
+)
+
+// dcDiscoverer holds the network functions used by DiscoverDC
+type dcDiscoverer struct {
+	lookupSRV func(service, proto, name string) (string, []*net.SRV, error)
+	ping      func(addr, domain string, timeout time.Duration) (*NetlogonResponse, error)
+}
+
+// DiscoverDC finds the domain controllers of the domain through the DNS SRV
+// records Active Directory registers, preferring those of the given site
+// (_ldap._tcp.<site>._sites.dc._msdcs.<domain>) if site is not empty.
+// Every domain controller is pinged with CLDAP; unreachable ones and those
+// not meeting the requirements are left out. The candidates are ranked with
+// domain controllers in the closest site first, in the order of their SRV
+// records otherwise, and can be dialed with DialDC.
+func DiscoverDC(domain, site string, requirements DCRequirements) ([]*DCCandidate, error) {
+	d := &dcDiscoverer{lookupSRV: net.LookupSRV, ping: PingDC}
+	return d.discover(domain, site, requirements)
+}
+
+func (d *dcDiscoverer) discover(domain, site string, requirements DCRequirements) ([]*DCCandidate, error) {
+	timeout := requirements.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	var records []*net.SRV
+	var err error
+	if site != "" {
+		_, records, err = d.lookupSRV("", "", "_ldap._tcp."+site+"._sites.dc._msdcs."+domain)
+	}
+	if len(records) == 0 {
+		if _, records, err = d.lookupSRV("", "", "_ldap._tcp.dc._msdcs."+domain); err != nil {
+			return nil, NewError(ErrorNetwork, err)
+		}
+	}
+
+	candidates := make([]*DCCandidate, len(records))
+	var wg sync.WaitGroup
+	for i, record := range records {
+		candidates[i] = &DCCandidate{
+			Host:     strings.TrimSuffix(record.Target, "."),
+			Port:     record.Port,
+			Priority: record.Priority,
+			Weight:   record.Weight,
+		}
+		wg.Add(1)
+		go func(c *DCCandidate) {
+			defer wg.Done()
+			// CLDAP uses UDP on the LDAP port
+			c.Netlogon, _ = d.ping(net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port))), domain, timeout)
+		}(candidates[i])
+	}
+	wg.Wait()
+
+	var result []*DCCandidate
+	for _, c := range candidates {
+		if c.Netlogon == nil || !requirements.satisfiedBy(c.Netlogon.Flags) {
+			continue
+		}
+		c.Closest = c.Netlogon.Flags.Has(DCFlagClosest) ||
+			(c.Netlogon.ClientSiteName != "" && strings.EqualFold(c.Netlogon.DCSiteName, c.Netlogon.ClientSiteName))
+		if requirements.GlobalCatalog {
+			c.Port = globalCatalogPort
+		}
+		result = append(result, c)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("ldap: no reachable domain controller found for %s", domain)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Closest && !result[j].Closest
+	})
+	return result, nil
+}
+
+func (r DCRequirements) satisfiedBy(flags DCFlags) bool {
+	return (!r.Writable || flags.Has(DCFlagWritable)) &&
+		(!r.GlobalCatalog || flags.Has(DCFlagGC)) &&
+		(!r.PDC || flags.Has(DCFlagPDC))
+}
+
+// DialDC connects to the first of the candidates which accepts the
+// connection, trying them in order
+func DialDC(candidates []*DCCandidate, opts ...DialOpt) (*Conn, error) {
+	err := errors.New("ldap: no domain controller candidates")
+	for _, c := range candidates {
+		var conn *Conn
+		if conn, err = DialURL(c.URL(), opts...); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,161 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// buildNetlogonResponse encodes a NETLOGON_SAM_LOGON_RESPONSE_EX, compressing
+// the domain name of the host and of the forest
+func buildNetlogonResponse(flags DCFlags, dcSite, clientSite string) []byte {
+	b := make([]byte, 24)
+	binary.LittleEndian.PutUint16(b, 23)
+	binary.LittleEndian.PutUint32(b[4:], uint32(flags))
+	label := func(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+	// forest name at offset 24: example.com
+	b = append(b, label("example")...)
+	b = append(b, label("com")...)
+	b = append(b, 0)
+	// domain name: pointer to the forest name
+	b = append(b, 0xc0, 24)
+	// host name: dc1 followed by a pointer to the forest name
+	b = append(b, label("dc1")...)
+	b = append(b, 0xc0, 24)
+	for _, name := range []string{"EXAMPLE", "DC1", "", dcSite, clientSite} {
+		if name != "" {
+			b = append(b, label(name)...)
+		}
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestParseNetlogonResponse(t *testing.T) {
+	r, err := ParseNetlogonResponse(buildNetlogonResponse(DCFlagWritable|DCFlagGC, "Site-A", "Site-B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NetlogonResponse{
+		Opcode: 23, Flags: DCFlagWritable | DCFlagGC,
+		ForestName: "example.com", DomainName: "example.com", HostName: "dc1.example.com",
+		NetbiosDomainName: "EXAMPLE", NetbiosComputerName: "DC1",
+		DCSiteName: "Site-A", ClientSiteName: "Site-B",
+	}
+	if *r != expected {
+		t.Errorf("got %+v, expected %+v", r, expected)
+	}
+
+	looping := append(make([]byte, 24), 0xc0, 24)
+	if _, err := ParseNetlogonResponse(looping); err == nil {
+		t.Error("expected error for pointer loop")
+	}
+	if _, err := ParseNetlogonResponse(buildNetlogonResponse(0, "a", "b")[:30]); err == nil {
+		t.Error("expected error for truncated response")
+	}
+}
+
+func TestPingDC(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer server.Close()
+
+	ntVersions := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 65536)
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := ber.DecodePacketErr(buf[:n])
+		if err != nil {
+			return
+		}
+		// the value of the NtVer assertion of the filter
+		ntVersions <- req.Children[1].Children[6].Children[1].Children[1].Data.Bytes()
+
+		entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+		searchEntry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+		searchEntry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Object Name"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		attribute := Attribute{Type: "Netlogon", Vals: []string{string(buildNetlogonResponse(DCFlagClosest, "Site-A", "Site-A"))}}
+		attributes.AppendChild(attribute.encode())
+		searchEntry.AppendChild(attributes)
+		entry.AppendChild(searchEntry)
+		_, _ = server.WriteTo(entry.Bytes(), addr)
+	}()
+
+	r, err := PingDC(server.LocalAddr().String(), "example.com", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.HostName != "dc1.example.com" || !r.Flags.Has(DCFlagClosest) {
+		t.Errorf("unexpected response %+v", r)
+	}
+	if ntVersion := <-ntVersions; !bytes.Equal(ntVersion, []byte{6, 0, 0, 0}) {
+		t.Errorf("unexpected NtVer %x", ntVersion)
+	}
+}
+
+func TestDiscoverDC(t *testing.T) {
+	responses := map[string]*NetlogonResponse{
+		"dc1.example.com:389":  {Flags: DCFlagWritable, DCSiteName: "Remote", ClientSiteName: "Local"},
+		"dc2.example.com:389":  {Flags: DCFlagWritable | DCFlagGC, DCSiteName: "Local", ClientSiteName: "Local"},
+		"rodc.example.com:389": {Flags: DCFlagGC, DCSiteName: "Local", ClientSiteName: "Local"},
+	}
+	var lookups []string
+	d := &dcDiscoverer{
+		lookupSRV: func(service, proto, name string) (string, []*net.SRV, error) {
+			lookups = append(lookups, name)
+			if name != "_ldap._tcp.dc._msdcs.example.com" {
+				return "", nil, errors.New("no such host")
+			}
+			return "", []*net.SRV{
+				{Target: "dc1.example.com.", Port: 389},
+				{Target: "dc2.example.com.", Port: 389},
+				{Target: "rodc.example.com.", Port: 389},
+				{Target: "down.example.com.", Port: 389},
+			}, nil
+		},
+		ping: func(addr, domain string, timeout time.Duration) (*NetlogonResponse, error) {
+			if r, ok := responses[addr]; ok {
+				return r, nil
+			}
+			return nil, errors.New("timeout")
+		},
+	}
+
+	candidates, err := d.discover("example.com", "Local", DCRequirements{Writable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lookups) != 2 || lookups[0] != "_ldap._tcp.Local._sites.dc._msdcs.example.com" {
+		t.Errorf("unexpected lookups %v", lookups)
+	}
+	if len(candidates) != 2 || candidates[0].Host != "dc2.example.com" || !candidates[0].Closest || candidates[1].Host != "dc1.example.com" {
+		t.Errorf("unexpected candidates %+v", candidates)
+	}
+	if url := candidates[0].URL(); url != "ldap://dc2.example.com:389" {
+		t.Errorf("got URL %s", url)
+	}
+
+	candidates, err = d.discover("example.com", "", DCRequirements{GlobalCatalog: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || candidates[0].URL() != "ldap://dc2.example.com:3268" {
+		t.Errorf("unexpected candidates %+v", candidates)
+	}
+
+	if _, err := d.discover("example.com", "", DCRequirements{PDC: true}); err == nil {
+		t.Error("expected error without matching domain controller")
+	}
+}
@@ -6,6 +6,9 @@ import (
 
 // debugging type
 //     - has a Printf method to write the debug output
+//
+// Conn.SetLogger provides leveled, structured events instead and is
+// preferred for new code.
 type debugging bool
 
 // Enable controls debugging mode.
@@ -20,9 +23,10 @@ func (debug debugging) Printf(format string, args ...interface{}) {
 	}
 }
 
-// PrintPacket dumps a packet.
+// PrintPacket dumps a packet. Credentials and password attribute values are
+// masked, see DebugRedactedAttributes.
 func (debug debugging) PrintPacket(packet *ber.Packet) {
 	if debug {
-		ber.WritePacket(logger.Writer(), packet)
+		ber.WritePacket(logger.Writer(), redactPacket(packet))
 	}
 }
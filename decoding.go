@@ -0,0 +1,218 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DecodingMode selects how strictly the responses of a server are validated
+type DecodingMode uint32
+
+// Decoding modes
+const (
+	// DecodingLenient accepts every response which can be decoded, including
+	// encodings which are not valid for LDAP but produced by some servers,
+	// e.g. the non-minimal four byte lengths of Active Directory. This is
+	// the default.
+	DecodingLenient DecodingMode = iota
+	// DecodingStrict rejects responses which violate the encoding rules of
+	// RFC 4511: indefinite or non-minimal lengths, non-minimal integers,
+	// booleans other than 0x00 and 0xFF, wrong tags in the LDAPMessage
+	// envelope and elements after the controls.
+	DecodingStrict
+)
+
+// DecodingModeMap contains human readable descriptions of decoding modes
+var DecodingModeMap = map[DecodingMode]string{
+	DecodingLenient: "lenient",
+	DecodingStrict:  "strict",
+}
+
+// SetDecodingMode sets how strictly the responses received on the connection
+// are validated. In strict mode, an operation receiving a response which
+// violates the encoding rules fails with ErrorUnexpectedResponse; the errors
+// of both modes name the mode in use.
+func (l *Conn) SetDecodingMode(mode DecodingMode) {
+	atomic.StoreUint32(&l.decodingMode, uint32(mode))
+}
+
+func (l *Conn) getDecodingMode() DecodingMode {
+	return DecodingMode(atomic.LoadUint32(&l.decodingMode))
+}
+
+// DialWithDecodingMode sets the decoding mode of the new connection, see
+// Conn.SetDecodingMode.
+func DialWithDecodingMode(mode DecodingMode) DialOpt {
+	return func(dc *DialContext) {
+		dc.decodingMode = mode
+	}
+}
+
+// responseTags are the application tags of the protocol operations a server
+// sends
+var responseTags = map[ber.Tag]bool{
+	ApplicationBindResponse:          true,
+	ApplicationSearchResultEntry:     true,
+	ApplicationSearchResultDone:      true,
+	ApplicationModifyResponse:        true,
+	ApplicationAddResponse:           true,
+	ApplicationDelResponse:           true,
+	ApplicationModifyDNResponse:      true,
+	ApplicationCompareResponse:       true,
+	ApplicationSearchResultReference: true,
+	ApplicationExtendedResponse:      true,
+	ApplicationIntermediateResponse:  true,
+}
+
+// berElement is an element of a BER encoding
+type berElement struct {
+	class       ber.Class
+	constructed bool
+	tag         ber.Tag
+	content     []byte
+}
+
+// checkStrictMessage validates the encoding of an LDAPMessage received from
+// a server according to the rules of DecodingStrict
+func checkStrictMessage(data []byte) error {
+	message, rest, err := readStrictElement(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%d trailing bytes after the message", len(rest))
+	}
+	if message.class != ber.ClassUniversal || !message.constructed || message.tag != ber.TagSequence {
+		return errors.New("message is not a sequence")
+	}
+	elements, err := readStrictElements(message.content)
+	if err != nil {
+		return err
+	}
+	if len(elements) < 2 {
+		return errors.New("missing message ID or protocol operation")
+	}
+	messageID := elements[0]
+	if messageID.class != ber.ClassUniversal || messageID.constructed || messageID.tag != ber.TagInteger {
+		return errors.New("message ID is not an integer")
+	}
+	if id, err := ber.ParseInt64(messageID.content); err != nil || id < 0 || id > math.MaxInt32 {
+		return errors.New("message ID out of range")
+	}
+	op := elements[1]
+	if op.class != ber.ClassApplication || !responseTags[op.tag] {
+		return fmt.Errorf("invalid protocol operation tag %d", op.tag)
+	}
+	if len(elements) > 2 {
+		controls := elements[2]
+		if controls.class != ber.ClassContext || !controls.constructed || controls.tag != 0 {
+			return fmt.Errorf("unexpected element with tag %d after the protocol operation", controls.tag)
+		}
+	}
+	if len(elements) > 3 {
+		return errors.New("trailing elements after the controls")
+	}
+	return nil
+}
+
+// readStrictElements reads the elements of a constructed encoding and,
+// recursively, their children
+func readStrictElements(data []byte) ([]berElement, error) {
+	var elements []berElement
+	for len(data) > 0 {
+		element, rest, err := readStrictElement(data)
+		if err != nil {
+			return nil, err
+		}
+		if element.constructed {
+			if _, err := readStrictElements(element.content); err != nil {
+				return nil, err
+			}
+		}
+		elements = append(elements, element)
+		data = rest
+	}
+	return elements, nil
+}
+
+// readStrictElement reads the element at the start of data and returns the
+// remaining bytes
+func readStrictElement(data []byte) (berElement, []byte, error) {
+	if len(data) < 2 {
+		return berElement{}, nil, errors.New("truncated element")
+	}
+	element := berElement{
+		class:       ber.Class(data[0]) & ber.ClassBitmask,
+		constructed: ber.Type(data[0])&ber.TypeBitmask == ber.TypeConstructed,
+		tag:         ber.Tag(data[0]) & ber.TagBitmask,
+	}
+	offset := 1
+	if element.tag == ber.HighTag {
+		// high tag numbers are encoded in base 128 without leading zeros
+		if data[offset] == 0x80 {
+			return berElement{}, nil, errors.New("non-minimal tag")
+		}
+		element.tag = 0
+		for ; offset < len(data) && data[offset]&0x80 != 0; offset++ {
+			element.tag = element.tag<<7 | ber.Tag(data[offset]&0x7f)
+		}
+		if offset >= len(data) {
+			return berElement{}, nil, errors.New("truncated tag")
+		}
+		element.tag = element.tag<<7 | ber.Tag(data[offset])
+		offset++
+		if offset >= len(data) {
+			return berElement{}, nil, errors.New("truncated element")
+		}
+	}
+
+	length := int(data[offset])
+	offset++
+	switch {
+	case length == 0x80:
+		return berElement{}, nil, errors.New("indefinite length")
+	case length > 0x80:
+		size := length & 0x7f
+		if size > 4 || offset+size > len(data) {
+			return berElement{}, nil, errors.New("invalid length")
+		}
+		if data[offset] == 0 {
+			return berElement{}, nil, errors.New("non-minimal length")
+		}
+		var n int64
+		for _, b := range data[offset : offset+size] {
+			n = n<<8 | int64(b)
+		}
+		offset += size
+		if n < 0x80 {
+			return berElement{}, nil, errors.New("non-minimal length")
+		}
+		if n > int64(len(data)-offset) {
+			return berElement{}, nil, errors.New("truncated element")
+		}
+		length = int(n)
+	}
+	if length > len(data)-offset {
+		return berElement{}, nil, errors.New("truncated element")
+	}
+	element.content = data[offset : offset+length]
+
+	if element.class == ber.ClassUniversal && !element.constructed {
+		switch element.tag {
+		case ber.TagBoolean:
+			if len(element.content) != 1 || (element.content[0] != 0x00 && element.content[0] != 0xff) {
+				return berElement{}, nil, errors.New("invalid boolean")
+			}
+		case ber.TagInteger, ber.TagEnumerated:
+			c := element.content
+			if len(c) == 0 || (len(c) > 1 && (c[0] == 0x00 && c[1] < 0x80 || c[0] == 0xff && c[1] >= 0x80)) {
+				return berElement{}, nil, errors.New("non-minimal integer")
+			}
+		}
+	}
+	return element, data[offset+length:], nil
+}
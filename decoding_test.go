@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestCheckStrictMessage(t *testing.T) {
+	valid := []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x6f, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00}
+	if err := checkStrictMessage(valid); err != nil {
+		t.Errorf("unexpected error for valid message: %s", err)
+	}
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"non-minimal length", []byte{0x30, 0x84, 0x00, 0x00, 0x00, 0x0c, 0x02, 0x01, 0x01, 0x6f, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00}, "non-minimal length"},
+		{"indefinite length", []byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x6f, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00, 0x00, 0x00}, "indefinite length"},
+		{"trailing bytes", append(append([]byte{}, valid...), 0x00), "trailing bytes"},
+		{"non-minimal integer", []byte{0x30, 0x0d, 0x02, 0x02, 0x00, 0x01, 0x6f, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00}, "non-minimal integer"},
+		{"request tag", []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x6e, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00}, "protocol operation"},
+		{"element after operation", []byte{0x30, 0x0f, 0x02, 0x01, 0x01, 0x6f, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00, 0x04, 0x01, 0x00}, "after the protocol operation"},
+		{"invalid boolean", []byte{0x30, 0x15, 0x02, 0x01, 0x01, 0x6f, 0x07, 0x0a, 0x01, 0x06, 0x04, 0x00, 0x04, 0x00, 0xa0, 0x07, 0x30, 0x05, 0x04, 0x00, 0x01, 0x01, 0x01}, "invalid boolean"},
+	} {
+		err := checkStrictMessage(test.data)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+// sendRawResponse sends data as response without re-encoding it
+func sendRawResponse(ptc *packetTranslatorConn, data []byte) {
+	ptc.lock.Lock()
+	defer ptc.lock.Unlock()
+	ptc.responseBuf.Write(data)
+	ptc.responseCond.Broadcast()
+}
+
+// respondWithFourByteLengths answers a compare request with compareTrue,
+// encoding all lengths of the envelope in four bytes like Active Directory
+func respondWithFourByteLengths(ptc *packetTranslatorConn) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return
+	}
+	messageID := byte(req.Children[0].Value.(int64))
+	sendRawResponse(ptc, []byte{0x30, 0x84, 0x00, 0x00, 0x00, 0x10, 0x02, 0x01, messageID,
+		0x6f, 0x84, 0x00, 0x00, 0x00, 0x07, 0x0a, 0x01, byte(LDAPResultCompareTrue), 0x04, 0x00, 0x04, 0x00})
+}
+
+func TestDecodingMode(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondWithFourByteLengths(ptc)
+	matched, err := conn.Compare("cn=a,dc=example,dc=com", "cn", "a")
+	if err != nil || !matched {
+		t.Fatalf("unexpected lenient result %v, %v", matched, err)
+	}
+
+	conn.SetDecodingMode(DecodingStrict)
+	go respondWithFourByteLengths(ptc)
+	_, err = conn.Compare("cn=a,dc=example,dc=com", "cn", "a")
+	if !IsErrorWithCode(err, ErrorUnexpectedResponse) || !strings.Contains(err.Error(), "strict decoding") {
+		t.Errorf("unexpected strict error %v", err)
+	}
+
+	// the connection remains usable for valid responses
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value, "MessageID"))
+		op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationCompareResponse, nil, "Compare Response")
+		op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultCompareFalse), "resultCode"))
+		op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		response.AppendChild(op)
+		_ = ptc.SendResponse(response)
+	}()
+	matched, err = conn.Compare("cn=a,dc=example,dc=com", "cn", "b")
+	if err != nil || matched {
+		t.Errorf("unexpected strict result %v, %v", matched, err)
+	}
+}
@@ -1,6 +1,8 @@
 package ldap
 
 import (
+	"context"
+
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
 
@@ -34,7 +36,15 @@ func NewDelRequest(DN string, Controls []Control) *DelRequest {
 
 // Del executes the given delete request
 func (l *Conn) Del(delRequest *DelRequest) error {
-	msgCtx, err := l.doRequest(delRequest)
+	return l.DelContext(context.Background(), delRequest)
+}
+
+// DelContext executes the given delete request. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) DelContext(ctx context.Context, delRequest *DelRequest) (err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, delRequest)
 	if err != nil {
 		return err
 	}
@@ -55,3 +65,15 @@ func (l *Conn) Del(delRequest *DelRequest) error {
 	}
 	return nil
 }
+
+// DelWithResult executes the given delete request and returns the result
+func (l *Conn) DelWithResult(delRequest *DelRequest) (*WriteResult, error) {
+	return l.DelWithResultContext(context.Background(), delRequest)
+}
+
+// DelWithResultContext executes the given delete request and returns the result. The correlation
+// ID carried by ctx, see WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) DelWithResultContext(ctx context.Context, delRequest *DelRequest) (_ *WriteResult, err error) {
+	defer correlateError(ctx, &err)
+	return l.writeWithResult(ctx, delRequest, ApplicationDelResponse)
+}
@@ -0,0 +1,199 @@
+package ldap
+
+import (
+	"reflect"
+	"strings"
+)
+
+// DiffToModify returns a ModifyRequest for the given DN which changes the
+// attributes held by old into those held by new. old and new may each be an
+// *Entry or a struct, or pointer to a struct, with `ldap` tags as accepted by
+// MarshalEntry. Empty string values of struct fields are treated as missing
+// attributes. Attributes whose values are unchanged are left out, values are
+// compared regardless of their order. If new is a struct, only attributes bound
+// to its fields are deleted, so diffing an entry read from the server against
+// a struct leaves objectClass and other unmapped attributes alone.
+func DiffToModify(dn string, old, new interface{}) (*ModifyRequest, error) {
+	oldEntry, err := diffEntry(old)
+	if err != nil {
+		return nil, err
+	}
+	newEntry, err := diffEntry(new)
+	if err != nil {
+		return nil, err
+	}
+	var bound map[string]bool
+	if _, ok := new.(*Entry); !ok {
+		bound = boundAttributes(reflect.TypeOf(new))
+	}
+	return diffEntries(dn, oldEntry, newEntry, bound), nil
+}
+
+// Diff returns a ModifyRequest for the DN of e which changes the attributes of
+// e into those of other. Attribute names are compared case-insensitively and
+// values regardless of their order. Values added to or removed from a
+// multi-valued attribute result in add or delete changes of just these values,
+// other changes replace the attribute. If the entries are equal, the request
+// has no changes.
+func (e *Entry) Diff(other *Entry) *ModifyRequest {
+	return diffEntries(e.DN, e, other, nil)
+}
+
+// diffEntry returns the entry to compare for an argument of DiffToModify
+func diffEntry(i interface{}) (*Entry, error) {
+	if e, ok := i.(*Entry); ok {
+		return e, nil
+	}
+	e, err := MarshalEntry(i)
+	if err != nil {
+		return nil, err
+	}
+	attributes := e.Attributes[:0]
+	for _, attr := range e.Attributes {
+		var values []string
+		for _, value := range attr.Values {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) > 0 {
+			attributes = append(attributes, NewEntryAttribute(attr.Name, values))
+		}
+	}
+	e.Attributes = attributes
+	return e, nil
+}
+
+// boundAttributes returns the lower case names of the attributes bound to the
+// fields of the struct type t as marshaled by MarshalEntry, or nil if a field
+// tagged with the remain option binds all attributes
+func boundAttributes(t reflect.Type) map[string]bool {
+	bound := make(map[string]bool)
+	if !addBoundAttributes(bound, t) {
+		return nil
+	}
+	return bound
+}
+
+// addBoundAttributes adds the attributes bound to the fields of the struct
+// type t to bound. It returns false if a field binds all attributes.
+func addBoundAttributes(bound map[string]bool, t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for n := 0; n < t.NumField(); n++ {
+		ft := t.Field(n)
+		name, _ := readTag(ft, decoderTagName)
+		if name == "" {
+			continue
+		}
+		if isMarshaledStruct(ft.Type) {
+			if ft.PkgPath != "" && !(ft.Anonymous && ft.Type.Kind() == reflect.Struct) {
+				continue
+			}
+			if !addBoundAttributes(bound, ft.Type) {
+				return false
+			}
+			continue
+		}
+		if ft.PkgPath != "" {
+			continue
+		}
+		if readTagFlag(ft, decoderTagName, "remain") {
+			return false
+		}
+		if name != "dn" {
+			bound[strings.ToLower(name)] = true
+		}
+	}
+	return true
+}
+
+// diffEntries returns the minimal changes turning the attributes of old into
+// those of new. Attribute names are compared case-insensitively. Attributes
+// missing from new are only deleted if they are in bound, unless bound is nil.
+func diffEntries(dn string, old, new *Entry, bound map[string]bool) *ModifyRequest {
+	req := NewModifyRequest(dn, nil)
+	for _, attr := range new.Attributes {
+		newValues := rawStringValues(attr)
+		oldValues := rawStringValues(old.getEqualFoldAttribute(attr.Name))
+		if len(oldValues) == 0 {
+			if len(newValues) > 0 {
+				req.Add(attr.Name, newValues)
+			}
+			continue
+		}
+		if len(newValues) == 0 {
+			req.Delete(attr.Name, []string{})
+			continue
+		}
+
+		added, removed := diffValues(oldValues, newValues)
+		switch {
+		case len(added) == 0 && len(removed) == 0:
+		case len(removed) == 0:
+			req.Add(attr.Name, added)
+		case len(added) == 0:
+			req.Delete(attr.Name, removed)
+		default:
+			req.Replace(attr.Name, newValues)
+		}
+	}
+	for _, attr := range old.Attributes {
+		if bound != nil && !bound[strings.ToLower(attr.Name)] {
+			continue
+		}
+		if len(rawStringValues(attr)) > 0 && !hasEqualFoldAttribute(new, attr.Name) {
+			req.Delete(attr.Name, []string{})
+		}
+	}
+	return req
+}
+
+// rawStringValues returns the values of the attribute as they are sent to the
+// server, which for binary attributes are their ByteValues
+func rawStringValues(attr *EntryAttribute) []string {
+	if attr == nil {
+		return nil
+	}
+	if !attr.IsBinary {
+		return attr.StringValues()
+	}
+	values := make([]string, len(attr.ByteValues))
+	for i, value := range attr.ByteValues {
+		values[i] = string(value)
+	}
+	return values
+}
+
+// diffValues returns the values only present in new and those only present in old
+func diffValues(old, new []string) (added, removed []string) {
+	oldSet := make(map[string]int, len(old))
+	for _, value := range old {
+		oldSet[value]++
+	}
+	for _, value := range new {
+		if oldSet[value] > 0 {
+			oldSet[value]--
+			continue
+		}
+		added = append(added, value)
+	}
+	for _, value := range old {
+		if oldSet[value] > 0 {
+			oldSet[value]--
+			removed = append(removed, value)
+		}
+	}
+	return added, removed
+}
+
+// hasEqualFoldAttribute returns true if the entry has the named attribute
+func hasEqualFoldAttribute(e *Entry, name string) bool {
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return true
+		}
+	}
+	return false
+}
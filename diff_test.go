@@ -0,0 +1,111 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffToModify(t *testing.T) {
+	type User struct {
+		DN          string   `ldap:"dn"`
+		CN          string   `ldap:"cn"`
+		Mail        []string `ldap:"mail"`
+		Description string   `ldap:"description"`
+		Phone       []string `ldap:"telephoneNumber"`
+		Title       string   `ldap:"title"`
+	}
+
+	old := &User{
+		DN:          "cn=mario,dc=example,dc=com",
+		CN:          "mario",
+		Mail:        []string{"mario@example.com", "mario@go-ldap.com"},
+		Description: "plumber",
+		Phone:       []string{"1", "2"},
+		Title:       "hero",
+	}
+	new := &User{
+		DN:    "cn=mario,dc=example,dc=com",
+		CN:    "mario",
+		Mail:  []string{"mario@go-ldap.com", "mario@example.com", "super@example.com"},
+		Phone: []string{"1"},
+		Title: "king",
+	}
+
+	req, err := DiffToModify(new.DN, old, new)
+	assert.Nil(t, err)
+	assert.Equal(t, "cn=mario,dc=example,dc=com", req.DN)
+	assert.Equal(t, []Change{
+		{Operation: AddAttribute, Modification: PartialAttribute{Type: "mail", Vals: []string{"super@example.com"}}},
+		{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "telephoneNumber", Vals: []string{"2"}}},
+		{Operation: ReplaceAttribute, Modification: PartialAttribute{Type: "title", Vals: []string{"king"}}},
+		{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "description", Vals: []string{}}},
+	}, req.Changes)
+
+	// attributes not bound to the struct are left alone
+	entry := &Entry{
+		DN: "cn=mario,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			NewEntryAttribute("CN", []string{"mario"}),
+			NewEntryAttribute("objectClass", []string{"person"}),
+			NewEntryAttribute("Title", []string{"hero"}),
+		},
+	}
+	req, err = DiffToModify(entry.DN, entry, &User{CN: "mario"})
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{
+		{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "Title", Vals: []string{}}},
+	}, req.Changes)
+
+	_, err = DiffToModify(entry.DN, entry, "not a struct")
+	assert.NotNil(t, err)
+}
+
+func TestEntryDiff(t *testing.T) {
+	entry := &Entry{
+		DN: "cn=group,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			NewEntryAttribute("objectClass", []string{"top", "groupOfNames"}),
+			NewEntryAttribute("member", []string{"cn=a", "cn=b", "cn=c"}),
+			NewEntryAttribute("description", []string{"old"}),
+		},
+	}
+
+	same := &Entry{
+		DN: "cn=group,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			NewEntryAttribute("MEMBER", []string{"cn=c", "cn=a", "cn=b"}),
+			NewEntryAttribute("objectclass", []string{"groupOfNames", "top"}),
+			NewEntryAttribute("description", []string{"old"}),
+		},
+	}
+	assert.Equal(t, 0, len(entry.Diff(same).Changes))
+
+	other := &Entry{
+		DN: "cn=group,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			NewEntryAttribute("objectClass", []string{"top", "groupOfNames"}),
+			NewEntryAttribute("member", []string{"cn=b", "cn=d"}),
+			NewEntryAttribute("cn", []string{"group"}),
+		},
+	}
+	req := entry.Diff(other)
+	assert.Equal(t, entry.DN, req.DN)
+	assert.Equal(t, []Change{
+		{Operation: ReplaceAttribute, Modification: PartialAttribute{Type: "member", Vals: []string{"cn=b", "cn=d"}}},
+		{Operation: AddAttribute, Modification: PartialAttribute{Type: "cn", Vals: []string{"group"}}},
+		{Operation: DeleteAttribute, Modification: PartialAttribute{Type: "description", Vals: []string{}}},
+	}, req.Changes)
+}
+
+func TestEntryDiffBinary(t *testing.T) {
+	photo := &EntryAttribute{Name: "jpegPhoto", Values: []string{}, ByteValues: [][]byte{{0xff, 0xd8}}, IsBinary: true}
+	old := &Entry{DN: "cn=mario,dc=example,dc=com", Attributes: []*EntryAttribute{photo}}
+	new := old.Clone()
+	assert.Equal(t, 0, len(old.Diff(new).Changes))
+
+	new.Attributes[0].ByteValues = append(new.Attributes[0].ByteValues, []byte{0xff, 0xd9})
+	assert.Equal(t, []Change{
+		{Operation: AddAttribute, Modification: PartialAttribute{Type: "jpegPhoto", Vals: []string{"\xff\xd9"}}},
+	}, old.Diff(new).Changes)
+}
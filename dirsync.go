@@ -0,0 +1,144 @@
+package ldap
+
+// This file contains the Active Directory DirSync control, which returns the
+// objects changed since a previous search
+//
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/2213a7f2-0a36-483c-b2a4-8574d53aa1e3
+
+import (
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Flags of the DirSync control
+const (
+	// DirSyncObjectSecurity returns only the objects and attributes the user
+	// may read, instead of requiring the Replicating Directory Changes right
+	DirSyncObjectSecurity int64 = 0x00000001
+	// DirSyncAncestorsFirstOrder returns parents before their children
+	DirSyncAncestorsFirstOrder int64 = 0x00000800
+	// DirSyncPublicDataOnly omits secret attributes like password hashes
+	DirSyncPublicDataOnly int64 = 0x00002000
+	// DirSyncIncrementalValues returns only the changed values of
+	// multi-valued attributes
+	DirSyncIncrementalValues int64 = 0x80000000
+)
+
+// ControlMicrosoftDirSync implements the LDAP_SERVER_DIRSYNC control. Sent
+// with a search, it makes Active Directory return the objects changed since
+// the search which returned Cookie, or all objects for an empty cookie. In
+// the response control, a non-zero Flags value indicates that more changes
+// are available.
+type ControlMicrosoftDirSync struct {
+	Criticality bool
+	Flags       int64
+	// MaxAttrCount limits the number of attribute values returned, 0 for the
+	// server's default
+	MaxAttrCount int64
+	Cookie       []byte
+}
+
+// NewControlMicrosoftDirSync returns a critical DirSync control
+func NewControlMicrosoftDirSync(flags, maxAttrCount int64, cookie []byte) *ControlMicrosoftDirSync {
+	return &ControlMicrosoftDirSync{
+		Criticality:  true,
+		Flags:        flags,
+		MaxAttrCount: maxAttrCount,
+		Cookie:       cookie,
+	}
+}
+
+// GetControlType returns the OID
+func (c *ControlMicrosoftDirSync) GetControlType() string {
+	return ControlTypeMicrosoftDirSync
+}
+
+// Encode returns the ber packet representation
+func (c *ControlMicrosoftDirSync) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeMicrosoftDirSync, "Control Type ("+ControlTypeMap[ControlTypeMicrosoftDirSync]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (DirSync)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSyncRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "Flags"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.MaxAttrCount, "MaxAttrCount"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "Cookie"))
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlMicrosoftDirSync) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Flags: %d  MaxAttrCount: %d  Cookie: %q",
+		ControlTypeMap[ControlTypeMicrosoftDirSync],
+		ControlTypeMicrosoftDirSync,
+		c.Criticality,
+		c.Flags,
+		c.MaxAttrCount,
+		c.Cookie)
+}
+
+// decodeDirSyncControl decodes the children of a DirSync control value
+func decodeDirSyncControl(criticality bool, children []*ber.Packet) (*ControlMicrosoftDirSync, error) {
+	if len(children) != 3 {
+		return nil, errors.New("ldap: invalid DirSync control value")
+	}
+	children[0].Description = "Flags"
+	children[1].Description = "MaxAttrCount"
+	children[2].Description = "Cookie"
+	flags, ok := children[0].Value.(int64)
+	if !ok {
+		return nil, errors.New("ldap: invalid DirSync flags")
+	}
+	maxAttrCount, ok := children[1].Value.(int64)
+	if !ok {
+		return nil, errors.New("ldap: invalid DirSync attribute count")
+	}
+	return &ControlMicrosoftDirSync{
+		Criticality:  criticality,
+		Flags:        flags,
+		MaxAttrCount: maxAttrCount,
+		Cookie:       children[2].Data.Bytes(),
+	}, nil
+}
+
+// DirSync searches for the objects changed since the DirSync search which
+// returned cookie, or for all objects if cookie is empty. It repeats the
+// search until the server reports that no more changes are available. The
+// DirSync control of the last response is included in the controls of the
+// result; pass its Cookie to the next call.
+//
+// The base DN must be the root of a naming context, and without
+// DirSyncObjectSecurity the Replicating Directory Changes right is required.
+func (l *Conn) DirSync(searchRequest *SearchRequest, flags, maxAttrCount int64, cookie []byte) (*SearchResult, error) {
+	control := NewControlMicrosoftDirSync(flags, maxAttrCount, cookie)
+	// the caller's controls are left untouched
+	req := *searchRequest
+	req.Controls = append(append([]Control(nil), searchRequest.Controls...), control)
+
+	searchResult := new(SearchResult)
+	for {
+		result, err := l.Search(&req)
+		if err != nil {
+			return searchResult, err
+		}
+		searchResult.Entries = append(searchResult.Entries, result.Entries...)
+		searchResult.Referrals = append(searchResult.Referrals, result.Referrals...)
+
+		response, ok := FindControl(result.Controls, ControlTypeMicrosoftDirSync).(*ControlMicrosoftDirSync)
+		if !ok {
+			return searchResult, NewError(ErrorUnexpectedResponse, errors.New("ldap: missing DirSync control in response"))
+		}
+		if response.Flags == 0 {
+			searchResult.Controls = result.Controls
+			return searchResult, nil
+		}
+		control.Cookie = response.Cookie
+	}
+}
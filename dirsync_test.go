@@ -0,0 +1,59 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestControlMicrosoftDirSync(t *testing.T) {
+	runControlTest(t, NewControlMicrosoftDirSync(DirSyncObjectSecurity|DirSyncIncrementalValues, 1000, []byte("cookie")))
+	runControlTest(t, &ControlMicrosoftDirSync{Cookie: []byte{}})
+}
+
+func TestDirSync(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	cookies := make(chan []byte, 2)
+	go func() {
+		// the first response announces more changes
+		for i, response := range []*ControlMicrosoftDirSync{
+			{Flags: 1, Cookie: []byte("first")},
+			{Flags: 0, Cookie: []byte("second")},
+		} {
+			req, err := respondWithResultControls(ptc, ApplicationSearchResultDone, LDAPResultSuccess, response)
+			if err != nil {
+				return
+			}
+			controls := req.Children[2].Children
+			control, err := DecodeControl(controls[len(controls)-1])
+			if err != nil {
+				t.Errorf("request %d: %s", i, err)
+				return
+			}
+			cookies <- control.(*ControlMicrosoftDirSync).Cookie
+		}
+	}()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=user)", nil, nil)
+	result, err := conn.DirSync(searchRequest, DirSyncObjectSecurity, 0, []byte("start"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"start", "first"} {
+		if cookie := <-cookies; !bytes.Equal(cookie, []byte(expected)) {
+			t.Errorf("expected cookie %q, got %q", expected, cookie)
+		}
+	}
+	control, ok := FindControl(result.Controls, ControlTypeMicrosoftDirSync).(*ControlMicrosoftDirSync)
+	if !ok || string(control.Cookie) != "second" {
+		t.Errorf("unexpected result controls %v", result.Controls)
+	}
+	if len(searchRequest.Controls) != 0 {
+		t.Errorf("the controls of the search request were modified: %v", searchRequest.Controls)
+	}
+}
@@ -348,3 +348,74 @@ func (r *RelativeDN) hasAllAttributesFold(attrs []*AttributeTypeAndValue) bool {
 func (a *AttributeTypeAndValue) EqualFold(other *AttributeTypeAndValue) bool {
 	return strings.EqualFold(a.Type, other.Type) && strings.EqualFold(a.Value, other.Value)
 }
+
+// ExtendedDN is a DN returned by Active Directory when the
+// ControlMicrosoftExtendedDN control is used, e.g.
+// "<GUID=...>;<SID=...>;CN=Administrator,CN=Users,DC=example,DC=com"
+type ExtendedDN struct {
+	// GUID is the objectGUID of the object
+	GUID GUID
+	// SID is the objectSid of the object, or nil if it has none
+	SID *SID
+	// DN is the plain DN of the object
+	DN string
+}
+
+// ParseExtendedDN parses a DN in the extended form returned with the
+// ControlMicrosoftExtendedDN control. GUIDs and SIDs are accepted in both
+// the binary hexadecimal and the string format. Plain DNs are returned as they are.
+func ParseExtendedDN(str string) (*ExtendedDN, error) {
+	e := &ExtendedDN{}
+	for strings.HasPrefix(str, "<") {
+		end := strings.IndexByte(str, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("ldap: invalid extended DN component '%s'", str)
+		}
+		component := str[1:end]
+		str = strings.TrimPrefix(str[end+1:], ";")
+
+		eq := strings.IndexByte(component, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("ldap: invalid extended DN component '<%s>'", component)
+		}
+		key, value := component[:eq], component[eq+1:]
+		switch strings.ToUpper(key) {
+		case "GUID":
+			guid, err := parseExtendedDNGUID(value)
+			if err != nil {
+				return nil, err
+			}
+			e.GUID = guid
+		case "SID":
+			sid, err := parseExtendedDNSID(value)
+			if err != nil {
+				return nil, err
+			}
+			e.SID = sid
+		}
+	}
+	e.DN = str
+	return e, nil
+}
+
+func parseExtendedDNGUID(value string) (GUID, error) {
+	if strings.IndexByte(value, '-') >= 0 {
+		return ParseGUIDString(value)
+	}
+	b, err := enchex.DecodeString(value)
+	if err != nil {
+		return GUID{}, ErrInvalidGUID
+	}
+	return ParseGUID(b)
+}
+
+func parseExtendedDNSID(value string) (*SID, error) {
+	if strings.HasPrefix(value, "S-") || strings.HasPrefix(value, "s-") {
+		return ParseSIDString(value)
+	}
+	b, err := enchex.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidSID
+	}
+	return ParseSID(b)
+}
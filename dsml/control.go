@@ -0,0 +1,71 @@
+package dsml
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// controlElement is a DSMLv2 <control>
+type controlElement struct {
+	Type         string `xml:"type,attr"`
+	Criticality  bool   `xml:"criticality,attr,omitempty"`
+	ControlValue *value `xml:"controlValue"`
+}
+
+// encodeControls returns the DSML elements for the given LDAP controls
+func encodeControls(controls []ldap.Control) []controlElement {
+	var elements []controlElement
+	for _, control := range controls {
+		packet := control.Encode()
+		el := controlElement{Type: control.GetControlType()}
+		for _, child := range packet.Children[1:] {
+			if criticality, ok := child.Value.(bool); ok {
+				el.Criticality = criticality
+				continue
+			}
+			// control values are always sent base64 encoded, as they are
+			// usually BER encoded structures
+			el.ControlValue = &value{Type: base64BinaryType, Data: base64.StdEncoding.EncodeToString(child.Data.Bytes())}
+		}
+		elements = append(elements, el)
+	}
+	return elements
+}
+
+// decodeControls returns the LDAP controls for the given DSML elements
+func decodeControls(elements []controlElement) ([]ldap.Control, error) {
+	var controls []ldap.Control
+	for _, el := range elements {
+		if el.Type == "" {
+			return nil, fmt.Errorf("dsml: control without type")
+		}
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, el.Type, "Control Type"))
+		if el.Criticality {
+			packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Criticality"))
+		}
+		if el.ControlValue != nil {
+			b, err := el.ControlValue.bytes()
+			if err != nil {
+				return nil, fmt.Errorf("dsml: invalid value for control %s: %w", el.Type, err)
+			}
+			packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(b), "Control Value"))
+		}
+
+		// round trip through the wire format so the control is decoded
+		// exactly like one received from a server
+		decoded, err := ber.DecodePacketErr(packet.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("dsml: invalid control %s: %w", el.Type, err)
+		}
+		control, err := ldap.DecodeControl(decoded)
+		if err != nil {
+			return nil, fmt.Errorf("dsml: invalid control %s: %w", el.Type, err)
+		}
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
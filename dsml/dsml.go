@@ -0,0 +1,26 @@
+// Package dsml encodes LDAP requests and search results as DSMLv2 documents
+// (https://www.oasis-open.org/committees/dsml/docs/dsmlv2.xsd) and decodes
+// them back into their ldap package counterparts.
+package dsml
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Namespace is the XML namespace of DSMLv2 core elements
+const Namespace = "urn:oasis:names:tc:DSML:2:0:core"
+
+// writeDocument writes v as an indented XML document to w
+func writeDocument(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
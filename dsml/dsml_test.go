@@ -0,0 +1,123 @@
+package dsml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+func TestBatchRequestRoundTrip(t *testing.T) {
+	search := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.DerefAlways, 10, 5, false,
+		"(&(objectClass=person)(|(cn=ab*c*d)(!(uid=x)))(mail=*)(cn:caseExactMatch:=Bob))",
+		[]string{"cn", "mail"}, []ldap.Control{ldap.NewControlPaging(100)})
+	add := ldap.NewAddRequest("cn=bob,dc=example,dc=com", nil)
+	add.Attribute("objectClass", []string{"top", "person"})
+	add.Attribute("jpegPhoto", []string{"\xff\xd8\x00\x01"})
+	modify := ldap.NewModifyRequest("cn=bob,dc=example,dc=com", nil)
+	modify.Add("mail", []string{"bob@example.com"})
+	modify.Delete("description", []string{})
+	modify.Replace("sn", []string{"Smith <&>"})
+	modify.Increment("uidNumber", "1")
+	del := ldap.NewDelRequest("cn=bob,dc=example,dc=com", []ldap.Control{ldap.NewControlManageDsaIT(true)})
+	moddn := ldap.NewModifyDNRequest("cn=bob,dc=example,dc=com", "cn=robert", false, "ou=people,dc=example,dc=com")
+	compare := ldap.NewCompareRequest("cn=bob,dc=example,dc=com", "sn", "Smith", []ldap.Control{ldap.NewControlManageDsaIT(true)})
+
+	requests := []interface{}{search, add, modify, del, moddn, compare}
+	var buf bytes.Buffer
+	if err := WriteBatchRequest(&buf, requests...); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<equalityMatch name="uid">`) {
+		t.Errorf("unexpected document:\n%s", buf.String())
+	}
+
+	got, err := ReadBatchRequest(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, requests) {
+		t.Errorf("round trip mismatch\n got: %#v\nwant: %#v", got, requests)
+	}
+}
+
+func TestReadBatchRequest(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<dsml:batchRequest xmlns:dsml="urn:oasis:names:tc:DSML:2:0:core" xmlns:x="http://www.w3.org/2001/XMLSchema-instance">
+  <dsml:searchRequest dn="dc=example,dc=com" scope="singleLevel" derefAliases="neverDerefAliases">
+    <dsml:filter>
+      <dsml:substrings name="cn"><dsml:initial>a</dsml:initial><dsml:final>Yg==</dsml:final></dsml:substrings>
+    </dsml:filter>
+  </dsml:searchRequest>
+  <dsml:modDNRequest dn="cn=a,dc=example,dc=com" newrdn="cn=b"/>
+  <dsml:compareRequest dn="cn=a,dc=example,dc=com">
+    <dsml:assertion name="sn"><dsml:value x:type="xsd:base64Binary">U21pdGg=</dsml:value></dsml:assertion>
+  </dsml:compareRequest>
+</dsml:batchRequest>`
+
+	got, err := ReadBatchRequest(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		&ldap.SearchRequest{BaseDN: "dc=example,dc=com", Scope: ldap.ScopeSingleLevel, DerefAliases: ldap.NeverDerefAliases, Filter: "(cn=a*Yg==)"},
+		ldap.NewModifyDNRequest("cn=a,dc=example,dc=com", "cn=b", true, ""),
+		&ldap.CompareRequest{DN: "cn=a,dc=example,dc=com", Attribute: "sn", Value: "Smith"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	if _, err := ReadBatchRequest(strings.NewReader(`<batchRequest><extendedRequest/></batchRequest>`)); err == nil {
+		t.Error("expected error for unsupported request")
+	}
+}
+
+func TestSearchResultRoundTrip(t *testing.T) {
+	result := &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			{DN: "cn=bob,dc=example,dc=com", Attributes: []*ldap.EntryAttribute{
+				{Name: "cn", Values: []string{"bob"}, ByteValues: [][]byte{[]byte("bob")}},
+				{Name: "objectGUID", Values: []string{"\x00\x01\xfe"}, ByteValues: [][]byte{{0x00, 0x01, 0xfe}}},
+			}},
+			{DN: "cn=alice,dc=example,dc=com"},
+		},
+		Referrals: []string{"ldap://other.example.com/dc=example,dc=com"},
+		Controls:  []ldap.Control{ldap.NewControlPaging(50)},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSearchResult(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<value xsi:type="xsd:base64Binary">AAH+</value>`) {
+		t.Errorf("binary value not base64 encoded:\n%s", buf.String())
+	}
+
+	got, err := ReadSearchResult(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, result) {
+		t.Errorf("round trip mismatch\n got: %#v\nwant: %#v", got, result)
+	}
+}
+
+func TestReadSearchResultError(t *testing.T) {
+	doc := `<batchResponse xmlns="urn:oasis:names:tc:DSML:2:0:core">
+  <searchResponse>
+    <searchResultEntry dn="cn=a"><attr name="cn"><value>a</value></attr></searchResultEntry>
+    <searchResultDone><resultCode code="4"/><errorMessage>too many</errorMessage></searchResultDone>
+  </searchResponse>
+</batchResponse>`
+
+	result, err := ReadSearchResult(strings.NewReader(doc))
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].GetAttributeValue("cn") != "a" {
+		t.Errorf("unexpected entries: %#v", result.Entries)
+	}
+}
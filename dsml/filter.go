@@ -0,0 +1,213 @@
+package dsml
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// filterElement is a DSMLv2 filter or one of its components, e.g. <and> or
+// <equalityMatch>
+type filterElement struct {
+	XMLName      xml.Name
+	Name         string          `xml:"name,attr,omitempty"`
+	MatchingRule string          `xml:"matchingRule,attr,omitempty"`
+	DNAttributes bool            `xml:"dnAttributes,attr,omitempty"`
+	Value        *value          `xml:"value"`
+	Initial      *value          `xml:"initial"`
+	Any          []value         `xml:"any"`
+	Final        *value          `xml:"final"`
+	Children     []filterElement `xml:",any"`
+}
+
+// filterElementNames maps filter choices to DSML element names
+var filterElementNames = map[uint64]string{
+	ldap.FilterAnd:             "and",
+	ldap.FilterOr:              "or",
+	ldap.FilterNot:             "not",
+	ldap.FilterEqualityMatch:   "equalityMatch",
+	ldap.FilterSubstrings:      "substrings",
+	ldap.FilterGreaterOrEqual:  "greaterOrEqual",
+	ldap.FilterLessOrEqual:     "lessOrEqual",
+	ldap.FilterPresent:         "present",
+	ldap.FilterApproxMatch:     "approxMatch",
+	ldap.FilterExtensibleMatch: "extensibleMatch",
+}
+
+// encodeFilter returns the DSML <filter> element for an LDAP filter string
+func encodeFilter(filter string) (*filterElement, error) {
+	packet, err := ldap.CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	child, err := encodeFilterPacket(packet)
+	if err != nil {
+		return nil, err
+	}
+	return &filterElement{XMLName: xml.Name{Local: "filter"}, Children: []filterElement{*child}}, nil
+}
+
+func encodeFilterPacket(packet *ber.Packet) (*filterElement, error) {
+	tag := uint64(packet.Tag)
+	name, ok := filterElementNames[tag]
+	if !ok {
+		return nil, fmt.Errorf("dsml: unknown filter choice %d", tag)
+	}
+	el := &filterElement{XMLName: xml.Name{Local: name}}
+
+	switch tag {
+	case ldap.FilterAnd, ldap.FilterOr, ldap.FilterNot:
+		for _, child := range packet.Children {
+			childElement, err := encodeFilterPacket(child)
+			if err != nil {
+				return nil, err
+			}
+			el.Children = append(el.Children, *childElement)
+		}
+	case ldap.FilterEqualityMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual, ldap.FilterApproxMatch:
+		el.Name = ber.DecodeString(packet.Children[0].Data.Bytes())
+		v := newValue(packet.Children[1].Data.Bytes())
+		el.Value = &v
+	case ldap.FilterPresent:
+		el.Name = ber.DecodeString(packet.Data.Bytes())
+	case ldap.FilterSubstrings:
+		el.Name = ber.DecodeString(packet.Children[0].Data.Bytes())
+		for _, child := range packet.Children[1].Children {
+			v := newValue(child.Data.Bytes())
+			switch child.Tag {
+			case ldap.FilterSubstringsInitial:
+				el.Initial = &v
+			case ldap.FilterSubstringsAny:
+				el.Any = append(el.Any, v)
+			case ldap.FilterSubstringsFinal:
+				el.Final = &v
+			}
+		}
+	case ldap.FilterExtensibleMatch:
+		for _, child := range packet.Children {
+			switch child.Tag {
+			case ldap.MatchingRuleAssertionMatchingRule:
+				el.MatchingRule = ber.DecodeString(child.Data.Bytes())
+			case ldap.MatchingRuleAssertionType:
+				el.Name = ber.DecodeString(child.Data.Bytes())
+			case ldap.MatchingRuleAssertionMatchValue:
+				v := newValue(child.Data.Bytes())
+				el.Value = &v
+			case ldap.MatchingRuleAssertionDNAttributes:
+				el.DNAttributes, _ = child.Value.(bool)
+			}
+		}
+	}
+	return el, nil
+}
+
+// decodeFilter returns the LDAP filter string for a DSML <filter> element
+func decodeFilter(el *filterElement) (string, error) {
+	if el == nil || len(el.Children) != 1 {
+		return "", fmt.Errorf("dsml: filter must have exactly one component")
+	}
+	packet, err := decodeFilterElement(&el.Children[0])
+	if err != nil {
+		return "", err
+	}
+	return ldap.DecompileFilter(packet)
+}
+
+func decodeFilterElement(el *filterElement) (*ber.Packet, error) {
+	var tag uint64
+	found := false
+	for t, name := range filterElementNames {
+		if name == el.XMLName.Local {
+			tag, found = t, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("dsml: unknown filter element <%s>", el.XMLName.Local)
+	}
+
+	valueBytes := func(v *value) ([]byte, error) {
+		if v == nil {
+			return nil, fmt.Errorf("dsml: missing value in <%s>", el.XMLName.Local)
+		}
+		return v.bytes()
+	}
+
+	switch tag {
+	case ldap.FilterAnd, ldap.FilterOr, ldap.FilterNot:
+		if tag == ldap.FilterNot && len(el.Children) != 1 {
+			return nil, fmt.Errorf("dsml: <not> must have exactly one component")
+		}
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, ber.Tag(tag), nil, ldap.FilterMap[tag])
+		for i := range el.Children {
+			child, err := decodeFilterElement(&el.Children[i])
+			if err != nil {
+				return nil, err
+			}
+			packet.AppendChild(child)
+		}
+		return packet, nil
+	case ldap.FilterEqualityMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual, ldap.FilterApproxMatch:
+		b, err := valueBytes(el.Value)
+		if err != nil {
+			return nil, err
+		}
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, ber.Tag(tag), nil, ldap.FilterMap[tag])
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, el.Name, "Attribute"))
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(b), "Condition"))
+		return packet, nil
+	case ldap.FilterPresent:
+		return ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.FilterPresent, el.Name, ldap.FilterMap[tag]), nil
+	case ldap.FilterSubstrings:
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, ldap.FilterSubstrings, nil, ldap.FilterMap[tag])
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, el.Name, "Attribute"))
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Substrings")
+		appendSubstring := func(substringTag uint64, v *value) error {
+			b, err := valueBytes(v)
+			if err != nil {
+				return err
+			}
+			seq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ber.Tag(substringTag), string(b), ldap.FilterSubstringsMap[substringTag]))
+			return nil
+		}
+		if el.Initial != nil {
+			if err := appendSubstring(ldap.FilterSubstringsInitial, el.Initial); err != nil {
+				return nil, err
+			}
+		}
+		for i := range el.Any {
+			if err := appendSubstring(ldap.FilterSubstringsAny, &el.Any[i]); err != nil {
+				return nil, err
+			}
+		}
+		if el.Final != nil {
+			if err := appendSubstring(ldap.FilterSubstringsFinal, el.Final); err != nil {
+				return nil, err
+			}
+		}
+		if len(seq.Children) == 0 {
+			return nil, fmt.Errorf("dsml: <substrings> must have at least one component")
+		}
+		packet.AppendChild(seq)
+		return packet, nil
+	default: // ldap.FilterExtensibleMatch
+		b, err := valueBytes(el.Value)
+		if err != nil {
+			return nil, err
+		}
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, ldap.FilterExtensibleMatch, nil, ldap.FilterMap[tag])
+		if el.MatchingRule != "" {
+			packet.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.MatchingRuleAssertionMatchingRule, el.MatchingRule, "Matching Rule"))
+		}
+		if el.Name != "" {
+			packet.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.MatchingRuleAssertionType, el.Name, "Type"))
+		}
+		packet.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ldap.MatchingRuleAssertionMatchValue, string(b), "Match Value"))
+		if el.DNAttributes {
+			packet.AppendChild(ber.NewBoolean(ber.ClassContext, ber.TypePrimitive, ldap.MatchingRuleAssertionDNAttributes, true, "DN Attributes"))
+		}
+		return packet, nil
+	}
+}
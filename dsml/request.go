@@ -0,0 +1,296 @@
+package dsml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/go-ldap/ldap"
+)
+
+// scopeNames maps search scopes to their DSML names
+var scopeNames = map[int]string{
+	ldap.ScopeBaseObject:   "baseObject",
+	ldap.ScopeSingleLevel:  "singleLevel",
+	ldap.ScopeWholeSubtree: "wholeSubtree",
+}
+
+// derefNames maps alias dereferencing choices to their DSML names
+var derefNames = map[int]string{
+	ldap.NeverDerefAliases:   "neverDerefAliases",
+	ldap.DerefInSearching:    "derefInSearching",
+	ldap.DerefFindingBaseObj: "derefFindingBaseObj",
+	ldap.DerefAlways:         "derefAlways",
+}
+
+// operationNames maps modify operations to their DSML names
+var operationNames = map[uint]string{
+	ldap.AddAttribute:       "add",
+	ldap.DeleteAttribute:    "delete",
+	ldap.ReplaceAttribute:   "replace",
+	ldap.IncrementAttribute: "increment",
+}
+
+type batchRequest struct {
+	XMLName  xml.Name         `xml:"batchRequest"`
+	Xmlns    string           `xml:"xmlns,attr,omitempty"`
+	XmlnsXsi string           `xml:"xmlns:xsi,attr,omitempty"`
+	XmlnsXsd string           `xml:"xmlns:xsd,attr,omitempty"`
+	Requests []requestElement `xml:",any"`
+}
+
+// requestElement holds any of the supported DSML request elements, the
+// element name tells which fields are used
+type requestElement struct {
+	XMLName  xml.Name
+	DN       string           `xml:"dn,attr"`
+	Controls []controlElement `xml:"control"`
+
+	// searchRequest
+	Scope        string                 `xml:"scope,attr,omitempty"`
+	DerefAliases string                 `xml:"derefAliases,attr,omitempty"`
+	SizeLimit    int                    `xml:"sizeLimit,attr,omitempty"`
+	TimeLimit    int                    `xml:"timeLimit,attr,omitempty"`
+	TypesOnly    bool                   `xml:"typesOnly,attr,omitempty"`
+	Filter       *filterElement         `xml:"filter"`
+	Attributes   *attributeDescriptions `xml:"attributes"`
+
+	// addRequest and modifyRequest
+	Attrs         []attrElement         `xml:"attr"`
+	Modifications []modificationElement `xml:"modification"`
+
+	// modDNRequest
+	NewRDN       string `xml:"newrdn,attr,omitempty"`
+	DeleteOldRDN *bool  `xml:"deleteoldrdn,attr,omitempty"`
+	NewSuperior  string `xml:"newSuperior,attr,omitempty"`
+
+	// compareRequest
+	Assertion *attrElement `xml:"assertion"`
+}
+
+type attributeDescriptions struct {
+	Attributes []attrElement `xml:"attribute"`
+}
+
+// attrElement is a DSML <attr>, <attribute> or <assertion>
+type attrElement struct {
+	Name   string  `xml:"name,attr"`
+	Values []value `xml:"value"`
+}
+
+type modificationElement struct {
+	Name      string  `xml:"name,attr"`
+	Operation string  `xml:"operation,attr"`
+	Values    []value `xml:"value"`
+}
+
+// WriteBatchRequest writes the given requests as a DSMLv2 <batchRequest> to w.
+// Supported requests are *ldap.SearchRequest, *ldap.AddRequest,
+// *ldap.ModifyRequest, *ldap.DelRequest, *ldap.ModifyDNRequest and
+// *ldap.CompareRequest.
+func WriteBatchRequest(w io.Writer, requests ...interface{}) error {
+	batch := &batchRequest{Xmlns: Namespace, XmlnsXsi: xsiNamespace, XmlnsXsd: xsdNamespace}
+	for _, req := range requests {
+		el, err := encodeRequest(req)
+		if err != nil {
+			return err
+		}
+		batch.Requests = append(batch.Requests, *el)
+	}
+	return writeDocument(w, batch)
+}
+
+// ReadBatchRequest reads a DSMLv2 <batchRequest> from r and returns the
+// contained requests in document order, see WriteBatchRequest for the
+// possible types
+func ReadBatchRequest(r io.Reader) ([]interface{}, error) {
+	batch := &batchRequest{}
+	if err := xml.NewDecoder(r).Decode(batch); err != nil {
+		return nil, fmt.Errorf("dsml: %w", err)
+	}
+	var requests []interface{}
+	for i := range batch.Requests {
+		req, err := decodeRequest(&batch.Requests[i])
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+func encodeRequest(req interface{}) (*requestElement, error) {
+	switch r := req.(type) {
+	case *ldap.SearchRequest:
+		scope, ok := scopeNames[r.Scope]
+		if !ok {
+			return nil, fmt.Errorf("dsml: invalid scope %d", r.Scope)
+		}
+		deref, ok := derefNames[r.DerefAliases]
+		if !ok {
+			return nil, fmt.Errorf("dsml: invalid derefAliases %d", r.DerefAliases)
+		}
+		filter, err := encodeFilter(r.Filter)
+		if err != nil {
+			return nil, err
+		}
+		el := &requestElement{
+			XMLName:      xml.Name{Local: "searchRequest"},
+			DN:           r.BaseDN,
+			Controls:     encodeControls(r.Controls),
+			Scope:        scope,
+			DerefAliases: deref,
+			SizeLimit:    r.SizeLimit,
+			TimeLimit:    r.TimeLimit,
+			TypesOnly:    r.TypesOnly,
+			Filter:       filter,
+		}
+		if len(r.Attributes) > 0 {
+			el.Attributes = &attributeDescriptions{}
+			for _, attr := range r.Attributes {
+				el.Attributes.Attributes = append(el.Attributes.Attributes, attrElement{Name: attr})
+			}
+		}
+		return el, nil
+	case *ldap.AddRequest:
+		el := &requestElement{XMLName: xml.Name{Local: "addRequest"}, DN: r.DN, Controls: encodeControls(r.Controls)}
+		for _, attr := range r.Attributes {
+			el.Attrs = append(el.Attrs, attrElement{Name: attr.Type, Values: newValues(attr.Vals)})
+		}
+		return el, nil
+	case *ldap.ModifyRequest:
+		el := &requestElement{XMLName: xml.Name{Local: "modifyRequest"}, DN: r.DN, Controls: encodeControls(r.Controls)}
+		for _, change := range r.Changes {
+			operation, ok := operationNames[change.Operation]
+			if !ok {
+				return nil, fmt.Errorf("dsml: invalid modify operation %d", change.Operation)
+			}
+			el.Modifications = append(el.Modifications, modificationElement{
+				Name:      change.Modification.Type,
+				Operation: operation,
+				Values:    newValues(change.Modification.Vals),
+			})
+		}
+		return el, nil
+	case *ldap.DelRequest:
+		return &requestElement{XMLName: xml.Name{Local: "delRequest"}, DN: r.DN, Controls: encodeControls(r.Controls)}, nil
+	case *ldap.ModifyDNRequest:
+		deleteOldRDN := r.DeleteOldRDN
+		return &requestElement{
+			XMLName:      xml.Name{Local: "modDNRequest"},
+			DN:           r.DN,
+			Controls:     encodeControls(r.Controls),
+			NewRDN:       r.NewRDN,
+			DeleteOldRDN: &deleteOldRDN,
+			NewSuperior:  r.NewSuperior,
+		}, nil
+	case *ldap.CompareRequest:
+		return &requestElement{
+			XMLName:   xml.Name{Local: "compareRequest"},
+			DN:        r.DN,
+			Controls:  encodeControls(r.Controls),
+			Assertion: &attrElement{Name: r.Attribute, Values: []value{newValue([]byte(r.Value))}},
+		}, nil
+	default:
+		return nil, fmt.Errorf("dsml: unsupported request type %T", req)
+	}
+}
+
+func decodeRequest(el *requestElement) (interface{}, error) {
+	controls, err := decodeControls(el.Controls)
+	if err != nil {
+		return nil, err
+	}
+
+	switch el.XMLName.Local {
+	case "searchRequest":
+		req := &ldap.SearchRequest{
+			BaseDN:    el.DN,
+			SizeLimit: el.SizeLimit,
+			TimeLimit: el.TimeLimit,
+			TypesOnly: el.TypesOnly,
+			Controls:  controls,
+		}
+		if req.Scope, err = lookupName(scopeNames, "scope", el.Scope); err != nil {
+			return nil, err
+		}
+		if req.DerefAliases, err = lookupName(derefNames, "derefAliases", el.DerefAliases); err != nil {
+			return nil, err
+		}
+		if req.Filter, err = decodeFilter(el.Filter); err != nil {
+			return nil, err
+		}
+		if el.Attributes != nil {
+			for _, attr := range el.Attributes.Attributes {
+				req.Attributes = append(req.Attributes, attr.Name)
+			}
+		}
+		return req, nil
+	case "addRequest":
+		req := ldap.NewAddRequest(el.DN, controls)
+		for _, attr := range el.Attrs {
+			values, err := stringValues(attr.Values)
+			if err != nil {
+				return nil, fmt.Errorf("dsml: invalid value for %s: %w", attr.Name, err)
+			}
+			req.Attribute(attr.Name, values)
+		}
+		return req, nil
+	case "modifyRequest":
+		req := ldap.NewModifyRequest(el.DN, controls)
+		for _, mod := range el.Modifications {
+			values, err := stringValues(mod.Values)
+			if err != nil {
+				return nil, fmt.Errorf("dsml: invalid value for %s: %w", mod.Name, err)
+			}
+			var operation uint
+			found := false
+			for op, name := range operationNames {
+				if name == mod.Operation {
+					operation, found = op, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("dsml: invalid modify operation %q", mod.Operation)
+			}
+			if values == nil {
+				values = []string{}
+			}
+			req.Changes = append(req.Changes, ldap.Change{
+				Operation:    operation,
+				Modification: ldap.PartialAttribute{Type: mod.Name, Vals: values},
+			})
+		}
+		return req, nil
+	case "delRequest":
+		return ldap.NewDelRequest(el.DN, controls), nil
+	case "modDNRequest":
+		// deleteoldrdn defaults to true in the DSMLv2 schema
+		deleteOldRDN := el.DeleteOldRDN == nil || *el.DeleteOldRDN
+		req := ldap.NewModifyDNRequest(el.DN, el.NewRDN, deleteOldRDN, el.NewSuperior)
+		req.Controls = controls
+		return req, nil
+	case "compareRequest":
+		if el.Assertion == nil || len(el.Assertion.Values) != 1 {
+			return nil, fmt.Errorf("dsml: compareRequest needs an assertion with exactly one value")
+		}
+		b, err := el.Assertion.Values[0].bytes()
+		if err != nil {
+			return nil, fmt.Errorf("dsml: invalid value for %s: %w", el.Assertion.Name, err)
+		}
+		return ldap.NewCompareRequest(el.DN, el.Assertion.Name, string(b), controls), nil
+	default:
+		return nil, fmt.Errorf("dsml: unsupported request <%s>", el.XMLName.Local)
+	}
+}
+
+// lookupName returns the key for name in names
+func lookupName(names map[int]string, attr, name string) (int, error) {
+	for k, v := range names {
+		if v == name {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("dsml: invalid %s %q", attr, name)
+}
@@ -0,0 +1,134 @@
+package dsml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-ldap/ldap"
+)
+
+type batchResponse struct {
+	XMLName         xml.Name         `xml:"batchResponse"`
+	Xmlns           string           `xml:"xmlns,attr,omitempty"`
+	XmlnsXsi        string           `xml:"xmlns:xsi,attr,omitempty"`
+	XmlnsXsd        string           `xml:"xmlns:xsd,attr,omitempty"`
+	SearchResponses []searchResponse `xml:"searchResponse"`
+	ErrorResponse   *errorResponse   `xml:"errorResponse"`
+}
+
+type searchResponse struct {
+	Entries    []searchResultEntry     `xml:"searchResultEntry"`
+	References []searchResultReference `xml:"searchResultReference"`
+	Done       *ldapResult             `xml:"searchResultDone"`
+}
+
+type searchResultEntry struct {
+	DN    string        `xml:"dn,attr"`
+	Attrs []attrElement `xml:"attr"`
+}
+
+type searchResultReference struct {
+	Refs []string `xml:"ref"`
+}
+
+type ldapResult struct {
+	Controls     []controlElement `xml:"control"`
+	ResultCode   resultCode       `xml:"resultCode"`
+	ErrorMessage string           `xml:"errorMessage,omitempty"`
+	Referrals    []string         `xml:"referral"`
+}
+
+type resultCode struct {
+	Code  uint16 `xml:"code,attr"`
+	Descr string `xml:"descr,attr,omitempty"`
+}
+
+type errorResponse struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message"`
+}
+
+// WriteSearchResult writes result as a DSMLv2 <batchResponse> holding a
+// single successful <searchResponse> to w
+func WriteSearchResult(w io.Writer, result *ldap.SearchResult) error {
+	response := searchResponse{
+		Done: &ldapResult{
+			Controls:   encodeControls(result.Controls),
+			ResultCode: resultCode{Code: ldap.LDAPResultSuccess, Descr: "success"},
+		},
+	}
+	for _, entry := range result.Entries {
+		el := searchResultEntry{DN: entry.DN}
+		for _, attr := range entry.Attributes {
+			a := attrElement{Name: attr.Name}
+			for _, b := range attr.ByteValues {
+				a.Values = append(a.Values, newValue(b))
+			}
+			el.Attrs = append(el.Attrs, a)
+		}
+		response.Entries = append(response.Entries, el)
+	}
+	if len(result.Referrals) > 0 {
+		response.References = append(response.References, searchResultReference{Refs: result.Referrals})
+	}
+
+	return writeDocument(w, &batchResponse{
+		Xmlns:           Namespace,
+		XmlnsXsi:        xsiNamespace,
+		XmlnsXsd:        xsdNamespace,
+		SearchResponses: []searchResponse{response},
+	})
+}
+
+// ReadSearchResult reads the first <searchResponse> of a DSMLv2
+// <batchResponse> from r. If the search did not succeed, the entries read so
+// far are returned along with an *ldap.Error carrying the result code.
+func ReadSearchResult(r io.Reader) (*ldap.SearchResult, error) {
+	batch := &batchResponse{}
+	if err := xml.NewDecoder(r).Decode(batch); err != nil {
+		return nil, fmt.Errorf("dsml: %w", err)
+	}
+	if len(batch.SearchResponses) == 0 {
+		if batch.ErrorResponse != nil {
+			return nil, fmt.Errorf("dsml: %s: %s", batch.ErrorResponse.Type, batch.ErrorResponse.Message)
+		}
+		return nil, fmt.Errorf("dsml: no searchResponse found")
+	}
+
+	response := batch.SearchResponses[0]
+	result := &ldap.SearchResult{}
+	for _, el := range response.Entries {
+		entry := &ldap.Entry{DN: el.DN}
+		for _, a := range el.Attrs {
+			attr := &ldap.EntryAttribute{Name: a.Name}
+			for _, v := range a.Values {
+				b, err := v.bytes()
+				if err != nil {
+					return nil, fmt.Errorf("dsml: invalid value for %s: %w", a.Name, err)
+				}
+				attr.Values = append(attr.Values, string(b))
+				attr.ByteValues = append(attr.ByteValues, b)
+			}
+			entry.Attributes = append(entry.Attributes, attr)
+		}
+		result.Entries = append(result.Entries, entry)
+	}
+	for _, ref := range response.References {
+		result.Referrals = append(result.Referrals, ref.Refs...)
+	}
+
+	if response.Done == nil {
+		return result, fmt.Errorf("dsml: searchResponse without searchResultDone")
+	}
+	controls, err := decodeControls(response.Done.Controls)
+	if err != nil {
+		return nil, err
+	}
+	result.Controls = controls
+	if code := response.Done.ResultCode.Code; code != ldap.LDAPResultSuccess {
+		return result, ldap.NewError(code, errors.New(response.Done.ErrorMessage))
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package dsml
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+	xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+
+	base64BinaryType = "xsd:base64Binary"
+)
+
+// value is a DSMLv2 value, which is either a string or base64 encoded binary data
+type value struct {
+	Type string `xml:"xsi:type,attr,omitempty"`
+	Data string `xml:",chardata"`
+}
+
+// UnmarshalXML reads the xsi:type attribute of the value regardless of the
+// prefix its namespace is bound to
+func (v *value) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "type" && (attr.Name.Space == xsiNamespace || attr.Name.Space == "xsi") {
+			v.Type = attr.Value
+		}
+	}
+	var data struct {
+		Data string `xml:",chardata"`
+	}
+	if err := d.DecodeElement(&data, &start); err != nil {
+		return err
+	}
+	v.Data = data.Data
+	return nil
+}
+
+// newValue returns the DSML value for b, using base64 if b is not valid UTF-8
+// or contains characters which can't be represented in XML
+func newValue(b []byte) value {
+	if !isXMLText(b) {
+		return value{Type: base64BinaryType, Data: base64.StdEncoding.EncodeToString(b)}
+	}
+	return value{Data: string(b)}
+}
+
+// bytes returns the raw data of the value
+func (v value) bytes() ([]byte, error) {
+	if strings.HasSuffix(v.Type, ":base64Binary") || v.Type == "base64Binary" {
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(v.Data))
+	}
+	return []byte(v.Data), nil
+}
+
+// newValues returns the DSML values for the given strings
+func newValues(values []string) []value {
+	var dsmlValues []value
+	for _, v := range values {
+		dsmlValues = append(dsmlValues, newValue([]byte(v)))
+	}
+	return dsmlValues
+}
+
+// stringValues returns the data of the given DSML values
+func stringValues(dsmlValues []value) ([]string, error) {
+	var values []string
+	for _, v := range dsmlValues {
+		b, err := v.bytes()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, string(b))
+	}
+	return values, nil
+}
+
+// isXMLText returns true if b is valid UTF-8 consisting of characters allowed
+// in XML character data
+func isXMLText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		switch {
+		case r == '\t' || r == '\n':
+		case r == '\r':
+			// would be normalized to \n when parsed
+			return false
+		case r < 0x20, r == 0xFFFE, r == 0xFFFF:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package ldap
+
+// This file contains extensions specific to NetIQ (formerly Novell)
+// eDirectory: the NMAS Set Password and Get Effective Privileges extended
+// operations and the eDirectory error codes reported in diagnostic messages.
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	nmasSetPasswordOID        = "2.16.840.1.113719.1.39.42.100.11"
+	getEffectivePrivilegesOID = "2.16.840.1.113719.1.27.100.33"
+	nmasLDAPExtensionVersion  = 1
+)
+
+// eDirectory error codes reported in diagnostic messages like
+// "NDS error: failed authentication (-669)"
+const (
+	EDirectoryErrorIntruderLockout       = -197
+	EDirectoryErrorDuplicatePassword     = -215
+	EDirectoryErrorPasswordTooShort      = -216
+	EDirectoryErrorMaximumLoginsExceeded = -217
+	EDirectoryErrorBadLoginTime          = -218
+	EDirectoryErrorNodeAddressViolation  = -219
+	EDirectoryErrorAccountExpired        = -220
+	EDirectoryErrorPasswordExpired       = -222
+	EDirectoryErrorPasswordExpiredGrace  = -223
+	EDirectoryErrorFailedAuthentication  = -669
+)
+
+// EDirectoryError is an error reported by eDirectory or its NMAS login
+// service. Results with such a diagnostic message carry an *EDirectoryError
+// as the underlying error of the *Error, so they can be matched with
+// errors.As or errors.Is and the sentinel errors below, e.g.
+//
+//	if errors.Is(err, ldap.ErrEDirectoryIntruderLockout) {
+//		...
+//	}
+type EDirectoryError struct {
+	// Code is the negative eDirectory or NMAS error code
+	Code int
+	// Message is the diagnostic message of the server, if any
+	Message string
+}
+
+// Sentinel errors for common eDirectory error codes
+var (
+	ErrEDirectoryIntruderLockout      = &EDirectoryError{Code: EDirectoryErrorIntruderLockout}
+	ErrEDirectoryAccountExpired       = &EDirectoryError{Code: EDirectoryErrorAccountExpired}
+	ErrEDirectoryPasswordExpired      = &EDirectoryError{Code: EDirectoryErrorPasswordExpired}
+	ErrEDirectoryFailedAuthentication = &EDirectoryError{Code: EDirectoryErrorFailedAuthentication}
+)
+
+func (e *EDirectoryError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("eDirectory error %d", e.Code)
+}
+
+// Is reports whether target is an *EDirectoryError with the same code
+func (e *EDirectoryError) Is(target error) bool {
+	t, ok := target.(*EDirectoryError)
+	return ok && t.Code == e.Code
+}
+
+// IsNMAS returns true if the error was reported by NMAS
+func (e *EDirectoryError) IsNMAS() bool {
+	// NMAS uses the codes from -1600 to -1699
+	return e.Code <= -1600 && e.Code > -1700
+}
+
+var eDirectoryDiagnosticPattern = regexp.MustCompile(`^(?:NDS|NMAS) error: .*\((-\d+)\)$`)
+
+// diagnosticError returns the underlying error for a diagnostic message,
+// an *EDirectoryError for messages of eDirectory
+func diagnosticError(message string) error {
+	if match := eDirectoryDiagnosticPattern.FindStringSubmatch(message); match != nil {
+		if code, err := strconv.Atoi(match[1]); err == nil {
+			return &EDirectoryError{Code: code, Message: message}
+		}
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// NMASSetPassword sets the Universal Password of the given object using the
+// NMAS Set Password extended operation of eDirectory. Depending on the
+// password policy, the simple and the NDS password are synchronized with it.
+// The operation should be used on TLS protected connections only.
+func (l *Conn) NMASSetPassword(objectDN, password string) error {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "NMAS Set Password Request")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(nmasLDAPExtensionVersion), "Version"))
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, objectDN, "Object DN"))
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, password, "Password"))
+
+	response, err := l.Extended(NewExtendedRequest(nmasSetPasswordOID, value.Bytes()))
+	if err != nil {
+		return err
+	}
+	if response.Value == nil {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: missing NMAS set password response"))
+	}
+	packet, err := ber.DecodePacketErr(response.Value)
+	if err != nil {
+		return NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: failed to decode NMAS set password response: %s", err))
+	}
+	if len(packet.Children) < 2 {
+		return NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid NMAS set password response"))
+	}
+	if code, _ := packet.Children[1].Value.(int64); code != 0 {
+		return &EDirectoryError{Code: int(code), Message: fmt.Sprintf("NMAS error: set password failed (%d)", code)}
+	}
+	return nil
+}
+
+// eDirectory entry rights returned by GetEffectivePrivileges for the
+// attribute name EntryRightsAttribute
+const (
+	EntryRightBrowse     = 0x01
+	EntryRightAdd        = 0x02
+	EntryRightDelete     = 0x04
+	EntryRightRename     = 0x08
+	EntryRightSupervisor = 0x10
+)
+
+// eDirectory attribute rights returned by GetEffectivePrivileges
+const (
+	AttributeRightCompare    = 0x01
+	AttributeRightRead       = 0x02
+	AttributeRightWrite      = 0x04
+	AttributeRightSelf       = 0x08
+	AttributeRightSupervisor = 0x20
+)
+
+// Pseudo attribute names for GetEffectivePrivileges
+const (
+	// EntryRightsAttribute requests the rights on the entry itself
+	EntryRightsAttribute = "[Entry Rights]"
+	// AllAttributesRightsAttribute requests the rights on all attributes
+	AllAttributesRightsAttribute = "[All Attributes Rights]"
+)
+
+// GetEffectivePrivileges returns the effective rights of trusteeDN on the
+// given attribute of objectDN as computed by eDirectory. The result is a
+// combination of the EntryRight constants for EntryRightsAttribute and of
+// the AttributeRight constants otherwise.
+func (l *Conn) GetEffectivePrivileges(objectDN, trusteeDN, attribute string) (int, error) {
+	// the fields are encoded one after another without an enclosing sequence
+	var value []byte
+	for _, field := range []string{objectDN, trusteeDN, attribute} {
+		value = append(value, ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, field, "").Bytes()...)
+	}
+
+	response, err := l.Extended(NewExtendedRequest(getEffectivePrivilegesOID, value))
+	if err != nil {
+		return 0, err
+	}
+	if response.Value == nil {
+		return 0, NewError(ErrorUnexpectedResponse, errors.New("ldap: missing effective privileges response"))
+	}
+	packet, err := ber.DecodePacketErr(response.Value)
+	if err != nil {
+		return 0, NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: failed to decode effective privileges response: %s", err))
+	}
+	if len(packet.Children) > 0 {
+		packet = packet.Children[0]
+	}
+	privileges, ok := packet.Value.(int64)
+	if !ok {
+		return 0, NewError(ErrorUnexpectedResponse, errors.New("ldap: invalid effective privileges response"))
+	}
+	return int(privileges), nil
+}
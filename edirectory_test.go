@@ -0,0 +1,150 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// respondToExtendedRequest answers an extended request with the given result
+// code and response value, returning the value of the request
+func respondToExtendedRequest(ptc *packetTranslatorConn, resultCode uint16, value *ber.Packet) ([]byte, error) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return nil, err
+	}
+	var requestValue []byte
+	if len(req.Children[1].Children) > 1 {
+		requestValue = req.Children[1].Children[1].Data.Bytes()
+	}
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	if value != nil {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extendedResponseValueTag, string(value.Bytes()), "responseValue"))
+	}
+	packet.AppendChild(response)
+	return requestValue, ptc.SendResponse(packet)
+}
+
+func TestEDirectoryError(t *testing.T) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultInvalidCredentials), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "NDS error: failed authentication (-669)", "diagnosticMessage"))
+	packet.AppendChild(response)
+
+	err := GetLDAPError(packet)
+	if !errors.Is(err, ErrInvalidCredentials) || !errors.Is(err, ErrEDirectoryFailedAuthentication) {
+		t.Errorf("expected %v to match ErrInvalidCredentials and ErrEDirectoryFailedAuthentication", err)
+	}
+	if errors.Is(err, ErrEDirectoryIntruderLockout) {
+		t.Errorf("expected %v not to match ErrEDirectoryIntruderLockout", err)
+	}
+	var edirErr *EDirectoryError
+	if !errors.As(err, &edirErr) || edirErr.Code != EDirectoryErrorFailedAuthentication || edirErr.IsNMAS() {
+		t.Errorf("unexpected eDirectory error %+v", edirErr)
+	}
+	if err.Error() != `LDAP Result Code 49 "Invalid Credentials": NDS error: failed authentication (-669)` {
+		t.Errorf("unexpected message %s", err)
+	}
+
+	if errors.As(diagnosticError("invalid credentials"), &edirErr) {
+		t.Error("expected plain error for other diagnostic messages")
+	}
+}
+
+func TestNMASSetPassword(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan []byte, 2)
+	go func() {
+		for _, code := range []int64{0, -216} {
+			value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "Version"))
+			value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, code, "Error"))
+			request, err := respondToExtendedRequest(ptc, LDAPResultSuccess, value)
+			if err != nil {
+				return
+			}
+			requests <- request
+		}
+	}()
+
+	if err := conn.NMASSetPassword("cn=jdoe,o=example", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	request := ber.DecodePacket(<-requests)
+	if len(request.Children) != 3 || request.Children[1].Value != "cn=jdoe,o=example" || request.Children[2].Value != "secret" {
+		t.Errorf("unexpected request %v", request)
+	}
+
+	err := conn.NMASSetPassword("cn=jdoe,o=example", "x")
+	var edirErr *EDirectoryError
+	if !errors.As(err, &edirErr) || edirErr.Code != EDirectoryErrorPasswordTooShort {
+		t.Errorf("expected password too short error, got %v", err)
+	}
+	<-requests
+
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	extended := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Extended Request")
+	extended.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, nmasSetPasswordOID, "Name"))
+	extended.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, "secret", "Value"))
+	packet.AppendChild(extended)
+	if value := redactPacket(packet).Children[1].Children[1].Data.String(); value != redactedPacketValue {
+		t.Errorf("expected the request value to be redacted, got %q", value)
+	}
+}
+
+func TestGetEffectivePrivileges(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan []byte, 1)
+	go func() {
+		request, err := respondToExtendedRequest(ptc, LDAPResultSuccess,
+			ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(EntryRightBrowse|EntryRightRename), "privileges"))
+		if err != nil {
+			return
+		}
+		requests <- request
+	}()
+
+	privileges, err := conn.GetEffectivePrivileges("cn=jdoe,o=example", "cn=admin,o=example", EntryRightsAttribute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if privileges != EntryRightBrowse|EntryRightRename {
+		t.Errorf("unexpected privileges %#x", privileges)
+	}
+	request := <-requests
+	var fields []string
+	for len(request) > 0 {
+		field, err := ber.DecodePacketErr(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, field.Value.(string))
+		request = request[len(field.Bytes()):]
+	}
+	if len(fields) != 3 || fields[2] != EntryRightsAttribute {
+		t.Errorf("unexpected request fields %v", fields)
+	}
+}
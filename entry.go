@@ -0,0 +1,206 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+)
+
+// MergeStrategy defines how Entry.Merge handles attributes present in both entries
+type MergeStrategy int
+
+// merge strategies
+const (
+	// MergeReplace replaces the values with those of the other entry
+	MergeReplace MergeStrategy = iota
+	// MergeAppend adds the values of the other entry which are not yet present
+	MergeAppend
+	// MergeKeep keeps the existing values
+	MergeKeep
+)
+
+// BinaryValueMode selects the string view of attributes with values which are
+// not valid UTF-8, e.g. objectGUID or jpegPhoto
+type BinaryValueMode int
+
+// binary value modes
+const (
+	// BinaryValuesOmitted leaves Values empty, the values are only
+	// available as ByteValues
+	BinaryValuesOmitted BinaryValueMode = iota
+	// BinaryValuesTransliterated reads each byte as ISO 8859-1 character,
+	// which suits text in a legacy encoding and can be reversed
+	BinaryValuesTransliterated
+	// BinaryValuesBase64 encodes the values with standard base64
+	BinaryValuesBase64
+)
+
+// binaryStringValues returns the string view of binary values
+func binaryStringValues(values [][]byte, mode BinaryValueMode) []string {
+	switch mode {
+	case BinaryValuesTransliterated:
+		strs := make([]string, len(values))
+		for i, value := range values {
+			runes := make([]rune, len(value))
+			for j, b := range value {
+				runes[j] = rune(b)
+			}
+			strs[i] = string(runes)
+		}
+		return strs
+	case BinaryValuesBase64:
+		strs := make([]string, len(values))
+		for i, value := range values {
+			strs[i] = base64.StdEncoding.EncodeToString(value)
+		}
+		return strs
+	}
+	return []string{}
+}
+
+// StringValues returns the string values of the attribute. For attributes
+// decoded with SearchRequest.LazyValues they are converted from ByteValues on
+// every call.
+func (e *EntryAttribute) StringValues() []string {
+	if e.Values != nil || e.ByteValues == nil {
+		return e.Values
+	}
+	values := make([]string, len(e.ByteValues))
+	for i, value := range e.ByteValues {
+		values[i] = string(value)
+	}
+	return values
+}
+
+// Clone returns a deep copy of the attribute which shares no slices with it
+func (e *EntryAttribute) Clone() *EntryAttribute {
+	clone := &EntryAttribute{Name: e.Name, IsBinary: e.IsBinary}
+	if e.Values != nil {
+		clone.Values = append([]string{}, e.Values...)
+	}
+	if e.ByteValues != nil {
+		clone.ByteValues = make([][]byte, len(e.ByteValues))
+		for i, value := range e.ByteValues {
+			if value != nil {
+				clone.ByteValues[i] = append([]byte{}, value...)
+			}
+		}
+	}
+	return clone
+}
+
+// Clone returns a deep copy of the entry, which can be modified without
+// affecting the original, e.g. the entries of a cached SearchResult
+func (e *Entry) Clone() *Entry {
+	clone := &Entry{DN: e.DN}
+	if e.Attributes != nil {
+		clone.Attributes = make([]*EntryAttribute, len(e.Attributes))
+		for i, attr := range e.Attributes {
+			clone.Attributes[i] = attr.Clone()
+		}
+	}
+	return clone
+}
+
+// getEqualFoldAttribute returns the named attribute or nil. Attribute matching
+// is done with strings.EqualFold.
+func (e *Entry) getEqualFoldAttribute(attribute string) *EntryAttribute {
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, attribute) {
+			return attr
+		}
+	}
+	return nil
+}
+
+// AddAttributeValue adds the value to the named attribute, creating the
+// attribute if needed. Values already present are not added again. Attribute
+// matching is done with strings.EqualFold.
+func (e *Entry) AddAttributeValue(attribute, value string) {
+	attr := e.getEqualFoldAttribute(attribute)
+	if attr == nil {
+		e.Attributes = append(e.Attributes, NewEntryAttribute(attribute, []string{value}))
+		return
+	}
+	attr.addValue(value)
+}
+
+// RemoveAttribute removes the named attribute with all its values. Attribute
+// matching is done with strings.EqualFold.
+func (e *Entry) RemoveAttribute(attribute string) {
+	attributes := e.Attributes[:0]
+	for _, attr := range e.Attributes {
+		if !strings.EqualFold(attr.Name, attribute) {
+			attributes = append(attributes, attr)
+		}
+	}
+	for i := len(attributes); i < len(e.Attributes); i++ {
+		e.Attributes[i] = nil
+	}
+	e.Attributes = attributes
+}
+
+// Merge copies the attributes of other into the entry. Attributes missing in
+// the entry are added, the strategy decides what happens to attributes present
+// in both. The DN of the entry is left unchanged and other is not modified.
+func (e *Entry) Merge(other *Entry, strategy MergeStrategy) {
+	for _, otherAttr := range other.Attributes {
+		attr := e.getEqualFoldAttribute(otherAttr.Name)
+		if attr == nil {
+			e.Attributes = append(e.Attributes, otherAttr.Clone())
+			continue
+		}
+		switch strategy {
+		case MergeReplace:
+			clone := otherAttr.Clone()
+			attr.Values, attr.ByteValues, attr.IsBinary = clone.Values, clone.ByteValues, clone.IsBinary
+		case MergeAppend:
+			if attr.IsBinary || otherAttr.IsBinary {
+				attr.addByteValues(otherAttr.ByteValues)
+				continue
+			}
+			for _, value := range otherAttr.StringValues() {
+				attr.addValue(value)
+			}
+		}
+	}
+}
+
+// addByteValues appends the values which are not yet present, turning the
+// attribute into a binary one without string view
+func (e *EntryAttribute) addByteValues(values [][]byte) {
+	if len(e.ByteValues) < len(e.Values) {
+		e.ByteValues = nil
+		for _, value := range e.Values {
+			e.ByteValues = append(e.ByteValues, []byte(value))
+		}
+	}
+	e.IsBinary, e.Values = true, []string{}
+	for _, value := range values {
+		present := false
+		for _, v := range e.ByteValues {
+			if bytes.Equal(v, value) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			e.ByteValues = append(e.ByteValues, append([]byte{}, value...))
+		}
+	}
+}
+
+// addValue appends the value unless it is already present, keeping Values and
+// ByteValues in sync
+func (e *EntryAttribute) addValue(value string) {
+	e.Values = e.StringValues()
+	for _, v := range e.Values {
+		if v == value {
+			return
+		}
+	}
+	if len(e.ByteValues) == len(e.Values) {
+		e.ByteValues = append(e.ByteValues, []byte(value))
+	}
+	e.Values = append(e.Values, value)
+}
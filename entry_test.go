@@ -0,0 +1,95 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEntryClone(t *testing.T) {
+	entry := NewEntry("cn=bob,dc=example,dc=com", map[string][]string{"cn": {"bob"}, "mail": {"bob@example.com"}})
+	clone := entry.Clone()
+	if !reflect.DeepEqual(entry, clone) {
+		t.Fatalf("clone differs: %#v", clone)
+	}
+
+	clone.Attributes[0].Values[0] = "alice"
+	clone.Attributes[0].ByteValues[0][0] = 'a'
+	clone.AddAttributeValue("mail", "alice@example.com")
+	if entry.GetAttributeValue("cn") != "bob" || string(entry.GetRawAttributeValue("cn")) != "bob" {
+		t.Errorf("modifying the clone changed the original: %v", entry)
+	}
+	if len(entry.GetAttributeValues("mail")) != 1 {
+		t.Errorf("modifying the clone changed the original: %v", entry)
+	}
+}
+
+func TestEntryAttributeMutation(t *testing.T) {
+	entry := NewEntry("cn=bob,dc=example,dc=com", map[string][]string{"cn": {"bob"}})
+	entry.AddAttributeValue("CN", "robert")
+	entry.AddAttributeValue("cn", "bob")
+	entry.AddAttributeValue("mail", "bob@example.com")
+
+	expected := &Entry{DN: "cn=bob,dc=example,dc=com", Attributes: []*EntryAttribute{
+		NewEntryAttribute("cn", []string{"bob", "robert"}),
+		NewEntryAttribute("mail", []string{"bob@example.com"}),
+	}}
+	if !reflect.DeepEqual(entry, expected) {
+		t.Errorf("got %v, want %v", entry, expected)
+	}
+
+	entry.RemoveAttribute("CN")
+	entry.RemoveAttribute("missing")
+	if len(entry.Attributes) != 1 || entry.Attributes[0].Name != "mail" {
+		t.Errorf("unexpected attributes after remove: %v", entry)
+	}
+}
+
+func TestEntryMerge(t *testing.T) {
+	base := NewEntry("cn=bob,dc=example,dc=com", map[string][]string{"cn": {"bob"}, "mail": {"a@example.com"}})
+	other := NewEntry("cn=other", map[string][]string{"mail": {"b@example.com", "a@example.com"}, "sn": {"Smith"}})
+
+	for _, tc := range []struct {
+		name     string
+		strategy MergeStrategy
+		mail     []string
+	}{
+		{name: "replace", strategy: MergeReplace, mail: []string{"b@example.com", "a@example.com"}},
+		{name: "append", strategy: MergeAppend, mail: []string{"a@example.com", "b@example.com"}},
+		{name: "keep", strategy: MergeKeep, mail: []string{"a@example.com"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := base.Clone()
+			entry.Merge(other, tc.strategy)
+
+			expected := &Entry{DN: "cn=bob,dc=example,dc=com", Attributes: []*EntryAttribute{
+				NewEntryAttribute("cn", []string{"bob"}),
+				NewEntryAttribute("mail", tc.mail),
+				NewEntryAttribute("sn", []string{"Smith"}),
+			}}
+			if !reflect.DeepEqual(entry, expected) {
+				t.Errorf("got %v, want %v", entry, expected)
+			}
+
+			// the merged values must not alias those of other
+			entry.GetRawAttributeValue("sn")[0] = 'X'
+			if other.GetAttributeValue("sn") != "Smith" || string(other.GetRawAttributeValue("sn")) != "Smith" {
+				t.Errorf("merge aliased the values of other")
+			}
+		})
+	}
+}
+
+func TestEntryMergeBinary(t *testing.T) {
+	entry := &Entry{DN: "cn=bob,dc=example,dc=com", Attributes: []*EntryAttribute{
+		NewEntryAttribute("userCertificate", []string{"text"}),
+	}}
+	other := &Entry{DN: "cn=other", Attributes: []*EntryAttribute{
+		{Name: "userCertificate", Values: []string{}, ByteValues: [][]byte{{0x30, 0x82}, []byte("text")}, IsBinary: true},
+	}}
+	entry.Merge(other, MergeAppend)
+
+	expected := &EntryAttribute{Name: "userCertificate", Values: []string{}, ByteValues: [][]byte{[]byte("text"), {0x30, 0x82}}, IsBinary: true}
+	if !reflect.DeepEqual(entry.Attributes[0], expected) {
+		t.Errorf("got %#v, want %#v", entry.Attributes[0], expected)
+	}
+}
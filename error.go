@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"errors"
 	"fmt"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
@@ -182,16 +183,90 @@ type Error struct {
 	Err error
 	// ResultCode is the LDAP error code
 	ResultCode uint16
-	// MatchedDN is the matchedDN returned if any
+	// MatchedDN is the matchedDN returned if any. On a noSuchObject result it
+	// names the closest existing ancestor of the requested entry.
 	MatchedDN string
+	// DiagnosticMessage is the diagnostic message returned by the server if any
+	DiagnosticMessage string
+	// Referrals are the referral URLs returned by the server if any
+	Referrals []string
 	// Packet is the returned packet if any
 	Packet *ber.Packet
+	// CorrelationID is the caller-supplied ID of the failed operation if any, see WithCorrelationID
+	CorrelationID string
+}
+
+// Sentinel errors for common result codes. An *Error matches a sentinel with
+// errors.Is if both have the same result code, e.g.
+//
+//	if errors.Is(err, ldap.ErrNoSuchObject) {
+//		...
+//	}
+var (
+	ErrOperationsError              = newSentinelError(LDAPResultOperationsError)
+	ErrProtocolError                = newSentinelError(LDAPResultProtocolError)
+	ErrTimeLimitExceeded            = newSentinelError(LDAPResultTimeLimitExceeded)
+	ErrSizeLimitExceeded            = newSentinelError(LDAPResultSizeLimitExceeded)
+	ErrAuthMethodNotSupported       = newSentinelError(LDAPResultAuthMethodNotSupported)
+	ErrStrongAuthRequired           = newSentinelError(LDAPResultStrongAuthRequired)
+	ErrReferral                     = newSentinelError(LDAPResultReferral)
+	ErrAdminLimitExceeded           = newSentinelError(LDAPResultAdminLimitExceeded)
+	ErrUnavailableCriticalExtension = newSentinelError(LDAPResultUnavailableCriticalExtension)
+	ErrConfidentialityRequired      = newSentinelError(LDAPResultConfidentialityRequired)
+	ErrNoSuchAttribute              = newSentinelError(LDAPResultNoSuchAttribute)
+	ErrUndefinedAttributeType       = newSentinelError(LDAPResultUndefinedAttributeType)
+	ErrConstraintViolation          = newSentinelError(LDAPResultConstraintViolation)
+	ErrAttributeOrValueExists       = newSentinelError(LDAPResultAttributeOrValueExists)
+	ErrInvalidAttributeSyntax       = newSentinelError(LDAPResultInvalidAttributeSyntax)
+	ErrNoSuchObject                 = newSentinelError(LDAPResultNoSuchObject)
+	ErrInvalidDNSyntax              = newSentinelError(LDAPResultInvalidDNSyntax)
+	ErrInappropriateAuthentication  = newSentinelError(LDAPResultInappropriateAuthentication)
+	ErrInvalidCredentials           = newSentinelError(LDAPResultInvalidCredentials)
+	ErrInsufficientAccessRights     = newSentinelError(LDAPResultInsufficientAccessRights)
+	ErrBusy                         = newSentinelError(LDAPResultBusy)
+	ErrUnavailable                  = newSentinelError(LDAPResultUnavailable)
+	ErrUnwillingToPerform           = newSentinelError(LDAPResultUnwillingToPerform)
+	ErrLoopDetect                   = newSentinelError(LDAPResultLoopDetect)
+	ErrNamingViolation              = newSentinelError(LDAPResultNamingViolation)
+	ErrObjectClassViolation         = newSentinelError(LDAPResultObjectClassViolation)
+	ErrNotAllowedOnNonLeaf          = newSentinelError(LDAPResultNotAllowedOnNonLeaf)
+	ErrNotAllowedOnRDN              = newSentinelError(LDAPResultNotAllowedOnRDN)
+	ErrEntryAlreadyExists           = newSentinelError(LDAPResultEntryAlreadyExists)
+	ErrOther                        = newSentinelError(LDAPResultOther)
+	ErrAssertionFailed              = newSentinelError(LDAPResultAssertionFailed)
+	ErrAuthorizationDenied          = newSentinelError(LDAPResultAuthorizationDenied)
+	ErrSyncRefreshRequired          = newSentinelError(LDAPResultSyncRefreshRequired)
+
+	ErrNetwork            = newSentinelError(ErrorNetwork)
+	ErrFilterCompile      = newSentinelError(ErrorFilterCompile)
+	ErrUnexpectedMessage  = newSentinelError(ErrorUnexpectedMessage)
+	ErrUnexpectedResponse = newSentinelError(ErrorUnexpectedResponse)
+	ErrEmptyPassword      = newSentinelError(ErrorEmptyPassword)
+)
+
+func newSentinelError(resultCode uint16) *Error {
+	return &Error{ResultCode: resultCode, Err: errors.New(LDAPResultCodeMap[resultCode])}
 }
 
 func (e *Error) Error() string {
+	if e.CorrelationID != "" {
+		return fmt.Sprintf("LDAP Result Code %d %q: %s (correlation ID %q)", e.ResultCode, LDAPResultCodeMap[e.ResultCode], e.Err.Error(), e.CorrelationID)
+	}
 	return fmt.Sprintf("LDAP Result Code %d %q: %s", e.ResultCode, LDAPResultCodeMap[e.ResultCode], e.Err.Error())
 }
 
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same result code, which
+// makes the sentinel errors like ErrNoSuchObject usable with errors.Is
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.ResultCode == e.ResultCode
+}
+
 // GetLDAPError creates an Error out of a BER packet representing a LDAPResult
 // The return is an error object. It can be casted to a Error structure.
 // This function returns nil if resultCode in the LDAPResult sequence is success(0).
@@ -206,15 +281,24 @@ func GetLDAPError(packet *ber.Packet) error {
 			return &Error{ResultCode: ErrorUnexpectedResponse, Err: fmt.Errorf("Empty response in packet"), Packet: packet}
 		}
 		if response.ClassType == ber.ClassApplication && response.TagType == ber.TypeConstructed && len(response.Children) >= 3 {
-			resultCode := uint16(response.Children[0].Value.(int64))
+			code, ok := response.Children[0].Value.(int64)
+			if !ok {
+				return &Error{ResultCode: ErrorUnexpectedResponse, Err: fmt.Errorf("Invalid result code in packet"), Packet: packet}
+			}
+			resultCode := uint16(code)
 			if resultCode == 0 { // No error
 				return nil
 			}
+			// the matched DN and diagnostic message are empty if malformed
+			matchedDN, _ := response.Children[1].Value.(string)
+			diagnosticMessage, _ := response.Children[2].Value.(string)
 			return &Error{
-				ResultCode: resultCode,
-				MatchedDN:  response.Children[1].Value.(string),
-				Err:        fmt.Errorf("%s", response.Children[2].Value.(string)),
-				Packet:     packet,
+				ResultCode:        resultCode,
+				MatchedDN:         matchedDN,
+				DiagnosticMessage: diagnosticMessage,
+				Referrals:         resultReferrals(response),
+				Err:               diagnosticError(diagnosticMessage),
+				Packet:            packet,
 			}
 		}
 	}
@@ -222,6 +306,22 @@ func GetLDAPError(packet *ber.Packet) error {
 	return &Error{ResultCode: ErrorNetwork, Err: fmt.Errorf("Invalid packet format"), Packet: packet}
 }
 
+// resultReferrals returns the referral URLs of an LDAPResult if any
+func resultReferrals(response *ber.Packet) []string {
+	var referrals []string
+	for _, child := range response.Children[3:] {
+		if child.ClassType != ber.ClassContext || child.Tag != ber.TagBitString {
+			continue
+		}
+		for _, uri := range child.Children {
+			if referral, ok := uri.Value.(string); ok {
+				referrals = append(referrals, referral)
+			}
+		}
+	}
+	return referrals
+}
+
 // NewError creates an LDAP error with the given code and underlying error
 func NewError(resultCode uint16, err error) error {
 	return &Error{ResultCode: resultCode, Err: err}
@@ -233,8 +333,8 @@ func IsErrorAnyOf(err error, codes ...uint16) bool {
 		return false
 	}
 
-	serverError, ok := err.(*Error)
-	if !ok {
+	var serverError *Error
+	if !errors.As(err, &serverError) {
 		return false
 	}
 
@@ -251,3 +351,62 @@ func IsErrorAnyOf(err error, codes ...uint16) bool {
 func IsErrorWithCode(err error, desiredResultCode uint16) bool {
 	return IsErrorAnyOf(err, desiredResultCode)
 }
+
+// IsTemporary returns true if the given error indicates a condition which may
+// go away when the operation is retried, e.g. a busy or unavailable server, an
+// exceeded time limit or a network error
+func IsTemporary(err error) bool {
+	return IsErrorAnyOf(err,
+		LDAPResultTimeLimitExceeded,
+		LDAPResultBusy,
+		LDAPResultUnavailable,
+		LDAPResultServerDown,
+		LDAPResultTimeout,
+		LDAPResultConnectError,
+		ErrorNetwork,
+	)
+}
+
+// IsAuthError returns true if the given error indicates that the client is not
+// authenticated or not authorized to perform the operation
+func IsAuthError(err error) bool {
+	return IsErrorAnyOf(err,
+		LDAPResultAuthMethodNotSupported,
+		LDAPResultStrongAuthRequired,
+		LDAPResultConfidentialityRequired,
+		LDAPResultInappropriateAuthentication,
+		LDAPResultInvalidCredentials,
+		LDAPResultInsufficientAccessRights,
+		LDAPResultAuthUnknown,
+		LDAPResultAuthorizationDenied,
+		ErrorEmptyPassword,
+	)
+}
+
+// noticeOfDisconnectionOID is the name of the unsolicited notification a
+// server sends before closing a connection, see RFC 4511 section 4.4.1
+const noticeOfDisconnectionOID = "1.3.6.1.4.1.1466.20036"
+
+// NoticeOfDisconnectionError is the underlying error of the *Error returned
+// for the operations pending on a connection which the server closed with a
+// Notice of Disconnection. The *Error has the result code of the notice,
+// e.g. LDAPResultUnavailable, so it can be matched with errors.As or with
+// errors.Is and the sentinel errors, e.g.
+//
+//	var notice *ldap.NoticeOfDisconnectionError
+//	if errors.As(err, &notice) {
+//		...
+//	}
+type NoticeOfDisconnectionError struct {
+	// ResultCode is the result code of the notice
+	ResultCode uint16
+	// DiagnosticMessage is the diagnostic message of the notice, if any
+	DiagnosticMessage string
+}
+
+func (e *NoticeOfDisconnectionError) Error() string {
+	if e.DiagnosticMessage != "" {
+		return "ldap: notice of disconnection: " + e.DiagnosticMessage
+	}
+	return "ldap: notice of disconnection"
+}
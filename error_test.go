@@ -2,6 +2,7 @@ package ldap
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"strings"
 	"testing"
@@ -80,6 +81,68 @@ func TestGetLDAPError(t *testing.T) {
 	if ldapError.Err.Error() != diagnosticMessage {
 		t.Errorf("Got incorrect error message in LDAP error; got %v, expected %v", ldapError.Err.Error(), diagnosticMessage)
 	}
+	if ldapError.DiagnosticMessage != diagnosticMessage {
+		t.Errorf("Got incorrect diagnostic message in LDAP error; got %v, expected %v", ldapError.DiagnosticMessage, diagnosticMessage)
+	}
+	if ldapError.MatchedDN != "dc=example,dc=org" {
+		t.Errorf("Got incorrect matched DN in LDAP error; got %v, expected %v", ldapError.MatchedDN, "dc=example,dc=org")
+	}
+}
+
+// TestGetLDAPErrorReferrals tests that referral URLs of a result are exposed on the error.
+func TestGetLDAPErrorReferrals(t *testing.T) {
+	modifyResponse := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationModifyResponse, nil, "Modify Response")
+	modifyResponse.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultReferral), "resultCode"))
+	modifyResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	modifyResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	referral := ber.Encode(ber.ClassContext, ber.TypeConstructed, ber.TagBitString, nil, "Referral")
+	referral.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "ldap://a.example.org/dc=example,dc=org", "URI"))
+	referral.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "ldap://b.example.org/dc=example,dc=org", "URI"))
+	modifyResponse.AppendChild(referral)
+	packet := ber.NewSequence("LDAPMessage")
+	packet.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(0), "messageID"))
+	packet.AppendChild(modifyResponse)
+
+	var ldapError *Error
+	if !errors.As(GetLDAPError(packet), &ldapError) {
+		t.Fatal("Did not get LDAP error response")
+	}
+	expected := []string{"ldap://a.example.org/dc=example,dc=org", "ldap://b.example.org/dc=example,dc=org"}
+	if len(ldapError.Referrals) != len(expected) || ldapError.Referrals[0] != expected[0] || ldapError.Referrals[1] != expected[1] {
+		t.Errorf("Got incorrect referrals in LDAP error; got %v, expected %v", ldapError.Referrals, expected)
+	}
+}
+
+// TestErrorIs tests matching LDAP errors against the sentinel errors.
+func TestErrorIs(t *testing.T) {
+	err := error(&Error{ResultCode: LDAPResultNoSuchObject, Err: errors.New("no such entry")})
+	if !errors.Is(err, ErrNoSuchObject) {
+		t.Errorf("expected %v to match ErrNoSuchObject", err)
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected %v not to match ErrInvalidCredentials", err)
+	}
+
+	wrapped := fmt.Errorf("modify failed: %w", err)
+	if !errors.Is(wrapped, ErrNoSuchObject) || !IsErrorWithCode(wrapped, LDAPResultNoSuchObject) {
+		t.Errorf("expected wrapped error %v to match ErrNoSuchObject", wrapped)
+	}
+	var ldapErr *Error
+	if !errors.As(wrapped, &ldapErr) || ldapErr.ResultCode != LDAPResultNoSuchObject {
+		t.Errorf("expected errors.As to find the LDAP error in %v", wrapped)
+	}
+
+	closed := errors.New("connection closed")
+	netErr := NewError(ErrorNetwork, closed)
+	if !errors.Is(netErr, closed) {
+		t.Errorf("expected %v to unwrap to the underlying error", netErr)
+	}
+	if !IsTemporary(netErr) || IsAuthError(netErr) {
+		t.Errorf("expected %v to be temporary and not an auth error", netErr)
+	}
+	if IsTemporary(ErrInvalidCredentials) || !IsAuthError(ErrInvalidCredentials) {
+		t.Errorf("expected %v to be an auth error and not temporary", ErrInvalidCredentials)
+	}
 }
 
 // TestGetLDAPErrorSuccess tests parsing of a result with no error (resultCode == 0).
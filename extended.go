@@ -0,0 +1,110 @@
+package ldap
+
+// This file contains the generic extended operation as specified in rfc 4511
+// section 4.12
+//
+// https://tools.ietf.org/html/rfc4511#section-4.12
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Context specific tags of extended responses
+const (
+	extendedResponseNameTag  = 10
+	extendedResponseValueTag = 11
+)
+
+// ExtendedRequest is an extended operation request
+type ExtendedRequest struct {
+	// Name is the OID of the operation
+	Name string
+	// Value is the encoded request value, nil if the operation has none
+	Value []byte
+	// Controls hold optional controls to send with the request
+	Controls []Control
+}
+
+// ExtendedResponse is the response to an extended operation
+type ExtendedResponse struct {
+	// Name is the OID of the response, if the server sent one
+	Name string
+	// Value is the encoded response value, nil if the server sent none
+	Value []byte
+	// Controls are the returned controls
+	Controls []Control
+}
+
+// NewExtendedRequest returns an extended request for the operation with the
+// given OID and encoded value
+func NewExtendedRequest(name string, value []byte) *ExtendedRequest {
+	return &ExtendedRequest{Name: name, Value: value}
+}
+
+func (req *ExtendedRequest) appendTo(envelope *ber.Packet) error {
+	pkt := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedRequest, nil, "Extended Request")
+	pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.Name, "Extended Request Name"))
+	if req.Value != nil {
+		pkt.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, string(req.Value), "Extended Request Value"))
+	}
+	envelope.AppendChild(pkt)
+	if len(req.Controls) > 0 {
+		envelope.AppendChild(encodeControls(req.Controls))
+	}
+	return nil
+}
+
+// Extended performs the ExtendedRequest
+func (l *Conn) Extended(extendedRequest *ExtendedRequest) (*ExtendedResponse, error) {
+	return l.ExtendedContext(context.Background(), extendedRequest)
+}
+
+// ExtendedContext performs the ExtendedRequest. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) ExtendedContext(ctx context.Context, extendedRequest *ExtendedRequest) (_ *ExtendedResponse, err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, extendedRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer l.finishMessage(msgCtx)
+
+	packet, err := l.readPacket(msgCtx)
+	if err != nil {
+		return nil, err
+	}
+	if packet.Children[1].Tag != ApplicationExtendedResponse {
+		return nil, NewError(ErrorUnexpectedResponse, fmt.Errorf("unexpected Response: %d", packet.Children[1].Tag))
+	}
+	if err := GetLDAPError(packet); err != nil {
+		return nil, err
+	}
+
+	response := &ExtendedResponse{}
+	for _, child := range packet.Children[1].Children {
+		if child.ClassType != ber.ClassContext {
+			continue
+		}
+		switch child.Tag {
+		case extendedResponseNameTag:
+			response.Name = child.Data.String()
+		case extendedResponseValueTag:
+			response.Value = child.Data.Bytes()
+		}
+	}
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			control, err := DecodeControl(child)
+			if err != nil {
+				return nil, errors.New("failed to decode child control: " + err.Error())
+			}
+			response.Controls = append(response.Controls, control)
+		}
+	}
+	return response, nil
+}
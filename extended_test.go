@@ -0,0 +1,59 @@
+package ldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestExtended(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *ber.Packet, 1)
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		requests <- req
+
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+		response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationExtendedResponse, nil, "Extended Response")
+		response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extendedResponseNameTag, "1.2.3.5", "responseName"))
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, extendedResponseValueTag, "response", "responseValue"))
+		packet.AppendChild(response)
+		packet.AppendChild(encodeControls([]Control{NewControlManageDsaIT(false)}))
+		_ = ptc.SendResponse(packet)
+	}()
+
+	req := NewExtendedRequest("1.2.3.4", []byte("request"))
+	req.Controls = []Control{NewControlManageDsaIT(true)}
+	response, err := conn.Extended(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Name != "1.2.3.5" || string(response.Value) != "response" {
+		t.Errorf("unexpected response %+v", response)
+	}
+	if FindControl(response.Controls, ControlTypeManageDsaIT) == nil {
+		t.Errorf("expected response controls, got %v", response.Controls)
+	}
+
+	sent := <-requests
+	operation := sent.Children[1]
+	if operation.Children[0].Data.String() != "1.2.3.4" || operation.Children[1].Data.String() != "request" {
+		t.Errorf("unexpected request name and value %q, %q", operation.Children[0].Data.String(), operation.Children[1].Data.String())
+	}
+	if len(sent.Children) != 3 {
+		t.Error("expected request controls")
+	}
+}
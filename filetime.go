@@ -0,0 +1,145 @@
+package ldap
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// fileTimeEpochOffset is the number of seconds between the FILETIME epoch,
+// January 1, 1601 UTC, and the Unix epoch
+const fileTimeEpochOffset = 11644473600
+
+// FileTime is an Active Directory timestamp counting 100-nanosecond
+// intervals since January 1, 1601 UTC, as used by pwdLastSet,
+// lastLogonTimestamp, accountExpires or lockoutTime. It can be used as field
+// type with Unmarshal and MarshalEntry.
+type FileTime int64
+
+// FileTimeNever is the value of accountExpires for accounts which never
+// expire. Active Directory uses 0 for this as well.
+const FileTimeNever FileTime = math.MaxInt64
+
+// NewFileTime converts t into a FileTime. The zero time and times before
+// 1601 are converted to 0.
+func NewFileTime(t time.Time) FileTime {
+	if t.IsZero() || t.Unix() < -fileTimeEpochOffset {
+		return 0
+	}
+	return FileTime((t.Unix()+fileTimeEpochOffset)*1e7 + int64(t.Nanosecond()/100))
+}
+
+// IsSet returns false for the special values 0 and FileTimeNever, which do
+// not denote a point in time. Their meaning depends on the attribute, e.g. a
+// pwdLastSet of 0 requires a password change at the next logon.
+func (f FileTime) IsSet() bool {
+	return f > 0 && f != FileTimeNever
+}
+
+// Time returns the point in time of f in UTC, or the zero time if f is not
+// set
+func (f FileTime) Time() time.Time {
+	if !f.IsSet() {
+		return time.Time{}
+	}
+	return time.Unix(int64(f)/1e7-fileTimeEpochOffset, int64(f)%1e7*100).UTC()
+}
+
+// String returns the time of f in RFC 3339 format, or "never" / "0" for
+// the special values
+func (f FileTime) String() string {
+	switch {
+	case f == FileTimeNever:
+		return "never"
+	case !f.IsSet():
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return f.Time().Format(time.RFC3339Nano)
+}
+
+// UnmarshalLDAP implements Unmarshaler
+func (f *FileTime) UnmarshalLDAP(values [][]byte) error {
+	v, err := parseADInteger(values)
+	*f = FileTime(v)
+	return err
+}
+
+// MarshalLDAP implements Marshaler
+func (f FileTime) MarshalLDAP() ([][]byte, error) {
+	return [][]byte{[]byte(strconv.FormatInt(int64(f), 10))}, nil
+}
+
+// Interval is an Active Directory duration counting 100-nanosecond
+// intervals, as used by lockoutDuration, lockOutObservationWindow,
+// maxPwdAge or minPwdAge. Active Directory stores these as negative
+// values. It can be used as field type with Unmarshal and MarshalEntry.
+type Interval int64
+
+// IntervalNever is the value of maxPwdAge or lockoutDuration meaning
+// passwords never expire or accounts stay locked until an administrator
+// unlocks them
+const IntervalNever Interval = math.MinInt64
+
+// NewInterval converts d into the negative Interval stored by Active
+// Directory
+func NewInterval(d time.Duration) Interval {
+	if d < 0 {
+		d = -d
+	}
+	return Interval(-int64(d / 100))
+}
+
+// IsNever reports whether i is IntervalNever
+func (i Interval) IsNever() bool {
+	return i == IntervalNever
+}
+
+// Duration returns the length of i. IntervalNever and intervals too long
+// for a time.Duration return the maximum duration.
+func (i Interval) Duration() time.Duration {
+	if i.IsNever() {
+		return math.MaxInt64
+	}
+	v := int64(i)
+	if v < 0 {
+		v = -v
+	}
+	if v > math.MaxInt64/100 {
+		return math.MaxInt64
+	}
+	return time.Duration(v * 100)
+}
+
+// String returns the duration of i, or "never"
+func (i Interval) String() string {
+	if i.IsNever() {
+		return "never"
+	}
+	return i.Duration().String()
+}
+
+// UnmarshalLDAP implements Unmarshaler
+func (i *Interval) UnmarshalLDAP(values [][]byte) error {
+	v, err := parseADInteger(values)
+	*i = Interval(v)
+	return err
+}
+
+// MarshalLDAP implements Marshaler
+func (i Interval) MarshalLDAP() ([][]byte, error) {
+	return [][]byte{[]byte(strconv.FormatInt(int64(i), 10))}, nil
+}
+
+// parseADInteger parses the first of the values as a 64 bit integer, an
+// attribute without values is 0
+func parseADInteger(values [][]byte) (int64, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(string(values[0]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ldap: invalid integer value '%s': %s", values[0], err)
+	}
+	return v, nil
+}
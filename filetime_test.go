@@ -0,0 +1,75 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFileTime(t *testing.T) {
+	tm := time.Date(2022, 3, 15, 10, 15, 30, 123456700, time.UTC)
+	f := NewFileTime(tm)
+	if f != 132918129301234567 {
+		t.Errorf("got %d", f)
+	}
+	if !f.IsSet() || !f.Time().Equal(tm) {
+		t.Errorf("got %s, expected %s", f.Time(), tm)
+	}
+	for _, special := range []FileTime{0, FileTimeNever} {
+		if special.IsSet() || !special.Time().IsZero() {
+			t.Errorf("%d should not be set", special)
+		}
+	}
+	if NewFileTime(time.Time{}) != 0 {
+		t.Error("zero time should be 0")
+	}
+	if FileTimeNever.String() != "never" || FileTime(0).String() != "0" {
+		t.Error("unexpected string for special values")
+	}
+}
+
+func TestInterval(t *testing.T) {
+	i := NewInterval(30 * time.Minute)
+	if i != -18000000000 {
+		t.Errorf("got %d", i)
+	}
+	if i.Duration() != 30*time.Minute || i.IsNever() {
+		t.Errorf("got %s", i.Duration())
+	}
+	if !IntervalNever.IsNever() || IntervalNever.String() != "never" {
+		t.Error("IntervalNever should be never")
+	}
+	if Interval(-9223372036854775807).Duration() <= 0 {
+		t.Error("long intervals should not overflow")
+	}
+}
+
+func TestUnmarshalADTime(t *testing.T) {
+	entry := NewEntry("cn=user", map[string][]string{
+		"pwdLastSet":      {"132918129301234567"},
+		"accountExpires":  {"9223372036854775807"},
+		"lockoutDuration": {"-18000000000"},
+	})
+	var user struct {
+		PwdLastSet      FileTime `ldap:"pwdLastSet"`
+		AccountExpires  FileTime `ldap:"accountExpires"`
+		LockoutDuration Interval `ldap:"lockoutDuration"`
+		LockoutTime     FileTime `ldap:"lockoutTime"`
+	}
+	if err := entry.Unmarshal(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.PwdLastSet.Time().Year() != 2022 || user.AccountExpires != FileTimeNever ||
+		user.LockoutDuration.Duration() != 30*time.Minute || user.LockoutTime.IsSet() {
+		t.Errorf("unexpected values %+v", user)
+	}
+
+	bad := NewEntry("cn=user", map[string][]string{"pwdLastSet": {"yesterday"}})
+	if err := bad.Unmarshal(&user); err == nil {
+		t.Error("expected error for invalid value")
+	}
+
+	values, err := user.LockoutDuration.MarshalLDAP()
+	if err != nil || string(values[0]) != "-18000000000" {
+		t.Errorf("got %s: %v", values, err)
+	}
+}
@@ -259,6 +259,7 @@ func TestInvalidFilter(t *testing.T) {
 }
 
 func BenchmarkFilterCompile(b *testing.B) {
+	b.ReportAllocs()
 	b.StopTimer()
 	filters := make([]string, len(testFilters))
 
@@ -275,6 +276,7 @@ func BenchmarkFilterCompile(b *testing.B) {
 }
 
 func BenchmarkFilterDecompile(b *testing.B) {
+	b.ReportAllocs()
 	b.StopTimer()
 	filters := make([]*ber.Packet, len(testFilters))
 
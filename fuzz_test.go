@@ -0,0 +1,130 @@
+//go:build go1.18
+// +build go1.18
+
+package ldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// searchResponseSeeds returns well-formed search responses for the fuzz
+// corpus
+func searchResponseSeeds() [][]byte {
+	entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "uid=jdoe,dc=example,dc=com", "DN"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn", "Type"))
+	values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "John Doe", "Value"))
+	attribute.AppendChild(values)
+	attributes.AppendChild(attribute)
+	op.AppendChild(attributes)
+	entry.AppendChild(op)
+
+	reference := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	reference.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	op = ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultReference, nil, "Search Result Reference")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "ldap://other.example.com/dc=example,dc=com", "URI"))
+	reference.AppendChild(op)
+
+	done := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	op = ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSizeLimitExceeded), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "size limit exceeded", "errorMessage"))
+	done.AppendChild(op)
+	controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	paging := NewControlPaging(100)
+	paging.SetCookie([]byte("cookie"))
+	controls.AppendChild(paging.Encode())
+	done.AppendChild(controls)
+
+	return [][]byte{entry.Bytes(), reference.Bytes(), done.Bytes()}
+}
+
+// decodeFuzzInput decodes data as BER packet, or returns nil. Failures of
+// the ber package itself are skipped: it panics on some malformed values,
+// which the connection reader recovers, and allocates the declared length of
+// a value before reading it, which cannot exceed the input here.
+func decodeFuzzInput(data []byte) (packet *ber.Packet) {
+	defer func(max int64) {
+		ber.MaxPacketLengthBytes = max
+		if recover() != nil {
+			packet = nil
+		}
+	}(ber.MaxPacketLengthBytes)
+	ber.MaxPacketLengthBytes = int64(len(data))
+	packet, err := ber.DecodePacketErr(data)
+	if err != nil {
+		return nil
+	}
+	return packet
+}
+
+func FuzzDecodeSearchResponse(f *testing.F) {
+	for _, seed := range searchResponseSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet := decodeFuzzInput(data)
+		if packet == nil {
+			return
+		}
+		// the decoders must report malformed responses instead of panicking
+		if entry, err := decodeEntry(packet, valueDecoding{binary: BinaryValueMode(len(data) % 3), lazy: len(data)%2 == 0}); err == nil && entry == nil {
+			t.Error("decodeEntry returned neither an entry nor an error")
+		}
+		_, _ = decodeReference(packet)
+		_ = GetLDAPError(packet)
+		_, _ = decodeResponseControls(packet)
+	})
+}
+
+func FuzzDecodeControl(f *testing.F) {
+	syncRequest := NewControlSyncRequest(SyncModeRefreshAndPersist, []byte("cookie"), true)
+	for _, control := range []Control{
+		NewControlPaging(100),
+		NewControlBeheraPasswordPolicy(),
+		NewControlMicrosoftDirSync(DirSyncObjectSecurity, 1000, []byte("cookie")),
+		NewControlMicrosoftExtendedDN(true),
+		NewControlManageDsaIT(true),
+		syncRequest,
+	} {
+		f.Add(control.Encode().Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet := decodeFuzzInput(data)
+		if packet == nil {
+			return
+		}
+		if control, err := DecodeControl(packet); err == nil && control == nil {
+			t.Error("DecodeControl returned neither a control nor an error")
+		}
+	})
+}
+
+func FuzzDecodePacket(f *testing.F) {
+	for _, seed := range searchResponseSeeds() {
+		f.Add(seed)
+	}
+	f.Add([]byte{0x30, 0x80, 0x02, 0x01, 0x01, 0x30, 0x80, 0x04, 0x01, 'a', 0x00, 0x00, 0x00, 0x00})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// decodePacket must decode the packets the ber package accepts the
+		// same way, and shares its panics on malformed REAL values
+		want := decodeFuzzInput(data)
+		if want == nil {
+			return
+		}
+		got, err := decodePacket(data)
+		if err != nil {
+			t.Fatalf("decodePacket failed on a valid packet: %v", err)
+		}
+		assertSamePacket(t, want, got, "packet")
+	})
+}
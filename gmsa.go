@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrInvalidManagedPassword is returned when a msDS-ManagedPassword value
+// could not be parsed
+var ErrInvalidManagedPassword = errors.New("ldap: invalid msDS-ManagedPassword blob")
+
+// ManagedPassword holds the passwords of a group managed service account,
+// parsed from the MSDS-MANAGEDPASSWORD_BLOB structure of the
+// msDS-ManagedPassword attribute
+// (https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/a9019740-3d73-46ef-a9ae-3ea8eb86ac2e).
+// The attribute is only returned over encrypted connections to principals
+// allowed by msDS-GroupMSAMembership. It can be used as field type with
+// Unmarshal.
+type ManagedPassword struct {
+	// CurrentPassword is the current password as UTF-16LE without the
+	// terminating null character, the form from which keys and the NT hash
+	// are derived
+	CurrentPassword []byte
+	// PreviousPassword is the password before the last change, or nil
+	PreviousPassword []byte
+	// QueryPasswordInterval is the time until the current password expires
+	QueryPasswordInterval time.Duration
+	// UnchangedPasswordInterval is the time after which the password may be
+	// changed again
+	UnchangedPasswordInterval time.Duration
+}
+
+// ParseManagedPassword parses a MSDS-MANAGEDPASSWORD_BLOB
+func ParseManagedPassword(b []byte) (*ManagedPassword, error) {
+	if len(b) < 16 || binary.LittleEndian.Uint16(b) != 1 {
+		return nil, ErrInvalidManagedPassword
+	}
+	length := int(binary.LittleEndian.Uint32(b[4:]))
+	if length < 16 || length > len(b) {
+		return nil, ErrInvalidManagedPassword
+	}
+	b = b[:length]
+	currentOffset := int(binary.LittleEndian.Uint16(b[8:]))
+	previousOffset := int(binary.LittleEndian.Uint16(b[10:]))
+	queryOffset := int(binary.LittleEndian.Uint16(b[12:]))
+	unchangedOffset := int(binary.LittleEndian.Uint16(b[14:]))
+
+	p := &ManagedPassword{}
+	var err error
+	if p.CurrentPassword, err = readUTF16String(b, currentOffset); err != nil {
+		return nil, err
+	}
+	if previousOffset != 0 {
+		if p.PreviousPassword, err = readUTF16String(b, previousOffset); err != nil {
+			return nil, err
+		}
+	}
+	if p.QueryPasswordInterval, err = readInterval(b, queryOffset); err != nil {
+		return nil, err
+	}
+	if p.UnchangedPasswordInterval, err = readInterval(b, unchangedOffset); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CurrentPasswordString returns the current password decoded from UTF-16.
+// Generated passwords are random and may contain invalid UTF-16, which is
+// replaced by U+FFFD; use CurrentPassword to derive keys.
+func (p *ManagedPassword) CurrentPasswordString() string {
+	return decodeUTF16LE(p.CurrentPassword)
+}
+
+// UnmarshalLDAP implements Unmarshaler
+func (p *ManagedPassword) UnmarshalLDAP(values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	parsed, err := ParseManagedPassword(values[0])
+	if err != nil {
+		return err
+	}
+	*p = *parsed
+	return nil
+}
+
+// readUTF16String reads the null-terminated UTF-16LE string at offset
+func readUTF16String(b []byte, offset int) ([]byte, error) {
+	if offset < 16 || offset >= len(b) {
+		return nil, ErrInvalidManagedPassword
+	}
+	for i := offset; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return append([]byte(nil), b[offset:i]...), nil
+		}
+	}
+	return nil, ErrInvalidManagedPassword
+}
+
+func readInterval(b []byte, offset int) (time.Duration, error) {
+	if offset < 16 || offset+8 > len(b) {
+		return 0, ErrInvalidManagedPassword
+	}
+	return Interval(binary.LittleEndian.Uint64(b[offset:])).Duration(), nil
+}
+
+func decodeUTF16LE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
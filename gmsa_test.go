@@ -0,0 +1,67 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// buildManagedPassword encodes a MSDS-MANAGEDPASSWORD_BLOB
+func buildManagedPassword(current, previous string, query, unchanged time.Duration) []byte {
+	// null-terminated UTF-16LE, reusing the encoding of quoted passwords
+	encode := func(s string) []byte {
+		quoted := []byte(EncodeADPassword(s))
+		return append(quoted[2:len(quoted)-2], 0, 0)
+	}
+	data := encode(current)
+	previousOffset := 0
+	if previous != "" {
+		previousOffset = 16 + len(data)
+		data = append(data, encode(previous)...)
+	}
+	for len(data)%8 != 0 {
+		data = append(data, 0)
+	}
+	queryOffset := 16 + len(data)
+	data = append(data, make([]byte, 16)...)
+	binary.LittleEndian.PutUint64(data[queryOffset-16:], uint64(query/100))
+	binary.LittleEndian.PutUint64(data[queryOffset-8:], uint64(unchanged/100))
+
+	b := make([]byte, 16, 16+len(data))
+	binary.LittleEndian.PutUint16(b, 1)
+	binary.LittleEndian.PutUint32(b[4:], uint32(16+len(data)))
+	binary.LittleEndian.PutUint16(b[8:], 16)
+	binary.LittleEndian.PutUint16(b[10:], uint16(previousOffset))
+	binary.LittleEndian.PutUint16(b[12:], uint16(queryOffset))
+	binary.LittleEndian.PutUint16(b[14:], uint16(queryOffset+8))
+	return append(b, data...)
+}
+
+func TestParseManagedPassword(t *testing.T) {
+	blob := buildManagedPassword("current", "previous", 30*24*time.Hour, 24*time.Hour)
+	p, err := ParseManagedPassword(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CurrentPasswordString() != "current" || decodeUTF16LE(p.PreviousPassword) != "previous" {
+		t.Errorf("unexpected passwords %q %q", p.CurrentPassword, p.PreviousPassword)
+	}
+	if !bytes.Equal(p.CurrentPassword, []byte("c\x00u\x00r\x00r\x00e\x00n\x00t\x00")) {
+		t.Errorf("unexpected raw password %x", p.CurrentPassword)
+	}
+	if p.QueryPasswordInterval != 30*24*time.Hour || p.UnchangedPasswordInterval != 24*time.Hour {
+		t.Errorf("unexpected intervals %s %s", p.QueryPasswordInterval, p.UnchangedPasswordInterval)
+	}
+
+	p, err = ParseManagedPassword(buildManagedPassword("current", "", time.Hour, time.Hour))
+	if err != nil || p.PreviousPassword != nil {
+		t.Errorf("expected no previous password: %v", err)
+	}
+
+	for _, invalid := range [][]byte{nil, blob[:15], blob[:len(blob)-1], append([]byte{2}, blob[1:]...)} {
+		if _, err := ParseManagedPassword(invalid); err != ErrInvalidManagedPassword {
+			t.Errorf("expected ErrInvalidManagedPassword, got %v", err)
+		}
+	}
+}
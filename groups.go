@@ -0,0 +1,151 @@
+package ldap
+
+import (
+	"errors"
+	"strings"
+)
+
+// MatchingRuleInChain is the LDAP_MATCHING_RULE_IN_CHAIN extensible match
+// rule of Active Directory, which follows linked attributes like member
+// transitively
+const MatchingRuleInChain = "1.2.840.113556.1.4.1941"
+
+// groupMembersPagingSize is the page size of the searches for nested
+// members on Active Directory
+const groupMembersPagingSize = 1000
+
+// IsMemberOf reports whether the entry with memberDN is a member of the
+// group with groupDN. If nested is true, memberships through nested groups
+// count as well; Active Directory evaluates them with MatchingRuleInChain,
+// for other servers the group is expanded with GetGroupMembers.
+func (l *Conn) IsMemberOf(memberDN, groupDN string, nested bool) (bool, error) {
+	if nested {
+		ad, err := l.isActiveDirectory()
+		if err != nil {
+			return false, err
+		}
+		if !ad {
+			members, err := l.GetGroupMembers(groupDN, true)
+			if err != nil {
+				return false, err
+			}
+			for _, member := range members {
+				if equalDN(member, memberDN) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	}
+
+	filter := "(member=" + EscapeFilter(memberDN) + ")"
+	if nested {
+		filter = "(member:" + MatchingRuleInChain + ":=" + EscapeFilter(memberDN) + ")"
+	}
+	_, err := l.SearchOne(NewSearchRequest(
+		groupDN, ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		filter, []string{"1.1"}, nil,
+	))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrEntryNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// GetGroupMembers returns the DNs of the members of the group with groupDN.
+// If nested is true, the members of nested groups are included as well,
+// along with the nested groups themselves; Active Directory resolves them
+// with MatchingRuleInChain in the default naming context, for other servers
+// every member is read to expand groups, skipping groups already expanded
+// in case of cycles.
+func (l *Conn) GetGroupMembers(groupDN string, nested bool) ([]string, error) {
+	if !nested {
+		return l.readGroupMembers(groupDN)
+	}
+	ad, err := l.isActiveDirectory()
+	if err != nil {
+		return nil, err
+	}
+	if ad {
+		return l.searchNestedMembers(groupDN)
+	}
+
+	var members []string
+	seen := map[string]bool{normalizeDN(groupDN): true}
+	queue := []string{groupDN}
+	for len(queue) > 0 {
+		dn := queue[0]
+		queue = queue[1:]
+		direct, err := l.readGroupMembers(dn)
+		if errors.Is(err, ErrNoSuchObject) || errors.Is(err, ErrEntryNotFound) {
+			// members may refer to entries which do not exist anymore
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range direct {
+			key := normalizeDN(member)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			members = append(members, member)
+			queue = append(queue, member)
+		}
+	}
+	return members, nil
+}
+
+func (l *Conn) readGroupMembers(groupDN string) ([]string, error) {
+	entry, err := l.GetEntry(groupDN, "member")
+	if err != nil {
+		return nil, err
+	}
+	return entry.GetEqualFoldAttributeValues("member"), nil
+}
+
+func (l *Conn) searchNestedMembers(groupDN string) ([]string, error) {
+	rootDSE, err := l.RootDSE()
+	if err != nil {
+		return nil, err
+	}
+	result, err := l.SearchWithPaging(NewSearchRequest(
+		rootDSE.DefaultNamingContext, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false,
+		"(memberOf:"+MatchingRuleInChain+":="+EscapeFilter(groupDN)+")", []string{"1.1"}, nil,
+	), groupMembersPagingSize)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		members = append(members, entry.DN)
+	}
+	return members, nil
+}
+
+func (l *Conn) isActiveDirectory() (bool, error) {
+	rootDSE, err := l.RootDSE()
+	if err != nil {
+		return false, err
+	}
+	return rootDSE.IsActiveDirectory(), nil
+}
+
+// normalizeDN returns a key for comparing DNs case-insensitively and
+// ignoring insignificant spaces. Invalid DNs are only lowercased.
+func normalizeDN(dn string) string {
+	parsed, err := ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+	return strings.ToLower(parsed.String())
+}
+
+func equalDN(a, b string) bool {
+	return normalizeDN(a) == normalizeDN(b)
+}
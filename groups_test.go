@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+// respondFromDirectory answers n base searches with the entry of the
+// searched DN, and the filters of the searches are sent to filters
+func respondFromDirectory(ptc *packetTranslatorConn, directory map[string]*Entry, n int, filters chan<- string) {
+	for i := 0; i < n; i++ {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		search := req.Children[1]
+		if filters != nil {
+			filter, _ := DecompileFilter(search.Children[6])
+			filters <- filter
+		}
+		var entries []*Entry
+		if entry, ok := directory[search.Children[0].Value.(string)]; ok {
+			entries = append(entries, entry)
+		}
+		resultCode := uint16(LDAPResultSuccess)
+		if len(entries) == 0 {
+			resultCode = LDAPResultNoSuchObject
+		}
+		sendSearchResult(ptc, req.Children[0].Value.(int64), resultCode, entries...)
+	}
+}
+
+func TestGetGroupMembersNested(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	directory := map[string]*Entry{
+		"":           NewEntry("", map[string][]string{"vendorName": {"test"}}),
+		"cn=a,dc=ex": NewEntry("cn=a,dc=ex", map[string][]string{"member": {"cn=b,dc=ex", "cn=u1,dc=ex"}}),
+		// cn=b contains cn=a again, which must not be expanded twice
+		"cn=b,dc=ex":  NewEntry("cn=b,dc=ex", map[string][]string{"member": {"CN=A, DC=ex", "cn=u2,dc=ex", "cn=gone,dc=ex"}}),
+		"cn=u1,dc=ex": NewEntry("cn=u1,dc=ex", nil),
+		"cn=u2,dc=ex": NewEntry("cn=u2,dc=ex", nil),
+	}
+	go respondFromDirectory(ptc, directory, 6, nil)
+
+	members, err := conn.GetGroupMembers("cn=a,dc=ex", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cn=b,dc=ex", "cn=u1,dc=ex", "cn=u2,dc=ex", "cn=gone,dc=ex"}
+	if !reflect.DeepEqual(members, expected) {
+		t.Errorf("got %v, expected %v", members, expected)
+	}
+
+	go respondFromDirectory(ptc, directory, 4, nil)
+	member, err := conn.IsMemberOf("cn=u2,dc=ex", "cn=b,dc=ex", true)
+	if err != nil || !member {
+		t.Errorf("expected nested membership: %v", err)
+	}
+}
+
+func TestIsMemberOfActiveDirectory(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	directory := map[string]*Entry{
+		"":           NewEntry("", map[string][]string{"supportedCapabilities": {"1.2.840.113556.1.4.800"}}),
+		"cn=g,dc=ex": NewEntry("cn=g,dc=ex", nil),
+	}
+	filters := make(chan string, 2)
+	go respondFromDirectory(ptc, directory, 2, filters)
+
+	member, err := conn.IsMemberOf("cn=u,dc=ex", "cn=g,dc=ex", true)
+	if err != nil || !member {
+		t.Errorf("expected membership: %v", err)
+	}
+	<-filters
+	if filter := <-filters; filter != "(member:1.2.840.113556.1.4.1941:=cn=u,dc=ex)" {
+		t.Errorf("unexpected filter %s", filter)
+	}
+
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		sendSearchResult(ptc, req.Children[0].Value.(int64), LDAPResultSuccess)
+	}()
+	member, err = conn.IsMemberOf("cn=u,dc=ex", "cn=g,dc=ex", false)
+	if err != nil || member {
+		t.Errorf("expected no membership: %v", err)
+	}
+}
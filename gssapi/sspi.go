@@ -12,7 +12,11 @@ import (
 	"github.com/alexbrainman/sspi/kerberos"
 )
 
-// SSPIClient implements ldap.GSSAPIClient interface.
+// kerbWrapNoEncrypt (KERB_WRAP_NO_ENCRYPT, SECQOP_WRAP_NO_ENCRYPT) indicates
+// Wrap and Unwrap should only sign & verify (not encrypt & decrypt).
+const kerbWrapNoEncrypt = 0x80000001
+
+// SSPIClient implements ldap.GSSAPIClient and ldap.GSSAPIWrapper interfaces.
 // Depends on secur32.dll.
 type SSPIClient struct {
 	creds *sspi.Credentials
@@ -103,16 +107,12 @@ func (c *SSPIClient) NegotiateSaslAuth(token []byte, authzid string) ([]byte, er
 	// Using SSPI rather than of GSSAPI, relevant documentation of differences here:
 	// https://learn.microsoft.com/en-us/windows/win32/secauthn/sspi-kerberos-interoperability-with-gssapi
 
-	// KERB_WRAP_NO_ENCRYPT (SECQOP_WRAP_NO_ENCRYPT) flag indicates Wrap and Unwrap
-	// should only sign & verify (not encrypt & decrypt).
-	const KERB_WRAP_NO_ENCRYPT = 0x80000001
-
 	// https://learn.microsoft.com/en-us/windows/win32/api/sspi/nf-sspi-decryptmessage
 	flags, inputPayload, err := c.ctx.DecryptMessage(token, 0)
 	if err != nil {
 		return nil, fmt.Errorf("error decrypting message: %w", err)
 	}
-	if flags&KERB_WRAP_NO_ENCRYPT == 0 {
+	if flags&kerbWrapNoEncrypt == 0 {
 		// Encrypted message, this is unexpected.
 		return nil, fmt.Errorf("message encrypted")
 	}
@@ -148,7 +148,7 @@ func (c *SSPIClient) NegotiateSaslAuth(token []byte, authzid string) ([]byte, er
 	}
 
 	// https://learn.microsoft.com/en-us/windows/win32/api/sspi/nf-sspi-encryptmessage
-	inputPayload, err = c.ctx.EncryptMessage(handshakePayload(byte(selectedSec), maxSecMsgSize, []byte(authzid)), KERB_WRAP_NO_ENCRYPT, 0)
+	inputPayload, err = c.ctx.EncryptMessage(handshakePayload(byte(selectedSec), maxSecMsgSize, []byte(authzid)), kerbWrapNoEncrypt, 0)
 	if err != nil {
 		return nil, fmt.Errorf("error encrypting message: %w", err)
 	}
@@ -156,6 +156,28 @@ func (c *SSPIClient) NegotiateSaslAuth(token []byte, authzid string) ([]byte, er
 	return inputPayload, nil
 }
 
+// Wrap signs message with the established security context and encrypts it
+// if confidential is true.
+func (c *SSPIClient) Wrap(message []byte, confidential bool) ([]byte, error) {
+	var qop uint32 = kerbWrapNoEncrypt
+	if confidential {
+		qop = 0
+	}
+	// EncryptMessage works in place
+	msg := make([]byte, len(message))
+	copy(msg, message)
+	return c.ctx.EncryptMessage(msg, qop, 0)
+}
+
+// Unwrap verifies and decrypts token with the established security context.
+func (c *SSPIClient) Unwrap(token []byte) ([]byte, bool, error) {
+	flags, message, err := c.ctx.DecryptMessage(token, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return message, flags&kerbWrapNoEncrypt == 0, nil
+}
+
 func handshakePayload(secLayer byte, maxSize uint32, authzid []byte) []byte {
 	// construct payload and send unencrypted:
 	// 		"The client then constructs data, with the first octet containing the
@@ -0,0 +1,99 @@
+package ldap
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidGUID is returned when a GUID could not be parsed
+var ErrInvalidGUID = errors.New("ldap: invalid GUID")
+
+// GUID is a globally unique identifier in the binary layout used by the
+// objectGUID attribute of Active Directory, where the first three fields
+// are stored little-endian. It can be used as field type with Unmarshal and
+// MarshalEntry.
+type GUID [16]byte
+
+// guidByteOrder maps the positions of the canonical string form to the
+// binary layout
+var guidByteOrder = [16]int{3, 2, 1, 0, 5, 4, 7, 6, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// ParseGUID parses the binary representation of a GUID
+func ParseGUID(b []byte) (GUID, error) {
+	var g GUID
+	if len(b) != len(g) {
+		return g, ErrInvalidGUID
+	}
+	copy(g[:], b)
+	return g, nil
+}
+
+// ParseGUIDString parses the canonical string form of a GUID, e.g.
+// "e6c5b2b3-c2a1-4ed3-8f9a-0b1c2d3e4f50", optionally enclosed in braces
+func ParseGUIDString(s string) (GUID, error) {
+	var g GUID
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return g, ErrInvalidGUID
+	}
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil {
+		return g, ErrInvalidGUID
+	}
+	for i, pos := range guidByteOrder {
+		g[pos] = b[i]
+	}
+	return g, nil
+}
+
+// String returns the canonical string form of the GUID
+func (g GUID) String() string {
+	var b [16]byte
+	for i, pos := range guidByteOrder {
+		b[i] = g[pos]
+	}
+	s := hex.EncodeToString(b[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// Bytes returns the binary representation of the GUID
+func (g GUID) Bytes() []byte {
+	return append([]byte(nil), g[:]...)
+}
+
+// FilterValue returns the GUID escaped for use in a search filter, e.g.
+// "(objectGUID=" + g.FilterValue() + ")"
+func (g GUID) FilterValue() string {
+	var b strings.Builder
+	for _, v := range g {
+		b.WriteByte('\\')
+		b.WriteString(hex.EncodeToString([]byte{v}))
+	}
+	return b.String()
+}
+
+// IsZero returns true if all bytes of the GUID are zero
+func (g GUID) IsZero() bool {
+	return g == GUID{}
+}
+
+// UnmarshalLDAP implements Unmarshaler, reading the first binary value
+func (g *GUID) UnmarshalLDAP(values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	guid, err := ParseGUID(values[0])
+	if err != nil {
+		return err
+	}
+	*g = guid
+	return nil
+}
+
+// MarshalLDAP implements Marshaler
+func (g GUID) MarshalLDAP() ([][]byte, error) {
+	return [][]byte{g.Bytes()}, nil
+}
@@ -0,0 +1,49 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGUID(t *testing.T) {
+	b := []byte{0xb3, 0xb2, 0xc5, 0xe6, 0xa1, 0xc2, 0xd3, 0x4e, 0x8f, 0x9a, 0x0b, 0x1c, 0x2d, 0x3e, 0x4f, 0x50}
+	g, err := ParseGUID(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "e6c5b2b3-c2a1-4ed3-8f9a-0b1c2d3e4f50"
+	if g.String() != expected {
+		t.Errorf("got %s, expected %s", g, expected)
+	}
+	if !bytes.Equal(g.Bytes(), b) {
+		t.Errorf("got %x, expected %x", g.Bytes(), b)
+	}
+	if f := g.FilterValue(); f != `\b3\b2\c5\e6\a1\c2\d3\4e\8f\9a\0b\1c\2d\3e\4f\50` {
+		t.Errorf("got filter value %s", f)
+	}
+	if _, err := CompileFilter("(objectGUID=" + g.FilterValue() + ")"); err != nil {
+		t.Errorf("filter value does not compile: %s", err)
+	}
+
+	for _, s := range []string{expected, "{E6C5B2B3-C2A1-4ED3-8F9A-0B1C2D3E4F50}"} {
+		if parsed, err := ParseGUIDString(s); err != nil || parsed != g {
+			t.Errorf("%s: got %s: %v", s, parsed, err)
+		}
+	}
+	for _, invalid := range []string{"", "e6c5b2b3c2a14ed38f9a0b1c2d3e4f50", "e6c5b2b3-c2a1-4ed3-8f9a-0b1c2d3e4fzz"} {
+		if _, err := ParseGUIDString(invalid); err != ErrInvalidGUID {
+			t.Errorf("expected ErrInvalidGUID for %q, got %v", invalid, err)
+		}
+	}
+	if _, err := ParseGUID(b[:15]); err != ErrInvalidGUID {
+		t.Errorf("expected ErrInvalidGUID, got %v", err)
+	}
+
+	var user struct {
+		GUID GUID `ldap:"objectGUID"`
+	}
+	entry := &Entry{Attributes: []*EntryAttribute{{Name: "objectGUID", Values: []string{string(b)}, ByteValues: [][]byte{b}}}}
+	if err := entry.Unmarshal(&user); err != nil || user.GUID != g {
+		t.Errorf("got %s: %v", user.GUID, err)
+	}
+}
@@ -0,0 +1,157 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoLAPSPassword is returned by GetLAPSPassword if the computer has no
+// LAPS password readable by the bound user
+var ErrNoLAPSPassword = errors.New("ldap: no LAPS password found")
+
+// LAPSPassword is the local administrator password of a computer managed by
+// legacy Microsoft LAPS or Windows LAPS
+type LAPSPassword struct {
+	// Account is the name of the managed account, empty for legacy LAPS
+	Account  string
+	Password string
+	// UpdateTime is when the password was set, zero for legacy LAPS
+	UpdateTime time.Time
+	// ExpirationTime is when the password is rotated next, zero if unknown
+	ExpirationTime time.Time
+}
+
+// lapsPasswordJSON is the JSON form of Windows LAPS passwords, see
+// https://learn.microsoft.com/en-us/windows-server/identity/laps/laps-technical-reference
+type lapsPasswordJSON struct {
+	Account    string `json:"n"`
+	UpdateTime string `json:"t"`
+	Password   string `json:"p"`
+}
+
+// ParseLAPSPasswordJSON parses the JSON value of the msLAPS-Password
+// attribute, which also is the plaintext of msLAPS-EncryptedPassword
+func ParseLAPSPasswordJSON(b []byte) (*LAPSPassword, error) {
+	var v lapsPasswordJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("ldap: invalid LAPS password: %w", err)
+	}
+	p := &LAPSPassword{Account: v.Account, Password: v.Password}
+	if v.UpdateTime != "" {
+		// the update time is a FILETIME in hexadecimal
+		t, err := strconv.ParseInt(v.UpdateTime, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid LAPS password update time '%s'", v.UpdateTime)
+		}
+		p.UpdateTime = FileTime(t).Time()
+	}
+	return p, nil
+}
+
+// LAPSEncryptedPassword is the value of the msLAPS-EncryptedPassword
+// attribute of Windows LAPS
+type LAPSEncryptedPassword struct {
+	UpdateTime time.Time
+	Flags      uint32
+	// EncryptedPassword is the CMS enveloped password, encrypted with
+	// DPAPI-NG for the authorized principal
+	EncryptedPassword []byte
+}
+
+// ParseLAPSEncryptedPassword parses the header of a msLAPS-EncryptedPassword
+// value
+func ParseLAPSEncryptedPassword(b []byte) (*LAPSEncryptedPassword, error) {
+	if len(b) < 16 {
+		return nil, errors.New("ldap: invalid LAPS encrypted password")
+	}
+	updateTime := int64(binary.LittleEndian.Uint32(b))<<32 | int64(binary.LittleEndian.Uint32(b[4:]))
+	size := int(binary.LittleEndian.Uint32(b[8:]))
+	if size > len(b)-16 {
+		return nil, errors.New("ldap: invalid LAPS encrypted password")
+	}
+	return &LAPSEncryptedPassword{
+		UpdateTime:        FileTime(updateTime).Time(),
+		Flags:             binary.LittleEndian.Uint32(b[12:]),
+		EncryptedPassword: append([]byte(nil), b[16:16+size]...),
+	}, nil
+}
+
+// Decrypt decrypts the password with the given function, which has to
+// implement the DPAPI-NG decryption of the CMS enveloped data, and parses
+// the resulting UTF-16LE JSON
+func (e *LAPSEncryptedPassword) Decrypt(decrypt func(encrypted []byte) ([]byte, error)) (*LAPSPassword, error) {
+	plaintext, err := decrypt(e.EncryptedPassword)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseLAPSPasswordJSON([]byte(strings.TrimRight(decodeUTF16LE(plaintext), "\x00")))
+	if err != nil {
+		return nil, err
+	}
+	if p.UpdateTime.IsZero() {
+		p.UpdateTime = e.UpdateTime
+	}
+	return p, nil
+}
+
+var lapsAttributes = []string{
+	"msLAPS-EncryptedPassword", "msLAPS-Password", "msLAPS-PasswordExpirationTime",
+	"ms-Mcs-AdmPwd", "ms-Mcs-AdmPwdExpirationTime",
+}
+
+// GetLAPSPassword reads the LAPS password of the computer with the given
+// DN. Windows LAPS passwords are preferred over legacy LAPS passwords;
+// encrypted Windows LAPS passwords are only used if decrypt is not nil, see
+// LAPSEncryptedPassword.Decrypt. ErrNoLAPSPassword is returned if no
+// password is readable.
+func (l *Conn) GetLAPSPassword(computerDN string, decrypt func(encrypted []byte) ([]byte, error)) (*LAPSPassword, error) {
+	entry, err := l.GetEntry(computerDN, lapsAttributes...)
+	if err != nil {
+		return nil, err
+	}
+	return lapsPasswordFromEntry(entry, decrypt)
+}
+
+func lapsPasswordFromEntry(entry *Entry, decrypt func([]byte) ([]byte, error)) (*LAPSPassword, error) {
+	var (
+		p          *LAPSPassword
+		expiration string
+		err        error
+	)
+	encrypted := entry.GetEqualFoldRawAttributeValue("msLAPS-EncryptedPassword")
+	switch {
+	case len(encrypted) > 0 && decrypt != nil:
+		var e *LAPSEncryptedPassword
+		if e, err = ParseLAPSEncryptedPassword(encrypted); err != nil {
+			return nil, err
+		}
+		if p, err = e.Decrypt(decrypt); err != nil {
+			return nil, err
+		}
+		expiration = entry.GetEqualFoldAttributeValue("msLAPS-PasswordExpirationTime")
+	case entry.GetEqualFoldAttributeValue("msLAPS-Password") != "":
+		if p, err = ParseLAPSPasswordJSON(entry.GetEqualFoldRawAttributeValue("msLAPS-Password")); err != nil {
+			return nil, err
+		}
+		expiration = entry.GetEqualFoldAttributeValue("msLAPS-PasswordExpirationTime")
+	case entry.GetEqualFoldAttributeValue("ms-Mcs-AdmPwd") != "":
+		p = &LAPSPassword{Password: entry.GetEqualFoldAttributeValue("ms-Mcs-AdmPwd")}
+		expiration = entry.GetEqualFoldAttributeValue("ms-Mcs-AdmPwdExpirationTime")
+	default:
+		return nil, ErrNoLAPSPassword
+	}
+
+	if expiration != "" {
+		var t FileTime
+		if err := t.UnmarshalLDAP([][]byte{[]byte(expiration)}); err != nil {
+			return nil, err
+		}
+		p.ExpirationTime = t.Time()
+	}
+	return p, nil
+}
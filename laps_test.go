@@ -0,0 +1,73 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLAPSPassword(t *testing.T) {
+	updated := time.Date(2022, 3, 15, 10, 15, 30, 0, time.UTC)
+	expires := time.Date(2022, 4, 14, 10, 15, 30, 0, time.UTC)
+	json := `{"n":"Administrator","t":"` + formatHexFileTime(updated) + `","p":"s3cret"}`
+
+	p, err := ParseLAPSPasswordJSON([]byte(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Account != "Administrator" || p.Password != "s3cret" || !p.UpdateTime.Equal(updated) {
+		t.Errorf("unexpected password %+v", p)
+	}
+
+	// the test "encryption" is the UTF-16LE encoding itself
+	plaintext := []byte(EncodeADPassword(json))
+	plaintext = append(plaintext[2:len(plaintext)-2], 0, 0)
+	encrypted := make([]byte, 16, 16+len(plaintext))
+	ft := int64(NewFileTime(updated))
+	binary.LittleEndian.PutUint32(encrypted, uint32(ft>>32))
+	binary.LittleEndian.PutUint32(encrypted[4:], uint32(ft))
+	binary.LittleEndian.PutUint32(encrypted[8:], uint32(len(plaintext)))
+	encrypted = append(encrypted, plaintext...)
+	identity := func(b []byte) ([]byte, error) { return b, nil }
+
+	expiration := []string{formatFileTime(expires)}
+	tests := []struct {
+		name     string
+		attrs    map[string][]string
+		decrypt  func([]byte) ([]byte, error)
+		password string
+		account  string
+	}{
+		{"legacy", map[string][]string{"ms-Mcs-AdmPwd": {"legacy"}, "ms-Mcs-AdmPwdExpirationTime": expiration}, nil, "legacy", ""},
+		{"windows", map[string][]string{"msLAPS-Password": {json}, "msLAPS-PasswordExpirationTime": expiration, "ms-Mcs-AdmPwd": {"legacy"}}, nil, "s3cret", "Administrator"},
+		{"encrypted", map[string][]string{"msLAPS-EncryptedPassword": {string(encrypted)}, "msLAPS-PasswordExpirationTime": expiration}, identity, "s3cret", "Administrator"},
+	}
+	for _, test := range tests {
+		p, err := lapsPasswordFromEntry(NewEntry("cn=pc", test.attrs), test.decrypt)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if p.Password != test.password || p.Account != test.account || !p.ExpirationTime.Equal(expires) {
+			t.Errorf("%s: unexpected password %+v", test.name, p)
+		}
+	}
+
+	encryptedOnly := NewEntry("cn=pc", map[string][]string{"msLAPS-EncryptedPassword": {string(encrypted)}})
+	if _, err := lapsPasswordFromEntry(encryptedOnly, nil); !errors.Is(err, ErrNoLAPSPassword) {
+		t.Errorf("expected ErrNoLAPSPassword without decrypt function, got %v", err)
+	}
+	if _, err := ParseLAPSEncryptedPassword(encrypted[:20]); err == nil {
+		t.Error("expected error for truncated encrypted password")
+	}
+}
+
+func formatHexFileTime(t time.Time) string {
+	return strconv.FormatInt(int64(NewFileTime(t)), 16)
+}
+
+func formatFileTime(t time.Time) string {
+	return strconv.FormatInt(int64(NewFileTime(t)), 10)
+}
@@ -32,6 +32,7 @@ const (
 	ApplicationSearchResultReference = 19
 	ApplicationExtendedRequest       = 23
 	ApplicationExtendedResponse      = 24
+	ApplicationIntermediateResponse  = 25
 )
 
 // ApplicationMap contains human readable descriptions of LDAP Application Codes
@@ -56,6 +57,7 @@ var ApplicationMap = map[uint8]string{
 	ApplicationSearchResultReference: "Search Result Reference",
 	ApplicationExtendedRequest:       "Extended Request",
 	ApplicationExtendedResponse:      "Extended Response",
+	ApplicationIntermediateResponse:  "Intermediate Response",
 }
 
 // Ldap Behera Password Policy Draft 10 (https://tools.ietf.org/html/draft-behera-ldap-password-policy-10)
@@ -150,6 +152,7 @@ func addLDAPDescriptions(packet *ber.Packet) (err error) {
 	case ApplicationExtendedRequest:
 		err = addRequestDescriptions(packet)
 	case ApplicationExtendedResponse:
+	case ApplicationIntermediateResponse:
 	}
 
 	return err
@@ -216,6 +219,9 @@ func addControlDescriptions(packet *ber.Packet) error {
 			value.Children[0].Children[0].Description = "Paging Size"
 			value.Children[0].Children[1].Description = "Cookie"
 
+		case ControlTypeVChuPasswordMustChange, ControlTypeVChuPasswordWarning:
+			value.Description += " (" + ControlTypeMap[controlType] + ")"
+
 		case ControlTypeBeheraPasswordPolicy:
 			value.Description += " (Password Policy - Behera Draft)"
 			if value.Value != nil {
@@ -307,12 +313,12 @@ func DebugBinaryFile(fileName string) error {
 	if err := addLDAPDescriptions(packet); err != nil {
 		return err
 	}
-	ber.PrintPacket(packet)
+	ber.PrintPacket(redactPacket(packet))
 
 	return nil
 }
 
-var hex = "0123456789abcdef"
+var hexDigits = "0123456789abcdef"
 
 func mustEscape(c byte) bool {
 	return c > 0x7f || c == '(' || c == ')' || c == '\\' || c == '*' || c == 0
@@ -336,8 +342,8 @@ func EscapeFilter(filter string) string {
 		c := filter[i]
 		if mustEscape(c) {
 			buf[j+0] = '\\'
-			buf[j+1] = hex[c>>4]
-			buf[j+2] = hex[c&0xf]
+			buf[j+1] = hexDigits[c>>4]
+			buf[j+2] = hexDigits[c&0xf]
 			j += 3
 		} else {
 			buf[j] = c
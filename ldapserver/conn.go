@@ -0,0 +1,308 @@
+package ldapserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// Conn is a client connection of a Server
+type Conn struct {
+	server *Server
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// writeMu serializes the responses of concurrent operations
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	conn    net.Conn
+	bindDN  string
+	active  int
+	closing bool
+	cancels map[int64]context.CancelFunc
+
+	// ops are the operations running in the background
+	ops sync.WaitGroup
+}
+
+func newConn(server *Server, conn net.Conn) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Conn{
+		server:  server,
+		ctx:     ctx,
+		cancel:  cancel,
+		conn:    conn,
+		cancels: make(map[int64]context.CancelFunc),
+	}
+}
+
+// RemoteAddr returns the address of the client
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.netConn().RemoteAddr()
+}
+
+// BoundDN returns the name the connection is bound to, or an empty string
+// if it is anonymous
+func (c *Conn) BoundDN() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bindDN
+}
+
+// TLSConnectionState returns the state of the TLS connection, established
+// by StartTLS or the listener, and whether there is one
+func (c *Conn) TLSConnectionState() (tls.ConnectionState, bool) {
+	if tlsConn, ok := c.netConn().(*tls.Conn); ok {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+func (c *Conn) netConn() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// serve reads and dispatches the requests of the connection until it is
+// closed or the client unbinds
+func (c *Conn) serve() {
+	defer func() {
+		c.close()
+		c.ops.Wait()
+		c.server.log(ldap.LogLevelDebug, "connection closed", "remote", c.RemoteAddr())
+	}()
+
+	r := bufio.NewReader(c.netConn())
+	for {
+		packet, err := ber.ReadPacket(r)
+		if err != nil {
+			return
+		}
+		if !c.begin() {
+			return
+		}
+		messageID, op, controls, err := decodeMessage(packet)
+		if err != nil {
+			// the message ID may be unknown, so the request cannot be
+			// answered
+			c.server.log(ldap.LogLevelWarn, "malformed request", "remote", c.RemoteAddr(), "error", err)
+			c.end()
+			return
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationUnbindRequest:
+			c.end()
+			return
+		case ldap.ApplicationAbandonRequest:
+			if id, err := decodeInt(op); err == nil {
+				c.abandon(id)
+			}
+			c.end()
+		case ldap.ApplicationBindRequest:
+			// the other operations are abandoned, RFC 4511 section 4.2.1
+			c.abandonAll()
+			c.ops.Wait()
+			c.bind(messageID, op, controls)
+			c.end()
+		case ldap.ApplicationExtendedRequest:
+			req, err := decodeExtendedRequest(op, controls)
+			if err == nil && req.Name == startTLSOID {
+				r = c.startTLS(messageID, r)
+				c.end()
+				if r == nil {
+					return
+				}
+				continue
+			}
+			c.run(messageID, func(ctx context.Context) {
+				if err != nil {
+					c.writeExtended(ctx, messageID, nil, err)
+					return
+				}
+				c.extended(ctx, messageID, req)
+			})
+		default:
+			c.run(messageID, func(ctx context.Context) {
+				c.dispatch(ctx, messageID, op, controls)
+			})
+		}
+	}
+}
+
+// decodeMessage decodes the envelope of a request
+func decodeMessage(packet *ber.Packet) (int64, *ber.Packet, []ldap.Control, error) {
+	if len(packet.Children) < 2 {
+		return 0, nil, nil, errors.New("missing message ID or protocol operation")
+	}
+	messageID, ok := packet.Children[0].Value.(int64)
+	if !ok {
+		return 0, nil, nil, errors.New("invalid message ID")
+	}
+	op := packet.Children[1]
+	if op.ClassType != ber.ClassApplication {
+		return 0, nil, nil, errors.New("invalid protocol operation")
+	}
+	var controls []ldap.Control
+	if len(packet.Children) > 2 {
+		var err error
+		if controls, err = decodeControls(packet.Children[2]); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	return messageID, op, controls, nil
+}
+
+// begin registers a request, returning false if the connection is closing
+func (c *Conn) begin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closing {
+		return false
+	}
+	c.active++
+	return true
+}
+
+// end marks a request registered with begin complete
+func (c *Conn) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+}
+
+// run calls f in the background with a context which is canceled when the
+// operation is abandoned or the connection is closed
+func (c *Conn) run(messageID int64, f func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.mu.Lock()
+	c.cancels[messageID] = cancel
+	c.mu.Unlock()
+	c.ops.Add(1)
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.cancels, messageID)
+			c.mu.Unlock()
+			cancel()
+			c.end()
+			c.ops.Done()
+		}()
+		f(ctx)
+	}()
+}
+
+func (c *Conn) abandon(messageID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.cancels[messageID]; ok {
+		cancel()
+	}
+}
+
+func (c *Conn) abandonAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cancel := range c.cancels {
+		cancel()
+	}
+}
+
+// pending returns the number of operations running in the background
+func (c *Conn) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cancels)
+}
+
+// closeIfIdle closes the connection unless operations are running and
+// returns true if it was closed
+func (c *Conn) closeIfIdle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active > 0 {
+		return false
+	}
+	if !c.closing {
+		c.closing = true
+		c.cancel()
+		c.conn.Close()
+	}
+	return true
+}
+
+// close closes the connection and cancels the running operations
+func (c *Conn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closing {
+		c.closing = true
+		c.cancel()
+		c.conn.Close()
+	}
+}
+
+// startTLS handles a StartTLS request and returns the reader for the
+// following requests, or nil if the connection failed
+func (c *Conn) startTLS(messageID int64, r *bufio.Reader) *bufio.Reader {
+	ctx := c.ctx
+	switch _, isTLS := c.TLSConnectionState(); {
+	case c.server.TLSConfig == nil:
+		c.writeExtended(ctx, messageID, nil, ldap.NewError(ldap.LDAPResultUnavailable, errors.New("StartTLS is not supported")))
+		return r
+	case isTLS:
+		c.writeExtended(ctx, messageID, nil, ldap.NewError(ldap.LDAPResultOperationsError, errors.New("TLS is already established")))
+		return r
+	case c.pending() > 0:
+		// RFC 4513 section 3.1.1
+		c.writeExtended(ctx, messageID, nil, ldap.NewError(ldap.LDAPResultOperationsError, errors.New("operations are outstanding")))
+		return r
+	case r.Buffered() > 0:
+		// the client must wait for the response before the handshake
+		c.server.log(ldap.LogLevelWarn, "request sent during StartTLS", "remote", c.RemoteAddr())
+		return nil
+	}
+	if err := c.writeExtended(ctx, messageID, &ldap.ExtendedResponse{Name: startTLSOID}, nil); err != nil {
+		return nil
+	}
+	tlsConn := tls.Server(c.netConn(), c.server.TLSConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		c.server.log(ldap.LogLevelWarn, "TLS handshake failed", "remote", c.RemoteAddr(), "error", err)
+		return nil
+	}
+	c.mu.Lock()
+	c.conn = tlsConn
+	c.mu.Unlock()
+	return bufio.NewReader(tlsConn)
+}
+
+// write sends op with the optional response controls. A connection failing
+// to write is closed.
+func (c *Conn) write(messageID int64, op *ber.Packet, controls []ldap.Control) error {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	envelope.AppendChild(op)
+	if len(controls) > 0 {
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			packet.AppendChild(control.Encode())
+		}
+		envelope.AppendChild(packet)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.netConn().Write(envelope.Bytes()); err != nil {
+		c.close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package ldapserver
+
+import (
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// protocolError returns the error for a malformed request
+func protocolError(format string, args ...interface{}) error {
+	return ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf(format, args...))
+}
+
+// decodeControls decodes the controls of a request
+func decodeControls(packet *ber.Packet) ([]ldap.Control, error) {
+	if packet == nil {
+		return nil, nil
+	}
+	var controls []ldap.Control
+	for _, child := range packet.Children {
+		control, err := ldap.DecodeControl(child)
+		if err != nil {
+			return nil, protocolError("invalid control: %s", err)
+		}
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
+
+// decodeInt decodes an integer or enumerated value
+func decodeInt(packet *ber.Packet) (int64, error) {
+	if v, ok := packet.Value.(int64); ok {
+		return v, nil
+	}
+	return ber.ParseInt64(packet.Data.Bytes())
+}
+
+// decodeBool decodes a boolean value
+func decodeBool(packet *ber.Packet) (bool, error) {
+	if v, ok := packet.Value.(bool); ok {
+		return v, nil
+	}
+	data := packet.Data.Bytes()
+	if len(data) != 1 {
+		return false, errors.New("invalid boolean")
+	}
+	return data[0] != 0, nil
+}
+
+func decodeBindRequest(op *ber.Packet, controls []ldap.Control) (*ldap.SimpleBindRequest, error) {
+	if len(op.Children) != 3 {
+		return nil, protocolError("invalid bind request")
+	}
+	if version, err := decodeInt(op.Children[0]); err != nil || version != 3 {
+		return nil, protocolError("unsupported protocol version")
+	}
+	if op.Children[2].ClassType != ber.ClassContext || op.Children[2].Tag != 0 {
+		return nil, ldap.NewError(ldap.LDAPResultAuthMethodNotSupported, errors.New("only simple binds are supported"))
+	}
+	return &ldap.SimpleBindRequest{
+		Username:           op.Children[1].Data.String(),
+		Password:           op.Children[2].Data.String(),
+		Controls:           controls,
+		AllowEmptyPassword: true,
+	}, nil
+}
+
+func decodeSearchRequest(op *ber.Packet, controls []ldap.Control) (*ldap.SearchRequest, error) {
+	if len(op.Children) != 8 {
+		return nil, protocolError("invalid search request")
+	}
+	var numbers [4]int64
+	for i := range numbers {
+		n, err := decodeInt(op.Children[i+1])
+		if err != nil {
+			return nil, protocolError("invalid search request: %s", err)
+		}
+		numbers[i] = n
+	}
+	typesOnly, err := decodeBool(op.Children[5])
+	if err != nil {
+		return nil, protocolError("invalid search request: %s", err)
+	}
+	filter, err := ldap.DecompileFilter(op.Children[6])
+	if err != nil {
+		return nil, protocolError("invalid filter: %s", err)
+	}
+	var attributes []string
+	for _, attribute := range op.Children[7].Children {
+		attributes = append(attributes, attribute.Data.String())
+	}
+	return &ldap.SearchRequest{
+		BaseDN:       op.Children[0].Data.String(),
+		Scope:        int(numbers[0]),
+		DerefAliases: int(numbers[1]),
+		SizeLimit:    int(numbers[2]),
+		TimeLimit:    int(numbers[3]),
+		TypesOnly:    typesOnly,
+		Filter:       filter,
+		Attributes:   attributes,
+		Controls:     controls,
+	}, nil
+}
+
+// decodeAttribute decodes an attribute type with its set of values
+func decodeAttribute(packet *ber.Packet) (string, []string, error) {
+	if len(packet.Children) != 2 {
+		return "", nil, protocolError("invalid attribute")
+	}
+	var values []string
+	for _, value := range packet.Children[1].Children {
+		values = append(values, value.Data.String())
+	}
+	return packet.Children[0].Data.String(), values, nil
+}
+
+func decodeAddRequest(op *ber.Packet, controls []ldap.Control) (*ldap.AddRequest, error) {
+	if len(op.Children) != 2 {
+		return nil, protocolError("invalid add request")
+	}
+	req := &ldap.AddRequest{DN: op.Children[0].Data.String(), Controls: controls}
+	for _, attribute := range op.Children[1].Children {
+		name, values, err := decodeAttribute(attribute)
+		if err != nil {
+			return nil, err
+		}
+		req.Attributes = append(req.Attributes, ldap.Attribute{Type: name, Vals: values})
+	}
+	return req, nil
+}
+
+func decodeModifyRequest(op *ber.Packet, controls []ldap.Control) (*ldap.ModifyRequest, error) {
+	if len(op.Children) != 2 {
+		return nil, protocolError("invalid modify request")
+	}
+	req := &ldap.ModifyRequest{DN: op.Children[0].Data.String(), Controls: controls}
+	for _, change := range op.Children[1].Children {
+		if len(change.Children) != 2 {
+			return nil, protocolError("invalid change")
+		}
+		operation, err := decodeInt(change.Children[0])
+		if err != nil {
+			return nil, protocolError("invalid change: %s", err)
+		}
+		name, values, err := decodeAttribute(change.Children[1])
+		if err != nil {
+			return nil, err
+		}
+		req.Changes = append(req.Changes, ldap.Change{
+			Operation:    uint(operation),
+			Modification: ldap.PartialAttribute{Type: name, Vals: values},
+		})
+	}
+	return req, nil
+}
+
+func decodeDelRequest(op *ber.Packet, controls []ldap.Control) (*ldap.DelRequest, error) {
+	return &ldap.DelRequest{DN: op.Data.String(), Controls: controls}, nil
+}
+
+func decodeModifyDNRequest(op *ber.Packet, controls []ldap.Control) (*ldap.ModifyDNRequest, error) {
+	if len(op.Children) != 3 && len(op.Children) != 4 {
+		return nil, protocolError("invalid modify DN request")
+	}
+	deleteOldRDN, err := decodeBool(op.Children[2])
+	if err != nil {
+		return nil, protocolError("invalid modify DN request: %s", err)
+	}
+	req := &ldap.ModifyDNRequest{
+		DN:           op.Children[0].Data.String(),
+		NewRDN:       op.Children[1].Data.String(),
+		DeleteOldRDN: deleteOldRDN,
+		Controls:     controls,
+	}
+	if len(op.Children) == 4 {
+		req.NewSuperior = op.Children[3].Data.String()
+	}
+	return req, nil
+}
+
+func decodeCompareRequest(op *ber.Packet, controls []ldap.Control) (*ldap.CompareRequest, error) {
+	if len(op.Children) != 2 || len(op.Children[1].Children) != 2 {
+		return nil, protocolError("invalid compare request")
+	}
+	return ldap.NewCompareRequest(
+		op.Children[0].Data.String(),
+		op.Children[1].Children[0].Data.String(),
+		op.Children[1].Children[1].Data.String(),
+		controls), nil
+}
+
+func decodeExtendedRequest(op *ber.Packet, controls []ldap.Control) (*ldap.ExtendedRequest, error) {
+	req := &ldap.ExtendedRequest{Controls: controls}
+	for _, child := range op.Children {
+		switch child.Tag {
+		case 0:
+			req.Name = child.Data.String()
+		case 1:
+			req.Value = append([]byte{}, child.Data.Bytes()...)
+		}
+	}
+	if req.Name == "" {
+		return nil, protocolError("invalid extended request")
+	}
+	return req, nil
+}
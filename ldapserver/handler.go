@@ -0,0 +1,77 @@
+package ldapserver
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap"
+)
+
+// The handler passed to NewServer implements one or more of the following
+// interfaces, one per operation. Requests for operations without a handler
+// fail with unwillingToPerform.
+//
+// Handlers are called concurrently, apart from binds, which abandon the
+// other operations of the connection and wait for them to return. The
+// context passed to a handler is canceled when the operation is abandoned or
+// the connection is closed; no response is sent for such operations.
+//
+// A nil error makes the operation succeed. An *ldap.Error returned by a
+// handler is sent with its result code, matched DN, diagnostic message and
+// referrals, any other error as result code other.
+
+// BindHandler handles simple bind requests. The connection is bound to the
+// name of the request if Bind succeeds, and anonymous otherwise.
+type BindHandler interface {
+	Bind(ctx context.Context, conn *Conn, req *ldap.SimpleBindRequest) error
+}
+
+// SearchHandler handles search requests, sending the results with w. The
+// handler is responsible for honoring the scope, filter, attribute
+// selection and limits of the request.
+type SearchHandler interface {
+	Search(ctx context.Context, conn *Conn, req *ldap.SearchRequest, w SearchResponseWriter) error
+}
+
+// SearchResponseWriter sends the results of a search
+type SearchResponseWriter interface {
+	// Entry sends a search result entry. The values of binary attributes,
+	// see ldap.EntryAttribute.IsBinary, are taken from ByteValues.
+	Entry(entry *ldap.Entry) error
+	// Referral sends a search result reference with the given LDAP URLs
+	Referral(uris ...string) error
+	// SetControls sets the controls of the search result done message
+	SetControls(controls ...ldap.Control)
+}
+
+// AddHandler handles add requests
+type AddHandler interface {
+	Add(ctx context.Context, conn *Conn, req *ldap.AddRequest) error
+}
+
+// ModifyHandler handles modify requests
+type ModifyHandler interface {
+	Modify(ctx context.Context, conn *Conn, req *ldap.ModifyRequest) error
+}
+
+// DeleteHandler handles delete requests
+type DeleteHandler interface {
+	Delete(ctx context.Context, conn *Conn, req *ldap.DelRequest) error
+}
+
+// ModifyDNHandler handles modify DN requests
+type ModifyDNHandler interface {
+	ModifyDN(ctx context.Context, conn *Conn, req *ldap.ModifyDNRequest) error
+}
+
+// CompareHandler handles compare requests, returning whether the entry
+// holds the value
+type CompareHandler interface {
+	Compare(ctx context.Context, conn *Conn, req *ldap.CompareRequest) (bool, error)
+}
+
+// ExtendedHandler handles extended requests other than StartTLS, which the
+// server handles itself. The response may be nil if there is no response
+// name or value to send.
+type ExtendedHandler interface {
+	Extended(ctx context.Context, conn *Conn, req *ldap.ExtendedRequest) (*ldap.ExtendedResponse, error)
+}
@@ -0,0 +1,238 @@
+package ldapserver
+
+import (
+	"context"
+	"errors"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// errNotSupported is the result of operations without a handler
+var errNotSupported = ldap.NewError(ldap.LDAPResultUnwillingToPerform, errors.New("operation not supported"))
+
+func (c *Conn) bind(messageID int64, op *ber.Packet, controls []ldap.Control) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+	req, err := decodeBindRequest(op, controls)
+	if err == nil {
+		if h, ok := c.server.handler.(BindHandler); ok {
+			err = h.Bind(ctx, c, req)
+		} else {
+			err = errNotSupported
+		}
+	}
+	c.mu.Lock()
+	c.bindDN = ""
+	if err == nil {
+		c.bindDN = req.Username
+	}
+	c.mu.Unlock()
+	if err != nil {
+		c.server.log(ldap.LogLevelInfo, "bind failed", "remote", c.RemoteAddr(), "error", err)
+	}
+	c.writeResult(ctx, messageID, ldap.ApplicationBindResponse, err)
+}
+
+// dispatch decodes the request op and passes it to the handler
+func (c *Conn) dispatch(ctx context.Context, messageID int64, op *ber.Packet, controls []ldap.Control) {
+	h := c.server.handler
+	var err error
+	switch op.Tag {
+	case ldap.ApplicationSearchRequest:
+		var req *ldap.SearchRequest
+		if req, err = decodeSearchRequest(op, controls); err == nil {
+			if h, ok := h.(SearchHandler); ok {
+				w := &searchWriter{ctx: ctx, conn: c, messageID: messageID}
+				err = h.Search(ctx, c, req, w)
+				c.writeResult(ctx, messageID, ldap.ApplicationSearchResultDone, err, w.controls...)
+				return
+			}
+			err = errNotSupported
+		}
+	case ldap.ApplicationAddRequest:
+		var req *ldap.AddRequest
+		if req, err = decodeAddRequest(op, controls); err == nil {
+			if h, ok := h.(AddHandler); ok {
+				err = h.Add(ctx, c, req)
+			} else {
+				err = errNotSupported
+			}
+		}
+	case ldap.ApplicationModifyRequest:
+		var req *ldap.ModifyRequest
+		if req, err = decodeModifyRequest(op, controls); err == nil {
+			if h, ok := h.(ModifyHandler); ok {
+				err = h.Modify(ctx, c, req)
+			} else {
+				err = errNotSupported
+			}
+		}
+	case ldap.ApplicationDelRequest:
+		var req *ldap.DelRequest
+		if req, err = decodeDelRequest(op, controls); err == nil {
+			if h, ok := h.(DeleteHandler); ok {
+				err = h.Delete(ctx, c, req)
+			} else {
+				err = errNotSupported
+			}
+		}
+	case ldap.ApplicationModifyDNRequest:
+		var req *ldap.ModifyDNRequest
+		if req, err = decodeModifyDNRequest(op, controls); err == nil {
+			if h, ok := h.(ModifyDNHandler); ok {
+				err = h.ModifyDN(ctx, c, req)
+			} else {
+				err = errNotSupported
+			}
+		}
+	case ldap.ApplicationCompareRequest:
+		var req *ldap.CompareRequest
+		if req, err = decodeCompareRequest(op, controls); err == nil {
+			if h, ok := h.(CompareHandler); ok {
+				var match bool
+				if match, err = h.Compare(ctx, c, req); err == nil {
+					err = ldap.NewError(ldap.LDAPResultCompareFalse, errors.New(""))
+					if match {
+						err = ldap.NewError(ldap.LDAPResultCompareTrue, errors.New(""))
+					}
+				}
+			} else {
+				err = errNotSupported
+			}
+		}
+	default:
+		// there is no response type for unknown operations
+		c.server.log(ldap.LogLevelWarn, "unknown operation", "remote", c.RemoteAddr(), "tag", op.Tag)
+		return
+	}
+	c.writeResult(ctx, messageID, op.Tag+1, err)
+}
+
+func (c *Conn) extended(ctx context.Context, messageID int64, req *ldap.ExtendedRequest) {
+	h, ok := c.server.handler.(ExtendedHandler)
+	if !ok {
+		c.writeExtended(ctx, messageID, nil, ldap.NewError(ldap.LDAPResultProtocolError, errors.New("unsupported extended operation")))
+		return
+	}
+	resp, err := h.Extended(ctx, c, req)
+	c.writeExtended(ctx, messageID, resp, err)
+}
+
+// resultOf returns the result code, matched DN, diagnostic message and
+// referrals for the error returned by a handler
+func resultOf(err error) (uint16, string, string, []string) {
+	if err == nil {
+		return ldap.LDAPResultSuccess, "", "", nil
+	}
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) {
+		return ldap.LDAPResultOther, "", err.Error(), nil
+	}
+	message := ldapErr.DiagnosticMessage
+	if message == "" && ldapErr.Err != nil {
+		message = ldapErr.Err.Error()
+	}
+	return ldapErr.ResultCode, ldapErr.MatchedDN, message, ldapErr.Referrals
+}
+
+// encodeResult encodes an LDAPResult for err with the given application tag
+func encodeResult(tag ber.Tag, err error) *ber.Packet {
+	code, matchedDN, message, referrals := resultOf(err)
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, ldap.ApplicationMap[uint8(tag)])
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, matchedDN, "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	if len(referrals) > 0 {
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "Referral")
+		for _, uri := range referrals {
+			packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, uri, "URI"))
+		}
+		op.AppendChild(packet)
+	}
+	return op
+}
+
+// writeResult sends the result of an operation unless ctx is done, i.e. the
+// operation was abandoned or the connection closed
+func (c *Conn) writeResult(ctx context.Context, messageID int64, tag ber.Tag, err error, controls ...ldap.Control) {
+	if ctx.Err() != nil {
+		return
+	}
+	_ = c.write(messageID, encodeResult(tag, err), controls)
+}
+
+// writeExtended sends the response of an extended operation unless ctx is
+// done
+func (c *Conn) writeExtended(ctx context.Context, messageID int64, resp *ldap.ExtendedResponse, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	op := encodeResult(ldap.ApplicationExtendedResponse, err)
+	var controls []ldap.Control
+	if resp != nil {
+		if resp.Name != "" {
+			op.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, resp.Name, "Response Name"))
+		}
+		if resp.Value != nil {
+			op.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 11, string(resp.Value), "Response Value"))
+		}
+		controls = resp.Controls
+	}
+	return c.write(messageID, op, controls)
+}
+
+// searchWriter is the SearchResponseWriter of a search operation
+type searchWriter struct {
+	ctx       context.Context
+	conn      *Conn
+	messageID int64
+	controls  []ldap.Control
+}
+
+func (w *searchWriter) Entry(entry *ldap.Entry) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	return w.conn.write(w.messageID, encodeEntry(entry), nil)
+}
+
+func (w *searchWriter) Referral(uris ...string) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultReference, nil, "Search Result Reference")
+	for _, uri := range uris {
+		op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, uri, "URI"))
+	}
+	return w.conn.write(w.messageID, op, nil)
+}
+
+func (w *searchWriter) SetControls(controls ...ldap.Control) {
+	w.controls = controls
+}
+
+// encodeEntry encodes a search result entry
+func encodeEntry(entry *ldap.Entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, attr := range entry.Attributes {
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr.Name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		if attr.IsBinary || len(attr.Values) == 0 {
+			for _, value := range attr.ByteValues {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value), "Value"))
+			}
+		} else {
+			for _, value := range attr.Values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+		}
+		seq.AppendChild(set)
+		list.AppendChild(seq)
+	}
+	op.AppendChild(list)
+	return op
+}
@@ -0,0 +1,240 @@
+// Package ldapserver provides a framework for LDAP servers, e.g. facades to
+// other user stores or proxies rewriting requests. Requests are decoded into
+// the request types of package ldap and passed to the handler interfaces
+// implemented by the application:
+//
+//	type directory struct{}
+//
+//	func (directory) Bind(ctx context.Context, conn *ldapserver.Conn, req *ldap.SimpleBindRequest) error {
+//		if !checkPassword(req.Username, req.Password) {
+//			return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+//		}
+//		return nil
+//	}
+//
+//	func (directory) Search(ctx context.Context, conn *ldapserver.Conn, req *ldap.SearchRequest, w ldapserver.SearchResponseWriter) error {
+//		for _, entry := range lookup(req) {
+//			if err := w.Entry(entry); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	}
+//
+//	server := ldapserver.NewServer(directory{})
+//	log.Fatal(server.ListenAndServe(":389"))
+//
+// The server supports StartTLS if TLSConfig is set and stops gracefully
+// with Shutdown.
+package ldapserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap"
+)
+
+// startTLSOID is the name of the StartTLS extended operation
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// ErrServerClosed is returned by the Serve methods after Shutdown or Close
+var ErrServerClosed = errors.New("ldapserver: server closed")
+
+// shutdownPollInterval is the interval in which Shutdown checks for idle
+// connections
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Server serves LDAP connections with a handler implementing one or more
+// of the handler interfaces, see BindHandler.
+type Server struct {
+	// TLSConfig is the configuration for ListenAndServeTLS and StartTLS.
+	// StartTLS requests fail with unavailable if it is nil.
+	TLSConfig *tls.Config
+	// Logger receives events about connections and malformed requests, if
+	// set
+	Logger ldap.StructuredLogger
+
+	handler interface{}
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// NewServer returns a server passing requests to handler
+func NewServer(handler interface{}) *Server {
+	return &Server{
+		handler:   handler,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr, ":389" if empty, and
+// serves the connections. It always returns a non-nil error.
+func (s *Server) ListenAndServe(addr string) error {
+	if addr == "" {
+		addr = ":389"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS listens on the TCP address addr, ":636" if empty, and
+// serves the connections with TLS using TLSConfig. It always returns a
+// non-nil error.
+func (s *Server) ListenAndServeTLS(addr string) error {
+	if s.TLSConfig == nil {
+		return errors.New("ldapserver: TLSConfig is required for ListenAndServeTLS")
+	}
+	if addr == "" {
+		addr = ":636"
+	}
+	l, err := tls.Listen("tcp", addr, s.TLSConfig)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each of them in a new
+// goroutine. It always returns a non-nil error, ErrServerClosed after
+// Shutdown or Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			netConn.Close()
+			return ErrServerClosed
+		}
+		c := newConn(s, netConn)
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		s.log(ldap.LogLevelDebug, "connection accepted", "remote", netConn.RemoteAddr())
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Shutdown stops the server gracefully: it closes the listeners, then
+// closes every connection once its running operations are complete. If ctx
+// is done before, the remaining connections are closed as by Close and the
+// error of ctx is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.closeListeners()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for !s.closeIdleConns() {
+		select {
+		case <-ctx.Done():
+			s.closeConns()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	s.wg.Wait()
+	return err
+}
+
+// Close stops the server immediately, closing the listeners and all
+// connections. The contexts of running operations are canceled, but Close
+// does not wait for their handlers to return.
+func (s *Server) Close() error {
+	err := s.closeListeners()
+	s.closeConns()
+	return err
+}
+
+// Addr returns the address of a listener of the server, or nil if it is
+// not serving
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l := range s.listeners {
+		return l.Addr()
+	}
+	return nil
+}
+
+// closeListeners marks the server closed and closes its listeners
+func (s *Server) closeListeners() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if closeErr := l.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// closeIdleConns closes the connections without running operations and
+// returns true if no connections remain
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idle := true
+	for c := range s.conns {
+		if !c.closeIfIdle() {
+			idle = false
+		}
+	}
+	return idle
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.close()
+	}
+}
+
+func (s *Server) log(level ldap.LogLevel, msg string, keysAndValues ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Log(level, msg, keysAndValues...)
+	}
+}
@@ -0,0 +1,410 @@
+package ldapserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap"
+)
+
+// testHandler implements all handler interfaces on a map of entries
+type testHandler struct {
+	mu       sync.Mutex
+	entries  map[string]*ldap.Entry
+	requests []string
+	blocking bool
+}
+
+func newTestHandler() *testHandler {
+	return &testHandler{entries: map[string]*ldap.Entry{
+		"uid=jdoe,dc=example,dc=com": ldap.NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{
+			"uid": {"jdoe"},
+			"cn":  {"John Doe"},
+		}),
+	}}
+}
+
+func (h *testHandler) record(request string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, request)
+}
+
+func (h *testHandler) Bind(ctx context.Context, conn *Conn, req *ldap.SimpleBindRequest) error {
+	if req.Username == "uid=jdoe,dc=example,dc=com" && req.Password == "secret" {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (h *testHandler) Search(ctx context.Context, conn *Conn, req *ldap.SearchRequest, w SearchResponseWriter) error {
+	h.record("search " + req.BaseDN + " " + req.Filter + " bound as " + conn.BoundDN())
+	h.mu.Lock()
+	blocking := h.blocking
+	h.mu.Unlock()
+	if blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if req.BaseDN == "dc=other,dc=com" {
+		return w.Referral("ldap://other.example.com/dc=other,dc=com")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[req.BaseDN]
+	if !ok {
+		return &ldap.Error{ResultCode: ldap.LDAPResultNoSuchObject, MatchedDN: "dc=example,dc=com", Err: errors.New("no such entry")}
+	}
+	w.SetControls(ldap.NewControlString("1.2.3.4", false, "done"))
+	return w.Entry(entry)
+}
+
+func (h *testHandler) Add(ctx context.Context, conn *Conn, req *ldap.AddRequest) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.entries[req.DN]; ok {
+		return ldap.NewError(ldap.LDAPResultEntryAlreadyExists, errors.New("entry exists"))
+	}
+	attributes := make(map[string][]string)
+	for _, attribute := range req.Attributes {
+		attributes[attribute.Type] = attribute.Vals
+	}
+	h.entries[req.DN] = ldap.NewEntry(req.DN, attributes)
+	return nil
+}
+
+func (h *testHandler) Modify(ctx context.Context, conn *Conn, req *ldap.ModifyRequest) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[req.DN]
+	if !ok {
+		return ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such entry"))
+	}
+	for _, change := range req.Changes {
+		if change.Operation != ldap.ReplaceAttribute {
+			return errors.New("only replace is supported")
+		}
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(change.Modification.Type, change.Modification.Vals))
+	}
+	return nil
+}
+
+func (h *testHandler) Delete(ctx context.Context, conn *Conn, req *ldap.DelRequest) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.entries, req.DN)
+	return nil
+}
+
+func (h *testHandler) ModifyDN(ctx context.Context, conn *Conn, req *ldap.ModifyDNRequest) error {
+	h.record("moddn " + req.DN + " " + req.NewRDN + " " + req.NewSuperior)
+	return nil
+}
+
+func (h *testHandler) Compare(ctx context.Context, conn *Conn, req *ldap.CompareRequest) (bool, error) {
+	for _, control := range req.Controls {
+		h.record("compare " + req.DN + " with control " + control.GetControlType())
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[req.DN]
+	if !ok {
+		return false, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such entry"))
+	}
+	return entry.GetAttributeValue(req.Attribute) == req.Value, nil
+}
+
+func (h *testHandler) Extended(ctx context.Context, conn *Conn, req *ldap.ExtendedRequest) (*ldap.ExtendedResponse, error) {
+	if req.Name != "1.3.6.1.4.1.4203.1.11.3" {
+		return nil, ldap.NewError(ldap.LDAPResultProtocolError, errors.New("unsupported extended operation"))
+	}
+	// who am I
+	return &ldap.ExtendedResponse{Value: []byte("dn:" + conn.BoundDN())}, nil
+}
+
+func (h *testHandler) recorded() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string{}, h.requests...)
+}
+
+// startServer serves handler on a loopback port
+func startServer(t *testing.T, server *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(l)
+	}()
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *ldap.Conn {
+	t.Helper()
+	conn, err := ldap.DialURL("ldap://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+func TestServerOperations(t *testing.T) {
+	h := newTestHandler()
+	conn := dial(t, startServer(t, NewServer(h)))
+
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "wrong"); !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Fatalf("expected invalidCredentials, got %v", err)
+	}
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest("uid=jdoe,dc=example,dc=com", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].GetAttributeValue("cn") != "John Doe" {
+		t.Fatalf("unexpected entries %v", result.Entries)
+	}
+	if len(result.Controls) != 1 || result.Controls[0].GetControlType() != "1.2.3.4" {
+		t.Fatalf("unexpected controls %v", result.Controls)
+	}
+	if requests := h.recorded(); len(requests) != 1 || requests[0] != "search uid=jdoe,dc=example,dc=com (objectClass=*) bound as uid=jdoe,dc=example,dc=com" {
+		t.Fatalf("unexpected requests %q", requests)
+	}
+
+	result, err = conn.Search(ldap.NewSearchRequest("dc=other,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Referrals) != 1 || result.Referrals[0] != "ldap://other.example.com/dc=other,dc=com" {
+		t.Fatalf("unexpected referrals %v", result.Referrals)
+	}
+
+	_, err = conn.Search(ldap.NewSearchRequest("uid=nobody,dc=example,dc=com", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) || ldapErr.ResultCode != ldap.LDAPResultNoSuchObject || ldapErr.MatchedDN != "dc=example,dc=com" {
+		t.Fatalf("expected noSuchObject with matched DN, got %v", err)
+	}
+
+	add := ldap.NewAddRequest("uid=asmith,dc=example,dc=com", nil)
+	add.Attribute("uid", []string{"asmith"})
+	add.Attribute("mail", []string{"asmith@example.com"})
+	if err := conn.Add(add); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Add(add); !ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists) {
+		t.Fatalf("expected entryAlreadyExists, got %v", err)
+	}
+
+	modify := ldap.NewModifyRequest("uid=asmith,dc=example,dc=com", nil)
+	modify.Replace("cn", []string{"Alice Smith"})
+	if err := conn.Modify(modify); err != nil {
+		t.Fatal(err)
+	}
+	modify = ldap.NewModifyRequest("uid=asmith,dc=example,dc=com", nil)
+	modify.Add("cn", []string{"Alice"})
+	if err := conn.Modify(modify); !ldap.IsErrorWithCode(err, ldap.LDAPResultOther) {
+		t.Fatalf("expected other, got %v", err)
+	}
+
+	if match, err := conn.Compare("uid=asmith,dc=example,dc=com", "cn", "Alice Smith"); err != nil || !match {
+		t.Fatalf("expected match, got %v, %v", match, err)
+	}
+	if match, err := conn.Compare("uid=asmith,dc=example,dc=com", "cn", "Bob"); err != nil || match {
+		t.Fatalf("expected no match, got %v, %v", match, err)
+	}
+	compare := ldap.NewCompareRequest("uid=asmith,dc=example,dc=com", "cn", "Alice Smith", []ldap.Control{ldap.NewControlManageDsaIT(false)})
+	if result, err := conn.CompareWithResult(compare); err != nil || !result.Matched {
+		t.Fatalf("expected match, got %v, %v", result, err)
+	}
+	if requests := h.recorded(); requests[len(requests)-1] != "compare uid=asmith,dc=example,dc=com with control "+ldap.ControlTypeManageDsaIT {
+		t.Fatalf("unexpected requests %q", requests)
+	}
+
+	if err := conn.ModifyDN(ldap.NewModifyDNRequest("uid=asmith,dc=example,dc=com", "uid=alice", true, "ou=people,dc=example,dc=com")); err != nil {
+		t.Fatal(err)
+	}
+	if requests := h.recorded(); requests[len(requests)-1] != "moddn uid=asmith,dc=example,dc=com uid=alice ou=people,dc=example,dc=com" {
+		t.Fatalf("unexpected requests %q", requests)
+	}
+
+	if err := conn.Del(ldap.NewDelRequest("uid=asmith,dc=example,dc=com", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Compare("uid=asmith,dc=example,dc=com", "cn", "Alice Smith"); !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		t.Fatalf("expected noSuchObject, got %v", err)
+	}
+
+	resp, err := conn.Extended(ldap.NewExtendedRequest("1.3.6.1.4.1.4203.1.11.3", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Value) != "dn:uid=jdoe,dc=example,dc=com" {
+		t.Fatalf("unexpected response value %q", resp.Value)
+	}
+}
+
+func TestServerUnsupportedOperation(t *testing.T) {
+	// a handler implementing no handler interface
+	conn := dial(t, startServer(t, NewServer(struct{}{})))
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); !ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform) {
+		t.Fatalf("expected unwillingToPerform, got %v", err)
+	}
+	if err := conn.Del(ldap.NewDelRequest("uid=jdoe,dc=example,dc=com", nil)); !ldap.IsErrorWithCode(err, ldap.LDAPResultUnwillingToPerform) {
+		t.Fatalf("expected unwillingToPerform, got %v", err)
+	}
+	if _, err := conn.Extended(ldap.NewExtendedRequest("1.2.3.4", nil)); !ldap.IsErrorWithCode(err, ldap.LDAPResultProtocolError) {
+		t.Fatalf("expected protocolError, got %v", err)
+	}
+	if err := conn.StartTLS(&tls.Config{InsecureSkipVerify: true}); !ldap.IsErrorWithCode(err, ldap.LDAPResultUnavailable) {
+		t.Fatalf("expected unavailable, got %v", err)
+	}
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldapserver test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestServerStartTLS(t *testing.T) {
+	h := newTestHandler()
+	server := NewServer(h)
+	server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}}
+	conn := dial(t, startServer(t, server))
+
+	if err := conn.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conn.TLSConnectionState(); !ok {
+		t.Fatal("expected a TLS connection")
+	}
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := conn.Extended(ldap.NewExtendedRequest("1.3.6.1.4.1.4203.1.11.3", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Value) != "dn:uid=jdoe,dc=example,dc=com" {
+		t.Fatalf("unexpected response value %q", resp.Value)
+	}
+}
+
+func TestServerAbandon(t *testing.T) {
+	h := newTestHandler()
+	h.blocking = true
+	conn := dial(t, startServer(t, NewServer(h)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	response := conn.SearchAsync(ctx, ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil), 0)
+	for response.Next() {
+	}
+	if err := response.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// the abandoned search no longer blocks the connection
+	h.mu.Lock()
+	h.blocking = false
+	h.mu.Unlock()
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	h := newTestHandler()
+	h.blocking = true
+	server := NewServer(h)
+	addr := startServer(t, server)
+	conn := dial(t, addr)
+	idle := dial(t, addr)
+
+	searchDone := make(chan error, 1)
+	go func() {
+		_, err := conn.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil))
+		searchDone <- err
+	}()
+	for len(h.recorded()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the running search keeps Shutdown from completing until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if err := <-searchDone; err == nil {
+		t.Fatal("expected the search to fail")
+	}
+	if err := idle.Bind("uid=jdoe,dc=example,dc=com", "secret"); err == nil {
+		t.Fatal("expected the idle connection to be closed")
+	}
+	if _, err := net.Dial("tcp", addr); err == nil {
+		t.Fatal("expected the listener to be closed")
+	}
+}
+
+func TestServerGracefulShutdown(t *testing.T) {
+	server := NewServer(newTestHandler())
+	addr := startServer(t, server)
+	conn := dial(t, addr)
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Bind("uid=jdoe,dc=example,dc=com", "secret"); err == nil {
+		t.Fatal("expected the connection to be closed")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(l); err != ErrServerClosed {
+		t.Fatalf("expected ErrServerClosed, got %v", err)
+	}
+}
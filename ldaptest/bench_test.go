@@ -0,0 +1,139 @@
+package ldaptest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+// The benchmarks below run the client against the in-memory Server, so they
+// cover encoding requests, reading and decoding responses and the message
+// handling of ldap.Conn. The time and allocations include those of the
+// server. Compare runs of them with benchstat, e.g.
+//
+//	go test -run XXX -bench . -count 10 ./ldaptest > new.txt
+//	benchstat old.txt new.txt
+
+// startBenchServer starts a server with n person entries below
+// ou=people,dc=example,dc=com and returns it with a connection to it
+func startBenchServer(b *testing.B, n int) (*Server, *ldap.Conn) {
+	b.Helper()
+	server := NewServer()
+	for _, entry := range []*ldap.Entry{
+		ldap.NewEntry("dc=example,dc=com", map[string][]string{"objectClass": {"domain"}, "dc": {"example"}}),
+		ldap.NewEntry("ou=people,dc=example,dc=com", map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"people"}}),
+	} {
+		if err := server.AddEntry(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		uid := fmt.Sprintf("user%d", i)
+		entry := ldap.NewEntry("uid="+uid+",ou=people,dc=example,dc=com", map[string][]string{
+			"objectClass": {"top", "person", "organizationalPerson", "inetOrgPerson"},
+			"uid":         {uid},
+			"cn":          {"User " + uid},
+			"sn":          {uid},
+			"mail":        {uid + "@example.com"},
+			"uidNumber":   {fmt.Sprint(10000 + i)},
+			"description": {"benchmark user " + uid},
+		})
+		if err := server.AddEntry(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := server.Start(); err != nil {
+		b.Fatal(err)
+	}
+	conn, err := ldap.DialURL(server.URL())
+	if err != nil {
+		server.Close()
+		b.Fatal(err)
+	}
+	return server, conn
+}
+
+func peopleSearch(filter string) *ldap.SearchRequest {
+	return ldap.NewSearchRequest("ou=people,dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, filter, nil, nil)
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, n := range []int{1, 100, 1000} {
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			server, conn := startBenchServer(b, n)
+			defer server.Close()
+			defer conn.Close()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result, err := conn.Search(peopleSearch("(objectClass=person)"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(result.Entries) != n {
+					b.Fatalf("got %d entries, expected %d", len(result.Entries), n)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSearchWithPaging(b *testing.B) {
+	server, conn := startBenchServer(b, 1000)
+	defer server.Close()
+	defer conn.Close()
+
+	for _, pagingSize := range []uint32{10, 100} {
+		b.Run(fmt.Sprintf("pagingSize=%d", pagingSize), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				result, err := conn.SearchWithPaging(peopleSearch("(objectClass=person)"), pagingSize)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(result.Entries) != 1000 {
+					b.Fatalf("got %d entries, expected 1000", len(result.Entries))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSearchFanOut runs as many searches for single entries
+// concurrently on one connection as there are goroutines
+func BenchmarkSearchFanOut(b *testing.B) {
+	server, conn := startBenchServer(b, 100)
+	defer server.Close()
+	defer conn.Close()
+
+	for _, goroutines := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("goroutines=%d", goroutines), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				errs := make(chan error, goroutines)
+				for g := 0; g < goroutines; g++ {
+					wg.Add(1)
+					go func(g int) {
+						defer wg.Done()
+						result, err := conn.Search(peopleSearch(fmt.Sprintf("(uid=user%d)", g)))
+						if err == nil && len(result.Entries) != 1 {
+							err = fmt.Errorf("got %d entries for user%d", len(result.Entries), g)
+						}
+						errs <- err
+					}(g)
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
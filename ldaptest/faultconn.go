@@ -0,0 +1,205 @@
+package ldaptest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap"
+)
+
+// FaultKind is the kind of fault injected by a FaultConn
+type FaultKind int
+
+// Fault kinds
+const (
+	// FaultLatency delays the message by Delay
+	FaultLatency FaultKind = iota
+	// FaultPartialWrite sends the first Bytes bytes of a request, then fails
+	// the write and closes the connection
+	FaultPartialWrite
+	// FaultDisconnect delivers the first Bytes bytes of a response, then
+	// closes the connection
+	FaultDisconnect
+	// FaultReorder delivers a response after the one following it
+	FaultReorder
+	// FaultMalformed replaces the message with a well-framed message which
+	// is not a valid LDAP message
+	FaultMalformed
+)
+
+// Fault describes a fault injected into the traffic of a FaultConn
+type Fault struct {
+	Kind FaultKind
+	// Direction selects requests (ldap.PacketSent) or responses
+	// (ldap.PacketReceived). FaultPartialWrite only applies to requests,
+	// FaultDisconnect and FaultReorder only to responses.
+	Direction ldap.PacketDirection
+	// Message is the number of the affected message in its direction,
+	// starting at 1, or 0 for every message
+	Message int
+	// Delay is the latency added by FaultLatency
+	Delay time.Duration
+	// Bytes is the number of bytes transferred by FaultPartialWrite and
+	// FaultDisconnect
+	Bytes int
+}
+
+// malformedMessage is a sequence holding an octet string instead of a
+// message ID and an operation
+var malformedMessage = []byte{0x30, 0x03, 0x04, 0x01, 0xff}
+
+// errFaultInjected is returned by the writes failed by FaultPartialWrite
+var errFaultInjected = errors.New("ldaptest: injected fault")
+
+// FaultConn is a net.Conn which injects faults into the LDAP messages sent
+// and received on an underlying connection, to exercise the timeout, error
+// and reconnect paths of a client:
+//
+//	transport, err := net.Dial("tcp", server.Addr())
+//	...
+//	conn := ldap.NewConn(ldaptest.NewFaultConn(transport,
+//		ldaptest.Fault{Kind: ldaptest.FaultDisconnect, Direction: ldap.PacketReceived, Message: 2, Bytes: 10},
+//	), false)
+//	conn.Start()
+type FaultConn struct {
+	net.Conn
+	faults []Fault
+
+	writeMu sync.Mutex
+	sent    []byte
+	nsent   int
+
+	readMu    sync.Mutex
+	received  []byte
+	nreceived int
+	pending   []byte
+	held      []byte
+	eof       bool
+}
+
+// NewFaultConn returns a FaultConn injecting faults into the traffic of conn
+func NewFaultConn(conn net.Conn, faults ...Fault) *FaultConn {
+	return &FaultConn{Conn: conn, faults: faults}
+}
+
+// faultsFor returns the faults for the nth message of the direction
+func (c *FaultConn) faultsFor(direction ldap.PacketDirection, n int) []Fault {
+	var faults []Fault
+	for _, fault := range c.faults {
+		if fault.Direction == direction && (fault.Message == 0 || fault.Message == n) {
+			faults = append(faults, fault)
+		}
+	}
+	return faults
+}
+
+// Write sends the requests in b, injecting faults once they are complete
+func (c *FaultConn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.sent = append(c.sent, b...)
+	for {
+		n := messageLength(c.sent)
+		if n == 0 || n > len(c.sent) {
+			return len(b), nil
+		}
+		message := c.sent[:n:n]
+		c.sent = c.sent[n:]
+		c.nsent++
+		for _, fault := range c.faultsFor(ldap.PacketSent, c.nsent) {
+			switch fault.Kind {
+			case FaultLatency:
+				time.Sleep(fault.Delay)
+			case FaultMalformed:
+				message = malformedMessage
+			case FaultPartialWrite:
+				if fault.Bytes < len(message) {
+					_, _ = c.Conn.Write(message[:fault.Bytes])
+				}
+				c.Conn.Close()
+				return 0, errFaultInjected
+			}
+		}
+		if _, err := c.Conn.Write(message); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Read returns the received responses, injecting faults once they are
+// complete
+func (c *FaultConn) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for len(c.pending) == 0 {
+		if c.eof {
+			return 0, io.EOF
+		}
+		if err := c.receive(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// receive reads from the underlying connection until at least one complete
+// response was received and processes the faults for it
+func (c *FaultConn) receive() error {
+	buf := make([]byte, 4096)
+	for {
+		n := messageLength(c.received)
+		if n > 0 && n <= len(c.received) {
+			message := c.received[:n:n]
+			c.received = c.received[n:]
+			c.nreceived++
+			c.deliver(message)
+			return nil
+		}
+		read, err := c.Conn.Read(buf)
+		c.received = append(c.received, buf[:read]...)
+		if err != nil && read == 0 {
+			// deliver a held response before the error
+			if len(c.held) > 0 {
+				c.pending = append(c.pending, c.held...)
+				c.held = nil
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// deliver applies the faults for the current response and queues it
+func (c *FaultConn) deliver(message []byte) {
+	for _, fault := range c.faultsFor(ldap.PacketReceived, c.nreceived) {
+		switch fault.Kind {
+		case FaultLatency:
+			time.Sleep(fault.Delay)
+		case FaultMalformed:
+			message = malformedMessage
+		case FaultDisconnect:
+			if fault.Bytes < len(message) {
+				message = message[:fault.Bytes]
+			}
+			c.pending = append(c.pending, message...)
+			c.eof = true
+			c.Conn.Close()
+			return
+		case FaultReorder:
+			if c.held == nil {
+				c.held = message
+				return
+			}
+		}
+	}
+	c.pending = append(c.pending, message...)
+	if c.held != nil {
+		c.pending = append(c.pending, c.held...)
+		c.held = nil
+	}
+}
@@ -0,0 +1,103 @@
+package ldaptest
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap"
+)
+
+// dialFaulty connects to a test server through a FaultConn
+func dialFaulty(t *testing.T, faults ...Fault) (*Server, *ldap.Conn) {
+	server := NewServer()
+	if err := server.LoadLDIF(strings.NewReader(testLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	transport, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	conn := ldap.NewConn(NewFaultConn(transport, faults...), false)
+	conn.Start()
+	return server, conn
+}
+
+var peopleRequest = ldap.NewSearchRequest("ou=people,dc=example,dc=com", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil)
+
+func TestFaultConnLatency(t *testing.T) {
+	server, conn := dialFaulty(t, Fault{Kind: FaultLatency, Direction: ldap.PacketReceived, Message: 2, Delay: 200 * time.Millisecond})
+	defer server.Close()
+	defer conn.Close()
+
+	conn.SetTimeout(50 * time.Millisecond)
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := conn.Search(peopleRequest)
+	if !ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestFaultConnDisconnect(t *testing.T) {
+	server, conn := dialFaulty(t, Fault{Kind: FaultDisconnect, Direction: ldap.PacketReceived, Message: 2, Bytes: 10})
+	defer server.Close()
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Search(peopleRequest); err == nil {
+		t.Error("expected the search to fail")
+	}
+	if !conn.IsClosing() {
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestFaultConnMalformed(t *testing.T) {
+	for _, direction := range []ldap.PacketDirection{ldap.PacketSent, ldap.PacketReceived} {
+		server, conn := dialFaulty(t, Fault{Kind: FaultMalformed, Direction: direction, Message: 1})
+		conn.SetTimeout(time.Second)
+		if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err == nil {
+			t.Errorf("direction %d: expected the bind to fail", direction)
+		}
+		conn.Close()
+		server.Close()
+	}
+}
+
+func TestFaultConnPartialWrite(t *testing.T) {
+	server, conn := dialFaulty(t, Fault{Kind: FaultPartialWrite, Direction: ldap.PacketSent, Message: 1, Bytes: 5})
+	defer server.Close()
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err == nil {
+		t.Error("expected the bind to fail")
+	}
+}
+
+func TestFaultConnReorder(t *testing.T) {
+	server, conn := dialFaulty(t, Fault{Kind: FaultReorder, Direction: ldap.PacketReceived, Message: 1})
+	defer server.Close()
+	defer conn.Close()
+
+	// the first entry is delivered after the second one
+	result, err := conn.Search(peopleRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	if strings.Join(dns, " ") != "uid=asmith,ou=people,dc=example,dc=com uid=jdoe,ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected entries %v", dns)
+	}
+}
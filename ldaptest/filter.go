@@ -0,0 +1,143 @@
+package ldaptest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// caseExactMatch is the OID of the matching rule which compares values case
+// sensitively in extensible match filters
+const caseExactMatch = "2.5.13.5"
+
+// matchFilter evaluates the filter of a search request against entry. Values
+// are compared case insensitively, and numerically by the ordering filters if
+// both values are integers. Approximate matches are evaluated as equality
+// matches.
+func matchFilter(filter *ber.Packet, entry *ldap.Entry) (bool, error) {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			matched, err := matchFilter(child, entry)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			matched, err := matchFilter(child, entry)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	case ldap.FilterNot:
+		if len(filter.Children) != 1 {
+			return false, fmt.Errorf("ldaptest: invalid not filter")
+		}
+		matched, err := matchFilter(filter.Children[0], entry)
+		return !matched, err
+	case ldap.FilterPresent:
+		return len(entry.GetEqualFoldAttributeValues(filter.Data.String())) > 0, nil
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		if len(filter.Children) != 2 {
+			return false, fmt.Errorf("ldaptest: invalid %s filter", ldap.FilterMap[uint64(filter.Tag)])
+		}
+		attribute, assertion := filter.Children[0].Data.String(), filter.Children[1].Data.String()
+		for _, value := range entry.GetEqualFoldAttributeValues(attribute) {
+			c := compareValues(value, assertion)
+			switch {
+			case filter.Tag == ldap.FilterGreaterOrEqual && c >= 0,
+				filter.Tag == ldap.FilterLessOrEqual && c <= 0,
+				c == 0:
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterSubstrings:
+		if len(filter.Children) != 2 {
+			return false, fmt.Errorf("ldaptest: invalid substrings filter")
+		}
+		for _, value := range entry.GetEqualFoldAttributeValues(filter.Children[0].Data.String()) {
+			if matchSubstrings(strings.ToLower(value), filter.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ldap.FilterExtensibleMatch:
+		var rule, attribute, assertion string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case ldap.MatchingRuleAssertionMatchingRule:
+				rule = child.Data.String()
+			case ldap.MatchingRuleAssertionType:
+				attribute = child.Data.String()
+			case ldap.MatchingRuleAssertionMatchValue:
+				assertion = child.Data.String()
+			}
+		}
+		if rule != "" && rule != caseExactMatch && !strings.EqualFold(rule, "caseExactMatch") {
+			return false, fmt.Errorf("ldaptest: unsupported matching rule '%s'", rule)
+		}
+		for _, attr := range entry.Attributes {
+			if attribute != "" && !strings.EqualFold(attr.Name, attribute) {
+				continue
+			}
+			for _, value := range attr.Values {
+				if value == assertion || (rule == "" && strings.EqualFold(value, assertion)) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("ldaptest: unsupported filter type %d", filter.Tag)
+	}
+}
+
+// compareValues compares two values numerically if both are integers and
+// case insensitively otherwise
+func compareValues(value, assertion string) int {
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if a, err := strconv.ParseInt(assertion, 10, 64); err == nil {
+			switch {
+			case v < a:
+				return -1
+			case v > a:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(value), strings.ToLower(assertion))
+}
+
+// matchSubstrings returns true if the lower case value matches the initial,
+// any and final substrings
+func matchSubstrings(value string, substrings []*ber.Packet) bool {
+	for i, substring := range substrings {
+		s := strings.ToLower(substring.Data.String())
+		switch substring.Tag {
+		case ldap.FilterSubstringsInitial:
+			if !strings.HasPrefix(value, s) {
+				return false
+			}
+			value = value[len(s):]
+		case ldap.FilterSubstringsAny:
+			index := strings.Index(value, s)
+			if index < 0 {
+				return false
+			}
+			value = value[index+len(s):]
+		case ldap.FilterSubstringsFinal:
+			if i != len(substrings)-1 || !strings.HasSuffix(value, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
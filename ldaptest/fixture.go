@@ -0,0 +1,394 @@
+package ldaptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// Fixtures store the LDAP messages of a session, one per line: "> " and the
+// base64 encoded message for requests, "< " for responses. Empty lines and
+// lines starting with "#" are ignored.
+const (
+	fixtureSent     = "> "
+	fixtureReceived = "< "
+)
+
+type fixtureMessage struct {
+	direction ldap.PacketDirection
+	data      []byte
+}
+
+// Recorder records the LDAP messages of a live session for replay by a
+// Replayer. Bind credentials, SASL tokens and password attribute values are
+// masked when they are recorded, see ldap.RedactMessage and
+// ldap.DebugRedactedAttributes.
+//
+// Wrap the transport of a plain connection with Wrap, or install Capture as
+// the packet capture hook of the connection to record TLS sessions:
+//
+//	recorder := ldaptest.NewRecorder()
+//	conn, err := ldap.DialURL("ldaps://ldap.example.com")
+//	...
+//	conn.SetPacketCapture(recorder.Capture)
+//	// run the session
+//	conn.Close()
+//	err = recorder.SaveFile("testdata/session.ldap")
+type Recorder struct {
+	mu       sync.Mutex
+	messages []fixtureMessage
+	err      error
+}
+
+// NewRecorder returns a Recorder without messages
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Capture records a message. It is an ldap.PacketCaptureFunc.
+func (r *Recorder) Capture(direction ldap.PacketDirection, data []byte) {
+	redacted, err := ldap.RedactMessage(data)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("ldaptest: failed to record message: %s", err)
+		}
+		return
+	}
+	r.messages = append(r.messages, fixtureMessage{direction: direction, data: redacted})
+}
+
+// Wrap returns a net.Conn recording the messages sent and received on conn.
+// The connection must not be encrypted, as the messages are read from the
+// raw stream.
+func (r *Recorder) Wrap(conn net.Conn) net.Conn {
+	return &recordingConn{Conn: conn, recorder: r}
+}
+
+// WriteTo writes the recorded messages as fixture to w
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return 0, r.err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# LDAP session recorded by ldaptest.Recorder\n")
+	for _, message := range r.messages {
+		prefix := fixtureSent
+		if message.direction == ldap.PacketReceived {
+			prefix = fixtureReceived
+		}
+		buf.WriteString(prefix + base64.StdEncoding.EncodeToString(message.data) + "\n")
+	}
+	return buf.WriteTo(w)
+}
+
+// SaveFile writes the recorded messages as fixture to the file at path
+func (r *Recorder) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordingConn splits the streams of a connection into messages for its
+// Recorder
+type recordingConn struct {
+	net.Conn
+	recorder *Recorder
+	sent     []byte
+	received []byte
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.received = c.record(ldap.PacketReceived, append(c.received, b[:n]...))
+	return n, err
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.sent = c.record(ldap.PacketSent, append(c.sent, b[:n]...))
+	return n, err
+}
+
+// record records the complete messages at the start of buf and returns the
+// remaining bytes
+func (c *recordingConn) record(direction ldap.PacketDirection, buf []byte) []byte {
+	for {
+		n := messageLength(buf)
+		if n == 0 || n > len(buf) {
+			return buf
+		}
+		c.recorder.Capture(direction, buf[:n])
+		buf = buf[n:]
+	}
+}
+
+// messageLength returns the length of the BER encoded message at the start
+// of buf, or 0 if the header is incomplete. LDAP messages are sequences with
+// a single byte tag and a definite length.
+func messageLength(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+	if buf[1] < 0x80 {
+		return 2 + int(buf[1])
+	}
+	size := int(buf[1] & 0x7f)
+	if len(buf) < 2+size {
+		return 0
+	}
+	length := 0
+	for _, b := range buf[2 : 2+size] {
+		length = length<<8 | int(b)
+	}
+	return 2 + size + length
+}
+
+// Replayer replays a recorded session to a client. Each request of the
+// client must match the next recorded request, apart from the message ID and
+// the masked credentials; the recorded responses following it are then sent
+// with the message ID of the request. The session must perform its
+// operations sequentially, as it did while it was recorded.
+//
+//	replayer, err := ldaptest.LoadReplayer("testdata/session.ldap")
+//	...
+//	conn := ldap.NewConn(replayer.Dial(), false)
+//	conn.Start()
+//	// run the session
+//	conn.Close()
+//	if err := replayer.Err(); err != nil {
+//		t.Error(err)
+//	}
+type Replayer struct {
+	messages []fixtureMessage
+
+	mu   sync.Mutex
+	next int
+	err  error
+	done chan struct{}
+}
+
+// NewReplayer returns a Replayer for the fixture read from r
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	p := &Replayer{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var direction ldap.PacketDirection
+		switch {
+		case strings.HasPrefix(text, strings.TrimSpace(fixtureSent)):
+			direction = ldap.PacketSent
+		case strings.HasPrefix(text, strings.TrimSpace(fixtureReceived)):
+			direction = ldap.PacketReceived
+		default:
+			return nil, fmt.Errorf("ldaptest: invalid fixture line %d", line)
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("ldaptest: invalid message on fixture line %d: %s", line, err)
+		}
+		if _, err := ber.DecodePacketErr(data); err != nil {
+			return nil, fmt.Errorf("ldaptest: invalid message on fixture line %d: %s", line, err)
+		}
+		p.messages = append(p.messages, fixtureMessage{direction: direction, data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadReplayer returns a Replayer for the fixture file at path
+func LoadReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayer(f)
+}
+
+// Dial returns a connection on which the session is replayed, for use with
+// ldap.NewConn. A Replayer can only be dialed once.
+func (p *Replayer) Dial() net.Conn {
+	client, server := net.Pipe()
+	p.mu.Lock()
+	p.done = make(chan struct{})
+	p.mu.Unlock()
+	go p.serve(server)
+	return client
+}
+
+// Err waits until the replayed connection is closed and returns the first
+// mismatch between the session and the fixture, or an error if recorded
+// requests were not sent
+func (p *Replayer) Err() error {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	missing := 0
+	for _, message := range p.messages[p.next:] {
+		// the session may end without unbinding
+		if message.direction == ldap.PacketSent && !isUnbind(message.data) {
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("ldaptest: %d recorded requests were not sent", missing)
+	}
+	return nil
+}
+
+func (p *Replayer) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+}
+
+func (p *Replayer) serve(conn net.Conn) {
+	defer close(p.done)
+	defer conn.Close()
+
+	// messageIDs maps the recorded message IDs to those of the session
+	messageIDs := make(map[int64]int64)
+	for {
+		p.mu.Lock()
+		var responses []fixtureMessage
+		for p.next < len(p.messages) && p.messages[p.next].direction == ldap.PacketReceived {
+			responses = append(responses, p.messages[p.next])
+			p.next++
+		}
+		p.mu.Unlock()
+		for _, response := range responses {
+			data, err := replaceMessageID(response.data, messageIDs)
+			if err == nil {
+				_, err = conn.Write(data)
+			}
+			if err != nil {
+				p.fail(fmt.Errorf("ldaptest: failed to replay response: %s", err))
+				return
+			}
+		}
+
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		actual, err := ldap.RedactMessage(packet.Bytes())
+		if err != nil {
+			p.fail(fmt.Errorf("ldaptest: invalid request: %s", err))
+			return
+		}
+
+		p.mu.Lock()
+		if p.next >= len(p.messages) {
+			p.mu.Unlock()
+			if !isUnbind(actual) {
+				p.fail(fmt.Errorf("ldaptest: unexpected request after the end of the fixture: %s", describeMessage(actual)))
+			}
+			return
+		}
+		expected := p.messages[p.next]
+		p.next++
+		p.mu.Unlock()
+
+		recordedID, ok := sameRequest(expected.data, actual)
+		if !ok {
+			p.fail(fmt.Errorf("ldaptest: request %s does not match recorded request %s", describeMessage(actual), describeMessage(expected.data)))
+			return
+		}
+		messageIDs[recordedID] = packet.Children[0].Value.(int64)
+	}
+}
+
+// sameRequest compares two requests apart from their message IDs and returns
+// the message ID of the recorded one
+func sameRequest(recorded, actual []byte) (int64, bool) {
+	r, err := ber.DecodePacketErr(recorded)
+	if err != nil || len(r.Children) < 2 {
+		return 0, false
+	}
+	a, err := ber.DecodePacketErr(actual)
+	if err != nil || len(a.Children) != len(r.Children) {
+		return 0, false
+	}
+	for i := 1; i < len(r.Children); i++ {
+		if !bytes.Equal(r.Children[i].Bytes(), a.Children[i].Bytes()) {
+			return 0, false
+		}
+	}
+	id, ok := r.Children[0].Value.(int64)
+	return id, ok
+}
+
+// replaceMessageID replaces the recorded message ID of a response with the
+// one of the corresponding request of the session
+func replaceMessageID(data []byte, messageIDs map[int64]int64) ([]byte, error) {
+	packet, err := ber.DecodePacketErr(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(packet.Children) < 2 {
+		return nil, errors.New("invalid response")
+	}
+	id, _ := packet.Children[0].Value.(int64)
+	if mapped, ok := messageIDs[id]; ok {
+		id = mapped
+	}
+	response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	for _, child := range packet.Children[1:] {
+		response.AppendChild(child)
+	}
+	return response.Bytes(), nil
+}
+
+func isUnbind(data []byte) bool {
+	packet, err := ber.DecodePacketErr(data)
+	return err == nil && len(packet.Children) > 1 && packet.Children[1].ClassType == ber.ClassApplication &&
+		packet.Children[1].Tag == ldap.ApplicationUnbindRequest
+}
+
+// describeMessage returns the operation and message ID of a message for
+// error messages
+func describeMessage(data []byte) string {
+	packet, err := ber.DecodePacketErr(data)
+	if err != nil || len(packet.Children) < 2 {
+		return "(invalid message)"
+	}
+	return fmt.Sprintf("%d (%s)", packet.Children[0].Value, ldap.ApplicationMap[uint8(packet.Children[1].Tag)])
+}
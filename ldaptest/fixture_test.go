@@ -0,0 +1,114 @@
+package ldaptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+// runSession binds, searches and changes a password, returning the DNs found
+func runSession(conn *ldap.Conn, password, filter string) ([]string, error) {
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", password); err != nil {
+		return nil, err
+	}
+	result, err := conn.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, filter, []string{"cn"}, nil))
+	if err != nil {
+		return nil, err
+	}
+	modify := ldap.NewModifyRequest("uid=jdoe,ou=people,dc=example,dc=com", nil)
+	modify.Replace("userPassword", []string{password + "2"})
+	if err := conn.Modify(modify); err != nil {
+		return nil, err
+	}
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	return dns, nil
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := NewServer()
+	if err := server.LoadLDIF(strings.NewReader(testLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	recorder := NewRecorder()
+	transport, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := ldap.NewConn(recorder.Wrap(transport), false)
+	conn.Start()
+	recorded, err := runSession(conn, "secret", "(objectClass=inetOrgPerson)")
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fixture bytes.Buffer
+	if _, err := recorder.WriteTo(&fixture); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(fixture.String(), "\n") {
+		if strings.HasPrefix(line, ">") {
+			data, err := base64.StdEncoding.DecodeString(line[2:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("secret")) {
+				t.Errorf("credentials were recorded: %q", data)
+			}
+		}
+	}
+
+	// the session is replayed without a server, whatever the password is
+	replayer, err := NewReplayer(bytes.NewReader(fixture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = ldap.NewConn(replayer.Dial(), false)
+	conn.Start()
+	replayed, err := runSession(conn, "other", "(objectClass=inetOrgPerson)")
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replayer.Err(); err != nil {
+		t.Error(err)
+	}
+	if strings.Join(replayed, " ") != strings.Join(recorded, " ") || len(recorded) != 2 {
+		t.Errorf("replayed %v, recorded %v", replayed, recorded)
+	}
+
+	// a different request is reported
+	replayer, err = NewReplayer(bytes.NewReader(fixture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = ldap.NewConn(replayer.Dial(), false)
+	conn.Start()
+	if _, err := runSession(conn, "secret", "(uid=jdoe)"); err == nil {
+		t.Error("expected the session to fail")
+	}
+	conn.Close()
+	if err := replayer.Err(); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a mismatch, got %v", err)
+	}
+}
+
+func TestNewReplayerInvalidFixture(t *testing.T) {
+	for _, fixture := range []string{"? MAA=\n", "> not base64\n", "< MAU=\n"} {
+		if _, err := NewReplayer(strings.NewReader(fixture)); err == nil {
+			t.Errorf("expected an error for %q", fixture)
+		}
+	}
+}
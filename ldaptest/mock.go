@@ -0,0 +1,895 @@
+// Package ldaptest provides helpers for testing code which talks to LDAP
+// servers: MockClient, a scriptable implementation of ldap.Client for unit
+// tests, Server, an in-memory LDAP server for integration tests, Recorder
+// and Replayer, which record live sessions as fixtures and replay them, and
+// FaultConn, which injects faults into the traffic of a connection.
+//
+//	client := ldaptest.NewMockClient()
+//	client.ExpectBind("cn=admin,dc=example,dc=com", "secret")
+//	client.ExpectSearch(ldaptest.SearchBaseDN("dc=example,dc=com")).
+//		ReturnEntries(ldap.NewEntry("uid=jdoe,dc=example,dc=com", nil))
+//
+//	// run the code under test with client
+//
+//	if err := client.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+package ldaptest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap"
+)
+
+// ErrUnexpectedCall is returned by the operations of a MockClient for which
+// no matching expectation is left
+var ErrUnexpectedCall = errors.New("ldaptest: unexpected call")
+
+var _ ldap.Client = &MockClient{}
+
+// MockClient is an ldap.Client which answers operations from expectations
+// registered with its Expect methods. An operation consumes the first
+// registered expectation of its kind whose matcher accepts the request; if
+// there is none, it fails with ErrUnexpectedCall.
+//
+// Start, Close, IsClosing, SetTimeout and TLSConnectionState only track the
+// state of the client and need no expectations.
+type MockClient struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	closing      bool
+	tls          bool
+	timeout      time.Duration
+}
+
+// NewMockClient returns a MockClient without expectations
+func NewMockClient() *MockClient {
+	return &MockClient{}
+}
+
+// Expectation is an expected operation of a MockClient. By default it is
+// expected exactly once and succeeds.
+type Expectation struct {
+	operation string
+	match     func(request interface{}) bool
+	result    interface{}
+	err       error
+	// times is the number of expected calls, 0 for any number
+	times int
+	calls int
+}
+
+// ReturnError makes the operation fail with err
+func (e *Expectation) ReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Times sets the number of calls the expectation is used for
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes makes the expectation match any number of calls, including none
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = 0
+	return e
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times > 0 && e.calls >= e.times
+}
+
+func (e *Expectation) unmet() bool {
+	return e.times > 0 && e.calls < e.times
+}
+
+// SearchExpectation is an expected Search or SearchWithPaging
+type SearchExpectation struct {
+	*Expectation
+}
+
+// Return makes the search return result
+func (e *SearchExpectation) Return(result *ldap.SearchResult) *SearchExpectation {
+	e.result = result
+	return e
+}
+
+// ReturnEntries makes the search return the given entries
+func (e *SearchExpectation) ReturnEntries(entries ...*ldap.Entry) *SearchExpectation {
+	return e.Return(&ldap.SearchResult{Entries: entries})
+}
+
+// BindExpectation is an expected Bind, UnauthenticatedBind or SimpleBind
+type BindExpectation struct {
+	*Expectation
+}
+
+// ReturnControls makes the bind return the given response controls, e.g. a
+// password policy control
+func (e *BindExpectation) ReturnControls(controls ...ldap.Control) *BindExpectation {
+	e.result = &ldap.SimpleBindResult{Controls: controls}
+	return e
+}
+
+// CompareExpectation is an expected Compare
+type CompareExpectation struct {
+	*Expectation
+}
+
+// Return makes the comparison return matched
+func (e *CompareExpectation) Return(matched bool) *CompareExpectation {
+	e.result = matched
+	return e
+}
+
+// PasswordModifyExpectation is an expected PasswordModify
+type PasswordModifyExpectation struct {
+	*Expectation
+}
+
+// Return makes the password modify operation return result
+func (e *PasswordModifyExpectation) Return(result *ldap.PasswordModifyResult) *PasswordModifyExpectation {
+	e.result = result
+	return e
+}
+
+// WhoAmIExpectation is an expected WhoAmI
+type WhoAmIExpectation struct {
+	*Expectation
+}
+
+// Return makes the operation return authzID
+func (e *WhoAmIExpectation) Return(authzID string) *WhoAmIExpectation {
+	e.result = &ldap.WhoAmIResult{AuthzID: authzID}
+	return e
+}
+
+// ExtendedExpectation is an expected Extended
+type ExtendedExpectation struct {
+	*Expectation
+}
+
+// Return makes the extended operation return response
+func (e *ExtendedExpectation) Return(response *ldap.ExtendedResponse) *ExtendedExpectation {
+	e.result = response
+	return e
+}
+
+// SyncreplExpectation is an expected Syncrepl
+type SyncreplExpectation struct {
+	*Expectation
+}
+
+type syncreplResult struct {
+	entries []*ldap.Entry
+	done    *ldap.ControlSyncDone
+}
+
+func (e *SyncreplExpectation) syncreplResult() *syncreplResult {
+	if r, ok := e.result.(*syncreplResult); ok {
+		return r
+	}
+	r := &syncreplResult{}
+	e.result = r
+	return r
+}
+
+// ReturnEntries makes the operation pass the given entries to the entry
+// callback with the add state
+func (e *SyncreplExpectation) ReturnEntries(entries ...*ldap.Entry) *SyncreplExpectation {
+	e.syncreplResult().entries = entries
+	return e
+}
+
+// Return makes the operation return done
+func (e *SyncreplExpectation) Return(done *ldap.ControlSyncDone) *SyncreplExpectation {
+	e.syncreplResult().done = done
+	return e
+}
+
+// expect registers an expectation for the operation
+func (m *MockClient) expect(operation string, match func(interface{}) bool) *Expectation {
+	e := &Expectation{operation: operation, match: match, times: 1}
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+// ExpectSearch expects a Search, SearchContext, SearchAsync,
+// SearchWithCallback or SearchWithPaging with a request accepted by match, or
+// any request if match is nil. The search returns an empty result unless
+// Return is used.
+func (m *MockClient) ExpectSearch(match func(*ldap.SearchRequest) bool) *SearchExpectation {
+	return &SearchExpectation{m.expect("Search", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.SearchRequest))
+	})}
+}
+
+// ExpectDirSync expects a DirSync with a request accepted by match, or any
+// request if match is nil. It returns an empty result unless Return is used.
+func (m *MockClient) ExpectDirSync(match func(*ldap.SearchRequest) bool) *SearchExpectation {
+	return &SearchExpectation{m.expect("DirSync", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.SearchRequest))
+	})}
+}
+
+// ExpectSyncrepl expects a Syncrepl with a request accepted by match, or any
+// request if match is nil
+func (m *MockClient) ExpectSyncrepl(match func(*ldap.SearchRequest) bool) *SyncreplExpectation {
+	return &SyncreplExpectation{m.expect("Syncrepl", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.SearchRequest))
+	})}
+}
+
+// ExpectBind expects a simple bind with the given credentials. Bind,
+// UnauthenticatedBind, SimpleBind and SimpleBindContext all match it.
+func (m *MockClient) ExpectBind(username, password string) *BindExpectation {
+	return &BindExpectation{m.expect("Bind", func(req interface{}) bool {
+		r := req.(*ldap.SimpleBindRequest)
+		return r.Username == username && r.Password == password
+	})}
+}
+
+// ExpectExternalBind expects an ExternalBind
+func (m *MockClient) ExpectExternalBind() *Expectation {
+	return m.expect("ExternalBind", nil)
+}
+
+// ExpectNTLMUnauthenticatedBind expects an NTLMUnauthenticatedBind with the
+// given domain and username
+func (m *MockClient) ExpectNTLMUnauthenticatedBind(domain, username string) *Expectation {
+	return m.expect("NTLMUnauthenticatedBind", func(req interface{}) bool {
+		return req == domain+"\\"+username
+	})
+}
+
+// ExpectDigestMD5Bind expects an MD5Bind or DigestMD5Bind with the given
+// credentials
+func (m *MockClient) ExpectDigestMD5Bind(username, password string) *Expectation {
+	return m.expect("DigestMD5Bind", func(req interface{}) bool {
+		r := req.(*ldap.DigestMD5BindRequest)
+		return r.Username == username && r.Password == password
+	})
+}
+
+// ExpectNTLMBind expects an NTLMBind, NTLMBindWithHash or NTLMChallengeBind
+// with the given domain and username
+func (m *MockClient) ExpectNTLMBind(domain, username string) *Expectation {
+	return m.expect("NTLMBind", func(req interface{}) bool {
+		r := req.(*ldap.NTLMBindRequest)
+		return r.Domain == domain && r.Username == username
+	})
+}
+
+// ExpectGSSAPIBind expects a GSSAPIBind or GSSAPIBindRequest for the given
+// service principal
+func (m *MockClient) ExpectGSSAPIBind(servicePrincipal string) *Expectation {
+	return m.expect("GSSAPIBind", func(req interface{}) bool {
+		return req.(*ldap.GSSAPIBindRequest).ServicePrincipalName == servicePrincipal
+	})
+}
+
+// ExpectWhoAmI expects a WhoAmI. It returns an empty authzId unless Return
+// is used.
+func (m *MockClient) ExpectWhoAmI() *WhoAmIExpectation {
+	return &WhoAmIExpectation{m.expect("WhoAmI", nil)}
+}
+
+// ExpectExtended expects an Extended or ExtendedContext of the operation
+// with the given OID. It returns an empty response unless Return is used.
+func (m *MockClient) ExpectExtended(name string) *ExtendedExpectation {
+	return &ExtendedExpectation{m.expect("Extended", func(req interface{}) bool {
+		return req.(*ldap.ExtendedRequest).Name == name
+	})}
+}
+
+// ExpectUnbind expects an Unbind
+func (m *MockClient) ExpectUnbind() *Expectation {
+	return m.expect("Unbind", nil)
+}
+
+// ExpectStartTLS expects a StartTLS
+func (m *MockClient) ExpectStartTLS() *Expectation {
+	return m.expect("StartTLS", nil)
+}
+
+// ExpectAdd expects an Add, AddWithResult or their Context variants with a request accepted by
+// match, or any request if match is nil
+func (m *MockClient) ExpectAdd(match func(*ldap.AddRequest) bool) *Expectation {
+	return m.expect("Add", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.AddRequest))
+	})
+}
+
+// ExpectDel expects a Del, DelWithResult or their Context variants with a request accepted by
+// match, or any request if match is nil
+func (m *MockClient) ExpectDel(match func(*ldap.DelRequest) bool) *Expectation {
+	return m.expect("Del", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.DelRequest))
+	})
+}
+
+// ExpectModify expects a Modify, ModifyWithResult or their Context variants
+// with a request accepted by match, or any request if match is nil
+func (m *MockClient) ExpectModify(match func(*ldap.ModifyRequest) bool) *Expectation {
+	return m.expect("Modify", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.ModifyRequest))
+	})
+}
+
+// ExpectModifyDN expects a ModifyDN, ModifyDNWithResult or their Context
+// variants with a request accepted by match, or any request if match is nil
+func (m *MockClient) ExpectModifyDN(match func(*ldap.ModifyDNRequest) bool) *Expectation {
+	return m.expect("ModifyDN", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.ModifyDNRequest))
+	})
+}
+
+// ExpectCompare expects a Compare, CompareWithResult or their Context variants
+// of the given value. It returns false unless Return is used.
+func (m *MockClient) ExpectCompare(dn, attribute, value string) *CompareExpectation {
+	return &CompareExpectation{m.expect("Compare", func(req interface{}) bool {
+		r := req.(*ldap.CompareRequest)
+		return r.DN == dn && r.Attribute == attribute && r.Value == value
+	})}
+}
+
+// ExpectPasswordModify expects a PasswordModify or PasswordModifyContext
+// with a request accepted by match, or any request if match is nil
+func (m *MockClient) ExpectPasswordModify(match func(*ldap.PasswordModifyRequest) bool) *PasswordModifyExpectation {
+	return &PasswordModifyExpectation{m.expect("PasswordModify", func(req interface{}) bool {
+		return match == nil || match(req.(*ldap.PasswordModifyRequest))
+	})}
+}
+
+// ExpectationsWereMet returns an error listing the expectations which were
+// not called as often as expected
+func (m *MockClient) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var unmet []string
+	for _, e := range m.expectations {
+		if e.unmet() {
+			unmet = append(unmet, fmt.Sprintf("%s called %d of %d times", e.operation, e.calls, e.times))
+		}
+	}
+	if len(unmet) > 0 {
+		return fmt.Errorf("ldaptest: unmet expectations: %s", strings.Join(unmet, ", "))
+	}
+	return nil
+}
+
+// call consumes the first matching expectation of the operation
+func (m *MockClient) call(operation string, request interface{}) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expectations {
+		if e.operation != operation || e.exhausted() {
+			continue
+		}
+		if e.match != nil && !e.match(request) {
+			continue
+		}
+		e.calls++
+		return e, e.err
+	}
+	return nil, fmt.Errorf("%w: %s %+v", ErrUnexpectedCall, operation, request)
+}
+
+// Start does nothing
+func (m *MockClient) Start() {}
+
+// StartTLS consumes an ExpectStartTLS expectation
+func (m *MockClient) StartTLS(*tls.Config) error {
+	if _, err := m.call("StartTLS", nil); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.tls = true
+	m.mu.Unlock()
+	return nil
+}
+
+// Close marks the client as closing
+func (m *MockClient) Close() {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+}
+
+// IsClosing returns true once Close was called
+func (m *MockClient) IsClosing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closing
+}
+
+// SetTimeout records the timeout
+func (m *MockClient) SetTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	m.timeout = timeout
+	m.mu.Unlock()
+}
+
+// TLSConnectionState returns an empty state, which is reported as valid
+// after a successful StartTLS
+func (m *MockClient) TLSConnectionState() (tls.ConnectionState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return tls.ConnectionState{}, m.tls
+}
+
+// Bind consumes an ExpectBind expectation
+func (m *MockClient) Bind(username, password string) error {
+	_, err := m.SimpleBind(ldap.NewSimpleBindRequest(username, password, nil))
+	return err
+}
+
+// UnauthenticatedBind consumes an ExpectBind expectation with an empty
+// password
+func (m *MockClient) UnauthenticatedBind(username string) error {
+	req := ldap.NewSimpleBindRequest(username, "", nil)
+	req.AllowEmptyPassword = true
+	_, err := m.SimpleBind(req)
+	return err
+}
+
+// SimpleBind consumes an ExpectBind expectation
+func (m *MockClient) SimpleBind(req *ldap.SimpleBindRequest) (*ldap.SimpleBindResult, error) {
+	e, err := m.call("Bind", req)
+	if e == nil {
+		return nil, err
+	}
+	// the controls are returned with errors as well, e.g. an expired password
+	if result, ok := e.result.(*ldap.SimpleBindResult); ok {
+		return result, err
+	}
+	return &ldap.SimpleBindResult{}, err
+}
+
+// ExternalBind consumes an ExpectExternalBind expectation
+func (m *MockClient) ExternalBind() error {
+	_, err := m.call("ExternalBind", nil)
+	return err
+}
+
+// NTLMUnauthenticatedBind consumes an ExpectNTLMUnauthenticatedBind
+// expectation
+func (m *MockClient) NTLMUnauthenticatedBind(domain, username string) error {
+	_, err := m.call("NTLMUnauthenticatedBind", domain+"\\"+username)
+	return err
+}
+
+// Unbind consumes an ExpectUnbind expectation
+func (m *MockClient) Unbind() error {
+	_, err := m.call("Unbind", nil)
+	return err
+}
+
+// SimpleBindContext consumes an ExpectBind expectation
+func (m *MockClient) SimpleBindContext(ctx context.Context, req *ldap.SimpleBindRequest) (*ldap.SimpleBindResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.SimpleBind(req)
+}
+
+// MD5Bind consumes an ExpectDigestMD5Bind expectation
+func (m *MockClient) MD5Bind(host, username, password string) error {
+	_, err := m.DigestMD5Bind(&ldap.DigestMD5BindRequest{Host: host, Username: username, Password: password})
+	return err
+}
+
+// DigestMD5Bind consumes an ExpectDigestMD5Bind expectation
+func (m *MockClient) DigestMD5Bind(req *ldap.DigestMD5BindRequest) (*ldap.DigestMD5BindResult, error) {
+	if _, err := m.call("DigestMD5Bind", req); err != nil {
+		return nil, err
+	}
+	return &ldap.DigestMD5BindResult{}, nil
+}
+
+// NTLMBind consumes an ExpectNTLMBind expectation
+func (m *MockClient) NTLMBind(domain, username, password string) error {
+	_, err := m.NTLMChallengeBind(&ldap.NTLMBindRequest{Domain: domain, Username: username, Password: password})
+	return err
+}
+
+// NTLMBindWithHash consumes an ExpectNTLMBind expectation
+func (m *MockClient) NTLMBindWithHash(domain, username, hash string) error {
+	_, err := m.NTLMChallengeBind(&ldap.NTLMBindRequest{Domain: domain, Username: username, Hash: hash})
+	return err
+}
+
+// NTLMChallengeBind consumes an ExpectNTLMBind expectation
+func (m *MockClient) NTLMChallengeBind(req *ldap.NTLMBindRequest) (*ldap.NTLMBindResult, error) {
+	if _, err := m.call("NTLMBind", req); err != nil {
+		return nil, err
+	}
+	return &ldap.NTLMBindResult{}, nil
+}
+
+// GSSAPIBind consumes an ExpectGSSAPIBind expectation
+func (m *MockClient) GSSAPIBind(client ldap.GSSAPIClient, servicePrincipal, authzid string) error {
+	return m.GSSAPIBindRequest(client, &ldap.GSSAPIBindRequest{ServicePrincipalName: servicePrincipal, AuthZID: authzid})
+}
+
+// GSSAPIBindRequest consumes an ExpectGSSAPIBind expectation
+func (m *MockClient) GSSAPIBindRequest(client ldap.GSSAPIClient, req *ldap.GSSAPIBindRequest) error {
+	_, err := m.call("GSSAPIBind", req)
+	return err
+}
+
+// AddContext consumes an ExpectAdd expectation
+func (m *MockClient) AddContext(ctx context.Context, req *ldap.AddRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Add(req)
+}
+
+// AddWithResultContext consumes an ExpectAdd expectation
+func (m *MockClient) AddWithResultContext(ctx context.Context, req *ldap.AddRequest) (*ldap.WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.AddWithResult(req)
+}
+
+// DelContext consumes an ExpectDel expectation
+func (m *MockClient) DelContext(ctx context.Context, req *ldap.DelRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Del(req)
+}
+
+// DelWithResultContext consumes an ExpectDel expectation
+func (m *MockClient) DelWithResultContext(ctx context.Context, req *ldap.DelRequest) (*ldap.WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.DelWithResult(req)
+}
+
+// ModifyContext consumes an ExpectModify expectation
+func (m *MockClient) ModifyContext(ctx context.Context, req *ldap.ModifyRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Modify(req)
+}
+
+// ModifyDNContext consumes an ExpectModifyDN expectation
+func (m *MockClient) ModifyDNContext(ctx context.Context, req *ldap.ModifyDNRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.ModifyDN(req)
+}
+
+// ModifyDNWithResultContext consumes an ExpectModifyDN expectation
+func (m *MockClient) ModifyDNWithResultContext(ctx context.Context, req *ldap.ModifyDNRequest) (*ldap.WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ModifyDNWithResult(req)
+}
+
+// ModifyWithResultContext consumes an ExpectModify expectation
+func (m *MockClient) ModifyWithResultContext(ctx context.Context, req *ldap.ModifyRequest) (*ldap.ModifyResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ModifyWithResult(req)
+}
+
+// CompareContext consumes an ExpectCompare expectation
+func (m *MockClient) CompareContext(ctx context.Context, dn, attribute, value string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return m.Compare(dn, attribute, value)
+}
+
+// CompareWithResultContext consumes an ExpectCompare expectation
+func (m *MockClient) CompareWithResultContext(ctx context.Context, req *ldap.CompareRequest) (*ldap.CompareResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.CompareWithResult(req)
+}
+
+// PasswordModifyContext consumes an ExpectPasswordModify expectation
+func (m *MockClient) PasswordModifyContext(ctx context.Context, req *ldap.PasswordModifyRequest) (*ldap.PasswordModifyResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.PasswordModify(req)
+}
+
+// WhoAmI consumes an ExpectWhoAmI expectation
+func (m *MockClient) WhoAmI(controls []ldap.Control) (*ldap.WhoAmIResult, error) {
+	e, err := m.call("WhoAmI", controls)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := e.result.(*ldap.WhoAmIResult); ok {
+		return result, nil
+	}
+	return &ldap.WhoAmIResult{}, nil
+}
+
+// Extended consumes an ExpectExtended expectation
+func (m *MockClient) Extended(req *ldap.ExtendedRequest) (*ldap.ExtendedResponse, error) {
+	e, err := m.call("Extended", req)
+	if err != nil {
+		return nil, err
+	}
+	if response, ok := e.result.(*ldap.ExtendedResponse); ok {
+		return response, nil
+	}
+	return &ldap.ExtendedResponse{}, nil
+}
+
+// ExtendedContext consumes an ExpectExtended expectation
+func (m *MockClient) ExtendedContext(ctx context.Context, req *ldap.ExtendedRequest) (*ldap.ExtendedResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Extended(req)
+}
+
+// Add consumes an ExpectAdd expectation
+func (m *MockClient) Add(req *ldap.AddRequest) error {
+	_, err := m.call("Add", req)
+	return err
+}
+
+// AddWithResult consumes an ExpectAdd expectation
+func (m *MockClient) AddWithResult(req *ldap.AddRequest) (*ldap.WriteResult, error) {
+	if _, err := m.call("Add", req); err != nil {
+		return nil, err
+	}
+	return &ldap.WriteResult{}, nil
+}
+
+// Del consumes an ExpectDel expectation
+func (m *MockClient) Del(req *ldap.DelRequest) error {
+	_, err := m.call("Del", req)
+	return err
+}
+
+// DelWithResult consumes an ExpectDel expectation
+func (m *MockClient) DelWithResult(req *ldap.DelRequest) (*ldap.WriteResult, error) {
+	if _, err := m.call("Del", req); err != nil {
+		return nil, err
+	}
+	return &ldap.WriteResult{}, nil
+}
+
+// Modify consumes an ExpectModify expectation
+func (m *MockClient) Modify(req *ldap.ModifyRequest) error {
+	_, err := m.call("Modify", req)
+	return err
+}
+
+// ModifyDN consumes an ExpectModifyDN expectation
+func (m *MockClient) ModifyDN(req *ldap.ModifyDNRequest) error {
+	_, err := m.call("ModifyDN", req)
+	return err
+}
+
+// ModifyDNWithResult consumes an ExpectModifyDN expectation
+func (m *MockClient) ModifyDNWithResult(req *ldap.ModifyDNRequest) (*ldap.WriteResult, error) {
+	if _, err := m.call("ModifyDN", req); err != nil {
+		return nil, err
+	}
+	return &ldap.WriteResult{}, nil
+}
+
+// ModifyWithResult consumes an ExpectModify expectation
+func (m *MockClient) ModifyWithResult(req *ldap.ModifyRequest) (*ldap.ModifyResult, error) {
+	if _, err := m.call("Modify", req); err != nil {
+		return nil, err
+	}
+	return &ldap.ModifyResult{}, nil
+}
+
+// Compare consumes an ExpectCompare expectation
+func (m *MockClient) Compare(dn, attribute, value string) (bool, error) {
+	e, err := m.call("Compare", &ldap.CompareRequest{DN: dn, Attribute: attribute, Value: value})
+	if err != nil {
+		return false, err
+	}
+	matched, _ := e.result.(bool)
+	return matched, nil
+}
+
+// CompareWithResult consumes an ExpectCompare expectation
+func (m *MockClient) CompareWithResult(req *ldap.CompareRequest) (*ldap.CompareResult, error) {
+	e, err := m.call("Compare", req)
+	if err != nil {
+		return nil, err
+	}
+	matched, _ := e.result.(bool)
+	return &ldap.CompareResult{Matched: matched}, nil
+}
+
+// PasswordModify consumes an ExpectPasswordModify expectation
+func (m *MockClient) PasswordModify(req *ldap.PasswordModifyRequest) (*ldap.PasswordModifyResult, error) {
+	e, err := m.call("PasswordModify", req)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := e.result.(*ldap.PasswordModifyResult); ok {
+		return result, nil
+	}
+	return &ldap.PasswordModifyResult{}, nil
+}
+
+// Search consumes an ExpectSearch expectation
+func (m *MockClient) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	e, err := m.call("Search", req)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := e.result.(*ldap.SearchResult); ok {
+		return result, nil
+	}
+	return &ldap.SearchResult{}, nil
+}
+
+// SearchContext consumes an ExpectSearch expectation
+func (m *MockClient) SearchContext(ctx context.Context, req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Search(req)
+}
+
+// SearchAsync consumes an ExpectSearch expectation. The results are available
+// from the returned Response immediately.
+func (m *MockClient) SearchAsync(ctx context.Context, req *ldap.SearchRequest, bufferSize int) ldap.Response {
+	result, err := m.SearchContext(ctx, req)
+	if err != nil {
+		return &mockResponse{err: err}
+	}
+	return &mockResponse{result: result}
+}
+
+// SearchWithCallback consumes an ExpectSearch expectation, passing the
+// entries, referrals and controls of its result to the callbacks
+func (m *MockClient) SearchWithCallback(req *ldap.SearchRequest, onEntry func(*ldap.Entry) error, onReferral func([]string), onControls func([]ldap.Control)) error {
+	result, err := m.Search(req)
+	if err != nil {
+		return err
+	}
+	for _, entry := range result.Entries {
+		if err := onEntry(entry); err != nil {
+			return err
+		}
+	}
+	if onReferral != nil && len(result.Referrals) > 0 {
+		onReferral(result.Referrals)
+	}
+	if onControls != nil && len(result.Controls) > 0 {
+		onControls(result.Controls)
+	}
+	return nil
+}
+
+// SearchWithPaging consumes an ExpectSearch expectation
+func (m *MockClient) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	return m.Search(req)
+}
+
+// DirSync consumes an ExpectDirSync expectation
+func (m *MockClient) DirSync(req *ldap.SearchRequest, flags, maxAttrCount int64, cookie []byte) (*ldap.SearchResult, error) {
+	e, err := m.call("DirSync", req)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := e.result.(*ldap.SearchResult); ok {
+		return result, nil
+	}
+	return &ldap.SearchResult{}, nil
+}
+
+// Syncrepl consumes an ExpectSyncrepl expectation, passing its entries to
+// onEntry
+func (m *MockClient) Syncrepl(ctx context.Context, req *ldap.SearchRequest, control *ldap.ControlSyncRequest, onEntry func(*ldap.Entry, *ldap.ControlSyncState) error, onInfo func(*ldap.SyncInfo) error) (*ldap.ControlSyncDone, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	e, err := m.call("Syncrepl", req)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := e.result.(*syncreplResult)
+	if result == nil {
+		return &ldap.ControlSyncDone{}, nil
+	}
+	for _, entry := range result.entries {
+		if err := onEntry(entry, &ldap.ControlSyncState{State: ldap.SyncStateAdd}); err != nil {
+			return nil, err
+		}
+	}
+	if result.done == nil {
+		return &ldap.ControlSyncDone{}, nil
+	}
+	return result.done, nil
+}
+
+// mockResponse is the ldap.Response of SearchAsync
+type mockResponse struct {
+	result *ldap.SearchResult
+	err    error
+	// index is the number of results returned by Next
+	index int
+}
+
+func (r *mockResponse) results() int {
+	if r.result == nil {
+		return 0
+	}
+	return len(r.result.Entries) + len(r.result.Referrals)
+}
+
+func (r *mockResponse) Next() bool {
+	if r.index >= r.results() {
+		return false
+	}
+	r.index++
+	return true
+}
+
+func (r *mockResponse) Entry() *ldap.Entry {
+	if r.index == 0 || r.index > len(r.result.Entries) {
+		return nil
+	}
+	return r.result.Entries[r.index-1]
+}
+
+func (r *mockResponse) Referral() string {
+	if r.index <= len(r.result.Entries) {
+		return ""
+	}
+	return r.result.Referrals[r.index-len(r.result.Entries)-1]
+}
+
+func (r *mockResponse) Controls() []ldap.Control {
+	if r.result == nil || r.index < r.results() {
+		return nil
+	}
+	return r.result.Controls
+}
+
+func (r *mockResponse) Err() error {
+	return r.err
+}
+
+// SearchBaseDN returns a search matcher accepting requests with the given
+// base DN
+func SearchBaseDN(baseDN string) func(*ldap.SearchRequest) bool {
+	return func(req *ldap.SearchRequest) bool {
+		return strings.EqualFold(req.BaseDN, baseDN)
+	}
+}
+
+// SearchFilter returns a search matcher accepting requests with the given
+// filter
+func SearchFilter(filter string) func(*ldap.SearchRequest) bool {
+	return func(req *ldap.SearchRequest) bool {
+		return req.Filter == filter
+	}
+}
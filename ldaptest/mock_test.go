@@ -0,0 +1,175 @@
+package ldaptest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+func TestMockClientSearch(t *testing.T) {
+	client := NewMockClient()
+	client.ExpectBind("cn=admin,dc=example,dc=com", "secret")
+	client.ExpectSearch(SearchFilter("(uid=jdoe)")).
+		ReturnEntries(ldap.NewEntry("uid=jdoe,dc=example,dc=com", map[string][]string{"cn": {"John Doe"}}))
+	client.ExpectSearch(SearchBaseDN("ou=groups,dc=example,dc=com"))
+
+	if err := client.Bind("cn=admin,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	// expectations match in any order as long as their matchers accept the request
+	result, err := client.SearchWithPaging(ldap.NewSearchRequest("ou=groups,dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(member=*)", nil, nil), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 0 {
+		t.Errorf("unexpected entries: %v", result.Entries)
+	}
+	result, err = client.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=jdoe)", nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].GetAttributeValue("cn") != "John Doe" {
+		t.Errorf("unexpected entries: %v", result.Entries)
+	}
+	if err := client.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// every expectation is used once by default
+	_, err = client.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=jdoe)", nil, nil))
+	if !errors.Is(err, ErrUnexpectedCall) {
+		t.Errorf("expected ErrUnexpectedCall, got %v", err)
+	}
+}
+
+func TestMockClientErrors(t *testing.T) {
+	client := NewMockClient()
+	client.ExpectBind("uid=jdoe,dc=example,dc=com", "wrong").
+		ReturnError(ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials")))
+	client.ExpectModify(func(req *ldap.ModifyRequest) bool {
+		return req.DN == "uid=jdoe,dc=example,dc=com"
+	}).ReturnError(ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("access denied"))).Times(2)
+
+	err := client.Bind("uid=jdoe,dc=example,dc=com", "wrong")
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	err = client.Modify(ldap.NewModifyRequest("uid=jdoe,dc=example,dc=com", nil))
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInsufficientAccessRights) {
+		t.Errorf("expected insufficient access rights, got %v", err)
+	}
+	if _, err := client.ModifyWithResult(ldap.NewModifyRequest("uid=other,dc=example,dc=com", nil)); !errors.Is(err, ErrUnexpectedCall) {
+		t.Errorf("expected ErrUnexpectedCall, got %v", err)
+	}
+
+	err = client.ExpectationsWereMet()
+	if err == nil || !strings.Contains(err.Error(), "Modify called 1 of 2 times") {
+		t.Errorf("unexpected result %v", err)
+	}
+}
+
+func TestMockClientOperations(t *testing.T) {
+	client := NewMockClient()
+	client.ExpectStartTLS()
+	client.ExpectBind("uid=jdoe,dc=example,dc=com", "").
+		ReturnControls(ldap.NewControlBeheraPasswordPolicy())
+	client.ExpectAdd(nil)
+	client.ExpectDel(nil)
+	client.ExpectModifyDN(nil)
+	client.ExpectCompare("uid=jdoe,dc=example,dc=com", "sn", "Doe").Return(true)
+	client.ExpectPasswordModify(nil).Return(&ldap.PasswordModifyResult{GeneratedPassword: "generated"})
+	client.ExpectUnbind().AnyTimes()
+
+	if err := client.StartTLS(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.TLSConnectionState(); !ok {
+		t.Error("expected TLS connection state after StartTLS")
+	}
+	result, err := client.SimpleBind(&ldap.SimpleBindRequest{Username: "uid=jdoe,dc=example,dc=com", AllowEmptyPassword: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Controls) != 1 {
+		t.Errorf("unexpected controls: %v", result.Controls)
+	}
+	if err := client.Add(ldap.NewAddRequest("uid=jdoe,dc=example,dc=com", nil)); err != nil {
+		t.Error(err)
+	}
+	if err := client.Del(ldap.NewDelRequest("uid=jdoe,dc=example,dc=com", nil)); err != nil {
+		t.Error(err)
+	}
+	if err := client.ModifyDN(ldap.NewModifyDNRequest("uid=jdoe,dc=example,dc=com", "uid=john", true, "")); err != nil {
+		t.Error(err)
+	}
+	if matched, err := client.Compare("uid=jdoe,dc=example,dc=com", "sn", "Doe"); err != nil || !matched {
+		t.Errorf("unexpected compare result %v, %v", matched, err)
+	}
+	pwResult, err := client.PasswordModify(ldap.NewPasswordModifyRequest("", "", ""))
+	if err != nil || pwResult.GeneratedPassword != "generated" {
+		t.Errorf("unexpected password modify result %v, %v", pwResult, err)
+	}
+
+	client.Close()
+	if !client.IsClosing() {
+		t.Error("expected client to be closing")
+	}
+	if err := client.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := client.ExternalBind(); !errors.Is(err, ErrUnexpectedCall) {
+		t.Errorf("expected ErrUnexpectedCall, got %v", err)
+	}
+}
+
+func TestMockClientSearchVariants(t *testing.T) {
+	client := NewMockClient()
+	entries := []*ldap.Entry{
+		ldap.NewEntry("uid=a,dc=example,dc=com", nil),
+		ldap.NewEntry("uid=b,dc=example,dc=com", nil),
+	}
+	client.ExpectSearch(nil).ReturnEntries(entries...).Times(3)
+	client.ExpectWhoAmI().Return("dn:uid=a,dc=example,dc=com")
+	client.ExpectExtended("1.3.6.1.4.1.4203.1.11.3")
+
+	req := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(uid=*)", nil, nil)
+	response := client.SearchAsync(context.Background(), req, 0)
+	var dns []string
+	for response.Next() {
+		dns = append(dns, response.Entry().DN)
+	}
+	if response.Err() != nil || strings.Join(dns, " ") != "uid=a,dc=example,dc=com uid=b,dc=example,dc=com" {
+		t.Errorf("unexpected async results %v, %v", dns, response.Err())
+	}
+
+	count := 0
+	err := client.SearchWithCallback(req, func(*ldap.Entry) error {
+		count++
+		return nil
+	}, nil, nil)
+	if err != nil || count != 2 {
+		t.Errorf("unexpected callback results %d, %v", count, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.SearchContext(ctx, req); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if _, err := client.SearchContext(context.Background(), req); err != nil {
+		t.Error(err)
+	}
+
+	if result, err := client.WhoAmI(nil); err != nil || result.AuthzID != "dn:uid=a,dc=example,dc=com" {
+		t.Errorf("unexpected WhoAmI result %v, %v", result, err)
+	}
+	if _, err := client.Extended(ldap.NewExtendedRequest("1.3.6.1.4.1.4203.1.11.3", nil)); err != nil {
+		t.Error(err)
+	}
+	if err := client.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,729 @@
+package ldaptest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap"
+)
+
+// Server is an in-memory LDAP server for integration tests. It supports
+// simple binds, searches with filter evaluation and the paged results
+// control, compare, add, modify and delete on a directory seeded with
+// AddEntry or LoadLDIF. Other operations fail with unwillingToPerform.
+//
+//	server := ldaptest.NewServer()
+//	if err := server.LoadLDIF(strings.NewReader(ldif)); err != nil {
+//		t.Fatal(err)
+//	}
+//	if err := server.Start(); err != nil {
+//		t.Fatal(err)
+//	}
+//	defer server.Close()
+//
+//	conn, err := ldap.DialURL(server.URL())
+//
+// A simple bind succeeds anonymously or if the password equals a userPassword
+// value of the entry. There is no access control, anonymous connections may
+// perform any operation.
+type Server struct {
+	mu       sync.RWMutex
+	entries  []*serverEntry
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type serverEntry struct {
+	dn    *ldap.DN
+	entry *ldap.Entry
+}
+
+// NewServer returns a server with an empty directory
+func NewServer() *Server {
+	return &Server{conns: make(map[net.Conn]struct{})}
+}
+
+// LoadLDIF adds the entries of the LDIF content records in r, e.g. as
+// written by ldap.SearchResult.WriteLDIF
+func (s *Server) LoadLDIF(r io.Reader) error {
+	entries, err := ldap.ParseLDIF(r)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := s.AddEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddEntry adds a copy of entry to the directory. Unlike an add request, it
+// does not require the parent entry to exist.
+func (s *Server) AddEntry(entry *ldap.Entry) error {
+	dn, err := ldap.ParseDN(entry.DN)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(dn) >= 0 {
+		return fmt.Errorf("ldaptest: entry %s already exists", entry.DN)
+	}
+	s.entries = append(s.entries, &serverEntry{dn: dn, entry: copyEntry(entry)})
+	return nil
+}
+
+// Entry returns a copy of the entry with the given DN, or nil if it does not
+// exist
+func (s *Server) Entry(dn string) *ldap.Entry {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.find(parsed); i >= 0 {
+		return copyEntry(s.entries[i].entry)
+	}
+	return nil
+}
+
+// Start listens on a random port of the loopback interface and serves
+// connections in the background until Close is called
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	// set before serving, so that Addr is available when Start returns
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = s.Serve(listener)
+	}()
+	return nil
+}
+
+// Serve accepts connections on listener and serves them until Close is
+// called. It always returns a non-nil error.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return errors.New("ldaptest: server closed")
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.RLock()
+			closed := s.closed
+			s.mu.RUnlock()
+			if closed {
+				return errors.New("ldaptest: server closed")
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Addr returns the address the server listens on, or an empty string if it
+// was not started
+func (s *Server) Addr() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// URL returns the ldap:// URL of the server for ldap.DialURL
+func (s *Server) URL() string {
+	return "ldap://" + s.Addr()
+}
+
+// Close stops the server, closes all connections and waits for their
+// handlers to return
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// serveConn handles the requests of a connection one after another until
+// it is closed or the client unbinds
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		messageID, ok := packet.Children[0].Value.(int64)
+		if !ok {
+			return
+		}
+		request := packet.Children[1]
+		var controls []*ber.Packet
+		if len(packet.Children) > 2 {
+			controls = packet.Children[2].Children
+		}
+
+		w := &responseWriter{conn: conn, messageID: messageID}
+		switch request.Tag {
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationAbandonRequest:
+			// requests are answered completely before the next is read
+			continue
+		case ldap.ApplicationBindRequest:
+			s.bind(w, request)
+		case ldap.ApplicationSearchRequest:
+			s.search(w, request, controls)
+		case ldap.ApplicationCompareRequest:
+			s.compare(w, request)
+		case ldap.ApplicationAddRequest:
+			s.add(w, request)
+		case ldap.ApplicationModifyRequest:
+			s.modify(w, request)
+		case ldap.ApplicationDelRequest:
+			s.del(w, request)
+		default:
+			w.result(request.Tag+1, ldap.LDAPResultUnwillingToPerform, "operation not supported")
+		}
+		if w.err != nil {
+			return
+		}
+	}
+}
+
+// responseWriter writes the responses to a request
+type responseWriter struct {
+	conn      net.Conn
+	messageID int64
+	err       error
+}
+
+// write sends op and the optional response controls
+func (w *responseWriter) write(op *ber.Packet, controls ...ldap.Control) {
+	if w.err != nil {
+		return
+	}
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, w.messageID, "MessageID"))
+	envelope.AppendChild(op)
+	if len(controls) > 0 {
+		packet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			packet.AppendChild(control.Encode())
+		}
+		envelope.AppendChild(packet)
+	}
+	_, w.err = w.conn.Write(envelope.Bytes())
+}
+
+// result sends an LDAPResult with the given application tag
+func (w *responseWriter) result(tag ber.Tag, code uint16, message string, controls ...ldap.Control) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, ldap.ApplicationMap[uint8(tag)])
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "Diagnostic Message"))
+	w.write(op, controls...)
+}
+
+// errResult sends the result for err, which is an *ldap.Error or an error
+// of a malformed request
+func (w *responseWriter) errResult(tag ber.Tag, err error) {
+	var ldapErr *ldap.Error
+	if errors.As(err, &ldapErr) {
+		w.result(tag, ldapErr.ResultCode, ldapErr.Err.Error())
+		return
+	}
+	w.result(tag, ldap.LDAPResultProtocolError, err.Error())
+}
+
+func (s *Server) bind(w *responseWriter, request *ber.Packet) {
+	if len(request.Children) != 3 {
+		w.result(ldap.ApplicationBindResponse, ldap.LDAPResultProtocolError, "invalid bind request")
+		return
+	}
+	if request.Children[2].Tag != 0 {
+		w.result(ldap.ApplicationBindResponse, ldap.LDAPResultAuthMethodNotSupported, "only simple binds are supported")
+		return
+	}
+	name, password := request.Children[1].Data.String(), request.Children[2].Data.String()
+	if name == "" && password == "" {
+		w.result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+		return
+	}
+
+	dn, err := ldap.ParseDN(name)
+	if err != nil {
+		w.result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "")
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i := s.find(dn); i >= 0 && password != "" {
+		for _, value := range s.entries[i].entry.GetEqualFoldAttributeValues("userPassword") {
+			if value == password {
+				w.result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+				return
+			}
+		}
+	}
+	w.result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "")
+}
+
+func (s *Server) search(w *responseWriter, request *ber.Packet, controls []*ber.Packet) {
+	if len(request.Children) != 8 {
+		w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, "invalid search request")
+		return
+	}
+	var paging *ldap.ControlPaging
+	for _, packet := range controls {
+		control, err := ldap.DecodeControl(packet)
+		if err != nil {
+			w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, err.Error())
+			return
+		}
+		if c, ok := control.(*ldap.ControlPaging); ok {
+			paging = c
+			continue
+		}
+		if len(packet.Children) > 1 && packet.Children[1].Value == true {
+			w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultUnavailableCriticalExtension, "unsupported critical control "+control.GetControlType())
+			return
+		}
+	}
+
+	baseDN := request.Children[0].Data.String()
+	scope, _ := request.Children[1].Value.(int64)
+	sizeLimit, _ := request.Children[3].Value.(int64)
+	typesOnly, _ := request.Children[5].Value.(bool)
+	filter := request.Children[6]
+	var attributes []string
+	for _, attribute := range request.Children[7].Children {
+		attributes = append(attributes, attribute.Data.String())
+	}
+
+	base, err := ldap.ParseDN(baseDN)
+	if err != nil {
+		w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInvalidDNSyntax, err.Error())
+		return
+	}
+
+	var entries []*ldap.Entry
+	s.mu.RLock()
+	if len(base.RDNs) == 0 && scope == ldap.ScopeBaseObject {
+		entries = append(entries, s.rootDSE())
+	} else if len(base.RDNs) > 0 && s.find(base) < 0 {
+		s.mu.RUnlock()
+		w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject, "")
+		return
+	}
+	for _, e := range s.entries {
+		if !inScope(base, e.dn, scope) {
+			continue
+		}
+		matched, err := matchFilter(filter, e.entry)
+		if err != nil {
+			s.mu.RUnlock()
+			w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, err.Error())
+			return
+		}
+		if matched {
+			entries = append(entries, e.entry)
+		}
+	}
+	s.mu.RUnlock()
+
+	// the cookie of the paged results control is the offset of the next page
+	var responseControls []ldap.Control
+	if paging != nil {
+		offset := 0
+		if len(paging.Cookie) > 0 {
+			if offset, err = strconv.Atoi(string(paging.Cookie)); err != nil || offset > len(entries) {
+				w.result(ldap.ApplicationSearchResultDone, ldap.LDAPResultUnwillingToPerform, "invalid paging cookie")
+				return
+			}
+		}
+		entries = entries[offset:]
+		response := ldap.NewControlPaging(0)
+		if paging.PagingSize > 0 && len(entries) > int(paging.PagingSize) {
+			entries = entries[:paging.PagingSize]
+			response.SetCookie([]byte(strconv.Itoa(offset + len(entries))))
+		}
+		responseControls = append(responseControls, response)
+	}
+
+	code := uint16(ldap.LDAPResultSuccess)
+	if sizeLimit > 0 && len(entries) > int(sizeLimit) {
+		entries = entries[:sizeLimit]
+		code = ldap.LDAPResultSizeLimitExceeded
+	}
+	for _, entry := range entries {
+		w.write(encodeEntry(entry, attributes, typesOnly))
+	}
+	w.result(ldap.ApplicationSearchResultDone, code, "", responseControls...)
+}
+
+// inScope returns true if dn is within the scope of a search below base
+func inScope(base, dn *ldap.DN, scope int64) bool {
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return base.EqualFold(dn)
+	case ldap.ScopeSingleLevel:
+		return len(dn.RDNs) == len(base.RDNs)+1 && base.AncestorOfFold(dn)
+	default:
+		return base.EqualFold(dn) || base.AncestorOfFold(dn)
+	}
+}
+
+// rootDSE returns the root DSE, listing the entries without a parent as
+// naming contexts
+func (s *Server) rootDSE() *ldap.Entry {
+	var namingContexts []string
+	for _, e := range s.entries {
+		if len(e.dn.RDNs) > 0 && s.find(parentDN(e.dn)) < 0 {
+			namingContexts = append(namingContexts, e.entry.DN)
+		}
+	}
+	return ldap.NewEntry("", map[string][]string{
+		"objectClass":          {"top"},
+		"namingContexts":       namingContexts,
+		"supportedControl":     {ldap.ControlTypePaging},
+		"supportedLDAPVersion": {"3"},
+	})
+}
+
+// encodeEntry encodes a search result entry with the requested attributes
+func encodeEntry(entry *ldap.Entry, attributes []string, typesOnly bool) *ber.Packet {
+	all := len(attributes) == 0
+	requested := make(map[string]bool)
+	for _, attribute := range attributes {
+		switch attribute {
+		case "*":
+			all = true
+		case "1.1":
+		default:
+			requested[strings.ToLower(attribute)] = true
+		}
+	}
+
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for _, attr := range entry.Attributes {
+		if !all && !requested[strings.ToLower(attr.Name)] {
+			continue
+		}
+		list.AppendChild(encodeAttribute(attr.Name, attr.Values, typesOnly))
+	}
+	op.AppendChild(list)
+	return op
+}
+
+func encodeAttribute(name string, values []string, typesOnly bool) *ber.Packet {
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+	set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+	if !typesOnly {
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+	}
+	seq.AppendChild(set)
+	return seq
+}
+
+func (s *Server) compare(w *responseWriter, request *ber.Packet) {
+	if len(request.Children) != 2 || len(request.Children[1].Children) != 2 {
+		w.result(ldap.ApplicationCompareResponse, ldap.LDAPResultProtocolError, "invalid compare request")
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, err := s.lookup(request.Children[0].Data.String())
+	if err != nil {
+		w.errResult(ldap.ApplicationCompareResponse, err)
+		return
+	}
+	attribute, assertion := request.Children[1].Children[0].Data.String(), request.Children[1].Children[1].Data.String()
+	values := e.entry.GetEqualFoldAttributeValues(attribute)
+	if len(values) == 0 {
+		w.result(ldap.ApplicationCompareResponse, ldap.LDAPResultNoSuchAttribute, "")
+		return
+	}
+	for _, value := range values {
+		if compareValues(value, assertion) == 0 {
+			w.result(ldap.ApplicationCompareResponse, ldap.LDAPResultCompareTrue, "")
+			return
+		}
+	}
+	w.result(ldap.ApplicationCompareResponse, ldap.LDAPResultCompareFalse, "")
+}
+
+func (s *Server) add(w *responseWriter, request *ber.Packet) {
+	if len(request.Children) != 2 {
+		w.result(ldap.ApplicationAddResponse, ldap.LDAPResultProtocolError, "invalid add request")
+		return
+	}
+	entry := &ldap.Entry{DN: request.Children[0].Data.String()}
+	for _, attribute := range request.Children[1].Children {
+		name, values, err := decodeAttribute(attribute)
+		if err != nil {
+			w.errResult(ldap.ApplicationAddResponse, err)
+			return
+		}
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(name, values))
+	}
+	dn, err := ldap.ParseDN(entry.DN)
+	if err != nil {
+		w.result(ldap.ApplicationAddResponse, ldap.LDAPResultInvalidDNSyntax, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(dn) >= 0 {
+		w.result(ldap.ApplicationAddResponse, ldap.LDAPResultEntryAlreadyExists, "")
+		return
+	}
+	// entries without any ancestor start a new naming context
+	if s.find(parentDN(dn)) < 0 {
+		for _, e := range s.entries {
+			if e.dn.AncestorOfFold(dn) {
+				w.result(ldap.ApplicationAddResponse, ldap.LDAPResultNoSuchObject, "parent entry does not exist")
+				return
+			}
+		}
+	}
+	s.entries = append(s.entries, &serverEntry{dn: dn, entry: entry})
+	w.result(ldap.ApplicationAddResponse, ldap.LDAPResultSuccess, "")
+}
+
+func (s *Server) modify(w *responseWriter, request *ber.Packet) {
+	if len(request.Children) != 2 {
+		w.result(ldap.ApplicationModifyResponse, ldap.LDAPResultProtocolError, "invalid modify request")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(request.Children[0].Data.String())
+	if err != nil {
+		w.errResult(ldap.ApplicationModifyResponse, err)
+		return
+	}
+
+	// the changes are applied to a copy, so that they are applied atomically
+	entry := copyEntry(e.entry)
+	for _, change := range request.Children[1].Children {
+		if len(change.Children) != 2 {
+			w.result(ldap.ApplicationModifyResponse, ldap.LDAPResultProtocolError, "invalid change")
+			return
+		}
+		operation, _ := change.Children[0].Value.(int64)
+		name, values, err := decodeAttribute(change.Children[1])
+		if err == nil {
+			err = applyChange(entry, uint(operation), name, values)
+		}
+		if err != nil {
+			w.errResult(ldap.ApplicationModifyResponse, err)
+			return
+		}
+	}
+	e.entry = entry
+	w.result(ldap.ApplicationModifyResponse, ldap.LDAPResultSuccess, "")
+}
+
+// applyChange applies a single modification to entry
+func applyChange(entry *ldap.Entry, operation uint, name string, values []string) error {
+	index := -1
+	for i, attr := range entry.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			index = i
+			break
+		}
+	}
+	var current []string
+	if index >= 0 {
+		current = entry.Attributes[index].Values
+	}
+
+	switch operation {
+	case ldap.AddAttribute:
+		for _, value := range values {
+			if indexOfValue(current, value) >= 0 {
+				return ldap.NewError(ldap.LDAPResultAttributeOrValueExists, fmt.Errorf("value '%s' of %s already exists", value, name))
+			}
+			current = append(current, value)
+		}
+	case ldap.DeleteAttribute:
+		if index < 0 {
+			return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("attribute %s does not exist", name))
+		}
+		if len(values) == 0 {
+			current = nil
+		}
+		for _, value := range values {
+			i := indexOfValue(current, value)
+			if i < 0 {
+				return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("value '%s' of %s does not exist", value, name))
+			}
+			current = append(current[:i:i], current[i+1:]...)
+		}
+	case ldap.ReplaceAttribute:
+		current = values
+	case ldap.IncrementAttribute:
+		if index < 0 || len(values) != 1 {
+			return ldap.NewError(ldap.LDAPResultNoSuchAttribute, fmt.Errorf("attribute %s cannot be incremented", name))
+		}
+		delta, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			return ldap.NewError(ldap.LDAPResultConstraintViolation, fmt.Errorf("invalid increment '%s'", values[0]))
+		}
+		incremented := make([]string, len(current))
+		for i, value := range current {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ldap.NewError(ldap.LDAPResultConstraintViolation, fmt.Errorf("value '%s' of %s is not an integer", value, name))
+			}
+			incremented[i] = strconv.FormatInt(n+delta, 10)
+		}
+		current = incremented
+	default:
+		return ldap.NewError(ldap.LDAPResultProtocolError, fmt.Errorf("invalid modify operation %d", operation))
+	}
+
+	switch {
+	case index >= 0 && len(current) == 0:
+		entry.Attributes = append(entry.Attributes[:index], entry.Attributes[index+1:]...)
+	case index >= 0:
+		entry.Attributes[index] = ldap.NewEntryAttribute(entry.Attributes[index].Name, current)
+	case len(current) > 0:
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(name, current))
+	}
+	return nil
+}
+
+func indexOfValue(values []string, value string) int {
+	for i, v := range values {
+		if compareValues(v, value) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) del(w *responseWriter, request *ber.Packet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(request.Data.String())
+	if err != nil {
+		w.errResult(ldap.ApplicationDelResponse, err)
+		return
+	}
+	for _, other := range s.entries {
+		if e.dn.AncestorOfFold(other.dn) {
+			w.result(ldap.ApplicationDelResponse, ldap.LDAPResultNotAllowedOnNonLeaf, "")
+			return
+		}
+	}
+	i := s.find(e.dn)
+	s.entries = append(s.entries[:i], s.entries[i+1:]...)
+	w.result(ldap.ApplicationDelResponse, ldap.LDAPResultSuccess, "")
+}
+
+// decodeAttribute decodes an attribute type with its set of values
+func decodeAttribute(packet *ber.Packet) (string, []string, error) {
+	if len(packet.Children) != 2 {
+		return "", nil, ldap.NewError(ldap.LDAPResultProtocolError, errors.New("invalid attribute"))
+	}
+	var values []string
+	for _, value := range packet.Children[1].Children {
+		values = append(values, value.Data.String())
+	}
+	return packet.Children[0].Data.String(), values, nil
+}
+
+// lookup returns the entry with the given DN. The caller must hold s.mu.
+func (s *Server) lookup(dn string) (*serverEntry, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+	i := s.find(parsed)
+	if i < 0 {
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, fmt.Errorf("entry %s does not exist", dn))
+	}
+	return s.entries[i], nil
+}
+
+// find returns the index of the entry with the given DN, or -1. The caller
+// must hold s.mu.
+func (s *Server) find(dn *ldap.DN) int {
+	for i, e := range s.entries {
+		if e.dn.EqualFold(dn) {
+			return i
+		}
+	}
+	return -1
+}
+
+func parentDN(dn *ldap.DN) *ldap.DN {
+	if len(dn.RDNs) == 0 {
+		return dn
+	}
+	return &ldap.DN{RDNs: dn.RDNs[1:]}
+}
+
+func copyEntry(entry *ldap.Entry) *ldap.Entry {
+	c := &ldap.Entry{DN: entry.DN}
+	for _, attr := range entry.Attributes {
+		values := append([]string(nil), attr.Values...)
+		c.Attributes = append(c.Attributes, ldap.NewEntryAttribute(attr.Name, values))
+	}
+	return c
+}
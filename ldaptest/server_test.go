@@ -0,0 +1,234 @@
+package ldaptest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+const testLDIF = `version: 1
+
+dn: dc=example,dc=com
+objectClass: domain
+dc: example
+
+dn: ou=people,dc=example,dc=com
+objectClass: organizationalUnit
+ou: people
+
+dn: uid=jdoe,ou=people,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: jdoe
+cn: John Doe
+sn: Doe
+mail: jdoe@example.com
+uidNumber: 1001
+userPassword: secret
+
+dn: uid=asmith,ou=people,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: asmith
+cn: Alice Smith
+sn: Smith
+uidNumber: 1002
+`
+
+func startTestServer(t *testing.T) (*Server, *ldap.Conn) {
+	server := NewServer()
+	if err := server.LoadLDIF(strings.NewReader(testLDIF)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := ldap.DialURL(server.URL())
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return server, conn
+}
+
+func searchDNs(t *testing.T, conn *ldap.Conn, baseDN string, scope int, filter string) []string {
+	result, err := conn.Search(ldap.NewSearchRequest(baseDN, scope, ldap.NeverDerefAliases, 0, 0, false, filter, nil, nil))
+	if err != nil {
+		t.Fatalf("%s: %s", filter, err)
+	}
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	return dns
+}
+
+func TestServerBind(t *testing.T) {
+	server, conn := startTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	if err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "secret"); err != nil {
+		t.Error(err)
+	}
+	err := conn.Bind("uid=jdoe,ou=people,dc=example,dc=com", "wrong")
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	err = conn.Bind("uid=asmith,ou=people,dc=example,dc=com", "secret")
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestServerDialWithCredentials(t *testing.T) {
+	server, conn := startTestServer(t)
+	defer server.Close()
+	conn.Close()
+
+	conn, err := ldap.DialURL(server.URL(), ldap.DialWithCredentials(ldap.StaticCredentials("uid=jdoe,ou=people,dc=example,dc=com", []byte("secret"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	_, err = ldap.DialURL(server.URL(), ldap.DialWithCredentials(ldap.StaticCredentials("uid=jdoe,ou=people,dc=example,dc=com", []byte("wrong"))))
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+}
+
+func TestServerSearch(t *testing.T) {
+	server, conn := startTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	people := "ou=people,dc=example,dc=com"
+	tests := []struct {
+		baseDN   string
+		scope    int
+		filter   string
+		expected string
+	}{
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(objectClass=inetOrgPerson)", "uid=jdoe,ou=people,dc=example,dc=com uid=asmith,ou=people,dc=example,dc=com"},
+		{"dc=example,dc=com", ldap.ScopeSingleLevel, "(objectClass=*)", people},
+		{people, ldap.ScopeBaseObject, "(objectClass=*)", people},
+		{"DC=Example,DC=Com", ldap.ScopeWholeSubtree, "(&(cn=john*)(!(sn=Smith)))", "uid=jdoe,ou=people,dc=example,dc=com"},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(|(uid=asmith)(mail=*@example.com))", "uid=jdoe,ou=people,dc=example,dc=com uid=asmith,ou=people,dc=example,dc=com"},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(cn=*e Smi*)", "uid=asmith,ou=people,dc=example,dc=com"},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(uidNumber>=1002)", "uid=asmith,ou=people,dc=example,dc=com"},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(uidNumber<=1001)", "uid=jdoe,ou=people,dc=example,dc=com"},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(sn:caseExactMatch:=doe)", ""},
+		{"dc=example,dc=com", ldap.ScopeWholeSubtree, "(sn:caseExactMatch:=Doe)", "uid=jdoe,ou=people,dc=example,dc=com"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(searchDNs(t, conn, test.baseDN, test.scope, test.filter), " "); got != test.expected {
+			t.Errorf("%s %s: got %q, expected %q", test.baseDN, test.filter, got, test.expected)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest("uid=jdoe,ou=people,dc=example,dc=com", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"CN", "mail"}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 || len(result.Entries[0].Attributes) != 2 || result.Entries[0].GetAttributeValue("mail") != "jdoe@example.com" {
+		t.Errorf("unexpected attributes: %v", result.Entries[0].Attributes)
+	}
+
+	_, err = conn.Search(ldap.NewSearchRequest("dc=missing,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+	_, err = conn.Search(ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=*)", nil, nil))
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		t.Errorf("expected size limit exceeded, got %v", err)
+	}
+
+	rootDSE, err := conn.RootDSE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rootDSE.NamingContexts, " ") != "dc=example,dc=com" {
+		t.Errorf("unexpected naming contexts %v", rootDSE.NamingContexts)
+	}
+}
+
+func TestServerSearchWithPaging(t *testing.T) {
+	server, conn := startTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	for i := 0; i < 25; i++ {
+		req := ldap.NewAddRequest(fmt.Sprintf("uid=user%d,ou=people,dc=example,dc=com", i), nil)
+		req.Attribute("objectClass", []string{"inetOrgPerson"})
+		req.Attribute("uid", []string{fmt.Sprintf("user%d", i)})
+		if err := conn.Add(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := conn.SearchWithPaging(ldap.NewSearchRequest("ou=people,dc=example,dc=com", ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, "(uid=user*)", []string{"uid"}, nil), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 25 {
+		t.Errorf("expected 25 entries, got %d", len(result.Entries))
+	}
+}
+
+func TestServerUpdates(t *testing.T) {
+	server, conn := startTestServer(t)
+	defer server.Close()
+	defer conn.Close()
+
+	add := ldap.NewAddRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)
+	add.Attribute("objectClass", []string{"inetOrgPerson"})
+	add.Attribute("cn", []string{"Bruce Wayne"})
+	if err := conn.Add(add); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Add(add); !ldap.IsErrorWithCode(err, ldap.LDAPResultEntryAlreadyExists) {
+		t.Errorf("expected entry already exists, got %v", err)
+	}
+	orphan := ldap.NewAddRequest("uid=x,ou=missing,dc=example,dc=com", nil)
+	orphan.Attribute("objectClass", []string{"inetOrgPerson"})
+	if err := conn.Add(orphan); !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		t.Errorf("expected no such object, got %v", err)
+	}
+
+	modify := ldap.NewModifyRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)
+	modify.Add("mail", []string{"bruce@example.com"})
+	modify.Replace("cn", []string{"Batman"})
+	if err := conn.Modify(modify); err != nil {
+		t.Fatal(err)
+	}
+	entry := server.Entry("uid=bwayne,ou=people,dc=example,dc=com")
+	if entry.GetAttributeValue("cn") != "Batman" || entry.GetAttributeValue("mail") != "bruce@example.com" {
+		t.Errorf("unexpected entry after modify:\n%s", entry.ToLDIF())
+	}
+
+	// a failing change leaves the entry unchanged
+	modify = ldap.NewModifyRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)
+	modify.Delete("mail", nil)
+	modify.Delete("description", []string{"missing"})
+	if err := conn.Modify(modify); !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchAttribute) {
+		t.Errorf("expected no such attribute, got %v", err)
+	}
+	if server.Entry("uid=bwayne,ou=people,dc=example,dc=com").GetAttributeValue("mail") == "" {
+		t.Error("modify was not atomic")
+	}
+
+	matched, err := conn.Compare("uid=bwayne,ou=people,dc=example,dc=com", "cn", "batman")
+	if err != nil || !matched {
+		t.Errorf("unexpected compare result %v, %v", matched, err)
+	}
+
+	if err := conn.Del(ldap.NewDelRequest("ou=people,dc=example,dc=com", nil)); !ldap.IsErrorWithCode(err, ldap.LDAPResultNotAllowedOnNonLeaf) {
+		t.Errorf("expected not allowed on non leaf, got %v", err)
+	}
+	if err := conn.Del(ldap.NewDelRequest("uid=bwayne,ou=people,dc=example,dc=com", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if server.Entry("uid=bwayne,ou=people,dc=example,dc=com") != nil {
+		t.Error("entry was not deleted")
+	}
+}
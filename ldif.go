@@ -0,0 +1,214 @@
+package ldap
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ldifLineLength is the column at which LDIF lines are folded
+const ldifLineLength = 76
+
+// ToLDIF returns the entry as an LDIF (RFC 2849) record
+func (e *Entry) ToLDIF() string {
+	var sb strings.Builder
+	// writing to a strings.Builder never fails
+	_ = e.WriteLDIF(&sb)
+	return sb.String()
+}
+
+// WriteLDIF writes the entry as an LDIF (RFC 2849) record to w. Values which
+// are not safe strings, e.g. binary data or values with leading spaces, are
+// base64 encoded and long lines are folded.
+func (e *Entry) WriteLDIF(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	writeLDIFLine(bw, "dn", []byte(e.DN))
+	for _, attr := range e.Attributes {
+		if len(attr.ByteValues) == 0 {
+			for _, value := range attr.Values {
+				writeLDIFLine(bw, attr.Name, []byte(value))
+			}
+			continue
+		}
+		for _, value := range attr.ByteValues {
+			writeLDIFLine(bw, attr.Name, value)
+		}
+	}
+	return bw.Flush()
+}
+
+// ToLDIF returns the entries of the search result as LDIF (RFC 2849)
+func (s *SearchResult) ToLDIF() string {
+	var sb strings.Builder
+	// writing to a strings.Builder never fails
+	_ = s.WriteLDIF(&sb)
+	return sb.String()
+}
+
+// WriteLDIF writes the entries of the search result as LDIF (RFC 2849) to w,
+// separating the records by empty lines
+func (s *SearchResult) WriteLDIF(w io.Writer) error {
+	if _, err := io.WriteString(w, "version: 1\n"); err != nil {
+		return err
+	}
+	for _, entry := range s.Entries {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+		if err := entry.WriteLDIF(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeLDIFLine writes a single "name: value" line, folding it at
+// ldifLineLength. Errors are reported by the final Flush of w.
+func writeLDIFLine(w *bufio.Writer, name string, value []byte) {
+	line := name + ": " + string(value)
+	if !isLDIFSafeString(value) {
+		line = name + ":: " + base64.StdEncoding.EncodeToString(value)
+	}
+	for len(line) > ldifLineLength {
+		w.WriteString(line[:ldifLineLength])
+		w.WriteString("\n ")
+		line = line[ldifLineLength:]
+	}
+	w.WriteString(line)
+	w.WriteString("\n")
+}
+
+// isLDIFSafeString returns true if value can be written as is, as defined by
+// SAFE-STRING in RFC 2849
+func isLDIFSafeString(value []byte) bool {
+	if len(value) == 0 {
+		return true
+	}
+	switch value[0] {
+	case ' ', ':', '<':
+		return false
+	}
+	if value[len(value)-1] == ' ' {
+		return false
+	}
+	for _, c := range value {
+		if c == 0 || c == '\n' || c == '\r' || c > 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// ldifLine is a single "name: value" line of an LDIF record
+type ldifLine struct {
+	name  string
+	value string
+}
+
+// parseLDIFLines unfolds the lines of an LDIF fragment and decodes their
+// values. Comments and empty lines are skipped, a "-" separator line is
+// returned with an empty value.
+func parseLDIFLines(ldif string) ([]ldifLine, error) {
+	var unfolded []string
+	for _, line := range strings.Split(ldif, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, " ") && len(unfolded) > 0 {
+			unfolded[len(unfolded)-1] += line[1:]
+			continue
+		}
+		unfolded = append(unfolded, line)
+	}
+
+	var lines []ldifLine
+	for _, line := range unfolded {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line == "-" {
+			lines = append(lines, ldifLine{name: "-"})
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			return nil, fmt.Errorf("ldap: invalid LDIF line '%s'", line)
+		}
+		name, value := line[:colon], line[colon+1:]
+		switch {
+		case strings.HasPrefix(value, ":"):
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("ldap: invalid base64 value of '%s' in LDIF: %s", name, err)
+			}
+			value = string(decoded)
+		case strings.HasPrefix(value, "<"):
+			return nil, fmt.Errorf("ldap: URL values are not supported in LDIF: '%s'", line)
+		default:
+			value = strings.TrimLeft(value, " ")
+		}
+		lines = append(lines, ldifLine{name: name, value: value})
+	}
+	return lines, nil
+}
+
+// ParseLDIF reads the entries of the LDIF (RFC 2849) content records in r,
+// e.g. as written by SearchResult.WriteLDIF. Change records and URL values
+// are not supported.
+func ParseLDIF(r io.Reader) ([]*Entry, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	var record []string
+	flush := func() error {
+		lines, err := parseLDIFLines(strings.Join(record, "\n"))
+		record = record[:0]
+		if err != nil || len(lines) == 0 {
+			return err
+		}
+		if strings.EqualFold(lines[0].name, "version") && len(entries) == 0 {
+			lines = lines[1:]
+			if len(lines) == 0 {
+				return nil
+			}
+		}
+		if !strings.EqualFold(lines[0].name, "dn") {
+			return fmt.Errorf("ldap: LDIF record starts with '%s' instead of 'dn'", lines[0].name)
+		}
+		entry := &Entry{DN: lines[0].value}
+		index := make(map[string]*EntryAttribute)
+		for _, line := range lines[1:] {
+			if line.name == "-" || strings.EqualFold(line.name, "changetype") {
+				return fmt.Errorf("ldap: LDIF change records are not supported: %s", entry.DN)
+			}
+			key := strings.ToLower(line.name)
+			attr, ok := index[key]
+			if !ok {
+				attr = &EntryAttribute{Name: line.name}
+				index[key] = attr
+				entry.Attributes = append(entry.Attributes, attr)
+			}
+			attr.Values = append(attr.Values, line.value)
+			attr.ByteValues = append(attr.ByteValues, []byte(line.value))
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSuffix(line, "\r") == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		record = append(record, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,68 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEntryToLDIF(t *testing.T) {
+	entry := &Entry{
+		DN: "cn=bob,dc=example,dc=com",
+		Attributes: []*EntryAttribute{
+			NewEntryAttribute("objectClass", []string{"top", "person"}),
+			NewEntryAttribute("description", []string{" leading space", "ünïcode", ""}),
+			{Name: "objectGUID", ByteValues: [][]byte{{0x00, 0x01, 0xfe}}},
+			{Name: "sn", Values: []string{"Smith"}},
+			NewEntryAttribute("info", []string{strings.Repeat("x", 80)}),
+		},
+	}
+	expected := "dn: cn=bob,dc=example,dc=com\n" +
+		"objectClass: top\n" +
+		"objectClass: person\n" +
+		"description:: IGxlYWRpbmcgc3BhY2U=\n" +
+		"description:: w7xuw69jb2Rl\n" +
+		"description: \n" +
+		"objectGUID:: AAH+\n" +
+		"sn: Smith\n" +
+		"info: " + strings.Repeat("x", 70) + "\n " + strings.Repeat("x", 10) + "\n"
+	if got := entry.ToLDIF(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestSearchResultToLDIF(t *testing.T) {
+	result := &SearchResult{Entries: []*Entry{
+		NewEntry("cn=a,dc=example,dc=com", map[string][]string{"cn": {"a"}}),
+		NewEntry("cn=b,dc=example,dc=com", map[string][]string{"cn": {"b"}}),
+	}}
+	expected := "version: 1\n\ndn: cn=a,dc=example,dc=com\ncn: a\n\ndn: cn=b,dc=example,dc=com\ncn: b\n"
+	if got := result.ToLDIF(); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestParseLDIF(t *testing.T) {
+	result := &SearchResult{Entries: []*Entry{
+		{DN: "cn=bob,dc=example,dc=com", Attributes: []*EntryAttribute{
+			NewEntryAttribute("objectClass", []string{"top", "person"}),
+			NewEntryAttribute("description", []string{" leading space", strings.Repeat("x", 80)}),
+		}},
+		NewEntry("cn=alice,dc=example,dc=com", map[string][]string{"cn": {"alice"}}),
+	}}
+	// comments and additional empty lines are ignored
+	ldif := "# exported\n" + strings.Replace(result.ToLDIF(), "\n\n", "\n\n\n", -1)
+	entries, err := ParseLDIF(strings.NewReader(ldif))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (&SearchResult{Entries: entries}).ToLDIF(); got != result.ToLDIF() {
+		t.Errorf("got:\n%s\nwant:\n%s", got, result.ToLDIF())
+	}
+
+	if _, err := ParseLDIF(strings.NewReader("dn: cn=bob\nchangetype: delete\n")); err == nil {
+		t.Error("expected an error for a change record")
+	}
+	if _, err := ParseLDIF(strings.NewReader("cn: bob\n")); err == nil {
+		t.Error("expected an error for a record without dn")
+	}
+}
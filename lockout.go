@@ -0,0 +1,120 @@
+package ldap
+
+import (
+	"time"
+)
+
+// ppolicyPermanentLockout is the pwdAccountLockedTime value of accounts which
+// are locked until an administrator unlocks them
+const ppolicyPermanentLockout = "000001010000Z"
+
+// lockoutAttributes are read by GetLockoutInfo and Unlock. The ppolicy
+// attributes are operational and must be requested explicitly.
+var lockoutAttributes = []string{
+	"lockoutTime", "badPwdCount", "badPasswordTime",
+	"pwdAccountLockedTime", "pwdFailureTime",
+}
+
+// LockoutInfo describes the failed binds and the lockout of an account, read
+// from lockoutTime, badPwdCount and badPasswordTime in Active Directory or
+// pwdAccountLockedTime and pwdFailureTime of the OpenLDAP ppolicy overlay
+type LockoutInfo struct {
+	// Locked is true if the server recorded a lockout. Lockouts which
+	// already ended because of a lockout duration are not detected, see
+	// ADAccountState for Active Directory.
+	Locked bool
+	// LockedAt is the time of the lockout, zero if it is not locked or
+	// permanently locked
+	LockedAt time.Time
+	// Permanent is true if the account is locked until an administrator
+	// unlocks it (ppolicy only)
+	Permanent bool
+	// FailureCount is the number of consecutive failed binds
+	FailureCount int
+	// LastFailure is the time of the last failed bind, zero if unknown
+	LastFailure time.Time
+}
+
+// NewLockoutInfo returns the lockout information of the given entry
+func NewLockoutInfo(entry *Entry) (*LockoutInfo, error) {
+	var attrs struct {
+		LockoutTime     FileTime `ldap:"lockoutTime"`
+		BadPwdCount     int      `ldap:"badPwdCount"`
+		BadPasswordTime FileTime `ldap:"badPasswordTime"`
+	}
+	if err := NewDecoder(DecodeWithCaseInsensitive(true)).Decode(entry, &attrs); err != nil {
+		return nil, err
+	}
+	info := &LockoutInfo{
+		Locked:       attrs.LockoutTime.IsSet(),
+		LockedAt:     attrs.LockoutTime.Time(),
+		FailureCount: attrs.BadPwdCount,
+		LastFailure:  attrs.BadPasswordTime.Time(),
+	}
+
+	if locked := entry.GetEqualFoldAttributeValue("pwdAccountLockedTime"); locked != "" {
+		info.Locked = true
+		if locked == ppolicyPermanentLockout {
+			info.Permanent = true
+		} else {
+			lockedAt, err := ParseGeneralizedTime(locked)
+			if err != nil {
+				return nil, err
+			}
+			info.LockedAt = lockedAt
+		}
+	}
+	if failures := entry.GetEqualFoldAttributeValues("pwdFailureTime"); len(failures) > 0 {
+		info.FailureCount = len(failures)
+		for _, value := range failures {
+			failure, err := ParseGeneralizedTime(value)
+			if err != nil {
+				return nil, err
+			}
+			if failure.After(info.LastFailure) {
+				info.LastFailure = failure
+			}
+		}
+	}
+	return info, nil
+}
+
+// GetLockoutInfo reads the lockout information of the account with the
+// given DN
+func (l *Conn) GetLockoutInfo(dn string) (*LockoutInfo, error) {
+	entry, err := l.GetEntry(dn, lockoutAttributes...)
+	if err != nil {
+		return nil, err
+	}
+	return NewLockoutInfo(entry)
+}
+
+// Unlock unlocks the account with the given DN and resets its failed binds.
+// On Active Directory lockoutTime is set to 0, which also resets badPwdCount;
+// on other servers the ppolicy attributes pwdAccountLockedTime and
+// pwdFailureTime are deleted if present.
+func (l *Conn) Unlock(dn string) error {
+	rootDSE, err := l.RootDSE()
+	if err != nil {
+		return err
+	}
+	req := NewModifyRequest(dn, nil)
+	if rootDSE.IsActiveDirectory() {
+		req.Replace("lockoutTime", []string{"0"})
+		return l.Modify(req)
+	}
+
+	entry, err := l.GetEntry(dn, "pwdAccountLockedTime", "pwdFailureTime")
+	if err != nil {
+		return err
+	}
+	for _, attr := range []string{"pwdAccountLockedTime", "pwdFailureTime"} {
+		if len(entry.GetEqualFoldAttributeValues(attr)) > 0 {
+			req.Delete(attr, nil)
+		}
+	}
+	if len(req.Changes) == 0 {
+		return nil
+	}
+	return l.Modify(req)
+}
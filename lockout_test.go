@@ -0,0 +1,119 @@
+package ldap
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestNewLockoutInfo(t *testing.T) {
+	lockedAt := time.Date(2022, 3, 15, 10, 0, 0, 0, time.UTC)
+	info, err := NewLockoutInfo(NewEntry("cn=user,dc=example", map[string][]string{
+		"lockoutTime":     {strconv.FormatInt(int64(NewFileTime(lockedAt)), 10)},
+		"badPwdCount":     {"5"},
+		"badPasswordTime": {strconv.FormatInt(int64(NewFileTime(lockedAt)), 10)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Locked || !info.LockedAt.Equal(lockedAt) || info.FailureCount != 5 || !info.LastFailure.Equal(lockedAt) {
+		t.Errorf("unexpected AD lockout info %+v", info)
+	}
+
+	info, err = NewLockoutInfo(NewEntry("cn=user,dc=example", map[string][]string{"lockoutTime": {"0"}, "badPwdCount": {"0"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Locked || info.FailureCount != 0 {
+		t.Errorf("unexpected AD lockout info %+v", info)
+	}
+
+	info, err = NewLockoutInfo(NewEntry("uid=user,dc=example", map[string][]string{
+		"pwdAccountLockedTime": {"20220315100000Z"},
+		"pwdFailureTime":       {"20220315095900Z", "20220315100000Z", "20220315095800Z"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Locked || info.Permanent || !info.LockedAt.Equal(lockedAt) || info.FailureCount != 3 || !info.LastFailure.Equal(lockedAt) {
+		t.Errorf("unexpected ppolicy lockout info %+v", info)
+	}
+
+	info, err = NewLockoutInfo(NewEntry("uid=user,dc=example", map[string][]string{"pwdAccountLockedTime": {"000001010000Z"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Locked || !info.Permanent || !info.LockedAt.IsZero() {
+		t.Errorf("unexpected ppolicy lockout info %+v", info)
+	}
+}
+
+// modifyChanges returns the operations and attribute types of a modify
+// request as "operation:type"
+func modifyChanges(req *ber.Packet) []string {
+	var changes []string
+	for _, change := range req.Children[1].Children[1].Children {
+		changes = append(changes, strconv.FormatInt(change.Children[0].Value.(int64), 10)+":"+change.Children[1].Children[0].Value.(string))
+	}
+	return changes
+}
+
+func TestUnlock(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *ber.Packet, 1)
+	go func() {
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", map[string][]string{"vendorName": {"OpenLDAP"}}))
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("uid=user,dc=example", map[string][]string{
+			"pwdFailureTime": {"20220315095900Z"},
+		}))
+		req, err := respondWithResult(ptc, ApplicationModifyResponse, LDAPResultSuccess)
+		if err != nil {
+			return
+		}
+		requests <- req
+	}()
+
+	if err := conn.Unlock("uid=user,dc=example"); err != nil {
+		t.Fatal(err)
+	}
+	if changes := modifyChanges(<-requests); !reflect.DeepEqual(changes, []string{"1:pwdFailureTime"}) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
+
+func TestUnlockActiveDirectory(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *ber.Packet, 1)
+	go func() {
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", map[string][]string{
+			"supportedCapabilities": {capabilityActiveDirectory},
+		}))
+		req, err := respondWithResult(ptc, ApplicationModifyResponse, LDAPResultSuccess)
+		if err != nil {
+			return
+		}
+		requests <- req
+	}()
+
+	if err := conn.Unlock("cn=user,dc=example"); err != nil {
+		t.Fatal(err)
+	}
+	if changes := modifyChanges(<-requests); !reflect.DeepEqual(changes, []string{"2:lockoutTime"}) {
+		t.Errorf("unexpected changes %v", changes)
+	}
+}
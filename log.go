@@ -0,0 +1,120 @@
+package ldap
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// LogLevel is the severity of a structured log event
+type LogLevel int
+
+// Log levels
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// LogLevelMap contains human readable descriptions of log levels
+var LogLevelMap = map[LogLevel]string{
+	LogLevelDebug: "DEBUG",
+	LogLevelInfo:  "INFO",
+	LogLevelWarn:  "WARN",
+	LogLevelError: "ERROR",
+}
+
+// String returns a human readable description of the log level
+func (lvl LogLevel) String() string {
+	if s, ok := LogLevelMap[lvl]; ok {
+		return s
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(lvl))
+}
+
+// StructuredLogger receives structured events emitted by a connection, e.g.
+// when connecting, binding, sending requests or receiving responses.
+// keysAndValues holds alternating keys and values, keys are always strings.
+// Implementations must be safe for concurrent use.
+type StructuredLogger interface {
+	Log(level LogLevel, msg string, keysAndValues ...interface{})
+}
+
+// redactedLogKeys lists the field keys whose values are never handed to a
+// StructuredLogger in clear text
+var redactedLogKeys = map[string]bool{
+	"password":    true,
+	"credentials": true,
+	"hash":        true,
+	"token":       true,
+}
+
+const redactedLogValue = "[REDACTED]"
+
+// stdStructuredLogger adapts a *log.Logger to the StructuredLogger interface
+type stdStructuredLogger struct {
+	logger   *log.Logger
+	minLevel LogLevel
+}
+
+// NewStdStructuredLogger returns a StructuredLogger writing events with at
+// least the given level to l, formatted as "LEVEL msg key=value ...".
+func NewStdStructuredLogger(l *log.Logger, minLevel LogLevel) StructuredLogger {
+	return &stdStructuredLogger{logger: l, minLevel: minLevel}
+}
+
+func (s *stdStructuredLogger) Log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	if level < s.minLevel {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteByte(' ')
+		fmt.Fprint(&b, keysAndValues[i])
+		b.WriteByte('=')
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, "%v", keysAndValues[i+1])
+		}
+	}
+	s.logger.Print(b.String())
+}
+
+// loggerHolder wraps the structured logger so it can be stored in an atomic.Value
+type loggerHolder struct {
+	logger StructuredLogger
+}
+
+// SetLogger sets the structured logger receiving events of this connection.
+// Passing nil disables structured logging.
+func (l *Conn) SetLogger(logger StructuredLogger) {
+	l.structuredLogger.Store(loggerHolder{logger: logger})
+}
+
+// log emits a structured event, masking the values of credential fields
+func (l *Conn) log(level LogLevel, msg string, keysAndValues ...interface{}) {
+	holder, ok := l.structuredLogger.Load().(loggerHolder)
+	if !ok || holder.logger == nil {
+		return
+	}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok && redactedLogKeys[strings.ToLower(key)] {
+			keysAndValues[i+1] = redactedLogValue
+		}
+	}
+	holder.logger.Log(level, msg, keysAndValues...)
+}
+
+// packetOperation returns a description of the protocol operation carried by
+// an LDAP message
+func packetOperation(packet *ber.Packet) string {
+	if packet == nil || len(packet.Children) < 2 {
+		return ""
+	}
+	return ApplicationMap[uint8(packet.Children[1].Tag)]
+}
@@ -0,0 +1,28 @@
+package ldap
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStructuredLoggerRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	conn := NewConn(newPacketTranslatorConn(), false)
+	conn.SetLogger(NewStdStructuredLogger(log.New(&buf, "", 0), LogLevelInfo))
+
+	conn.log(LogLevelDebug, "ignored", "dn", "cn=admin")
+	conn.log(LogLevelInfo, "bind", "dn", "cn=admin", "Password", "secret")
+
+	out := buf.String()
+	if strings.Contains(out, "ignored") {
+		t.Errorf("debug event should have been filtered: %q", out)
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("password should have been redacted: %q", out)
+	}
+	if expected := "INFO bind dn=cn=admin Password=" + redactedLogValue + "\n"; out != expected {
+		t.Errorf("got %q, expected %q", out, expected)
+	}
+}
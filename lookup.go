@@ -0,0 +1,64 @@
+package ldap
+
+import (
+	"errors"
+)
+
+var (
+	// ErrEntryNotFound is returned by SearchOne, GetEntry and
+	// StreamAttributeValue if no entry matched
+	ErrEntryNotFound = errors.New("ldap: no entry found")
+	// ErrMultipleEntries is returned by SearchOne if more than one entry matched
+	ErrMultipleEntries = errors.New("ldap: more than one entry found")
+)
+
+// SearchOne performs the given search request and returns the single
+// matching entry. ErrEntryNotFound is returned if no entry matched and
+// ErrMultipleEntries if more than one did. To avoid transferring more entries
+// than needed, the size limit of the request is lowered to 2 for the search.
+func (l *Conn) SearchOne(searchRequest *SearchRequest) (*Entry, error) {
+	req := *searchRequest
+	if req.SizeLimit == 0 || req.SizeLimit > 2 {
+		req.SizeLimit = 2
+	}
+
+	result, err := l.Search(&req)
+	if err != nil {
+		if IsErrorWithCode(err, LDAPResultSizeLimitExceeded) && result != nil && len(result.Entries) > 1 {
+			return nil, ErrMultipleEntries
+		}
+		return nil, err
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrEntryNotFound
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, ErrMultipleEntries
+	}
+}
+
+// GetEntry reads the entry with the given DN, returning the given attributes
+// or all user attributes if none are given. If the entry does not exist,
+// the returned error matches ErrNoSuchObject.
+func (l *Conn) GetEntry(dn string, attributes ...string) (*Entry, error) {
+	return l.SearchOne(NewSearchRequest(
+		dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", attributes, nil,
+	))
+}
+
+// Exists returns true if the entry with the given DN exists and is visible
+// to the bound user
+func (l *Conn) Exists(dn string) (bool, error) {
+	_, err := l.GetEntry(dn, "1.1")
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrEntryNotFound), errors.Is(err, ErrNoSuchObject):
+		return false, nil
+	default:
+		return false, err
+	}
+}
@@ -0,0 +1,63 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSearchOne(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(uid=bob)", nil, nil)
+
+	go respondToSearch(ptc, "cn=bob,dc=example,dc=com")
+	entry, err := conn.SearchOne(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.DN != "cn=bob,dc=example,dc=com" {
+		t.Errorf("unexpected entry %s", entry.DN)
+	}
+	if searchRequest.SizeLimit != 0 {
+		t.Errorf("size limit of the request was modified")
+	}
+
+	go respondToSearch(ptc)
+	if _, err := conn.SearchOne(searchRequest); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("expected ErrEntryNotFound, got %v", err)
+	}
+
+	go respondToSearchWithCode(ptc, LDAPResultSizeLimitExceeded, "cn=bob,dc=example,dc=com", "cn=bob,ou=other,dc=example,dc=com")
+	if _, err := conn.SearchOne(searchRequest); !errors.Is(err, ErrMultipleEntries) {
+		t.Errorf("expected ErrMultipleEntries, got %v", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearch(ptc, "cn=bob,dc=example,dc=com")
+	if exists, err := conn.Exists("cn=bob,dc=example,dc=com"); err != nil || !exists {
+		t.Errorf("expected entry to exist, got %t, %v", exists, err)
+	}
+
+	go respondToSearchWithCode(ptc, LDAPResultNoSuchObject)
+	if exists, err := conn.Exists("cn=alice,dc=example,dc=com"); err != nil || exists {
+		t.Errorf("expected entry not to exist, got %t, %v", exists, err)
+	}
+
+	go respondToSearchWithCode(ptc, LDAPResultInsufficientAccessRights)
+	if _, err := conn.Exists("cn=alice,dc=example,dc=com"); !errors.Is(err, ErrInsufficientAccessRights) {
+		t.Errorf("expected ErrInsufficientAccessRights, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package ldap
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalEntry returns an Entry holding the fields of the struct pointed to by
+// i, using the same `ldap` struct tags as Entry.Unmarshal. The field tagged
+// "dn" sets the DN of the entry. Fields tagged with omitempty are left out if
+// they hold the zero value, nil pointers and empty slices are always left out.
+// time.Time fields are encoded as GeneralizedTime in UTC unless a layout
+// option is given, and fields of types implementing Marshaler encode
+// themselves.
+func MarshalEntry(i interface{}) (*Entry, error) {
+	sv := reflect.ValueOf(i)
+	if sv.Kind() == reflect.Ptr {
+		sv = sv.Elem()
+	}
+	if sv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ldap: expected struct or pointer to a struct, got %s", sv.Kind())
+	}
+
+	e := &Entry{}
+	if err := e.marshalStruct(sv); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// marshalStruct adds the fields of the struct sv to the entry
+func (e *Entry) marshalStruct(sv reflect.Value) error {
+	st := sv.Type()
+	for n := 0; n < st.NumField(); n++ {
+		fv, ft := sv.Field(n), st.Field(n)
+
+		fieldTag, omitEmpty := readTag(ft, decoderTagName)
+		if fieldTag == "" {
+			continue
+		}
+
+		if isMarshaledStruct(ft.Type) {
+			if ft.PkgPath != "" && !(ft.Anonymous && fv.Kind() == reflect.Struct) {
+				continue
+			}
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if err := e.marshalStruct(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// skip unexported fields
+		if ft.PkgPath != "" {
+			continue
+		}
+
+		if readTagFlag(ft, decoderTagName, "remain") {
+			e.marshalRemaining(fv)
+			continue
+		}
+		if fieldTag == "dn" {
+			if fv.Kind() != reflect.String {
+				return fmt.Errorf("ldap: expected dn field to be of type string, got %v", ft.Type)
+			}
+			e.DN = fv.String()
+			continue
+		}
+		if omitEmpty && isZeroValue(fv) {
+			continue
+		}
+
+		values, err := marshalField(fv, ft)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		e.addRawAttributeValues(fieldTag, values)
+	}
+	return nil
+}
+
+// marshalRemaining adds the attributes held by a map field tagged with the
+// remain option
+func (e *Entry) marshalRemaining(mv reflect.Value) {
+	if mv.Kind() != reflect.Map || mv.Type().Key().Kind() != reflect.String {
+		return
+	}
+	keys := mv.MapKeys()
+	// keep the order of the attributes stable
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, key.String())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := mv.MapIndex(reflect.ValueOf(name).Convert(mv.Type().Key()))
+		var values [][]byte
+		switch value.Kind() {
+		case reflect.String:
+			values = [][]byte{[]byte(value.String())}
+		case reflect.Slice:
+			for n := 0; n < value.Len(); n++ {
+				if item := value.Index(n); item.Kind() == reflect.String {
+					values = append(values, []byte(item.String()))
+				}
+			}
+		}
+		if len(values) > 0 {
+			e.addRawAttributeValues(name, values)
+		}
+	}
+}
+
+// addRawAttributeValues appends the values to the named attribute of the
+// entry, adding the attribute if it doesn't exist
+func (e *Entry) addRawAttributeValues(name string, values [][]byte) {
+	for _, attr := range e.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			for _, value := range values {
+				attr.Values = append(attr.Values, string(value))
+				attr.ByteValues = append(attr.ByteValues, value)
+			}
+			return
+		}
+	}
+	attr := &EntryAttribute{Name: name, ByteValues: values}
+	for _, value := range values {
+		attr.Values = append(attr.Values, string(value))
+	}
+	e.Attributes = append(e.Attributes, attr)
+}
+
+var marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+
+// isMarshaledStruct returns true if the field type t is a struct, or a pointer
+// to one, whose fields should be marshaled instead of the field itself
+func isMarshaledStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !t.Implements(marshalerType) && !reflect.PtrTo(t).Implements(marshalerType)
+}
+
+// isZeroValue returns true if v holds the zero value of its type, or is an
+// empty slice or map
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.IsZero()
+		}
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// marshalField returns the attribute values held by the struct field fv
+func marshalField(fv reflect.Value, ft reflect.StructField) ([][]byte, error) {
+	if m, ok := fv.Interface().(Marshaler); ok {
+		if fv.Kind() == reflect.Ptr && fv.IsNil() {
+			return nil, nil
+		}
+		return m.MarshalLDAP()
+	}
+	if fv.CanAddr() {
+		if m, ok := fv.Addr().Interface().(Marshaler); ok {
+			return m.MarshalLDAP()
+		}
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil, nil
+		}
+		return marshalField(fv.Elem(), ft)
+	}
+
+	switch v := fv.Interface().(type) {
+	case string:
+		return [][]byte{[]byte(v)}, nil
+	case []byte:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		return [][]byte{v}, nil
+	case [][]byte:
+		return v, nil
+	case int, int8, int16, int32, int64:
+		return [][]byte{[]byte(strconv.FormatInt(fv.Int(), 10))}, nil
+	case uint, uint8, uint16, uint32, uint64:
+		return [][]byte{[]byte(strconv.FormatUint(fv.Uint(), 10))}, nil
+	case float32, float64:
+		return [][]byte{[]byte(strconv.FormatFloat(fv.Float(), 'g', -1, fv.Type().Bits()))}, nil
+	case bool:
+		if v {
+			return [][]byte{[]byte("TRUE")}, nil
+		}
+		return [][]byte{[]byte("FALSE")}, nil
+	case time.Time:
+		if layout := readTagOption(ft, decoderTagName, "layout"); layout != "" {
+			return [][]byte{[]byte(v.Format(layout))}, nil
+		}
+		return [][]byte{[]byte(FormatGeneralizedTime(v))}, nil
+	}
+
+	if fv.Kind() == reflect.Slice {
+		var values [][]byte
+		for n := 0; n < fv.Len(); n++ {
+			itemValues, err := marshalField(fv.Index(n), ft)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("ldap: cannot marshal field %s of type %v", ft.Name, ft.Type)
+}
@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalEntry(t *testing.T) {
+	type Audit struct {
+		CreatorsName string `ldap:"creatorsName,omitempty"`
+	}
+	type User struct {
+		Audit
+		DN          string            `ldap:"dn"`
+		CN          string            `ldap:"cn"`
+		Mail        []string          `ldap:"mail"`
+		UIDNumber   uint32            `ldap:"uidNumber"`
+		Locked      bool              `ldap:"locked"`
+		Description string            `ldap:"description,omitempty"`
+		Manager     *string           `ldap:"manager"`
+		Expires     time.Time         `ldap:"expires,layout=2006-01-02"`
+		GUID        testHexValues     `ldap:"objectGUID"`
+		Internal    string            `ldap:"-"`
+		Other       map[string]string `ldap:",remain"`
+	}
+
+	user := &User{
+		Audit:     Audit{CreatorsName: "cn=admin"},
+		DN:        "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+		CN:        "mario",
+		Mail:      []string{"mario@go-ldap.com", "mario@example.com"},
+		UIDNumber: 1000,
+		Expires:   time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC),
+		GUID:      testHexValues{"0102"},
+		Internal:  "not an attribute",
+		Other:     map[string]string{"sn": "Mario"},
+	}
+
+	entry, err := MarshalEntry(user)
+	assert.Nil(t, err)
+	assert.Equal(t, user.DN, entry.DN)
+
+	expect := map[string][]string{
+		"creatorsName": {"cn=admin"},
+		"cn":           {"mario"},
+		"mail":         {"mario@go-ldap.com", "mario@example.com"},
+		"uidNumber":    {"1000"},
+		"locked":       {"FALSE"},
+		"expires":      {"2023-01-31"},
+		"objectGUID":   {"\x01\x02"},
+		"sn":           {"Mario"},
+	}
+	got := map[string][]string{}
+	for _, attr := range entry.Attributes {
+		got[attr.Name] = attr.Values
+	}
+	assert.Equal(t, expect, got)
+
+	decoded := &User{}
+	assert.Nil(t, entry.Unmarshal(decoded))
+	assert.Equal(t, user.Mail, decoded.Mail)
+	assert.Equal(t, user.UIDNumber, decoded.UIDNumber)
+
+	req, err := NewAddRequestFromStruct("", user, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, user.DN, req.DN)
+	assert.Equal(t, len(expect), len(req.Attributes))
+
+	_, err = MarshalEntry("not a struct")
+	assert.NotNil(t, err)
+}
+
+func (h testHexValues) MarshalLDAP() ([][]byte, error) {
+	var values [][]byte
+	for _, value := range h {
+		var b []byte
+		for i := 0; i+1 < len(value); i += 2 {
+			n, err := strconv.ParseUint(value[i:i+2], 16, 8)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, byte(n))
+		}
+		values = append(values, b)
+	}
+	return values, nil
+}
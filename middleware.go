@@ -0,0 +1,92 @@
+package ldap
+
+import (
+	"errors"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Handler sends an LDAP request message to the server and returns the stream
+// of response messages for it.
+type Handler func(request *ber.Packet) (ResponseStream, error)
+
+// ResponseStream yields the response messages of a single request.
+type ResponseStream interface {
+	// Next blocks until the next response message for the request is received
+	Next() (*ber.Packet, error)
+}
+
+// Middleware wraps a Handler to observe or modify outgoing requests and
+// their responses, e.g. for logging, metrics, rewriting controls or rate
+// limiting. A Middleware must either call next or return an error.
+type Middleware func(next Handler) Handler
+
+var errRequestNotSent = errors.New("ldap: middleware did not send the request")
+
+// Use appends middleware to the interceptor chain of the connection. The
+// middleware added first is the outermost one, i.e. it sees requests first
+// and responses last. All requests except StartTLS pass through the chain.
+func (l *Conn) Use(middleware ...Middleware) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	l.middleware = append(l.middleware, middleware...)
+}
+
+// Next returns the next response packet received for the message
+func (msgCtx *messageContext) Next() (*ber.Packet, error) {
+	packetResponse, ok := msgCtx.receive()
+	if !ok {
+		return nil, NewError(ErrorNetwork, errRespChanClosed)
+	}
+	return packetResponse.ReadPacket()
+}
+
+// sendRequest passes the request packet through the middleware chain and
+// sends it to the server. With scrubCredentials, the packet is zeroed once
+// it is sent.
+func (l *Conn) sendRequest(packet *ber.Packet, flags sendMessageFlags, correlationID string) (*messageContext, error) {
+	if flags&scrubCredentials != 0 {
+		defer scrubPacket(packet)
+	}
+	l.messageMutex.Lock()
+	middleware := l.middleware
+	l.messageMutex.Unlock()
+	if len(middleware) == 0 {
+		return l.sendMessageWithFlags(packet, flags, correlationID)
+	}
+
+	var msgCtx *messageContext
+	handler := Handler(func(request *ber.Packet) (ResponseStream, error) {
+		var err error
+		msgCtx, err = l.sendMessageWithFlags(request, flags, correlationID)
+		if err != nil {
+			return nil, err
+		}
+		return msgCtx, nil
+	})
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	stream, err := handler(packet)
+	if err != nil {
+		if msgCtx != nil {
+			l.finishMessage(msgCtx)
+		}
+		return nil, err
+	}
+	if msgCtx == nil {
+		return nil, NewError(ErrorUnexpectedMessage, errRequestNotSent)
+	}
+	msgCtx.stream = stream
+	return msgCtx, nil
+}
+
+// receivePacket returns the next response for the message, passing it through
+// the middleware chain
+func (l *Conn) receivePacket(msgCtx *messageContext) (*ber.Packet, error) {
+	if msgCtx.stream != nil {
+		return msgCtx.stream.Next()
+	}
+	return msgCtx.Next()
+}
@@ -0,0 +1,97 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+type recordingStream struct {
+	next      ResponseStream
+	responses *[]string
+}
+
+func (s *recordingStream) Next() (*ber.Packet, error) {
+	packet, err := s.next.Next()
+	if err == nil {
+		*s.responses = append(*s.responses, packetOperation(packet))
+	}
+	return packet, err
+}
+
+func TestMiddleware(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var calls, requests, responses []string
+	conn.Use(func(next Handler) Handler {
+		return func(request *ber.Packet) (ResponseStream, error) {
+			calls = append(calls, "outer")
+			return next(request)
+		}
+	}, func(next Handler) Handler {
+		return func(request *ber.Packet) (ResponseStream, error) {
+			calls = append(calls, "inner")
+			requests = append(requests, packetOperation(request))
+			stream, err := next(request)
+			if err != nil {
+				return nil, err
+			}
+			return &recordingStream{next: stream, responses: &responses}, nil
+		}
+	})
+
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+		delResponse := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationDelResponse, nil, "Del Response")
+		delResponse.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+		delResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+		delResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+		response.AppendChild(delResponse)
+		_ = ptc.SendResponse(response)
+	}()
+
+	if err := conn.Del(NewDelRequest("cn=john,dc=example,dc=com", nil)); err != nil {
+		t.Fatalf("delete failed: %s", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "outer" || calls[1] != "inner" {
+		t.Errorf("unexpected middleware order: %v", calls)
+	}
+	if len(requests) != 1 || requests[0] != ApplicationMap[ApplicationDelRequest] {
+		t.Errorf("unexpected requests: %v", requests)
+	}
+	if len(responses) != 1 || responses[0] != ApplicationMap[ApplicationDelResponse] {
+		t.Errorf("unexpected responses: %v", responses)
+	}
+}
+
+func TestMiddlewareError(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	expected := errors.New("rate limit exceeded")
+	conn.Use(func(next Handler) Handler {
+		return func(request *ber.Packet) (ResponseStream, error) {
+			return nil, expected
+		}
+	})
+
+	if err := conn.Del(NewDelRequest("cn=john,dc=example,dc=com", nil)); err != expected {
+		t.Errorf("expected middleware error, got %v", err)
+	}
+}
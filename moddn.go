@@ -1,6 +1,8 @@
 package ldap
 
 import (
+	"context"
+
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
 
@@ -75,7 +77,15 @@ func (req *ModifyDNRequest) appendTo(envelope *ber.Packet) error {
 // ModifyDN renames the given DN and optionally move to another base (when the "newSup" argument
 // to NewModifyDNRequest() is not "").
 func (l *Conn) ModifyDN(m *ModifyDNRequest) error {
-	msgCtx, err := l.doRequest(m)
+	return l.ModifyDNContext(context.Background(), m)
+}
+
+// ModifyDNContext performs the ModifyDNRequest. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) ModifyDNContext(ctx context.Context, m *ModifyDNRequest) (err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, m)
 	if err != nil {
 		return err
 	}
@@ -96,3 +106,15 @@ func (l *Conn) ModifyDN(m *ModifyDNRequest) error {
 	}
 	return nil
 }
+
+// ModifyDNWithResult performs the ModifyDNRequest and returns the result
+func (l *Conn) ModifyDNWithResult(m *ModifyDNRequest) (*WriteResult, error) {
+	return l.ModifyDNWithResultContext(context.Background(), m)
+}
+
+// ModifyDNWithResultContext performs the ModifyDNRequest and returns the result. The correlation ID
+// carried by ctx, see WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) ModifyDNWithResultContext(ctx context.Context, m *ModifyDNRequest) (_ *WriteResult, err error) {
+	defer correlateError(ctx, &err)
+	return l.writeWithResult(ctx, m, ApplicationModifyDNResponse)
+}
@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
@@ -109,7 +110,15 @@ func NewModifyRequest(dn string, controls []Control) *ModifyRequest {
 
 // Modify performs the ModifyRequest
 func (l *Conn) Modify(modifyRequest *ModifyRequest) error {
-	msgCtx, err := l.doRequest(modifyRequest)
+	return l.ModifyContext(context.Background(), modifyRequest)
+}
+
+// ModifyContext performs the ModifyRequest. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) ModifyContext(ctx context.Context, modifyRequest *ModifyRequest) (err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, modifyRequest)
 	if err != nil {
 		return err
 	}
@@ -131,53 +140,80 @@ func (l *Conn) Modify(modifyRequest *ModifyRequest) error {
 	return nil
 }
 
-// ModifyResult holds the server's response to a modify request
-type ModifyResult struct {
+// WriteResult holds the server's response to an add, delete, modify or modify DN request, including
+// the response controls, e.g. the pre-read and post-read entries or a password policy control. It is
+// returned with the error of a failed operation as well.
+type WriteResult struct {
 	// Controls are the returned controls
 	Controls []Control
 	// Referral is the returned referral
 	Referral string
+	// ResultCode is the returned result code
+	ResultCode uint16
+	// MatchedDN is the returned matched DN, e.g. the closest existing ancestor of the entry on a
+	// noSuchObject result
+	MatchedDN string
+	// DiagnosticMessage is the returned diagnostic message
+	DiagnosticMessage string
 }
 
+// ModifyResult holds the server's response to a modify request
+type ModifyResult = WriteResult
+
 // ModifyWithResult performs the ModifyRequest and returns the result
 func (l *Conn) ModifyWithResult(modifyRequest *ModifyRequest) (*ModifyResult, error) {
-	msgCtx, err := l.doRequest(modifyRequest)
+	return l.ModifyWithResultContext(context.Background(), modifyRequest)
+}
+
+// ModifyWithResultContext performs the ModifyRequest and returns the result. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) ModifyWithResultContext(ctx context.Context, modifyRequest *ModifyRequest) (_ *ModifyResult, err error) {
+	defer correlateError(ctx, &err)
+	return l.writeWithResult(ctx, modifyRequest, ApplicationModifyResponse)
+}
+
+// writeWithResult performs a write request answered by a response with the given tag and returns
+// the result
+func (l *Conn) writeWithResult(ctx context.Context, req request, responseTag ber.Tag) (*WriteResult, error) {
+	msgCtx, err := l.doRequestContext(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	defer l.finishMessage(msgCtx)
 
-	result := &ModifyResult{
-		Controls: make([]Control, 0),
-	}
-
 	l.Debug.Printf("%d: waiting for response", msgCtx.id)
 	packet, err := l.readPacket(msgCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	switch packet.Children[1].Tag {
-	case ApplicationModifyResponse:
-		if err = GetLDAPError(packet); err != nil {
-			if referral, referralErr := getReferral(err, packet); referralErr != nil {
-				return result, referralErr
-			} else {
-				result.Referral = referral
+	result := &WriteResult{
+		Controls: make([]Control, 0),
+	}
+	if packet.Children[1].Tag != responseTag {
+		logger.Printf("Unexpected Response: %d", packet.Children[1].Tag)
+		return result, nil
+	}
+	if len(packet.Children) == 3 {
+		for _, child := range packet.Children[2].Children {
+			decodedChild, err := DecodeControl(child)
+			if err != nil {
+				return nil, errors.New("failed to decode child control: " + err.Error())
 			}
-
-			return result, err
+			result.Controls = append(result.Controls, decodedChild)
 		}
-		if len(packet.Children) == 3 {
-			for _, child := range packet.Children[2].Children {
-				decodedChild, err := DecodeControl(child)
-				if err != nil {
-					return nil, errors.New("failed to decode child control: " + err.Error())
-				}
-				result.Controls = append(result.Controls, decodedChild)
-			}
+	}
+
+	err = GetLDAPError(packet)
+	var ldapErr *Error
+	if errors.As(err, &ldapErr) {
+		result.ResultCode = ldapErr.ResultCode
+		result.MatchedDN = ldapErr.MatchedDN
+		result.DiagnosticMessage = ldapErr.DiagnosticMessage
+		if len(ldapErr.Referrals) > 0 {
+			result.Referral = ldapErr.Referrals[0]
 		}
 	}
 	l.Debug.Printf("%d: returning", msgCtx.id)
-	return result, nil
+	return result, err
 }
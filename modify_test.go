@@ -0,0 +1,48 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteWithResult(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondWithResultControls(ptc, ApplicationAddResponse, LDAPResultSuccess, NewControlString("1.2.3.4", false, "csn"))
+	result, err := conn.AddWithResult(NewAddRequest("cn=a,dc=example,dc=com", nil))
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(result.Controls)) {
+		assert.Equal(t, "1.2.3.4", result.Controls[0].GetControlType())
+		assert.Equal(t, uint16(LDAPResultSuccess), result.ResultCode)
+	}
+
+	// the controls of a failed operation are returned with the error
+	go respondWithResultControls(ptc, ApplicationModifyResponse, LDAPResultConstraintViolation, &ControlVChuPasswordMustChange{MustChange: true})
+	modify := NewModifyRequest("cn=a,dc=example,dc=com", nil)
+	modify.Replace("userPassword", []string{"short"})
+	result, err = conn.ModifyWithResult(modify)
+	assert.True(t, IsErrorWithCode(err, LDAPResultConstraintViolation))
+	if assert.NotNil(t, result) {
+		assert.Equal(t, uint16(LDAPResultConstraintViolation), result.ResultCode)
+		c, ok := FindControl(result.Controls, ControlTypeVChuPasswordMustChange).(*ControlVChuPasswordMustChange)
+		assert.True(t, ok && c.MustChange)
+	}
+
+	go respondWithResult(ptc, ApplicationModifyDNResponse, LDAPResultSuccess)
+	result, err = conn.ModifyDNWithResult(NewModifyDNRequest("cn=a,dc=example,dc=com", "cn=b", true, ""))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, len(result.Controls))
+	}
+
+	go respondWithResult(ptc, ApplicationDelResponse, LDAPResultNoSuchObject)
+	result, err = conn.DelWithResult(NewDelRequest("cn=a,dc=example,dc=com", nil))
+	assert.True(t, IsErrorWithCode(err, LDAPResultNoSuchObject))
+	if assert.NotNil(t, result) {
+		assert.Equal(t, uint16(LDAPResultNoSuchObject), result.ResultCode)
+	}
+}
@@ -0,0 +1,140 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// moveSubtreePagingSize is the page size of the search reading the entries of
+// a subtree copied by MoveSubtree
+const moveSubtreePagingSize = 500
+
+// Rename changes the RDN of the entry with the given DN, keeping it under its
+// parent. If deleteOld is set, the values of the old RDN are removed from the
+// entry.
+func (l *Conn) Rename(dn, newRDN string, deleteOld bool) error {
+	return l.ModifyDN(NewModifyDNRequest(dn, newRDN, deleteOld, ""))
+}
+
+// Move moves the entry with the given DN below newSuperior, keeping its RDN.
+// Many servers only move leaf entries, see MoveSubtree for moving entries
+// with children.
+func (l *Conn) Move(dn, newSuperior string) error {
+	parsed, err := ParseDN(dn)
+	if err != nil {
+		return err
+	}
+	if len(parsed.RDNs) == 0 {
+		return fmt.Errorf("ldap: cannot move the root DSE")
+	}
+	return l.ModifyDN(NewModifyDNRequest(dn, parsed.RDNs[0].String(), true, newSuperior))
+}
+
+// MoveProgress describes a step of a MoveSubtree falling back to copying the
+// entries
+type MoveProgress struct {
+	// DN is the original DN of the entry
+	DN string
+	// NewDN is the DN of the copy of the entry
+	NewDN string
+	// Deleted is set if the original entry was deleted, otherwise the copy
+	// was added
+	Deleted bool
+	// Done is the number of completed steps including this one, Total the
+	// number of all steps, i.e. twice the number of entries
+	Done, Total int
+}
+
+// MoveSubtree moves the entry with the given DN and all entries below it
+// under newSuperior. It tries a Move first. If the server refuses to move
+// non-leaf entries, e.g. the bdb and hdb backends of OpenLDAP, or entries
+// across naming contexts, the entries are copied to their new DNs, parents
+// first, and the originals are deleted afterwards, children first. progress,
+// if not nil, is called after each copied and deleted entry.
+//
+// Only the user attributes of the entries are copied, operational attributes
+// like entryUUID or createTimestamp are assigned anew by the server. If a copy
+// fails, the copies added so far are deleted again; if a delete fails, the
+// copy is complete and the remaining originals are left in place.
+func (l *Conn) MoveSubtree(dn, newSuperior string, progress func(MoveProgress)) error {
+	err := l.Move(dn, newSuperior)
+	if !IsErrorAnyOf(err, LDAPResultNotAllowedOnNonLeaf, LDAPResultAffectsMultipleDSAs) {
+		return err
+	}
+	l.log(LogLevelInfo, "server cannot move the subtree, copying the entries", "dn", dn, "newSuperior", newSuperior)
+
+	root, err := ParseDN(dn)
+	if err != nil {
+		return err
+	}
+	superior, err := ParseDN(newSuperior)
+	if err != nil {
+		return err
+	}
+	if root.EqualFold(superior) || root.AncestorOfFold(superior) {
+		return fmt.Errorf("ldap: cannot move %q below itself", dn)
+	}
+
+	result, err := l.SearchWithPaging(NewSearchRequest(dn, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"*"}, nil), moveSubtreePagingSize)
+	if err != nil {
+		return err
+	}
+
+	type move struct {
+		entry *Entry
+		depth int
+		newDN string
+	}
+	moves := make([]move, len(result.Entries))
+	for i, entry := range result.Entries {
+		parsed, err := ParseDN(entry.DN)
+		if err != nil {
+			return err
+		}
+		depth := len(parsed.RDNs) - len(root.RDNs)
+		rdns := make([]string, 0, depth+2)
+		for _, rdn := range parsed.RDNs[:depth+1] {
+			rdns = append(rdns, rdn.String())
+		}
+		if newSuperior != "" {
+			rdns = append(rdns, newSuperior)
+		}
+		moves[i] = move{entry: entry, depth: depth, newDN: strings.Join(rdns, ",")}
+	}
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].depth < moves[j].depth })
+
+	done, total := 0, 2*len(moves)
+	report := func(m move, deleted bool) {
+		done++
+		if progress != nil {
+			progress(MoveProgress{DN: m.entry.DN, NewDN: m.newDN, Deleted: deleted, Done: done, Total: total})
+		}
+	}
+
+	for i, m := range moves {
+		req := NewAddRequest(m.newDN, nil)
+		for _, attr := range m.entry.Attributes {
+			// Values lacks binary values and those of lazily decoded entries
+			req.Attribute(attr.Name, rawStringValues(attr))
+		}
+		if err := l.Add(req); err != nil {
+			// remove the copies added so far, children first
+			for j := i - 1; j >= 0; j-- {
+				if delErr := l.Del(NewDelRequest(moves[j].newDN, nil)); delErr != nil {
+					l.log(LogLevelWarn, "failed to delete copy of moved entry", "dn", moves[j].newDN, "error", delErr)
+				}
+			}
+			return fmt.Errorf("ldap: failed to copy %q to %q: %w", m.entry.DN, m.newDN, err)
+		}
+		report(m, false)
+	}
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := l.Del(NewDelRequest(moves[i].entry.DN, nil)); err != nil {
+			return fmt.Errorf("ldap: failed to delete %q after copying it: %w", moves[i].entry.DN, err)
+		}
+		report(moves[i], true)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package ldap
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRenameAndMove(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requests := make(chan *ModifyDNRequest, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			req, err := respondWithResult(ptc, ApplicationModifyDNResponse, LDAPResultSuccess)
+			if err != nil {
+				return
+			}
+			op := req.Children[1]
+			m := &ModifyDNRequest{
+				DN:           op.Children[0].Data.String(),
+				NewRDN:       op.Children[1].Data.String(),
+				DeleteOldRDN: len(op.Children[2].Data.Bytes()) > 0 && op.Children[2].Data.Bytes()[0] != 0,
+			}
+			if len(op.Children) > 3 {
+				m.NewSuperior = op.Children[3].Data.String()
+			}
+			requests <- m
+		}
+	}()
+
+	if err := conn.Rename("uid=jdoe,ou=people,dc=example,dc=com", "uid=john", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Move("uid=jdoe,ou=people,dc=example,dc=com", "ou=former,dc=example,dc=com"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*ModifyDNRequest{
+		{DN: "uid=jdoe,ou=people,dc=example,dc=com", NewRDN: "uid=john"},
+		{DN: "uid=jdoe,ou=people,dc=example,dc=com", NewRDN: "uid=jdoe", DeleteOldRDN: true, NewSuperior: "ou=former,dc=example,dc=com"},
+	}
+	for _, e := range expected {
+		if m := <-requests; !reflect.DeepEqual(m, e) {
+			t.Errorf("expected %+v, got %+v", e, m)
+		}
+	}
+}
+
+func TestMoveSubtreeCopies(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	// not valid UTF-8, so decoded as binary value
+	const photo = "\xff\xd8\xff\xe0"
+	operations := make(chan string, 4)
+	go func() {
+		if _, err := respondWithResult(ptc, ApplicationModifyDNResponse, LDAPResultNotAllowedOnNonLeaf); err != nil {
+			return
+		}
+		respondToSearchWithEntries(ptc, LDAPResultSuccess,
+			NewEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{"uid": {"jdoe"}, "jpegPhoto": {photo}}),
+			NewEntry("ou=people,dc=example,dc=com", map[string][]string{"ou": {"people"}, "objectClass": {"organizationalUnit"}}),
+		)
+		for i := 0; i < 2; i++ {
+			req, err := respondWithResult(ptc, ApplicationAddResponse, LDAPResultSuccess)
+			if err != nil {
+				return
+			}
+			op := "add " + req.Children[1].Children[0].Data.String()
+			if bytes.Contains(req.Bytes(), []byte(photo)) {
+				op += " with photo"
+			}
+			operations <- op
+		}
+		for i := 0; i < 2; i++ {
+			req, err := respondWithResult(ptc, ApplicationDelResponse, LDAPResultSuccess)
+			if err != nil {
+				return
+			}
+			operations <- "del " + req.Children[1].Data.String()
+		}
+		close(operations)
+	}()
+
+	var steps []MoveProgress
+	err := conn.MoveSubtree("ou=people,dc=example,dc=com", "o=archive,dc=example,dc=com", func(p MoveProgress) {
+		steps = append(steps, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []string
+	for op := range operations {
+		ops = append(ops, op)
+	}
+	expectedOps := []string{
+		"add ou=people,o=archive,dc=example,dc=com",
+		"add uid=jdoe,ou=people,o=archive,dc=example,dc=com with photo",
+		"del uid=jdoe,ou=people,dc=example,dc=com",
+		"del ou=people,dc=example,dc=com",
+	}
+	if !reflect.DeepEqual(ops, expectedOps) {
+		t.Errorf("expected operations %q, got %q", expectedOps, ops)
+	}
+	if len(steps) != 4 {
+		t.Fatalf("expected 4 progress steps, got %d", len(steps))
+	}
+	if last := steps[3]; !last.Deleted || last.Done != 4 || last.Total != 4 || last.DN != "ou=people,dc=example,dc=com" {
+		t.Errorf("unexpected last progress step %+v", last)
+	}
+}
+
+func TestMoveSubtreeBelowItself(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondWithResult(ptc, ApplicationModifyDNResponse, LDAPResultNotAllowedOnNonLeaf)
+	err := conn.MoveSubtree("ou=people,dc=example,dc=com", "ou=sub,ou=people,dc=example,dc=com", nil)
+	if err == nil || IsErrorWithCode(err, LDAPResultNotAllowedOnNonLeaf) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,458 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// This file holds the encoding and decoding of the messages exchanged on a
+// connection. Requests are encoded into pooled buffers. Responses are read
+// into a single buffer each and decoded into ber.Packets allocated in blocks,
+// with the same result as ber.ReadPacket but without its allocations for
+// every byte of a header and every element.
+
+// maxPooledBufferSize is the capacity above which encode buffers are not
+// returned to the pool, so that a single large request does not pin memory
+const maxPooledBufferSize = 64 << 10
+
+var encodeBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// encodeMessage returns a buffer from the pool holding the encoding of p,
+// which is the same as p.Bytes(). The buffer must be passed to releaseBuffer once
+// it is no longer used.
+func encodeMessage(p *ber.Packet) *bytes.Buffer {
+	buf := encodeBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	appendHeader(buf, p.Identifier, p.Data.Len())
+	buf.Write(p.Data.Bytes())
+	return buf
+}
+
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		encodeBufferPool.Put(buf)
+	}
+}
+
+// appendHeader appends the identifier and definite length of an element
+func appendHeader(buf *bytes.Buffer, identifier ber.Identifier, length int) {
+	b := byte(identifier.ClassType) | byte(identifier.TagType)
+	if identifier.Tag < ber.HighTag {
+		buf.WriteByte(b | byte(identifier.Tag))
+	} else {
+		buf.WriteByte(b | byte(ber.HighTag))
+		var tag [10]byte
+		i := len(tag)
+		for t := identifier.Tag; t != 0; t >>= 7 {
+			i--
+			tag[i] = byte(t&ber.HighTagValueBitmask | ber.HighTagContinueBitmask)
+		}
+		tag[len(tag)-1] &^= byte(ber.HighTagContinueBitmask)
+		buf.Write(tag[i:])
+	}
+
+	if length < 0x80 {
+		buf.WriteByte(byte(length))
+		return
+	}
+	n := 0
+	for l := length; l > 0; l >>= 8 {
+		n++
+	}
+	buf.WriteByte(ber.LengthLongFormBitmask | byte(n))
+	for i := n - 1; i >= 0; i-- {
+		buf.WriteByte(byte(length >> uint(8*i)))
+	}
+}
+
+// errIndefiniteMessage is returned by readMessage and peekHeader for
+// elements with an indefinite length, which cannot be framed without
+// decoding them
+var errIndefiniteMessage = errors.New("ldap: message with indefinite length")
+
+// readMessage reads the next BER encoded message from r
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	size, length, err := peekHeader(r, 0, maxInt)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size+length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+// maxInt is the largest value of an int
+const maxInt = int(^uint(0) >> 1)
+
+// peekHeader returns the size of the identifier and length of the element
+// starting offset bytes into the buffered data of r, and the length of its
+// contents. No more than limit bytes are peeked, so that a malformed header
+// at the end of a message does not wait for the next one.
+func peekHeader(r *bufio.Reader, offset, limit int) (int, int, error) {
+	peek := func(n int) ([]byte, error) {
+		if n > limit {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b, err := r.Peek(n)
+		return b, unexpectedEOF(err)
+	}
+	header, err := peek(offset + 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	header = header[offset:]
+	size := 1
+	if ber.Tag(header[0])&ber.TagBitmask == ber.HighTag {
+		for {
+			size++
+			if size > 10 {
+				return 0, 0, errors.New("high-tag-number tag overflow")
+			}
+			if header, err = peek(offset + size + 1); err != nil {
+				return 0, 0, err
+			}
+			header = header[offset:]
+			if ber.Tag(header[size-1])&ber.HighTagContinueBitmask == 0 {
+				break
+			}
+		}
+	}
+
+	b := header[size]
+	size++
+	var length int64
+	switch {
+	case b == 0xff:
+		return 0, 0, errors.New("invalid length byte 0xff")
+	case b == ber.LengthLongFormBitmask:
+		return 0, 0, errIndefiniteMessage
+	case b&ber.LengthLongFormBitmask == 0:
+		length = int64(b)
+	default:
+		n := int(b & ber.LengthValueBitmask)
+		if n > 8 {
+			return 0, 0, errors.New("long-form length overflow")
+		}
+		if header, err = peek(offset + size + n); err != nil {
+			return 0, 0, err
+		}
+		header = header[offset:]
+		for _, b := range header[size : size+n] {
+			length = length<<8 | int64(b)
+		}
+		size += n
+	}
+	if length < 0 || int64(int(length)) != length || int(length) > maxInt-size-offset {
+		return 0, 0, errors.New("long-form length overflow")
+	}
+	if ber.MaxPacketLengthBytes > 0 && length > ber.MaxPacketLengthBytes {
+		return 0, 0, fmt.Errorf("length %d greater than maximum %d", length, ber.MaxPacketLengthBytes)
+	}
+	return size, int(length), nil
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for io.EOF, as ber.ReadPacket
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readResponse reads and decodes the next message from r. The returned bytes
+// are the message as received; captured is used for messages which have to
+// be read with ber.ReadPacket.
+func readResponse(r *bufio.Reader, captured *bytes.Buffer) (*ber.Packet, []byte, error) {
+	data, err := readMessage(r)
+	if err == errIndefiniteMessage {
+		captured.Reset()
+		packet, err := ber.ReadPacket(io.TeeReader(r, captured))
+		return packet, captured.Bytes(), err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	packet, err := decodePacket(data)
+	return packet, data, err
+}
+
+// packetDecoder decodes a message into ber.Packets. The packets, their data
+// buffers and child lists are allocated in blocks. The ByteValues of the
+// packets reference the message, their Data a copy of it, since some
+// decoders rewrite the Data of packets in place.
+type packetDecoder struct {
+	data     []byte
+	copy     []byte
+	stack    []*ber.Packet
+	packets  []ber.Packet
+	buffers  []bytes.Buffer
+	children []*ber.Packet
+	block    int
+}
+
+// decodePacket decodes a BER encoded message with the same result as
+// ber.DecodePacketErr, except that the Data of constructed packets holds
+// their content as received instead of the re-encoded children.
+func decodePacket(data []byte) (*ber.Packet, error) {
+	d := &packetDecoder{data: data, copy: append([]byte(nil), data...), block: 8}
+	p, _, err := d.decode(0, len(data))
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// nextBlock returns the size of the next block of packets, doubling it up
+// to a limit
+func (d *packetDecoder) nextBlock() int {
+	n := d.block
+	if d.block < 256 {
+		d.block *= 2
+	}
+	return n
+}
+
+func (d *packetDecoder) newPacket(identifier ber.Identifier, data []byte) *ber.Packet {
+	if len(d.packets) == 0 {
+		n := d.nextBlock()
+		d.packets = make([]ber.Packet, n)
+		d.buffers = make([]bytes.Buffer, n)
+	}
+	p := &d.packets[0]
+	buf := &d.buffers[0]
+	d.packets, d.buffers = d.packets[1:], d.buffers[1:]
+	*buf = *bytes.NewBuffer(data)
+	p.Identifier = identifier
+	p.Data = buf
+	return p
+}
+
+func (d *packetDecoder) newChildren(n int) []*ber.Packet {
+	if n == 0 {
+		return []*ber.Packet{}
+	}
+	if n > len(d.children) {
+		size := 4 * d.block
+		if n > size {
+			size = n
+		}
+		d.children = make([]*ber.Packet, size)
+	}
+	children := d.children[:n:n]
+	d.children = d.children[n:]
+	return children
+}
+
+func (d *packetDecoder) byteAt(pos, limit int) (byte, error) {
+	if pos >= limit {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return d.data[pos], nil
+}
+
+// decodeHeader decodes the identifier and length of the element at pos
+func (d *packetDecoder) decodeHeader(pos, limit int) (ber.Identifier, int, int, error) {
+	b, err := d.byteAt(pos, limit)
+	if err != nil {
+		return ber.Identifier{}, 0, pos, err
+	}
+	pos++
+	identifier := ber.Identifier{
+		ClassType: ber.Class(b) & ber.ClassBitmask,
+		TagType:   ber.Type(b) & ber.TypeBitmask,
+		Tag:       ber.Tag(b) & ber.TagBitmask,
+	}
+	if identifier.Tag == ber.HighTag {
+		identifier.Tag = 0
+		for n := 1; ; n++ {
+			if b, err = d.byteAt(pos, limit); err != nil {
+				return ber.Identifier{}, 0, pos, err
+			}
+			pos++
+			identifier.Tag = identifier.Tag<<7 | ber.Tag(b)&ber.HighTagValueBitmask
+			if n == 1 && identifier.Tag == 0 {
+				return ber.Identifier{}, 0, pos, errors.New("invalid first high-tag-number tag byte")
+			}
+			if n > 9 {
+				return ber.Identifier{}, 0, pos, errors.New("high-tag-number tag overflow")
+			}
+			if ber.Tag(b)&ber.HighTagContinueBitmask == 0 {
+				break
+			}
+		}
+	}
+
+	if b, err = d.byteAt(pos, limit); err != nil {
+		return ber.Identifier{}, 0, pos, err
+	}
+	pos++
+	var length int
+	switch {
+	case b == 0xff:
+		return ber.Identifier{}, 0, pos, errors.New("invalid length byte 0xff")
+	case b == ber.LengthLongFormBitmask:
+		if identifier.TagType == ber.TypePrimitive {
+			return ber.Identifier{}, 0, pos, errors.New("indefinite length used with primitive type")
+		}
+		length = ber.LengthIndefinite
+	case b&ber.LengthLongFormBitmask == 0:
+		length = int(b)
+	default:
+		n := int(b & ber.LengthValueBitmask)
+		if n > 8 {
+			return ber.Identifier{}, 0, pos, errors.New("long-form length overflow")
+		}
+		var length64 int64
+		for i := 0; i < n; i++ {
+			if b, err = d.byteAt(pos, limit); err != nil {
+				return ber.Identifier{}, 0, pos, err
+			}
+			pos++
+			length64 = length64<<8 | int64(b)
+		}
+		length = int(length64)
+		if int64(length) != length64 {
+			return ber.Identifier{}, 0, pos, errors.New("long-form length overflow")
+		}
+		if length < ber.LengthIndefinite {
+			return ber.Identifier{}, 0, pos, fmt.Errorf("length cannot be less than %d", ber.LengthIndefinite)
+		}
+	}
+	return identifier, length, pos, nil
+}
+
+// decode decodes the element at pos, which ends before limit, and returns
+// the position after it
+func (d *packetDecoder) decode(pos, limit int) (*ber.Packet, int, error) {
+	identifier, length, pos, err := d.decodeHeader(pos, limit)
+	if err != nil {
+		return nil, pos, err
+	}
+
+	if identifier.TagType == ber.TypeConstructed {
+		start, end := pos, limit
+		if length != ber.LengthIndefinite {
+			if length > limit-pos {
+				return nil, pos, io.ErrUnexpectedEOF
+			}
+			end = pos + length
+		}
+		// the children are collected on the stack, then copied to a list of
+		// the exact size
+		base := len(d.stack)
+		contentEnd := end
+		for length == ber.LengthIndefinite || pos < end {
+			childStart := pos
+			child, next, err := d.decode(pos, end)
+			if err != nil {
+				d.stack = d.stack[:base]
+				return nil, next, err
+			}
+			pos = next
+			if isEOCPacket(child) {
+				if length != ber.LengthIndefinite {
+					d.stack = d.stack[:base]
+					return nil, pos, errors.New("eoc child not allowed with definite length")
+				}
+				contentEnd = childStart
+				break
+			}
+			d.stack = append(d.stack, child)
+		}
+		p := d.newPacket(identifier, d.copy[start:contentEnd:contentEnd])
+		p.Children = d.newChildren(len(d.stack) - base)
+		copy(p.Children, d.stack[base:])
+		for i := base; i < len(d.stack); i++ {
+			d.stack[i] = nil
+		}
+		d.stack = d.stack[:base]
+		return p, pos, nil
+	}
+
+	if ber.MaxPacketLengthBytes > 0 && int64(length) > ber.MaxPacketLengthBytes {
+		return nil, pos, fmt.Errorf("length %d greater than maximum %d", length, ber.MaxPacketLengthBytes)
+	}
+	if length > limit-pos {
+		return nil, pos, io.ErrUnexpectedEOF
+	}
+	end := pos + length
+	p := d.newPacket(identifier, d.copy[pos:end:end])
+	p.Children = d.newChildren(0)
+	if identifier.ClassType != ber.ClassUniversal {
+		return p, end, nil
+	}
+
+	content := d.data[pos:end:end]
+	p.ByteValue = content
+	switch identifier.Tag {
+	case ber.TagBoolean:
+		val, _ := ber.ParseInt64(content)
+		p.Value = val != 0
+	case ber.TagInteger, ber.TagEnumerated:
+		p.Value, _ = ber.ParseInt64(content)
+	case ber.TagOctetString:
+		p.Value = ber.DecodeString(content)
+	case ber.TagRealFloat:
+		p.Value, err = ber.ParseReal(content)
+	case ber.TagUTF8String:
+		if !utf8.Valid(content) {
+			err = errors.New("invalid UTF-8 string")
+		} else {
+			p.Value = ber.DecodeString(content)
+		}
+	case ber.TagPrintableString:
+		if err = checkPrintableString(content); err == nil {
+			p.Value = ber.DecodeString(content)
+		}
+	case ber.TagIA5String:
+		val := ber.DecodeString(content)
+		for i, c := range val {
+			if c >= 0x7f {
+				err = fmt.Errorf("invalid character for IA5String at pos %d: %c", i, c)
+				break
+			}
+		}
+		if err == nil {
+			p.Value = val
+		}
+	case ber.TagGeneralizedTime:
+		p.Value, err = ber.ParseGeneralizedTime(content)
+	}
+	if err != nil {
+		return nil, end, err
+	}
+	return p, end, nil
+}
+
+// isEOCPacket returns true for the end-of-contents marker of an element with
+// indefinite length
+func isEOCPacket(p *ber.Packet) bool {
+	return p.Tag == ber.TagEOC && p.ClassType == ber.ClassUniversal && p.TagType == ber.TypePrimitive &&
+		len(p.ByteValue) == 0 && len(p.Children) == 0
+}
+
+// checkPrintableString validates the characters of a PrintableString
+func checkPrintableString(content []byte) error {
+	for i, c := range string(content) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '\'', c == '(', c == ')', c == '+', c == ',', c == '-', c == '.', c == '=', c == '/', c == ':', c == '?', c == ' ':
+		default:
+			return fmt.Errorf("invalid character in position %d", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+)
+
+// testEntryMessage returns the encoding of a search result entry with n
+// attributes
+func testEntryMessage(n int) []byte {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "uid=jdoe,ou=people,dc=example,dc=com", "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for i := 0; i < n; i++ {
+		attribute := Attribute{Type: fmt.Sprintf("attribute%d", i), Vals: []string{"first value", "second value"}}
+		attributes.AppendChild(attribute.encode())
+	}
+	op.AppendChild(attributes)
+	envelope.AppendChild(op)
+	return envelope.Bytes()
+}
+
+// assertSamePacket compares a packet decoded by decodePacket with the one
+// decoded by the ber package. The Data of constructed packets is not
+// compared, see decodePacket.
+func assertSamePacket(t *testing.T, want, got *ber.Packet, path string) {
+	t.Helper()
+	if !assert.Equal(t, want.Identifier, got.Identifier, path) ||
+		!assert.Equal(t, len(want.Children), len(got.Children), path) {
+		return
+	}
+	if !sameValue(want.Value, got.Value) {
+		t.Errorf("%s: value %#v, got %#v", path, want.Value, got.Value)
+	}
+	assert.Equal(t, want.ByteValue, got.ByteValue, path)
+	if want.TagType == ber.TypePrimitive && !bytes.Equal(want.Data.Bytes(), got.Data.Bytes()) {
+		t.Errorf("%s: data %x, got %x", path, want.Data.Bytes(), got.Data.Bytes())
+	}
+	for i := range want.Children {
+		assertSamePacket(t, want.Children[i], got.Children[i], fmt.Sprintf("%s/%d", path, i))
+	}
+}
+
+// sameValue reports whether the values of two packets are equal, treating
+// NaN REAL values as equal
+func sameValue(want, got interface{}) bool {
+	if w, ok := want.(float64); ok && math.IsNaN(w) {
+		g, ok := got.(float64)
+		return ok && math.IsNaN(g)
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func TestDecodePacket(t *testing.T) {
+	paging := NewControlPaging(100)
+	paging.SetCookie([]byte("cookie"))
+	highTag := ber.Encode(ber.ClassContext, ber.TypeConstructed, 1000, nil, "High Tag")
+	highTag.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Boolean"))
+	highTag.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 40, "value", "Value"))
+	large := ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(bytes.Repeat([]byte("x"), 70000)), "Large")
+
+	for name, data := range map[string][]byte{
+		"entry":    testEntryMessage(3),
+		"control":  paging.Encode().Bytes(),
+		"high tag": highTag.Bytes(),
+		"large":    large.Bytes(),
+		// Active Directory encodes lengths with four bytes
+		"non-minimal length": {0x30, 0x84, 0x00, 0x00, 0x00, 0x06, 0x02, 0x01, 0x01, 0x04, 0x01, 'a'},
+		"indefinite length":  {0x30, 0x80, 0x02, 0x01, 0x01, 0x30, 0x80, 0x04, 0x01, 'a', 0x00, 0x00, 0x00, 0x00},
+	} {
+		want, err := ber.DecodePacketErr(data)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		got, err := decodePacket(data)
+		if assert.NoError(t, err, name) {
+			assertSamePacket(t, want, got, name)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"truncated":                 {0x30, 0x06, 0x02, 0x01, 0x01},
+		"invalid length":            {0x04, 0xff},
+		"indefinite primitive":      {0x04, 0x80, 0x00, 0x00},
+		"eoc with definite length":  {0x30, 0x02, 0x00, 0x00},
+		"invalid UTF-8":             {0x0c, 0x01, 0xff},
+		"missing end-of-contents":   {0x30, 0x80, 0x02, 0x01, 0x01},
+		"child beyond parent":       {0x30, 0x02, 0x04, 0x03, 'a', 'b', 'c'},
+		"zero high tag":             {0x1f, 0x80, 0x01, 0x00},
+		"invalid printable string":  {0x13, 0x01, '*'},
+		"invalid IA5 string":        {0x16, 0x01, 0x80},
+		"long-form length overflow": {0x04, 0x89, 0x01},
+	} {
+		_, berErr := ber.DecodePacketErr(data)
+		assert.Error(t, berErr, name)
+		_, err := decodePacket(data)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestDecodePacketDataIsolated(t *testing.T) {
+	data := testEntryMessage(1)
+	packet, err := decodePacket(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// decoders rewriting the Data of a packet must not affect the message or
+	// the ByteValues referencing it
+	value := packet.Children[1].Children[0]
+	value.Data.Truncate(0)
+	value.Data.WriteString("rewritten")
+	assert.Equal(t, testEntryMessage(1), data)
+	assert.Equal(t, []byte("uid=jdoe,ou=people,dc=example,dc=com"), value.ByteValue)
+}
+
+func TestEncodeMessage(t *testing.T) {
+	for _, length := range []int{0, 1, 0x7f, 0x80, 0xff, 0x100, 0x10000} {
+		for _, tag := range []ber.Tag{ber.TagOctetString, 30, 31, 127, 128, 1 << 20} {
+			p := ber.NewString(ber.ClassContext, ber.TypePrimitive, tag, string(make([]byte, length)), "Value")
+			buf := encodeMessage(p)
+			assert.Equal(t, p.Bytes(), buf.Bytes(), "length %d, tag %d", length, tag)
+			releaseBuffer(buf)
+		}
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	indefinite := []byte{0x30, 0x80, 0x02, 0x01, 0x02, 0x42, 0x00, 0x00, 0x00}
+	long := testEntryMessage(100)
+	stream := append(append(append([]byte{}, testEntryMessage(1)...), indefinite...), long...)
+	r := bufio.NewReader(bytes.NewReader(stream))
+	var captured bytes.Buffer
+
+	for _, want := range [][]byte{testEntryMessage(1), indefinite, long} {
+		packet, data, err := readResponse(r, &captured)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, want, data)
+		assert.Equal(t, 2, len(packet.Children))
+	}
+	_, _, err := readResponse(r, &captured)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	r = bufio.NewReader(bytes.NewReader(long[:100]))
+	_, _, err = readResponse(r, &captured)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+// BenchmarkReadResponse compares reading search result entries with
+// ber.ReadPacket and readResponse
+func BenchmarkReadResponse(b *testing.B) {
+	message := testEntryMessage(20)
+	stream := bytes.Repeat(message, 100)
+	for _, bench := range []struct {
+		name string
+		read func(r *bufio.Reader, captured *bytes.Buffer) error
+	}{
+		{"ber.ReadPacket", func(r *bufio.Reader, captured *bytes.Buffer) error {
+			captured.Reset()
+			_, err := ber.ReadPacket(io.TeeReader(r, captured))
+			return err
+		}},
+		{"readResponse", func(r *bufio.Reader, captured *bytes.Buffer) error {
+			_, _, err := readResponse(r, captured)
+			return err
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(message)))
+			var captured bytes.Buffer
+			src := bytes.NewReader(stream)
+			r := bufio.NewReader(src)
+			for i := 0; i < b.N; i++ {
+				if r.Buffered() == 0 && src.Len() == 0 {
+					src.Reset(stream)
+					r.Reset(src)
+				}
+				if err := bench.read(r, &captured); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkEncodeMessage compares encoding a bind request with
+// ber.Packet.Bytes and the pooled buffers of encodeMessage
+func BenchmarkEncodeMessage(b *testing.B) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	if err := NewSimpleBindRequest("uid=jdoe,ou=people,dc=example,dc=com", "secret", nil).appendTo(envelope); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = ioutil.Discard.Write(envelope.Bytes())
+		}
+	})
+	b.Run("encodeMessage", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := encodeMessage(envelope)
+			_, _ = ioutil.Discard.Write(buf.Bytes())
+			releaseBuffer(buf)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SearchPager retrieves the results of a paged search page by page, so only
+// one page has to be held in memory at a time. See Conn.SearchPager.
+type SearchPager struct {
+	conn          *Conn
+	searchRequest *SearchRequest
+	pagingControl *ControlPaging
+	done          bool
+}
+
+// SearchPager returns a pager for the given search request, which is sent
+// once per page with the paging control updated as in SearchWithPaging. No
+// request is sent until NextPage is called.
+func (l *Conn) SearchPager(searchRequest *SearchRequest, pagingSize uint32) (*SearchPager, error) {
+	pagingControl, err := pagingControlFor(searchRequest, pagingSize)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchPager{
+		conn:          l,
+		searchRequest: searchRequest,
+		pagingControl: pagingControl,
+	}, nil
+}
+
+// NextPage retrieves the next page of results. The returned bool is true if
+// there are more pages to retrieve. Once it is false, further calls return
+// a nil result.
+func (p *SearchPager) NextPage() (*SearchResult, bool, error) {
+	if p.done {
+		return nil, false, nil
+	}
+
+	result, err := p.conn.Search(p.searchRequest)
+	if err != nil {
+		p.done = true
+		return result, false, sessionLost(err, p.pagingControl.Cookie)
+	}
+	if result == nil {
+		p.done = true
+		return nil, false, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
+	}
+
+	pagingResult := FindControl(result.Controls, ControlTypePaging)
+	if pagingResult == nil {
+		p.done = true
+		return result, false, nil
+	}
+	cookie := pagingResult.(*ControlPaging).Cookie
+	if len(cookie) == 0 {
+		p.done = true
+		return result, false, nil
+	}
+	p.pagingControl.SetCookie(cookie)
+	return result, true, nil
+}
+
+// Close abandons the paged search on the server if not all pages were
+// retrieved. Calling Close before the first or after the last page is a
+// no-op. The paging control of the search request is left unchanged.
+func (p *SearchPager) Close() error {
+	if p.done {
+		return nil
+	}
+	p.done = true
+	if len(p.pagingControl.Cookie) == 0 {
+		// no search is in progress on the server
+		return nil
+	}
+	p.conn.Debug.Printf("Abandoning Paging...")
+	abandon := cloneSearchRequest(p.searchRequest)
+	abandon.Controls = append(abandon.Controls, &ControlPaging{PagingSize: 0, Cookie: p.pagingControl.Cookie})
+	_, err := p.conn.Search(abandon)
+	return err
+}
+
+// SessionLostError is the underlying error of the *Error returned when the
+// connection of a search spanning several requests, e.g. a paged search,
+// fails after the server issued a cookie. Cookies are only valid on the
+// connection and server which issued them, so the search has to be started
+// over on a new connection, e.g.
+//
+//	var lost *ldap.SessionLostError
+//	if errors.As(err, &lost) {
+//		...
+//	}
+type SessionLostError struct {
+	// Cookie is the last cookie issued by the server
+	Cookie []byte
+	// Err is the error of the failed request
+	Err error
+}
+
+func (e *SessionLostError) Error() string {
+	return "ldap: connection lost during a search with cookie, start over: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the failed request
+func (e *SessionLostError) Unwrap() error {
+	return e.Err
+}
+
+// sessionLost wraps network errors of a search holding the given cookie in a
+// *SessionLostError
+func sessionLost(err error, cookie []byte) error {
+	if len(cookie) == 0 || !IsErrorWithCode(err, ErrorNetwork) {
+		return err
+	}
+	return NewError(ErrorNetwork, &SessionLostError{Cookie: cookie, Err: err})
+}
+
+// pagingControlFor returns the paging control of the search request, adding
+// one with the given paging size if needed
+func pagingControlFor(searchRequest *SearchRequest, pagingSize uint32) (*ControlPaging, error) {
+	control := FindControl(searchRequest.Controls, ControlTypePaging)
+	if control == nil {
+		pagingControl := NewControlPaging(pagingSize)
+		searchRequest.Controls = append(searchRequest.Controls, pagingControl)
+		return pagingControl, nil
+	}
+
+	pagingControl, ok := control.(*ControlPaging)
+	if !ok {
+		return nil, fmt.Errorf("expected paging control to be of type *ControlPaging, got %v", control)
+	}
+	if pagingControl.PagingSize != pagingSize {
+		return nil, fmt.Errorf("paging size given in search request (%d) conflicts with size given in search call (%d)", pagingControl.PagingSize, pagingSize)
+	}
+	return pagingControl, nil
+}
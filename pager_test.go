@@ -0,0 +1,215 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// respondToPagedSearch answers a search request with the given entries and a
+// paging control carrying cookie, returning the paging control of the request
+func respondToPagedSearch(ptc *packetTranslatorConn, cookie string, dns ...string) (*ControlPaging, error) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return nil, err
+	}
+	messageID := req.Children[0].Value.(int64)
+
+	var requestControl *ControlPaging
+	if len(req.Children) == 3 {
+		for _, child := range req.Children[2].Children {
+			control, err := DecodeControl(child)
+			if err != nil {
+				return nil, err
+			}
+			if paging, ok := control.(*ControlPaging); ok {
+				requestControl = paging
+			}
+		}
+	}
+
+	for _, dn := range dns {
+		entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		searchEntry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+		searchEntry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+		searchEntry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+		entry.AppendChild(searchEntry)
+		if err := ptc.SendResponse(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	done := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	searchDone := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	searchDone.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+	searchDone.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	searchDone.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	done.AppendChild(searchDone)
+	responseControl := NewControlPaging(0)
+	responseControl.SetCookie([]byte(cookie))
+	done.AppendChild(encodeControls([]Control{responseControl}))
+	return requestControl, ptc.SendResponse(done)
+}
+
+func TestSearchPager(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	pager, err := conn.SearchPager(searchRequest, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pages := []struct {
+		cookie string
+		dns    []string
+	}{
+		{cookie: "1", dns: []string{"cn=a", "cn=b"}},
+		{cookie: "", dns: []string{"cn=c"}},
+	}
+	requestCookies := make(chan string, len(pages))
+	go func() {
+		for _, page := range pages {
+			control, err := respondToPagedSearch(ptc, page.cookie, page.dns...)
+			if err != nil || control == nil {
+				close(requestCookies)
+				return
+			}
+			requestCookies <- string(control.Cookie)
+		}
+	}()
+
+	for i, page := range pages {
+		result, more, err := pager.NextPage()
+		if err != nil {
+			t.Fatalf("page %d: %s", i, err)
+		}
+		if more != (i < len(pages)-1) {
+			t.Errorf("page %d: unexpected more %t", i, more)
+		}
+		if len(result.Entries) != len(page.dns) || result.Entries[0].DN != page.dns[0] {
+			t.Errorf("page %d: unexpected entries %v", i, result.Entries)
+		}
+	}
+	if cookie := <-requestCookies; cookie != "" {
+		t.Errorf("first request sent cookie %q", cookie)
+	}
+	if cookie := <-requestCookies; cookie != "1" {
+		t.Errorf("second request sent cookie %q, expected %q", cookie, "1")
+	}
+
+	result, more, err := pager.NextPage()
+	if result != nil || more || err != nil {
+		t.Errorf("expected no more pages, got %v, %t, %v", result, more, err)
+	}
+	if err := pager.Close(); err != nil {
+		t.Errorf("close after last page failed: %s", err)
+	}
+}
+
+func TestSearchPagerClose(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	pager, err := conn.SearchPager(searchRequest, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abandonSize := make(chan uint32, 1)
+	go func() {
+		if _, err := respondToPagedSearch(ptc, "1", "cn=a"); err != nil {
+			return
+		}
+		control, err := respondToPagedSearch(ptc, "")
+		if err != nil || control == nil {
+			close(abandonSize)
+			return
+		}
+		abandonSize <- control.PagingSize
+	}()
+
+	if _, more, err := pager.NextPage(); err != nil || !more {
+		t.Fatalf("unexpected first page: %t, %v", more, err)
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if size, ok := <-abandonSize; !ok || size != 0 {
+		t.Errorf("expected paging to be abandoned with size 0, got %d", size)
+	}
+	if control := FindControl(searchRequest.Controls, ControlTypePaging).(*ControlPaging); control.PagingSize != 1 {
+		t.Errorf("expected the paging control of the request to be unchanged, got %v", control)
+	}
+}
+
+func TestSearchPagerCloseWithoutCookie(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	pager, err := conn.SearchPager(searchRequest, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing is sent before the first page
+	if err := pager.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if result, more, err := pager.NextPage(); result != nil || more || err != nil {
+		t.Errorf("expected no pages after Close, got %v, %t, %v", result, more, err)
+	}
+	ptc.lock.Lock()
+	sent := ptc.requestBuf.Len()
+	ptc.lock.Unlock()
+	if sent != 0 {
+		t.Errorf("expected no request to be sent, got %d bytes", sent)
+	}
+}
+
+func TestSearchPagerSessionLost(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	pager, err := conn.SearchPager(searchRequest, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go respondToPagedSearch(ptc, "1", "cn=a")
+	if _, more, err := pager.NextPage(); err != nil || !more {
+		t.Fatalf("unexpected first page: %t, %v", more, err)
+	}
+
+	ptc.Close()
+	_, more, err := pager.NextPage()
+	var lost *SessionLostError
+	if more || !errors.As(err, &lost) {
+		t.Fatalf("expected a SessionLostError, got %t, %v", more, err)
+	}
+	if string(lost.Cookie) != "1" || !IsErrorWithCode(err, ErrorNetwork) {
+		t.Errorf("unexpected error %v with cookie %q", err, lost.Cookie)
+	}
+}
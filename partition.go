@@ -0,0 +1,136 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PartitionByAttribute splits the search request into one request per value
+// prefix, each additionally filtering on entries whose attribute starts with
+// the prefix, e.g. the letters of the alphabet for "cn". Entries without the
+// attribute or not matching any prefix are not covered by the partitions.
+func PartitionByAttribute(searchRequest *SearchRequest, attribute string, prefixes []string) []*SearchRequest {
+	partitions := make([]*SearchRequest, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		partition := cloneSearchRequest(searchRequest)
+		partition.Filter = fmt.Sprintf("(&%s(%s=%s*))", searchRequest.Filter, attribute, EscapeFilter(prefix))
+		partitions = append(partitions, partition)
+	}
+	return partitions
+}
+
+// PartitionByChildren splits a subtree search request into a search of the
+// base object and one subtree search per direct child of the base DN, which
+// are discovered with a one-level search using l.
+func PartitionByChildren(l Client, searchRequest *SearchRequest) ([]*SearchRequest, error) {
+	if searchRequest.Scope != ScopeWholeSubtree {
+		return nil, errors.New("ldap: only subtree searches can be partitioned by children")
+	}
+
+	children, err := l.Search(NewSearchRequest(
+		searchRequest.BaseDN, ScopeSingleLevel, searchRequest.DerefAliases, 0, searchRequest.TimeLimit, false,
+		"(objectClass=*)", []string{"1.1"}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	base := cloneSearchRequest(searchRequest)
+	base.Scope = ScopeBaseObject
+	partitions := []*SearchRequest{base}
+	for _, child := range children.Entries {
+		partition := cloneSearchRequest(searchRequest)
+		partition.BaseDN = child.DN
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+// SearchPartitioned performs the partitions of a search, see
+// PartitionByAttribute and PartitionByChildren, in parallel using one worker
+// per client and merges the results in the order of the partitions. If
+// pagingSize is not 0, the partitions are searched with SearchWithPaging.
+//
+// The first error stops the remaining partitions from being started and is
+// returned together with the results of the partitions which succeeded.
+func SearchPartitioned(clients []Client, partitions []*SearchRequest, pagingSize uint32) (*SearchResult, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("ldap: no clients given for partitioned search")
+	}
+
+	results := make([]*SearchResult, len(partitions))
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		stop     = make(chan struct{})
+	)
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client Client) {
+			defer wg.Done()
+			for i := range indexes {
+				var (
+					result *SearchResult
+					err    error
+				)
+				if pagingSize != 0 {
+					result, err = client.SearchWithPaging(partitions[i], pagingSize)
+				} else {
+					result, err = client.Search(partitions[i])
+				}
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("ldap: partition %q %s failed: %w", partitions[i].BaseDN, partitions[i].Filter, err)
+						close(stop)
+					})
+					continue
+				}
+				results[i] = result
+			}
+		}(client)
+	}
+
+feed:
+	for i := range partitions {
+		select {
+		case indexes <- i:
+		case <-stop:
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	merged := &SearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: make([]string, 0),
+		Controls:  make([]Control, 0),
+	}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		merged.Entries = append(merged.Entries, result.Entries...)
+		merged.Referrals = append(merged.Referrals, result.Referrals...)
+		merged.Controls = append(merged.Controls, result.Controls...)
+	}
+	return merged, firstErr
+}
+
+// cloneSearchRequest returns a copy of the search request which can be
+// modified and sent concurrently with the original. Paging controls are left
+// out, as their state is specific to a single search.
+func cloneSearchRequest(searchRequest *SearchRequest) *SearchRequest {
+	clone := *searchRequest
+	clone.Attributes = append([]string(nil), searchRequest.Attributes...)
+	clone.Controls = nil
+	for _, control := range searchRequest.Controls {
+		if control.GetControlType() != ControlTypePaging {
+			clone.Controls = append(clone.Controls, control)
+		}
+	}
+	return &clone
+}
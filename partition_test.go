@@ -0,0 +1,59 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartitionByAttribute(t *testing.T) {
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false,
+		"(objectClass=person)", []string{"cn"}, []Control{NewControlPaging(10), NewControlManageDsaIT(false)})
+	partitions := PartitionByAttribute(searchRequest, "cn", []string{"a", "b*"})
+
+	if len(partitions) != 2 {
+		t.Fatalf("expected 2 partitions, got %d", len(partitions))
+	}
+	if partitions[0].Filter != "(&(objectClass=person)(cn=a*))" || partitions[1].Filter != `(&(objectClass=person)(cn=b\2a*))` {
+		t.Errorf("unexpected filters %q, %q", partitions[0].Filter, partitions[1].Filter)
+	}
+	if !reflect.DeepEqual(partitions[0].Controls, []Control{NewControlManageDsaIT(false)}) {
+		t.Errorf("unexpected controls %v", partitions[0].Controls)
+	}
+	partitions[0].Attributes[0] = "mail"
+	if searchRequest.Attributes[0] != "cn" {
+		t.Error("partition shares attributes with the original request")
+	}
+}
+
+func TestSearchPartitioned(t *testing.T) {
+	var clients []Client
+	for i := 0; i < 2; i++ {
+		ptc := newPacketTranslatorConn()
+		defer ptc.Close()
+		conn := NewConn(ptc, false)
+		conn.Start()
+		defer conn.Close()
+		clients = append(clients, conn)
+
+		// a worker may end up searching all partitions
+		go func() {
+			for j := 0; j < 2; j++ {
+				respondToSearch(ptc, "cn=x,dc=example,dc=com")
+			}
+		}()
+	}
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	partitions := PartitionByAttribute(searchRequest, "cn", []string{"a", "b"})
+	result, err := SearchPartitioned(clients, partitions, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(result.Entries))
+	}
+
+	if _, err := SearchPartitioned(nil, partitions, 0); err == nil {
+		t.Error("expected error without clients")
+	}
+}
@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
@@ -20,6 +21,12 @@ type PasswordModifyRequest struct {
 	OldPassword string
 	// NewPassword, if present, contains the desired password for this user
 	NewPassword string
+	// OldPasswordBytes and NewPasswordBytes are used instead of OldPassword
+	// and NewPassword if they are not nil. Unlike strings, they can be zeroed
+	// by the caller once the operation returned, as the request keeps no
+	// other copy of them.
+	OldPasswordBytes []byte
+	NewPasswordBytes []byte
 }
 
 // PasswordModifyResult holds the server response to a PasswordModifyRequest
@@ -39,21 +46,30 @@ func (req *PasswordModifyRequest) appendTo(envelope *ber.Packet) error {
 	if req.UserIdentity != "" {
 		passwordModifyRequestValue.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.UserIdentity, "User Identity"))
 	}
-	if req.OldPassword != "" {
+	// the sequence must not grow after the old password is written, which
+	// would leave a copy of it behind
+	passwordModifyRequestValue.Data.Grow(len(req.OldPasswordBytes) + len(req.NewPasswordBytes) + 20)
+	if req.OldPasswordBytes != nil {
+		appendSecretChild(passwordModifyRequestValue, newSecretPacket(ber.ClassContext, 1, req.OldPasswordBytes, "Old Password"))
+	} else if req.OldPassword != "" {
 		passwordModifyRequestValue.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, req.OldPassword, "Old Password"))
 	}
-	if req.NewPassword != "" {
+	if req.NewPasswordBytes != nil {
+		appendSecretChild(passwordModifyRequestValue, newSecretPacket(ber.ClassContext, 2, req.NewPasswordBytes, "New Password"))
+	} else if req.NewPassword != "" {
 		passwordModifyRequestValue.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 2, req.NewPassword, "New Password"))
 	}
-	extendedRequestValue.AppendChild(passwordModifyRequestValue)
+	appendSecretChild(extendedRequestValue, passwordModifyRequestValue)
 
-	pkt.AppendChild(extendedRequestValue)
+	appendSecretChild(pkt, extendedRequestValue)
 
-	envelope.AppendChild(pkt)
+	appendSecretChild(envelope, pkt)
 
 	return nil
 }
 
+func (req *PasswordModifyRequest) credentials() {}
+
 // NewPasswordModifyRequest creates a new PasswordModifyRequest
 //
 // According to the RFC 3602 (https://tools.ietf.org/html/rfc3062):
@@ -81,7 +97,15 @@ func NewPasswordModifyRequest(userIdentity string, oldPassword string, newPasswo
 
 // PasswordModify performs the modification request
 func (l *Conn) PasswordModify(passwordModifyRequest *PasswordModifyRequest) (*PasswordModifyResult, error) {
-	msgCtx, err := l.doRequest(passwordModifyRequest)
+	return l.PasswordModifyContext(context.Background(), passwordModifyRequest)
+}
+
+// PasswordModifyContext performs the modification request. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) PasswordModifyContext(ctx context.Context, passwordModifyRequest *PasswordModifyRequest) (_ *PasswordModifyResult, err error) {
+	defer correlateError(ctx, &err)
+
+	msgCtx, err := l.doRequestContext(ctx, passwordModifyRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,231 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Active Directory sub-error codes reported in the diagnostic message of a
+// failed bind, e.g. "80090308: LdapErr: DSID-0C09044E, comment:
+// AcceptSecurityContext error, data 775, v4563"
+const (
+	ADErrorUserNotFound       = 0x525
+	ADErrorInvalidCredentials = 0x52e
+	ADErrorInvalidLogonHours  = 0x530
+	ADErrorInvalidWorkstation = 0x531
+	ADErrorPasswordExpired    = 0x532
+	ADErrorAccountDisabled    = 0x533
+	ADErrorAccountExpired     = 0x701
+	ADErrorPasswordMustChange = 0x773
+	ADErrorAccountLockedOut   = 0x775
+)
+
+var adDiagnosticPattern = regexp.MustCompile(`, data ([0-9a-fA-F]+),`)
+
+// ADErrorCode returns the sub-error code Active Directory reported in the
+// diagnostic message of err, if any
+func ADErrorCode(err error) (int, bool) {
+	var ldapErr *Error
+	if !errors.As(err, &ldapErr) {
+		return 0, false
+	}
+	match := adDiagnosticPattern.FindStringSubmatch(ldapErr.DiagnosticMessage)
+	if match == nil {
+		return 0, false
+	}
+	code, parseErr := strconv.ParseInt(match[1], 16, 32)
+	if parseErr != nil {
+		return 0, false
+	}
+	return int(code), true
+}
+
+// PasswordPolicyStatus is the state of an account's password as reported on
+// a bind or password change, independent of the mechanism the server used:
+// the Behera password policy control, the Netscape password expired and
+// expiring controls, Active Directory sub-error codes or eDirectory errors
+type PasswordPolicyStatus struct {
+	// Expired is set if the password has expired
+	Expired bool
+	// MustChange is set if the password must be changed, e.g. after a reset
+	MustChange bool
+	// Locked is set if the account is locked out, e.g. after too many
+	// failed binds
+	Locked bool
+	// Disabled is set if the account is disabled
+	Disabled bool
+	// AccountExpired is set if the account itself has expired
+	AccountExpired bool
+	// ExpiresIn is the time until the password expires if the server warned
+	// about it, zero otherwise
+	ExpiresIn time.Duration
+	// GraceLogins is the number of remaining binds with the expired
+	// password, -1 if the server did not report it
+	GraceLogins int
+}
+
+// NewPasswordPolicyStatus returns the password policy status reported by the
+// controls and error of an operation, usually a bind. Request the Behera
+// password policy control with NewControlBeheraPasswordPolicy to receive it
+// from servers which only send it on request.
+func NewPasswordPolicyStatus(controls []Control, err error) *PasswordPolicyStatus {
+	s := &PasswordPolicyStatus{GraceLogins: -1}
+
+	if c, ok := FindControl(controls, ControlTypeBeheraPasswordPolicy).(*ControlBeheraPasswordPolicy); ok {
+		if expiresIn, ok := c.ExpiresIn(); ok {
+			s.ExpiresIn = expiresIn
+		}
+		if grace, ok := c.GraceLogins(); ok {
+			s.GraceLogins = grace
+		}
+		code, _ := c.ErrorCode()
+		switch code {
+		case BeheraPasswordExpired:
+			s.Expired = true
+		case BeheraAccountLocked:
+			s.Locked = true
+		case BeheraChangeAfterReset:
+			s.MustChange = true
+		}
+	}
+
+	if c, ok := FindControl(controls, ControlTypeVChuPasswordMustChange).(*ControlVChuPasswordMustChange); ok && c.MustChange {
+		// the control is sent with a failed bind if the password has
+		// expired, and with a successful one if it must be changed
+		if err != nil {
+			s.Expired = true
+		} else {
+			s.MustChange = true
+		}
+	}
+	if c, ok := FindControl(controls, ControlTypeVChuPasswordWarning).(*ControlVChuPasswordWarning); ok && c.Expire >= 0 {
+		s.ExpiresIn = time.Duration(c.Expire) * time.Second
+	}
+
+	if code, ok := ADErrorCode(err); ok {
+		switch code {
+		case ADErrorPasswordExpired:
+			s.Expired = true
+		case ADErrorPasswordMustChange:
+			s.MustChange = true
+		case ADErrorAccountLockedOut:
+			s.Locked = true
+		case ADErrorAccountDisabled:
+			s.Disabled = true
+		case ADErrorAccountExpired:
+			s.AccountExpired = true
+		}
+	}
+
+	var edirErr *EDirectoryError
+	if errors.As(err, &edirErr) {
+		switch edirErr.Code {
+		case EDirectoryErrorPasswordExpired, EDirectoryErrorPasswordExpiredGrace:
+			s.Expired = true
+		case EDirectoryErrorIntruderLockout:
+			s.Locked = true
+		case EDirectoryErrorAccountExpired:
+			s.AccountExpired = true
+		}
+	}
+	return s
+}
+
+// ExpiresIn returns the time until the password expires and true if the
+// server sent the timeBeforeExpiration warning
+func (c *ControlBeheraPasswordPolicy) ExpiresIn() (time.Duration, bool) {
+	if c.Expire < 0 {
+		return 0, false
+	}
+	return time.Duration(c.Expire) * time.Second, true
+}
+
+// GraceLogins returns the number of remaining binds with the expired
+// password and true if the server sent the graceAuthNsRemaining warning
+func (c *ControlBeheraPasswordPolicy) GraceLogins() (int, bool) {
+	if c.Grace < 0 {
+		return 0, false
+	}
+	return int(c.Grace), true
+}
+
+// ErrorCode returns the error, e.g. BeheraPasswordTooShort, and true if the
+// server sent one. Errors unknown to this package are returned as well.
+func (c *ControlBeheraPasswordPolicy) ErrorCode() (int8, bool) {
+	if c.Error < 0 {
+		return -1, false
+	}
+	return c.Error, true
+}
+
+// decode reads the PasswordPolicyResponseValue sequence of a response. Besides
+// the encoding of the draft, the variations of some servers are accepted: a
+// warning holding timeBeforeExpiration without the choice, and tags wrapping
+// a universal INTEGER or ENUMERATED. Elements with unknown tags are ignored.
+func (c *ControlBeheraPasswordPolicy) decode(sequence *ber.Packet) error {
+	for _, child := range sequence.Children {
+		if child.ClassType != ber.ClassContext {
+			continue
+		}
+		switch child.Tag {
+		case 0:
+			child.Description = "Warning"
+			warning := child
+			if child.TagType == ber.TypeConstructed {
+				if len(child.Children) == 0 {
+					return fmt.Errorf("password policy warning requires a value")
+				}
+				warning = child.Children[0]
+			}
+			val, err := beheraInteger(warning)
+			if err != nil {
+				return err
+			}
+			if warning == child || warning.Tag == 0 {
+				warning.Description = "timeBeforeExpiration"
+				c.Expire = val
+			} else if warning.Tag == 1 {
+				warning.Description = "graceAuthNsRemaining"
+				c.Grace = val
+			}
+		case 1:
+			child.Description = "Error"
+			val, err := beheraInteger(child)
+			if err != nil {
+				return err
+			}
+			if val < 0 || val > 127 {
+				return fmt.Errorf("failed to decode data bytes: %s", "invalid PasswordPolicyResponse enum value")
+			}
+			c.Error = int8(val)
+			c.ErrorString = BeheraPasswordPolicyErrorMap[c.Error]
+			if c.ErrorString == "" {
+				c.ErrorString = fmt.Sprintf("Unknown password policy error %d", c.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// beheraInteger returns the integer of a context tagged element of a password
+// policy response, which some servers wrap in a universal INTEGER or
+// ENUMERATED
+func beheraInteger(p *ber.Packet) (int64, error) {
+	if p.TagType == ber.TypeConstructed {
+		if len(p.Children) != 1 {
+			return 0, fmt.Errorf("invalid password policy element")
+		}
+		p = p.Children[0]
+	}
+	val, err := ber.ParseInt64(p.Data.Bytes())
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode data bytes: %s", err)
+	}
+	p.Value = val
+	return val, nil
+}
@@ -0,0 +1,180 @@
+package ldap
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestNewPasswordPolicyStatus(t *testing.T) {
+	adError := func(code string) error {
+		message := "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data " + code + ", v4563"
+		return &Error{ResultCode: LDAPResultInvalidCredentials, DiagnosticMessage: message, Err: errors.New(message)}
+	}
+
+	tests := []struct {
+		name     string
+		controls []Control
+		err      error
+		expected PasswordPolicyStatus
+	}{
+		{
+			name:     "no status",
+			expected: PasswordPolicyStatus{GraceLogins: -1},
+		},
+		{
+			name:     "behera warning",
+			controls: []Control{&ControlBeheraPasswordPolicy{Expire: 3600, Grace: -1, Error: -1}},
+			expected: PasswordPolicyStatus{ExpiresIn: time.Hour, GraceLogins: -1},
+		},
+		{
+			name:     "behera expired with grace logins",
+			controls: []Control{&ControlBeheraPasswordPolicy{Expire: -1, Grace: 2, Error: BeheraPasswordExpired}},
+			expected: PasswordPolicyStatus{Expired: true, GraceLogins: 2},
+		},
+		{
+			name:     "behera locked",
+			controls: []Control{&ControlBeheraPasswordPolicy{Expire: -1, Grace: -1, Error: BeheraAccountLocked}},
+			err:      ErrInvalidCredentials,
+			expected: PasswordPolicyStatus{Locked: true, GraceLogins: -1},
+		},
+		{
+			name:     "netscape must change",
+			controls: []Control{&ControlVChuPasswordMustChange{MustChange: true}},
+			expected: PasswordPolicyStatus{MustChange: true, GraceLogins: -1},
+		},
+		{
+			name:     "netscape expired",
+			controls: []Control{&ControlVChuPasswordMustChange{MustChange: true}},
+			err:      ErrInvalidCredentials,
+			expected: PasswordPolicyStatus{Expired: true, GraceLogins: -1},
+		},
+		{
+			name:     "netscape expiring",
+			controls: []Control{&ControlVChuPasswordWarning{Expire: 60}},
+			expected: PasswordPolicyStatus{ExpiresIn: time.Minute, GraceLogins: -1},
+		},
+		{name: "ad expired", err: adError("532"), expected: PasswordPolicyStatus{Expired: true, GraceLogins: -1}},
+		{name: "ad disabled", err: adError("533"), expected: PasswordPolicyStatus{Disabled: true, GraceLogins: -1}},
+		{name: "ad account expired", err: adError("701"), expected: PasswordPolicyStatus{AccountExpired: true, GraceLogins: -1}},
+		{name: "ad must change", err: adError("773"), expected: PasswordPolicyStatus{MustChange: true, GraceLogins: -1}},
+		{name: "ad locked", err: adError("775"), expected: PasswordPolicyStatus{Locked: true, GraceLogins: -1}},
+		{name: "ad invalid credentials", err: adError("52e"), expected: PasswordPolicyStatus{GraceLogins: -1}},
+		{
+			name:     "edirectory lockout",
+			err:      &Error{ResultCode: LDAPResultInvalidCredentials, Err: diagnosticError("NDS error: login lockout (-197)")},
+			expected: PasswordPolicyStatus{Locked: true, GraceLogins: -1},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			status := NewPasswordPolicyStatus(tc.controls, tc.err)
+			if *status != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, *status)
+			}
+		})
+	}
+
+	if code, ok := ADErrorCode(adError("775")); !ok || code != ADErrorAccountLockedOut {
+		t.Errorf("unexpected AD error code %#x", code)
+	}
+}
+
+func TestControlBeheraPasswordPolicyResponse(t *testing.T) {
+	for _, c := range []*ControlBeheraPasswordPolicy{
+		{Expire: 3600, Grace: -1, Error: -1},
+		{Expire: -1, Grace: 3, Error: BeheraPasswordExpired, ErrorString: "Password expired"},
+		{Expire: -1, Grace: -1, Error: BeheraChangeAfterReset, ErrorString: "Password must be changed"},
+		{Expire: -1, Grace: -1, Error: 9, ErrorString: "Unknown password policy error 9"},
+	} {
+		decoded, err := DecodeControl(c.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, decoded) {
+			t.Errorf("got %v, want %v", decoded, c)
+		}
+	}
+
+	c := NewControlBeheraPasswordPolicy()
+	if _, ok := c.ExpiresIn(); ok {
+		t.Error("unexpected expiration")
+	}
+	if _, ok := c.GraceLogins(); ok {
+		t.Error("unexpected grace logins")
+	}
+	if _, ok := c.ErrorCode(); ok {
+		t.Error("unexpected error")
+	}
+	c = &ControlBeheraPasswordPolicy{Expire: 60, Grace: 0, Error: BeheraPasswordTooShort}
+	if expiresIn, ok := c.ExpiresIn(); !ok || expiresIn != time.Minute {
+		t.Errorf("unexpected expiration %s", expiresIn)
+	}
+	if grace, ok := c.GraceLogins(); !ok || grace != 0 {
+		t.Errorf("unexpected grace logins %d", grace)
+	}
+	if code, ok := c.ErrorCode(); !ok || code != BeheraPasswordTooShort {
+		t.Errorf("unexpected error %d", code)
+	}
+}
+
+func TestControlBeheraPasswordPolicyVariations(t *testing.T) {
+	control := func(elements ...*ber.Packet) *ber.Packet {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeBeheraPasswordPolicy, "Control Type"))
+		seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswordPolicyResponseValue")
+		for _, e := range elements {
+			seq.AppendChild(e)
+		}
+		packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(seq.Bytes()), "Control Value"))
+		decoded, err := ber.DecodePacketErr(packet.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded
+	}
+	wrap := func(class ber.Class, tag ber.Tag, child *ber.Packet) *ber.Packet {
+		p := ber.Encode(class, ber.TypeConstructed, tag, nil, "")
+		p.AppendChild(child)
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		packet *ber.Packet
+		want   *ControlBeheraPasswordPolicy
+	}{
+		{
+			name:   "warning without choice",
+			packet: control(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 0, int64(120), "")),
+			want:   &ControlBeheraPasswordPolicy{Expire: 120, Grace: -1, Error: -1},
+		},
+		{
+			name:   "grace as tagged integer",
+			packet: control(wrap(ber.ClassContext, 0, wrap(ber.ClassContext, 1, ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(2), "")))),
+			want:   &ControlBeheraPasswordPolicy{Expire: -1, Grace: 2, Error: -1},
+		},
+		{
+			name: "error as tagged enumerated",
+			packet: control(
+				ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(7), "unknown element"),
+				wrap(ber.ClassContext, 1, ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(BeheraAccountLocked), "")),
+			),
+			want: &ControlBeheraPasswordPolicy{Expire: -1, Grace: -1, Error: BeheraAccountLocked, ErrorString: "Account locked"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeControl(tt.packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package ldap
+
+import (
+	"strings"
+	"unicode"
+)
+
+// passwordQualityAttributes are the attributes of an OpenLDAP pwdPolicy
+// entry read by GetPasswordQualityPolicy
+var passwordQualityAttributes = []string{"pwdMinLength", "pwdMaxLength", "pwdCheckQuality", "pwdInHistory"}
+
+// PasswordQualityPolicy checks new passwords against the quality rules of an
+// OpenLDAP password policy before they are sent, so that applications can
+// report violations immediately instead of after a failed PasswordModify or
+// Modify. The server remains the authority: its check modules may enforce
+// rules which are not known to the client.
+type PasswordQualityPolicy struct {
+	// MinLength is the minimum length of a password in bytes (pwdMinLength)
+	MinLength int
+	// MaxLength is the maximum length of a password in bytes, 0 for no limit
+	// (pwdMaxLength)
+	MaxLength int
+	// CheckQuality is 0 if the server does not check the quality of new
+	// passwords, in which case the lengths are not checked either, 1 if it
+	// checks them if it can and 2 if it must (pwdCheckQuality)
+	CheckQuality int
+	// InHistory is the number of previous passwords which cannot be reused
+	// (pwdInHistory)
+	InHistory int
+	// MinClasses is the number of character classes, i.e. lower case and
+	// upper case letters, digits and other characters, a password must
+	// contain. The pwdPolicy schema has no such attribute, it is set by the
+	// application to match the check module of the server, e.g. ppm.
+	MinClasses int
+}
+
+// NewPasswordQualityPolicy returns the password quality policy of the given
+// pwdPolicy entry
+func NewPasswordQualityPolicy(entry *Entry) (*PasswordQualityPolicy, error) {
+	var attrs struct {
+		MinLength    int `ldap:"pwdMinLength"`
+		MaxLength    int `ldap:"pwdMaxLength"`
+		CheckQuality int `ldap:"pwdCheckQuality"`
+		InHistory    int `ldap:"pwdInHistory"`
+	}
+	if err := NewDecoder(DecodeWithCaseInsensitive(true)).Decode(entry, &attrs); err != nil {
+		return nil, err
+	}
+	return &PasswordQualityPolicy{
+		MinLength:    attrs.MinLength,
+		MaxLength:    attrs.MaxLength,
+		CheckQuality: attrs.CheckQuality,
+		InHistory:    attrs.InHistory,
+	}, nil
+}
+
+// GetPasswordQualityPolicy reads the password quality policy of the pwdPolicy
+// entry with the given DN, e.g. the pwdPolicySubentry of a user or the
+// default policy of the ppolicy overlay
+func (l *Conn) GetPasswordQualityPolicy(dn string) (*PasswordQualityPolicy, error) {
+	entry, err := l.GetEntry(dn, passwordQualityAttributes...)
+	if err != nil {
+		return nil, err
+	}
+	return NewPasswordQualityPolicy(entry)
+}
+
+// PasswordQualityViolation is a rule of a PasswordQualityPolicy a password
+// does not satisfy
+type PasswordQualityViolation int
+
+// Password quality violations
+const (
+	PasswordTooShort PasswordQualityViolation = iota
+	PasswordTooLong
+	PasswordTooFewClasses
+	PasswordInHistory
+)
+
+// PasswordQualityViolationMap contains human readable descriptions of
+// password quality violations
+var PasswordQualityViolationMap = map[PasswordQualityViolation]string{
+	PasswordTooShort:      "too short",
+	PasswordTooLong:       "too long",
+	PasswordTooFewClasses: "too few character classes",
+	PasswordInHistory:     "used before",
+}
+
+// PasswordQualityError is returned for passwords which violate a
+// PasswordQualityPolicy, e.g.
+//
+//	var quality *ldap.PasswordQualityError
+//	if errors.As(err, &quality) {
+//		...
+//	}
+type PasswordQualityError struct {
+	// Violations are the violated rules
+	Violations []PasswordQualityViolation
+}
+
+func (e *PasswordQualityError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		reasons[i] = PasswordQualityViolationMap[v]
+	}
+	return "ldap: password is " + strings.Join(reasons, ", ")
+}
+
+// Check returns a *PasswordQualityError if password violates the length or
+// character class rules of the policy
+func (p *PasswordQualityPolicy) Check(password string) error {
+	var violations []PasswordQualityViolation
+	if p.CheckQuality > 0 {
+		if len(password) < p.MinLength {
+			violations = append(violations, PasswordTooShort)
+		}
+		if p.MaxLength > 0 && len(password) > p.MaxLength {
+			violations = append(violations, PasswordTooLong)
+		}
+	}
+	if p.MinClasses > 0 && passwordClasses(password) < p.MinClasses {
+		violations = append(violations, PasswordTooFewClasses)
+	}
+	if len(violations) > 0 {
+		return &PasswordQualityError{Violations: violations}
+	}
+	return nil
+}
+
+// passwordClasses returns the number of character classes in password
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// CheckPasswordQuality checks password as the new password of the user with
+// the given DN against policy. If the policy keeps a password history, the
+// password is compared with the current userPassword of the user, which the
+// server rejects as well. Older passwords cannot be checked, as the
+// pwdHistory attribute is not readable by clients. A compare refused by the
+// server, e.g. for lack of access rights, skips the history check.
+func (l *Conn) CheckPasswordQuality(policy *PasswordQualityPolicy, dn, password string) error {
+	err := policy.Check(password)
+	if err != nil || policy.InHistory == 0 {
+		return err
+	}
+	current, err := l.Compare(dn, "userPassword", password)
+	if err != nil {
+		if IsErrorAnyOf(err, LDAPResultInsufficientAccessRights, LDAPResultNoSuchAttribute,
+			LDAPResultUndefinedAttributeType, LDAPResultInappropriateMatching) {
+			return nil
+		}
+		return err
+	}
+	if current {
+		return &PasswordQualityError{Violations: []PasswordQualityViolation{PasswordInHistory}}
+	}
+	return nil
+}
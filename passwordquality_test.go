@@ -0,0 +1,65 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordQualityPolicy(t *testing.T) {
+	policy, err := NewPasswordQualityPolicy(NewEntry("cn=default,ou=policies,dc=example,dc=com", map[string][]string{
+		"pwdMinLength":    {"8"},
+		"pwdMaxLength":    {"16"},
+		"pwdCheckQuality": {"2"},
+		"pwdInHistory":    {"5"},
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &PasswordQualityPolicy{MinLength: 8, MaxLength: 16, CheckQuality: 2, InHistory: 5}, policy)
+	policy.MinClasses = 3
+
+	assert.NoError(t, policy.Check("Secret-42"))
+	var quality *PasswordQualityError
+	if assert.True(t, errors.As(policy.Check("secret"), &quality)) {
+		assert.Equal(t, []PasswordQualityViolation{PasswordTooShort, PasswordTooFewClasses}, quality.Violations)
+		assert.Equal(t, "ldap: password is too short, too few character classes", quality.Error())
+	}
+	if assert.True(t, errors.As(policy.Check("Secret-42-Secret-42"), &quality)) {
+		assert.Equal(t, []PasswordQualityViolation{PasswordTooLong}, quality.Violations)
+	}
+
+	// the server does not check the lengths without pwdCheckQuality
+	policy.CheckQuality = 0
+	assert.NoError(t, policy.Check("Pw-1"))
+}
+
+func TestCheckPasswordQuality(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	policy := &PasswordQualityPolicy{MinLength: 8, CheckQuality: 1, InHistory: 3}
+	dn := "uid=jdoe,ou=people,dc=example,dc=com"
+
+	// local violations are reported without a compare
+	var quality *PasswordQualityError
+	assert.True(t, errors.As(conn.CheckPasswordQuality(policy, dn, "short"), &quality))
+
+	go respondWithResult(ptc, ApplicationCompareResponse, LDAPResultCompareTrue)
+	if assert.True(t, errors.As(conn.CheckPasswordQuality(policy, dn, "current password"), &quality)) {
+		assert.Equal(t, []PasswordQualityViolation{PasswordInHistory}, quality.Violations)
+	}
+
+	go respondWithResult(ptc, ApplicationCompareResponse, LDAPResultCompareFalse)
+	assert.NoError(t, conn.CheckPasswordQuality(policy, dn, "new password"))
+
+	go respondWithResult(ptc, ApplicationCompareResponse, LDAPResultInsufficientAccessRights)
+	assert.NoError(t, conn.CheckPasswordQuality(policy, dn, "new password"))
+
+	go respondWithResult(ptc, ApplicationCompareResponse, LDAPResultNoSuchObject)
+	assert.True(t, IsErrorWithCode(conn.CheckPasswordQuality(policy, dn, "new password"), LDAPResultNoSuchObject))
+}
@@ -0,0 +1,107 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxRangeRetrievals limits the follow-up searches for a single attribute,
+// in case a server keeps returning the same range
+const maxRangeRetrievals = 10000
+
+// parseRangeOption splits an attribute description like
+// "member;range=0-1499" into the description without the range option and
+// the bounds of the range. The upper bound is -1 for "*", which marks the
+// last range. ok is false if the description has no range option.
+func parseRangeOption(name string) (base string, low, high int, ok bool) {
+	options := strings.Split(name, ";")
+	for i, option := range options[1:] {
+		if len(option) < 6 || !strings.EqualFold(option[:6], "range=") {
+			continue
+		}
+		bounds := strings.SplitN(option[6:], "-", 2)
+		if len(bounds) != 2 {
+			return "", 0, 0, false
+		}
+		var err error
+		if low, err = strconv.Atoi(bounds[0]); err != nil {
+			return "", 0, 0, false
+		}
+		if bounds[1] == "*" {
+			high = -1
+		} else if high, err = strconv.Atoi(bounds[1]); err != nil {
+			return "", 0, 0, false
+		}
+		base = strings.Join(append(options[:i+1:i+1], options[i+2:]...), ";")
+		return base, low, high, true
+	}
+	return "", 0, 0, false
+}
+
+// completeRangedAttributes replaces the ranged attributes of the entries
+// with attributes holding all values, which are read with base searches
+// for the following ranges
+func (l *Conn) completeRangedAttributes(ctx context.Context, entries []*Entry, decoding valueDecoding) error {
+	for _, entry := range entries {
+		for i, attr := range entry.Attributes {
+			base, _, high, ok := parseRangeOption(attr.Name)
+			if !ok {
+				continue
+			}
+			complete := &EntryAttribute{
+				Name:       base,
+				Values:     append([]string(nil), attr.Values...),
+				ByteValues: append([][]byte(nil), attr.ByteValues...),
+				IsBinary:   attr.IsBinary,
+			}
+			for n := 0; high >= 0; n++ {
+				if n >= maxRangeRetrievals {
+					return fmt.Errorf("ldap: too many ranges retrieving %s of %s", base, entry.DN)
+				}
+				next, err := l.retrieveRange(ctx, entry.DN, base, high+1, decoding)
+				if err != nil {
+					return err
+				}
+				if next == nil {
+					break
+				}
+				complete.Values = append(complete.Values, next.Values...)
+				complete.ByteValues = append(complete.ByteValues, next.ByteValues...)
+				complete.IsBinary = complete.IsBinary || next.IsBinary
+				_, _, high, _ = parseRangeOption(next.Name)
+			}
+			if complete.IsBinary {
+				// the ranges may differ in whether they hold binary values
+				complete.Values = binaryStringValues(complete.ByteValues, decoding.binary)
+			}
+			entry.Attributes[i] = complete
+		}
+	}
+	return nil
+}
+
+// retrieveRange reads the values of the attribute from low onwards. nil is
+// returned if the server returned no further range.
+func (l *Conn) retrieveRange(ctx context.Context, dn, attribute string, low int, decoding valueDecoding) (*EntryAttribute, error) {
+	req := NewSearchRequest(
+		dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{fmt.Sprintf("%s;range=%d-*", attribute, low)}, nil,
+	)
+	req.DisableRangeRetrieval = true
+	req.BinaryValues = decoding.binary
+	req.LazyValues = decoding.lazy
+	result, err := l.SearchContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range result.Entries {
+		for _, attr := range entry.Attributes {
+			if base, _, _, ok := parseRangeOption(attr.Name); ok && strings.EqualFold(base, attribute) {
+				return attr, nil
+			}
+		}
+	}
+	return nil, nil
+}
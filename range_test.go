@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRangeOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		low, high int
+		ok        bool
+	}{
+		{"member;range=0-1499", "member", 0, 1499, true},
+		{"member;Range=1500-*", "member", 1500, -1, true},
+		{"description;lang-de;range=3-4", "description;lang-de", 3, 4, true},
+		{"member", "", 0, 0, false},
+		{"member;range=x-1", "", 0, 0, false},
+	}
+	for _, test := range tests {
+		base, low, high, ok := parseRangeOption(test.name)
+		if base != test.base || low != test.low || high != test.high || ok != test.ok {
+			t.Errorf("%s: got %q %d %d %t", test.name, base, low, high, ok)
+		}
+	}
+}
+
+func TestSearchRangeRetrieval(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	requested := make(chan []string, 2)
+	go func() {
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("cn=group,dc=example", map[string][]string{
+			"cn":               {"group"},
+			"member;range=0-1": {"cn=a", "cn=b"},
+		}))
+		for _, attr := range []map[string][]string{
+			{"member;range=2-3": {"cn=c", "cn=d"}},
+			{"member;range=4-*": {"cn=e"}},
+		} {
+			req, err := ptc.ReceiveRequest()
+			if err != nil {
+				return
+			}
+			var attributes []string
+			for _, a := range req.Children[1].Children[7].Children {
+				attributes = append(attributes, a.Value.(string))
+			}
+			requested <- attributes
+			sendSearchResult(ptc, req.Children[0].Value.(int64), LDAPResultSuccess, NewEntry("cn=group,dc=example", attr))
+		}
+	}()
+
+	result, err := conn.Search(NewSearchRequest("cn=group,dc=example", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cn=a", "cn=b", "cn=c", "cn=d", "cn=e"}
+	if members := result.Entries[0].GetAttributeValues("member"); !reflect.DeepEqual(members, expected) {
+		t.Errorf("got members %v, expected %v", members, expected)
+	}
+	if cn := result.Entries[0].GetAttributeValue("cn"); cn != "group" {
+		t.Errorf("got cn %q", cn)
+	}
+	if attrs := <-requested; !reflect.DeepEqual(attrs, []string{"member;range=2-*"}) {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+	if attrs := <-requested; !reflect.DeepEqual(attrs, []string{"member;range=4-*"}) {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("cn=group,dc=example", map[string][]string{
+		"member;range=0-1": {"cn=a", "cn=b"},
+	}))
+	req := NewSearchRequest("cn=group,dc=example", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	req.DisableRangeRetrieval = true
+	result, err = conn.Search(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Entries[0].Attributes[0].Name != "member;range=0-1" {
+		t.Errorf("expected ranged attribute, got %s", result.Entries[0].Attributes[0].Name)
+	}
+}
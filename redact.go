@@ -0,0 +1,225 @@
+package ldap
+
+import (
+	"bytes"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// DebugRedactedAttributes lists the attributes, in lower case, whose values are
+// masked when packets are printed for debugging.
+//
+// WARNING: since this is a package-level variable, it must not be modified
+// while connections are in use.
+var DebugRedactedAttributes = map[string]bool{
+	"userpassword":    true,
+	"unicodepwd":      true,
+	"authpassword":    true,
+	"sambantpassword": true,
+	"sambalmpassword": true,
+}
+
+const redactedPacketValue = "[REDACTED]"
+
+// redactPacket returns a copy of the given LDAP message with bind credentials,
+// SASL tokens and password attribute values, including those of search result
+// entries, masked, suitable for debug output.
+// The structure of the message is left intact.
+func redactPacket(packet *ber.Packet) *ber.Packet {
+	if packet == nil {
+		return nil
+	}
+	packet = clonePacket(packet)
+	if len(packet.Children) < 2 {
+		return packet
+	}
+
+	op := packet.Children[1]
+	if op.ClassType != ber.ClassApplication {
+		return packet
+	}
+	switch op.Tag {
+	case ApplicationBindRequest:
+		if len(op.Children) < 3 {
+			break
+		}
+		auth := op.Children[2]
+		if auth.TagType == ber.TypeConstructed {
+			// SASL: mechanism followed by the optional credentials
+			if len(auth.Children) > 1 {
+				for _, cred := range auth.Children[1:] {
+					maskPacket(cred)
+				}
+			}
+		} else {
+			maskPacket(auth)
+		}
+	case ApplicationBindResponse:
+		if len(op.Children) <= 3 {
+			break
+		}
+		for _, child := range op.Children[3:] {
+			// serverSaslCreds [7]
+			if child.ClassType == ber.ClassContext && child.Tag == ber.TagObjectDescriptor {
+				maskPacket(child)
+			}
+		}
+	case ApplicationAddRequest:
+		if len(op.Children) >= 2 {
+			for _, attribute := range op.Children[1].Children {
+				maskAttributeValues(attribute)
+			}
+		}
+	case ApplicationSearchResultEntry:
+		if len(op.Children) >= 2 {
+			for _, attribute := range op.Children[1].Children {
+				maskAttributeValues(attribute)
+			}
+		}
+	case ApplicationModifyRequest:
+		if len(op.Children) >= 2 {
+			for _, change := range op.Children[1].Children {
+				if len(change.Children) >= 2 {
+					maskAttributeValues(change.Children[1])
+				}
+			}
+		}
+	case ApplicationExtendedRequest:
+		if len(op.Children) < 2 {
+			break
+		}
+		name, _ := op.Children[0].Value.(string)
+		value := op.Children[1]
+		if name == nmasSetPasswordOID {
+			maskPacket(value)
+			break
+		}
+		if name != passwordModifyOID {
+			break
+		}
+		if len(value.Children) == 0 {
+			maskPacket(value)
+			break
+		}
+		for _, field := range value.Children[0].Children {
+			// oldPasswd [1] and newPasswd [2]
+			if field.Tag == 1 || field.Tag == 2 {
+				maskPacket(field)
+			}
+		}
+	}
+	return packet
+}
+
+// maskAttributeValues masks the values of an attribute sequence if the
+// attribute type is listed in DebugRedactedAttributes
+func maskAttributeValues(attribute *ber.Packet) {
+	if len(attribute.Children) < 2 {
+		return
+	}
+	name, _ := attribute.Children[0].Value.(string)
+	// strip attribute options like ";binary"
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	if !DebugRedactedAttributes[strings.ToLower(name)] {
+		return
+	}
+	for _, value := range attribute.Children[1].Children {
+		maskPacket(value)
+	}
+}
+
+// maskPacket replaces the contents of the given packet
+func maskPacket(packet *ber.Packet) {
+	packet.Value = redactedPacketValue
+	packet.ByteValue = nil
+	packet.Children = nil
+	packet.Data = bytes.NewBufferString(redactedPacketValue)
+}
+
+// clonePacket returns a deep copy of the given packet
+func clonePacket(packet *ber.Packet) *ber.Packet {
+	clone := &ber.Packet{
+		Identifier:  packet.Identifier,
+		Value:       packet.Value,
+		ByteValue:   packet.ByteValue,
+		Description: packet.Description,
+		Data:        new(bytes.Buffer),
+	}
+	if packet.Data != nil {
+		clone.Data.Write(packet.Data.Bytes())
+	}
+	for _, child := range packet.Children {
+		clone.Children = append(clone.Children, clonePacket(child))
+	}
+	return clone
+}
+
+// RedactMessage returns the BER encoding of the LDAP message in data with
+// bind credentials, SASL tokens and password attribute values masked like in
+// debug output, e.g. to store captured packets
+func RedactMessage(data []byte) ([]byte, error) {
+	packet, err := ber.DecodePacketErr(data)
+	if err != nil {
+		return nil, err
+	}
+	return encodePacket(redactPacket(packet)), nil
+}
+
+// encodePacket returns the BER encoding of packet. Unlike packet.Bytes(), the
+// encoding of constructed packets is rebuilt from their children, so that
+// masked children are included.
+func encodePacket(packet *ber.Packet) []byte {
+	if packet.TagType != ber.TypeConstructed || len(packet.Children) == 0 {
+		return packet.Bytes()
+	}
+	rebuilt := ber.Encode(packet.ClassType, packet.TagType, packet.Tag, nil, packet.Description)
+	for _, child := range packet.Children {
+		rebuilt.Data.Write(encodePacket(child))
+	}
+	return rebuilt.Bytes()
+}
+
+// credentialRequest is implemented by requests carrying credentials. Their
+// encoding is zeroed once it is sent, see scrubPacket.
+type credentialRequest interface {
+	credentials()
+}
+
+// newSecretPacket returns a primitive element holding secret. Unlike
+// ber.NewString, it leaves no string copy of the secret, which could not be
+// zeroed, and its Value is nil.
+func newSecretPacket(classType ber.Class, tag ber.Tag, secret []byte, description string) *ber.Packet {
+	packet := ber.Encode(classType, ber.TypePrimitive, tag, nil, description)
+	packet.Data.Write(secret)
+	return packet
+}
+
+// appendSecretChild appends child to parent like parent.AppendChild, but
+// writes the encoding of child directly, as the temporary copy made by
+// child.Bytes() would be left behind unzeroed
+func appendSecretChild(parent, child *ber.Packet) {
+	appendHeader(parent.Data, child.Identifier, child.Data.Len())
+	parent.Data.Write(child.Data.Bytes())
+	parent.Children = append(parent.Children, child)
+}
+
+// scrubPacket zeroes the encoded data held by packet and its children
+func scrubPacket(packet *ber.Packet) {
+	if packet.Data != nil {
+		data := packet.Data.Bytes()
+		zeroBytes(data[:cap(data)])
+	}
+	for _, child := range packet.Children {
+		scrubPacket(child)
+	}
+}
+
+// zeroBytes overwrites b with zeros
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
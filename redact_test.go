@@ -0,0 +1,225 @@
+package ldap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestRedactPacket(t *testing.T) {
+	envelope := func(req request) *ber.Packet {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+		if err := req.appendTo(packet); err != nil {
+			t.Fatal(err)
+		}
+		return packet
+	}
+
+	addRequest := NewAddRequest("cn=test,dc=example,dc=com", nil)
+	addRequest.Attribute("cn", []string{"test"})
+	addRequest.Attribute("userPassword", []string{"s3cret"})
+
+	searchEntry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	searchEntry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn=test,dc=example,dc=com", "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	attributes.AppendChild((&Attribute{Type: "cn", Vals: []string{"test"}}).encode())
+	attributes.AppendChild((&Attribute{Type: "userPassword", Vals: []string{"{SSHA}s3cret"}}).encode())
+	entry.AppendChild(attributes)
+	searchEntry.AppendChild(entry)
+
+	tests := []struct {
+		name    string
+		packet  *ber.Packet
+		visible []string
+	}{
+		{
+			name:    "simple bind",
+			packet:  envelope(NewSimpleBindRequest("cn=admin", "s3cret", nil)),
+			visible: []string{"cn=admin"},
+		},
+		{
+			name:    "add",
+			packet:  envelope(addRequest),
+			visible: []string{"cn=test,dc=example,dc=com", "userPassword"},
+		},
+		{
+			name:    "search result entry",
+			packet:  searchEntry,
+			visible: []string{"cn=test,dc=example,dc=com", "userPassword", "test"},
+		},
+		{
+			name:    "password modify",
+			packet:  envelope(NewPasswordModifyRequest("uid=user", "s3cret", "s3cret2")),
+			visible: []string{"uid=user"},
+		},
+	}
+
+	for _, tc := range tests {
+		before := tc.packet.Bytes()
+		var buf bytes.Buffer
+		ber.WritePacket(&buf, redactPacket(tc.packet))
+		out := buf.String()
+
+		if strings.Contains(out, "s3cret") {
+			t.Errorf("%s: credentials not redacted:\n%s", tc.name, out)
+		}
+		if !strings.Contains(out, redactedPacketValue) {
+			t.Errorf("%s: expected %q in output:\n%s", tc.name, redactedPacketValue, out)
+		}
+		for _, v := range tc.visible {
+			if !strings.Contains(out, v) {
+				t.Errorf("%s: expected %q to remain visible:\n%s", tc.name, v, out)
+			}
+		}
+		if !bytes.Equal(before, tc.packet.Bytes()) {
+			t.Errorf("%s: original packet was modified", tc.name)
+		}
+	}
+}
+
+func TestRedactShortPackets(t *testing.T) {
+	// a bind response without serverSaslCreds
+	response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	bindResponse := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+	bindResponse.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+	bindResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	bindResponse.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	response.AppendChild(bindResponse)
+
+	// a SASL bind request without credentials
+	request := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(2), "MessageID"))
+	bindRequest := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindRequest, nil, "Bind Request")
+	bindRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(3), "Version"))
+	bindRequest.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
+	auth := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "authentication")
+	auth.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "EXTERNAL", "SASL Mech"))
+	bindRequest.AppendChild(auth)
+	request.AppendChild(bindRequest)
+
+	for _, packet := range []*ber.Packet{response, request} {
+		if redacted := redactPacket(packet); !bytes.Equal(redacted.Bytes(), packet.Bytes()) {
+			t.Errorf("unexpected change of packet without secrets:\n%s", redacted.Bytes())
+		}
+	}
+}
+
+func TestRedactMessage(t *testing.T) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+	modifyRequest := NewModifyRequest("cn=test,dc=example,dc=com", nil)
+	modifyRequest.Replace("userPassword", []string{"s3cret"})
+	modifyRequest.Replace("description", []string{"visible"})
+	if err := modifyRequest.appendTo(packet); err != nil {
+		t.Fatal(err)
+	}
+
+	redacted, err := RedactMessage(packet.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(redacted, []byte("s3cret")) {
+		t.Errorf("credentials not redacted: %q", redacted)
+	}
+	// the result is a valid message with the same structure
+	decoded, err := ber.DecodePacketErr(redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := decoded.Children[1].Children[1].Children
+	if len(changes) != 2 || changes[0].Children[1].Children[1].Children[0].Data.String() != redactedPacketValue ||
+		changes[1].Children[1].Children[1].Children[0].Data.String() != "visible" {
+		t.Errorf("unexpected redacted message %q", redacted)
+	}
+
+	if _, err := RedactMessage([]byte{0x30, 0x05}); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+// containsData reports whether the data of packet or one of its children
+// contains b
+func containsData(packet *ber.Packet, b []byte) bool {
+	if bytes.Contains(packet.Data.Bytes(), b) {
+		return true
+	}
+	for _, child := range packet.Children {
+		if containsData(child, b) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSecretPasswords(t *testing.T) {
+	encode := func(req request) []byte {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, int64(1), "MessageID"))
+		if err := req.appendTo(packet); err != nil {
+			t.Fatal(err)
+		}
+		return packet.Bytes()
+	}
+
+	controls := []Control{NewControlBeheraPasswordPolicy()}
+	for name, reqs := range map[string][2]request{
+		"simple bind": {
+			NewSimpleBindRequest("cn=admin", "s3cret", nil),
+			&SimpleBindRequest{Username: "cn=admin", PasswordBytes: []byte("s3cret")},
+		},
+		"simple bind with controls": {
+			NewSimpleBindRequest("cn=admin", "s3cret", controls),
+			&SimpleBindRequest{Username: "cn=admin", PasswordBytes: []byte("s3cret"), Controls: controls},
+		},
+		"password modify": {
+			NewPasswordModifyRequest("uid=user", "s3cret", "s3cret2"),
+			&PasswordModifyRequest{UserIdentity: "uid=user", OldPasswordBytes: []byte("s3cret"), NewPasswordBytes: []byte("s3cret2")},
+		},
+	} {
+		if !bytes.Equal(encode(reqs[0]), encode(reqs[1])) {
+			t.Errorf("%s: byte passwords are encoded differently", name)
+		}
+	}
+}
+
+func TestCredentialsScrubbed(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var sent []*ber.Packet
+	conn.Use(func(next Handler) Handler {
+		return func(request *ber.Packet) (ResponseStream, error) {
+			sent = append(sent, request)
+			return next(request)
+		}
+	})
+
+	password := []byte("s3cret")
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	if _, err := conn.SimpleBind(&SimpleBindRequest{Username: "cn=admin", PasswordBytes: password}); err != nil {
+		t.Fatal(err)
+	}
+	go respondWithResult(ptc, ApplicationExtendedResponse, LDAPResultSuccess)
+	if _, err := conn.PasswordModify(&PasswordModifyRequest{OldPasswordBytes: password, NewPasswordBytes: []byte("n3w")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, packet := range sent {
+		if containsData(packet, password) {
+			t.Errorf("%s request was not scrubbed", packetOperation(packet))
+		}
+	}
+	// the password of the caller is left alone
+	if string(password) != "s3cret" {
+		t.Errorf("password was modified: %q", password)
+	}
+}
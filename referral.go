@@ -0,0 +1,255 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultReferralHops is the default limit of the length of referral chains
+const defaultReferralHops = 10
+
+// ReferralConfig defines how SearchWithReferrals follows search result
+// references
+type ReferralConfig struct {
+	// Dial connects to the server of a referral, given as ldap[s]://host:port.
+	// Defaults to DialURL.
+	Dial func(addr string) (*Conn, error)
+	// Bind authenticates a new connection before it is searched, e.g. with
+	// the credentials used for the original connection. If nil, referrals
+	// are searched anonymously, unless Credentials is set.
+	Bind func(conn *Conn, addr string) error
+	// Credentials authenticates new connections if Bind is nil, asking the
+	// provider for the current credentials on every bind
+	Credentials CredentialProvider
+	// MaxHops limits the length of referral chains. Defaults to 10.
+	MaxHops int
+	// ContinueOnError keeps following the other references if one of them
+	// fails, keeping the failed ones in SearchResult.Referrals
+	ContinueOnError bool
+	// GlobalCatalog is the address of an Active Directory global catalog
+	// server of the forest, given as ldap[s]://host[:port] with the port
+	// defaulting to 3268 or 3269. If set, referred searches are performed in
+	// the global catalog, which holds all domains of the forest, instead of
+	// dialing a domain controller of every referred domain. References to
+	// naming contexts the global catalog does not hold, e.g. application
+	// partitions, are followed as usual. Note that the global catalog only
+	// returns the attributes of the partial attribute set.
+	GlobalCatalog string
+}
+
+// SearchWithReferrals performs the given search request and follows the
+// search result references (continuation references) returned by the
+// server, merging the entries of the referred servers into the result.
+// Connections are opened once per server and closed before returning.
+// References pointing to a search which was already performed are skipped to
+// prevent loops. SearchResult.Referrals holds the references which were not
+// followed.
+func (l *Conn) SearchWithReferrals(searchRequest *SearchRequest, config *ReferralConfig) (*SearchResult, error) {
+	if config == nil {
+		config = &ReferralConfig{}
+	}
+	f := &referralFollower{
+		config:  config,
+		conns:   make(map[string]*Conn),
+		visited: map[string]bool{referralKey("", searchRequest): true},
+	}
+	if config.GlobalCatalog != "" {
+		addr, err := globalCatalogAddr(config.GlobalCatalog)
+		if err != nil {
+			return nil, err
+		}
+		f.globalCatalog = addr
+	}
+	defer f.close()
+
+	result, err := l.Search(searchRequest)
+	if err != nil {
+		return result, err
+	}
+	return result, f.follow(result, searchRequest, 1)
+}
+
+type referralFollower struct {
+	config  *ReferralConfig
+	conns   map[string]*Conn
+	visited map[string]bool
+	// globalCatalog is the address of the global catalog, if configured
+	globalCatalog string
+}
+
+// follow replaces the referrals of result with the entries they refer to
+func (f *referralFollower) follow(result *SearchResult, searchRequest *SearchRequest, hop int) error {
+	maxHops := f.config.MaxHops
+	if maxHops == 0 {
+		maxHops = defaultReferralHops
+	}
+
+	referrals := result.Referrals
+	result.Referrals = make([]string, 0)
+	for _, referral := range referrals {
+		if hop > maxHops {
+			result.Referrals = append(result.Referrals, referral)
+			continue
+		}
+		addr, req, err := parseSearchReference(referral, searchRequest)
+		if err == nil {
+			key := referralKey(addr, req)
+			if f.visited[key] {
+				continue
+			}
+			f.visited[key] = true
+			err = f.search(result, addr, req, hop)
+		}
+		if err != nil {
+			if !f.config.ContinueOnError {
+				return fmt.Errorf("ldap: following referral %s: %w", referral, err)
+			}
+			result.Referrals = append(result.Referrals, referral)
+		}
+	}
+	return nil
+}
+
+// search performs the referred search, in the global catalog if configured,
+// and appends its entries to result
+func (f *referralFollower) search(result *SearchResult, addr string, searchRequest *SearchRequest, hop int) error {
+	if f.globalCatalog != "" && !strings.EqualFold(addr, f.globalCatalog) {
+		err := f.searchAt(result, f.globalCatalog, searchRequest, hop)
+		if !IsErrorWithCode(err, LDAPResultNoSuchObject) {
+			return err
+		}
+	}
+	return f.searchAt(result, addr, searchRequest, hop)
+}
+
+// searchAt performs the search on the server at addr and appends its entries
+// to result
+func (f *referralFollower) searchAt(result *SearchResult, addr string, searchRequest *SearchRequest, hop int) error {
+	conn, err := f.conn(addr)
+	if err != nil {
+		return err
+	}
+	referred, err := conn.Search(searchRequest)
+	if err != nil {
+		return err
+	}
+	if err := f.follow(referred, searchRequest, hop+1); err != nil {
+		return err
+	}
+	result.Entries = append(result.Entries, referred.Entries...)
+	result.Referrals = append(result.Referrals, referred.Referrals...)
+	return nil
+}
+
+// conn returns the connection to addr, dialing and binding it if needed
+func (f *referralFollower) conn(addr string) (*Conn, error) {
+	if conn, ok := f.conns[addr]; ok {
+		return conn, nil
+	}
+	dial := f.config.Dial
+	if dial == nil {
+		dial = func(addr string) (*Conn, error) {
+			return DialURL(addr)
+		}
+	}
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	if f.config.Bind != nil {
+		err = f.config.Bind(conn, addr)
+	} else if f.config.Credentials != nil {
+		err = conn.BindWithCredentials(context.Background(), f.config.Credentials)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	f.conns[addr] = conn
+	return conn, nil
+}
+
+func (f *referralFollower) close() {
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+}
+
+// globalCatalogAddr returns the address of the global catalog given as
+// ldap[s]://host[:port], adding the default port of the scheme
+func globalCatalogAddr(addr string) (string, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	port := globalCatalogPort
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		port = globalCatalogTLSPort
+	default:
+		return "", fmt.Errorf("ldap: unsupported global catalog scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("ldap: global catalog without host")
+	}
+	if u.Port() != "" {
+		return u.Scheme + "://" + u.Host, nil
+	}
+	return u.Scheme + "://" + net.JoinHostPort(u.Hostname(), strconv.Itoa(port)), nil
+}
+
+// referralKey identifies a search for loop detection
+func referralKey(addr string, searchRequest *SearchRequest) string {
+	return fmt.Sprintf("%s|%s|%d|%s", strings.ToLower(addr), strings.ToLower(searchRequest.BaseDN), searchRequest.Scope, searchRequest.Filter)
+}
+
+// parseSearchReference parses an LDAP URL (RFC 4516) of a continuation
+// reference and returns the server address and the search request to send to
+// it. The base DN, scope and filter of the URL take precedence over those of
+// the original request.
+func parseSearchReference(referral string, searchRequest *SearchRequest) (string, *SearchRequest, error) {
+	u, err := url.Parse(referral)
+	if err != nil {
+		return "", nil, err
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return "", nil, fmt.Errorf("unsupported referral scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", nil, errors.New("referral without host")
+	}
+
+	req := cloneSearchRequest(searchRequest)
+	if dn := strings.TrimPrefix(u.Path, "/"); dn != "" {
+		req.BaseDN = dn
+	}
+
+	// the query holds attributes?scope?filter?extensions
+	parts := strings.Split(u.RawQuery, "?")
+	if len(parts) > 1 && parts[1] != "" {
+		switch strings.ToLower(parts[1]) {
+		case "base":
+			req.Scope = ScopeBaseObject
+		case "one":
+			req.Scope = ScopeSingleLevel
+		case "sub":
+			req.Scope = ScopeWholeSubtree
+		default:
+			return "", nil, fmt.Errorf("invalid referral scope %q", parts[1])
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		filter, err := url.PathUnescape(parts[2])
+		if err != nil {
+			return "", nil, err
+		}
+		req.Filter = filter
+	}
+	return u.Scheme + "://" + u.Host, req, nil
+}
@@ -0,0 +1,195 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// respondWithReferences answers a search request with an entry and search
+// result references to the given URLs
+func respondWithReferences(ptc *packetTranslatorConn, dn string, referrals ...string) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return
+	}
+	messageID := req.Children[0].Value.(int64)
+
+	entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	searchEntry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	searchEntry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	searchEntry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+	entry.AppendChild(searchEntry)
+	_ = ptc.SendResponse(entry)
+
+	for _, referral := range referrals {
+		ref := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		ref.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		searchRef := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultReference, nil, "Search Result Reference")
+		searchRef.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, referral, "URI"))
+		ref.AppendChild(searchRef)
+		_ = ptc.SendResponse(ref)
+	}
+
+	done := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	searchDone := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	searchDone.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+	searchDone.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	searchDone.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	done.AppendChild(searchDone)
+	_ = ptc.SendResponse(done)
+}
+
+func TestParseSearchReference(t *testing.T) {
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(cn=a)", []string{"cn"}, nil)
+
+	addr, req, err := parseSearchReference("ldaps://dc2.example.com:636/ou=b,dc=example,dc=com??one?(cn=b%20c)", searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "ldaps://dc2.example.com:636" {
+		t.Errorf("unexpected address %q", addr)
+	}
+	expected := NewSearchRequest("ou=b,dc=example,dc=com", ScopeSingleLevel, NeverDerefAliases, 0, 0, false, "(cn=b c)", []string{"cn"}, nil)
+	if !reflect.DeepEqual(req, expected) {
+		t.Errorf("got %+v, want %+v", req, expected)
+	}
+
+	_, req, err = parseSearchReference("ldap://dc2.example.com", searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.BaseDN != searchRequest.BaseDN || req.Scope != searchRequest.Scope || req.Filter != searchRequest.Filter {
+		t.Errorf("expected original search, got %+v", req)
+	}
+
+	for _, referral := range []string{"http://example.com/", "ldap:///dc=example,dc=com", "ldap://h/dc=x??children"} {
+		if _, _, err := parseSearchReference(referral, searchRequest); err == nil {
+			t.Errorf("expected error for %q", referral)
+		}
+	}
+}
+
+func TestSearchWithReferrals(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	remote := newPacketTranslatorConn()
+	defer remote.Close()
+
+	const referral = "ldap://dc2.example.com/ou=b,dc=example,dc=com"
+	go respondWithReferences(ptc, "cn=a,dc=example,dc=com", referral, referral, "ldap://unreachable.example.com/ou=c,dc=example,dc=com")
+	// the remote server refers back to itself
+	go respondWithReferences(remote, "cn=b,ou=b,dc=example,dc=com", referral+"??sub")
+
+	var dialed []string
+	config := &ReferralConfig{
+		Dial: func(addr string) (*Conn, error) {
+			dialed = append(dialed, addr)
+			if addr != "ldap://dc2.example.com" {
+				return nil, NewError(ErrorNetwork, errCouldNotRetMsg)
+			}
+			remoteConn := NewConn(remote, false)
+			remoteConn.Start()
+			return remoteConn, nil
+		},
+		ContinueOnError: true,
+	}
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	result, err := conn.SearchWithReferrals(searchRequest, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	if !reflect.DeepEqual(dns, []string{"cn=a,dc=example,dc=com", "cn=b,ou=b,dc=example,dc=com"}) {
+		t.Errorf("unexpected entries %v", dns)
+	}
+	if !reflect.DeepEqual(result.Referrals, []string{"ldap://unreachable.example.com/ou=c,dc=example,dc=com"}) {
+		t.Errorf("unexpected referrals %v", result.Referrals)
+	}
+	if !reflect.DeepEqual(dialed, []string{"ldap://dc2.example.com", "ldap://unreachable.example.com"}) {
+		t.Errorf("unexpected dials %v", dialed)
+	}
+}
+
+func TestSearchWithReferralsGlobalCatalog(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	gc := newPacketTranslatorConn()
+	defer gc.Close()
+	dc := newPacketTranslatorConn()
+	defer dc.Close()
+
+	go respondWithReferences(ptc, "cn=a,dc=example,dc=com",
+		"ldap://child.example.com/dc=child,dc=example,dc=com",
+		"ldap://example.com/dc=DomainDnsZones,dc=example,dc=com")
+	go func() {
+		respondWithReferences(gc, "cn=b,dc=child,dc=example,dc=com")
+		// application partitions are not held by the global catalog
+		respondToSearchWithCode(gc, LDAPResultNoSuchObject)
+	}()
+	go respondWithReferences(dc, "dc=example.com,dc=DomainDnsZones,dc=example,dc=com")
+
+	var dialed []string
+	config := &ReferralConfig{
+		Dial: func(addr string) (*Conn, error) {
+			dialed = append(dialed, addr)
+			c := dc
+			if addr == "ldap://gc.example.com:3268" {
+				c = gc
+			}
+			referred := NewConn(c, false)
+			referred.Start()
+			return referred, nil
+		},
+		GlobalCatalog: "ldap://gc.example.com",
+	}
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	result, err := conn.SearchWithReferrals(searchRequest, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dns []string
+	for _, entry := range result.Entries {
+		dns = append(dns, entry.DN)
+	}
+	expected := []string{"cn=a,dc=example,dc=com", "cn=b,dc=child,dc=example,dc=com", "dc=example.com,dc=DomainDnsZones,dc=example,dc=com"}
+	if !reflect.DeepEqual(dns, expected) {
+		t.Errorf("unexpected entries %v", dns)
+	}
+	if !reflect.DeepEqual(dialed, []string{"ldap://gc.example.com:3268", "ldap://example.com"}) {
+		t.Errorf("unexpected dials %v", dialed)
+	}
+}
+
+func TestGlobalCatalogAddr(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"ldap://gc.example.com":       "ldap://gc.example.com:3268",
+		"ldaps://gc.example.com":      "ldaps://gc.example.com:3269",
+		"ldap://gc.example.com:13268": "ldap://gc.example.com:13268",
+		"ldap://[::1]":                "ldap://[::1]:3268",
+		"gc.example.com:3268":         "",
+	} {
+		actual, err := globalCatalogAddr(addr)
+		if (err != nil) != (expected == "") || actual != expected {
+			t.Errorf("%s: expected %q, got %q, %v", addr, expected, actual, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -10,6 +11,7 @@ import (
 var (
 	errRespChanClosed = errors.New("ldap: response channel closed")
 	errCouldNotRetMsg = errors.New("ldap: could not retrieve message")
+	errMissingOp      = errors.New("ldap: response without protocol operation")
 	ErrNilConnection  = errors.New("ldap: conn is nil, expected net.Conn")
 )
 
@@ -24,37 +26,59 @@ func (f requestFunc) appendTo(p *ber.Packet) error {
 }
 
 func (l *Conn) doRequest(req request) (*messageContext, error) {
+	return l.doRequestContext(context.Background(), req)
+}
+
+// doRequestContext sends the request, tagging it with the correlation ID
+// carried by ctx and registering its value stream, see StreamAttributeValue
+func (l *Conn) doRequestContext(ctx context.Context, req request) (*messageContext, error) {
 	if l == nil || l.conn == nil {
 		return nil, ErrNilConnection
 	}
 
+	messageID := l.nextMessageID()
 	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
-	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, l.nextMessageID(), "MessageID"))
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
 	if err := req.appendTo(packet); err != nil {
 		return nil, err
 	}
+	if err := l.checkCriticalControls(packet); err != nil {
+		return nil, err
+	}
+	// the stream has to be known before the response can arrive
+	l.addValueStream(ctx, messageID)
 
 	if l.Debug {
 		l.Debug.PrintPacket(packet)
 	}
 
-	msgCtx, err := l.sendMessage(packet)
+	correlationID := CorrelationID(ctx)
+	trace := l.startTrace(req, correlationID)
+	var flags sendMessageFlags
+	if _, ok := req.(credentialRequest); ok {
+		flags |= scrubCredentials
+	}
+	msgCtx, err := l.sendRequest(packet, flags, correlationID)
 	if err != nil {
+		if trace != nil {
+			trace.recordError(err)
+			trace.end()
+		}
 		return nil, err
 	}
+	msgCtx.trace = trace
 	l.Debug.Printf("%d: returning", msgCtx.id)
 	return msgCtx, nil
 }
 
 func (l *Conn) readPacket(msgCtx *messageContext) (*ber.Packet, error) {
 	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return nil, NewError(ErrorNetwork, errRespChanClosed)
-	}
-	packet, err := packetResponse.ReadPacket()
+	packet, err := l.receivePacket(msgCtx)
 	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
 	if err != nil {
+		if msgCtx.trace != nil {
+			msgCtx.trace.recordError(err)
+		}
 		return nil, err
 	}
 
@@ -62,6 +86,15 @@ func (l *Conn) readPacket(msgCtx *messageContext) (*ber.Packet, error) {
 		return nil, NewError(ErrorNetwork, errCouldNotRetMsg)
 	}
 
+	if msgCtx.trace != nil {
+		msgCtx.trace.observe(packet)
+	}
+
+	// the operations access the protocol operation of the response directly
+	if len(packet.Children) < 2 {
+		return nil, NewError(ErrorUnexpectedResponse, errMissingOp)
+	}
+
 	if l.Debug {
 		if err = addLDAPDescriptions(packet); err != nil {
 			return nil, err
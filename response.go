@@ -0,0 +1,171 @@
+package ldap
+
+import (
+	"context"
+	"sync"
+)
+
+// Response yields the results of an asynchronous search one by one, see
+// Conn.SearchAsync
+type Response interface {
+	// Next waits for the next result and returns true if there is one. It
+	// returns false once the search is done, failed or was canceled.
+	Next() bool
+	// Entry returns the entry of the current result, or nil for a referral
+	Entry() *Entry
+	// Referral returns the referral URI of the current result, or ""
+	Referral() string
+	// Controls returns the controls of the final response, once Next
+	// returned false
+	Controls() []Control
+	// Err returns the error which ended the search, if any
+	Err() error
+}
+
+type asyncSearchResult struct {
+	entry    *Entry
+	referral string
+	controls []Control
+	err      error
+	done     bool
+}
+
+type searchResponse struct {
+	ctx      context.Context
+	ch       chan *asyncSearchResult
+	entry    *Entry
+	referral string
+	controls []Control
+	err      error
+}
+
+// SearchAsync performs the given search request in the background, making
+// the results available through the returned Response as they arrive. Up to
+// bufferSize results are buffered if they are not consumed fast enough.
+//
+// Canceling ctx stops the search: the operation is abandoned on the server,
+// Next returns false and Err returns the error of ctx.
+func (l *Conn) SearchAsync(ctx context.Context, searchRequest *SearchRequest, bufferSize int) Response {
+	r := &searchResponse{
+		ctx: ctx,
+		ch:  make(chan *asyncSearchResult, bufferSize),
+	}
+	go r.run(l, searchRequest)
+	return r
+}
+
+func (r *searchResponse) Next() bool {
+	result, ok := <-r.ch
+	if !ok {
+		// the final result is not delivered once ctx is done
+		if r.err == nil {
+			r.err = r.ctx.Err()
+		}
+		return false
+	}
+	r.entry, r.referral = result.entry, result.referral
+	if result.done {
+		r.controls, r.err = result.controls, result.err
+		return false
+	}
+	return true
+}
+
+func (r *searchResponse) Entry() *Entry {
+	return r.entry
+}
+
+func (r *searchResponse) Referral() string {
+	return r.referral
+}
+
+func (r *searchResponse) Controls() []Control {
+	return r.controls
+}
+
+func (r *searchResponse) Err() error {
+	return r.err
+}
+
+// send passes the result to the consumer unless ctx is done
+func (r *searchResponse) send(result *asyncSearchResult) bool {
+	select {
+	case r.ch <- result:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+func (r *searchResponse) run(l *Conn, searchRequest *SearchRequest) {
+	defer close(r.ch)
+
+	msgCtx, err := l.doRequestContext(r.ctx, searchRequest)
+	if err != nil {
+		r.send(&asyncSearchResult{err: err, done: true})
+		return
+	}
+
+	// the message is finished early if ctx is done, which also unblocks
+	// the pending read below
+	var finishOnce sync.Once
+	finish := func() {
+		finishOnce.Do(func() {
+			l.finishMessage(msgCtx)
+		})
+	}
+	defer finish()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-r.ctx.Done():
+			finish()
+			if err := l.abandon(msgCtx.id); err != nil {
+				l.Debug.Printf("%d: failed to abandon search: %s", msgCtx.id, err)
+			}
+		case <-stop:
+		}
+	}()
+
+	for {
+		packet, err := l.readPacket(msgCtx)
+		if err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				// reading failed because the message was finished early
+				err = ctxErr
+			}
+			r.send(&asyncSearchResult{err: err, done: true})
+			return
+		}
+
+		switch packet.Children[1].Tag {
+		case ApplicationSearchResultEntry:
+			entry, err := decodeEntry(packet, searchRequest.valueDecoding())
+			if err != nil {
+				r.send(&asyncSearchResult{err: err, done: true})
+				return
+			}
+			if !r.send(&asyncSearchResult{entry: entry}) {
+				return
+			}
+		case ApplicationSearchResultReference:
+			for _, child := range packet.Children[1].Children {
+				uri, ok := child.Value.(string)
+				if !ok {
+					continue
+				}
+				if !r.send(&asyncSearchResult{referral: uri}) {
+					return
+				}
+			}
+		case ApplicationSearchResultDone:
+			controls, err := decodeResponseControls(packet)
+			if err == nil {
+				err = GetLDAPError(packet)
+			}
+			r.send(&asyncSearchResult{controls: controls, err: err, done: true})
+			return
+		}
+	}
+}
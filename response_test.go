@@ -0,0 +1,80 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestSearchAsync(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com")
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	r := conn.SearchAsync(context.Background(), searchRequest, 1)
+	var dns []string
+	for r.Next() {
+		dns = append(dns, r.Entry().DN)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("search failed: %s", err)
+	}
+	if len(dns) != 2 || dns[0] != "cn=a,dc=example,dc=com" || dns[1] != "cn=b,dc=example,dc=com" {
+		t.Errorf("unexpected entries %v", dns)
+	}
+}
+
+func TestSearchAsyncCancel(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	// send a single entry and never finish the search
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+		searchEntry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+		searchEntry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn=a,dc=example,dc=com", "Object Name"))
+		searchEntry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+		entry.AppendChild(searchEntry)
+		_ = ptc.SendResponse(entry)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	r := conn.SearchAsync(ctx, searchRequest, 0)
+	if !r.Next() {
+		t.Fatalf("expected an entry, got error %v", r.Err())
+	}
+	cancel()
+	if r.Next() {
+		t.Fatal("expected search to stop after cancel")
+	}
+	if !errors.Is(r.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", r.Err())
+	}
+
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Children[1].Tag != ApplicationAbandonRequest {
+		t.Errorf("expected abandon request, got %s", ApplicationMap[uint8(req.Children[1].Tag)])
+	}
+}
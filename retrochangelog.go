@@ -0,0 +1,162 @@
+package ldap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetroChangelogBaseDN is the suffix of the retro changelog of 389 Directory
+// Server and other servers implementing draft-good-ldap-changelog
+const RetroChangelogBaseDN = "cn=changelog"
+
+// RetroChangelogRecord is a change recorded in the retro changelog
+type RetroChangelogRecord struct {
+	ChangeNumber int64
+	// Time is the changeTime of the change, zero if it is not recorded
+	Time time.Time
+	// Type is the changeType of the change: "add", "modify", "delete" or
+	// "modrdn"
+	Type string
+	DN   string
+	// Request is the change as *AddRequest, *ModifyRequest, *DelRequest or
+	// *ModifyDNRequest
+	Request interface{}
+}
+
+// retroChangelogOperations maps the LDIF change operations to modify
+// operations
+var retroChangelogOperations = map[string]uint{
+	"add":       AddAttribute,
+	"delete":    DeleteAttribute,
+	"replace":   ReplaceAttribute,
+	"increment": IncrementAttribute,
+}
+
+// ParseRetroChangelogEntry converts a changelogEntry of the retro changelog
+// into a RetroChangelogRecord, parsing the LDIF of its changes attribute
+func ParseRetroChangelogEntry(entry *Entry) (*RetroChangelogRecord, error) {
+	r := &RetroChangelogRecord{
+		Type: entry.GetEqualFoldAttributeValue("changeType"),
+		DN:   entry.GetEqualFoldAttributeValue("targetDN"),
+	}
+	number := entry.GetEqualFoldAttributeValue("changeNumber")
+	var err error
+	if r.ChangeNumber, err = strconv.ParseInt(number, 10, 64); err != nil {
+		return nil, fmt.Errorf("ldap: invalid changeNumber '%s' in changelog entry %s", number, entry.DN)
+	}
+	if changeTime := entry.GetEqualFoldAttributeValue("changeTime"); changeTime != "" {
+		if r.Time, err = ParseGeneralizedTime(changeTime); err != nil {
+			return nil, err
+		}
+	}
+
+	// 389 Directory Server terminates the changes with a NUL byte
+	lines, err := parseLDIFLines(strings.TrimRight(entry.GetEqualFoldAttributeValue("changes"), "\x00"))
+	if err != nil {
+		return nil, fmt.Errorf("%s in changelog entry %s", err, entry.DN)
+	}
+	switch strings.ToLower(r.Type) {
+	case "add":
+		req := NewAddRequest(r.DN, nil)
+		index := make(map[string]int)
+		for _, line := range lines {
+			key := strings.ToLower(line.name)
+			if i, ok := index[key]; ok {
+				req.Attributes[i].Vals = append(req.Attributes[i].Vals, line.value)
+				continue
+			}
+			index[key] = len(req.Attributes)
+			req.Attribute(line.name, []string{line.value})
+		}
+		r.Request = req
+	case "modify":
+		req := NewModifyRequest(r.DN, nil)
+		var change *Change
+		for _, line := range lines {
+			if line.name == "-" {
+				change = nil
+				continue
+			}
+			if change == nil {
+				operation, ok := retroChangelogOperations[strings.ToLower(line.name)]
+				if !ok {
+					return nil, fmt.Errorf("ldap: invalid modify operation '%s' in changelog entry %s", line.name, entry.DN)
+				}
+				req.appendChange(operation, line.value, nil)
+				change = &req.Changes[len(req.Changes)-1]
+				continue
+			}
+			change.Modification.Vals = append(change.Modification.Vals, line.value)
+		}
+		r.Request = req
+	case "delete":
+		r.Request = NewDelRequest(r.DN, nil)
+	case "modrdn":
+		r.Request = NewModifyDNRequest(
+			r.DN,
+			entry.GetEqualFoldAttributeValue("newRDN"),
+			strings.EqualFold(entry.GetEqualFoldAttributeValue("deleteOldRDN"), "TRUE"),
+			entry.GetEqualFoldAttributeValue("newSuperior"),
+		)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported changeType '%s' in changelog entry %s", r.Type, entry.DN)
+	}
+	return r, nil
+}
+
+// retroChangelogPagingSize is the page size of the searches of
+// RetroChangelogReader
+const retroChangelogPagingSize = 500
+
+// RetroChangelogReader tails the retro changelog, returning the changes in
+// order of their changeNumber and remembering the last one processed
+type RetroChangelogReader struct {
+	client Client
+	last   int64
+}
+
+// NewRetroChangelogReader returns a reader for the retro changelog which
+// starts with the change following lastChangeNumber. Pass 0 to read all
+// changes still in the changelog.
+func NewRetroChangelogReader(client Client, lastChangeNumber int64) *RetroChangelogReader {
+	return &RetroChangelogReader{client: client, last: lastChangeNumber}
+}
+
+// LastChangeNumber returns the changeNumber of the last change read, which
+// can be persisted to resume reading with a new reader
+func (r *RetroChangelogReader) LastChangeNumber() int64 {
+	return r.last
+}
+
+// Next returns the changes recorded since the last call
+func (r *RetroChangelogReader) Next() ([]*RetroChangelogRecord, error) {
+	filter := fmt.Sprintf("(&(objectClass=changelogEntry)(changeNumber>=%d))", r.last+1)
+	result, err := r.client.SearchWithPaging(NewSearchRequest(
+		RetroChangelogBaseDN, ScopeSingleLevel, NeverDerefAliases, 0, 0, false,
+		filter, []string{"*"}, nil,
+	), retroChangelogPagingSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*RetroChangelogRecord
+	for _, entry := range result.Entries {
+		record, err := ParseRetroChangelogEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if record.ChangeNumber > r.last {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ChangeNumber < records[j].ChangeNumber
+	})
+	if len(records) > 0 {
+		r.last = records[len(records)-1].ChangeNumber
+	}
+	return records, nil
+}
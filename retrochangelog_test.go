@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRetroChangelogEntry(t *testing.T) {
+	record, err := ParseRetroChangelogEntry(NewEntry("changenumber=42,cn=changelog", map[string][]string{
+		"changeNumber": {"42"},
+		"changeTime":   {"20240102030405Z"},
+		"changeType":   {"modify"},
+		"targetDn":     {"uid=jdoe,dc=example,dc=com"},
+		"changes": {"replace: mail\nmail: jdoe@example.com\n-\nadd: description\ndescription:: w6TDtsO8\n" +
+			"description: a long\n  value\n-\ndelete: telephoneNumber\n-\n\x00"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.ChangeNumber != 42 || record.Time.IsZero() {
+		t.Errorf("unexpected record %+v", record)
+	}
+	expected := NewModifyRequest("uid=jdoe,dc=example,dc=com", nil)
+	expected.Replace("mail", []string{"jdoe@example.com"})
+	expected.Add("description", []string{"äöü", "a long value"})
+	expected.Delete("telephoneNumber", nil)
+	if !reflect.DeepEqual(record.Request, expected) {
+		t.Errorf("unexpected request %+v", record.Request)
+	}
+
+	record, err = ParseRetroChangelogEntry(NewEntry("changenumber=43,cn=changelog", map[string][]string{
+		"changeNumber": {"43"},
+		"changeType":   {"add"},
+		"targetDn":     {"uid=jdoe,dc=example,dc=com"},
+		"changes":      {"objectClass: top\nuid: jdoe\nobjectClass: person\n"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := NewAddRequest("uid=jdoe,dc=example,dc=com", nil)
+	add.Attribute("objectClass", []string{"top", "person"})
+	add.Attribute("uid", []string{"jdoe"})
+	if !reflect.DeepEqual(record.Request, add) {
+		t.Errorf("unexpected request %+v", record.Request)
+	}
+
+	record, err = ParseRetroChangelogEntry(NewEntry("changenumber=44,cn=changelog", map[string][]string{
+		"changeNumber": {"44"},
+		"changeType":   {"modrdn"},
+		"targetDn":     {"uid=jdoe,dc=example,dc=com"},
+		"newRDN":       {"uid=john"},
+		"deleteOldRDN": {"TRUE"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(record.Request, NewModifyDNRequest("uid=jdoe,dc=example,dc=com", "uid=john", true, "")) {
+		t.Errorf("unexpected request %+v", record.Request)
+	}
+}
+
+func TestRetroChangelogReader(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	filters := make(chan string, 1)
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		filter, _ := DecompileFilter(req.Children[1].Children[6])
+		filters <- filter
+		sendSearchResult(ptc, req.Children[0].Value.(int64), LDAPResultSuccess,
+			NewEntry("changenumber=12,cn=changelog", map[string][]string{
+				"changeNumber": {"12"}, "changeType": {"delete"}, "targetDn": {"uid=b,dc=example"},
+			}),
+			NewEntry("changenumber=11,cn=changelog", map[string][]string{
+				"changeNumber": {"11"}, "changeType": {"delete"}, "targetDn": {"uid=a,dc=example"},
+			}),
+		)
+	}()
+
+	reader := NewRetroChangelogReader(conn, 10)
+	records, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ChangeNumber != 11 || records[1].ChangeNumber != 12 {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if reader.LastChangeNumber() != 12 {
+		t.Errorf("unexpected last change number %d", reader.LastChangeNumber())
+	}
+	if filter := <-filters; filter != "(&(objectClass=changelogEntry)(changeNumber>=11))" {
+		t.Errorf("unexpected filter %s", filter)
+	}
+}
@@ -0,0 +1,157 @@
+package ldap
+
+import (
+	"strings"
+)
+
+// RootDSE holds the commonly used attributes of the root DSE of a server,
+// see https://datatracker.ietf.org/doc/html/rfc4512#section-5.1. Attributes
+// only returned by Active Directory are zero for other servers.
+type RootDSE struct {
+	NamingContexts          []string `ldap:"namingContexts"`
+	SubschemaSubentry       string   `ldap:"subschemaSubentry"`
+	SupportedLDAPVersion    []int    `ldap:"supportedLDAPVersion"`
+	SupportedControl        []string `ldap:"supportedControl"`
+	SupportedExtension      []string `ldap:"supportedExtension"`
+	SupportedFeatures       []string `ldap:"supportedFeatures"`
+	SupportedSASLMechanisms []string `ldap:"supportedSASLMechanisms"`
+	AltServer               []string `ldap:"altServer"`
+	VendorName              string   `ldap:"vendorName"`
+	VendorVersion           string   `ldap:"vendorVersion"`
+
+	// Active Directory
+	SupportedCapabilities         []string `ldap:"supportedCapabilities"`
+	DefaultNamingContext          string   `ldap:"defaultNamingContext"`
+	RootDomainNamingContext       string   `ldap:"rootDomainNamingContext"`
+	ConfigurationNamingContext    string   `ldap:"configurationNamingContext"`
+	SchemaNamingContext           string   `ldap:"schemaNamingContext"`
+	DNSHostName                   string   `ldap:"dnsHostName"`
+	ServerName                    string   `ldap:"serverName"`
+	DomainFunctionality           int      `ldap:"domainFunctionality"`
+	ForestFunctionality           int      `ldap:"forestFunctionality"`
+	DomainControllerFunctionality int      `ldap:"domainControllerFunctionality"`
+	IsGlobalCatalogReady          bool     `ldap:"isGlobalCatalogReady"`
+	IsSynchronized                bool     `ldap:"isSynchronized"`
+
+	entry *Entry
+}
+
+// rootDSEAttributes are requested explicitly, as most of them are
+// operational attributes which are not returned for "*"
+var rootDSEAttributes = []string{
+	"*", "+",
+	"namingContexts", "subschemaSubentry", "supportedLDAPVersion", "supportedControl",
+	"supportedExtension", "supportedFeatures", "supportedSASLMechanisms", "altServer",
+	"vendorName", "vendorVersion",
+}
+
+// Entry returns the root DSE entry as returned by the server, e.g. to access
+// attributes without a field in RootDSE
+func (r *RootDSE) Entry() *Entry {
+	return r.entry
+}
+
+// SupportsControl returns true if the server lists the control OID
+func (r *RootDSE) SupportsControl(oid string) bool {
+	return containsString(r.SupportedControl, oid)
+}
+
+// SupportsExtension returns true if the server lists the extended operation OID
+func (r *RootDSE) SupportsExtension(oid string) bool {
+	return containsString(r.SupportedExtension, oid)
+}
+
+// SupportsFeature returns true if the server lists the feature OID
+func (r *RootDSE) SupportsFeature(oid string) bool {
+	return containsString(r.SupportedFeatures, oid)
+}
+
+// capabilityActiveDirectory is the LDAP_CAP_ACTIVE_DIRECTORY_OID capability
+// listed by Active Directory domain controllers
+const capabilityActiveDirectory = "1.2.840.113556.1.4.800"
+
+// IsActiveDirectory returns true if the server is an Active Directory domain
+// controller
+func (r *RootDSE) IsActiveDirectory() bool {
+	return containsString(r.SupportedCapabilities, capabilityActiveDirectory)
+}
+
+// ServerVendor identifies the directory server software, see RootDSE.Vendor
+type ServerVendor int
+
+// Directory servers detected by RootDSE.Vendor
+const (
+	VendorUnknown ServerVendor = iota
+	VendorActiveDirectory
+	VendorOpenLDAP
+	// Vendor389DS is 389 Directory Server or Red Hat Directory Server
+	Vendor389DS
+)
+
+// Vendor detects the directory server software from the root DSE
+func (r *RootDSE) Vendor() ServerVendor {
+	switch {
+	case r.IsActiveDirectory():
+		return VendorActiveDirectory
+	case strings.Contains(r.VendorName, "389") || strings.Contains(r.VendorName, "Red Hat"):
+		return Vendor389DS
+	case r.entry != nil && containsFoldString(r.entry.GetEqualFoldAttributeValues("objectClass"), "OpenLDAProotDSE"):
+		return VendorOpenLDAP
+	}
+	return VendorUnknown
+}
+
+// SupportsSASLMechanism returns true if the server lists the SASL mechanism.
+// Mechanism names are compared case-insensitively.
+func (r *RootDSE) SupportsSASLMechanism(mechanism string) bool {
+	return containsFoldString(r.SupportedSASLMechanisms, mechanism)
+}
+
+// RootDSE returns the root DSE of the server. It is read once and cached
+// for the lifetime of the connection, use RefreshRootDSE to read it again,
+// e.g. after binding.
+func (l *Conn) RootDSE() (*RootDSE, error) {
+	l.messageMutex.Lock()
+	rootDSE := l.rootDSE
+	l.messageMutex.Unlock()
+	if rootDSE != nil {
+		return rootDSE, nil
+	}
+	return l.RefreshRootDSE()
+}
+
+// RefreshRootDSE reads the root DSE from the server and updates the cached
+// copy returned by RootDSE
+func (l *Conn) RefreshRootDSE() (*RootDSE, error) {
+	entry, err := l.GetEntry("", rootDSEAttributes...)
+	if err != nil {
+		return nil, err
+	}
+	rootDSE := &RootDSE{entry: entry}
+	if err := entry.Unmarshal(rootDSE); err != nil {
+		return nil, err
+	}
+
+	l.messageMutex.Lock()
+	l.rootDSE = rootDSE
+	l.messageMutex.Unlock()
+	return rootDSE, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFoldString(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
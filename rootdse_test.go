@@ -0,0 +1,59 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRootDSE(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", map[string][]string{
+		"namingContexts":          {"DC=example,DC=com", "CN=Configuration,DC=example,DC=com"},
+		"defaultNamingContext":    {"DC=example,DC=com"},
+		"supportedLDAPVersion":    {"3", "2"},
+		"supportedControl":        {ControlTypePaging},
+		"supportedSASLMechanisms": {"GSSAPI", "EXTERNAL"},
+		"domainFunctionality":     {"7"},
+		"isGlobalCatalogReady":    {"TRUE"},
+		"highestCommittedUSN":     {"12345"},
+		"supportedCapabilities":   {"1.2.840.113556.1.4.800"},
+	}))
+
+	rootDSE, err := conn.RootDSE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rootDSE.NamingContexts, []string{"DC=example,DC=com", "CN=Configuration,DC=example,DC=com"}) {
+		t.Errorf("unexpected naming contexts %v", rootDSE.NamingContexts)
+	}
+	if rootDSE.DefaultNamingContext != "DC=example,DC=com" || rootDSE.DomainFunctionality != 7 || !rootDSE.IsGlobalCatalogReady || !rootDSE.IsActiveDirectory() {
+		t.Errorf("unexpected AD attributes %+v", rootDSE)
+	}
+	if !reflect.DeepEqual(rootDSE.SupportedLDAPVersion, []int{3, 2}) {
+		t.Errorf("unexpected LDAP versions %v", rootDSE.SupportedLDAPVersion)
+	}
+	if !rootDSE.SupportsControl(ControlTypePaging) || rootDSE.SupportsControl(ControlTypeVChuPasswordMustChange) {
+		t.Error("unexpected supported controls")
+	}
+	if !rootDSE.SupportsSASLMechanism("gssapi") || rootDSE.SupportsSASLMechanism("DIGEST-MD5") {
+		t.Error("unexpected supported SASL mechanisms")
+	}
+	if rootDSE.Entry().GetAttributeValue("highestCommittedUSN") != "12345" {
+		t.Error("expected raw entry to be available")
+	}
+
+	// the second call is served from the cache without a request
+	cached, err := conn.RootDSE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != rootDSE {
+		t.Error("expected cached root DSE")
+	}
+}
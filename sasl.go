@@ -0,0 +1,181 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// SASLSecurityLayer is a bit mask of SASL security layers, see RFC 4752
+// section 3.3
+type SASLSecurityLayer byte
+
+// SASL security layers
+const (
+	SASLSecurityNone            SASLSecurityLayer = 1
+	SASLSecurityIntegrity       SASLSecurityLayer = 2
+	SASLSecurityConfidentiality SASLSecurityLayer = 4
+)
+
+// SASLSecurityLayerMap contains human readable descriptions of SASL security
+// layers
+var SASLSecurityLayerMap = map[SASLSecurityLayer]string{
+	SASLSecurityNone:            "none",
+	SASLSecurityIntegrity:       "integrity",
+	SASLSecurityConfidentiality: "confidentiality",
+}
+
+// maxSASLBuffer is the maximum size of a SASL buffer received from the
+// server, the largest size the negotiation can express
+const maxSASLBuffer = 1<<24 - 1
+
+// GSSAPIWrapper is implemented by GSSAPIClients which can protect messages
+// with the established security context, as required to install a SASL
+// security layer, see GSSAPIBindRequest.SecurityLayer
+type GSSAPIWrapper interface {
+	// Wrap protects message with GSS_Wrap, encrypting it if confidential
+	// is true
+	Wrap(message []byte, confidential bool) ([]byte, error)
+	// Unwrap verifies token with GSS_Unwrap and returns the message and
+	// whether it was encrypted
+	Unwrap(token []byte) (message []byte, confidential bool, err error)
+}
+
+// negotiateSASLSecurityLayer answers the security layers offered by the
+// server after the GSSAPI security context is established with the
+// strongest of the requested layers. It returns the wrapped response, the
+// selected layer and the maximum size of a SASL buffer the server receives.
+// See RFC 4752 section 3.1.
+func negotiateSASLSecurityLayer(wrapper GSSAPIWrapper, token []byte, req *GSSAPIBindRequest) ([]byte, SASLSecurityLayer, uint32, error) {
+	offer, _, err := wrapper.Unwrap(token)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("ldap: unwrapping SASL security layers: %w", err)
+	}
+	if len(offer) != 4 {
+		return nil, 0, 0, fmt.Errorf("ldap: bad SASL security layer offer of %d bytes", len(offer))
+	}
+	offered := SASLSecurityLayer(offer[0])
+	maxSize := uint32(offer[1])<<16 | uint32(offer[2])<<8 | uint32(offer[3])
+
+	var layer SASLSecurityLayer
+	for _, l := range []SASLSecurityLayer{SASLSecurityConfidentiality, SASLSecurityIntegrity, SASLSecurityNone} {
+		if req.SecurityLayer&offered&l != 0 {
+			layer = l
+			break
+		}
+	}
+	if layer == 0 {
+		return nil, 0, 0, fmt.Errorf("ldap: server offers no requested SASL security layer (offered %#x, requested %#x)", offered, req.SecurityLayer)
+	}
+
+	response := make([]byte, 4, 4+len(req.AuthZID))
+	if layer != SASLSecurityNone {
+		binary.BigEndian.PutUint32(response, maxSASLBuffer)
+	}
+	response[0] = byte(layer)
+	response = append(response, req.AuthZID...)
+	wrapped, err := wrapper.Wrap(response, false)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("ldap: wrapping SASL security layer: %w", err)
+	}
+	return wrapped, layer, maxSize, nil
+}
+
+// saslConn wraps and unwraps the PDUs sent on a connection with a SASL
+// security layer. Every Write is sent as one SASL buffer, so a write must
+// hold a whole PDU. See RFC 4422 section 3.7.
+type saslConn struct {
+	net.Conn
+	client       GSSAPIClient
+	wrapper      GSSAPIWrapper
+	confidential bool
+	// maxSend is the maximum size of a SASL buffer sent, 0 for no limit
+	maxSend uint32
+	// pending holds the unwrapped data not yet read
+	pending []byte
+}
+
+func newSASLConn(conn net.Conn, client GSSAPIClient, layer SASLSecurityLayer, maxSend uint32) *saslConn {
+	return &saslConn{
+		Conn:         conn,
+		client:       client,
+		wrapper:      client.(GSSAPIWrapper),
+		confidential: layer == SASLSecurityConfidentiality,
+		maxSend:      maxSend,
+	}
+}
+
+func (c *saslConn) Read(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+			return 0, err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxSASLBuffer {
+			return 0, fmt.Errorf("ldap: SASL buffer of %d bytes exceeds the maximum of %d", size, maxSASLBuffer)
+		}
+		token := make([]byte, size)
+		if _, err := io.ReadFull(c.Conn, token); err != nil {
+			return 0, err
+		}
+		message, confidential, err := c.wrapper.Unwrap(token)
+		if err != nil {
+			return 0, fmt.Errorf("ldap: unwrapping SASL buffer: %w", err)
+		}
+		if c.confidential && !confidential {
+			return 0, errors.New("ldap: received unencrypted SASL buffer")
+		}
+		c.pending = message
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *saslConn) Write(b []byte) (int, error) {
+	token, err := c.wrapper.Wrap(b, c.confidential)
+	if err != nil {
+		return 0, fmt.Errorf("ldap: wrapping SASL buffer: %w", err)
+	}
+	if c.maxSend > 0 && uint32(len(token)) > c.maxSend {
+		return 0, fmt.Errorf("ldap: SASL buffer of %d bytes exceeds the maximum of %d of the server", len(token), c.maxSend)
+	}
+	buf := make([]byte, 4+len(token))
+	binary.BigEndian.PutUint32(buf, uint32(len(token)))
+	copy(buf[4:], token)
+	if _, err := c.Conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the connection and destroys the security context
+func (c *saslConn) Close() error {
+	err := c.Conn.Close()
+	// nolint:errcheck
+	c.client.DeleteSecContext()
+	return err
+}
+
+// installSASLLayer protects all further PDUs with the SASL security layer.
+// It is called while the reader is stopped after the final bind response.
+func (l *Conn) installSASLLayer(client GSSAPIClient, layer SASLSecurityLayer, maxSend uint32) {
+	l.messageMutex.Lock()
+	l.writeMu.Lock()
+	l.conn = newSASLConn(l.conn, client, layer, maxSend)
+	l.writeMu.Unlock()
+	l.messageMutex.Unlock()
+	l.log(LogLevelInfo, "SASL security layer installed", "layer", SASLSecurityLayerMap[layer])
+}
+
+// hasSASLConfidentiality reports whether the connection is encrypted by a
+// SASL security layer
+func (l *Conn) hasSASLConfidentiality() bool {
+	l.messageMutex.Lock()
+	sc, ok := l.conn.(*saslConn)
+	l.messageMutex.Unlock()
+	return ok && sc.confidential
+}
@@ -0,0 +1,299 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGSSAPIClient establishes a security context in one step. Its wrapped
+// tokens are the message prefixed with 1 if encrypted, which flips the bits.
+type fakeGSSAPIClient struct {
+	deleted bool
+}
+
+func (c *fakeGSSAPIClient) InitSecContext(target string, token []byte) ([]byte, bool, error) {
+	return []byte("init " + target), false, nil
+}
+
+func (c *fakeGSSAPIClient) NegotiateSaslAuth(token []byte, authzid string) ([]byte, error) {
+	return c.Wrap(append([]byte{byte(SASLSecurityNone), 0, 0, 0}, authzid...), false)
+}
+
+func (c *fakeGSSAPIClient) DeleteSecContext() error {
+	c.deleted = true
+	return nil
+}
+
+func (c *fakeGSSAPIClient) Wrap(message []byte, confidential bool) ([]byte, error) {
+	token := []byte{0}
+	if confidential {
+		token[0] = 1
+	}
+	for _, b := range message {
+		if confidential {
+			b = ^b
+		}
+		token = append(token, b)
+	}
+	return token, nil
+}
+
+func (c *fakeGSSAPIClient) Unwrap(token []byte) ([]byte, bool, error) {
+	if len(token) == 0 {
+		return nil, false, errors.New("empty token")
+	}
+	confidential := token[0] == 1
+	message, _ := c.Wrap(token[1:], confidential)
+	return message[1:], confidential, nil
+}
+
+// saslServer answers the binds of a GSSAPI client on conn, offering the
+// given security layers, and then answers a request protected by the
+// selected layer
+type saslServer struct {
+	conn    net.Conn
+	wrapper fakeGSSAPIClient
+	offered SASLSecurityLayer
+	// selected is the negotiation response of the client
+	selected []byte
+}
+
+func (s *saslServer) bindResponse(req *ber.Packet, resultCode uint16, token []byte) []byte {
+	response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationBindResponse, nil, "Bind Response")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	if token != nil {
+		result.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, ber.TagObjectDescriptor, string(token), "serverSaslCreds"))
+	}
+	response.AppendChild(result)
+	return response.Bytes()
+}
+
+// offer answers the first bind with the offered security layers
+func (s *saslServer) offer() error {
+	req, err := ber.ReadPacket(s.conn)
+	if err != nil {
+		return err
+	}
+	offer, _ := s.wrapper.Wrap([]byte{byte(s.offered), 0, 0x10, 0}, false)
+	_, err = s.conn.Write(s.bindResponse(req, LDAPResultSaslBindInProgress, offer))
+	return err
+}
+
+func (s *saslServer) serve(protected bool) error {
+	if err := s.offer(); err != nil {
+		return err
+	}
+	req, err := ber.ReadPacket(s.conn)
+	if err != nil {
+		return err
+	}
+	auth := req.Children[1].Children[2]
+	s.selected, _, err = s.wrapper.Unwrap(auth.Children[1].ByteValue)
+	if err != nil {
+		return err
+	}
+	if _, err := s.conn.Write(s.bindResponse(req, LDAPResultSuccess, nil)); err != nil {
+		return err
+	}
+	if !protected {
+		return nil
+	}
+	return s.answerProtected()
+}
+
+// answerProtected answers a delete request protected by the security layer
+func (s *saslServer) answerProtected() error {
+	var header [4]byte
+	if _, err := io.ReadFull(s.conn, header[:]); err != nil {
+		return err
+	}
+	token := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(s.conn, token); err != nil {
+		return err
+	}
+	message, confidential, err := s.wrapper.Unwrap(token)
+	if err != nil {
+		return err
+	}
+	req, err := ber.ReadPacket(bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationDelResponse, nil, "Del Response")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	response.AppendChild(result)
+	token, _ = s.wrapper.Wrap(response.Bytes(), confidential)
+	binary.BigEndian.PutUint32(header[:], uint32(len(token)))
+	_, err = s.conn.Write(append(header[:], token...))
+	return err
+}
+
+func TestGSSAPISecurityLayer(t *testing.T) {
+	for _, layer := range []SASLSecurityLayer{SASLSecurityIntegrity, SASLSecurityConfidentiality} {
+		client, server := net.Pipe()
+		conn := NewConn(client, false)
+		conn.Start()
+
+		s := &saslServer{conn: server, offered: SASLSecurityNone | SASLSecurityIntegrity | SASLSecurityConfidentiality}
+		done := make(chan error, 1)
+		go func() { done <- s.serve(true) }()
+
+		gssapi := &fakeGSSAPIClient{}
+		err := conn.GSSAPIBindRequest(gssapi, &GSSAPIBindRequest{
+			ServicePrincipalName: "ldap/dc.example.com",
+			AuthZID:              "u:admin",
+			SecurityLayer:        layer,
+		})
+		if !assert.NoError(t, err) {
+			conn.Close()
+			continue
+		}
+		assert.Equal(t, append([]byte{byte(layer), 0xff, 0xff, 0xff}, "u:admin"...), s.selected)
+		assert.False(t, gssapi.deleted)
+		assert.Equal(t, layer == SASLSecurityConfidentiality, conn.hasSASLConfidentiality())
+
+		assert.NoError(t, conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)))
+		assert.NoError(t, <-done)
+		conn.Close()
+		assert.True(t, gssapi.deleted)
+	}
+}
+
+func TestGSSAPISecurityLayerNegotiation(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client, false)
+	conn.Start()
+	defer conn.Close()
+
+	// the server only offers integrity
+	s := &saslServer{conn: server, offered: SASLSecurityNone | SASLSecurityIntegrity}
+	go s.offer()
+	gssapi := &fakeGSSAPIClient{}
+	err := conn.GSSAPIBindRequest(gssapi, &GSSAPIBindRequest{SecurityLayer: SASLSecurityConfidentiality})
+	assert.Error(t, err)
+	assert.True(t, gssapi.deleted)
+
+	// without a layer the client negotiates
+	done := make(chan error, 1)
+	go func() { done <- s.serve(false) }()
+	gssapi = &fakeGSSAPIClient{}
+	assert.NoError(t, conn.GSSAPIBind(gssapi, "ldap/dc.example.com", ""))
+	assert.NoError(t, <-done)
+	assert.Equal(t, []byte{byte(SASLSecurityNone), 0, 0, 0}, s.selected)
+	assert.True(t, gssapi.deleted)
+}
+
+func TestGSSSPNEGOSecurityLayer(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client, false)
+	conn.Start()
+	defer conn.Close()
+
+	// GSS-SPNEGO does not negotiate the layer after the security context
+	s := &saslServer{conn: server}
+	done := make(chan error, 1)
+	go func() {
+		req, err := ber.ReadPacket(server)
+		if err != nil {
+			done <- err
+			return
+		}
+		if mechanism := req.Children[1].Children[2].Children[0].Value; mechanism != "GSS-SPNEGO" {
+			done <- fmt.Errorf("unexpected mechanism %v", mechanism)
+			return
+		}
+		if _, err := server.Write(s.bindResponse(req, LDAPResultSuccess, nil)); err != nil {
+			done <- err
+			return
+		}
+		done <- s.answerProtected()
+	}()
+
+	err := conn.GSSAPIBindRequest(&fakeGSSAPIClient{}, &GSSAPIBindRequest{
+		Mechanism:     "GSS-SPNEGO",
+		SecurityLayer: SASLSecurityIntegrity,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Del(NewDelRequest("cn=a,dc=example,dc=com", nil)))
+	assert.NoError(t, <-done)
+}
+
+func TestSASLSecurityLayerOutstandingRequests(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	newRequest := func() *ber.Packet {
+		packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+		packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, conn.nextMessageID(), "MessageID"))
+		assert.NoError(t, NewDelRequest("cn=a,dc=example,dc=com", nil).appendTo(packet))
+		return packet
+	}
+
+	// a request awaiting its response
+	msgCtx, err := conn.sendMessage(newRequest())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.finishMessage(msgCtx)
+
+	_, err = conn.sendMessageWithFlags(newRequest(), saslSecurityLayer, "")
+	var ldapErr *Error
+	if assert.True(t, errors.As(err, &ldapErr)) {
+		assert.Equal(t, "ldap: cannot install the SASL security layer with outstanding requests", ldapErr.Err.Error())
+	}
+}
+
+func TestSASLSecurityLayerConcurrentRequest(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+
+	// a request sent while the layer is installed must not go out unprotected
+	var sendErr error
+	install := func() {
+		done := make(chan error, 1)
+		go func() {
+			packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Request")
+			packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, conn.nextMessageID(), "MessageID"))
+			if err := NewDelRequest("cn=a,dc=example,dc=com", nil).appendTo(packet); err != nil {
+				done <- err
+				return
+			}
+			msgCtx, err := conn.sendMessage(packet)
+			if err == nil {
+				conn.finishMessage(msgCtx)
+			}
+			done <- err
+		}()
+		sendErr = <-done
+	}
+	_, err := conn.saslBindTokenExchange("GSSAPI", nil, []byte("token"), install, nil)
+	assert.NoError(t, err)
+	var ldapErr *Error
+	if assert.True(t, errors.As(sendErr, &ldapErr)) {
+		assert.Equal(t, "ldap: connection is installing the SASL security layer", ldapErr.Err.Error())
+	}
+}
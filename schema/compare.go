@@ -0,0 +1,158 @@
+package schema
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+)
+
+// EqualityFunc reports whether two assertion values are equal under a
+// matching rule
+type EqualityFunc func(a, b string) bool
+
+// equalityFuncs maps the lowercased names of well-known equality matching
+// rules of RFC 4517 to their implementation. OIDs are resolved to names
+// through the matching rule registry of the ldap package.
+var equalityFuncs = map[string]EqualityFunc{
+	"objectidentifiermatch":  caseIgnoreMatch,
+	"distinguishednamematch": distinguishedNameMatch,
+	"caseignorematch":        caseIgnoreMatch,
+	"caseexactmatch":         caseExactMatch,
+	"numericstringmatch":     numericStringMatch,
+	"caseignorelistmatch":    caseIgnoreMatch,
+	"booleanmatch":           booleanMatch,
+	"integermatch":           integerMatch,
+	"octetstringmatch":       octetStringMatch,
+	"telephonenumbermatch":   telephoneNumberMatch,
+	"uniquemembermatch":      distinguishedNameMatch,
+	"generalizedtimematch":   generalizedTimeMatch,
+	"caseexactia5match":      caseExactMatch,
+	"caseignoreia5match":     caseIgnoreMatch,
+}
+
+// EqualityFuncFor returns the implementation of the equality matching rule
+// with the given name or OID, if it is known
+func EqualityFuncFor(nameOrOID string) (EqualityFunc, bool) {
+	if rule, ok := ldap.LookupMatchingRule(nameOrOID); ok {
+		nameOrOID = rule.Name
+	}
+	equal, ok := equalityFuncs[strings.ToLower(nameOrOID)]
+	return equal, ok
+}
+
+// EqualityRule returns the equality matching rule of the attribute, which may
+// be inherited from its superior types, or "" if it has none or the attribute
+// is not defined
+func (s *Schema) EqualityRule(attribute string) string {
+	a := s.AttributeType(attribute)
+	// the depth is limited in case of cyclic definitions
+	for depth := 0; a != nil && depth < 32; depth++ {
+		if a.Equality != "" {
+			return a.Equality
+		}
+		if a.Superior == "" {
+			break
+		}
+		a = s.AttributeType(a.Superior)
+	}
+	return ""
+}
+
+// Equal reports whether the two values of the attribute are equal the way
+// the server compares them, using the equality matching rule of the
+// attribute. Values of attributes with unknown or unsupported rules are
+// compared byte by byte.
+func (s *Schema) Equal(attribute, a, b string) bool {
+	if equal, ok := EqualityFuncFor(s.EqualityRule(attribute)); ok {
+		return equal(a, b)
+	}
+	return a == b
+}
+
+// EqualValues reports whether the two value sets of the attribute are equal,
+// regardless of their order, see Equal
+func (s *Schema) EqualValues(attribute string, a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return s.containsAll(attribute, a, b) && s.containsAll(attribute, b, a)
+}
+
+// ContainsValue reports whether values holds a value of the attribute equal
+// to value, see Equal
+func (s *Schema) ContainsValue(attribute string, values []string, value string) bool {
+	for _, v := range values {
+		if s.Equal(attribute, v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) containsAll(attribute string, values, subset []string) bool {
+	for _, value := range subset {
+		if !s.ContainsValue(attribute, values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareSpaces removes leading and trailing spaces and collapses inner
+// spaces, as the insignificant space handling of RFC 4518
+func prepareSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func caseIgnoreMatch(a, b string) bool {
+	return strings.EqualFold(prepareSpaces(a), prepareSpaces(b))
+}
+
+func caseExactMatch(a, b string) bool {
+	return prepareSpaces(a) == prepareSpaces(b)
+}
+
+func octetStringMatch(a, b string) bool {
+	return a == b
+}
+
+func numericStringMatch(a, b string) bool {
+	return strings.Replace(a, " ", "", -1) == strings.Replace(b, " ", "", -1)
+}
+
+func telephoneNumberMatch(a, b string) bool {
+	strip := strings.NewReplacer(" ", "", "-", "")
+	return strings.EqualFold(strip.Replace(a), strip.Replace(b))
+}
+
+func booleanMatch(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+func integerMatch(a, b string) bool {
+	x, okA := new(big.Int).SetString(strings.TrimSpace(a), 10)
+	y, okB := new(big.Int).SetString(strings.TrimSpace(b), 10)
+	if !okA || !okB {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+func generalizedTimeMatch(a, b string) bool {
+	x, errA := ldap.ParseGeneralizedTime(a)
+	y, errB := ldap.ParseGeneralizedTime(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return x.Equal(y)
+}
+
+func distinguishedNameMatch(a, b string) bool {
+	x, errA := ldap.ParseDN(a)
+	y, errB := ldap.ParseDN(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return x.EqualFold(y)
+}
@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"testing"
+)
+
+func testSchema(t *testing.T) *Schema {
+	s := New()
+	for _, def := range []string{
+		"( 2.5.4.41 NAME 'name' EQUALITY caseIgnoreMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+		"( 2.5.4.3 NAME 'cn' SUP name )",
+		"( 2.5.4.31 NAME 'member' EQUALITY distinguishedNameMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.12 )",
+		"( 1.3.6.1.1.1.1.0 NAME 'uidNumber' EQUALITY integerMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.27 SINGLE-VALUE )",
+		"( 2.5.18.1 NAME 'createTimestamp' EQUALITY generalizedTimeMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.24 )",
+		"( 0.9.2342.19200300.100.1.3 NAME 'mail' EQUALITY caseIgnoreIA5Match SYNTAX 1.3.6.1.4.1.1466.115.121.1.26 )",
+		"( 2.5.4.20 NAME 'telephoneNumber' EQUALITY telephoneNumberMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.50 )",
+		"( 1.2.3 NAME 'custom' EQUALITY customMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+	} {
+		a, err := ParseAttributeType(def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AddAttributeType(a)
+	}
+	return s
+}
+
+func TestSchemaEqual(t *testing.T) {
+	s := testSchema(t)
+
+	if rule := s.EqualityRule("CN"); rule != "caseIgnoreMatch" {
+		t.Errorf("expected inherited caseIgnoreMatch, got %q", rule)
+	}
+
+	for _, tc := range []struct {
+		attribute string
+		a, b      string
+		equal     bool
+	}{
+		{"cn", "John  Smith ", "john smith", true},
+		{"cn", "John", "Jon", false},
+		{"member", "CN=Bob, DC=example,DC=com", "cn=bob,dc=example,dc=com", true},
+		{"member", "cn=bob,dc=example,dc=com", "cn=bob,dc=example,dc=org", false},
+		{"uidNumber", "0100", "100", true},
+		{"uidNumber", "100", "101", false},
+		{"createTimestamp", "20230102030405Z", "20230102050405+0200", true},
+		{"createTimestamp", "20230102030405Z", "20230102030406Z", false},
+		{"mail", "Bob@Example.com", "bob@example.com", true},
+		{"telephoneNumber", "+1 555-1234", "+15551234", true},
+		{"custom", "a", "A", false},
+		{"undefined", "a", "A", false},
+		{"undefined", "a", "a", true},
+	} {
+		if equal := s.Equal(tc.attribute, tc.a, tc.b); equal != tc.equal {
+			t.Errorf("%s: Equal(%q, %q) = %t, expected %t", tc.attribute, tc.a, tc.b, equal, tc.equal)
+		}
+	}
+
+	if !s.EqualValues("cn", []string{"A", "b"}, []string{"B", "a"}) {
+		t.Error("expected value sets to be equal")
+	}
+	if s.EqualValues("cn", []string{"a", "a"}, []string{"a", "b"}) {
+		t.Error("expected value sets to differ")
+	}
+}
+
+func TestSchemaDecodeValue(t *testing.T) {
+	s := testSchema(t)
+	if v, err := s.DecodeValue("uidNumber", []byte("1000")); err != nil || v != int64(1000) {
+		t.Errorf("unexpected value %v: %v", v, err)
+	}
+	if v, err := s.DecodeValue("cn", []byte("mario")); err != nil || v != "mario" {
+		t.Errorf("unexpected value %v: %v", v, err)
+	}
+	if _, err := s.DecodeValue("uidNumber", []byte("many")); err == nil {
+		t.Error("expected error decoding invalid integer")
+	}
+	if syntax := s.Syntax("1.3.6.1.4.1.1466.115.121.1.27"); syntax == nil || syntax.Description != "Integer" {
+		t.Errorf("unexpected well-known syntax %v", syntax)
+	}
+	if _, ok := EqualityFuncFor("2.5.13.14"); !ok {
+		t.Error("expected integerMatch by OID")
+	}
+}
@@ -0,0 +1,187 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+)
+
+// ObjectClassChain resolves the given object classes and all their
+// superiors. Each class is returned once, the given classes first, followed
+// by their superiors in the order they are reached. Unknown classes result in
+// an error.
+func (s *Schema) ObjectClassChain(objectClasses ...string) ([]*ObjectClass, error) {
+	var chain []*ObjectClass
+	seen := make(map[*ObjectClass]bool)
+	queue := objectClasses
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		o := s.ObjectClass(name)
+		if o == nil {
+			return nil, fmt.Errorf("schema: undefined object class '%s'", name)
+		}
+		if seen[o] {
+			continue
+		}
+		seen[o] = true
+		chain = append(chain, o)
+		queue = append(queue, o.Superiors...)
+	}
+	return chain, nil
+}
+
+// StructuralClass returns the most specific structural object class of the
+// chain resolved from the given object classes, or nil if there is none
+func (s *Schema) StructuralClass(objectClasses ...string) (*ObjectClass, error) {
+	chain, err := s.ObjectClassChain(objectClasses...)
+	if err != nil {
+		return nil, err
+	}
+	// a structural class which is the superior of another is not the most
+	// specific one
+	superiors := make(map[*ObjectClass]bool)
+	for _, o := range chain {
+		for _, sup := range o.Superiors {
+			superiors[s.ObjectClass(sup)] = true
+		}
+	}
+	for _, o := range chain {
+		if o.Kind == Structural && !superiors[o] {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
+
+// Attributes computes the required and optional attributes of entries with
+// the given object classes, including those inherited from their superiors.
+// Attributes are returned by their primary name if they are defined, and
+// required attributes are not repeated as optional ones.
+func (s *Schema) Attributes(objectClasses ...string) (must, may []string, err error) {
+	chain, err := s.ObjectClassChain(objectClasses...)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	for _, o := range chain {
+		for _, name := range o.Must {
+			if key := s.attributeKey(name); !seen[key] {
+				seen[key] = true
+				must = append(must, s.attributeName(name))
+			}
+		}
+	}
+	for _, o := range chain {
+		for _, name := range o.May {
+			if key := s.attributeKey(name); !seen[key] {
+				seen[key] = true
+				may = append(may, s.attributeName(name))
+			}
+		}
+	}
+	return must, may, nil
+}
+
+// ValidationError lists the violations of the object class rules found by
+// ValidateEntry
+type ValidationError struct {
+	DN string
+	// Missing lists the required attributes the entry does not have
+	Missing []string
+	// NotAllowed lists the attributes of the entry which none of its object
+	// classes permits
+	NotAllowed []string
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing required attributes "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.NotAllowed) > 0 {
+		problems = append(problems, "attributes not allowed "+strings.Join(e.NotAllowed, ", "))
+	}
+	return fmt.Sprintf("schema: entry '%s' violates its object classes: %s", e.DN, strings.Join(problems, "; "))
+}
+
+// ValidateEntry checks that the entry has all attributes required by its
+// object classes and no attributes they do not allow, so it can be verified
+// before it is added. Entries of the extensibleObject class may hold any
+// attribute. Violations are returned as *ValidationError.
+func (s *Schema) ValidateEntry(entry *ldap.Entry) error {
+	objectClasses := entry.GetEqualFoldAttributeValues("objectClass")
+	if len(objectClasses) == 0 {
+		return fmt.Errorf("schema: entry '%s' has no object class", entry.DN)
+	}
+	must, may, err := s.Attributes(objectClasses...)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, attr := range entry.Attributes {
+		present[s.attributeKey(attr.Name)] = true
+	}
+	allowed := make(map[string]bool)
+	verr := &ValidationError{DN: entry.DN}
+	for _, name := range must {
+		key := s.attributeKey(name)
+		allowed[key] = true
+		if !present[key] {
+			verr.Missing = append(verr.Missing, name)
+		}
+	}
+	for _, name := range may {
+		allowed[s.attributeKey(name)] = true
+	}
+
+	extensible := false
+	for _, name := range objectClasses {
+		if strings.EqualFold(s.ObjectClass(name).Name(), "extensibleObject") {
+			extensible = true
+		}
+	}
+	if !extensible {
+		for _, attr := range entry.Attributes {
+			if !allowed[s.attributeKey(attr.Name)] {
+				verr.NotAllowed = append(verr.NotAllowed, attr.Name)
+			}
+		}
+	}
+
+	if len(verr.Missing) > 0 || len(verr.NotAllowed) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// ValidateAddRequest validates the entry the request would add, see
+// ValidateEntry
+func (s *Schema) ValidateAddRequest(req *ldap.AddRequest) error {
+	entry := &ldap.Entry{DN: req.DN}
+	for _, attr := range req.Attributes {
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(attr.Type, attr.Vals))
+	}
+	return s.ValidateEntry(entry)
+}
+
+// attributeKey identifies an attribute by its OID if it is defined, so its
+// names and OID are treated alike
+func (s *Schema) attributeKey(name string) string {
+	if a := s.AttributeType(name); a != nil {
+		return strings.ToLower(a.OID)
+	}
+	if i := strings.IndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+func (s *Schema) attributeName(name string) string {
+	if a := s.AttributeType(name); a != nil {
+		return a.Name()
+	}
+	return name
+}
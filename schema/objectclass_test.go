@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+func testObjectClassSchema(t *testing.T) *Schema {
+	s := New()
+	for _, def := range []string{
+		"( 2.5.4.0 NAME 'objectClass' SYNTAX 1.3.6.1.4.1.1466.115.121.1.38 )",
+		"( 2.5.4.3 NAME ( 'cn' 'commonName' ) SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+		"( 2.5.4.4 NAME ( 'sn' 'surname' ) SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+		"( 2.5.4.13 NAME 'description' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+		"( 2.5.4.20 NAME 'telephoneNumber' SYNTAX 1.3.6.1.4.1.1466.115.121.1.50 )",
+		"( 0.9.2342.19200300.100.1.3 NAME 'mail' SYNTAX 1.3.6.1.4.1.1466.115.121.1.26 )",
+	} {
+		a, err := ParseAttributeType(def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AddAttributeType(a)
+	}
+	for _, def := range []string{
+		"( 2.5.6.0 NAME 'top' ABSTRACT MUST objectClass )",
+		"( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( telephoneNumber $ description ) )",
+		"( 2.5.6.7 NAME 'organizationalPerson' SUP person STRUCTURAL MAY ( description ) )",
+		"( 1.2.3.1 NAME 'mailRecipient' SUP top AUXILIARY MAY ( mail $ cn ) )",
+		"( 1.3.6.1.4.1.1466.101.120.111 NAME 'extensibleObject' SUP top AUXILIARY )",
+	} {
+		o, err := ParseObjectClass(def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AddObjectClass(o)
+	}
+	return s
+}
+
+func TestObjectClassChain(t *testing.T) {
+	s := testObjectClassSchema(t)
+	chain, err := s.ObjectClassChain("organizationalPerson", "mailRecipient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range chain {
+		names = append(names, o.Name())
+	}
+	expected := []string{"organizationalPerson", "mailRecipient", "person", "top"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("got chain %v, expected %v", names, expected)
+	}
+	if _, err := s.ObjectClassChain("unknown"); err == nil {
+		t.Error("expected error for undefined object class")
+	}
+
+	structural, err := s.StructuralClass("top", "person", "organizationalPerson", "mailRecipient")
+	if err != nil || structural == nil || structural.Name() != "organizationalPerson" {
+		t.Errorf("unexpected structural class %v: %v", structural, err)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	s := testObjectClassSchema(t)
+	must, may, err := s.Attributes("organizationalPerson", "mailRecipient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"sn", "cn", "objectClass"}; !reflect.DeepEqual(must, expected) {
+		t.Errorf("got must %v, expected %v", must, expected)
+	}
+	if expected := []string{"description", "mail", "telephoneNumber"}; !reflect.DeepEqual(may, expected) {
+		t.Errorf("got may %v, expected %v", may, expected)
+	}
+}
+
+func TestValidateEntry(t *testing.T) {
+	s := testObjectClassSchema(t)
+	entry := ldap.NewEntry("cn=mario,dc=example", map[string][]string{
+		"objectClass": {"top", "person"},
+		"commonName":  {"mario"},
+		"surname":     {"rossi"},
+		"cn;lang-it":  {"mario"},
+	})
+	if err := s.ValidateEntry(entry); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	err := s.ValidateAddRequest(&ldap.AddRequest{DN: "cn=mario,dc=example", Attributes: []ldap.Attribute{
+		{Type: "objectClass", Vals: []string{"person"}},
+		{Type: "cn", Vals: []string{"mario"}},
+		{Type: "mail", Vals: []string{"mario@example.com"}},
+	}})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if !reflect.DeepEqual(verr.Missing, []string{"sn"}) || !reflect.DeepEqual(verr.NotAllowed, []string{"mail"}) {
+		t.Errorf("unexpected validation error %s", verr)
+	}
+
+	entry = ldap.NewEntry("cn=mario,dc=example", map[string][]string{
+		"objectClass": {"person", "extensibleObject"},
+		"cn":          {"mario"},
+		"sn":          {"rossi"},
+		"mail":        {"mario@example.com"},
+	})
+	if err := s.ValidateEntry(entry); err != nil {
+		t.Errorf("unexpected error for extensible object %s", err)
+	}
+
+	if err := s.ValidateEntry(ldap.NewEntry("cn=x", map[string][]string{"cn": {"x"}})); err == nil {
+		t.Error("expected error for entry without object class")
+	}
+}
@@ -0,0 +1,338 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenOpen tokenKind = iota
+	tokenClose
+	tokenQuoted
+	tokenWord
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// lex splits a definition into parentheses, quoted strings and other words
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose})
+			i++
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string at offset %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenQuoted, value: unescapeQDString(s[i+1 : i+1+end])})
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r()'", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: s[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// unescapeQDString replaces the escapes allowed in qdstrings, \27 for ' and
+// \5C for \
+func unescapeQDString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	r := strings.NewReplacer(`\27`, `'`, `\5C`, `\`, `\5c`, `\`)
+	return r.Replace(s)
+}
+
+// definition is the generic form of an RFC 4512 description: a numeric OID
+// followed by keywords, which are either flags or have one or more values
+type definition struct {
+	oid        string
+	values     map[string][]string
+	flags      map[string]bool
+	extensions map[string][]string
+}
+
+// flagKeywords are keywords without a value
+var flagKeywords = map[string]bool{
+	"OBSOLETE":             true,
+	"SINGLE-VALUE":         true,
+	"COLLECTIVE":           true,
+	"NO-USER-MODIFICATION": true,
+	"ABSTRACT":             true,
+	"STRUCTURAL":           true,
+	"AUXILIARY":            true,
+}
+
+// parseDefinition parses a description, allowing only the given keywords
+// besides extensions
+func parseDefinition(s string, allowed map[string]bool) (*definition, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) < 3 || tokens[0].kind != tokenOpen || tokens[len(tokens)-1].kind != tokenClose {
+		return nil, fmt.Errorf("definition must be enclosed in parentheses")
+	}
+	tokens = tokens[1 : len(tokens)-1]
+	if tokens[0].kind != tokenWord && tokens[0].kind != tokenQuoted {
+		return nil, fmt.Errorf("definition must start with an OID")
+	}
+
+	def := &definition{
+		oid:        tokens[0].value,
+		values:     make(map[string][]string),
+		flags:      make(map[string]bool),
+		extensions: make(map[string][]string),
+	}
+	for i := 1; i < len(tokens); {
+		if tokens[i].kind != tokenWord {
+			return nil, fmt.Errorf("expected keyword, got %q", tokens[i].value)
+		}
+		keyword := tokens[i].value
+		i++
+
+		isExtension := strings.HasPrefix(keyword, "X-")
+		if !isExtension && !allowed[keyword] {
+			return nil, fmt.Errorf("unexpected keyword %s", keyword)
+		}
+		if _, seen := def.values[keyword]; seen || def.flags[keyword] {
+			return nil, fmt.Errorf("duplicate keyword %s", keyword)
+		}
+		if flagKeywords[keyword] {
+			def.flags[keyword] = true
+			continue
+		}
+
+		values, n, err := parseValues(tokens[i:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyword, err)
+		}
+		i += n
+		if isExtension {
+			def.extensions[keyword] = values
+		} else {
+			def.values[keyword] = values
+		}
+	}
+	return def, nil
+}
+
+// parseValues parses a single value or a parenthesized list of values,
+// separated by spaces or "$", returning the values and the number of tokens
+// consumed
+func parseValues(tokens []token) ([]string, int, error) {
+	if len(tokens) == 0 {
+		return nil, 0, fmt.Errorf("missing value")
+	}
+	switch tokens[0].kind {
+	case tokenWord, tokenQuoted:
+		return []string{tokens[0].value}, 1, nil
+	case tokenOpen:
+		var values []string
+		for i := 1; i < len(tokens); i++ {
+			switch tokens[i].kind {
+			case tokenClose:
+				return values, i + 1, nil
+			case tokenOpen:
+				return nil, 0, fmt.Errorf("unexpected nested list")
+			default:
+				if tokens[i].kind == tokenWord && tokens[i].value == "$" {
+					continue
+				}
+				// values directly adjacent to the separator, e.g. a$b
+				for _, v := range strings.Split(tokens[i].value, "$") {
+					if v != "" || tokens[i].kind == tokenQuoted {
+						values = append(values, v)
+					}
+				}
+			}
+		}
+		return nil, 0, fmt.Errorf("unterminated list")
+	default:
+		return nil, 0, fmt.Errorf("missing value")
+	}
+}
+
+// single returns the only value of the keyword, or ""
+func (d *definition) single(keyword string) (string, error) {
+	values := d.values[keyword]
+	switch len(values) {
+	case 0:
+		return "", nil
+	case 1:
+		return values[0], nil
+	default:
+		return "", fmt.Errorf("%s must have a single value", keyword)
+	}
+}
+
+var attributeTypeKeywords = map[string]bool{
+	"NAME": true, "DESC": true, "OBSOLETE": true, "SUP": true, "EQUALITY": true,
+	"ORDERING": true, "SUBSTR": true, "SYNTAX": true, "SINGLE-VALUE": true,
+	"COLLECTIVE": true, "NO-USER-MODIFICATION": true, "USAGE": true,
+}
+
+// ParseAttributeType parses an AttributeTypeDescription as defined in
+// https://datatracker.ietf.org/doc/html/rfc4512#section-4.1.2
+func ParseAttributeType(s string) (*AttributeType, error) {
+	def, err := parseDefinition(s, attributeTypeKeywords)
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid attribute type: %w", err)
+	}
+	a := &AttributeType{
+		OID:                def.oid,
+		Names:              def.values["NAME"],
+		Obsolete:           def.flags["OBSOLETE"],
+		SingleValue:        def.flags["SINGLE-VALUE"],
+		Collective:         def.flags["COLLECTIVE"],
+		NoUserModification: def.flags["NO-USER-MODIFICATION"],
+		Usage:              UsageUserApplications,
+		Extensions:         def.extensions,
+	}
+	fields := []struct {
+		keyword string
+		value   *string
+	}{
+		{"DESC", &a.Description},
+		{"SUP", &a.Superior},
+		{"EQUALITY", &a.Equality},
+		{"ORDERING", &a.Ordering},
+		{"SUBSTR", &a.Substring},
+		{"USAGE", &a.Usage},
+	}
+	for _, field := range fields {
+		value, err := def.single(field.keyword)
+		if err != nil {
+			return nil, fmt.Errorf("schema: invalid attribute type %s: %w", def.oid, err)
+		}
+		if value != "" {
+			*field.value = value
+		}
+	}
+
+	syntax, err := def.single("SYNTAX")
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid attribute type %s: %w", def.oid, err)
+	}
+	if i := strings.IndexByte(syntax, '{'); i >= 0 && strings.HasSuffix(syntax, "}") {
+		length, err := strconv.Atoi(syntax[i+1 : len(syntax)-1])
+		if err != nil {
+			return nil, fmt.Errorf("schema: invalid attribute type %s: invalid syntax length %q", def.oid, syntax)
+		}
+		syntax, a.SyntaxLength = syntax[:i], length
+	}
+	a.Syntax = syntax
+
+	switch a.Usage {
+	case UsageUserApplications, UsageDirectoryOperation, UsageDistributedOperation, UsageDSAOperation:
+	default:
+		return nil, fmt.Errorf("schema: invalid attribute type %s: invalid usage %q", def.oid, a.Usage)
+	}
+	return a, nil
+}
+
+var objectClassKeywords = map[string]bool{
+	"NAME": true, "DESC": true, "OBSOLETE": true, "SUP": true, "ABSTRACT": true,
+	"STRUCTURAL": true, "AUXILIARY": true, "MUST": true, "MAY": true,
+}
+
+// ParseObjectClass parses an ObjectClassDescription as defined in
+// https://datatracker.ietf.org/doc/html/rfc4512#section-4.1.1
+func ParseObjectClass(s string) (*ObjectClass, error) {
+	def, err := parseDefinition(s, objectClassKeywords)
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid object class: %w", err)
+	}
+	description, err := def.single("DESC")
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid object class %s: %w", def.oid, err)
+	}
+
+	o := &ObjectClass{
+		OID:         def.oid,
+		Names:       def.values["NAME"],
+		Description: description,
+		Obsolete:    def.flags["OBSOLETE"],
+		Superiors:   def.values["SUP"],
+		Kind:        Structural,
+		Must:        def.values["MUST"],
+		May:         def.values["MAY"],
+		Extensions:  def.extensions,
+	}
+	kinds := 0
+	for flag, kind := range map[string]ObjectClassKind{"ABSTRACT": Abstract, "STRUCTURAL": Structural, "AUXILIARY": Auxiliary} {
+		if def.flags[flag] {
+			o.Kind = kind
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return nil, fmt.Errorf("schema: invalid object class %s: more than one kind", def.oid)
+	}
+	return o, nil
+}
+
+var matchingRuleKeywords = map[string]bool{
+	"NAME": true, "DESC": true, "OBSOLETE": true, "SYNTAX": true,
+}
+
+// ParseMatchingRule parses a MatchingRuleDescription as defined in
+// https://datatracker.ietf.org/doc/html/rfc4512#section-4.1.3
+func ParseMatchingRule(s string) (*MatchingRule, error) {
+	def, err := parseDefinition(s, matchingRuleKeywords)
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid matching rule: %w", err)
+	}
+	m := &MatchingRule{
+		OID:        def.oid,
+		Names:      def.values["NAME"],
+		Obsolete:   def.flags["OBSOLETE"],
+		Extensions: def.extensions,
+	}
+	if m.Description, err = def.single("DESC"); err != nil {
+		return nil, fmt.Errorf("schema: invalid matching rule %s: %w", def.oid, err)
+	}
+	if m.Syntax, err = def.single("SYNTAX"); err != nil {
+		return nil, fmt.Errorf("schema: invalid matching rule %s: %w", def.oid, err)
+	}
+	if m.Syntax == "" {
+		return nil, fmt.Errorf("schema: invalid matching rule %s: missing SYNTAX", def.oid)
+	}
+	return m, nil
+}
+
+var syntaxKeywords = map[string]bool{
+	"DESC": true,
+}
+
+// ParseSyntax parses a SyntaxDescription as defined in
+// https://datatracker.ietf.org/doc/html/rfc4512#section-4.1.5
+func ParseSyntax(s string) (*Syntax, error) {
+	def, err := parseDefinition(s, syntaxKeywords)
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid syntax: %w", err)
+	}
+	description, err := def.single("DESC")
+	if err != nil {
+		return nil, fmt.Errorf("schema: invalid syntax %s: %w", def.oid, err)
+	}
+	return &Syntax{OID: def.oid, Description: description, Extensions: def.extensions}, nil
+}
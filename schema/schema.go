@@ -0,0 +1,292 @@
+// Package schema parses the subschema of an LDAP server, as defined in
+// https://datatracker.ietf.org/doc/html/rfc4512#section-4, into attribute
+// types, object classes, matching rules and syntaxes which can be looked up
+// by name or OID.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+)
+
+// attribute type usages
+const (
+	UsageUserApplications     = "userApplications"
+	UsageDirectoryOperation   = "directoryOperation"
+	UsageDistributedOperation = "distributedOperation"
+	UsageDSAOperation         = "dSAOperation"
+)
+
+// AttributeType is an attribute type definition
+type AttributeType struct {
+	OID                string
+	Names              []string
+	Description        string
+	Obsolete           bool
+	Superior           string
+	Equality           string
+	Ordering           string
+	Substring          string
+	Syntax             string
+	SyntaxLength       int
+	SingleValue        bool
+	Collective         bool
+	NoUserModification bool
+	Usage              string
+	Extensions         map[string][]string
+}
+
+// Name returns the primary name of the attribute type, or its OID if it has
+// no name
+func (a *AttributeType) Name() string {
+	return primaryName(a.OID, a.Names)
+}
+
+// ObjectClassKind is the kind of an object class
+type ObjectClassKind int
+
+// object class kinds
+const (
+	Structural ObjectClassKind = iota
+	Abstract
+	Auxiliary
+)
+
+// ObjectClassKindMap contains human readable descriptions of object class kinds
+var ObjectClassKindMap = map[ObjectClassKind]string{
+	Structural: "STRUCTURAL",
+	Abstract:   "ABSTRACT",
+	Auxiliary:  "AUXILIARY",
+}
+
+// ObjectClass is an object class definition
+type ObjectClass struct {
+	OID         string
+	Names       []string
+	Description string
+	Obsolete    bool
+	Superiors   []string
+	Kind        ObjectClassKind
+	Must        []string
+	May         []string
+	Extensions  map[string][]string
+}
+
+// Name returns the primary name of the object class, or its OID if it has
+// no name
+func (o *ObjectClass) Name() string {
+	return primaryName(o.OID, o.Names)
+}
+
+// MatchingRule is a matching rule definition
+type MatchingRule struct {
+	OID         string
+	Names       []string
+	Description string
+	Obsolete    bool
+	Syntax      string
+	Extensions  map[string][]string
+}
+
+// Name returns the primary name of the matching rule, or its OID if it has
+// no name
+func (m *MatchingRule) Name() string {
+	return primaryName(m.OID, m.Names)
+}
+
+// Syntax is an LDAP syntax definition
+type Syntax struct {
+	OID         string
+	Description string
+	Extensions  map[string][]string
+}
+
+func primaryName(oid string, names []string) string {
+	if len(names) > 0 {
+		return names[0]
+	}
+	return oid
+}
+
+// Schema holds the definitions of a subschema in the order they were parsed
+type Schema struct {
+	AttributeTypes []*AttributeType
+	ObjectClasses  []*ObjectClass
+	MatchingRules  []*MatchingRule
+	Syntaxes       []*Syntax
+
+	attributeTypes map[string]*AttributeType
+	objectClasses  map[string]*ObjectClass
+	matchingRules  map[string]*MatchingRule
+	syntaxes       map[string]*Syntax
+}
+
+// New returns an empty schema
+func New() *Schema {
+	return &Schema{
+		attributeTypes: make(map[string]*AttributeType),
+		objectClasses:  make(map[string]*ObjectClass),
+		matchingRules:  make(map[string]*MatchingRule),
+		syntaxes:       make(map[string]*Syntax),
+	}
+}
+
+// AddAttributeType adds the definition, lookups by its OID and names return
+// it from then on
+func (s *Schema) AddAttributeType(a *AttributeType) {
+	s.AttributeTypes = append(s.AttributeTypes, a)
+	for _, key := range append([]string{a.OID}, a.Names...) {
+		s.attributeTypes[strings.ToLower(key)] = a
+	}
+}
+
+// AddObjectClass adds the definition, lookups by its OID and names return it
+// from then on
+func (s *Schema) AddObjectClass(o *ObjectClass) {
+	s.ObjectClasses = append(s.ObjectClasses, o)
+	for _, key := range append([]string{o.OID}, o.Names...) {
+		s.objectClasses[strings.ToLower(key)] = o
+	}
+}
+
+// AddMatchingRule adds the definition, lookups by its OID and names return
+// it from then on
+func (s *Schema) AddMatchingRule(m *MatchingRule) {
+	s.MatchingRules = append(s.MatchingRules, m)
+	for _, key := range append([]string{m.OID}, m.Names...) {
+		s.matchingRules[strings.ToLower(key)] = m
+	}
+}
+
+// AddSyntax adds the definition, lookups by its OID return it from then on
+func (s *Schema) AddSyntax(syntax *Syntax) {
+	s.Syntaxes = append(s.Syntaxes, syntax)
+	s.syntaxes[strings.ToLower(syntax.OID)] = syntax
+}
+
+// AttributeType returns the attribute type with the given name or OID, or nil.
+// Names are matched case-insensitively and attribute options like ";binary"
+// are ignored.
+func (s *Schema) AttributeType(nameOrOID string) *AttributeType {
+	if i := strings.IndexByte(nameOrOID, ';'); i >= 0 {
+		nameOrOID = nameOrOID[:i]
+	}
+	return s.attributeTypes[strings.ToLower(nameOrOID)]
+}
+
+// ObjectClass returns the object class with the given name or OID, or nil
+func (s *Schema) ObjectClass(nameOrOID string) *ObjectClass {
+	return s.objectClasses[strings.ToLower(nameOrOID)]
+}
+
+// MatchingRule returns the matching rule with the given name or OID, or nil
+func (s *Schema) MatchingRule(nameOrOID string) *MatchingRule {
+	return s.matchingRules[strings.ToLower(nameOrOID)]
+}
+
+// Syntax returns the syntax with the given OID, or nil. Well-known syntaxes
+// of the ldap package registry are returned even if the server did not
+// publish them.
+func (s *Schema) Syntax(oid string) *Syntax {
+	if syntax, ok := s.syntaxes[strings.ToLower(oid)]; ok {
+		return syntax
+	}
+	if info, ok := ldap.LookupSyntax(oid); ok && info.OID == oid {
+		return &Syntax{OID: info.OID, Description: info.Name}
+	}
+	return nil
+}
+
+// AttributeSyntax returns the syntax OID of the attribute, which may be
+// inherited from its superior types, or "" if it has none or the attribute
+// is not defined
+func (s *Schema) AttributeSyntax(attribute string) string {
+	a := s.AttributeType(attribute)
+	// the depth is limited in case of cyclic definitions
+	for depth := 0; a != nil && depth < 32; depth++ {
+		if a.Syntax != "" {
+			return a.Syntax
+		}
+		if a.Superior == "" {
+			break
+		}
+		a = s.AttributeType(a.Superior)
+	}
+	return ""
+}
+
+// DecodeValue decodes a raw value of the attribute into its Go
+// representation according to the attribute's syntax, see
+// ldap.DecodeSyntaxValue
+func (s *Schema) DecodeValue(attribute string, value []byte) (interface{}, error) {
+	return ldap.DecodeSyntaxValue(s.AttributeSyntax(attribute), value)
+}
+
+// Parse parses the definitions held by the attributeTypes, objectClasses,
+// matchingRules and ldapSyntaxes attributes of a subschema entry
+func Parse(entry *ldap.Entry) (*Schema, error) {
+	s := New()
+	for _, value := range entry.GetEqualFoldAttributeValues("ldapSyntaxes") {
+		syntax, err := ParseSyntax(value)
+		if err != nil {
+			return nil, err
+		}
+		s.AddSyntax(syntax)
+	}
+	for _, value := range entry.GetEqualFoldAttributeValues("matchingRules") {
+		m, err := ParseMatchingRule(value)
+		if err != nil {
+			return nil, err
+		}
+		s.AddMatchingRule(m)
+	}
+	for _, value := range entry.GetEqualFoldAttributeValues("attributeTypes") {
+		a, err := ParseAttributeType(value)
+		if err != nil {
+			return nil, err
+		}
+		s.AddAttributeType(a)
+	}
+	for _, value := range entry.GetEqualFoldAttributeValues("objectClasses") {
+		o, err := ParseObjectClass(value)
+		if err != nil {
+			return nil, err
+		}
+		s.AddObjectClass(o)
+	}
+	return s, nil
+}
+
+// Fetch reads the subschema entry named by the subschemaSubentry attribute of
+// the root DSE and parses it
+func Fetch(l ldap.Client) (*Schema, error) {
+	rootDSE, err := l.Search(ldap.NewSearchRequest(
+		"", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"subschemaSubentry"}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(rootDSE.Entries) != 1 {
+		return nil, errors.New("schema: root DSE not found")
+	}
+	dn := rootDSE.Entries[0].GetEqualFoldAttributeValue("subschemaSubentry")
+	if dn == "" {
+		return nil, errors.New("schema: root DSE has no subschemaSubentry")
+	}
+
+	result, err := l.Search(ldap.NewSearchRequest(
+		dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=subschema)", []string{"attributeTypes", "objectClasses", "matchingRules", "ldapSyntaxes"}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		return nil, fmt.Errorf("schema: subschema entry %q not found", dn)
+	}
+	return Parse(result.Entries[0])
+}
@@ -0,0 +1,176 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+func TestParseAttributeType(t *testing.T) {
+	a, err := ParseAttributeType("( 2.5.4.41 NAME 'name' DESC 'RFC4519: common supertype of name attributes' EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{32768} )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &AttributeType{
+		OID:          "2.5.4.41",
+		Names:        []string{"name"},
+		Description:  "RFC4519: common supertype of name attributes",
+		Equality:     "caseIgnoreMatch",
+		Substring:    "caseIgnoreSubstringsMatch",
+		Syntax:       "1.3.6.1.4.1.1466.115.121.1.15",
+		SyntaxLength: 32768,
+		Usage:        UsageUserApplications,
+		Extensions:   map[string][]string{},
+	}
+	if !reflect.DeepEqual(a, expected) {
+		t.Errorf("got %+v, want %+v", a, expected)
+	}
+
+	a, err = ParseAttributeType("( 2.5.18.1 NAME ( 'createTimestamp' 'created' ) DESC 'it\\27s quoted' SUP name SINGLE-VALUE NO-USER-MODIFICATION USAGE directoryOperation X-ORIGIN ( 'RFC 4512' 'test' ) )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Names, []string{"createTimestamp", "created"}) || a.Name() != "createTimestamp" {
+		t.Errorf("unexpected names %v", a.Names)
+	}
+	if a.Description != "it's quoted" {
+		t.Errorf("unexpected description %q", a.Description)
+	}
+	if a.Superior != "name" || !a.SingleValue || !a.NoUserModification || a.Usage != UsageDirectoryOperation {
+		t.Errorf("unexpected attribute type %+v", a)
+	}
+	if !reflect.DeepEqual(a.Extensions["X-ORIGIN"], []string{"RFC 4512", "test"}) {
+		t.Errorf("unexpected extensions %v", a.Extensions)
+	}
+
+	for _, s := range []string{
+		"2.5.4.41 NAME 'name'",
+		"( 2.5.4.41 NAME 'name' UNKNOWN x )",
+		"( 2.5.4.41 NAME 'name' NAME 'other' )",
+		"( 2.5.4.41 NAME 'unterminated )",
+		"( 2.5.4.41 USAGE everywhere )",
+		"( 2.5.4.41 SYNTAX 1.2.3{x} )",
+	} {
+		if _, err := ParseAttributeType(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestParseObjectClass(t *testing.T) {
+	o, err := ParseObjectClass("( 2.5.6.6 NAME 'person' DESC 'RFC2256: a person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( userPassword $ telephoneNumber $seeAlso$ description ) )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ObjectClass{
+		OID:         "2.5.6.6",
+		Names:       []string{"person"},
+		Description: "RFC2256: a person",
+		Superiors:   []string{"top"},
+		Kind:        Structural,
+		Must:        []string{"sn", "cn"},
+		May:         []string{"userPassword", "telephoneNumber", "seeAlso", "description"},
+		Extensions:  map[string][]string{},
+	}
+	if !reflect.DeepEqual(o, expected) {
+		t.Errorf("got %+v, want %+v", o, expected)
+	}
+
+	o, err = ParseObjectClass("( 2.5.6.0 NAME 'top' ABSTRACT MUST objectClass )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Kind != Abstract || !reflect.DeepEqual(o.Must, []string{"objectClass"}) {
+		t.Errorf("unexpected object class %+v", o)
+	}
+
+	if _, err := ParseObjectClass("( 2.5.6.0 NAME 'top' ABSTRACT AUXILIARY )"); err == nil {
+		t.Error("expected error for multiple kinds")
+	}
+}
+
+func TestParseMatchingRuleAndSyntax(t *testing.T) {
+	m, err := ParseMatchingRule("( 2.5.13.2 NAME 'caseIgnoreMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name() != "caseIgnoreMatch" || m.Syntax != "1.3.6.1.4.1.1466.115.121.1.15" {
+		t.Errorf("unexpected matching rule %+v", m)
+	}
+	if _, err := ParseMatchingRule("( 2.5.13.2 NAME 'caseIgnoreMatch' )"); err == nil {
+		t.Error("expected error for missing syntax")
+	}
+
+	syntax, err := ParseSyntax("( 1.3.6.1.4.1.1466.115.121.1.15 DESC 'Directory String' X-NOT-HUMAN-READABLE 'FALSE' )")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if syntax.Description != "Directory String" || syntax.Extensions["X-NOT-HUMAN-READABLE"][0] != "FALSE" {
+		t.Errorf("unexpected syntax %+v", syntax)
+	}
+}
+
+func TestParse(t *testing.T) {
+	entry := ldap.NewEntry("cn=Subschema", map[string][]string{
+		"ldapSyntaxes":   {"( 1.3.6.1.4.1.1466.115.121.1.15 DESC 'Directory String' )"},
+		"matchingRules":  {"( 2.5.13.2 NAME 'caseIgnoreMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"},
+		"attributeTypes": {"( 2.5.4.3 NAME ( 'cn' 'commonName' ) EQUALITY caseIgnoreMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"},
+		"objectClasses":  {"( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) )"},
+	})
+	s, err := Parse(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cn := s.AttributeType("commonName;lang-de")
+	if cn == nil || cn != s.AttributeType("2.5.4.3") || cn != s.AttributeType("CN") {
+		t.Error("attribute type lookup failed")
+	}
+	if s.ObjectClass("PERSON") == nil || s.ObjectClass("2.5.6.6") == nil || s.ObjectClass("organization") != nil {
+		t.Error("object class lookup failed")
+	}
+	if s.MatchingRule("caseignorematch") == nil || s.Syntax("1.3.6.1.4.1.1466.115.121.1.15") == nil {
+		t.Error("matching rule or syntax lookup failed")
+	}
+
+	entry = ldap.NewEntry("cn=Subschema", map[string][]string{"objectClasses": {"invalid"}})
+	if _, err := Parse(entry); err == nil {
+		t.Error("expected error for invalid definition")
+	}
+}
+
+// searchClient answers base searches with the entry of the base DN
+type searchClient struct {
+	ldap.Client
+	entries map[string]*ldap.Entry
+}
+
+func (c *searchClient) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+	if entry, ok := c.entries[req.BaseDN]; ok {
+		result.Entries = append(result.Entries, entry)
+	}
+	return result, nil
+}
+
+func TestFetch(t *testing.T) {
+	client := &searchClient{entries: map[string]*ldap.Entry{
+		"": ldap.NewEntry("", map[string][]string{"subschemaSubentry": {"cn=Subschema"}}),
+		"cn=Subschema": ldap.NewEntry("cn=Subschema", map[string][]string{
+			"attributeTypes": {"( 2.5.4.3 NAME 'cn' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"},
+		}),
+	}}
+	s, err := Fetch(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AttributeType("cn") == nil {
+		t.Error("expected cn to be defined")
+	}
+
+	delete(client.entries, "cn=Subschema")
+	if _, err := Fetch(client); err == nil {
+		t.Error("expected error for missing subschema entry")
+	}
+}
@@ -1,12 +1,17 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
 )
@@ -73,7 +78,7 @@ type Entry struct {
 func (e *Entry) GetAttributeValues(attribute string) []string {
 	for _, attr := range e.Attributes {
 		if attr.Name == attribute {
-			return attr.Values
+			return attr.StringValues()
 		}
 	}
 	return []string{}
@@ -84,7 +89,7 @@ func (e *Entry) GetAttributeValues(attribute string) []string {
 func (e *Entry) GetEqualFoldAttributeValues(attribute string) []string {
 	for _, attr := range e.Attributes {
 		if strings.EqualFold(attribute, attr.Name) {
-			return attr.Values
+			return attr.StringValues()
 		}
 	}
 	return []string{}
@@ -149,45 +154,153 @@ func (e *Entry) GetEqualFoldRawAttributeValue(attribute string) []byte {
 
 // Print outputs a human-readable description
 func (e *Entry) Print() {
-	fmt.Printf("DN: %s\n", e.DN)
-	for _, attr := range e.Attributes {
-		attr.Print()
-	}
+	e.PrintTo(os.Stdout)
 }
 
 // PrettyPrint outputs a human-readable description indenting
 func (e *Entry) PrettyPrint(indent int) {
-	fmt.Printf("%sDN: %s\n", strings.Repeat(" ", indent), e.DN)
+	e.PrettyPrintTo(os.Stdout, indent)
+}
+
+// PrintTo writes a human-readable description to w
+func (e *Entry) PrintTo(w io.Writer) {
+	fmt.Fprintf(w, "DN: %s\n", e.DN)
 	for _, attr := range e.Attributes {
-		attr.PrettyPrint(indent + 2)
+		attr.PrintTo(w)
 	}
 }
 
+// PrettyPrintTo writes a human-readable description with indenting to w
+func (e *Entry) PrettyPrintTo(w io.Writer, indent int) {
+	fmt.Fprintf(w, "%sDN: %s\n", strings.Repeat(" ", indent), e.DN)
+	for _, attr := range e.Attributes {
+		attr.PrettyPrintTo(w, indent+2)
+	}
+}
+
+// String returns the human-readable description written by Print
+func (e *Entry) String() string {
+	var sb strings.Builder
+	e.PrintTo(&sb)
+	return sb.String()
+}
+
+// Unmarshaler is implemented by types which decode themselves from the raw
+// values of an attribute, e.g. SIDs, GUIDs or enums. Entry.Unmarshal calls
+// UnmarshalLDAP for fields whose type, or a pointer to it, implements it.
+type Unmarshaler interface {
+	UnmarshalLDAP(values [][]byte) error
+}
+
+// Marshaler is the counterpart of Unmarshaler, implemented by types which
+// encode themselves into raw attribute values. MarshalEntry calls MarshalLDAP
+// for fields whose type, or a pointer to it, implements it.
+type Marshaler interface {
+	MarshalLDAP() ([][]byte, error)
+}
+
 // Describe the tag to use for struct field tags
 const decoderTagName = "ldap"
 
 // readTag will read the reflect.StructField value for
-// the key defined in decoderTagName. If omitempty is
-// specified, the field may not be filled.
-func readTag(f reflect.StructField) (string, bool) {
-	val, ok := f.Tag.Lookup(decoderTagName)
+// the given tag key, usually decoderTagName. If omitempty is
+// specified, the field may not be filled. The name defaults
+// to the field name, and is empty for fields tagged with "-",
+// which are skipped.
+func readTag(f reflect.StructField, tagName string) (string, bool) {
+	val, ok := f.Tag.Lookup(tagName)
 	if !ok {
 		return f.Name, false
 	}
+	if val == "-" {
+		return "", false
+	}
 	opts := strings.Split(val, ",")
 	omit := false
-	if len(opts) == 2 {
-		omit = opts[1] == "omitempty"
+	for _, opt := range opts[1:] {
+		if opt == "omitempty" {
+			omit = true
+		}
+	}
+	if opts[0] == "" {
+		return f.Name, omit
 	}
 	return opts[0], omit
 }
 
+// readTagOption returns the value of a "key=value" option of the given struct
+// tag, or "" if it isn't set
+func readTagOption(f reflect.StructField, tagName, key string) string {
+	val, ok := f.Tag.Lookup(tagName)
+	if !ok {
+		return ""
+	}
+	for _, opt := range strings.Split(val, ",")[1:] {
+		if strings.HasPrefix(opt, key+"=") {
+			return opt[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+// readTagFlag returns true if the given struct tag has the given option, e.g.
+// "remain" in `ldap:",remain"`
+func readTagFlag(f reflect.StructField, tagName, flag string) bool {
+	val, ok := f.Tag.Lookup(tagName)
+	if !ok {
+		return false
+	}
+	for _, opt := range strings.Split(val, ",")[1:] {
+		if opt == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBoolValue parses an attribute value of the Boolean syntax
+func parseBoolValue(value string) (bool, error) {
+	switch {
+	case strings.EqualFold(value, "TRUE"):
+		return true, nil
+	case strings.EqualFold(value, "FALSE"):
+		return false, nil
+	}
+	return false, fmt.Errorf("ldap: could not parse value '%s' into bool field", value)
+}
+
+// parseTimeValue parses an attribute value as GeneralizedTime, or with the
+// given layout if it is not empty
+func parseTimeValue(value string, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, value)
+	}
+	return ParseGeneralizedTime(value)
+}
+
 // Unmarshal parses the Entry in the value pointed to by i
 //
-// Currently, this methods only supports struct fields of type
-// string, []string, int, int64 or []byte. Other field types will not be
-// regarded. If the field type is a string or int but multiple attribute
+// Currently, this methods only supports struct fields of type string,
+// []string, signed and unsigned integers, float32, float64, bool, []byte,
+// time.Time, [][]byte, or pointers or slices of any of them. Other field types
+// will not be regarded. If the field type is a string or number but multiple attribute
 // values are returned, the first value will be used to fill the field.
+// Fields of embedded and nested structs are filled as if they were fields of
+// the outer struct, so common groups of attributes can be shared. A nil
+// pointer to a nested struct is only set if any of its attributes is present.
+//
+// A map[string][]string or map[string]string field tagged with the remain
+// option receives all attributes not bound to other fields. i may also point
+// to such a map, which is then filled with all attributes of the entry.
+//
+// Use a Decoder to read a struct tag other than "ldap", or for strict or
+// case-sensitive matching of attributes.
+// Pointer fields are left nil if the attribute is missing, so they can be used
+// to tell a missing attribute from one with a zero value. Fields of types
+// implementing Unmarshaler decode the raw attribute values themselves.
+// interface{} fields receive the values decoded according to the syntax given
+// by the syntax option, e.g. `ldap:"uidNumber,syntax=Integer"`, see
+// DecodeSyntaxValue.
 //
 // Example:
 //	type UserEntry struct {
@@ -198,6 +311,7 @@ func readTag(f reflect.StructField) (string, bool) {
 //		// This field will be filled with the attribute value for
 //		// userPrincipalName. An attribute can be read into a struct field
 //		// multiple times. Missing attributes will not result in an error.
+//		// Attribute names are matched case-insensitively.
 //		UserPrincipalName string `ldap:"userPrincipalName"`
 //
 //		// memberOf may have multiple values. If you don't
@@ -211,74 +325,413 @@ func readTag(f reflect.StructField) (string, bool) {
 //		// LongID is similar to ID but uses an int64 instead.
 //		LongID int64 `ldap:"longId"`
 //
+//		// Unsigned integer and float fields work the same way. Values which
+//		// are negative or out of range for the field type fail unmarshaling.
+//		UserAccountControl uint32 `ldap:"userAccountControl"`
+//		Quota float64 `ldap:"quota"`
+//
+//		// Boolean syntax values "TRUE" and "FALSE" are parsed
+//		// case-insensitively, other values fail unmarshaling.
+//		Locked bool `ldap:"locked"`
+//
 //		// Data is similar to MemberOf a slice containing all attribute
 //		// values.
 //		Data []byte `ldap:"data"`
 //
-//		// This won't work, as the field is not of type string. For this
-//		// to work, you'll have to temporarily store the result in string
-// 		// (or string array) and convert it to the desired type afterwards.
-//		UserAccountControl uint32 `ldap:"userPrincipalName"`
+//		// Slices of the other supported types hold all attribute values,
+//		// e.g. all certificates or all numbers of a multi-valued attribute.
+//		Certificates [][]byte `ldap:"userCertificate;binary"`
+//		Ports []int `ldap:"port"`
+//
+//		// Time fields are parsed from GeneralizedTime values, including
+//		// fractional seconds and time zone offsets. A different time.Parse
+//		// layout can be given with the layout option.
+//		Created time.Time `ldap:"createTimestamp"`
+//		Expires *time.Time `ldap:"expires,layout=2006-01-02"`
+//
+//		// Pointer fields stay nil if the attribute is missing.
+//		EmployeeNumber *int `ldap:"employeeNumber"`
+//
+//		// The fields of nested structs are filled from the entry's
+//		// attributes, e.g. createTimestamp and modifyTimestamp.
+//		Audit AuditFields
+//
+//		// Other receives all attributes not bound to any other field.
+//		Other map[string][]string `ldap:",remain"`
 //	}
 //	user := UserEntry{}
 //	if err := result.Unmarshal(&user); err != nil {
 //		// ...
 //	}
 func (e *Entry) Unmarshal(i interface{}) (err error) {
+	return NewDecoder().Decode(e, i)
+}
+
+// Decoder fills Go values from entries like Entry.Unmarshal, with configurable
+// struct tags and matching rules. A Decoder is safe for concurrent use.
+type Decoder struct {
+	tagName         string
+	strict          bool
+	caseInsensitive bool
+}
+
+// DecoderOpt configures a Decoder.
+type DecoderOpt func(*Decoder)
+
+// DecodeWithTagName sets the struct tag key read by the decoder, e.g. "json"
+// to reuse existing struct definitions. The default is "ldap".
+func DecodeWithTagName(tagName string) DecoderOpt {
+	return func(d *Decoder) {
+		d.tagName = tagName
+	}
+}
+
+// DecodeWithStrict makes decoding fail if the entry has an attribute which is
+// not bound to any field, unless the struct has a field tagged with the
+// remain option.
+func DecodeWithStrict(strict bool) DecoderOpt {
+	return func(d *Decoder) {
+		d.strict = strict
+	}
+}
+
+// DecodeWithCaseInsensitive sets whether attribute names are matched to
+// struct tags case-insensitively, as attribute names are case-insensitive in
+// LDAP. This is the default, servers may e.g. return "objectclass" for a
+// field tagged "objectClass".
+func DecodeWithCaseInsensitive(caseInsensitive bool) DecoderOpt {
+	return func(d *Decoder) {
+		d.caseInsensitive = caseInsensitive
+	}
+}
+
+// NewDecoder returns a Decoder configured by the given options.
+func NewDecoder(opts ...DecoderOpt) *Decoder {
+	d := &Decoder{tagName: decoderTagName, caseInsensitive: true}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Decode parses the Entry in the value pointed to by i, see Entry.Unmarshal.
+func (d *Decoder) Decode(e *Entry, i interface{}) error {
 	// Make sure it's a ptr
 	if vo := reflect.ValueOf(i).Kind(); vo != reflect.Ptr {
 		return fmt.Errorf("ldap: cannot use %s, expected pointer to a struct", vo)
 	}
 
-	sv, st := reflect.ValueOf(i).Elem(), reflect.TypeOf(i).Elem()
+	sv := reflect.ValueOf(i).Elem()
+	if sv.Kind() == reflect.Map {
+		return d.decodeRemaining(e, sv, nil)
+	}
 	// Make sure it's pointing to a struct
 	if sv.Kind() != reflect.Struct {
-		return fmt.Errorf("ldap: expected pointer to a struct, got %s", sv.Kind())
+		return fmt.Errorf("ldap: expected pointer to a struct or map, got %s", sv.Kind())
 	}
 
+	bound := map[string]bool{}
+	var remain []reflect.Value
+	if _, err := d.decodeStruct(e, sv, bound, &remain); err != nil {
+		return err
+	}
+	for _, mv := range remain {
+		if err := d.decodeRemaining(e, mv, bound); err != nil {
+			return err
+		}
+	}
+	if d.strict && len(remain) == 0 {
+		for _, attr := range e.Attributes {
+			if !bound[d.attributeKey(attr.Name)] {
+				return fmt.Errorf("ldap: no field for attribute %q", attr.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// attributeKey returns the key identifying the named attribute in the set of
+// bound attributes
+func (d *Decoder) attributeKey(name string) string {
+	if d.caseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// decodeStruct fills the fields of the struct sv. Embedded and nested
+// structs are filled recursively, sharing the attribute namespace of sv.
+// The names of all attributes bound to fields are added to bound, fields
+// tagged with the remain option are added to remain. It returns true if any
+// attribute of the struct is present, a remain field counts as present.
+func (d *Decoder) decodeStruct(e *Entry, sv reflect.Value, bound map[string]bool, remain *[]reflect.Value) (bool, error) {
+	found := false
+	st := sv.Type()
 	for n := 0; n < st.NumField(); n++ {
 		// Holds struct field value and type
 		fv, ft := sv.Field(n), st.Field(n)
 
+		// omitempty can be safely discarded, as it's not needed when unmarshalling
+		fieldTag, _ := readTag(ft, d.tagName)
+		if fieldTag == "" {
+			continue
+		}
+
+		if isNestedStruct(ft.Type) {
+			// exported fields of embedded unexported structs are promoted, so
+			// they are filled as well
+			if ft.PkgPath != "" && !(ft.Anonymous && fv.Kind() == reflect.Struct) {
+				continue
+			}
+			// a nil pointer is filled through a new struct, which is only
+			// set if any of its attributes is present
+			target := fv
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					target = reflect.New(ft.Type.Elem())
+				}
+				target = target.Elem()
+			}
+			nestedFound, err := d.decodeStruct(e, target, bound, remain)
+			if err != nil {
+				return false, err
+			}
+			if nestedFound && fv.Kind() == reflect.Ptr && fv.IsNil() {
+				fv.Set(target.Addr())
+			}
+			found = found || nestedFound
+			continue
+		}
+
 		// skip unexported fields
 		if ft.PkgPath != "" {
 			continue
 		}
 
-		// omitempty can be safely discarded, as it's not needed when unmarshalling
-		fieldTag, _ := readTag(ft)
+		if readTagFlag(ft, d.tagName, "remain") {
+			*remain = append(*remain, fv)
+			found = true
+			continue
+		}
 
 		// Fill the field with the distinguishedName if the tag key is `dn`
 		if fieldTag == "dn" {
 			fv.SetString(e.DN)
 			continue
 		}
+		bound[d.attributeKey(fieldTag)] = true
 
-		values := e.GetAttributeValues(fieldTag)
+		values, raw := e.GetAttributeValues(fieldTag), e.GetRawAttributeValues(fieldTag)
+		if d.caseInsensitive {
+			values, raw = e.GetEqualFoldAttributeValues(fieldTag), e.GetEqualFoldRawAttributeValues(fieldTag)
+		}
 		if len(values) == 0 {
 			continue
 		}
+		found = true
 
-		switch fv.Interface().(type) {
-		case []string:
-			for _, item := range values {
-				fv.Set(reflect.Append(fv, reflect.ValueOf(item)))
+		if err := d.decodeField(fv, ft, values, raw); err != nil {
+			return false, err
+		}
+	}
+	return found, nil
+}
+
+// decodeRemaining fills the map mv with all attributes of the entry which
+// are not bound to a struct field. mv must be a map[string][]string or a
+// map[string]string, the latter receiving the first value of each attribute.
+func (d *Decoder) decodeRemaining(e *Entry, mv reflect.Value, bound map[string]bool) error {
+	mt := mv.Type()
+	if mt.Kind() != reflect.Map || mt.Key().Kind() != reflect.String ||
+		(mt.Elem() != reflect.TypeOf([]string{}) && mt.Elem().Kind() != reflect.String) {
+		return fmt.Errorf("ldap: expected map[string][]string or map[string]string, got %v", mt)
+	}
+	if mv.IsNil() {
+		mv.Set(reflect.MakeMap(mt))
+	}
+	for _, attr := range e.Attributes {
+		if bound[d.attributeKey(attr.Name)] {
+			continue
+		}
+		values := attr.StringValues()
+		var value reflect.Value
+		if mt.Elem().Kind() == reflect.String {
+			if len(values) == 0 {
+				continue
 			}
-		case string:
-			fv.SetString(values[0])
-		case []byte:
-			fv.SetBytes([]byte(values[0]))
-		case int, int64:
-			intVal, err := strconv.ParseInt(values[0], 10, 64)
-			if err != nil {
-				return fmt.Errorf("ldap: could not parse value '%s' into int field", values[0])
+			value = reflect.ValueOf(values[0]).Convert(mt.Elem())
+		} else {
+			value = reflect.ValueOf(append([]string(nil), values...))
+		}
+		mv.SetMapIndex(reflect.ValueOf(attr.Name).Convert(mt.Key()), value)
+	}
+	return nil
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// isNestedStruct returns true if the field type t is a struct, or a pointer
+// to one, whose fields should be filled instead of the field itself
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	return !reflect.PtrTo(t).Implements(unmarshalerType)
+}
+
+// decodeField fills the struct field fv with the given attribute values
+func (d *Decoder) decodeField(fv reflect.Value, ft reflect.StructField, values []string, raw [][]byte) error {
+	if fv.CanAddr() {
+		if u, ok := fv.Addr().Interface().(Unmarshaler); ok {
+			return u.UnmarshalLDAP(raw)
+		}
+	}
+	if fv.Kind() == reflect.Ptr {
+		// pointers are only allocated for attributes which are present, so
+		// missing attributes can be told apart from zero values
+		pv := reflect.New(fv.Type().Elem())
+		if err := d.decodeField(pv.Elem(), ft, values, raw); err != nil {
+			return err
+		}
+		fv.Set(pv)
+		return nil
+	}
+
+	if fv.Kind() == reflect.Interface && fv.NumMethod() == 0 {
+		return d.decodeSyntaxField(fv, ft, raw)
+	}
+
+	switch fv.Interface().(type) {
+	case []string:
+		for _, item := range values {
+			fv.Set(reflect.Append(fv, reflect.ValueOf(item)))
+		}
+	case string:
+		fv.SetString(values[0])
+	case []byte:
+		fv.SetBytes([]byte(values[0]))
+	case [][]byte:
+		for _, item := range raw {
+			fv.Set(reflect.Append(fv, reflect.ValueOf(append([]byte(nil), item...))))
+		}
+	case int, int8, int16, int32, int64:
+		intVal, err := strconv.ParseInt(values[0], 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ldap: could not parse value '%s' into int field", values[0])
+		}
+		fv.SetInt(intVal)
+	case uint, uint8, uint16, uint32, uint64:
+		uintVal, err := strconv.ParseUint(values[0], 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ldap: could not parse value '%s' into %v field: %s", values[0], fv.Type(), err)
+		}
+		fv.SetUint(uintVal)
+	case float32, float64:
+		floatVal, err := strconv.ParseFloat(values[0], fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ldap: could not parse value '%s' into %v field: %s", values[0], fv.Type(), err)
+		}
+		fv.SetFloat(floatVal)
+	case bool:
+		boolVal, err := parseBoolValue(values[0])
+		if err != nil {
+			return err
+		}
+		fv.SetBool(boolVal)
+	case time.Time:
+		t, err := parseTimeValue(values[0], readTagOption(ft, d.tagName, "layout"))
+		if err != nil {
+			return fmt.Errorf("ldap: could not parse value '%s' into time field: %s", values[0], err)
+		}
+		fv.Set(reflect.ValueOf(t))
+	default:
+		if fv.Kind() == reflect.Slice {
+			// fill slices of the other supported types value by value
+			for n, value := range values {
+				var rawValue [][]byte
+				if n < len(raw) {
+					rawValue = [][]byte{raw[n]}
+				}
+				ev := reflect.New(fv.Type().Elem()).Elem()
+				if err := d.decodeField(ev, ft, []string{value}, rawValue); err != nil {
+					return err
+				}
+				fv.Set(reflect.Append(fv, ev))
 			}
-			fv.SetInt(intVal)
-		default:
-			return fmt.Errorf("ldap: expected field to be of type string, []string, int, int64 or []byte, got %v", ft.Type)
+			return nil
+		}
+		return fmt.Errorf("ldap: expected field to be of type string, []string, int, uint, float, bool, []byte, [][]byte, time.Time or a pointer or slice of them, got %v", ft.Type)
+	}
+	return nil
+}
+
+// decodeEntry returns the entry of a SearchResultEntry response
+func decodeEntry(packet *ber.Packet, decoding valueDecoding) (*Entry, error) {
+	if len(packet.Children) < 2 {
+		return nil, invalidResponse("search result entry", "missing protocol operation")
+	}
+	op := packet.Children[1]
+	if len(op.Children) != 2 {
+		return nil, invalidResponse("search result entry", fmt.Sprintf("expected object name and attributes, got %d elements", len(op.Children)))
+	}
+	dn, ok := op.Children[0].Value.(string)
+	if !ok {
+		return nil, invalidResponse("search result entry", "object name is not a string")
+	}
+	attributes, err := decodeAttributes(op.Children[1].Children, decoding)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{DN: dn, Attributes: attributes}, nil
+}
+
+// decodeReference returns the first URI of a SearchResultReference response
+func decodeReference(packet *ber.Packet) (string, error) {
+	if len(packet.Children) < 2 {
+		return "", invalidResponse("search result reference", "missing protocol operation")
+	}
+	op := packet.Children[1]
+	if len(op.Children) == 0 {
+		return "", invalidResponse("search result reference", "missing URI")
+	}
+	uri, ok := op.Children[0].Value.(string)
+	if !ok {
+		return "", invalidResponse("search result reference", "URI is not a string")
+	}
+	return uri, nil
+}
+
+// invalidResponse returns the error for a malformed response
+func invalidResponse(response, reason string) error {
+	return NewError(ErrorUnexpectedResponse, fmt.Errorf("ldap: invalid %s: %s", response, reason))
+}
+
+// decodeSyntaxField fills an interface{} field with the values decoded
+// according to the "syntax" option of the field, e.g.
+// `ldap:"uidNumber,syntax=Integer"`. Multiple values are stored as a
+// []interface{}.
+func (d *Decoder) decodeSyntaxField(fv reflect.Value, ft reflect.StructField, raw [][]byte) error {
+	syntax := readTagOption(ft, d.tagName, "syntax")
+	decoded := make([]interface{}, 0, len(raw))
+	for _, value := range raw {
+		v, err := DecodeSyntaxValue(syntax, value)
+		if err != nil {
+			return err
 		}
+		decoded = append(decoded, v)
+	}
+	if len(decoded) == 1 {
+		fv.Set(reflect.ValueOf(&decoded[0]).Elem())
+		return nil
 	}
-	return
+	fv.Set(reflect.ValueOf(decoded))
+	return nil
 }
 
 // NewEntryAttribute returns a new EntryAttribute with the desired key-value pair
@@ -298,20 +751,43 @@ func NewEntryAttribute(name string, values []string) *EntryAttribute {
 type EntryAttribute struct {
 	// Name is the name of the attribute
 	Name string
-	// Values contain the string values of the attribute
+	// Values contain the string values of the attribute. They are nil for
+	// attributes decoded with SearchRequest.LazyValues, use StringValues to
+	// read them in any case.
 	Values []string
 	// ByteValues contain the raw values of the attribute
 	ByteValues [][]byte
+	// IsBinary is set for decoded attributes with values which are not valid
+	// UTF-8. Their Values hold the string view selected by
+	// SearchRequest.BinaryValues, by default none.
+	IsBinary bool
 }
 
 // Print outputs a human-readable description
 func (e *EntryAttribute) Print() {
-	fmt.Printf("%s: %s\n", e.Name, e.Values)
+	e.PrintTo(os.Stdout)
 }
 
 // PrettyPrint outputs a human-readable description with indenting
 func (e *EntryAttribute) PrettyPrint(indent int) {
-	fmt.Printf("%s%s: %s\n", strings.Repeat(" ", indent), e.Name, e.Values)
+	e.PrettyPrintTo(os.Stdout, indent)
+}
+
+// PrintTo writes a human-readable description to w
+func (e *EntryAttribute) PrintTo(w io.Writer) {
+	fmt.Fprintf(w, "%s: %s\n", e.Name, e.StringValues())
+}
+
+// PrettyPrintTo writes a human-readable description with indenting to w
+func (e *EntryAttribute) PrettyPrintTo(w io.Writer, indent int) {
+	fmt.Fprintf(w, "%s%s: %s\n", strings.Repeat(" ", indent), e.Name, e.StringValues())
+}
+
+// String returns the human-readable description written by Print
+func (e *EntryAttribute) String() string {
+	var sb strings.Builder
+	e.PrintTo(&sb)
+	return sb.String()
 }
 
 // SearchResult holds the server's response to a search request
@@ -322,22 +798,44 @@ type SearchResult struct {
 	Referrals []string
 	// Controls are the returned controls
 	Controls []Control
+	// Partial is set if the search ended with a size, time or administrative
+	// limit being exceeded and SearchRequest.AllowPartialResults was set
+	Partial bool
+	// PartialResultCode is the result code which ended a partial search
+	PartialResultCode uint16
 }
 
 // Print outputs a human-readable description
 func (s *SearchResult) Print() {
-	for _, entry := range s.Entries {
-		entry.Print()
-	}
+	s.PrintTo(os.Stdout)
 }
 
 // PrettyPrint outputs a human-readable description with indenting
 func (s *SearchResult) PrettyPrint(indent int) {
+	s.PrettyPrintTo(os.Stdout, indent)
+}
+
+// PrintTo writes a human-readable description to w
+func (s *SearchResult) PrintTo(w io.Writer) {
 	for _, entry := range s.Entries {
-		entry.PrettyPrint(indent)
+		entry.PrintTo(w)
 	}
 }
 
+// PrettyPrintTo writes a human-readable description with indenting to w
+func (s *SearchResult) PrettyPrintTo(w io.Writer, indent int) {
+	for _, entry := range s.Entries {
+		entry.PrettyPrintTo(w, indent)
+	}
+}
+
+// String returns the human-readable description written by Print
+func (s *SearchResult) String() string {
+	var sb strings.Builder
+	s.PrintTo(&sb)
+	return sb.String()
+}
+
 // SearchRequest represents a search request to send to the server
 type SearchRequest struct {
 	BaseDN       string
@@ -349,6 +847,39 @@ type SearchRequest struct {
 	Filter       string
 	Attributes   []string
 	Controls     []Control
+	// AllowPartialResults makes searches ending with a size, time or
+	// administrative limit being exceeded return the entries received so
+	// far with SearchResult.Partial set, instead of an error
+	AllowPartialResults bool
+	// DisableRangeRetrieval turns off fetching the remaining values of
+	// attributes returned with a range option like "member;range=0-1499",
+	// see Conn.Search
+	DisableRangeRetrieval bool
+	// BinaryValues selects the Values of attributes with values which are
+	// not valid UTF-8, see EntryAttribute.IsBinary
+	BinaryValues BinaryValueMode
+	// LazyValues keeps only the ByteValues of the returned attributes,
+	// leaving their Values nil. The string values are converted on access by
+	// EntryAttribute.StringValues and the getters of Entry, which halves the
+	// memory held by large results that are mostly not read as strings.
+	LazyValues bool
+	// MaxEntries and MaxResultBytes limit the number of entries and the size
+	// of the DNs, attribute names, values and referrals collected by Search
+	// and SearchWithPaging, 0 meaning no limit. Unlike SizeLimit they are
+	// enforced by the client: once one is exceeded, the search is abandoned
+	// and a *ResultLimitError is returned with the results received so far.
+	MaxEntries     int
+	MaxResultBytes int64
+}
+
+// valueDecoding selects how the values of decoded attributes are held
+type valueDecoding struct {
+	binary BinaryValueMode
+	lazy   bool
+}
+
+func (req *SearchRequest) valueDecoding() valueDecoding {
+	return valueDecoding{binary: req.BinaryValues, lazy: req.LazyValues}
 }
 
 func (req *SearchRequest) appendTo(envelope *ber.Packet) error {
@@ -411,29 +942,24 @@ func NewSearchRequest(
 //  - given SearchRequest contains a control of type ControlTypePaging with pagingSize not equal to the size requested: fail without issuing any queries
 // A requested pagingSize of 0 is interpreted as no limit by LDAP servers.
 func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
-	var pagingControl *ControlPaging
-
-	control := FindControl(searchRequest.Controls, ControlTypePaging)
-	if control == nil {
-		pagingControl = NewControlPaging(pagingSize)
-		searchRequest.Controls = append(searchRequest.Controls, pagingControl)
-	} else {
-		castControl, ok := control.(*ControlPaging)
-		if !ok {
-			return nil, fmt.Errorf("expected paging control to be of type *ControlPaging, got %v", control)
-		}
-		if castControl.PagingSize != pagingSize {
-			return nil, fmt.Errorf("paging size given in search request (%d) conflicts with size given in search call (%d)", castControl.PagingSize, pagingSize)
-		}
-		pagingControl = castControl
+	pagingControl, err := pagingControlFor(searchRequest, pagingSize)
+	if err != nil {
+		return nil, err
 	}
 
 	searchResult := new(SearchResult)
+	// the MaxEntries and MaxResultBytes of the request apply to all pages
+	budget := newResultBudget(searchRequest)
 	for {
-		result, err := l.Search(searchRequest)
+		result, err := l.search(context.Background(), searchRequest, budget)
 		l.Debug.Printf("Looking for Paging Control...")
+		var limitErr *ResultLimitError
+		if errors.As(err, &limitErr) {
+			searchResult.Entries = append(searchResult.Entries, result.Entries...)
+			searchResult.Referrals = append(searchResult.Referrals, result.Referrals...)
+		}
 		if err != nil {
-			return searchResult, err
+			return searchResult, sessionLost(err, pagingControl.Cookie)
 		}
 		if result == nil {
 			return searchResult, NewError(ErrorNetwork, errors.New("ldap: packet not received"))
@@ -442,6 +968,10 @@ func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32)
 		searchResult.Entries = append(searchResult.Entries, result.Entries...)
 		searchResult.Referrals = append(searchResult.Referrals, result.Referrals...)
 		searchResult.Controls = append(searchResult.Controls, result.Controls...)
+		if result.Partial {
+			searchResult.Partial = true
+			searchResult.PartialResultCode = result.PartialResultCode
+		}
 
 		l.Debug.Printf("Looking for Paging Control...")
 		pagingResult := FindControl(result.Controls, ControlTypePaging)
@@ -471,13 +1001,37 @@ func (l *Conn) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32)
 	return searchResult, nil
 }
 
-// Search performs the given search request
+// Search performs the given search request. Attributes which Active
+// Directory returns in ranges, e.g. "member;range=0-1499" for large groups,
+// are completed with follow-up searches and returned under their plain name
+// unless DisableRangeRetrieval is set.
 func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
-	msgCtx, err := l.doRequest(searchRequest)
+	return l.SearchContext(context.Background(), searchRequest)
+}
+
+// SearchContext performs the given search request. The correlation ID carried by ctx, see
+// WithCorrelationID, is attached to the operation and any returned error.
+func (l *Conn) SearchContext(ctx context.Context, searchRequest *SearchRequest) (_ *SearchResult, err error) {
+	defer correlateError(ctx, &err)
+	return l.search(ctx, searchRequest, newResultBudget(searchRequest))
+}
+
+// search performs the search request, collecting the results within budget
+func (l *Conn) search(ctx context.Context, searchRequest *SearchRequest, budget *resultBudget) (*SearchResult, error) {
+	msgCtx, err := l.doRequestContext(ctx, searchRequest)
 	if err != nil {
 		return nil, err
 	}
-	defer l.finishMessage(msgCtx)
+	abandon := false
+	defer func() {
+		l.finishMessage(msgCtx)
+		if !abandon {
+			return
+		}
+		if err := l.abandon(msgCtx.id); err != nil {
+			l.Debug.Printf("%d: failed to abandon search: %s", msgCtx.id, err)
+		}
+	}()
 
 	result := &SearchResult{
 		Entries:   make([]*Entry, 0),
@@ -493,52 +1047,266 @@ func (l *Conn) Search(searchRequest *SearchRequest) (*SearchResult, error) {
 
 		switch packet.Children[1].Tag {
 		case 4:
-			entry := &Entry{
-				DN:         packet.Children[1].Children[0].Value.(string),
-				Attributes: unpackAttributes(packet.Children[1].Children[1].Children),
+			entry, err := decodeEntry(packet, searchRequest.valueDecoding())
+			if err != nil {
+				return result, err
+			}
+			if err := budget.addEntry(entry); err != nil {
+				abandon = true
+				return result, err
 			}
 			result.Entries = append(result.Entries, entry)
 		case 5:
 			err := GetLDAPError(packet)
+			if err != nil {
+				if !searchRequest.AllowPartialResults || !isLimitExceeded(err) {
+					return result, err
+				}
+				result.Partial = true
+				var ldapErr *Error
+				if errors.As(err, &ldapErr) {
+					result.PartialResultCode = ldapErr.ResultCode
+				}
+			}
+			controls, err := decodeResponseControls(packet)
 			if err != nil {
 				return result, err
 			}
-			if len(packet.Children) == 3 {
-				for _, child := range packet.Children[2].Children {
-					decodedChild, err := DecodeControl(child)
-					if err != nil {
-						return result, fmt.Errorf("failed to decode child control: %s", err)
-					}
-					result.Controls = append(result.Controls, decodedChild)
+			result.Controls = append(result.Controls, controls...)
+			if !searchRequest.DisableRangeRetrieval {
+				if err := l.completeRangedAttributes(ctx, result.Entries, searchRequest.valueDecoding()); err != nil {
+					return result, err
 				}
 			}
 			return result, nil
 		case 19:
-			result.Referrals = append(result.Referrals, packet.Children[1].Children[0].Value.(string))
+			referral, err := decodeReference(packet)
+			if err != nil {
+				return result, err
+			}
+			if err := budget.addBytes(int64(len(referral))); err != nil {
+				abandon = true
+				return result, err
+			}
+			result.Referrals = append(result.Referrals, referral)
 		}
 	}
 }
 
-// unpackAttributes will extract all given LDAP attributes and it's values
-// from the ber.Packet
-func unpackAttributes(children []*ber.Packet) []*EntryAttribute {
+// decodeAttributes will extract all given LDAP attributes and it's values
+// from the ber.Packet. Attributes with values which are not valid UTF-8 get
+// the string view selected by mode.
+//
+// The attributes and their value slices are carved out of a few allocations
+// per entry, as large results otherwise spend most of their time allocating.
+// The slices are capped, so appending to them does not clobber the values of
+// the next attribute.
+func decodeAttributes(children []*ber.Packet, decoding valueDecoding) ([]*EntryAttribute, error) {
+	total := 0
+	for _, child := range children {
+		if len(child.Children) != 2 {
+			return nil, invalidResponse("search result entry", fmt.Sprintf("expected attribute type and values, got %d elements", len(child.Children)))
+		}
+		total += len(child.Children[1].Children)
+	}
+
+	attributes := make([]EntryAttribute, len(children))
 	entries := make([]*EntryAttribute, len(children))
+	byteValues := make([][]byte, total)
+	var values []string
+	if !decoding.lazy {
+		values = make([]string, total)
+	}
 	for i, child := range children {
+		name, ok := child.Children[0].Value.(string)
+		if !ok {
+			return nil, invalidResponse("search result entry", "attribute type is not a string")
+		}
 		length := len(child.Children[1].Children)
-		entry := &EntryAttribute{
-			Name: child.Children[0].Value.(string),
-			// pre-allocate the slice since we can determine
-			// the number of attributes at this point
-			Values:     make([]string, length),
-			ByteValues: make([][]byte, length),
+		entry := &attributes[i]
+		entry.Name = name
+		entry.ByteValues, byteValues = byteValues[:length:length], byteValues[length:]
+		if !decoding.lazy {
+			entry.Values, values = values[:length:length], values[length:]
 		}
 
-		for i, value := range child.Children[1].Children {
-			entry.ByteValues[i] = value.ByteValue
-			entry.Values[i] = value.Value.(string)
+		for j, value := range child.Children[1].Children {
+			s, ok := value.Value.(string)
+			if !ok {
+				return nil, invalidResponse("search result entry", fmt.Sprintf("value of attribute %q is not a string", name))
+			}
+			entry.ByteValues[j] = value.ByteValue
+			if !decoding.lazy {
+				entry.Values[j] = s
+			}
+			if !entry.IsBinary && !utf8.Valid(value.ByteValue) {
+				entry.IsBinary = true
+			}
+		}
+		if entry.IsBinary {
+			// the string view of binary values is selected up front
+			entry.Values = binaryStringValues(entry.ByteValues, decoding.binary)
 		}
 		entries[i] = entry
 	}
 
-	return entries
+	return entries, nil
+}
+
+// isLimitExceeded returns true if err is caused by a size, time or
+// administrative limit being exceeded
+func isLimitExceeded(err error) bool {
+	return IsErrorAnyOf(err, LDAPResultSizeLimitExceeded, LDAPResultTimeLimitExceeded, LDAPResultAdminLimitExceeded)
+}
+
+// ResultLimitError is returned by searches collecting more results than
+// allowed by the MaxEntries or MaxResultBytes of the SearchRequest, e.g.
+//
+//	var limitErr *ldap.ResultLimitError
+//	if errors.As(err, &limitErr) {
+//		...
+//	}
+type ResultLimitError struct {
+	// Limit is the name of the exceeded limit, "MaxEntries" or
+	// "MaxResultBytes"
+	Limit string
+	// Max is the value of the exceeded limit
+	Max int64
+}
+
+func (e *ResultLimitError) Error() string {
+	return fmt.Sprintf("ldap: search result exceeds %s of %d", e.Limit, e.Max)
+}
+
+// resultBudget tracks the results collected by a search against the
+// MaxEntries and MaxResultBytes of its SearchRequest
+type resultBudget struct {
+	maxEntries int
+	maxBytes   int64
+	entries    int
+	bytes      int64
+}
+
+func newResultBudget(req *SearchRequest) *resultBudget {
+	return &resultBudget{maxEntries: req.MaxEntries, maxBytes: req.MaxResultBytes}
+}
+
+// addEntry accounts for a collected entry, returning a *ResultLimitError if
+// it exceeds a limit
+func (b *resultBudget) addEntry(entry *Entry) error {
+	b.entries++
+	if b.maxEntries > 0 && b.entries > b.maxEntries {
+		return &ResultLimitError{Limit: "MaxEntries", Max: int64(b.maxEntries)}
+	}
+	size := int64(len(entry.DN))
+	for _, attribute := range entry.Attributes {
+		size += int64(len(attribute.Name))
+		for _, value := range attribute.ByteValues {
+			size += int64(len(value))
+		}
+	}
+	return b.addBytes(size)
+}
+
+// addBytes accounts for size bytes of collected results, returning a
+// *ResultLimitError if they exceed MaxResultBytes
+func (b *resultBudget) addBytes(size int64) error {
+	b.bytes += size
+	if b.maxBytes > 0 && b.bytes > b.maxBytes {
+		return &ResultLimitError{Limit: "MaxResultBytes", Max: b.maxBytes}
+	}
+	return nil
+}
+
+// ErrStopSearch can be returned by the entry callback of SearchWithCallback to
+// stop the search early without SearchWithCallback returning an error
+var ErrStopSearch = errors.New("ldap: search stopped")
+
+// SearchWithCallback performs the given search request, passing the results
+// to the given callbacks as they arrive instead of collecting them. onEntry is
+// called for every entry, onReferral with the URIs of every search result
+// reference and onControls with the controls of the final response. Any of
+// them may be nil.
+//
+// If onEntry returns an error, the search is abandoned on the server and the
+// error is returned, unless it is ErrStopSearch.
+func (l *Conn) SearchWithCallback(searchRequest *SearchRequest, onEntry func(*Entry) error, onReferral func([]string), onControls func([]Control)) error {
+	msgCtx, err := l.doRequest(searchRequest)
+	if err != nil {
+		return err
+	}
+	abandon := false
+	defer func() {
+		// the search must be finished first, as pending responses would
+		// otherwise block sending the abandon request
+		l.finishMessage(msgCtx)
+		if !abandon {
+			return
+		}
+		if err := l.abandon(msgCtx.id); err != nil {
+			l.Debug.Printf("%d: failed to abandon search: %s", msgCtx.id, err)
+		}
+	}()
+
+	for {
+		packet, err := l.readPacket(msgCtx)
+		if err != nil {
+			return err
+		}
+
+		switch packet.Children[1].Tag {
+		case ApplicationSearchResultEntry:
+			if onEntry == nil {
+				continue
+			}
+			entry, err := decodeEntry(packet, searchRequest.valueDecoding())
+			if err != nil {
+				abandon = true
+				return err
+			}
+			if err := onEntry(entry); err != nil {
+				abandon = true
+				if errors.Is(err, ErrStopSearch) {
+					return nil
+				}
+				return err
+			}
+		case ApplicationSearchResultReference:
+			if onReferral == nil {
+				continue
+			}
+			var referrals []string
+			for _, child := range packet.Children[1].Children {
+				if uri, ok := child.Value.(string); ok {
+					referrals = append(referrals, uri)
+				}
+			}
+			onReferral(referrals)
+		case ApplicationSearchResultDone:
+			if onControls != nil && len(packet.Children) == 3 {
+				controls, err := decodeResponseControls(packet)
+				if err != nil {
+					return err
+				}
+				onControls(controls)
+			}
+			return GetLDAPError(packet)
+		}
+	}
+}
+
+// decodeResponseControls returns the controls of a response packet
+func decodeResponseControls(packet *ber.Packet) ([]Control, error) {
+	if len(packet.Children) != 3 {
+		return nil, nil
+	}
+	var controls []Control
+	for _, child := range packet.Children[2].Children {
+		decodedChild, err := DecodeControl(child)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode child control: %s", err)
+		}
+		controls = append(controls, decodedChild)
+	}
+	return controls, nil
 }
@@ -0,0 +1,33 @@
+//go:build go1.23
+// +build go1.23
+
+package ldap
+
+import (
+	"iter"
+)
+
+// SearchIter returns an iterator performing the given search request and
+// yielding the entries as they arrive from the server. Errors are yielded
+// with a nil entry and end the iteration. Breaking out of the loop early
+// abandons the search on the server. Search result references are skipped.
+//
+//	for entry, err := range l.SearchIter(searchRequest) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (l *Conn) SearchIter(searchRequest *SearchRequest) iter.Seq2[*Entry, error] {
+	return func(yield func(*Entry, error) bool) {
+		err := l.SearchWithCallback(searchRequest, func(entry *Entry) error {
+			if !yield(entry, nil) {
+				return ErrStopSearch
+			}
+			return nil
+		}, nil, nil)
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
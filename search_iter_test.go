@@ -0,0 +1,71 @@
+//go:build go1.23
+// +build go1.23
+
+package ldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+func TestSearchIter(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com")
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	var dns []string
+	for entry, err := range conn.SearchIter(searchRequest) {
+		if err != nil {
+			t.Fatalf("search failed: %s", err)
+		}
+		dns = append(dns, entry.DN)
+	}
+	if len(dns) != 2 || dns[0] != "cn=a,dc=example,dc=com" || dns[1] != "cn=b,dc=example,dc=com" {
+		t.Errorf("unexpected entries %v", dns)
+	}
+}
+
+func TestSearchIterBreak(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com")
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	for entry, err := range conn.SearchIter(searchRequest) {
+		if err != nil {
+			t.Fatalf("search failed: %s", err)
+		}
+		if entry.DN != "cn=a,dc=example,dc=com" {
+			t.Errorf("unexpected entry %s", entry.DN)
+		}
+		break
+	}
+
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Children[1].Tag != ApplicationAbandonRequest {
+		t.Fatalf("expected abandon request, got %s", ApplicationMap[uint8(req.Children[1].Tag)])
+	}
+	// the abandon request got the message ID following the search
+	id, err := ber.ParseInt64(req.Children[1].Data.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if searchID := req.Children[0].Value.(int64) - 1; id != searchID {
+		t.Errorf("abandoned message %d, expected %d", id, searchID)
+	}
+}
@@ -1,9 +1,17 @@
 package ldap
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
 )
 
 // TestNewEntry tests that repeated calls to NewEntry return the same value with the same input
@@ -31,6 +39,27 @@ func TestNewEntry(t *testing.T) {
 	}
 }
 
+func TestEntryPrintTo(t *testing.T) {
+	entry := NewEntry("cn=bob,dc=example,dc=com", map[string][]string{
+		"cn":   {"bob"},
+		"mail": {"bob@example.com", "robert@example.com"},
+	})
+	result := &SearchResult{Entries: []*Entry{entry, entry}}
+
+	expected := "DN: cn=bob,dc=example,dc=com\ncn: [bob]\nmail: [bob@example.com robert@example.com]\n"
+	var buf bytes.Buffer
+	entry.PrintTo(&buf)
+	assert.Equal(t, expected, buf.String())
+	assert.Equal(t, expected, entry.String())
+	assert.Equal(t, expected+expected, result.String())
+	assert.Equal(t, "cn: [bob]\n", entry.Attributes[0].String())
+
+	buf.Reset()
+	result.PrettyPrintTo(&buf, 2)
+	pretty := "  DN: cn=bob,dc=example,dc=com\n    cn: [bob]\n    mail: [bob@example.com robert@example.com]\n"
+	assert.Equal(t, strings.Repeat(pretty, 2), buf.String())
+}
+
 func TestGetAttributeValue(t *testing.T) {
 	dn := "testDN"
 	attributes := map[string][]string{
@@ -72,6 +101,37 @@ func TestEntry_Unmarshal(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	t.Run("fields tagged with - are skipped", func(t *testing.T) {
+		entry := NewEntry("cn=mario,dc=example,dc=com", map[string][]string{
+			"cn": {"mario"},
+			"-":  {"dash"},
+		})
+
+		type Contact struct {
+			Mail string `ldap:"mail"`
+		}
+		type User struct {
+			CN       string   `ldap:"cn"`
+			Internal string   `ldap:"-"`
+			Contact  Contact  `ldap:"-"`
+			Backup   *Contact `ldap:"-"`
+		}
+		entry.Attributes = append(entry.Attributes, NewEntryAttribute("mail", []string{"mario@example.com"}))
+		user := User{}
+		assert.Nil(t, entry.Unmarshal(&user))
+		assert.Equal(t, User{CN: "mario"}, user)
+
+		contact := &Contact{Mail: "mario@example.com"}
+		marshaled, err := MarshalEntry(&User{CN: "mario", Internal: "x", Contact: *contact, Backup: contact})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(marshaled.Attributes))
+
+		// the attributes of skipped structs are not bound, so they are kept
+		req, err := DiffToModify(entry.DN, entry, &User{CN: "mario"})
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(req.Changes))
+	})
+
 	t.Run("user struct be decoded", func(t *testing.T) {
 		entry := &Entry{
 			DN: "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
@@ -170,4 +230,703 @@ func TestEntry_Unmarshal(t *testing.T) {
 		assert.Equal(t, expect, result)
 	})
 
+	t.Run("bool fields be decoded", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("pwdReset", []string{"TRUE"}),
+				NewEntryAttribute("obsolete", []string{"false"}),
+				NewEntryAttribute("singleValue", []string{"True"}),
+			},
+		}
+
+		type Flags struct {
+			PwdReset    bool `ldap:"pwdReset"`
+			Obsolete    bool `ldap:"obsolete"`
+			SingleValue bool `ldap:"singleValue"`
+		}
+
+		result := &Flags{Obsolete: true}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Flags{PwdReset: true, Obsolete: false, SingleValue: true}, result)
+
+		bad := &Entry{Attributes: []*EntryAttribute{NewEntryAttribute("pwdReset", []string{"yes"})}}
+		assert.NotNil(t, bad.Unmarshal(&Flags{}))
+	})
+
+	t.Run("unsigned and float fields be decoded", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("userAccountControl", []string{"4260352"}),
+				NewEntryAttribute("uidNumber", []string{"18446744073709551615"}),
+				NewEntryAttribute("quota", []string{"1.5"}),
+			},
+		}
+
+		type Account struct {
+			UserAccountControl uint32  `ldap:"userAccountControl"`
+			UIDNumber          uint64  `ldap:"uidNumber"`
+			Quota              float64 `ldap:"quota"`
+		}
+
+		result := &Account{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Account{UserAccountControl: 4260352, UIDNumber: 18446744073709551615, Quota: 1.5}, result)
+
+		type Small struct {
+			UserAccountControl uint16 `ldap:"userAccountControl"`
+		}
+		assert.NotNil(t, entry.Unmarshal(&Small{}), "value out of range")
+
+		negative := &Entry{Attributes: []*EntryAttribute{NewEntryAttribute("userAccountControl", []string{"-1"})}}
+		assert.NotNil(t, negative.Unmarshal(&Account{}), "negative value")
+	})
+
+	t.Run("pointer fields be decoded", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("description", []string{""}),
+				NewEntryAttribute("employeeNumber", []string{"0"}),
+				NewEntryAttribute("memberOf", []string{"cn=admins", "cn=users"}),
+			},
+		}
+
+		type User struct {
+			Description    *string   `ldap:"description"`
+			Mail           *string   `ldap:"mail"`
+			EmployeeNumber *int      `ldap:"employeeNumber"`
+			UIDNumber      *uint32   `ldap:"uidNumber"`
+			MemberOf       *[]string `ldap:"memberOf"`
+		}
+
+		result := &User{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		description, employeeNumber := "", 0
+		expect := &User{
+			Description:    &description,
+			EmployeeNumber: &employeeNumber,
+			MemberOf:       &[]string{"cn=admins", "cn=users"},
+		}
+		assert.Equal(t, expect, result)
+
+		bad := &Entry{Attributes: []*EntryAttribute{NewEntryAttribute("employeeNumber", []string{"x"})}}
+		invalid := &User{}
+		assert.NotNil(t, bad.Unmarshal(invalid))
+		assert.Nil(t, invalid.EmployeeNumber)
+	})
+
+	t.Run("unmarshaler fields be decoded", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				{
+					Name:       "objectGUID",
+					Values:     []string{"\x01\x02"},
+					ByteValues: [][]byte{{0x01, 0x02}},
+				},
+				NewEntryAttribute("objectClass", []string{"top", "person"}),
+			},
+		}
+
+		type Object struct {
+			GUID        testHexValues  `ldap:"objectGUID"`
+			ObjectClass *testHexValues `ldap:"objectClass"`
+			Missing     *testHexValues `ldap:"missing"`
+		}
+
+		result := &Object{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, testHexValues{"0102"}, result.GUID)
+		assert.Equal(t, &testHexValues{"746f70", "706572736f6e"}, result.ObjectClass)
+		assert.Nil(t, result.Missing)
+	})
+
+	t.Run("embedded and nested structs be decoded", func(t *testing.T) {
+		entry := &Entry{
+			DN: "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("cn", []string{"mario"}),
+				NewEntryAttribute("creatorsName", []string{"cn=admin"}),
+				NewEntryAttribute("modifiersName", []string{"cn=luigi"}),
+				NewEntryAttribute("mail", []string{"mario@go-ldap.com"}),
+			},
+		}
+
+		type Audit struct {
+			CreatorsName  string `ldap:"creatorsName"`
+			ModifiersName string `ldap:"modifiersName"`
+		}
+		type Contact struct {
+			Mail string `ldap:"mail"`
+		}
+		type User struct {
+			Audit
+			DN      string `ldap:"dn"`
+			CN      string `ldap:"cn"`
+			Contact *Contact
+		}
+
+		result := &User{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		expect := &User{
+			Audit:   Audit{CreatorsName: "cn=admin", ModifiersName: "cn=luigi"},
+			DN:      "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+			CN:      "mario",
+			Contact: &Contact{Mail: "mario@go-ldap.com"},
+		}
+		assert.Equal(t, expect, result)
+
+		// nested struct pointers stay nil if none of their attributes is present
+		result = &User{}
+		assert.Nil(t, NewEntry(entry.DN, nil).Unmarshal(result))
+		assert.Equal(t, &User{DN: entry.DN}, result)
+	})
+
+	t.Run("maps be decoded", func(t *testing.T) {
+		entry := &Entry{
+			DN: "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("cn", []string{"mario"}),
+				NewEntryAttribute("mail", []string{"mario@go-ldap.com", "mario@example.com"}),
+				NewEntryAttribute("sn", []string{"Mario"}),
+			},
+		}
+
+		type User struct {
+			DN    string              `ldap:"dn"`
+			CN    string              `ldap:"cn"`
+			Other map[string][]string `ldap:",remain"`
+		}
+
+		result := &User{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		expect := &User{
+			DN: "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+			CN: "mario",
+			Other: map[string][]string{
+				"mail": {"mario@go-ldap.com", "mario@example.com"},
+				"sn":   {"Mario"},
+			},
+		}
+		assert.Equal(t, expect, result)
+
+		all := map[string]string{}
+		err = entry.Unmarshal(&all)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"cn": "mario", "mail": "mario@go-ldap.com", "sn": "Mario"}, all)
+
+		invalid := map[string]int{}
+		assert.NotNil(t, entry.Unmarshal(&invalid))
+	})
+
+	t.Run("slices be decoded", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("port", []string{"389", "636"}),
+				NewEntryAttribute("usn", []string{"9223372036854775807", "-1"}),
+				NewEntryAttribute("serial", []string{"18446744073709551615"}),
+				{
+					Name:       "userCertificate;binary",
+					Values:     []string{"\x30\x82", "\x30\x83"},
+					ByteValues: [][]byte{{0x30, 0x82}, {0x30, 0x83}},
+				},
+			},
+		}
+
+		type Server struct {
+			Ports        []int    `ldap:"port"`
+			USNs         []int64  `ldap:"usn"`
+			Serials      []uint64 `ldap:"serial"`
+			Certificates [][]byte `ldap:"userCertificate;binary"`
+		}
+
+		result := &Server{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		expect := &Server{
+			Ports:        []int{389, 636},
+			USNs:         []int64{9223372036854775807, -1},
+			Serials:      []uint64{18446744073709551615},
+			Certificates: [][]byte{{0x30, 0x82}, {0x30, 0x83}},
+		}
+		assert.Equal(t, expect, result)
+
+		bad := &Entry{Attributes: []*EntryAttribute{NewEntryAttribute("port", []string{"389", "ldaps"})}}
+		assert.NotNil(t, bad.Unmarshal(&Server{}))
+	})
+
+	t.Run("attribute names be matched case-insensitively", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("objectclass", []string{"top", "person"}),
+				NewEntryAttribute("CN", []string{"mario"}),
+			},
+		}
+
+		type Person struct {
+			ObjectClass []string          `ldap:"objectClass"`
+			CN          string            `ldap:"cn"`
+			Other       map[string]string `ldap:",remain"`
+		}
+
+		result := &Person{}
+		err := entry.Unmarshal(result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Person{ObjectClass: []string{"top", "person"}, CN: "mario", Other: map[string]string{}}, result)
+	})
+
+	t.Run("time fields be decoded", func(t *testing.T) {
+		entry := &Entry{
+			DN: "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("createTimestamp", []string{"20220315101530Z"}),
+				NewEntryAttribute("pwdChangedTime", []string{"20220315111530.25+0100"}),
+				NewEntryAttribute("expires", []string{"2023-01-31"}),
+			},
+		}
+
+		type User struct {
+			Created     time.Time  `ldap:"createTimestamp"`
+			PwdChanged  *time.Time `ldap:"pwdChangedTime"`
+			Expires     time.Time  `ldap:"expires,layout=2006-01-02"`
+			LastLogon   *time.Time `ldap:"lastLogon"`
+			Unspecified time.Time  `ldap:"modifyTimestamp"`
+		}
+
+		result := &User{}
+		err := entry.Unmarshal(result)
+		assert.Nil(t, err)
+
+		created := time.Date(2022, 3, 15, 10, 15, 30, 0, time.UTC)
+		assert.True(t, result.Created.Equal(created))
+		assert.NotNil(t, result.PwdChanged)
+		assert.True(t, result.PwdChanged.Equal(created.Add(250*time.Millisecond)))
+		assert.True(t, result.Expires.Equal(time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC)))
+		assert.Nil(t, result.LastLogon)
+		assert.True(t, result.Unspecified.IsZero())
+
+		bad := &Entry{Attributes: []*EntryAttribute{NewEntryAttribute("createTimestamp", []string{"yesterday"})}}
+		assert.NotNil(t, bad.Unmarshal(&User{}))
+	})
+
+	t.Run("syntax decoded interface values", func(t *testing.T) {
+		entry := &Entry{
+			Attributes: []*EntryAttribute{
+				NewEntryAttribute("uidNumber", []string{"1000"}),
+				NewEntryAttribute("member", []string{"cn=a,dc=example", "cn=b,dc=example"}),
+				NewEntryAttribute("description", []string{"text"}),
+			},
+		}
+
+		type Group struct {
+			UIDNumber   interface{} `ldap:"uidNumber,syntax=Integer"`
+			Members     interface{} `ldap:"member,syntax=1.3.6.1.4.1.1466.115.121.1.12"`
+			Description interface{} `ldap:"description"`
+		}
+
+		result := &Group{}
+		assert.Nil(t, entry.Unmarshal(result))
+		assert.Equal(t, int64(1000), result.UIDNumber)
+		assert.Equal(t, "text", result.Description)
+		members, ok := result.Members.([]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, 2, len(members))
+		assert.Equal(t, "cn=b,dc=example", members[1].(*DN).String())
+
+		bad := &Entry{Attributes: []*EntryAttribute{NewEntryAttribute("uidNumber", []string{"many"})}}
+		assert.NotNil(t, bad.Unmarshal(&Group{}))
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	entry := &Entry{
+		DN: "cn=mario,ou=Users,dc=go-ldap,dc=github,dc=com",
+		Attributes: []*EntryAttribute{
+			NewEntryAttribute("cn", []string{"mario"}),
+			NewEntryAttribute("mail", []string{"mario@go-ldap.com"}),
+		},
+	}
+
+	type User struct {
+		DN   string `json:"dn"`
+		CN   string `json:"cn"`
+		Mail string `json:"email" ldap:"mail"`
+	}
+
+	t.Run("tag name", func(t *testing.T) {
+		result := &User{}
+		err := NewDecoder(DecodeWithTagName("json")).Decode(entry, result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &User{DN: entry.DN, CN: "mario"}, result)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		assert.NotNil(t, NewDecoder(DecodeWithTagName("json"), DecodeWithStrict(true)).Decode(entry, &User{}))
+		assert.Nil(t, NewDecoder(DecodeWithStrict(true)).Decode(entry, &struct {
+			CN   string `ldap:"cn"`
+			Mail string `ldap:"mail"`
+		}{}))
+		assert.Nil(t, NewDecoder(DecodeWithStrict(true)).Decode(entry, &struct {
+			CN    string            `ldap:"cn"`
+			Other map[string]string `ldap:",remain"`
+		}{}))
+	})
+
+	t.Run("case insensitive", func(t *testing.T) {
+		type Person struct {
+			CN   string `ldap:"CN"`
+			Mail string `ldap:"Mail"`
+		}
+
+		result := &Person{}
+		err := NewDecoder(DecodeWithCaseInsensitive(true), DecodeWithStrict(true)).Decode(entry, result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Person{CN: "mario", Mail: "mario@go-ldap.com"}, result)
+
+		result = &Person{}
+		err = NewDecoder(DecodeWithCaseInsensitive(false)).Decode(entry, result)
+
+		assert.Nil(t, err)
+		assert.Equal(t, &Person{}, result)
+	})
+}
+
+// testHexValues implements Unmarshaler by hex encoding the raw values
+type testHexValues []string
+
+func (h *testHexValues) UnmarshalLDAP(values [][]byte) error {
+	for _, value := range values {
+		*h = append(*h, fmt.Sprintf("%x", value))
+	}
+	return nil
+}
+
+func TestSearchWithCallback(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com")
+	var dns []string
+	controlsCalled := false
+	err := conn.SearchWithCallback(searchRequest, func(entry *Entry) error {
+		dns = append(dns, entry.DN)
+		return nil
+	}, nil, func([]Control) {
+		controlsCalled = true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com"}, dns)
+	assert.False(t, controlsCalled)
+
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com")
+	dns = nil
+	err = conn.SearchWithCallback(searchRequest, func(entry *Entry) error {
+		dns = append(dns, entry.DN)
+		return ErrStopSearch
+	}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cn=a,dc=example,dc=com"}, dns)
+
+	req, err := ptc.ReceiveRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, ber.Tag(ApplicationAbandonRequest), req.Children[1].Tag)
+
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com")
+	callbackErr := errors.New("callback failed")
+	err = conn.SearchWithCallback(searchRequest, func(*Entry) error {
+		return callbackErr
+	}, nil, nil)
+	assert.True(t, errors.Is(err, callbackErr))
+}
+
+func TestSearchResultLimits(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	searchRequest.MaxEntries = 2
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com", "cn=c,dc=example,dc=com")
+	result, err := conn.Search(searchRequest)
+	var limitErr *ResultLimitError
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.Equal(t, &ResultLimitError{Limit: "MaxEntries", Max: 2}, limitErr)
+	}
+	assert.Equal(t, 2, len(result.Entries))
+	req, err := ptc.ReceiveRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, ber.Tag(ApplicationAbandonRequest), req.Children[1].Tag)
+
+	// the limit is not exceeded by as many entries
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com", "cn=b,dc=example,dc=com")
+	result, err = conn.Search(searchRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result.Entries))
+
+	searchRequest.MaxEntries = 0
+	searchRequest.MaxResultBytes = 100
+	entry := NewEntry("cn=a,dc=example,dc=com", map[string][]string{"description": {strings.Repeat("x", 60)}})
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, entry, entry)
+	result, err = conn.Search(searchRequest)
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.Equal(t, &ResultLimitError{Limit: "MaxResultBytes", Max: 100}, limitErr)
+	}
+	assert.Equal(t, 1, len(result.Entries))
+	req, err = ptc.ReceiveRequest()
+	assert.NoError(t, err)
+	assert.Equal(t, ber.Tag(ApplicationAbandonRequest), req.Children[1].Tag)
+}
+
+func TestSearchAllowPartialResults(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 1, 0, false, "(objectClass=*)", nil, nil)
+
+	go respondToSearchWithCode(ptc, LDAPResultSizeLimitExceeded, "cn=a,dc=example,dc=com")
+	result, err := conn.Search(searchRequest)
+	assert.True(t, IsErrorWithCode(err, LDAPResultSizeLimitExceeded))
+	assert.False(t, result.Partial)
+
+	searchRequest.AllowPartialResults = true
+	go respondToSearchWithCode(ptc, LDAPResultSizeLimitExceeded, "cn=a,dc=example,dc=com")
+	result, err = conn.Search(searchRequest)
+	assert.NoError(t, err)
+	assert.True(t, result.Partial)
+	assert.Equal(t, uint16(LDAPResultSizeLimitExceeded), result.PartialResultCode)
+	assert.Equal(t, 1, len(result.Entries))
+
+	go respondToSearchWithCode(ptc, LDAPResultNoSuchObject)
+	_, err = conn.Search(searchRequest)
+	assert.True(t, IsErrorWithCode(err, LDAPResultNoSuchObject))
+}
+
+// respondWithMalformedEntry sends an entry without attributes followed by the
+// search result done message
+func respondWithMalformedEntry(ptc *packetTranslatorConn) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return
+	}
+	messageID := req.Children[0].Value.(int64)
+	entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	searchEntry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	searchEntry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cn=a,dc=example,dc=com", "Object Name"))
+	entry.AppendChild(searchEntry)
+	_ = ptc.SendResponse(entry)
+	sendSearchResult(ptc, messageID, LDAPResultSuccess)
+}
+
+func TestSearchMalformedEntry(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+
+	go respondWithMalformedEntry(ptc)
+	_, err := conn.Search(searchRequest)
+	assert.True(t, IsErrorWithCode(err, ErrorUnexpectedResponse), "unexpected error %v", err)
+	assert.True(t, strings.Contains(err.Error(), "invalid search result entry"))
+
+	go respondWithMalformedEntry(ptc)
+	response := conn.SearchAsync(context.Background(), searchRequest, 0)
+	assert.False(t, response.Next())
+	assert.True(t, IsErrorWithCode(response.Err(), ErrorUnexpectedResponse), "unexpected error %v", response.Err())
+
+	// the connection remains usable
+	go respondToSearch(ptc, "cn=a,dc=example,dc=com")
+	result, err := conn.Search(searchRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Entries))
+}
+
+func TestSearchBinaryValues(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	entry := NewEntry("cn=a,dc=example,dc=com", map[string][]string{
+		"cn":         {"a"},
+		"objectGUID": {"\x01\xff\xfe"},
+		"legacyName": {"Jos\xe9"},
+	})
+	for _, test := range []struct {
+		mode       BinaryValueMode
+		objectGUID []string
+		legacyName []string
+	}{
+		{BinaryValuesOmitted, []string{}, []string{}},
+		{BinaryValuesTransliterated, []string{"\u0001ÿþ"}, []string{"José"}},
+		{BinaryValuesBase64, []string{"Af/+"}, []string{"Sm9z6Q=="}},
+	} {
+		searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+		searchRequest.BinaryValues = test.mode
+
+		go respondToSearchWithEntries(ptc, LDAPResultSuccess, entry)
+		result, err := conn.Search(searchRequest)
+		assert.NoError(t, err)
+		if !assert.Equal(t, 1, len(result.Entries)) {
+			continue
+		}
+		e := result.Entries[0]
+		assert.Equal(t, []string{"a"}, e.GetAttributeValues("cn"))
+		assert.Equal(t, test.objectGUID, e.GetAttributeValues("objectGUID"))
+		assert.Equal(t, test.legacyName, e.GetAttributeValues("legacyName"))
+		assert.Equal(t, []byte("\x01\xff\xfe"), e.GetRawAttributeValue("objectGUID"))
+		for _, attr := range e.Attributes {
+			assert.Equal(t, attr.Name != "cn", attr.IsBinary, attr.Name)
+		}
+	}
+}
+
+func TestSearchLazyValues(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	entry := NewEntry("cn=a,dc=example,dc=com", map[string][]string{
+		"cn":          {"a", "b"},
+		"description": {},
+		"objectGUID":  {"\x01\xff\xfe"},
+	})
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil)
+	searchRequest.LazyValues = true
+
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, entry)
+	result, err := conn.Search(searchRequest)
+	assert.NoError(t, err)
+	if !assert.Equal(t, 1, len(result.Entries)) {
+		return
+	}
+	e := result.Entries[0]
+	for _, attr := range e.Attributes {
+		if attr.Name == "objectGUID" {
+			// the string view of binary attributes is selected eagerly
+			assert.Equal(t, []string{}, attr.Values)
+			continue
+		}
+		assert.Nil(t, attr.Values, attr.Name)
+	}
+	assert.Equal(t, []string{"a", "b"}, e.GetAttributeValues("cn"))
+	assert.Equal(t, "a", e.GetEqualFoldAttributeValue("CN"))
+	assert.Equal(t, []string{}, e.GetAttributeValues("description"))
+	assert.Equal(t, []byte("\x01\xff\xfe"), e.GetRawAttributeValue("objectGUID"))
+
+	e.AddAttributeValue("cn", "c")
+	assert.Equal(t, []string{"a", "b", "c"}, e.GetAttributeValues("cn"))
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, e.GetRawAttributeValues("cn"))
+}
+
+func TestDecodeAttributesCapped(t *testing.T) {
+	packet, err := decodePacket(testEntryMessage(2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	entry, err := decodeEntry(packet, valueDecoding{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the attributes share their backing arrays
+	first := entry.Attributes[0]
+	first.Values = append(first.Values, "third value")
+	first.ByteValues = append(first.ByteValues, []byte("third value"))
+	assert.Equal(t, []string{"first value", "second value"}, entry.Attributes[1].Values)
+	assert.Equal(t, [][]byte{[]byte("first value"), []byte("second value")}, entry.Attributes[1].ByteValues)
+}
+
+// BenchmarkDecodeEntries decodes a result of 10000 entries with 20
+// attributes each
+func BenchmarkDecodeEntries(b *testing.B) {
+	packets := make([]*ber.Packet, 10000)
+	for i := range packets {
+		packet, err := decodePacket(testEntryMessage(20))
+		if err != nil {
+			b.Fatal(err)
+		}
+		packets[i] = packet
+	}
+	for name, decoding := range map[string]valueDecoding{
+		"eager": {},
+		"lazy":  {lazy: true},
+	} {
+		decoding := decoding
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, packet := range packets {
+					if _, err := decodeEntry(packet, decoding); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUnmarshal decodes an entry into a struct with string, integer,
+// time, slice and byte slice fields
+func BenchmarkUnmarshal(b *testing.B) {
+	type User struct {
+		DN          string    `ldap:"dn"`
+		CN          string    `ldap:"cn"`
+		Mail        []string  `ldap:"mail"`
+		UIDNumber   int       `ldap:"uidNumber"`
+		Modified    time.Time `ldap:"modifyTimestamp"`
+		Photo       []byte    `ldap:"jpegPhoto"`
+		Description string    `ldap:"description"`
+	}
+	entry := NewEntry("uid=jdoe,ou=people,dc=example,dc=com", map[string][]string{
+		"cn":              {"John Doe"},
+		"mail":            {"jdoe@example.com", "john.doe@example.com"},
+		"uidNumber":       {"1001"},
+		"modifyTimestamp": {"20240131120000Z"},
+		"jpegPhoto":       {"\xff\xd8\xff\xe0"},
+		"objectClass":     {"top", "person", "inetOrgPerson"},
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var user User
+		if err := entry.Unmarshal(&user); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
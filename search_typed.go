@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package ldap
+
+// SearchTyped performs the given search request and unmarshals every returned
+// entry into a new T using Entry.Unmarshal, so T must be a struct type using
+// the `ldap` struct tags.
+func SearchTyped[T any](l *Conn, searchRequest *SearchRequest) ([]T, error) {
+	result, err := l.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalEntries[T](result.Entries)
+}
+
+// SearchTypedWithPaging is like SearchTyped, but uses SearchWithPaging with
+// the given paging size to retrieve the entries
+func SearchTypedWithPaging[T any](l *Conn, searchRequest *SearchRequest, pagingSize uint32) ([]T, error) {
+	result, err := l.SearchWithPaging(searchRequest, pagingSize)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalEntries[T](result.Entries)
+}
+
+// unmarshalEntries unmarshals each entry into a new T
+func unmarshalEntries[T any](entries []*Entry) ([]T, error) {
+	values := make([]T, 0, len(entries))
+	for _, entry := range entries {
+		var v T
+		if err := entry.Unmarshal(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,37 @@
+//go:build go1.18
+// +build go1.18
+
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTyped(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go respondToSearch(ptc, "cn=john,dc=example,dc=com", "cn=jane,dc=example,dc=com")
+
+	type person struct {
+		DN string `ldap:"dn"`
+	}
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=person)", nil, nil)
+	people, err := SearchTyped[person](conn, searchRequest)
+	if err != nil {
+		t.Fatalf("search failed: %s", err)
+	}
+	expected := []person{{DN: "cn=john,dc=example,dc=com"}, {DN: "cn=jane,dc=example,dc=com"}}
+	if !reflect.DeepEqual(people, expected) {
+		t.Errorf("got %v, want %v", people, expected)
+	}
+
+	if _, err := unmarshalEntries[string]([]*Entry{NewEntry("cn=x", nil)}); err == nil {
+		t.Error("expected error for non-struct type")
+	}
+}
@@ -0,0 +1,205 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// well-known SIDs (https://learn.microsoft.com/en-us/windows/win32/secauthz/well-known-sids)
+const (
+	SIDNull                   = "S-1-0-0"
+	SIDEveryone               = "S-1-1-0"
+	SIDCreatorOwner           = "S-1-3-0"
+	SIDCreatorGroup           = "S-1-3-1"
+	SIDAnonymous              = "S-1-5-7"
+	SIDSelf                   = "S-1-5-10"
+	SIDAuthenticatedUsers     = "S-1-5-11"
+	SIDLocalSystem            = "S-1-5-18"
+	SIDLocalService           = "S-1-5-19"
+	SIDNetworkService         = "S-1-5-20"
+	SIDBuiltinAdministrators  = "S-1-5-32-544"
+	SIDBuiltinUsers           = "S-1-5-32-545"
+	SIDBuiltinGuests          = "S-1-5-32-546"
+	SIDBuiltinAccountOperator = "S-1-5-32-548"
+	SIDBuiltinServerOperators = "S-1-5-32-549"
+	SIDBuiltinBackupOperators = "S-1-5-32-551"
+)
+
+// well-known relative identifiers of domain accounts and groups
+const (
+	RIDAdministrator      = 500
+	RIDGuest              = 501
+	RIDKrbtgt             = 502
+	RIDDomainAdmins       = 512
+	RIDDomainUsers        = 513
+	RIDDomainGuests       = 514
+	RIDDomainComputers    = 515
+	RIDDomainControllers  = 516
+	RIDCertPublishers     = 517
+	RIDSchemaAdmins       = 518
+	RIDEnterpriseAdmins   = 519
+	RIDGroupPolicyOwners  = 520
+	RIDReadOnlyDCs        = 521
+	RIDProtectedUsers     = 525
+	RIDKeyAdmins          = 526
+	RIDEnterpriseKeyAdmin = 527
+)
+
+// ErrInvalidSID is returned when a SID could not be parsed
+var ErrInvalidSID = errors.New("ldap: invalid SID")
+
+// SID is a Windows security identifier, as held by the objectSid and
+// tokenGroups attributes of Active Directory. It can be used as field type
+// with Unmarshal and MarshalEntry.
+type SID struct {
+	Revision            uint8
+	IdentifierAuthority uint64
+	SubAuthorities      []uint32
+}
+
+// ParseSID parses the binary representation of a SID
+func ParseSID(b []byte) (*SID, error) {
+	if len(b) < 8 || int(b[1])*4+8 != len(b) {
+		return nil, ErrInvalidSID
+	}
+	sid := &SID{Revision: b[0]}
+	for _, v := range b[2:8] {
+		sid.IdentifierAuthority = sid.IdentifierAuthority<<8 | uint64(v)
+	}
+	for i := 8; i < len(b); i += 4 {
+		sid.SubAuthorities = append(sid.SubAuthorities, binary.LittleEndian.Uint32(b[i:]))
+	}
+	return sid, nil
+}
+
+// ParseSIDString parses the string representation of a SID, e.g.
+// "S-1-5-21-1004336348-1177238915-682003330-512"
+func ParseSIDString(s string) (*SID, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 3 || !strings.EqualFold(parts[0], "S") {
+		return nil, ErrInvalidSID
+	}
+	revision, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, ErrInvalidSID
+	}
+	sid := &SID{Revision: uint8(revision)}
+	// authorities of 2^32 and above are written in hexadecimal
+	if sid.IdentifierAuthority, err = strconv.ParseUint(parts[2], 0, 48); err != nil {
+		return nil, ErrInvalidSID
+	}
+	if len(parts)-3 > 255 {
+		return nil, ErrInvalidSID
+	}
+	for _, part := range parts[3:] {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidSID
+		}
+		sid.SubAuthorities = append(sid.SubAuthorities, uint32(v))
+	}
+	return sid, nil
+}
+
+// String returns the string representation of the SID
+func (s *SID) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "S-%d-", s.Revision)
+	if s.IdentifierAuthority >= 1<<32 {
+		fmt.Fprintf(&b, "0x%012X", s.IdentifierAuthority)
+	} else {
+		b.WriteString(strconv.FormatUint(s.IdentifierAuthority, 10))
+	}
+	for _, v := range s.SubAuthorities {
+		b.WriteByte('-')
+		b.WriteString(strconv.FormatUint(uint64(v), 10))
+	}
+	return b.String()
+}
+
+// Bytes returns the binary representation of the SID
+func (s *SID) Bytes() []byte {
+	b := make([]byte, 8+4*len(s.SubAuthorities))
+	b[0] = s.Revision
+	b[1] = uint8(len(s.SubAuthorities))
+	for i := 0; i < 6; i++ {
+		b[7-i] = byte(s.IdentifierAuthority >> (8 * i))
+	}
+	for i, v := range s.SubAuthorities {
+		binary.LittleEndian.PutUint32(b[8+4*i:], v)
+	}
+	return b
+}
+
+// FilterValue returns the binary SID escaped for use in a search filter,
+// e.g. "(objectSid=" + s.FilterValue() + ")"
+func (s *SID) FilterValue() string {
+	var b strings.Builder
+	for _, v := range s.Bytes() {
+		fmt.Fprintf(&b, "\\%02x", v)
+	}
+	return b.String()
+}
+
+// RID returns the relative identifier, the last sub-authority of the SID,
+// or 0 if it has none
+func (s *SID) RID() uint32 {
+	if len(s.SubAuthorities) == 0 {
+		return 0
+	}
+	return s.SubAuthorities[len(s.SubAuthorities)-1]
+}
+
+// Domain returns the SID without its relative identifier, which is the SID
+// of the domain for domain accounts and groups
+func (s *SID) Domain() *SID {
+	domain := &SID{Revision: s.Revision, IdentifierAuthority: s.IdentifierAuthority}
+	if len(s.SubAuthorities) > 0 {
+		domain.SubAuthorities = append([]uint32(nil), s.SubAuthorities[:len(s.SubAuthorities)-1]...)
+	}
+	return domain
+}
+
+// WithRID returns the SID of the account or group with the given relative
+// identifier in the domain identified by s, e.g. the Domain Admins group for
+// RIDDomainAdmins
+func (s *SID) WithRID(rid uint32) *SID {
+	sid := &SID{Revision: s.Revision, IdentifierAuthority: s.IdentifierAuthority}
+	sid.SubAuthorities = append(append([]uint32(nil), s.SubAuthorities...), rid)
+	return sid
+}
+
+// Equal returns true if both SIDs are identical
+func (s *SID) Equal(other *SID) bool {
+	if s.Revision != other.Revision || s.IdentifierAuthority != other.IdentifierAuthority ||
+		len(s.SubAuthorities) != len(other.SubAuthorities) {
+		return false
+	}
+	for i := range s.SubAuthorities {
+		if s.SubAuthorities[i] != other.SubAuthorities[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalLDAP implements Unmarshaler, reading the first binary value
+func (s *SID) UnmarshalLDAP(values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	sid, err := ParseSID(values[0])
+	if err != nil {
+		return err
+	}
+	*s = *sid
+	return nil
+}
+
+// MarshalLDAP implements Marshaler
+func (s *SID) MarshalLDAP() ([][]byte, error) {
+	return [][]byte{s.Bytes()}, nil
+}
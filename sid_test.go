@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSID(t *testing.T) {
+	b := []byte{
+		0x01, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+		0x15, 0x00, 0x00, 0x00,
+		0xdc, 0xf4, 0xdc, 0x3b,
+		0x83, 0x3d, 0x2b, 0x46,
+		0x82, 0x8b, 0xa6, 0x28,
+		0x00, 0x02, 0x00, 0x00,
+	}
+	sid, err := ParseSID(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "S-1-5-21-1004336348-1177238915-682003330-512"
+	if sid.String() != expected {
+		t.Errorf("got %s, expected %s", sid, expected)
+	}
+	if !bytes.Equal(sid.Bytes(), b) {
+		t.Errorf("got %x, expected %x", sid.Bytes(), b)
+	}
+	if sid.RID() != RIDDomainAdmins {
+		t.Errorf("got RID %d", sid.RID())
+	}
+	if domain := sid.Domain(); domain.String() != "S-1-5-21-1004336348-1177238915-682003330" || !domain.WithRID(512).Equal(sid) {
+		t.Errorf("unexpected domain %s", domain)
+	}
+
+	parsed, err := ParseSIDString(expected)
+	if err != nil || !parsed.Equal(sid) {
+		t.Errorf("got %v: %v", parsed, err)
+	}
+	if s, err := ParseSIDString(SIDBuiltinAdministrators); err != nil || s.String() != SIDBuiltinAdministrators {
+		t.Errorf("got %v: %v", s, err)
+	}
+	if s, err := ParseSIDString("S-1-0x123456789ABC-1"); err != nil || s.String() != "S-1-0x123456789ABC-1" {
+		t.Errorf("got %v: %v", s, err)
+	}
+
+	for _, invalid := range []string{"", "S-1", "X-1-5", "S-1-5-x", "S-1-5-4294967296"} {
+		if _, err := ParseSIDString(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+	if _, err := ParseSID(b[:10]); err != ErrInvalidSID {
+		t.Errorf("expected ErrInvalidSID, got %v", err)
+	}
+
+	var user struct {
+		SID SID `ldap:"objectSid"`
+	}
+	entry := &Entry{Attributes: []*EntryAttribute{{Name: "objectSid", Values: []string{string(b)}, ByteValues: [][]byte{b}}}}
+	if err := entry.Unmarshal(&user); err != nil || !user.SID.Equal(sid) {
+		t.Errorf("got %s: %v", &user.SID, err)
+	}
+}
+
+func TestParseExtendedDN(t *testing.T) {
+	e, err := ParseExtendedDN("<GUID=b3b2c5e6a1c2d34e8f9a0b1c2d3e4f50>;<SID=010500000000000515000000dcf4dc3b833d2b46828ba62800020000>;CN=Domain Admins,CN=Users,DC=example,DC=com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.GUID.String() != "e6c5b2b3-c2a1-4ed3-8f9a-0b1c2d3e4f50" || e.SID.String() != "S-1-5-21-1004336348-1177238915-682003330-512" ||
+		e.DN != "CN=Domain Admins,CN=Users,DC=example,DC=com" {
+		t.Errorf("unexpected extended DN %+v", e)
+	}
+
+	e, err = ParseExtendedDN("<GUID=e6c5b2b3-c2a1-4ed3-8f9a-0b1c2d3e4f50>;<SID=S-1-5-32-544>;CN=Administrators,CN=Builtin,DC=example,DC=com")
+	if err != nil || e.GUID.String() != "e6c5b2b3-c2a1-4ed3-8f9a-0b1c2d3e4f50" || e.SID.String() != SIDBuiltinAdministrators ||
+		e.DN != "CN=Administrators,CN=Builtin,DC=example,DC=com" {
+		t.Errorf("unexpected extended DN %+v: %v", e, err)
+	}
+
+	e, err = ParseExtendedDN("CN=plain,DC=example")
+	if err != nil || e.DN != "CN=plain,DC=example" || e.SID != nil || !e.GUID.IsZero() {
+		t.Errorf("unexpected extended DN %+v: %v", e, err)
+	}
+
+	for _, invalid := range []string{"<GUID=abc;CN=x", "<GUID>;CN=x", "<GUID=abc>;CN=x", "<SID=zz>;CN=x"} {
+		if _, err := ParseExtendedDN(invalid); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
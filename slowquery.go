@@ -0,0 +1,94 @@
+package ldap
+
+import (
+	"time"
+)
+
+// SlowQuery describes a search or bind operation which took at least as long
+// as the configured slow query threshold
+type SlowQuery struct {
+	// Operation is the kind of operation, i.e. "search" or "bind"
+	Operation string
+	// BaseDN is the base DN of a search or the DN a bind was performed with
+	BaseDN string
+	// Scope is the scope of a search
+	Scope int
+	// Filter is the filter of a search
+	Filter string
+	// Controls are the controls sent with the request
+	Controls []Control
+	// Duration is the time between sending the request and receiving the final response
+	Duration time.Duration
+	// Entries is the number of entries returned by a search
+	Entries int
+	// ResultCode is the result code returned by the server, or -1 if there was none
+	ResultCode int64
+	// CorrelationID is the caller-supplied ID of the operation, see WithCorrelationID
+	CorrelationID string
+}
+
+type slowQueryConfig struct {
+	threshold time.Duration
+	callback  func(*SlowQuery)
+}
+
+// SetSlowQueryThreshold enables reporting of searches and binds taking at least
+// the given duration. Slow queries are delivered to callback if it is not nil,
+// otherwise they are logged as warnings through the structured logger set with
+// SetLogger, or through the package logger if there is none.
+// A threshold of 0 disables slow query reporting.
+func (l *Conn) SetSlowQueryThreshold(threshold time.Duration, callback func(*SlowQuery)) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	if threshold <= 0 {
+		l.slowQuery = nil
+		return
+	}
+	l.slowQuery = &slowQueryConfig{threshold: threshold, callback: callback}
+}
+
+func (l *Conn) reportSlowQuery(t *operationTrace, elapsed time.Duration) {
+	q := &SlowQuery{
+		Operation:     t.operation,
+		Duration:      elapsed,
+		Entries:       t.entries,
+		ResultCode:    t.resultCode,
+		CorrelationID: t.correlationID,
+	}
+	switch r := t.req.(type) {
+	case *SearchRequest:
+		q.BaseDN = r.BaseDN
+		q.Scope = r.Scope
+		q.Filter = r.Filter
+		q.Controls = r.Controls
+	case *SimpleBindRequest:
+		q.BaseDN = r.Username
+		q.Controls = r.Controls
+	case *DigestMD5BindRequest:
+		q.BaseDN = r.Username
+		q.Controls = r.Controls
+	case *NTLMBindRequest:
+		q.BaseDN = r.Username
+		q.Controls = r.Controls
+	}
+
+	if t.slowQuery.callback != nil {
+		t.slowQuery.callback(q)
+		return
+	}
+
+	if holder, ok := l.structuredLogger.Load().(loggerHolder); ok && holder.logger != nil {
+		fields := []interface{}{"operation", q.Operation, "base_dn", q.BaseDN}
+		if q.Operation == "search" {
+			fields = append(fields, "scope", ScopeMap[q.Scope], "filter", q.Filter, "entries", q.Entries)
+		}
+		fields = append(fields, "duration", q.Duration, "result_code", q.ResultCode, "controls", q.Controls)
+		if q.CorrelationID != "" {
+			fields = append(fields, "correlation_id", q.CorrelationID)
+		}
+		l.log(LogLevelWarn, "slow query", fields...)
+		return
+	}
+	logger.Printf("ldap: slow %s (%s): base DN %q, filter %q, %d entries, result code %d, controls %v",
+		q.Operation, q.Duration, q.BaseDN, q.Filter, q.Entries, q.ResultCode, q.Controls)
+}
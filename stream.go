@@ -0,0 +1,460 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ErrAttributeNotFound is returned by StreamAttributeValue if the entry has
+// no value of the attribute
+var ErrAttributeNotFound = errors.New("ldap: attribute not found")
+
+// binaryTransferAttributes are the attributes which have to be requested
+// with the ";binary" transfer option, RFC 4523 section 2
+var binaryTransferAttributes = map[string]bool{
+	"usercertificate":           true,
+	"cacertificate":             true,
+	"crosscertificatepair":      true,
+	"certificaterevocationlist": true,
+	"authorityrevocationlist":   true,
+	"deltarevocationlist":       true,
+	"supportedalgorithms":       true,
+}
+
+// StreamAttributeValue writes the first value of the given attribute of the
+// entry with the given DN to w and returns the number of bytes written. The
+// value is copied to w as it is received instead of being held in memory,
+// which suits large values like jpegPhoto or userCertificate. Attributes
+// with certificate syntaxes are requested with the ";binary" transfer option
+// required by RFC 4523, unless attribute already has options.
+//
+// As w is written by the goroutine reading the connection, a slow writer
+// delays the responses of all operations on the connection. The response
+// holding the value is neither passed to the packet capture hook nor
+// validated by DecodingStrict. Values which cannot be streamed, like values
+// in attributes encoded with an indefinite length, are read completely before
+// they are written.
+//
+// If the entry does not exist, the error of the server is returned, which
+// matches ErrNoSuchObject. ErrEntryNotFound is returned if the search returns
+// no entry, e.g. because the entry is not visible to the bound user. If the
+// entry has no value of the attribute, ErrAttributeNotFound is returned.
+func (l *Conn) StreamAttributeValue(dn, attribute string, w io.Writer) (int64, error) {
+	name := attribute
+	if i := strings.IndexByte(attribute, ';'); i >= 0 {
+		name = attribute[:i]
+	} else if binaryTransferAttributes[strings.ToLower(attribute)] {
+		attribute += ";binary"
+	}
+
+	stream := &valueStream{name: name, w: w}
+	defer l.removeValueStream(stream)
+	req := NewSearchRequest(
+		dn, ScopeBaseObject, NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{attribute}, nil,
+	)
+	req.LazyValues = true
+	req.DisableRangeRetrieval = true
+	result, err := l.SearchContext(context.WithValue(context.Background(), valueStreamKey{}, stream), req)
+	if err != nil {
+		return stream.written, err
+	}
+	if stream.streamed {
+		return stream.written, stream.err
+	}
+	if len(result.Entries) == 0 {
+		return 0, ErrEntryNotFound
+	}
+
+	// the value was read completely
+	for _, attr := range result.Entries[0].Attributes {
+		if stream.matches(attr.Name) && len(attr.ByteValues) > 0 {
+			n, err := w.Write(attr.ByteValues[0])
+			return int64(n), err
+		}
+	}
+	return 0, ErrAttributeNotFound
+}
+
+// valueStream receives the first value of an attribute of the entry returned
+// by a search, see StreamAttributeValue. Its fields are set by the reader
+// goroutine before the entry is passed on.
+type valueStream struct {
+	messageID int64
+	// name is the name of the attribute without options
+	name string
+	w    io.Writer
+
+	// streamed is set once a value is copied to w
+	streamed bool
+	written  int64
+	// err is the error writing to w
+	err error
+}
+
+type valueStreamKey struct{}
+
+func (s *valueStream) matches(attribute string) bool {
+	if i := strings.IndexByte(attribute, ';'); i >= 0 {
+		attribute = attribute[:i]
+	}
+	return strings.EqualFold(attribute, s.name)
+}
+
+// copy copies the next length bytes of r to the writer of the stream. The
+// bytes are consumed even if writing fails, as they are part of the response.
+func (s *valueStream) copy(r *bufio.Reader, length int) error {
+	for length > 0 {
+		n := length
+		if n > r.Size() {
+			n = r.Size()
+		}
+		b, err := r.Peek(n)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if s.err == nil {
+			var written int
+			written, s.err = s.w.Write(b)
+			s.written += int64(written)
+		}
+		_, _ = r.Discard(n)
+		length -= n
+	}
+	return nil
+}
+
+// addValueStream registers the stream carried by ctx, if any, for the
+// response to the request with the given message ID
+func (l *Conn) addValueStream(ctx context.Context, messageID int64) {
+	stream, ok := ctx.Value(valueStreamKey{}).(*valueStream)
+	if !ok {
+		return
+	}
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	if l.valueStreams == nil {
+		l.valueStreams = make(map[int64]*valueStream)
+	}
+	stream.messageID = messageID
+	l.valueStreams[messageID] = stream
+}
+
+func (l *Conn) removeValueStream(stream *valueStream) {
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	if l.valueStreams[stream.messageID] == stream {
+		delete(l.valueStreams, stream.messageID)
+	}
+}
+
+// peekValueStream returns the stream of the next response buffered in r if
+// it is a search result entry which can be streamed
+func (l *Conn) peekValueStream(r *bufio.Reader) *valueStream {
+	// streams are registered before their request is sent, so they are
+	// known once the response starts to arrive
+	if _, err := r.Peek(1); err != nil {
+		return nil
+	}
+	l.messageMutex.Lock()
+	pending := len(l.valueStreams) > 0
+	l.messageMutex.Unlock()
+	if !pending {
+		return nil
+	}
+	messageID, ok := peekStreamableEntry(r)
+	if !ok {
+		return nil
+	}
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	return l.valueStreams[messageID]
+}
+
+// stickyErrorReader returns the first error of r from all later reads, as
+// bufio.Reader passes on an error only once and it must not be lost when it
+// occurs while peeking at a response
+type stickyErrorReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *stickyErrorReader) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.r.Read(p)
+	s.err = err
+	return n, err
+}
+
+// The identifiers of the elements of search result entries
+const (
+	sequenceIdentifier    = byte(ber.ClassUniversal) | byte(ber.TypeConstructed) | byte(ber.TagSequence)
+	setIdentifier         = byte(ber.ClassUniversal) | byte(ber.TypeConstructed) | byte(ber.TagSet)
+	integerIdentifier     = byte(ber.ClassUniversal) | byte(ber.TypePrimitive) | byte(ber.TagInteger)
+	octetStringIdentifier = byte(ber.ClassUniversal) | byte(ber.TypePrimitive) | byte(ber.TagOctetString)
+	entryIdentifier       = byte(ber.ClassApplication) | byte(ber.TypeConstructed) | byte(ApplicationSearchResultEntry)
+)
+
+// peekElement returns the identifier byte, the header size and the content
+// length of the element starting offset bytes into the buffered data of r
+func peekElement(r *bufio.Reader, offset, limit int) (byte, int, int, error) {
+	size, length, err := peekHeader(r, offset, limit)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if offset+size+length > limit {
+		return 0, 0, 0, io.ErrUnexpectedEOF
+	}
+	b, err := r.Peek(offset + 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return b[offset], size, length, nil
+}
+
+// peekStreamableEntry returns the message ID of the next message buffered in
+// r if it is a search result entry encoded with definite lengths up to its
+// attributes
+func peekStreamableEntry(r *bufio.Reader) (int64, bool) {
+	identifier, size, length, err := peekElement(r, 0, maxInt)
+	if err != nil || identifier != sequenceIdentifier {
+		return 0, false
+	}
+	limit := size + length
+	offset := size
+
+	identifier, size, length, err = peekElement(r, offset, limit)
+	if err != nil || identifier != integerIdentifier || length == 0 || length > 8 {
+		return 0, false
+	}
+	b, err := r.Peek(offset + size + length)
+	if err != nil {
+		return 0, false
+	}
+	messageID, err := ber.ParseInt64(b[offset+size:])
+	if err != nil {
+		return 0, false
+	}
+	offset += size + length
+
+	identifier, size, _, err = peekElement(r, offset, limit)
+	if err != nil || identifier != entryIdentifier {
+		return 0, false
+	}
+	offset += size
+	identifier, size, length, err = peekElement(r, offset, limit)
+	if err != nil || identifier != octetStringIdentifier {
+		return 0, false
+	}
+	offset += size + length
+	identifier, _, _, err = peekElement(r, offset, limit)
+	if err != nil || identifier != sequenceIdentifier {
+		return 0, false
+	}
+	return messageID, true
+}
+
+// readStreamedEntry reads the search result entry checked by
+// peekStreamableEntry, copying the first value of the attribute of stream to
+// its writer. The returned packet holds the attribute without values.
+func readStreamedEntry(r *bufio.Reader, stream *valueStream) (*ber.Packet, error) {
+	size, length, err := peekHeader(r, 0, maxInt)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = r.Discard(size)
+	s := &entryStreamer{r: r, remaining: length}
+
+	messageID, err := s.element()
+	if err != nil {
+		return nil, err
+	}
+	_, opLength, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	opEnd := s.remaining - opLength
+	objectName, err := s.element()
+	if err != nil {
+		return nil, err
+	}
+	_, attributesLength, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	attributesEnd := s.remaining - attributesLength
+	if attributesEnd < opEnd {
+		return nil, errStreamedEntryLength
+	}
+	var attributes []byte
+	for s.remaining > attributesEnd {
+		attribute, err := s.attribute(stream)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute...)
+	}
+	if s.remaining != attributesEnd {
+		return nil, errStreamedEntryLength
+	}
+	opTail, err := s.contents(s.remaining - opEnd)
+	if err != nil {
+		return nil, err
+	}
+	controls, err := s.contents(s.remaining)
+	if err != nil {
+		return nil, err
+	}
+
+	op := wrapElement(ber.Identifier{ClassType: ber.ClassApplication, TagType: ber.TypeConstructed, Tag: ApplicationSearchResultEntry},
+		objectName, wrapElement(sequence, attributes), opTail)
+	return decodePacket(wrapElement(sequence, messageID, op, controls))
+}
+
+var errStreamedEntryLength = errors.New("ldap: invalid element length in search result entry")
+
+var sequence = ber.Identifier{ClassType: ber.ClassUniversal, TagType: ber.TypeConstructed, Tag: ber.TagSequence}
+
+// wrapElement returns the encoding of the element with the given identifier
+// and contents
+func wrapElement(identifier ber.Identifier, contents ...[]byte) []byte {
+	length := 0
+	for _, content := range contents {
+		length += len(content)
+	}
+	var buf bytes.Buffer
+	buf.Grow(length + 16)
+	appendHeader(&buf, identifier, length)
+	for _, content := range contents {
+		buf.Write(content)
+	}
+	return buf.Bytes()
+}
+
+// entryStreamer reads the elements of a search result entry
+type entryStreamer struct {
+	r *bufio.Reader
+	// remaining is the number of unread bytes of the message
+	remaining int
+}
+
+// header consumes the header of the next element and returns its identifier
+// byte and content length
+func (s *entryStreamer) header() (byte, int, error) {
+	identifier, size, length, err := peekElement(s.r, 0, s.remaining)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, _ = s.r.Discard(size)
+	s.remaining -= size
+	return identifier, length, nil
+}
+
+// element reads the next element
+func (s *entryStreamer) element() ([]byte, error) {
+	size, length, err := peekHeader(s.r, 0, s.remaining)
+	if err == errIndefiniteMessage {
+		var buf bytes.Buffer
+		_, err := ber.ReadPacket(io.TeeReader(io.LimitReader(s.r, int64(s.remaining)), &buf))
+		s.remaining -= buf.Len()
+		return buf.Bytes(), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.contents(size + length)
+}
+
+// contents reads the next n bytes
+func (s *entryStreamer) contents(n int) ([]byte, error) {
+	if n > s.remaining {
+		return nil, errStreamedEntryLength
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	s.remaining -= n
+	return data, nil
+}
+
+// attribute reads the next attribute. The first value of the attribute of
+// stream is copied to its writer and the attribute is returned without
+// values.
+func (s *entryStreamer) attribute(stream *valueStream) ([]byte, error) {
+	if stream.streamed || !s.streamable(stream) {
+		return s.element()
+	}
+
+	_, attributeLength, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	attributeEnd := s.remaining - attributeLength
+	attributeType, err := s.element()
+	if err != nil {
+		return nil, err
+	}
+	_, valuesLength, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	valuesEnd := s.remaining - valuesLength
+	if valuesEnd < attributeEnd {
+		return nil, errStreamedEntryLength
+	}
+	for s.remaining > valuesEnd {
+		_, length, err := s.header()
+		if err != nil {
+			return nil, err
+		}
+		if stream.streamed {
+			_, err = s.r.Discard(length)
+			err = unexpectedEOF(err)
+		} else {
+			stream.streamed = true
+			err = stream.copy(s.r, length)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.remaining -= length
+	}
+	if s.remaining != valuesEnd {
+		return nil, errStreamedEntryLength
+	}
+	tail, err := s.contents(s.remaining - attributeEnd)
+	if err != nil {
+		return nil, err
+	}
+	values := wrapElement(ber.Identifier{ClassType: ber.ClassUniversal, TagType: ber.TypeConstructed, Tag: ber.TagSet})
+	return wrapElement(sequence, attributeType, values, tail), nil
+}
+
+// streamable returns true if the next attribute is the attribute of stream
+// and its values are encoded with definite lengths
+func (s *entryStreamer) streamable(stream *valueStream) bool {
+	identifier, size, length, err := peekElement(s.r, 0, s.remaining)
+	if err != nil || identifier != sequenceIdentifier {
+		return false
+	}
+	limit := size + length
+	identifier, typeSize, typeLength, err := peekElement(s.r, size, limit)
+	if err != nil || identifier != octetStringIdentifier {
+		return false
+	}
+	b, err := s.r.Peek(size + typeSize + typeLength)
+	if err != nil || !stream.matches(string(b[size+typeSize:])) {
+		return false
+	}
+	identifier, _, _, err = peekElement(s.r, size+typeSize+typeLength, limit)
+	return err == nil && identifier == setIdentifier
+}
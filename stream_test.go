@@ -0,0 +1,118 @@
+package ldap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkWriter records the writes of StreamAttributeValue
+type chunkWriter struct {
+	bytes.Buffer
+	writes int
+	err    error
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.Buffer.Write(p)
+}
+
+// respondToStream answers the search of StreamAttributeValue with entry,
+// returning the requested attribute
+func respondToStream(ptc *packetTranslatorConn, entry *Entry, attribute chan<- string) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return
+	}
+	attribute <- req.Children[1].Children[7].Children[0].Value.(string)
+	sendSearchResult(ptc, req.Children[0].Value.(int64), LDAPResultSuccess, entry)
+}
+
+func TestStreamAttributeValue(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	photo := bytes.Repeat([]byte{0xff, 0xd8, 0x00}, 10000)
+	entry := NewEntry("cn=a,dc=example,dc=com", map[string][]string{
+		"cn":        {"a"},
+		"jpegPhoto": {string(photo), "second photo"},
+		"sn":        {"b"},
+	})
+	attribute := make(chan string, 1)
+	go respondToStream(ptc, entry, attribute)
+	var w chunkWriter
+	n, err := conn.StreamAttributeValue("cn=a,dc=example,dc=com", "jpegPhoto", &w)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(photo)), n)
+	assert.Equal(t, photo, w.Bytes())
+	assert.Equal(t, "jpegPhoto", <-attribute)
+	// the value is written while it is read
+	assert.True(t, w.writes > 1, "the value was written at once")
+
+	// certificates are requested with the binary transfer option
+	entry = NewEntry("cn=a,dc=example,dc=com", map[string][]string{
+		"userCertificate;binary": {"\x30\x82\x01\x00"},
+	})
+	go respondToStream(ptc, entry, attribute)
+	w.Reset()
+	_, err = conn.StreamAttributeValue("cn=a,dc=example,dc=com", "userCertificate", &w)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x30\x82\x01\x00"), w.Bytes())
+	assert.Equal(t, "userCertificate;binary", <-attribute)
+
+	go respondToStream(ptc, NewEntry("cn=a,dc=example,dc=com", nil), attribute)
+	_, err = conn.StreamAttributeValue("cn=a,dc=example,dc=com", "jpegPhoto", &w)
+	assert.Equal(t, ErrAttributeNotFound, err)
+	<-attribute
+
+	// a failing writer does not break the connection
+	writeErr := errors.New("disk full")
+	entry = NewEntry("cn=a,dc=example,dc=com", map[string][]string{"jpegPhoto": {string(photo)}})
+	go respondToStream(ptc, entry, attribute)
+	_, err = conn.StreamAttributeValue("cn=a,dc=example,dc=com", "jpegPhoto", &chunkWriter{err: writeErr})
+	assert.Equal(t, writeErr, err)
+	<-attribute
+	go respondToSearchWithEntries(ptc, LDAPResultSuccess, entry)
+	result, err := conn.Search(NewSearchRequest("cn=a,dc=example,dc=com", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil))
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(result.Entries)) {
+		assert.Equal(t, photo, result.Entries[0].GetRawAttributeValue("jpegPhoto"))
+	}
+}
+
+func TestStreamAttributeValueIndefiniteLength(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		messageID := req.Children[0].Value.(int64)
+		// the jpegPhoto attribute has an indefinite length
+		sendRawResponse(ptc, []byte{0x30, 0x20, 0x02, 0x01, byte(messageID),
+			0x64, 0x1b, 0x04, 0x01, 'x',
+			0x30, 0x16, 0x30, 0x80, 0x04, 0x09, 'j', 'p', 'e', 'g', 'P', 'h', 'o', 't', 'o',
+			0x31, 0x05, 0x04, 0x03, 'a', 'b', 'c', 0x00, 0x00})
+		sendSearchResult(ptc, messageID, LDAPResultSuccess)
+	}()
+	var w bytes.Buffer
+	n, err := conn.StreamAttributeValue("x", "jpegPhoto", &w)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, "abc", w.String())
+}
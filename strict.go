@@ -0,0 +1,127 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrWeakMechanism is returned by binds with a mechanism which is not
+// allowed in strict crypto mode, see SetStrictCrypto
+var ErrWeakMechanism = errors.New("ldap: bind mechanism not allowed in strict crypto mode")
+
+// strictCrypto is 1 if strict crypto mode is enabled, it is loaded atomically
+var strictCrypto uint32
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved by FIPS 140-2
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the elliptic curves approved by FIPS 140-2
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// SetStrictCrypto enables or disables strict crypto mode for all
+// connections of the process. In strict crypto mode
+//
+//   - TLS connections, including those started with StartTLS, are limited
+//     to TLS 1.2 and the FIPS approved cipher suites and curves. TLS 1.3 is
+//     disabled, as its cipher suites cannot be restricted.
+//   - DIGEST-MD5 and NTLM binds fail with ErrWeakMechanism. CRAM-MD5 is not
+//     supported at all.
+//   - Simple binds with a password fail with ErrTLSRequired unless the
+//     connection uses TLS, a unix socket or a SASL security layer with
+//     confidentiality, see GSSAPIBindRequest.SecurityLayer.
+//
+// It does not make the cryptography of the Go runtime FIPS validated.
+func SetStrictCrypto(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&strictCrypto, v)
+}
+
+// StrictCrypto returns whether strict crypto mode is enabled
+func StrictCrypto() bool {
+	return atomic.LoadUint32(&strictCrypto) == 1
+}
+
+// strictTLSConfig returns config restricted to the FIPS approved versions,
+// cipher suites and curves if strict crypto mode is enabled, and config
+// itself otherwise. config is not modified.
+func strictTLSConfig(config *tls.Config) *tls.Config {
+	if !StrictCrypto() {
+		return config
+	}
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.MinVersion = tls.VersionTLS12
+	config.MaxVersion = tls.VersionTLS12
+	config.CipherSuites = intersectUint16(config.CipherSuites, fipsCipherSuites)
+	curves := make([]uint16, len(config.CurvePreferences))
+	for i, curve := range config.CurvePreferences {
+		curves[i] = uint16(curve)
+	}
+	allowed := make([]uint16, len(fipsCurves))
+	for i, curve := range fipsCurves {
+		allowed[i] = uint16(curve)
+	}
+	config.CurvePreferences = nil
+	for _, curve := range intersectUint16(curves, allowed) {
+		config.CurvePreferences = append(config.CurvePreferences, tls.CurveID(curve))
+	}
+	return config
+}
+
+// intersectUint16 returns the values of configured which are allowed, in the
+// order of configured, or allowed if there are none, as an empty list would
+// select the defaults of crypto/tls
+func intersectUint16(configured, allowed []uint16) []uint16 {
+	var values []uint16
+	for _, v := range configured {
+		for _, a := range allowed {
+			if v == a {
+				values = append(values, v)
+				break
+			}
+		}
+	}
+	if len(values) == 0 {
+		return append(values, allowed...)
+	}
+	return values
+}
+
+// checkStrictMechanism returns ErrWeakMechanism for binds with mechanism in
+// strict crypto mode
+func checkStrictMechanism(mechanism string) error {
+	if StrictCrypto() {
+		return fmt.Errorf("%w: %s", ErrWeakMechanism, mechanism)
+	}
+	return nil
+}
+
+// checkStrictConfidentiality returns ErrTLSRequired in strict crypto mode if
+// secrets sent on the connection would not be protected
+func (l *Conn) checkStrictConfidentiality() error {
+	if !StrictCrypto() || l.isTLS || l.hasSASLConfidentiality() {
+		return nil
+	}
+	l.messageMutex.Lock()
+	conn := l.conn
+	l.messageMutex.Unlock()
+	if addr := conn.LocalAddr(); addr != nil && addr.Network() == "unix" {
+		return nil
+	}
+	return ErrTLSRequired
+}
@@ -0,0 +1,75 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictTLSConfig(t *testing.T) {
+	config := &tls.Config{
+		ServerName:       "ldap.example.com",
+		CipherSuites:     []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		CurvePreferences: []tls.CurveID{tls.X25519},
+	}
+	assert.True(t, strictTLSConfig(config) == config)
+
+	SetStrictCrypto(true)
+	defer SetStrictCrypto(false)
+	strict := strictTLSConfig(config)
+	assert.Equal(t, "ldap.example.com", strict.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS12), strict.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), strict.MaxVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, strict.CipherSuites)
+	assert.Equal(t, fipsCurves, strict.CurvePreferences)
+	// the config of the caller is not modified
+	assert.Equal(t, uint16(0), config.MinVersion)
+	assert.Equal(t, []tls.CurveID{tls.X25519}, config.CurvePreferences)
+
+	assert.Equal(t, fipsCipherSuites, strictTLSConfig(nil).CipherSuites)
+}
+
+// unixConn is a packetTranslatorConn reporting a unix socket address
+type unixConn struct {
+	*packetTranslatorConn
+}
+
+func (c unixConn) LocalAddr() net.Addr {
+	return &net.UnixAddr{Name: "/var/run/slapd/ldapi", Net: "unix"}
+}
+
+func TestStrictCryptoBinds(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	SetStrictCrypto(true)
+	defer SetStrictCrypto(false)
+
+	assert.True(t, errors.Is(conn.Bind("cn=a,dc=example,dc=com", "secret"), ErrTLSRequired))
+	assert.True(t, errors.Is(conn.MD5Bind("ldap.example.com", "a", "secret"), ErrWeakMechanism))
+	assert.True(t, errors.Is(conn.NTLMBind("EXAMPLE", "a", "secret"), ErrWeakMechanism))
+
+	// binds without secrets are allowed
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.UnauthenticatedBind("cn=a,dc=example,dc=com"))
+
+	// TLS and unix sockets are confidential
+	conn.isTLS = true
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Bind("cn=a,dc=example,dc=com", "secret"))
+
+	ptc2 := newPacketTranslatorConn()
+	defer ptc2.Close()
+	unix := NewConn(unixConn{ptc2}, false)
+	unix.Start()
+	defer unix.Close()
+	go respondWithResult(ptc2, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, unix.Bind("cn=a,dc=example,dc=com", "secret"))
+}
@@ -0,0 +1,284 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// SyncInfoOID is the name of the Sync Info intermediate response message
+// defined in https://tools.ietf.org/html/rfc4533
+const SyncInfoOID = "1.3.6.1.4.1.4203.1.9.1.4"
+
+// UUID is the entryUUID of an entry as sent by content synchronization
+type UUID [16]byte
+
+// String returns the UUID in the form defined in https://tools.ietf.org/html/rfc4122
+func (u UUID) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// parseUUID returns the UUID encoded in the given octet string
+func parseUUID(data []byte) (UUID, error) {
+	var u UUID
+	if len(data) != len(u) {
+		return u, fmt.Errorf("ldap: invalid entryUUID length %d", len(data))
+	}
+	copy(u[:], data)
+	return u, nil
+}
+
+// octetBytes returns a copy of the content of an octet string packet
+func octetBytes(packet *ber.Packet) []byte {
+	return append([]byte{}, packet.Data.Bytes()...)
+}
+
+// decodeSyncControl decodes the values of the content synchronization
+// controls
+func decodeSyncControl(controlType string, criticality bool, children []*ber.Packet) (Control, error) {
+	switch controlType {
+	case ControlTypeSyncRequest:
+		if len(children) == 0 {
+			return nil, errors.New("ldap: sync request control requires a mode")
+		}
+		children[0].Description = "Mode"
+		mode, ok := children[0].Value.(int64)
+		if !ok {
+			return nil, errors.New("ldap: invalid sync request mode")
+		}
+		c := &ControlSyncRequest{Criticality: criticality, Mode: SyncMode(mode)}
+		for _, child := range children[1:] {
+			switch child.Tag {
+			case ber.TagOctetString:
+				child.Description = "Cookie"
+				c.Cookie = octetBytes(child)
+			case ber.TagBoolean:
+				child.Description = "Reload Hint"
+				c.ReloadHint, _ = child.Value.(bool)
+			}
+		}
+		return c, nil
+	case ControlTypeSyncState:
+		if len(children) < 2 {
+			return nil, errors.New("ldap: sync state control requires a state and an entryUUID")
+		}
+		children[0].Description = "State"
+		state, ok := children[0].Value.(int64)
+		if !ok {
+			return nil, errors.New("ldap: invalid sync state")
+		}
+		children[1].Description = "Entry UUID"
+		uuid, err := parseUUID(children[1].Data.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		c := &ControlSyncState{State: SyncState(state), EntryUUID: uuid}
+		if len(children) > 2 {
+			children[2].Description = "Cookie"
+			c.Cookie = octetBytes(children[2])
+		}
+		return c, nil
+	default:
+		c := &ControlSyncDone{}
+		for _, child := range children {
+			switch child.Tag {
+			case ber.TagOctetString:
+				child.Description = "Cookie"
+				c.Cookie = octetBytes(child)
+			case ber.TagBoolean:
+				child.Description = "Refresh Deletes"
+				c.RefreshDeletes, _ = child.Value.(bool)
+			}
+		}
+		return c, nil
+	}
+}
+
+// SyncInfoType is the kind of a SyncInfo message
+type SyncInfoType int
+
+// Sync Info message kinds as defined in https://tools.ietf.org/html/rfc4533
+const (
+	SyncInfoNewCookie      SyncInfoType = 0
+	SyncInfoRefreshDelete  SyncInfoType = 1
+	SyncInfoRefreshPresent SyncInfoType = 2
+	SyncInfoIDSet          SyncInfoType = 3
+)
+
+// SyncInfo is a Sync Info intermediate response message sent by the server
+// during content synchronization
+type SyncInfo struct {
+	Type   SyncInfoType
+	Cookie []byte
+	// RefreshDone is set for SyncInfoRefreshDelete and SyncInfoRefreshPresent
+	// messages if the refresh stage is complete
+	RefreshDone bool
+	// RefreshDeletes is set for SyncInfoIDSet messages if the entries with
+	// the UUIDs were deleted rather than are present
+	RefreshDeletes bool
+	UUIDs          []UUID
+}
+
+// ParseSyncInfo decodes the value of a Sync Info message
+func ParseSyncInfo(data []byte) (*SyncInfo, error) {
+	packet, err := ber.DecodePacketErr(data)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: failed to decode sync info: %s", err)
+	}
+	if packet.ClassType != ber.ClassContext || packet.Tag > ber.Tag(SyncInfoIDSet) {
+		return nil, fmt.Errorf("ldap: unknown sync info message %d", packet.Tag)
+	}
+
+	info := &SyncInfo{Type: SyncInfoType(packet.Tag)}
+	if info.Type == SyncInfoNewCookie {
+		info.Cookie = octetBytes(packet)
+		return info, nil
+	}
+	// refreshDone defaults to true
+	info.RefreshDone = info.Type != SyncInfoIDSet
+	for _, child := range packet.Children {
+		switch child.Tag {
+		case ber.TagOctetString:
+			info.Cookie = octetBytes(child)
+		case ber.TagBoolean:
+			value, _ := child.Value.(bool)
+			if info.Type == SyncInfoIDSet {
+				info.RefreshDeletes = value
+			} else {
+				info.RefreshDone = value
+			}
+		case ber.TagSet:
+			for _, item := range child.Children {
+				uuid, err := parseUUID(item.Data.Bytes())
+				if err != nil {
+					return nil, err
+				}
+				info.UUIDs = append(info.UUIDs, uuid)
+			}
+		}
+	}
+	return info, nil
+}
+
+// Syncrepl performs the content synchronization operation described in
+// https://tools.ietf.org/html/rfc4533 with the given search request and Sync
+// Request control. onEntry is called for every entry with its Sync State
+// control, onInfo for every Sync Info message; either may be nil.
+//
+// In refreshOnly mode the Sync Done control of the final response is
+// returned. In refreshAndPersist mode the operation only ends with an error,
+// e.g. when ctx is canceled, which abandons it on the server. If a callback
+// returns an error, the operation is abandoned as well and the error is
+// returned.
+func (l *Conn) Syncrepl(ctx context.Context, searchRequest *SearchRequest, control *ControlSyncRequest, onEntry func(*Entry, *ControlSyncState) error, onInfo func(*SyncInfo) error) (*ControlSyncDone, error) {
+	req := *searchRequest
+	req.Controls = append(append([]Control{}, searchRequest.Controls...), control)
+
+	msgCtx, err := l.doRequestContext(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+	var finishOnce sync.Once
+	finish := func() {
+		finishOnce.Do(func() {
+			l.finishMessage(msgCtx)
+		})
+	}
+	abandon := false
+	stop := make(chan struct{})
+	defer func() {
+		close(stop)
+		// the search must be finished first, as pending responses would
+		// otherwise block sending the abandon request
+		finish()
+		if !abandon && ctx.Err() == nil {
+			return
+		}
+		if err := l.abandon(msgCtx.id); err != nil {
+			l.Debug.Printf("%d: failed to abandon sync: %s", msgCtx.id, err)
+		}
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			// unblocks the pending read below
+			finish()
+		case <-stop:
+		}
+	}()
+
+	for {
+		packet, err := l.readPacket(msgCtx)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+
+		switch packet.Children[1].Tag {
+		case ApplicationSearchResultEntry:
+			controls, err := decodeResponseControls(packet)
+			if err != nil {
+				return nil, err
+			}
+			state, _ := FindControl(controls, ControlTypeSyncState).(*ControlSyncState)
+			if state == nil {
+				return nil, errors.New("ldap: sync entry without sync state control")
+			}
+			if onEntry == nil {
+				continue
+			}
+			entry, err := decodeEntry(packet, searchRequest.valueDecoding())
+			if err != nil {
+				abandon = true
+				return nil, err
+			}
+			if err := onEntry(entry, state); err != nil {
+				abandon = true
+				return nil, err
+			}
+		case ApplicationIntermediateResponse:
+			var name string
+			var value []byte
+			for _, child := range packet.Children[1].Children {
+				switch child.Tag {
+				case 0:
+					name = child.Data.String()
+				case 1:
+					value = child.Data.Bytes()
+				}
+			}
+			if name != SyncInfoOID {
+				continue
+			}
+			info, err := ParseSyncInfo(value)
+			if err != nil {
+				return nil, err
+			}
+			if onInfo == nil {
+				continue
+			}
+			if err := onInfo(info); err != nil {
+				abandon = true
+				return nil, err
+			}
+		case ApplicationSearchResultDone:
+			if err := GetLDAPError(packet); err != nil {
+				return nil, err
+			}
+			controls, err := decodeResponseControls(packet)
+			if err != nil {
+				return nil, err
+			}
+			done, _ := FindControl(controls, ControlTypeSyncDone).(*ControlSyncDone)
+			if done == nil {
+				done = &ControlSyncDone{}
+			}
+			return done, nil
+		}
+	}
+}
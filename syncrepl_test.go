@@ -0,0 +1,148 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// sendSyncEntry sends an entry with a sync state control as response to the
+// sync operation with the given message ID
+func sendSyncEntry(ptc *packetTranslatorConn, messageID int64, dn string, state *ControlSyncState) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "Object Name"))
+	entry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+	packet.AppendChild(entry)
+	packet.AppendChild(encodeControls([]Control{state}))
+	_ = ptc.SendResponse(packet)
+}
+
+// sendSyncInfo sends a sync info message with the given value
+func sendSyncInfo(ptc *packetTranslatorConn, messageID int64, info *ber.Packet) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationIntermediateResponse, nil, "Intermediate Response")
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, SyncInfoOID, "Response Name"))
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 1, string(info.Bytes()), "Response Value"))
+	packet.AppendChild(response)
+	_ = ptc.SendResponse(packet)
+}
+
+// sendSyncDone ends the sync operation with the given sync done control
+func sendSyncDone(ptc *packetTranslatorConn, messageID int64, done *ControlSyncDone) {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(LDAPResultSuccess), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(result)
+	packet.AppendChild(encodeControls([]Control{done}))
+	_ = ptc.SendResponse(packet)
+}
+
+func TestParseSyncInfo(t *testing.T) {
+	info, err := ParseSyncInfo(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "cookie", "newcookie").Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != SyncInfoNewCookie || string(info.Cookie) != "cookie" {
+		t.Errorf("unexpected sync info %+v", info)
+	}
+
+	present := ber.Encode(ber.ClassContext, ber.TypeConstructed, 2, nil, "refreshPresent")
+	present.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cookie", "cookie"))
+	info, err = ParseSyncInfo(present.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != SyncInfoRefreshPresent || !info.RefreshDone || string(info.Cookie) != "cookie" {
+		t.Errorf("unexpected sync info %+v", info)
+	}
+
+	if _, err := ParseSyncInfo(ber.Encode(ber.ClassContext, ber.TypeConstructed, 4, nil, "unknown").Bytes()); err == nil {
+		t.Error("expected error for unknown sync info message")
+	}
+}
+
+type memoryCookieStore struct {
+	cookie []byte
+}
+
+func (s *memoryCookieStore) LoadCookie() ([]byte, error) {
+	return s.cookie, nil
+}
+
+func (s *memoryCookieStore) SaveCookie(cookie []byte) error {
+	s.cookie = cookie
+	return nil
+}
+
+func TestSyncReplConsumer(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	requests := make(chan *ControlSyncRequest, 1)
+	go func() {
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		if control, err := DecodeControl(req.Children[2].Children[0]); err == nil {
+			requests <- control.(*ControlSyncRequest)
+		}
+		messageID := req.Children[0].Value.(int64)
+		sendSyncEntry(ptc, messageID, "cn=a,dc=example", &ControlSyncState{State: SyncStateAdd, EntryUUID: UUID{1}, Cookie: []byte("c1")})
+		sendSyncEntry(ptc, messageID, "cn=b,dc=example", &ControlSyncState{State: SyncStatePresent, EntryUUID: UUID{2}})
+
+		idSet := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "syncIdSet")
+		idSet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "refreshDeletes"))
+		uuids := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "syncUUIDs")
+		uuids.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string([]byte{4, 15: 0}), "syncUUID"))
+		idSet.AppendChild(uuids)
+		sendSyncInfo(ptc, messageID, idSet)
+
+		sendSyncDone(ptc, messageID, &ControlSyncDone{Cookie: []byte("c2")})
+	}()
+
+	dials := 0
+	consumer := NewSyncReplConsumer(func() (*Conn, error) {
+		dials++
+		if dials == 1 {
+			return nil, NewError(ErrorNetwork, errors.New("connection refused"))
+		}
+		conn := NewConn(ptc, false)
+		conn.Start()
+		return conn, nil
+	}, NewSearchRequest("dc=example", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(objectClass=*)", nil, nil), SyncModeRefreshOnly)
+	consumer.RetryDelay = time.Millisecond
+	store := &memoryCookieStore{cookie: []byte("c0")}
+	consumer.CookieStore = store
+	consumer.Track(UUID{3}, "cn=c,dc=example")
+	consumer.Track(UUID{4}, "cn=d,dc=example")
+
+	var events []string
+	err := consumer.Run(context.Background(), func(event *SyncEvent) error {
+		events = append(events, []string{"add", "modify", "delete", "present"}[event.Type]+" "+event.DN)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"add cn=a,dc=example", "present cn=b,dc=example", "delete cn=d,dc=example", "delete cn=c,dc=example"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+	if request := <-requests; request.Mode != SyncModeRefreshOnly || string(request.Cookie) != "c0" {
+		t.Errorf("unexpected sync request %+v", request)
+	}
+	if string(store.cookie) != "c2" {
+		t.Errorf("expected cookie c2 to be stored, got %q", store.cookie)
+	}
+}
@@ -0,0 +1,257 @@
+package ldap
+
+import (
+	"context"
+	"time"
+)
+
+// SyncEventType is the kind of a SyncEvent
+type SyncEventType int
+
+// Event kinds delivered by SyncReplConsumer
+const (
+	SyncEventAdd SyncEventType = iota
+	SyncEventModify
+	SyncEventDelete
+	SyncEventPresent
+)
+
+// SyncEvent is a change delivered by SyncReplConsumer
+type SyncEvent struct {
+	Type SyncEventType
+	UUID UUID
+	// DN is the DN of the entry. For deletions reported by UUID only it is
+	// the last DN seen for the UUID, if any.
+	DN string
+	// Entry is the entry with its attributes for SyncEventAdd and
+	// SyncEventModify events
+	Entry *Entry
+}
+
+// SyncCookieStore persists the synchronization state of a SyncReplConsumer
+type SyncCookieStore interface {
+	// LoadCookie returns the stored cookie, or nil if there is none
+	LoadCookie() ([]byte, error)
+	// SaveCookie stores the given cookie
+	SaveCookie(cookie []byte) error
+}
+
+// DefaultSyncRetryDelay is the default delay before a SyncReplConsumer
+// reconnects after losing its connection
+const DefaultSyncRetryDelay = 5 * time.Second
+
+// SyncReplConsumer keeps a replica in sync with a directory using content
+// synchronization (RFC 4533). It reconnects when the connection is lost,
+// resuming from the last cookie, and turns the present and delete phases of
+// refreshes into explicit events, so the handler only needs to apply them.
+//
+// To detect entries deleted while it was not running, the consumer needs to
+// know the entries of the replica: those seen since it was created are
+// tracked automatically, others must be registered with Track.
+type SyncReplConsumer struct {
+	// Dial returns a new, bound connection. It is called initially and after
+	// the connection was lost.
+	Dial          func() (*Conn, error)
+	SearchRequest *SearchRequest
+	Mode          SyncMode
+	// CookieStore persists the cookie if set
+	CookieStore SyncCookieStore
+	// RetryDelay is the delay before reconnecting, DefaultSyncRetryDelay if
+	// zero
+	RetryDelay time.Duration
+
+	cookie []byte
+	// known maps the UUIDs of the entries in the replica to their DN
+	known map[UUID]string
+	// present collects the UUIDs reported during the present phase of a
+	// refresh
+	present map[UUID]struct{}
+}
+
+// NewSyncReplConsumer returns a SyncReplConsumer for the given search
+func NewSyncReplConsumer(dial func() (*Conn, error), searchRequest *SearchRequest, mode SyncMode) *SyncReplConsumer {
+	return &SyncReplConsumer{
+		Dial:          dial,
+		SearchRequest: searchRequest,
+		Mode:          mode,
+		known:         make(map[UUID]string),
+	}
+}
+
+// Track registers an entry already present in the replica
+func (c *SyncReplConsumer) Track(uuid UUID, dn string) {
+	if c.known == nil {
+		c.known = make(map[UUID]string)
+	}
+	c.known[uuid] = dn
+}
+
+// Cookie returns the current synchronization state
+func (c *SyncReplConsumer) Cookie() []byte {
+	return c.cookie
+}
+
+// Run synchronizes until ctx is canceled, the handler returns an error, or a
+// refreshOnly synchronization is complete, passing the changes to handler.
+// Connection errors and busy or unavailable servers are retried after
+// RetryDelay. If the server requires a full reload, the cookie is dropped and
+// the content is refreshed from scratch.
+func (c *SyncReplConsumer) Run(ctx context.Context, handler func(*SyncEvent) error) error {
+	if c.known == nil {
+		c.known = make(map[UUID]string)
+	}
+	if c.CookieStore != nil {
+		cookie, err := c.CookieStore.LoadCookie()
+		if err != nil {
+			return err
+		}
+		c.cookie = cookie
+	}
+
+	delay := c.RetryDelay
+	if delay == 0 {
+		delay = DefaultSyncRetryDelay
+	}
+	for {
+		conn, err := c.Dial()
+		if err == nil {
+			err = c.sync(ctx, conn, handler)
+			conn.Close()
+			if err == nil {
+				return nil
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if IsErrorWithCode(err, LDAPResultSyncRefreshRequired) {
+			if err := c.setCookie(nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if !IsErrorAnyOf(err, ErrorNetwork, LDAPResultBusy, LDAPResultUnavailable, LDAPResultServerDown) {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// sync runs one synchronization operation on conn
+func (c *SyncReplConsumer) sync(ctx context.Context, conn *Conn, handler func(*SyncEvent) error) error {
+	c.present = make(map[UUID]struct{})
+
+	onEntry := func(entry *Entry, state *ControlSyncState) error {
+		event := &SyncEvent{UUID: state.EntryUUID, DN: entry.DN, Entry: entry}
+		switch state.State {
+		case SyncStateAdd:
+			event.Type = SyncEventAdd
+		case SyncStateModify:
+			event.Type = SyncEventModify
+		case SyncStateDelete:
+			event.Type, event.Entry = SyncEventDelete, nil
+		default:
+			event.Type, event.Entry = SyncEventPresent, nil
+		}
+		if err := c.apply(event, handler); err != nil {
+			return err
+		}
+		return c.updateCookie(state.Cookie)
+	}
+	onInfo := func(info *SyncInfo) error {
+		switch info.Type {
+		case SyncInfoRefreshPresent:
+			if info.RefreshDone {
+				if err := c.endPresentPhase(handler); err != nil {
+					return err
+				}
+			}
+		case SyncInfoRefreshDelete:
+			c.present = nil
+		case SyncInfoIDSet:
+			for _, uuid := range info.UUIDs {
+				event := &SyncEvent{Type: SyncEventPresent, UUID: uuid, DN: c.known[uuid]}
+				if info.RefreshDeletes {
+					event.Type = SyncEventDelete
+				}
+				if err := c.apply(event, handler); err != nil {
+					return err
+				}
+			}
+		}
+		return c.updateCookie(info.Cookie)
+	}
+
+	done, err := conn.Syncrepl(ctx, c.SearchRequest, NewControlSyncRequest(c.Mode, c.cookie, false), onEntry, onInfo)
+	if err != nil {
+		return err
+	}
+	if !done.RefreshDeletes {
+		if err := c.endPresentPhase(handler); err != nil {
+			return err
+		}
+	}
+	return c.updateCookie(done.Cookie)
+}
+
+// apply updates the tracked entries and passes the event to handler
+func (c *SyncReplConsumer) apply(event *SyncEvent, handler func(*SyncEvent) error) error {
+	if event.Type == SyncEventDelete {
+		if event.DN == "" {
+			event.DN = c.known[event.UUID]
+		}
+		delete(c.known, event.UUID)
+	} else {
+		if event.DN != "" {
+			c.known[event.UUID] = event.DN
+		} else if _, ok := c.known[event.UUID]; !ok {
+			c.known[event.UUID] = ""
+		}
+		if c.present != nil {
+			c.present[event.UUID] = struct{}{}
+		}
+	}
+	return handler(event)
+}
+
+// endPresentPhase delivers deletions for all tracked entries which were not
+// reported during the present phase
+func (c *SyncReplConsumer) endPresentPhase(handler func(*SyncEvent) error) error {
+	present := c.present
+	c.present = nil
+	if present == nil {
+		return nil
+	}
+	for uuid, dn := range c.known {
+		if _, ok := present[uuid]; ok {
+			continue
+		}
+		if err := c.apply(&SyncEvent{Type: SyncEventDelete, UUID: uuid, DN: dn}, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateCookie stores cookie unless it is nil
+func (c *SyncReplConsumer) updateCookie(cookie []byte) error {
+	if cookie == nil {
+		return nil
+	}
+	return c.setCookie(cookie)
+}
+
+func (c *SyncReplConsumer) setCookie(cookie []byte) error {
+	c.cookie = cookie
+	if c.CookieStore == nil {
+		return nil
+	}
+	return c.CookieStore.SaveCookie(cookie)
+}
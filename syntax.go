@@ -0,0 +1,205 @@
+package ldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// well-known syntax OIDs (https://datatracker.ietf.org/doc/html/rfc4517#section-3.3)
+const (
+	SyntaxBitString              = "1.3.6.1.4.1.1466.115.121.1.6"
+	SyntaxBoolean                = "1.3.6.1.4.1.1466.115.121.1.7"
+	SyntaxCountryString          = "1.3.6.1.4.1.1466.115.121.1.11"
+	SyntaxDN                     = "1.3.6.1.4.1.1466.115.121.1.12"
+	SyntaxDirectoryString        = "1.3.6.1.4.1.1466.115.121.1.15"
+	SyntaxGeneralizedTime        = "1.3.6.1.4.1.1466.115.121.1.24"
+	SyntaxIA5String              = "1.3.6.1.4.1.1466.115.121.1.26"
+	SyntaxInteger                = "1.3.6.1.4.1.1466.115.121.1.27"
+	SyntaxJPEG                   = "1.3.6.1.4.1.1466.115.121.1.28"
+	SyntaxNameAndOptionalUID     = "1.3.6.1.4.1.1466.115.121.1.34"
+	SyntaxNumericString          = "1.3.6.1.4.1.1466.115.121.1.36"
+	SyntaxOID                    = "1.3.6.1.4.1.1466.115.121.1.38"
+	SyntaxOctetString            = "1.3.6.1.4.1.1466.115.121.1.40"
+	SyntaxPostalAddress          = "1.3.6.1.4.1.1466.115.121.1.41"
+	SyntaxPrintableString        = "1.3.6.1.4.1.1466.115.121.1.44"
+	SyntaxTelephoneNumber        = "1.3.6.1.4.1.1466.115.121.1.50"
+	SyntaxUTCTime                = "1.3.6.1.4.1.1466.115.121.1.53"
+	SyntaxSubstringAssertion     = "1.3.6.1.4.1.1466.115.121.1.58"
+	SyntaxSecurityDescriptor     = "1.2.840.113556.1.4.907"
+	SyntaxLargeIntegerInterval   = "1.2.840.113556.1.4.906"
+	SyntaxObjectDNBinaryWithData = "1.2.840.113556.1.4.903"
+)
+
+// SyntaxInfo describes an LDAP syntax and how its values are decoded
+type SyntaxInfo struct {
+	// OID is the numeric OID of the syntax
+	OID string
+	// Name is the descriptive name of the syntax, e.g. "Directory String"
+	Name string
+	// Decode converts a raw value into its Go representation. If nil, values
+	// are kept as strings.
+	Decode func(value []byte) (interface{}, error)
+}
+
+// MatchingRuleInfo describes an LDAP matching rule
+type MatchingRuleInfo struct {
+	// OID is the numeric OID of the matching rule
+	OID string
+	// Name is the name of the matching rule, e.g. "caseIgnoreMatch"
+	Name string
+	// Syntax is the OID of the assertion syntax of the matching rule
+	Syntax string
+}
+
+var (
+	registryMutex sync.RWMutex
+	syntaxes      = make(map[string]*SyntaxInfo)
+	matchingRules = make(map[string]*MatchingRuleInfo)
+)
+
+func init() {
+	for _, info := range []*SyntaxInfo{
+		{OID: SyntaxBitString, Name: "Bit String"},
+		{OID: SyntaxBoolean, Name: "Boolean", Decode: decodeBooleanValue},
+		{OID: SyntaxCountryString, Name: "Country String"},
+		{OID: SyntaxDN, Name: "DN", Decode: decodeDNValue},
+		{OID: SyntaxDirectoryString, Name: "Directory String"},
+		{OID: SyntaxGeneralizedTime, Name: "Generalized Time", Decode: decodeGeneralizedTimeValue},
+		{OID: SyntaxIA5String, Name: "IA5 String"},
+		{OID: SyntaxInteger, Name: "Integer", Decode: decodeIntegerValue},
+		{OID: SyntaxJPEG, Name: "JPEG", Decode: decodeOctetStringValue},
+		{OID: SyntaxNameAndOptionalUID, Name: "Name And Optional UID"},
+		{OID: SyntaxNumericString, Name: "Numeric String"},
+		{OID: SyntaxOID, Name: "OID"},
+		{OID: SyntaxOctetString, Name: "Octet String", Decode: decodeOctetStringValue},
+		{OID: SyntaxPostalAddress, Name: "Postal Address"},
+		{OID: SyntaxPrintableString, Name: "Printable String"},
+		{OID: SyntaxTelephoneNumber, Name: "Telephone Number"},
+		{OID: SyntaxUTCTime, Name: "UTC Time", Decode: decodeUTCTimeValue},
+		{OID: SyntaxSubstringAssertion, Name: "Substring Assertion"},
+		{OID: SyntaxSecurityDescriptor, Name: "Object(Security-Descriptor)", Decode: decodeOctetStringValue},
+		{OID: SyntaxLargeIntegerInterval, Name: "Large Integer/Interval", Decode: decodeIntegerValue},
+		{OID: SyntaxObjectDNBinaryWithData, Name: "Object(DN-Binary)"},
+	} {
+		RegisterSyntax(info)
+	}
+
+	for _, info := range []*MatchingRuleInfo{
+		{OID: "2.5.13.0", Name: "objectIdentifierMatch", Syntax: SyntaxOID},
+		{OID: "2.5.13.1", Name: "distinguishedNameMatch", Syntax: SyntaxDN},
+		{OID: "2.5.13.2", Name: "caseIgnoreMatch", Syntax: SyntaxDirectoryString},
+		{OID: "2.5.13.3", Name: "caseIgnoreOrderingMatch", Syntax: SyntaxDirectoryString},
+		{OID: "2.5.13.4", Name: "caseIgnoreSubstringsMatch", Syntax: SyntaxSubstringAssertion},
+		{OID: "2.5.13.5", Name: "caseExactMatch", Syntax: SyntaxDirectoryString},
+		{OID: "2.5.13.6", Name: "caseExactOrderingMatch", Syntax: SyntaxDirectoryString},
+		{OID: "2.5.13.7", Name: "caseExactSubstringsMatch", Syntax: SyntaxSubstringAssertion},
+		{OID: "2.5.13.8", Name: "numericStringMatch", Syntax: SyntaxNumericString},
+		{OID: "2.5.13.9", Name: "numericStringOrderingMatch", Syntax: SyntaxNumericString},
+		{OID: "2.5.13.10", Name: "numericStringSubstringsMatch", Syntax: SyntaxSubstringAssertion},
+		{OID: "2.5.13.11", Name: "caseIgnoreListMatch", Syntax: SyntaxPostalAddress},
+		{OID: "2.5.13.13", Name: "booleanMatch", Syntax: SyntaxBoolean},
+		{OID: "2.5.13.14", Name: "integerMatch", Syntax: SyntaxInteger},
+		{OID: "2.5.13.15", Name: "integerOrderingMatch", Syntax: SyntaxInteger},
+		{OID: "2.5.13.17", Name: "octetStringMatch", Syntax: SyntaxOctetString},
+		{OID: "2.5.13.18", Name: "octetStringOrderingMatch", Syntax: SyntaxOctetString},
+		{OID: "2.5.13.20", Name: "telephoneNumberMatch", Syntax: SyntaxTelephoneNumber},
+		{OID: "2.5.13.21", Name: "telephoneNumberSubstringsMatch", Syntax: SyntaxSubstringAssertion},
+		{OID: "2.5.13.23", Name: "uniqueMemberMatch", Syntax: SyntaxNameAndOptionalUID},
+		{OID: "2.5.13.27", Name: "generalizedTimeMatch", Syntax: SyntaxGeneralizedTime},
+		{OID: "2.5.13.28", Name: "generalizedTimeOrderingMatch", Syntax: SyntaxGeneralizedTime},
+		{OID: "1.3.6.1.4.1.1466.109.114.1", Name: "caseExactIA5Match", Syntax: SyntaxIA5String},
+		{OID: "1.3.6.1.4.1.1466.109.114.2", Name: "caseIgnoreIA5Match", Syntax: SyntaxIA5String},
+		{OID: "1.3.6.1.4.1.1466.109.114.3", Name: "caseIgnoreIA5SubstringsMatch", Syntax: SyntaxSubstringAssertion},
+	} {
+		RegisterMatchingRule(info)
+	}
+}
+
+// RegisterSyntax adds or replaces a syntax in the registry used by
+// LookupSyntax, e.g. for vendor specific syntaxes
+func RegisterSyntax(info *SyntaxInfo) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	syntaxes[info.OID] = info
+	syntaxes[strings.ToLower(info.Name)] = info
+}
+
+// LookupSyntax returns the registered syntax with the given OID or name.
+// Names are matched case-insensitively.
+func LookupSyntax(nameOrOID string) (*SyntaxInfo, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	if info, ok := syntaxes[nameOrOID]; ok {
+		return info, true
+	}
+	info, ok := syntaxes[strings.ToLower(nameOrOID)]
+	return info, ok
+}
+
+// RegisterMatchingRule adds or replaces a matching rule in the registry used
+// by LookupMatchingRule
+func RegisterMatchingRule(info *MatchingRuleInfo) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	matchingRules[info.OID] = info
+	matchingRules[strings.ToLower(info.Name)] = info
+}
+
+// LookupMatchingRule returns the registered matching rule with the given OID
+// or name. Names are matched case-insensitively.
+func LookupMatchingRule(nameOrOID string) (*MatchingRuleInfo, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	if info, ok := matchingRules[nameOrOID]; ok {
+		return info, true
+	}
+	info, ok := matchingRules[strings.ToLower(nameOrOID)]
+	return info, ok
+}
+
+// DecodeSyntaxValue decodes the raw value using the registered syntax with
+// the given OID or name. Values of unknown syntaxes or syntaxes without a
+// decode function are returned as string.
+func DecodeSyntaxValue(nameOrOID string, value []byte) (interface{}, error) {
+	info, ok := LookupSyntax(nameOrOID)
+	if !ok || info.Decode == nil {
+		return string(value), nil
+	}
+	return info.Decode(value)
+}
+
+func decodeBooleanValue(value []byte) (interface{}, error) {
+	switch string(value) {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return nil, fmt.Errorf("ldap: invalid Boolean value '%s'", value)
+}
+
+func decodeDNValue(value []byte) (interface{}, error) {
+	return ParseDN(string(value))
+}
+
+func decodeGeneralizedTimeValue(value []byte) (interface{}, error) {
+	return ParseGeneralizedTime(string(value))
+}
+
+func decodeUTCTimeValue(value []byte) (interface{}, error) {
+	return ParseUTCTime(string(value))
+}
+
+func decodeIntegerValue(value []byte) (interface{}, error) {
+	i, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: invalid Integer value '%s': %s", value, err)
+	}
+	return i, nil
+}
+
+func decodeOctetStringValue(value []byte) (interface{}, error) {
+	return append([]byte(nil), value...), nil
+}
@@ -0,0 +1,81 @@
+package ldap
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLookupSyntax(t *testing.T) {
+	for _, nameOrOID := range []string{SyntaxInteger, "Integer", "integer"} {
+		info, ok := LookupSyntax(nameOrOID)
+		if !ok || info.OID != SyntaxInteger {
+			t.Errorf("LookupSyntax(%q) = %v, %t", nameOrOID, info, ok)
+		}
+	}
+	if _, ok := LookupSyntax("1.2.3.4"); ok {
+		t.Error("unexpected syntax for unknown OID")
+	}
+
+	rule, ok := LookupMatchingRule("2.5.13.2")
+	if !ok || rule.Name != "caseIgnoreMatch" || rule.Syntax != SyntaxDirectoryString {
+		t.Errorf("unexpected matching rule %v, %t", rule, ok)
+	}
+	if rule, ok = LookupMatchingRule("CASEIGNOREMATCH"); !ok || rule.OID != "2.5.13.2" {
+		t.Errorf("unexpected matching rule %v, %t", rule, ok)
+	}
+}
+
+func TestDecodeSyntaxValue(t *testing.T) {
+	tests := []struct {
+		syntax   string
+		value    string
+		expected interface{}
+	}{
+		{SyntaxBoolean, "TRUE", true},
+		{SyntaxBoolean, "FALSE", false},
+		{SyntaxInteger, "-42", int64(-42)},
+		{SyntaxGeneralizedTime, "20220315101530Z", time.Date(2022, 3, 15, 10, 15, 30, 0, time.UTC)},
+		{SyntaxDirectoryString, "text", "text"},
+		{"1.2.3.4", "unknown", "unknown"},
+	}
+	for _, test := range tests {
+		v, err := DecodeSyntaxValue(test.syntax, []byte(test.value))
+		if err != nil {
+			t.Errorf("%s %q: %s", test.syntax, test.value, err)
+			continue
+		}
+		if tm, ok := test.expected.(time.Time); ok {
+			if !tm.Equal(v.(time.Time)) {
+				t.Errorf("%s %q: got %v", test.syntax, test.value, v)
+			}
+		} else if v != test.expected {
+			t.Errorf("%s %q: got %#v, expected %#v", test.syntax, test.value, v, test.expected)
+		}
+	}
+
+	v, err := DecodeSyntaxValue(SyntaxDN, []byte("cn=a,dc=example"))
+	if err != nil || v.(*DN).String() != "cn=a,dc=example" {
+		t.Errorf("unexpected DN %v: %v", v, err)
+	}
+	v, err = DecodeSyntaxValue(SyntaxOctetString, []byte{0, 1})
+	if err != nil || !bytes.Equal(v.([]byte), []byte{0, 1}) {
+		t.Errorf("unexpected octet string %v: %v", v, err)
+	}
+
+	for _, syntax := range []string{SyntaxBoolean, SyntaxInteger, SyntaxGeneralizedTime, SyntaxDN} {
+		if _, err := DecodeSyntaxValue(syntax, []byte("bad")); err == nil {
+			t.Errorf("expected error decoding invalid %s value", syntax)
+		}
+	}
+}
+
+func TestRegisterSyntax(t *testing.T) {
+	RegisterSyntax(&SyntaxInfo{OID: "1.2.3.4.5", Name: "Test Syntax", Decode: func(value []byte) (interface{}, error) {
+		return len(value), nil
+	}})
+	v, err := DecodeSyntaxValue("test syntax", []byte("abc"))
+	if err != nil || v != 3 {
+		t.Errorf("unexpected value %v: %v", v, err)
+	}
+}
@@ -0,0 +1,224 @@
+package ldap
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bindThrottleSweepInterval is how often a BindThrottle forgets idle identities
+const bindThrottleSweepInterval = time.Minute
+
+// BindThrottle limits the bind attempts per identity, i.e. per bind DN or
+// user name, on the connections it is set on, see SetBindThrottle and
+// DialWithBindThrottle. It protects the directory server and the accounts,
+// which may be locked after a number of failed binds, when the client binds
+// on behalf of others, e.g. as the backend of a login form. Throttled binds
+// are not sent and fail with a *BindThrottledError.
+//
+// A BindThrottle must not be copied after first use. It can be shared by
+// several connections, e.g. those of a pool, to throttle across them.
+type BindThrottle struct {
+	// Rate is the number of bind attempts allowed per identity and second
+	// on average and Burst the number allowed at once, at least 1. A Rate
+	// of 0 does not limit the attempts.
+	Rate  float64
+	Burst int
+	// Backoff is the time the binds of an identity are refused after a
+	// bind failed with invalid credentials. It doubles with every further
+	// failure up to MaxBackoff, 0 meaning no maximum, until a bind
+	// succeeds. A Backoff of 0 disables it.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	mu         sync.Mutex
+	identities map[string]*bindAttempts
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+// bindAttempts is the state of an identity of a BindThrottle
+type bindAttempts struct {
+	tokens       float64
+	last         time.Time
+	failures     int
+	blockedUntil time.Time
+}
+
+// BindThrottledError is returned for binds refused by a BindThrottle, e.g.
+//
+//	var throttled *ldap.BindThrottledError
+//	if errors.As(err, &throttled) {
+//		...
+//	}
+type BindThrottledError struct {
+	// Identity is the throttled bind DN or user name
+	Identity string
+	// RetryAfter is the time until a bind of the identity is allowed again
+	RetryAfter time.Duration
+	// Failures is the number of consecutive binds of the identity which
+	// failed with invalid credentials
+	Failures int
+}
+
+func (e *BindThrottledError) Error() string {
+	if e.Failures > 0 {
+		return fmt.Sprintf("ldap: bind of %q throttled for %s after %d failed attempts", e.Identity, e.RetryAfter, e.Failures)
+	}
+	return fmt.Sprintf("ldap: bind of %q throttled for %s", e.Identity, e.RetryAfter)
+}
+
+// DialWithBindThrottle sets the BindThrottle of the new connection, see
+// SetBindThrottle
+func DialWithBindThrottle(throttle *BindThrottle) DialOpt {
+	return func(dc *DialContext) {
+		dc.bindThrottle = throttle
+	}
+}
+
+type bindThrottleHolder struct {
+	throttle *BindThrottle
+}
+
+// SetBindThrottle limits the simple, DIGEST-MD5 and NTLM binds with a
+// password on this connection with throttle. Passing nil removes the limit.
+func (l *Conn) SetBindThrottle(throttle *BindThrottle) {
+	l.bindThrottle.Store(bindThrottleHolder{throttle: throttle})
+}
+
+// throttleBind asks the BindThrottle of the connection, if any, for a bind
+// as username. The returned function must be called with the result of the
+// bind.
+func (l *Conn) throttleBind(username string) (func(error), error) {
+	holder, _ := l.bindThrottle.Load().(bindThrottleHolder)
+	t := holder.throttle
+	if t == nil {
+		return func(error) {}, nil
+	}
+	identity := bindIdentity(username)
+	if err := t.acquire(identity); err != nil {
+		l.log(LogLevelWarn, "bind throttled", "dn", username, "error", err)
+		return nil, err
+	}
+	return func(err error) {
+		t.record(identity, err)
+	}, nil
+}
+
+// bindIdentity returns the key of username in a BindThrottle. DNs are
+// normalized, so that differently spelled DNs of an entry share the limit.
+func bindIdentity(username string) string {
+	if dn, err := ParseDN(username); err == nil && len(dn.RDNs) > 0 {
+		return strings.ToLower(dn.String())
+	}
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func (t *BindThrottle) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+func (t *BindThrottle) burst() float64 {
+	if t.Burst < 1 {
+		return 1
+	}
+	return float64(t.Burst)
+}
+
+// idle returns how long an identity must be without attempts before it is
+// forgotten: its tokens are refilled and the backoff no longer matters
+func (t *BindThrottle) idle() time.Duration {
+	idle := t.Backoff
+	if t.MaxBackoff > idle {
+		idle = t.MaxBackoff
+	}
+	if t.Rate > 0 {
+		if refill := time.Duration(t.burst() / t.Rate * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+	return idle
+}
+
+// acquire takes an attempt of identity or returns a *BindThrottledError
+func (t *BindThrottle) acquire(identity string) error {
+	now := t.clock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sweep(now)
+	a := t.identities[identity]
+	if a == nil {
+		if t.identities == nil {
+			t.identities = make(map[string]*bindAttempts)
+		}
+		a = &bindAttempts{tokens: t.burst(), last: now}
+		t.identities[identity] = a
+	}
+	if now.Before(a.blockedUntil) {
+		return &BindThrottledError{Identity: identity, RetryAfter: a.blockedUntil.Sub(now), Failures: a.failures}
+	}
+	if t.Rate > 0 {
+		a.tokens = math.Min(t.burst(), a.tokens+now.Sub(a.last).Seconds()*t.Rate)
+		a.last = now
+		if a.tokens < 1 {
+			retryAfter := time.Duration((1 - a.tokens) / t.Rate * float64(time.Second))
+			return &BindThrottledError{Identity: identity, RetryAfter: retryAfter, Failures: a.failures}
+		}
+		a.tokens--
+	}
+	a.last = now
+	return nil
+}
+
+// record updates the backoff of identity with the result of a bind. Only
+// invalid credentials count as failures, other errors, e.g. of the network,
+// say nothing about the credentials.
+func (t *BindThrottle) record(identity string, err error) {
+	now := t.clock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a := t.identities[identity]
+	if a == nil {
+		return
+	}
+	switch {
+	case err == nil:
+		a.failures = 0
+		a.blockedUntil = time.Time{}
+	case IsErrorWithCode(err, LDAPResultInvalidCredentials):
+		a.failures++
+		if t.Backoff <= 0 {
+			return
+		}
+		backoff := t.Backoff
+		for i := 1; i < a.failures && backoff < math.MaxInt64/2; i++ {
+			backoff *= 2
+		}
+		if t.MaxBackoff > 0 && backoff > t.MaxBackoff {
+			backoff = t.MaxBackoff
+		}
+		a.blockedUntil = now.Add(backoff)
+	}
+}
+
+// sweep forgets the identities idle for long enough, so that the throttle
+// does not grow with every user name ever tried
+func (t *BindThrottle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < bindThrottleSweepInterval {
+		return
+	}
+	t.lastSweep = now
+	idle := t.idle()
+	for identity, a := range t.identities {
+		if !now.Before(a.blockedUntil) && now.Sub(a.last) > idle {
+			delete(t.identities, identity)
+		}
+	}
+}
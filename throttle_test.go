@@ -0,0 +1,94 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindThrottle(t *testing.T) {
+	now := time.Unix(0, 0)
+	throttle := &BindThrottle{Rate: 1, Burst: 2, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	throttle.now = func() time.Time { return now }
+
+	// the burst is used up, then an attempt per second is allowed
+	assert.NoError(t, throttle.acquire("a"))
+	assert.NoError(t, throttle.acquire("a"))
+	var throttled *BindThrottledError
+	if assert.True(t, errors.As(throttle.acquire("a"), &throttled)) {
+		assert.Equal(t, time.Second, throttled.RetryAfter)
+	}
+	assert.NoError(t, throttle.acquire("b"))
+	now = now.Add(time.Second)
+	assert.NoError(t, throttle.acquire("a"))
+
+	// the backoff doubles with each invalid credentials failure
+	throttle = &BindThrottle{Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	throttle.now = func() time.Time { return now }
+	invalid := NewError(LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	for i, backoff := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		assert.NoError(t, throttle.acquire("a"))
+		throttle.record("a", invalid)
+		if assert.True(t, errors.As(throttle.acquire("a"), &throttled)) {
+			assert.Equal(t, backoff, throttled.RetryAfter)
+			assert.Equal(t, i+1, throttled.Failures)
+		}
+		now = now.Add(backoff)
+	}
+
+	// other errors do not count and a success resets the backoff
+	assert.NoError(t, throttle.acquire("a"))
+	throttle.record("a", NewError(ErrorNetwork, errors.New("connection reset")))
+	assert.NoError(t, throttle.acquire("a"))
+	throttle.record("a", nil)
+	assert.NoError(t, throttle.acquire("a"))
+	throttle.record("a", invalid)
+	if assert.True(t, errors.As(throttle.acquire("a"), &throttled)) {
+		assert.Equal(t, time.Second, throttled.RetryAfter)
+		assert.Equal(t, 1, throttled.Failures)
+	}
+
+	// idle identities are forgotten
+	now = now.Add(time.Hour)
+	assert.NoError(t, throttle.acquire("c"))
+	assert.Equal(t, 1, len(throttle.identities))
+}
+
+func TestBindIdentity(t *testing.T) {
+	assert.Equal(t, bindIdentity("cn=John Doe,dc=example,dc=com"), bindIdentity("CN=john doe, DC=Example, DC=com"))
+	assert.Equal(t, "jdoe@example.com", bindIdentity(" JDoe@example.com"))
+}
+
+func TestSetBindThrottle(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+	conn.SetBindThrottle(&BindThrottle{Backoff: time.Minute})
+
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultInvalidCredentials)
+	err := conn.Bind("cn=a,dc=example,dc=com", "wrong")
+	assert.True(t, IsErrorWithCode(err, LDAPResultInvalidCredentials))
+
+	// the second attempt is refused without sending it
+	err = conn.Bind("CN=A,dc=example,dc=com", "wrong")
+	var throttled *BindThrottledError
+	if assert.True(t, errors.As(err, &throttled)) {
+		assert.Equal(t, "cn=a,dc=example,dc=com", throttled.Identity)
+		assert.Equal(t, 1, throttled.Failures)
+	}
+
+	// other identities and unauthenticated binds are not affected
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Bind("cn=b,dc=example,dc=com", "secret"))
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.UnauthenticatedBind("cn=a,dc=example,dc=com"))
+
+	conn.SetBindThrottle(nil)
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultSuccess)
+	assert.NoError(t, conn.Bind("cn=a,dc=example,dc=com", "secret"))
+}
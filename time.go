@@ -0,0 +1,73 @@
+package ldap
+
+import (
+	"fmt"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	generalizedTimeLayout = "20060102150405.999999999Z"
+	utcTimeLayout         = "060102150405Z"
+)
+
+// ParseGeneralizedTime parses a GeneralizedTime value as used by LDAP
+// (https://datatracker.ietf.org/doc/html/rfc4517#section-3.3.13). Minutes
+// and seconds are optional, fractions may be separated by "." or ",", and the
+// time zone is given either as "Z" or as an offset like "+0200" or "-05".
+func ParseGeneralizedTime(value string) (time.Time, error) {
+	t, err := ber.ParseGeneralizedTime([]byte(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ldap: invalid GeneralizedTime '%s': %s", value, err)
+	}
+	return t, nil
+}
+
+// FormatGeneralizedTime formats t as GeneralizedTime in UTC. Fractional
+// seconds are only included if t has them.
+func FormatGeneralizedTime(t time.Time) string {
+	return t.UTC().Format(generalizedTimeLayout)
+}
+
+// ParseUTCTime parses a value of the legacy UTCTime syntax
+// (https://datatracker.ietf.org/doc/html/rfc4517#section-3.3.34), which has
+// a two-digit year. Years 50 to 99 are read as 1950 to 1999, years 00 to 49
+// as 2000 to 2049. Seconds are optional and the time zone is given either as
+// "Z" or as an offset like "+0200".
+func ParseUTCTime(value string) (time.Time, error) {
+	var layout string
+	switch len(value) {
+	case 11:
+		layout = "0601021504Z"
+	case 13:
+		layout = "060102150405Z"
+	case 15:
+		layout = "0601021504-0700"
+	case 17:
+		layout = "060102150405-0700"
+	default:
+		return time.Time{}, fmt.Errorf("ldap: invalid UTCTime '%s'", value)
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ldap: invalid UTCTime '%s': %s", value, err)
+	}
+	// time.Parse reads two-digit years 69 to 99 as 19xx
+	if t.Year() < 1950 {
+		t = t.AddDate(100, 0, 0)
+	} else if t.Year() >= 2050 {
+		t = t.AddDate(-100, 0, 0)
+	}
+	return t, nil
+}
+
+// FormatUTCTime formats t as UTCTime in UTC. Only years 1950 to 2049 can be
+// represented, other years result in an error.
+func FormatUTCTime(t time.Time) (string, error) {
+	t = t.UTC()
+	if t.Year() < 1950 || t.Year() >= 2050 {
+		return "", fmt.Errorf("ldap: year %d out of UTCTime range", t.Year())
+	}
+	return t.Format(utcTimeLayout), nil
+}
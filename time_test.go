@@ -0,0 +1,75 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGeneralizedTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"20220315101530Z", time.Date(2022, 3, 15, 10, 15, 30, 0, time.UTC)},
+		{"20220315101530.25Z", time.Date(2022, 3, 15, 10, 15, 30, 250000000, time.UTC)},
+		{"20220315101530,5Z", time.Date(2022, 3, 15, 10, 15, 30, 500000000, time.UTC)},
+		{"202203151015Z", time.Date(2022, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"20220315101530+0200", time.Date(2022, 3, 15, 8, 15, 30, 0, time.UTC)},
+		{"20220315101530-05", time.Date(2022, 3, 15, 15, 15, 30, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := ParseGeneralizedTime(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+		} else if !got.Equal(test.expected) {
+			t.Errorf("%s: got %s, expected %s", test.value, got, test.expected)
+		}
+	}
+	if _, err := ParseGeneralizedTime("yesterday"); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func TestFormatGeneralizedTime(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	if got := FormatGeneralizedTime(time.Date(2022, 3, 15, 12, 15, 30, 0, loc)); got != "20220315101530Z" {
+		t.Errorf("got %s", got)
+	}
+	if got := FormatGeneralizedTime(time.Date(2022, 3, 15, 10, 15, 30, 250000000, time.UTC)); got != "20220315101530.25Z" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestUTCTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"220315101530Z", time.Date(2022, 3, 15, 10, 15, 30, 0, time.UTC)},
+		{"2203151015Z", time.Date(2022, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"490315101530Z", time.Date(2049, 3, 15, 10, 15, 30, 0, time.UTC)},
+		{"500315101530Z", time.Date(1950, 3, 15, 10, 15, 30, 0, time.UTC)},
+		{"990315101530+0100", time.Date(1999, 3, 15, 9, 15, 30, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := ParseUTCTime(test.value)
+		if err != nil {
+			t.Errorf("%s: %s", test.value, err)
+		} else if !got.Equal(test.expected) {
+			t.Errorf("%s: got %s, expected %s", test.value, got, test.expected)
+		}
+	}
+	for _, value := range []string{"20220315101530Z", "221315101530Z"} {
+		if _, err := ParseUTCTime(value); err == nil {
+			t.Errorf("expected error for %s", value)
+		}
+	}
+
+	s, err := FormatUTCTime(time.Date(1999, 3, 15, 10, 15, 30, 0, time.UTC))
+	if err != nil || s != "990315101530Z" {
+		t.Errorf("got %s: %v", s, err)
+	}
+	if _, err := FormatUTCTime(time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected error for year out of range")
+	}
+}
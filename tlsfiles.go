@@ -0,0 +1,170 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// TLSFiles holds a client certificate and CA certificates for mutual TLS
+// loaded from PEM files. The files are reloaded when their modification time
+// changes, so that long running processes pick up rotated certificates
+// without restarting. Reloading can also be triggered with Reload, e.g. on
+// SIGHUP with ReloadOnSignal. If a reload fails, e.g. because only the
+// certificate but not yet the key was replaced, the previous certificates
+// remain in use.
+//
+// The client certificate is used by TLS connections dialed with
+// DialWithTLSFiles or started with StartTLS(files.Config()), and can be
+// used for an ExternalBind.
+type TLSFiles struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	rootCAs  *x509.CertPool
+	modTimes [3]time.Time
+}
+
+// NewTLSFiles loads the client certificate and key from certFile and
+// keyFile and the CA certificates used to verify the server from caFile.
+// certFile and keyFile may be empty to only load the CA certificates, and
+// caFile may be empty to use the system roots.
+func NewTLSFiles(certFile, keyFile, caFile string) (*TLSFiles, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("ldap: both a certificate and a key file are required")
+	}
+	f := &TLSFiles{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload loads the files again
+func (f *TLSFiles) Reload() error {
+	modTimes, err := f.stat()
+	if err != nil {
+		return err
+	}
+	var cert *tls.Certificate
+	if f.certFile != "" {
+		c, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			return fmt.Errorf("ldap: loading client certificate: %w", err)
+		}
+		cert = &c
+	}
+	var rootCAs *x509.CertPool
+	if f.caFile != "" {
+		pem, err := ioutil.ReadFile(f.caFile)
+		if err != nil {
+			return fmt.Errorf("ldap: loading CA certificates: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ldap: no CA certificates found in %s", f.caFile)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cert = cert
+	f.rootCAs = rootCAs
+	f.modTimes = modTimes
+	return nil
+}
+
+// stat returns the modification times of the files
+func (f *TLSFiles) stat() ([3]time.Time, error) {
+	var modTimes [3]time.Time
+	for i, name := range []string{f.certFile, f.keyFile, f.caFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return modTimes, fmt.Errorf("ldap: %w", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// current reloads the files if they changed and returns the certificates
+func (f *TLSFiles) current() (*tls.Certificate, *x509.CertPool) {
+	modTimes, err := f.stat()
+	f.mu.Lock()
+	changed := err == nil && modTimes != f.modTimes
+	f.mu.Unlock()
+	if changed {
+		if err := f.Reload(); err != nil {
+			logger.Printf("ldap: keeping the previous TLS certificates: %s", err)
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cert, f.rootCAs
+}
+
+// Config returns a new tls.Config with the current CA certificates. The
+// client certificate is looked up on every handshake, so a rotated
+// certificate is used even with an older config. A rotated CA file is only
+// used by the configs returned afterwards. The config may be modified, e.g.
+// to set the ServerName required by StartTLS.
+func (f *TLSFiles) Config() *tls.Config {
+	_, rootCAs := f.current()
+	config := &tls.Config{RootCAs: rootCAs}
+	if f.certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := f.current()
+			return cert, nil
+		}
+	}
+	return config
+}
+
+// ReloadOnSignal reloads the files whenever one of signals, e.g.
+// syscall.SIGHUP, is received, until the returned function is called.
+// Reload errors are logged and the previous certificates remain in use.
+func (f *TLSFiles) ReloadOnSignal(signals ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, signals...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := f.Reload(); err != nil {
+					logger.Printf("ldap: keeping the previous TLS certificates: %s", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// DialWithTLSFiles sets the tls.Config of the new connection to the Config
+// of files, see TLSFiles. It replaces a config set with DialWithTLSConfig.
+func DialWithTLSFiles(files *TLSFiles) DialOpt {
+	return func(dc *DialContext) {
+		dc.tlsFiles = files
+	}
+}
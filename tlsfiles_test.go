@@ -0,0 +1,162 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and
+// its key to name.crt and name.key in dir and returns it
+func writeTestCertificate(t *testing.T, dir, name string, modTime time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ext, block := range map[string]*pem.Block{
+		".crt": {Type: "CERTIFICATE", Bytes: der},
+		".key": {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		path := filepath.Join(dir, name+ext)
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// copyTestFile copies the file from to to with the given modification time
+func copyTestFile(t *testing.T, from, to string, modTime time.Time) {
+	t.Helper()
+	data, err := ioutil.ReadFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(to, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(to, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ldap-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Now().Add(-time.Hour)
+	writeTestCertificate(t, dir, "server", start)
+	first := writeTestCertificate(t, dir, "first", start)
+	second := writeTestCertificate(t, dir, "second", start)
+	cert := filepath.Join(dir, "client.crt")
+	key := filepath.Join(dir, "client.key")
+	copyTestFile(t, filepath.Join(dir, "first.crt"), cert, start)
+	copyTestFile(t, filepath.Join(dir, "first.key"), key, start)
+
+	// the server requires a client certificate and reports the one it got
+	serverKeyPair, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(first)
+	clientCAs.AddCert(second)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	clients := make(chan string, 1)
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if err := c.(*tls.Conn).Handshake(); err != nil {
+				clients <- err.Error()
+			} else {
+				clients <- c.(*tls.Conn).ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			c.Close()
+		}
+	}()
+
+	_, err = NewTLSFiles(cert, "", "")
+	assert.Error(t, err)
+	files, err := NewTLSFiles(cert, key, filepath.Join(dir, "server.crt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dial := func() {
+		t.Helper()
+		conn, err := DialURL("ldaps://"+listener.Addr().String(), DialWithTLSFiles(files))
+		if !assert.NoError(t, err) {
+			return
+		}
+		conn.Close()
+	}
+	dial()
+	assert.Equal(t, "first", <-clients)
+
+	// a half rotated certificate keeps the previous one
+	copyTestFile(t, filepath.Join(dir, "second.crt"), cert, start.Add(time.Minute))
+	config := files.Config()
+	c, err := config.GetClientCertificate(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, first.Raw, c.Certificate[0])
+	}
+
+	copyTestFile(t, filepath.Join(dir, "second.key"), key, start.Add(time.Minute))
+	c, err = config.GetClientCertificate(nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, second.Raw, c.Certificate[0])
+	}
+	dial()
+	assert.Equal(t, "second", <-clients)
+}
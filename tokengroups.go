@@ -0,0 +1,115 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sidLookupBatchSize limits the number of SIDs resolved with one search
+const sidLookupBatchSize = 100
+
+// ADGroup is a group an Active Directory account is a member of
+type ADGroup struct {
+	SID *SID
+	// DN and Name, the sAMAccountName, are empty if the SID could not be
+	// resolved, e.g. for groups of other domains
+	DN   string
+	Name string
+}
+
+// TokenGroups reads the SIDs of all groups the account with the given DN is
+// a member of, including nested groups and its primary group, from the
+// constructed tokenGroups attribute. If globalAndUniversal is true,
+// tokenGroupsGlobalAndUniversal is read instead, which omits domain local
+// groups but includes universal groups of other domains.
+func (l *Conn) TokenGroups(dn string, globalAndUniversal bool) ([]*SID, error) {
+	attribute := "tokenGroups"
+	if globalAndUniversal {
+		attribute = "tokenGroupsGlobalAndUniversal"
+	}
+	// constructed attributes are only returned for base searches
+	entry, err := l.GetEntry(dn, attribute)
+	if err != nil {
+		return nil, err
+	}
+	var sids []*SID
+	for _, value := range entry.GetEqualFoldRawAttributeValues(attribute) {
+		sid, err := ParseSID(value)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: invalid SID in %s: %w", attribute, err)
+		}
+		sids = append(sids, sid)
+	}
+	return sids, nil
+}
+
+// LookupSIDs searches the subtree of baseDN for the objects with the given
+// SIDs, requesting the given attributes in addition to objectSid. The found
+// entries are returned by the string form of their SID; SIDs without an
+// object are missing from the result. If baseDN is empty, the default
+// naming context of the root DSE is used.
+func (l *Conn) LookupSIDs(baseDN string, sids []*SID, attributes ...string) (map[string]*Entry, error) {
+	if baseDN == "" {
+		rootDSE, err := l.RootDSE()
+		if err != nil {
+			return nil, err
+		}
+		baseDN = rootDSE.DefaultNamingContext
+	}
+	attributes = append([]string{"objectSid"}, attributes...)
+
+	entries := make(map[string]*Entry, len(sids))
+	for start := 0; start < len(sids); start += sidLookupBatchSize {
+		end := start + sidLookupBatchSize
+		if end > len(sids) {
+			end = len(sids)
+		}
+		var filter strings.Builder
+		filter.WriteString("(|")
+		for _, sid := range sids[start:end] {
+			filter.WriteString("(objectSid=" + sid.FilterValue() + ")")
+		}
+		filter.WriteString(")")
+
+		result, err := l.Search(NewSearchRequest(
+			baseDN, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false,
+			filter.String(), attributes, nil,
+		))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range result.Entries {
+			sid, err := ParseSID(entry.GetEqualFoldRawAttributeValue("objectSid"))
+			if err != nil {
+				continue
+			}
+			entries[sid.String()] = entry
+		}
+	}
+	return entries, nil
+}
+
+// GetTokenGroups returns the expanded group memberships of the account with
+// the given DN, including nested groups and its primary group, resolving
+// the SIDs read by TokenGroups to the DNs and names of the groups in the
+// default naming context
+func (l *Conn) GetTokenGroups(dn string, globalAndUniversal bool) ([]*ADGroup, error) {
+	sids, err := l.TokenGroups(dn, globalAndUniversal)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := l.LookupSIDs("", sids, "sAMAccountName")
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*ADGroup, 0, len(sids))
+	for _, sid := range sids {
+		group := &ADGroup{SID: sid}
+		if entry, ok := entries[sid.String()]; ok {
+			group.DN = entry.DN
+			group.Name = entry.GetEqualFoldAttributeValue("sAMAccountName")
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
@@ -0,0 +1,64 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetTokenGroups(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	domain, _ := ParseSIDString("S-1-5-21-1004336348-1177238915-682003330")
+	admins, users := domain.WithRID(RIDDomainAdmins), domain.WithRID(RIDDomainUsers)
+	foreign, _ := ParseSIDString("S-1-5-21-1-2-3-1105")
+
+	filters := make(chan string, 1)
+	go func() {
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("cn=user,dc=example", map[string][]string{
+			"tokenGroups": {string(admins.Bytes()), string(users.Bytes()), string(foreign.Bytes())},
+		}))
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry("", map[string][]string{
+			"defaultNamingContext": {"dc=example"},
+		}))
+
+		req, err := ptc.ReceiveRequest()
+		if err != nil {
+			return
+		}
+		filter, _ := DecompileFilter(req.Children[1].Children[6])
+		filters <- filter
+		sendSearchResult(ptc, req.Children[0].Value.(int64), LDAPResultSuccess,
+			NewEntry("cn=Domain Admins,cn=Users,dc=example", map[string][]string{
+				"objectSid": {string(admins.Bytes())}, "sAMAccountName": {"Domain Admins"},
+			}),
+			NewEntry("cn=Domain Users,cn=Users,dc=example", map[string][]string{
+				"objectSid": {string(users.Bytes())}, "sAMAccountName": {"Domain Users"},
+			}),
+		)
+	}()
+
+	groups, err := conn.GetTokenGroups("cn=user,dc=example", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups", len(groups))
+	}
+	if groups[0].Name != "Domain Admins" || groups[0].DN != "cn=Domain Admins,cn=Users,dc=example" || !groups[0].SID.Equal(admins) {
+		t.Errorf("unexpected group %+v", groups[0])
+	}
+	if groups[1].Name != "Domain Users" {
+		t.Errorf("unexpected group %+v", groups[1])
+	}
+	if groups[2].DN != "" || !groups[2].SID.Equal(foreign) {
+		t.Errorf("expected unresolved group, got %+v", groups[2])
+	}
+	if filter := <-filters; strings.Count(filter, "(objectSid=") != 3 {
+		t.Errorf("unexpected lookup filter %s", filter)
+	}
+}
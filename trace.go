@@ -0,0 +1,272 @@
+package ldap
+
+import (
+	"strings"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Span attribute keys recorded on spans created for LDAP operations
+const (
+	SpanAttributeDN            = "ldap.dn"
+	SpanAttributeBaseDN        = "ldap.base_dn"
+	SpanAttributeScope         = "ldap.scope"
+	SpanAttributeFilter        = "ldap.filter"
+	SpanAttributeResultCode    = "ldap.result_code"
+	SpanAttributeEntryCount    = "ldap.entry_count"
+	SpanAttributeCorrelationID = "ldap.correlation_id"
+)
+
+// Span represents a single traced LDAP operation.
+type Span interface {
+	// SetAttribute records a key/value pair on the span
+	SetAttribute(key string, value interface{})
+	// RecordError records an error that caused the operation to fail
+	RecordError(err error)
+	// End marks the operation as finished
+	End()
+}
+
+// Tracer creates spans around LDAP operations. It is the integration point for
+// tracing systems such as OpenTelemetry, which can be adapted to this interface
+// without the ldap package depending on them.
+type Tracer interface {
+	// StartSpan starts a span for the given operation name, e.g. "search" or "bind"
+	StartSpan(operation string) Span
+}
+
+// TraceOpt configures how LDAP operations are traced.
+type TraceOpt func(*traceConfig)
+
+type traceConfig struct {
+	redactFilter func(string) string
+}
+
+// TraceWithFilterRedactor sets the function used to redact search filters before
+// they are recorded on a span. By default RedactFilterValues is used. Pass a nil
+// function to record filters as they are.
+func TraceWithFilterRedactor(redact func(filter string) string) TraceOpt {
+	return func(tc *traceConfig) {
+		tc.redactFilter = redact
+	}
+}
+
+// SetTracer enables tracing of all subsequent operations on this connection
+// using the given Tracer. Passing nil disables tracing.
+func (l *Conn) SetTracer(tracer Tracer, opts ...TraceOpt) {
+	tc := &traceConfig{redactFilter: RedactFilterValues}
+	for _, opt := range opts {
+		opt(tc)
+	}
+
+	l.messageMutex.Lock()
+	defer l.messageMutex.Unlock()
+	l.tracer = tracer
+	l.traceConfig = tc
+}
+
+// RedactFilterValues replaces all assertion values of the given filter with a
+// question mark, keeping the structure of the filter visible. Presence filters
+// like (objectClass=*) are left untouched.
+func RedactFilterValues(filter string) string {
+	var b strings.Builder
+	inValue := false
+	for i := 0; i < len(filter); i++ {
+		c := filter[i]
+		switch {
+		case inValue:
+			// assertion values can't contain an unescaped ')'
+			if c == ')' {
+				inValue = false
+				b.WriteByte(c)
+			}
+		case c == '=':
+			b.WriteByte(c)
+			if strings.HasPrefix(filter[i+1:], "*)") {
+				continue
+			}
+			b.WriteByte('?')
+			inValue = true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// operationTrace holds the span and timing of a single in-flight operation
+type operationTrace struct {
+	conn       *Conn
+	req        request
+	operation  string
+	start      time.Time
+	span       Span
+	slowQuery  *slowQueryConfig
+	entries    int
+	resultCode int64
+	// correlationID is the caller-supplied ID of the operation, if any
+	correlationID string
+	auditor       *Auditor
+	err           error
+}
+
+// startTrace starts tracking the given request, or returns nil if neither
+// tracing nor slow query logging apply or the request doesn't expect a response
+func (l *Conn) startTrace(req request, correlationID string) *operationTrace {
+	return l.startOperationTrace(operationName(req), req, correlationID)
+}
+
+// startOperationTrace is like startTrace for the given operation, req may be
+// nil for operations without a request type, e.g. GSSAPI binds
+func (l *Conn) startOperationTrace(operation string, req request, correlationID string) *operationTrace {
+	l.messageMutex.Lock()
+	tracer, tc, sq, auditor := l.tracer, l.traceConfig, l.slowQuery, l.auditor
+	l.messageMutex.Unlock()
+	if tracer == nil && sq == nil && auditor == nil {
+		return nil
+	}
+
+	if operation == "" {
+		return nil
+	}
+	if sq != nil && operation != "search" && operation != "bind" {
+		sq = nil
+	}
+	if auditor != nil && !isAuditedOperation(operation) {
+		auditor = nil
+	}
+	if tracer == nil && sq == nil && auditor == nil {
+		return nil
+	}
+
+	t := &operationTrace{
+		conn:          l,
+		req:           req,
+		operation:     operation,
+		start:         time.Now(),
+		slowQuery:     sq,
+		resultCode:    -1,
+		correlationID: correlationID,
+		auditor:       auditor,
+	}
+	if tracer == nil {
+		return t
+	}
+
+	span := tracer.StartSpan(operation)
+	if correlationID != "" {
+		span.SetAttribute(SpanAttributeCorrelationID, correlationID)
+	}
+	switch r := req.(type) {
+	case *SearchRequest:
+		span.SetAttribute(SpanAttributeBaseDN, r.BaseDN)
+		span.SetAttribute(SpanAttributeScope, ScopeMap[r.Scope])
+		filter := r.Filter
+		if tc.redactFilter != nil {
+			filter = tc.redactFilter(filter)
+		}
+		span.SetAttribute(SpanAttributeFilter, filter)
+	case *SimpleBindRequest:
+		span.SetAttribute(SpanAttributeDN, r.Username)
+	case *AddRequest:
+		span.SetAttribute(SpanAttributeDN, r.DN)
+	case *DelRequest:
+		span.SetAttribute(SpanAttributeDN, r.DN)
+	case *ModifyRequest:
+		span.SetAttribute(SpanAttributeDN, r.DN)
+	case *ModifyDNRequest:
+		span.SetAttribute(SpanAttributeDN, r.DN)
+	case *CompareRequest:
+		span.SetAttribute(SpanAttributeDN, r.DN)
+	}
+	t.span = span
+
+	return t
+}
+
+// observe records the result code and entry count carried by a response packet
+func (t *operationTrace) observe(packet *ber.Packet) {
+	if len(packet.Children) < 2 {
+		return
+	}
+	response := packet.Children[1]
+	if response.Tag == ApplicationSearchResultEntry {
+		t.entries++
+		return
+	}
+	if response.ClassType == ber.ClassApplication && response.TagType == ber.TypeConstructed && len(response.Children) >= 3 {
+		if code, ok := response.Children[0].Value.(int64); ok {
+			t.resultCode = code
+		}
+	}
+}
+
+// recordError records an error that caused the operation to fail
+func (t *operationTrace) recordError(err error) {
+	t.err = err
+	if t.span != nil {
+		t.span.RecordError(err)
+	}
+}
+
+// endSteps ends the trace of an operation taking several requests, like the
+// DIGEST-MD5, NTLM and GSSAPI binds, whose responses are observed by the
+// operation itself. err is the error of the operation, it is recorded unless
+// it is the result of the last response.
+func (t *operationTrace) endSteps(err error) {
+	if err != nil && (t.resultCode < 0 || !IsErrorWithCode(err, uint16(t.resultCode))) {
+		t.recordError(err)
+	}
+	t.end()
+}
+
+// end finishes the span of the operation and reports it if it was slow
+func (t *operationTrace) end() {
+	if t.span != nil {
+		if t.resultCode >= 0 {
+			t.span.SetAttribute(SpanAttributeResultCode, t.resultCode)
+		}
+		if t.entries > 0 {
+			t.span.SetAttribute(SpanAttributeEntryCount, t.entries)
+		}
+		t.span.End()
+	}
+	if t.slowQuery != nil {
+		if elapsed := time.Since(t.start); elapsed >= t.slowQuery.threshold {
+			t.conn.reportSlowQuery(t, elapsed)
+		}
+	}
+	if t.auditor != nil {
+		t.audit()
+	}
+}
+
+// operationName returns the name of the operation performed by the given
+// request as used for span names
+func operationName(req request) string {
+	switch req.(type) {
+	case *SearchRequest:
+		return "search"
+	case *SimpleBindRequest, *DigestMD5BindRequest, *NTLMBindRequest:
+		return "bind"
+	case *AddRequest:
+		return "add"
+	case *DelRequest:
+		return "delete"
+	case *ModifyRequest:
+		return "modify"
+	case *ModifyDNRequest:
+		return "modifyDN"
+	case *CompareRequest:
+		return "compare"
+	case *PasswordModifyRequest:
+		return "passwordModify"
+	case *ExtendedRequest:
+		return "extended"
+	case unbindRequest, abandonRequest:
+		// there is no response to unbind and abandon requests
+		return ""
+	}
+	return "request"
+}
@@ -0,0 +1,257 @@
+package ldap
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+type testSpan struct {
+	operation  string
+	attributes map[string]interface{}
+	errs       []error
+	ended      bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *testSpan) End() {
+	s.ended = true
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(operation string) Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &testSpan{operation: operation, attributes: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestRedactFilterValues(t *testing.T) {
+	tests := map[string]string{
+		"(cn=john)":                         "(cn=?)",
+		"(objectClass=*)":                   "(objectClass=*)",
+		"(&(uid=jo*)(!(mail=a\\29b)))":      "(&(uid=?)(!(mail=?)))",
+		"(cn:dn:2.4.6.8.10:=Barbara Jones)": "(cn:dn:2.4.6.8.10:=?)",
+		"(age>=21)":                         "(age>=?)",
+	}
+	for filter, expected := range tests {
+		if got := RedactFilterValues(filter); got != expected {
+			t.Errorf("RedactFilterValues(%q) = %q, expected %q", filter, got, expected)
+		}
+	}
+}
+
+func TestTraceSearch(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	tracer := &testTracer{}
+	conn.SetTracer(tracer)
+
+	go respondToSearch(ptc, "cn=john,dc=example,dc=com")
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(cn=john)", nil, nil)
+	if _, err := conn.Search(searchRequest); err != nil {
+		t.Fatalf("search failed: %s", err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.operation != "search" || !span.ended {
+		t.Errorf("unexpected span %q, ended: %t", span.operation, span.ended)
+	}
+	expected := map[string]interface{}{
+		SpanAttributeBaseDN:     "dc=example,dc=com",
+		SpanAttributeScope:      ScopeMap[ScopeWholeSubtree],
+		SpanAttributeFilter:     "(cn=?)",
+		SpanAttributeResultCode: int64(LDAPResultSuccess),
+		SpanAttributeEntryCount: 1,
+	}
+	for key, value := range expected {
+		if span.attributes[key] != value {
+			t.Errorf("span attribute %s = %v, expected %v", key, span.attributes[key], value)
+		}
+	}
+}
+
+func TestSlowQueryCallback(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	var slow []*SlowQuery
+	conn.SetSlowQueryThreshold(time.Nanosecond, func(q *SlowQuery) {
+		slow = append(slow, q)
+	})
+
+	go respondToSearch(ptc, "cn=john,dc=example,dc=com", "cn=jane,dc=example,dc=com")
+
+	searchRequest := NewSearchRequest("dc=example,dc=com", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(cn=j*)", nil, nil)
+	if _, err := conn.Search(searchRequest); err != nil {
+		t.Fatalf("search failed: %s", err)
+	}
+
+	if len(slow) != 1 {
+		t.Fatalf("expected 1 slow query, got %d", len(slow))
+	}
+	q := slow[0]
+	if q.Operation != "search" || q.BaseDN != "dc=example,dc=com" || q.Filter != "(cn=j*)" || q.Entries != 2 || q.ResultCode != LDAPResultSuccess || q.Duration <= 0 {
+		t.Errorf("unexpected slow query: %+v", q)
+	}
+}
+
+func TestTraceMultiStepBind(t *testing.T) {
+	client, server := net.Pipe()
+	conn := NewConn(client, false)
+	conn.Start()
+	defer conn.Close()
+
+	tracer := &testTracer{}
+	conn.SetTracer(tracer)
+	var slow []*SlowQuery
+	conn.SetSlowQueryThreshold(time.Nanosecond, func(q *SlowQuery) {
+		slow = append(slow, q)
+	})
+	var buf bytes.Buffer
+	conn.SetLogger(NewStdStructuredLogger(log.New(&buf, "", 0), LogLevelInfo))
+
+	// the GSSAPI bind takes two requests
+	s := &saslServer{conn: server, offered: SASLSecurityNone}
+	done := make(chan error, 1)
+	go func() { done <- s.serve(false) }()
+	if err := conn.GSSAPIBind(&fakeGSSAPIClient{}, "ldap/dc.example.com", ""); err != nil {
+		t.Fatalf("bind failed: %s", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.operation != "bind" || !span.ended || len(span.errs) != 0 {
+		t.Errorf("unexpected span %q, ended: %t, errors: %v", span.operation, span.ended, span.errs)
+	}
+	if code := span.attributes[SpanAttributeResultCode]; code != int64(LDAPResultSuccess) {
+		t.Errorf("unexpected result code %v", code)
+	}
+	if len(slow) != 1 || slow[0].Operation != "bind" || slow[0].ResultCode != LDAPResultSuccess {
+		t.Errorf("unexpected slow queries: %+v", slow)
+	}
+	if expected := "INFO bind succeeded mechanism=GSSAPI\n"; buf.String() != expected {
+		t.Errorf("got log %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestTraceMultiStepBindFailure(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	tracer := &testTracer{}
+	conn.SetTracer(tracer)
+	var buf bytes.Buffer
+	conn.SetLogger(NewStdStructuredLogger(log.New(&buf, "", 0), LogLevelInfo))
+
+	go respondWithResult(ptc, ApplicationBindResponse, LDAPResultInvalidCredentials)
+	_, err := conn.DigestMD5Bind(&DigestMD5BindRequest{Host: "ldap.example.com", Username: "mario", Password: "secret"})
+	if !IsErrorWithCode(err, LDAPResultInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	// the result code tells the failure, the span only records other errors
+	if !span.ended || len(span.errs) != 0 || span.attributes[SpanAttributeResultCode] != int64(LDAPResultInvalidCredentials) {
+		t.Errorf("unexpected span %+v", span)
+	}
+	if !strings.HasPrefix(buf.String(), "WARN bind failed mechanism=DIGEST-MD5 dn=mario error=") {
+		t.Errorf("unexpected log %q", buf.String())
+	}
+}
+
+// respondToSearch answers the next request received by ptc with the given
+// entries and a successful search result done message
+func respondToSearch(ptc *packetTranslatorConn, dns ...string) {
+	respondToSearchWithCode(ptc, LDAPResultSuccess, dns...)
+}
+
+// respondToSearchWithCode is like respondToSearch, but ends the search with
+// the given result code
+func respondToSearchWithCode(ptc *packetTranslatorConn, resultCode uint16, dns ...string) {
+	entries := make([]*Entry, 0, len(dns))
+	for _, dn := range dns {
+		entries = append(entries, &Entry{DN: dn})
+	}
+	respondToSearchWithEntries(ptc, resultCode, entries...)
+}
+
+// respondToSearchWithEntries answers a search request with the given entries
+// including their attributes, ending the search with resultCode
+func respondToSearchWithEntries(ptc *packetTranslatorConn, resultCode uint16, entries ...*Entry) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return
+	}
+	sendSearchResult(ptc, req.Children[0].Value.(int64), resultCode, entries...)
+}
+
+// sendSearchResult sends the entries and the search result done message as
+// response to the search with the given message ID
+func sendSearchResult(ptc *packetTranslatorConn, messageID int64, resultCode uint16, entries ...*Entry) {
+	for _, e := range entries {
+		entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		searchEntry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultEntry, nil, "Search Result Entry")
+		searchEntry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "Object Name"))
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for _, attr := range e.Attributes {
+			attribute := Attribute{Type: attr.Name, Vals: attr.Values}
+			attributes.AppendChild(attribute.encode())
+		}
+		searchEntry.AppendChild(attributes)
+		entry.AppendChild(searchEntry)
+		_ = ptc.SendResponse(entry)
+	}
+
+	done := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	searchDone := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ApplicationSearchResultDone, nil, "Search Result Done")
+	searchDone.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	searchDone.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	searchDone.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	done.AppendChild(searchDone)
+	_ = ptc.SendResponse(done)
+}
@@ -0,0 +1,154 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UserAccountControl holds the flags of the userAccountControl attribute of
+// Active Directory accounts
+// (https://learn.microsoft.com/en-us/troubleshoot/windows-server/active-directory/useraccountcontrol-manipulate-account-properties).
+// It can be used as field type with Unmarshal and MarshalEntry.
+type UserAccountControl uint32
+
+// userAccountControl flags
+const (
+	UACScript                     UserAccountControl = 0x0001
+	UACAccountDisable             UserAccountControl = 0x0002
+	UACHomedirRequired            UserAccountControl = 0x0008
+	UACLockout                    UserAccountControl = 0x0010
+	UACPasswdNotRequired          UserAccountControl = 0x0020
+	UACPasswdCantChange           UserAccountControl = 0x0040
+	UACEncryptedTextPwdAllowed    UserAccountControl = 0x0080
+	UACTempDuplicateAccount       UserAccountControl = 0x0100
+	UACNormalAccount              UserAccountControl = 0x0200
+	UACInterdomainTrustAccount    UserAccountControl = 0x0800
+	UACWorkstationTrustAccount    UserAccountControl = 0x1000
+	UACServerTrustAccount         UserAccountControl = 0x2000
+	UACDontExpirePassword         UserAccountControl = 0x10000
+	UACMNSLogonAccount            UserAccountControl = 0x20000
+	UACSmartcardRequired          UserAccountControl = 0x40000
+	UACTrustedForDelegation       UserAccountControl = 0x80000
+	UACNotDelegated               UserAccountControl = 0x100000
+	UACUseDESKeyOnly              UserAccountControl = 0x200000
+	UACDontReqPreauth             UserAccountControl = 0x400000
+	UACPasswordExpired            UserAccountControl = 0x800000
+	UACTrustedToAuthForDelegation UserAccountControl = 0x1000000
+	UACPartialSecretsAccount      UserAccountControl = 0x4000000
+	UACUseAESKeys                 UserAccountControl = 0x8000000
+)
+
+// userAccountControlNames maps the flags to the names used by Microsoft
+var userAccountControlNames = map[UserAccountControl]string{
+	UACScript:                     "SCRIPT",
+	UACAccountDisable:             "ACCOUNTDISABLE",
+	UACHomedirRequired:            "HOMEDIR_REQUIRED",
+	UACLockout:                    "LOCKOUT",
+	UACPasswdNotRequired:          "PASSWD_NOTREQD",
+	UACPasswdCantChange:           "PASSWD_CANT_CHANGE",
+	UACEncryptedTextPwdAllowed:    "ENCRYPTED_TEXT_PWD_ALLOWED",
+	UACTempDuplicateAccount:       "TEMP_DUPLICATE_ACCOUNT",
+	UACNormalAccount:              "NORMAL_ACCOUNT",
+	UACInterdomainTrustAccount:    "INTERDOMAIN_TRUST_ACCOUNT",
+	UACWorkstationTrustAccount:    "WORKSTATION_TRUST_ACCOUNT",
+	UACServerTrustAccount:         "SERVER_TRUST_ACCOUNT",
+	UACDontExpirePassword:         "DONT_EXPIRE_PASSWORD",
+	UACMNSLogonAccount:            "MNS_LOGON_ACCOUNT",
+	UACSmartcardRequired:          "SMARTCARD_REQUIRED",
+	UACTrustedForDelegation:       "TRUSTED_FOR_DELEGATION",
+	UACNotDelegated:               "NOT_DELEGATED",
+	UACUseDESKeyOnly:              "USE_DES_KEY_ONLY",
+	UACDontReqPreauth:             "DONT_REQ_PREAUTH",
+	UACPasswordExpired:            "PASSWORD_EXPIRED",
+	UACTrustedToAuthForDelegation: "TRUSTED_TO_AUTH_FOR_DELEGATION",
+	UACPartialSecretsAccount:      "PARTIAL_SECRETS_ACCOUNT",
+	UACUseAESKeys:                 "USE_AES_KEYS",
+}
+
+// Has returns true if all the given flags are set
+func (u UserAccountControl) Has(flags UserAccountControl) bool {
+	return u&flags == flags
+}
+
+// Set returns u with the given flags set
+func (u UserAccountControl) Set(flags UserAccountControl) UserAccountControl {
+	return u | flags
+}
+
+// Clear returns u with the given flags cleared
+func (u UserAccountControl) Clear(flags UserAccountControl) UserAccountControl {
+	return u &^ flags
+}
+
+// String lists the names of the set flags separated by "|", e.g.
+// "ACCOUNTDISABLE|NORMAL_ACCOUNT". Unknown flags are listed in hexadecimal.
+func (u UserAccountControl) String() string {
+	var names []string
+	for i := uint(0); i < 32; i++ {
+		flag := UserAccountControl(1) << i
+		if !u.Has(flag) {
+			continue
+		}
+		if name, ok := userAccountControlNames[flag]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("0x%X", uint32(flag)))
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// UnmarshalLDAP implements Unmarshaler
+func (u *UserAccountControl) UnmarshalLDAP(values [][]byte) error {
+	v, err := parseADInteger(values)
+	*u = UserAccountControl(v)
+	return err
+}
+
+// MarshalLDAP implements Marshaler
+func (u UserAccountControl) MarshalLDAP() ([][]byte, error) {
+	return [][]byte{[]byte(strconv.FormatUint(uint64(u), 10))}, nil
+}
+
+// maxUserAccountControlAttempts limits how often ModifyUserAccountControl
+// retries when the attribute was changed concurrently
+const maxUserAccountControlAttempts = 5
+
+// ModifyUserAccountControl sets and clears the given flags of the account
+// with the given DN and returns the resulting value. The attribute is read
+// and written back with an assertion on the read value, so concurrent
+// changes to other flags are not lost; the update is retried if the value
+// changed in between.
+func (l *Conn) ModifyUserAccountControl(dn string, set, clear UserAccountControl) (UserAccountControl, error) {
+	for attempt := 0; ; attempt++ {
+		entry, err := l.GetEntry(dn, "userAccountControl")
+		if err != nil {
+			return 0, err
+		}
+		raw := entry.GetAttributeValue("userAccountControl")
+		var current UserAccountControl
+		if err := current.UnmarshalLDAP([][]byte{[]byte(raw)}); err != nil {
+			return 0, err
+		}
+		updated := current.Set(set).Clear(clear)
+		if updated == current {
+			return current, nil
+		}
+
+		assertion, err := NewControlAssertion("(userAccountControl=" + EscapeFilter(raw) + ")")
+		if err != nil {
+			return 0, err
+		}
+		req := NewModifyRequest(dn, []Control{assertion})
+		req.Replace("userAccountControl", []string{strconv.FormatUint(uint64(updated), 10)})
+		err = l.Modify(req)
+		if err == nil {
+			return updated, nil
+		}
+		if !errors.Is(err, ErrAssertionFailed) || attempt+1 >= maxUserAccountControlAttempts {
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package ldap
+
+import (
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// respondWithResult answers the next request with a response of the given
+// application tag and result code and returns the request
+func respondWithResult(ptc *packetTranslatorConn, application ber.Tag, resultCode uint16) (*ber.Packet, error) {
+	return respondWithResultControls(ptc, application, resultCode)
+}
+
+// respondWithResultControls is like respondWithResult, but attaches the given
+// controls to the response
+func respondWithResultControls(ptc *packetTranslatorConn, application ber.Tag, resultCode uint16, controls ...Control) (*ber.Packet, error) {
+	req, err := ptc.ReceiveRequest()
+	if err != nil {
+		return nil, err
+	}
+	response := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, req.Children[0].Value.(int64), "MessageID"))
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, ApplicationMap[uint8(application)])
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	response.AppendChild(result)
+	if len(controls) > 0 {
+		response.AppendChild(encodeControls(controls))
+	}
+	return req, ptc.SendResponse(response)
+}
+
+func TestUserAccountControl(t *testing.T) {
+	u := UACNormalAccount
+	if u.Has(UACAccountDisable) {
+		t.Error("unexpected ACCOUNTDISABLE")
+	}
+	u = u.Set(UACAccountDisable | UACDontExpirePassword)
+	if !u.Has(UACAccountDisable|UACDontExpirePassword) || u != 0x10202 {
+		t.Errorf("got %#x", uint32(u))
+	}
+	if s := u.String(); s != "ACCOUNTDISABLE|NORMAL_ACCOUNT|DONT_EXPIRE_PASSWORD" {
+		t.Errorf("got %s", s)
+	}
+	if u = u.Clear(UACAccountDisable); u != 0x10200 {
+		t.Errorf("got %#x", uint32(u))
+	}
+	if s := UserAccountControl(0x400).String(); s != "0x400" {
+		t.Errorf("got %s", s)
+	}
+}
+
+func TestModifyUserAccountControl(t *testing.T) {
+	ptc := newPacketTranslatorConn()
+	defer ptc.Close()
+
+	conn := NewConn(ptc, false)
+	conn.Start()
+	defer conn.Close()
+
+	const dn = "cn=user,dc=example"
+	errs := make(chan error, 1)
+	go func() {
+		// the first attempt fails as the value was changed concurrently
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry(dn, map[string][]string{"userAccountControl": {"512"}}))
+		if _, err := respondWithResult(ptc, ApplicationModifyResponse, LDAPResultAssertionFailed); err != nil {
+			errs <- err
+			return
+		}
+		respondToSearchWithEntries(ptc, LDAPResultSuccess, NewEntry(dn, map[string][]string{"userAccountControl": {"66048"}}))
+		req, err := respondWithResult(ptc, ApplicationModifyResponse, LDAPResultSuccess)
+		if err != nil {
+			errs <- err
+			return
+		}
+		controls := req.Children[2]
+		control, err := DecodeControl(controls.Children[0])
+		if err != nil {
+			errs <- err
+			return
+		}
+		if filter := control.(*ControlAssertion).Filter; filter != "(userAccountControl=66048)" {
+			t.Errorf("got assertion %s", filter)
+		}
+		errs <- nil
+	}()
+
+	u, err := conn.ModifyUserAccountControl(dn, UACAccountDisable, UACDontExpirePassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != UACNormalAccount|UACAccountDisable {
+		t.Errorf("got %s", u)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,188 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// ControlServerSideSortingResult is the response control of a search with
+// server side sorting, see RFC 2891 section 1.2
+type ControlServerSideSortingResult struct {
+	Criticality bool
+	// Result is the result code of the sort, e.g. LDAPResultSuccess or
+	// LDAPResultNoSuchAttribute
+	Result uint16
+	// AttributeType is the sort key attribute which caused the failure, if
+	// the server reported it
+	AttributeType string
+}
+
+// GetControlType returns the OID
+func (c *ControlServerSideSortingResult) GetControlType() string {
+	return ControlTypeServerSideSortingResult
+}
+
+// Encode returns the ber packet representation
+func (c *ControlServerSideSortingResult) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeServerSideSortingResult, "Control Type ("+ControlTypeMap[ControlTypeServerSideSortingResult]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Sort Result)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortResult")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.Result), "Sort Result"))
+	if c.AttributeType != "" {
+		seq.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, c.AttributeType, "Attribute Type"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlServerSideSortingResult) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  Result: %s  AttributeType: %s",
+		ControlTypeMap[ControlTypeServerSideSortingResult],
+		ControlTypeServerSideSortingResult,
+		c.Criticality,
+		LDAPResultCodeMap[c.Result],
+		c.AttributeType)
+}
+
+// decodeSortResultControl decodes the children of a sort response control
+// value
+func decodeSortResultControl(criticality bool, children []*ber.Packet) (Control, error) {
+	if len(children) < 1 || len(children) > 2 {
+		return nil, errors.New("ldap: invalid sort result control value")
+	}
+	children[0].Description = "Sort Result"
+	result, ok := children[0].Value.(int64)
+	if !ok {
+		return nil, errors.New("ldap: invalid sort result code")
+	}
+	c := &ControlServerSideSortingResult{Criticality: criticality, Result: uint16(result)}
+	if len(children) == 2 {
+		children[1].Description = "Attribute Type"
+		c.AttributeType = children[1].Data.String()
+	}
+	return c, nil
+}
+
+// ControlVLVResponse is the response control of a search with a virtual list
+// view, see draft-ietf-ldapext-ldapv3-vlv section 6.2
+type ControlVLVResponse struct {
+	Criticality bool
+	// TargetPosition is the position of the target entry in the list,
+	// starting with 1
+	TargetPosition int64
+	// ContentCount is the server's estimate of the size of the list
+	ContentCount int64
+	// Result is the result code of the virtual list view, e.g.
+	// LDAPResultSuccess or LDAPResultOffsetRangeError
+	Result uint16
+	// ContextID is the opaque state of the list to send with the next
+	// request, if the server returned one
+	ContextID []byte
+}
+
+// GetControlType returns the OID
+func (c *ControlVLVResponse) GetControlType() string {
+	return ControlTypeVLVResponse
+}
+
+// Encode returns the ber packet representation
+func (c *ControlVLVResponse) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ControlTypeVLVResponse, "Control Type ("+ControlTypeMap[ControlTypeVLVResponse]+")"))
+	if c.Criticality {
+		packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (VLV Response)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VirtualListViewResponse")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.TargetPosition, "Target Position"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.ContentCount, "Content Count"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(c.Result), "Virtual List View Result"))
+	if c.ContextID != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.ContextID), "Context ID"))
+	}
+	value.AppendChild(seq)
+	packet.AppendChild(value)
+	return packet
+}
+
+// String returns a human-readable description
+func (c *ControlVLVResponse) String() string {
+	return fmt.Sprintf(
+		"Control Type: %s (%q)  Criticality: %t  TargetPosition: %d  ContentCount: %d  Result: %s  ContextID: %q",
+		ControlTypeMap[ControlTypeVLVResponse],
+		ControlTypeVLVResponse,
+		c.Criticality,
+		c.TargetPosition,
+		c.ContentCount,
+		LDAPResultCodeMap[c.Result],
+		c.ContextID)
+}
+
+// decodeVLVResponseControl decodes the children of a VLV response control
+// value
+func decodeVLVResponseControl(criticality bool, children []*ber.Packet) (Control, error) {
+	if len(children) < 3 || len(children) > 4 {
+		return nil, errors.New("ldap: invalid VLV response control value")
+	}
+	children[0].Description = "Target Position"
+	children[1].Description = "Content Count"
+	children[2].Description = "Virtual List View Result"
+	targetPosition, ok := children[0].Value.(int64)
+	if !ok {
+		return nil, errors.New("ldap: invalid VLV target position")
+	}
+	contentCount, ok := children[1].Value.(int64)
+	if !ok {
+		return nil, errors.New("ldap: invalid VLV content count")
+	}
+	result, ok := children[2].Value.(int64)
+	if !ok {
+		return nil, errors.New("ldap: invalid VLV result code")
+	}
+	c := &ControlVLVResponse{
+		Criticality:    criticality,
+		TargetPosition: targetPosition,
+		ContentCount:   contentCount,
+		Result:         uint16(result),
+	}
+	if len(children) == 4 {
+		children[3].Description = "Context ID"
+		c.ContextID = children[3].Data.Bytes()
+	}
+	return c, nil
+}
+
+// SortResult returns the server side sorting response control of the
+// search, nil if the server did not return one
+func (s *SearchResult) SortResult() *ControlServerSideSortingResult {
+	c, _ := FindControl(s.Controls, ControlTypeServerSideSortingResult).(*ControlServerSideSortingResult)
+	return c
+}
+
+// VLVResponse returns the virtual list view response control of the search,
+// nil if the server did not return one
+func (s *SearchResult) VLVResponse() *ControlVLVResponse {
+	c, _ := FindControl(s.Controls, ControlTypeVLVResponse).(*ControlVLVResponse)
+	return c
+}
+
+// SizeEstimate returns the server's estimate of the total number of entries
+// of a paged search, reported in the size of the last paging control which
+// has one, and false if the server did not provide one
+func (s *SearchResult) SizeEstimate() (int, bool) {
+	for i := len(s.Controls) - 1; i >= 0; i-- {
+		if c, ok := s.Controls[i].(*ControlPaging); ok && c.PagingSize > 0 {
+			return int(c.PagingSize), true
+		}
+	}
+	return 0, false
+}
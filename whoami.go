@@ -5,7 +5,6 @@ package ldap
 // https://tools.ietf.org/html/rfc4532
 
 import (
-	"errors"
 	"fmt"
 
 	ber "github.com/go-asn1-ber/asn1-ber"
@@ -42,7 +41,7 @@ func (l *Conn) WhoAmI(controls []Control) (*WhoAmIResult, error) {
 
 	l.Debug.PrintPacket(packet)
 
-	msgCtx, err := l.sendMessage(packet)
+	msgCtx, err := l.sendRequest(packet, 0, "")
 	if err != nil {
 		return nil, err
 	}
@@ -50,28 +49,11 @@ func (l *Conn) WhoAmI(controls []Control) (*WhoAmIResult, error) {
 
 	result := &WhoAmIResult{}
 
-	l.Debug.Printf("%d: waiting for response", msgCtx.id)
-	packetResponse, ok := <-msgCtx.responses
-	if !ok {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: response channel closed"))
-	}
-	packet, err = packetResponse.ReadPacket()
-	l.Debug.Printf("%d: got response %p", msgCtx.id, packet)
+	packet, err = l.readPacket(msgCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	if packet == nil {
-		return nil, NewError(ErrorNetwork, errors.New("ldap: could not retrieve message"))
-	}
-
-	if l.Debug {
-		if err := addLDAPDescriptions(packet); err != nil {
-			return nil, err
-		}
-		ber.PrintPacket(packet)
-	}
-
 	if packet.Children[1].Tag == ApplicationExtendedResponse {
 		if err := GetLDAPError(packet); err != nil {
 			return nil, err